@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// priceOracleTimeout bounds a price oracle request so a slow oracle
+// doesn't hold up the asset list.
+const priceOracleTimeout = 5 * time.Second
+
+// priceCacheTTL is how long a price oracle answer is served before the
+// oracle is asked again.
+const priceCacheTTL = time.Minute
+
+// fetchAssetPrices reads the USD price of each asset from the price oracle,
+// which answers with a JSON object mapping asset names to prices.
+func (s *Server) fetchAssetPrices(ctx context.Context) (map[string]float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, priceOracleTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.priceOracleURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("price oracle returned %s", resp.Status)
+	}
+	var prices map[string]float64
+	if err := json.NewDecoder(resp.Body).Decode(&prices); err != nil {
+		return nil, fmt.Errorf("decode prices: %w", err)
+	}
+	return prices, nil
+}
+
+// assetPrices returns the cached asset prices, refreshing them from the
+// price oracle once they are older than priceCacheTTL. A failed refresh
+// keeps serving the previous answer. The oracle is asked without holding
+// pricesMu, so a slow oracle doesn't block readers of the cache.
+func (s *Server) assetPrices(ctx context.Context) map[string]float64 {
+	s.pricesMu.Lock()
+	cached, fresh := s.prices, s.prices != nil && time.Since(s.pricesAt) < priceCacheTTL
+	s.pricesMu.Unlock()
+	if fresh {
+		return cached
+	}
+
+	prices, err := s.fetchAssetPrices(ctx)
+	if err != nil {
+		s.logger.Warn("failed to fetch asset prices", zap.Error(err))
+		return cached
+	}
+
+	s.pricesMu.Lock()
+	s.prices = prices
+	s.pricesAt = time.Now()
+	s.pricesMu.Unlock()
+	return prices
+}
+
+// handleListAssets lists the assets channels can be funded with and their
+// USD exchange rates. Rates are null when no price oracle is configured or
+// it doesn't know the asset.
+func (s *Server) handleListAssets(c *gin.Context) {
+	var prices map[string]float64
+	if s.priceOracleURL != "" {
+		prices = s.assetPrices(c.Request.Context())
+	}
+
+	registered := s.assets.List()
+	assets := make([]gin.H, 0, len(registered))
+	for _, a := range registered {
+		entry := gin.H{
+			"name":       a.Name,
+			"type":       "ckbytes",
+			"is_payment": a.Name == s.paymentAssetName,
+			"usd_rate":   nil,
+		}
+		if a.IsSUDT() {
+			entry["type"] = "sudt"
+			entry["issuer_lock_hash"] = a.IssuerLockHash.Hex()
+		}
+		if rate, ok := prices[a.Name]; ok {
+			entry["usd_rate"] = rate
+		}
+		assets = append(assets, entry)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"assets":        assets,
+		"payment_asset": s.paymentAssetName,
+	})
+}
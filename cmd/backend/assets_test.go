@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
+	"go.uber.org/zap"
+
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
+)
+
+func TestHandleListAssets(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var hits int
+	oracle := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Write([]byte(`{"CKBytes": 0.01}`))
+	}))
+	defer oracle.Close()
+
+	s := newTestServer(t)
+	s.assets.RegisterSUDT(types.HexToHash("0x1111111111111111111111111111111111111111111111111111111111111111"), "USDI")
+	s.priceOracleURL = oracle.URL
+
+	r := gin.New()
+	r.GET("/api/v1/assets", s.handleListAssets)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/assets", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		PaymentAsset string `json:"payment_asset"`
+		Assets       []struct {
+			Name    string   `json:"name"`
+			Type    string   `json:"type"`
+			USDRate *float64 `json:"usd_rate"`
+		} `json:"assets"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.PaymentAsset != "CKBytes" || len(resp.Assets) != 2 {
+		t.Fatalf("Unexpected response: %s", w.Body.String())
+	}
+	if ckb := resp.Assets[0]; ckb.Type != "ckbytes" || ckb.USDRate == nil || *ckb.USDRate != 0.01 {
+		t.Errorf("Expected CKBytes priced at 0.01, got %s", w.Body.String())
+	}
+	if usdi := resp.Assets[1]; usdi.Name != "USDI" || usdi.Type != "sudt" || usdi.USDRate != nil {
+		t.Errorf("Expected unpriced USDI SUDT, got %s", w.Body.String())
+	}
+
+	// A second request is served from the cache
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/assets", nil))
+	if hits != 1 {
+		t.Errorf("Expected the oracle asked once, got %d", hits)
+	}
+}
+
+func TestNewServer_RejectsPaymentAsset(t *testing.T) {
+	assets := perun.NewAssetRegistry()
+	assets.RegisterSUDT(types.HexToHash("0x1111111111111111111111111111111111111111111111111111111111111111"), "USDI")
+
+	for _, name := range []string{"UNKNOWN", "USDI"} {
+		if _, err := NewServer(&ServerConfig{Logger: zap.NewNop(), Assets: assets, PaymentAsset: name}); err == nil {
+			t.Errorf("Expected payment asset %q to be rejected", name)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+)
+
+// Audited actions.
+const (
+	auditWalletCreated        = "wallet_created"
+	auditSessionCreated       = "session_created"
+	auditSessionEnded         = "session_ended"
+	auditChannelOpened        = "channel_opened"
+	auditChannelClosed        = "channel_closed"
+	auditRateChanged          = "rate_changed"
+	auditRefund               = "refund"
+	auditMACAuthorized        = "mac_authorized"
+	auditMACDeauthorized      = "mac_deauthorized"
+	auditWalletsExported      = "wallets_exported"
+	auditWalletsImported      = "wallets_imported"
+	auditDBCompacted          = "db_compacted"
+	auditBackupCodesGenerated = "backup_codes_generated"
+	auditBackupCodeUsed       = "backup_code_used"
+	auditTOTPEnabled          = "totp_enabled"
+	auditSessionExpiryChanged = "session_expiry_changed"
+	auditSessionTransferred   = "session_transferred"
+	auditEarningsSynced       = "earnings_synced"
+)
+
+// auditActor identifies who triggered an audited action.
+type auditActor struct {
+	Type db.ActorType
+	ID   string
+}
+
+// systemActor is the actor for background workers and guest-facing flows.
+var systemActor = auditActor{Type: db.ActorSystem}
+
+// requestActor returns the actor behind a request: the API key or dashboard
+// session that authorized it, otherwise the system.
+func (s *Server) requestActor(c *gin.Context) auditActor {
+	if keyID := c.GetString("api_key_id"); keyID != "" {
+		return auditActor{Type: db.ActorAPIKey, ID: keyID}
+	}
+	if s.hasDashboardSession(c) {
+		return auditActor{Type: db.ActorHost}
+	}
+	return systemActor
+}
+
+// audit records an action in the audit log. Failures are logged but never
+// fail the action itself.
+func (s *Server) audit(actor auditActor, action, sessionID, walletID, details string) {
+	err := s.db.LogAudit(&db.AuditEntry{
+		Action:    action,
+		SessionID: sessionID,
+		WalletID:  walletID,
+		ActorType: actor.Type,
+		ActorID:   actor.ID,
+		Details:   details,
+	})
+	if err != nil {
+		s.logger.Error("failed to write audit log",
+			zap.String("action", action),
+			zap.String("session_id", sessionID),
+			zap.Error(err),
+		)
+	}
+}
+
+// handleAuditLog lists audit entries, oldest first, optionally filtered by
+// time range and session.
+func (s *Server) handleAuditLog(c *gin.Context) {
+	var req struct {
+		From      time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+		To        time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+		SessionID string    `form:"session_id"`
+		Limit     int       `form:"limit"`
+		Offset    int       `form:"offset"`
+	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid query: " + err.Error()})
+		return
+	}
+
+	if req.Limit <= 0 || req.Limit > 500 {
+		req.Limit = 100
+	}
+
+	entries, err := s.db.ListAuditLog(&db.AuditQuery{
+		From:      req.From,
+		To:        req.To,
+		SessionID: req.SessionID,
+		Limit:     req.Limit,
+		Offset:    req.Offset,
+	})
+	if err != nil {
+		s.logger.Error("failed to list audit log", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list audit log"})
+		return
+	}
+
+	result := make([]gin.H, 0, len(entries))
+	for _, e := range entries {
+		result = append(result, gin.H{
+			"id":         e.ID,
+			"timestamp":  e.Timestamp.Format(time.RFC3339),
+			"action":     e.Action,
+			"session_id": e.SessionID,
+			"wallet_id":  e.WalletID,
+			"actor_type": e.ActorType,
+			"actor_id":   e.ActorID,
+			"details":    e.Details,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": result,
+		"count":   len(result),
+		"limit":   req.Limit,
+		"offset":  req.Offset,
+	})
+}
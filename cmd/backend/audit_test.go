@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
+	"go.uber.org/zap"
+
+	"github.com/airfi/airfi-perun-nervous/internal/auth"
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+	"github.com/airfi/airfi-perun-nervous/internal/guest"
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
+	"github.com/airfi/airfi-perun-nervous/internal/router"
+)
+
+func TestAuditLog_SessionLifecycle(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+	s.ckbClient = &fundedRPCClient{}
+	s.withdrawer = perun.NewWithdrawer(s.ckbClient, zap.NewNop())
+	s.walletManager = guest.NewWalletManager(types.NetworkTest)
+	s.router = &router.NoopRouter{}
+
+	opened := make(chan struct{})
+	s.channelOpener = func(ctx context.Context, wallet *db.GuestWallet, sessionID string, balanceCKB int64) {
+		close(opened)
+	}
+
+	r := gin.New()
+	r.POST("/api/v1/wallet/guest", s.handleCreateGuestWallet)
+	r.PUT("/api/v1/settings/rate", s.handleUpdateRate)
+	r.GET("/api/v1/admin/audit-log", s.dashboardAuthMiddleware(auth.APIKeyScopeRead), s.handleAuditLog)
+
+	// Guest creates a wallet from the captive portal
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/wallet/guest",
+		strings.NewReader(`{"mac_address":"aa:bb:cc:dd:ee:ff","ip_address":"192.168.1.50"}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 creating wallet, got %d: %s", w.Code, w.Body.String())
+	}
+	var created struct {
+		WalletID string `json:"wallet_id"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+
+	// Funding detector creates the session and authorizes the MAC
+	wallet, err := s.db.GetGuestWallet(created.WalletID)
+	if err != nil {
+		t.Fatalf("GetGuestWallet failed: %v", err)
+	}
+	if !s.processPendingWallet(context.Background(), wallet, 1500) {
+		t.Fatal("Expected funded wallet to start channel opening")
+	}
+	<-opened
+	wallet, _ = s.db.GetGuestWallet(created.WalletID)
+	sessionID := wallet.SessionID
+
+	// Host changes the rate from the dashboard
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/settings/rate", strings.NewReader(`{"rate_per_hour":600}`))
+	req.AddCookie(hostCookie(t, s))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 updating rate, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Session ends and WiFi access is revoked
+	s.sessions[sessionID] = &GuestSession{ID: sessionID, FundingAmount: big.NewInt(0), TotalPaid: big.NewInt(0)}
+	if _, ok := s.detachSession(context.Background(), sessionID, systemActor); !ok {
+		t.Fatal("Expected active session to be detached")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/admin/audit-log", nil)
+	req.AddCookie(hostCookie(t, s))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Entries []struct {
+			Action    string `json:"action"`
+			SessionID string `json:"session_id"`
+			WalletID  string `json:"wallet_id"`
+			ActorType string `json:"actor_type"`
+		} `json:"entries"`
+		Count int `json:"count"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+
+	expected := []struct{ action, actor string }{
+		{auditWalletCreated, "system"},
+		{auditSessionCreated, "system"},
+		{auditMACAuthorized, "system"},
+		{auditRateChanged, "host"},
+		{auditSessionEnded, "system"},
+		{auditMACDeauthorized, "system"},
+	}
+	if resp.Count != len(expected) {
+		t.Fatalf("Expected %d entries, got %d: %s", len(expected), resp.Count, w.Body.String())
+	}
+	for i, e := range expected {
+		got := resp.Entries[i]
+		if got.Action != e.action || got.ActorType != e.actor {
+			t.Errorf("Entry %d: expected %s by %s, got %s by %s", i, e.action, e.actor, got.Action, got.ActorType)
+		}
+		if got.Action != auditRateChanged && got.WalletID != created.WalletID {
+			t.Errorf("Entry %d (%s): expected wallet %s, got %q", i, got.Action, created.WalletID, got.WalletID)
+		}
+	}
+
+	// Filtering by session leaves out the wallet and rate entries
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/admin/audit-log?session_id="+sessionID+"&from="+time.Now().Add(-time.Hour).UTC().Format(time.RFC3339), nil)
+	req.AddCookie(hostCookie(t, s))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Count != 4 {
+		t.Errorf("Expected 4 entries for session, got %d: %s", resp.Count, w.Body.String())
+	}
+}
+
+func TestHandleAuditLog_Unauthorized(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+
+	r := gin.New()
+	r.GET("/api/v1/admin/audit-log", s.dashboardAuthMiddleware(auth.APIKeyScopeRead), s.handleAuditLog)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/audit-log", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401, got %d", w.Code)
+	}
+}
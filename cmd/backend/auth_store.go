@@ -0,0 +1,71 @@
+package main
+
+import (
+	"github.com/airfi/airfi-perun-nervous/internal/auth"
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+)
+
+// apiKeyStore keeps the API keys of auth.APIKeyService in the database.
+type apiKeyStore struct {
+	db *db.DB
+}
+
+func (s apiKeyStore) CreateAPIKey(k *auth.APIKey) error {
+	return s.db.CreateAPIKey(&db.APIKey{
+		ID:         k.ID,
+		KeyHash:    k.KeyHash,
+		Label:      k.Label,
+		Scopes:     k.Scopes,
+		CreatedAt:  k.CreatedAt,
+		LastUsedAt: k.LastUsedAt,
+	})
+}
+
+func (s apiKeyStore) GetAPIKeyByHash(keyHash string) (*auth.APIKey, error) {
+	k, err := s.db.GetAPIKeyByHash(keyHash)
+	if err != nil {
+		return nil, err
+	}
+	return &auth.APIKey{
+		ID:         k.ID,
+		KeyHash:    k.KeyHash,
+		Label:      k.Label,
+		Scopes:     k.Scopes,
+		CreatedAt:  k.CreatedAt,
+		LastUsedAt: k.LastUsedAt,
+	}, nil
+}
+
+func (s apiKeyStore) TouchAPIKey(id string) error {
+	return s.db.TouchAPIKey(id)
+}
+
+// backupCodeStore keeps the 2FA backup codes of auth.TOTPService in the
+// database.
+type backupCodeStore struct {
+	db *db.DB
+}
+
+func (s backupCodeStore) ReplaceBackupCodes(codeHashes []string) error {
+	return s.db.ReplaceBackupCodes(codeHashes)
+}
+
+func (s backupCodeStore) ListBackupCodes() ([]*auth.BackupCode, error) {
+	stored, err := s.db.ListBackupCodes()
+	if err != nil {
+		return nil, err
+	}
+	codes := make([]*auth.BackupCode, 0, len(stored))
+	for _, c := range stored {
+		codes = append(codes, &auth.BackupCode{
+			CodeHash:  c.CodeHash,
+			CreatedAt: c.CreatedAt,
+			UsedAt:    c.UsedAt,
+		})
+	}
+	return codes, nil
+}
+
+func (s backupCodeStore) UseBackupCode(codeHash string) (bool, error) {
+	return s.db.UseBackupCode(codeHash)
+}
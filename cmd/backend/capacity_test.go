@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
+
+	"github.com/airfi/airfi-perun-nervous/internal/guest"
+	"github.com/airfi/airfi-perun-nervous/tests/mocks"
+)
+
+func TestHandleCreateGuestWallet_AtCapacity(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+	s.walletManager = guest.NewWalletManager(types.NetworkTest)
+	mockRouter := mocks.NewMockRouter()
+	s.router = mockRouter
+	s.maxDevices = 2
+
+	r := gin.New()
+	r.POST("/api/v1/wallet/guest", s.handleCreateGuestWallet)
+	create := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/wallet/guest", strings.NewReader(`{"mac_address":"aa:bb:cc:dd:ee:ff"}`))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	ctx := context.Background()
+	mockRouter.AuthorizeMAC(ctx, "11:22:33:44:55:01", "", "", "")
+	if w := create(); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 below capacity, got %d: %s", w.Code, w.Body.String())
+	}
+
+	mockRouter.AuthorizeMAC(ctx, "11:22:33:44:55:02", "", "", "")
+	w := create()
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 at capacity, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Error   string `json:"error"`
+		Current int    `json:"current"`
+		Max     int    `json:"max"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Error != "WiFi at capacity" || resp.Current != 2 || resp.Max != 2 {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+
+	// Zero means unlimited
+	s.maxDevices = 0
+	if w := create(); w.Code != http.StatusOK {
+		t.Errorf("Expected 200 without a device limit, got %d", w.Code)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
+
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+	"github.com/airfi/airfi-perun-nervous/internal/guest"
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
+)
+
+func TestHandleEstimateGuestCells(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+	s.ckbClient = &fundedRPCClient{}
+
+	w, err := guest.NewWalletManager(types.NetworkTest).GenerateWallet()
+	if err != nil {
+		t.Fatalf("GenerateWallet failed: %v", err)
+	}
+	s.db.CreateGuestWallet(&db.GuestWallet{ID: "w1", Address: w.Address, PrivateKeyHex: w.GetPrivateKeyHex(), Status: "funded", CreatedAt: time.Now()})
+
+	r := gin.New()
+	r.GET("/api/v1/wallet/guest/:id/estimate-cells", s.handleEstimateGuestCells)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/wallet/guest/w1/estimate-cells", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		WalletID          string `json:"wallet_id"`
+		CurrentCells      int    `json:"current_cells"`
+		TargetCells       int    `json:"target_cells"`
+		AchievableCells   int    `json:"achievable_cells"`
+		TotalFee          uint64 `json:"total_fee_shannons"`
+		TotalCapacity     uint64 `json:"total_capacity_shannons"`
+		CapacityAfterFees uint64 `json:"capacity_after_fees_shannons"`
+		IsViable          bool   `json:"is_viable"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	// One 2000 CKB cell splits three times into the four channel cells
+	if resp.WalletID != "w1" || resp.CurrentCells != 1 || resp.TargetCells != guestChannelCells || resp.AchievableCells != 4 || !resp.IsViable {
+		t.Errorf("unexpected estimate: %s", rec.Body.String())
+	}
+	if resp.TotalFee != 3*perun.SplitFee || resp.TotalCapacity != 2000*100000000 || resp.CapacityAfterFees != resp.TotalCapacity-resp.TotalFee {
+		t.Errorf("unexpected fees: %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/wallet/guest/w1/estimate-cells?cells=0", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("cells=0: expected 400, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/wallet/guest/missing/estimate-cells", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("missing wallet: expected 404, got %d", rec.Code)
+	}
+}
@@ -3,11 +3,13 @@ package main
 import (
 	"context"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"math/big"
 	"time"
 
 	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
 	"go.uber.org/zap"
 
 	gpchannel "perun.network/go-perun/channel"
@@ -16,6 +18,7 @@ import (
 	"github.com/airfi/airfi-perun-nervous/internal/db"
 	"github.com/airfi/airfi-perun-nervous/internal/guest"
 	"github.com/airfi/airfi-perun-nervous/internal/perun"
+	"github.com/airfi/airfi-perun-nervous/internal/session"
 )
 
 // HostProposalHandler handles incoming channel proposals on the host side.
@@ -29,18 +32,18 @@ func (h *HostProposalHandler) HandleProposal(proposal gpclient.ChannelProposal,
 	h.logger.Info("received channel proposal")
 
 	ctx := context.Background()
-	hostBalance, err := h.server.hostClient.GetBalance(ctx)
+	hostBalance, err := h.server.hostClient.RefreshBalance(ctx)
 	if err != nil {
 		h.logger.Warn("failed to check host balance", zap.Error(err))
 	} else {
 		h.logger.Info("host balance before funding",
 			zap.String("balance_shannons", hostBalance.String()),
-			zap.Float64("balance_ckb", float64(hostBalance.Int64())/100000000),
+			zap.Float64("balance_ckb", session.ShannonsToCKB(hostBalance)),
 		)
 	}
 
-	hostLockScript, _ := guest.DecodeAddress(h.server.hostClient.GetAddress())
-	cellSplitter := perun.NewCellSplitter(h.server.ckbClient, h.logger)
+	hostLockScript := h.server.hostClient.GetLockScript()
+	cellSplitter := h.server.newCellSplitter(h.logger)
 	cellCount, _ := cellSplitter.CountCells(ctx, hostLockScript)
 	h.logger.Info("host cell count before funding", zap.Int("count", cellCount))
 
@@ -50,25 +53,71 @@ func (h *HostProposalHandler) HandleProposal(proposal gpclient.ChannelProposal,
 		return
 	}
 
+	// Reject proposals the host cannot fund so the guest can retry with less
+	if requested := requestedHostFunding(ledgerProposal); err == nil && requested != nil && hostBalance.Cmp(requested) < 0 {
+		h.logger.Warn("host balance too low for requested funding",
+			zap.String("requested_shannons", requested.String()),
+			zap.String("balance_shannons", hostBalance.String()),
+		)
+		if err := responder.Reject(ctx, perun.HostInsufficientFundsReason(requested, hostBalance)); err != nil {
+			h.logger.Error("failed to reject proposal", zap.Error(err))
+		}
+		return
+	}
+
 	accept := ledgerProposal.Accept(h.server.hostClient.GetAccount().Address(), gpclient.WithRandomNonce())
 
-	_, err = responder.Accept(context.Background(), accept)
+	// Derive the channel ID from the proposal before committing to it, so a
+	// channel whose params don't add up is never funded
+	expectedID, err := perun.ProposedChannelID(ledgerProposal, accept)
+	if err != nil {
+		h.logger.Warn("rejecting proposal with invalid channel parameters", zap.Error(err))
+		if err := responder.Reject(ctx, "invalid channel parameters"); err != nil {
+			h.logger.Error("failed to reject proposal", zap.Error(err))
+		}
+		return
+	}
+
+	ch, err := responder.Accept(context.Background(), accept)
 	if err != nil {
 		h.logger.Error("failed to accept proposal", zap.Error(err))
 		return
 	}
 
+	if channelID := perun.ChannelID(ch.ID()); channelID != expectedID {
+		h.logger.Error("rejecting channel whose ID does not match the proposal",
+			zap.String("channel_id", channelID.String()),
+			zap.String("expected_id", expectedID.String()),
+		)
+		if err := ch.Close(); err != nil {
+			h.logger.Warn("failed to close rejected channel", zap.Error(err))
+		}
+		return
+	}
+	h.server.hostClient.TrackChannel(ch)
+
 	h.logger.Info("accepted channel proposal")
 }
 
-// HandleUpdate handles a channel update.
+// requestedHostFunding returns the CKB amount the proposal asks the host
+// (participant 1) to fund, or nil if it cannot be determined.
+func requestedHostFunding(proposal *gpclient.LedgerChannelProposalMsg) *big.Int {
+	balances := proposal.FundingAgreement
+	if balances == nil && proposal.InitBals != nil {
+		balances = proposal.InitBals.Balances
+	}
+	if len(balances) == 0 || len(balances[0]) < 2 {
+		return nil
+	}
+	return balances[0][1]
+}
+
+// HandleUpdate handles a channel update. The host client's update
+// validator decides whether it is signed.
 func (h *HostProposalHandler) HandleUpdate(cur *gpchannel.State, next gpclient.ChannelUpdate, responder *gpclient.UpdateResponder) {
 	h.logger.Info("received update proposal", zap.Uint64("version", next.State.Version))
 
-	err := responder.Accept(context.Background())
-	if err != nil {
-		h.logger.Error("failed to accept update", zap.Error(err))
-	}
+	h.server.hostClient.HandleUpdate(cur, next, responder)
 }
 
 // openChannelForSession opens a Perun payment channel for a funded session.
@@ -100,25 +149,51 @@ func (s *Server) openChannelForSession(ctx context.Context, wallet *db.GuestWall
 
 	// Guest cell preparation
 	s.logger.Info("preparing guest wallet cells for Perun operation")
-	cellSplitter := perun.NewCellSplitter(s.ckbClient, s.logger.Named("cell-splitter"))
-	if err := cellSplitter.EnsureMinimumCells(ctx, guestPrivKey, guestLockScript, 4); err != nil {
-		s.logger.Error("failed to prepare wallet cells", zap.Error(err))
-		s.db.UpdateSessionStatus(sessionID, "cell_preparation_failed")
+	cellSplitter := s.newCellSplitter(s.logger.Named("cell-splitter"))
+	if !s.guestCanReachCells(ctx, cellSplitter, guestLockScript, guestChannelCells) {
+		s.db.UpdateSessionStatus(sessionID, "insufficient_capacity")
 		return
 	}
+	// The session is already active optimistically, so progress goes on the
+	// wallet. If the channel doesn't open, the wallet goes back to its status
+	// unless something else, like the refund, has moved it on.
+	defer func() {
+		if _, err := s.db.ResetWalletStatus(wallet.ID, "preparing_cells", wallet.Status); err != nil {
+			s.logger.Warn("failed to restore wallet status", zap.String("wallet_id", wallet.ID), zap.Error(err))
+		}
+	}()
+	cellSplitter.SetProgressCallback(func(p perun.CellSplitProgress) {
+		s.logger.Info("guest cell preparation progress",
+			zap.String("session_id", sessionID),
+			zap.Int("step", p.Step),
+			zap.Int("total_steps", p.TotalSteps),
+			zap.Int("current_cells", p.CurrentCells),
+			zap.Int("target_cells", p.TargetCells),
+			zap.String("tx_hash", p.LastTxHash),
+		)
+		s.db.UpdateWalletStatus(wallet.ID, "preparing_cells")
+	})
+	if err := cellSplitter.EnsureMinimumCells(ctx, guestPrivKey, guestLockScript, guestChannelCells); err != nil {
+		// Capacity may be spread over cells too small to split; consolidate first
+		s.logger.Warn("cell split failed, merging small cells", zap.Error(err))
+		if _, err := cellSplitter.MergeThenSplit(ctx, guestPrivKey, guestLockScript, guestChannelCells); err != nil {
+			s.logger.Error("failed to prepare wallet cells", zap.Error(err))
+			s.db.UpdateSessionStatus(sessionID, "cell_preparation_failed")
+			return
+		}
+	}
 	guestCellCount, _ := cellSplitter.CountCells(ctx, guestLockScript)
 	s.logger.Info("guest wallet cell preparation complete", zap.Int("cell_count", guestCellCount))
 
-	// Create guest channel client
-	guestClient, err := perun.NewChannelClient(&perun.ChannelClientConfig{
-		RPCURL:     perun.TestnetRPCURL,
-		PrivateKey: guestPrivKey,
-		Deployment: perun.GetTestnetDeployment(),
-		Logger:     s.logger.Named("guest-" + sessionID[:8]),
-		WireBus:    s.wireBus,
-	})
+	// One channel opening per wallet at a time
+	walletMu := s.walletLock(wallet.ID)
+	walletMu.Lock()
+	defer walletMu.Unlock()
+
+	// Get a guest channel client, reusing an idle one for this wallet
+	guestClient, err := s.guestClients.Get(ctx, guestPrivKey)
 	if err != nil {
-		s.logger.Error("failed to create guest client", zap.Error(err))
+		s.logger.Error("failed to create guest client", zap.String("session_id", sessionID), zap.Error(err))
 		s.db.UpdateSessionStatus(sessionID, "channel_failed")
 		return
 	}
@@ -142,7 +217,7 @@ func (s *Server) openChannelForSession(ctx context.Context, wallet *db.GuestWall
 	} else {
 		s.logger.Info("perun client balance",
 			zap.String("balance_shannons", perunBalance.String()),
-			zap.Float64("balance_ckb", float64(perunBalance.Int64())/100000000),
+			zap.Float64("balance_ckb", session.ShannonsToCKB(perunBalance)),
 		)
 	}
 
@@ -153,7 +228,7 @@ func (s *Server) openChannelForSession(ctx context.Context, wallet *db.GuestWall
 			zap.Int64("minimum_required", minBalanceForChannel),
 		)
 		s.db.UpdateSessionStatus(sessionID, "insufficient_funds")
-		guestClient.Close()
+		s.guestClients.Put(guestClient)
 		return
 	}
 
@@ -166,52 +241,77 @@ func (s *Server) openChannelForSession(ctx context.Context, wallet *db.GuestWall
 		zap.Int64("funding_ckb", fundingCKB),
 	)
 
-	hostFunding := big.NewInt(10000000000) // 100 CKB
+	hostFunding := big.NewInt(10000000000)              // 100 CKB
+	minHostFunding := big.NewInt(perun.MinCellCapacity) // 61 CKB fallback when host is low
 
 	s.db.UpdateSessionStatus(sessionID, "channel_opening")
 
-	channelCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
-	defer cancel()
-
-	channel, err := guestClient.ProposeChannel(
-		channelCtx,
+	// The guest client bounds the proposal with the configured funding timeout
+	channel, err := guestClient.ProposeChannelWithFallback(
+		ctx,
 		s.hostClient.GetWireAddress(),
 		s.hostClient.GetAccount().Address(),
 		guestFunding,
 		hostFunding,
+		minHostFunding,
 	)
 	if err != nil {
-		guestClient.Close()
+		s.guestClients.Put(guestClient)
 		s.logger.Error("failed to open channel", zap.Error(err))
-		s.db.UpdateSessionStatus(sessionID, "channel_failed")
+		s.failChannelOpening(ctx, sessionID, wallet, err)
+		return
+	}
 
-		// Revoke optimistic WiFi access
-		if wallet.MACAddress != "" {
-			s.logger.Warn("revoking optimistic WiFi access due to channel failure",
-				zap.String("session_id", sessionID),
-				zap.String("mac", wallet.MACAddress),
-			)
-			if err := s.router.DeauthorizeMAC(ctx, wallet.MACAddress); err != nil {
-				s.logger.Error("failed to deauthorize MAC after channel failure", zap.Error(err))
-			}
+	channelID := perun.ChannelID(channel.ID())
+	// Recorded now so the funding endpoint can report on-chain progress
+	if err := s.db.UpdateSessionChannel(sessionID, channelID, "channel_opening"); err != nil {
+		s.logger.Warn("failed to record channel ID", zap.String("session_id", sessionID), zap.Error(err))
+	}
+
+	// go-perun has accepted the funding; confirm the PCTS cell is committed on-chain
+	if err := guestClient.WaitForFunding(ctx, channelID, s.fundingTimeout); err != nil {
+		s.logger.Error("channel funding not confirmed on-chain",
+			zap.String("session_id", sessionID),
+			zap.String("channel_id", channelID.String()),
+			zap.Error(err),
+		)
+		// Whatever did land goes back to the guest wallet before the refund
+		if err := guestClient.SettleChannel(ctx, channel); err != nil {
+			s.logger.Warn("failed to settle unfunded channel", zap.String("session_id", sessionID), zap.Error(err))
 		}
+		s.guestClients.Put(guestClient)
+		s.failChannelOpening(ctx, sessionID, wallet, err)
 		return
 	}
+	s.webhooks.Emit("channel.funding_confirmed", map[string]any{
+		"session_id": sessionID,
+		"channel_id": channelID,
+		"wallet_id":  wallet.ID,
+	})
 
-	channelID := fmt.Sprintf("%x", channel.ID())
-
-	if err := s.db.UpdateSessionChannel(sessionID, channelID, "active"); err != nil {
-		s.logger.Error("failed to update session channel", zap.Error(err))
+	// Session and wallet move to the open state together
+	err = s.db.Transaction(func(tx *db.DB) error {
+		if err := tx.UpdateSessionChannel(sessionID, channelID, "active"); err != nil {
+			return fmt.Errorf("failed to update session channel: %w", err)
+		}
+		if err := tx.UpdateWalletStatus(wallet.ID, "channel_open"); err != nil {
+			return fmt.Errorf("failed to update wallet status: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("failed to record channel opening", zap.String("session_id", sessionID), zap.Error(err))
 	} else {
 		s.logger.Info("channel opened successfully",
 			zap.String("session_id", sessionID),
-			zap.String("channel_id", channelID),
+			zap.String("channel_id", channelID.String()),
 		)
-	}
-	if err := s.db.UpdateWalletStatus(wallet.ID, "channel_open"); err != nil {
-		s.logger.Error("failed to update wallet status", zap.Error(err))
+		s.audit(systemActor, auditChannelOpened, sessionID, wallet.ID, "channel_id="+channelID.String())
 	}
 
+	// The opening state fixes the host's initial balance for earnings
+	s.recordChannelSnapshot(sessionID, channel)
+
 	// Calculate catch-up payment for elapsed time
 	dbSession, err := s.db.GetSession(sessionID)
 	if err != nil {
@@ -235,11 +335,13 @@ func (s *Server) openChannelForSession(ctx context.Context, wallet *db.GuestWall
 	)
 
 	if catchUpShannons.Cmp(big.NewInt(0)) > 0 {
-		err := guestClient.SendPayment(channel, catchUpShannons)
+		err := guestClient.SendPaymentBatch(channel, intervalPayments(s.ratePerMin, elapsedMinutes))
 		if err != nil {
 			s.logger.Error("failed to send catch-up payment", zap.Error(err))
 		} else {
 			s.logger.Info("catch-up payment sent", zap.Int64("amount_ckb", catchUpCKB))
+			s.recordChannelSnapshot(sessionID, channel)
+			s.recordPayment(sessionID, db.PaymentCatchUp, catchUpShannons)
 		}
 	}
 
@@ -248,16 +350,20 @@ func (s *Server) openChannelForSession(ctx context.Context, wallet *db.GuestWall
 		ID:            sessionID,
 		Client:        guestClient,
 		Channel:       channel,
+		ChannelID:     channelID,
 		GuestAddress:  wallet.Address,
 		FundingAmount: guestFunding,
 		TotalPaid:     catchUpShannons,
 		CreatedAt:     dbSession.CreatedAt,
 		ExpiresAt:     dbSession.ExpiresAt,
+		LastPaymentAt: time.Now(),
 	}
 
 	s.sessionsMu.Lock()
 	s.sessions[sessionID] = guestSession
 	s.sessionsMu.Unlock()
+	s.storeSessionOpened(guestSession)
+	go s.monitorPeer(ctx, guestSession)
 
 	// Update database with initial spent amount
 	newBalance := fundingCKB - catchUpCKB
@@ -265,8 +371,69 @@ func (s *Server) openChannelForSession(ctx context.Context, wallet *db.GuestWall
 
 	s.logger.Info("Perun channel opened",
 		zap.String("session_id", sessionID),
-		zap.String("channel_id", channelID),
+		zap.String("channel_id", channelID.String()),
 		zap.Int64("guest_funding", balanceCKB),
 		zap.Int64("catch_up_spent", catchUpCKB),
 	)
 }
+
+// failChannelOpening marks a session whose channel couldn't be opened or
+// funded as failed, revokes its optimistic WiFi access and refunds the
+// guest wallet in the background.
+func (s *Server) failChannelOpening(ctx context.Context, sessionID string, wallet *db.GuestWallet, cause error) {
+	status := "channel_failed"
+	if errors.Is(cause, perun.ErrFundingTimeout) {
+		status = "funding_timeout"
+	}
+	s.db.UpdateSessionStatus(sessionID, status)
+
+	if wallet.MACAddress != "" {
+		s.logger.Warn("revoking optimistic WiFi access due to channel failure",
+			zap.String("session_id", sessionID),
+			zap.String("mac", wallet.MACAddress),
+		)
+		if err := s.router.DeauthorizeMAC(ctx, wallet.MACAddress); err != nil {
+			s.logger.Error("failed to deauthorize MAC after channel failure", zap.Error(err))
+		} else {
+			s.audit(systemActor, auditMACDeauthorized, sessionID, wallet.ID, "mac="+wallet.MACAddress)
+		}
+	}
+
+	go func() {
+		txHash, err := s.withdrawToSender(context.Background(), sessionID)
+		if err != nil {
+			s.logger.Warn("refund after channel failure failed", zap.String("session_id", sessionID), zap.Error(err))
+			return
+		}
+		s.logger.Info("refunded guest after channel failure",
+			zap.String("session_id", sessionID),
+			zap.String("tx_hash", txHash),
+		)
+	}()
+}
+
+// guestChannelCells is how many cells a guest wallet is split into before
+// its channel is opened.
+const guestChannelCells = 4
+
+// guestCanReachCells reports whether the guest wallet holds, or can be
+// prepared with, at least minCells cells. Lookup failures are logged and
+// treated as reachable so cell preparation still gets a chance to run.
+func (s *Server) guestCanReachCells(ctx context.Context, cellSplitter *perun.CellSplitter, lockScript *types.Script, minCells int) bool {
+	estimate, err := cellSplitter.DryRunSplit(ctx, lockScript, minCells)
+	if err != nil {
+		s.logger.Warn("failed to estimate guest cell preparation", zap.Error(err))
+		return true
+	}
+	if !estimate.IsViable {
+		s.logger.Error("guest wallet capacity too low for channel cells",
+			zap.Uint64("capacity_shannons", estimate.TotalCapacity),
+			zap.Int("current_cells", estimate.CurrentCells),
+			zap.Int("achievable_cells", estimate.AchievableCells),
+			zap.Int("required_cells", minCells),
+			zap.Uint64("split_fee_shannons", estimate.TotalFeeCost),
+		)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+
+	gpchannel "perun.network/go-perun/channel"
+	gpclient "perun.network/go-perun/client"
+	"perun.network/perun-ckb-backend/channel/asset"
+
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
+	"github.com/airfi/airfi-perun-nervous/tests/mocks"
+)
+
+func TestRequestedHostFunding(t *testing.T) {
+	ckbAsset := asset.NewCKBytesAsset()
+	alloc := gpchannel.NewAllocation(2, ckbAsset)
+	alloc.SetAssetBalances(ckbAsset, []gpchannel.Bal{big.NewInt(50000000000), big.NewInt(10000000000)})
+
+	proposal := &gpclient.LedgerChannelProposalMsg{}
+	proposal.InitBals = alloc
+
+	requested := requestedHostFunding(proposal)
+	if requested == nil || requested.Cmp(big.NewInt(10000000000)) != 0 {
+		t.Errorf("Expected host funding 10000000000, got %v", requested)
+	}
+
+	if requestedHostFunding(&gpclient.LedgerChannelProposalMsg{}) != nil {
+		t.Error("Expected nil for proposal without balances")
+	}
+}
+
+func TestFailChannelOpening(t *testing.T) {
+	s := newTestServer(t)
+	s.enableDryRun()
+	mockRouter := mocks.NewMockRouter()
+	s.router = mockRouter
+
+	const mac = "aa:bb:cc:dd:ee:ff"
+	wallet := &db.GuestWallet{ID: "w-1", Address: "ckt1guest", Status: "funded", MACAddress: mac}
+	s.db.CreateGuestWallet(wallet)
+	s.db.CreateSession(&db.Session{ID: "session-1", WalletID: wallet.ID, MACAddress: mac, Status: "channel_opening"})
+	mockRouter.AuthorizeMAC(context.Background(), mac, "", "", "")
+
+	s.failChannelOpening(context.Background(), "session-1", wallet, fmt.Errorf("wait: %w", perun.ErrFundingTimeout))
+
+	if session, _ := s.db.GetSession("session-1"); session.Status != "funding_timeout" {
+		t.Errorf("Expected funding_timeout, got %s", session.Status)
+	}
+	if mockRouter.IsAuthorized(mac) {
+		t.Error("Expected the MAC deauthorized")
+	}
+}
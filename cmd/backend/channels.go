@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/airfi/airfi-perun-nervous/internal/auth"
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
+)
+
+// fundingStatusTimeout bounds one funding status lookup.
+const fundingStatusTimeout = 10 * time.Second
+
+// handleListChannels returns the host's open Perun channels, both the ones
+// it proposed and the ones it accepted from guests.
+func (s *Server) handleListChannels(c *gin.Context) {
+	if s.channelLister == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "host channel client not available"})
+		return
+	}
+
+	channels, err := s.channelLister()
+	if err != nil {
+		s.logger.Error("failed to list channels", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list channels"})
+		return
+	}
+
+	result := make([]gin.H, 0, len(channels))
+	for _, ch := range channels {
+		result = append(result, gin.H{
+			"channel_id":   ch.ID.String(),
+			"peer_address": ch.PeerAddress,
+			"state":        ch.State,
+			"version":      ch.Version,
+			"my_balance":   ch.MyBalance.String(),
+			"peer_balance": ch.PeerBalance.String(),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"channels": result,
+		"count":    len(result),
+	})
+}
+
+// handleGetChannelFunding reports how far a session's channel funding has
+// progressed on-chain, so the guest portal can show progress while the
+// channel opens. Before the channel ID is known the phase is pending.
+func (s *Server) handleGetChannelFunding(c *gin.Context) {
+	sessionID := c.Param("sessionId")
+
+	if !s.authorizeSessionScope(c, sessionID, auth.ScopeRead) {
+		return
+	}
+
+	dbSession, err := s.db.GetSession(sessionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return
+	}
+
+	resp := gin.H{
+		"session_id":            sessionID,
+		"status":                dbSession.Status,
+		"phase":                 perun.FundingPhasePending,
+		"guest_funding_tx_hash": "",
+		"host_funding_tx_hash":  "",
+		"confirmed":             false,
+		"block_number":          uint64(0),
+	}
+	if dbSession.ChannelID.IsZero() {
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	resp["channel_id"] = dbSession.ChannelID
+
+	if s.fundingStatus == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "host channel client not available"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), fundingStatusTimeout)
+	defer cancel()
+	fs, err := s.fundingStatus(ctx, dbSession.ChannelID)
+	if err != nil {
+		s.logger.Error("failed to get channel funding status", zap.String("session_id", sessionID), zap.Error(err))
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to get channel funding status"})
+		return
+	}
+
+	resp["phase"] = fs.Phase
+	resp["guest_funding_tx_hash"] = fs.GuestFundingTxHash
+	resp["host_funding_tx_hash"] = fs.HostFundingTxHash
+	resp["confirmed"] = fs.Confirmed
+	resp["block_number"] = fs.BlockNumber
+	if fs.PCTS != nil {
+		resp["pcts_out_point"] = fmt.Sprintf("%s:%d", fs.PCTS.TxHash.Hex(), fs.PCTS.Index)
+	}
+	c.JSON(http.StatusOK, resp)
+}
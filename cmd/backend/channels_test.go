@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
+
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
+)
+
+func TestHandleListChannels(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+	s.channelLister = func() ([]perun.ChannelSummary, error) {
+		return []perun.ChannelSummary{{
+			ID:          perun.ChannelID{0xab},
+			PeerAddress: "ckt1peer",
+			MyBalance:   big.NewInt(10000000000),
+			PeerBalance: big.NewInt(2500000000),
+			Version:     4,
+			State:       string(perun.ChannelStateOpen),
+		}}, nil
+	}
+
+	r := gin.New()
+	r.GET("/api/v1/channels", s.handleListChannels)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/channels", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Count    int `json:"count"`
+		Channels []struct {
+			ChannelID   string `json:"channel_id"`
+			PeerAddress string `json:"peer_address"`
+			State       string `json:"state"`
+			Version     uint64 `json:"version"`
+			MyBalance   string `json:"my_balance"`
+			PeerBalance string `json:"peer_balance"`
+		} `json:"channels"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if resp.Count != 1 || len(resp.Channels) != 1 {
+		t.Fatalf("expected one channel, got %s", w.Body.String())
+	}
+	ch := resp.Channels[0]
+	if ch.ChannelID != (perun.ChannelID{0xab}).String() || ch.PeerAddress != "ckt1peer" || ch.State != "open" || ch.Version != 4 {
+		t.Errorf("unexpected channel: %+v", ch)
+	}
+	if ch.MyBalance != "10000000000" || ch.PeerBalance != "2500000000" {
+		t.Errorf("unexpected balances: %s / %s", ch.MyBalance, ch.PeerBalance)
+	}
+}
+
+func TestHandleListChannels_NoHostClient(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+
+	r := gin.New()
+	r.GET("/api/v1/channels", s.handleListChannels)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/channels", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503, got %d", w.Code)
+	}
+}
+
+func TestHandleGetChannelFunding(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+	s.fundingStatus = func(ctx context.Context, channelID perun.ChannelID) (*perun.FundingStatus, error) {
+		return &perun.FundingStatus{
+			Phase:              perun.FundingPhaseFunded,
+			PCTS:               &types.OutPoint{TxHash: types.HexToHash("0x0c"), Index: 0},
+			GuestFundingTxHash: types.HexToHash("0x0b").Hex(),
+			HostFundingTxHash:  types.HexToHash("0x0c").Hex(),
+			BlockNumber:        4218305,
+		}, nil
+	}
+
+	s.db.CreateSession(&db.Session{ID: "proposing", Status: "channel_opening", ExpiresAt: time.Now().Add(time.Hour)})
+	s.db.CreateSession(&db.Session{ID: "funding", Status: "channel_opening", ExpiresAt: time.Now().Add(time.Hour)})
+	s.db.UpdateSessionChannel("funding", perun.ChannelID{0xab}, "channel_opening")
+
+	r := gin.New()
+	r.GET("/api/v1/sessions/:sessionId/channel/funding", s.handleGetChannelFunding)
+
+	type fundingResp struct {
+		Phase       string `json:"phase"`
+		HostTxHash  string `json:"host_funding_tx_hash"`
+		BlockNumber uint64 `json:"block_number"`
+		OutPoint    string `json:"pcts_out_point"`
+	}
+
+	// No channel ID yet while the proposal is in flight
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, hostRequest(t, s, http.MethodGet, "/api/v1/sessions/proposing/channel/funding", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp fundingResp
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Phase != perun.FundingPhasePending {
+		t.Errorf("Expected pending phase, got %q", resp.Phase)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, hostRequest(t, s, http.MethodGet, "/api/v1/sessions/funding/channel/funding", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	resp = fundingResp{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Phase != perun.FundingPhaseFunded || resp.BlockNumber != 4218305 || resp.HostTxHash != types.HexToHash("0x0c").Hex() {
+		t.Errorf("Unexpected funding status: %s", w.Body.String())
+	}
+	if resp.OutPoint != types.HexToHash("0x0c").Hex()+":0" {
+		t.Errorf("Unexpected out point: %q", resp.OutPoint)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, hostRequest(t, s, http.MethodGet, "/api/v1/sessions/missing/channel/funding", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("missing session: expected 404, got %d", w.Code)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/airfi/airfi-perun-nervous/internal/auth"
+	"github.com/airfi/airfi-perun-nervous/internal/router"
+)
+
+// routerQueryTimeout bounds a single router status query.
+const routerQueryTimeout = 5 * time.Second
+
+// handleGetSessionClient returns live router data for the session's guest device.
+func (s *Server) handleGetSessionClient(c *gin.Context) {
+	sessionID := c.Param("sessionId")
+
+	if !s.authorizeSessionScope(c, sessionID, auth.ScopeRead) {
+		return
+	}
+
+	session, err := s.db.GetSession(sessionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return
+	}
+	if session.MACAddress == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no device recorded for session"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), routerQueryTimeout)
+	defer cancel()
+
+	info, err := s.router.GetClientInfo(ctx, session.MACAddress)
+	if err != nil {
+		if errors.Is(err, router.ErrClientNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "device not connected"})
+			return
+		}
+		s.logger.Error("failed to get client info", zap.String("session_id", sessionID), zap.Error(err))
+		c.JSON(http.StatusBadGateway, gin.H{"error": "router query failed"})
+		return
+	}
+
+	s.db.UpdateSessionUsage(sessionID, int64(info.BytesIn), int64(info.BytesOut))
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id":      sessionID,
+		"mac":             info.MAC,
+		"ip":              info.IP,
+		"ssid":            info.SSID,
+		"signal_strength": info.SignalStrength,
+		"tx_rate":         info.TxRate,
+		"rx_rate":         info.RxRate,
+		"bytes_in":        info.BytesIn,
+		"bytes_out":       info.BytesOut,
+		"connected_at":    info.ConnectedAt.Format(time.RFC3339),
+	})
+}
+
+// recordUsage stores the router's traffic counters for the given sessions.
+// Routers that implement router.UsageReporter are queried once for all of
+// them; others are asked about one client at a time.
+func (s *Server) recordUsage(ctx context.Context, sessionIDs []string) {
+	ctx, cancel := context.WithTimeout(ctx, routerQueryTimeout)
+	defer cancel()
+
+	var usage map[string]*router.ClientInfo
+	if reporter, ok := s.router.(router.UsageReporter); ok {
+		var err error
+		if usage, err = reporter.ClientUsage(ctx); err != nil {
+			s.logger.Debug("failed to get client usage", zap.Error(err))
+			return
+		}
+	}
+
+	for _, sessionID := range sessionIDs {
+		session, err := s.db.GetSession(sessionID)
+		if err != nil || session.MACAddress == "" {
+			continue
+		}
+
+		var info *router.ClientInfo
+		if usage != nil {
+			info = usage[strings.ToLower(session.MACAddress)]
+		} else if info, err = s.router.GetClientInfo(ctx, session.MACAddress); err != nil && !errors.Is(err, router.ErrClientNotFound) {
+			s.logger.Debug("failed to get client usage", zap.String("session_id", sessionID), zap.Error(err))
+		}
+		if info == nil {
+			continue
+		}
+
+		if err := s.db.UpdateSessionUsage(sessionID, int64(info.BytesIn), int64(info.BytesOut)); err != nil {
+			s.logger.Error("failed to update session usage", zap.String("session_id", sessionID), zap.Error(err))
+		}
+	}
+}
+
+// topologyQueryTimeout bounds a topology query, which visits every access point.
+const topologyQueryTimeout = 15 * time.Second
+
+// handleRouterTopology lists the access points and their client counts.
+func (s *Server) handleRouterTopology(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), topologyQueryTimeout)
+	defer cancel()
+
+	topology, err := s.router.GetTopology(ctx)
+	if err != nil {
+		s.logger.Error("failed to get router topology", zap.Error(err))
+		c.JSON(http.StatusBadGateway, gin.H{"error": "router query failed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"aps":   topology.APs,
+		"count": len(topology.APs),
+	})
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+	"github.com/airfi/airfi-perun-nervous/internal/router"
+)
+
+// stubRouter returns fixed client info for one MAC address.
+type stubRouter struct {
+	router.NoopRouter
+	mac  string
+	info *router.ClientInfo
+}
+
+func (r *stubRouter) GetClientInfo(ctx context.Context, mac string) (*router.ClientInfo, error) {
+	if mac != r.mac {
+		return nil, router.ErrClientNotFound
+	}
+	return r.info, nil
+}
+
+func TestHandleGetSessionClient(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+	s.router = &stubRouter{
+		mac: "aa:bb:cc:dd:ee:ff",
+		info: &router.ClientInfo{
+			MAC:            "aa:bb:cc:dd:ee:ff",
+			SignalStrength: -60,
+			BytesIn:        2048,
+			BytesOut:       1024,
+		},
+	}
+
+	s.db.CreateSession(&db.Session{ID: "session-1", Status: "active", MACAddress: "aa:bb:cc:dd:ee:ff", ExpiresAt: time.Now().Add(time.Hour)})
+	s.db.CreateSession(&db.Session{ID: "session-2", Status: "active", ExpiresAt: time.Now().Add(time.Hour)})
+
+	r := gin.New()
+	r.GET("/api/v1/sessions/:sessionId/client", s.handleGetSessionClient)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, hostRequest(t, s, http.MethodGet, "/api/v1/sessions/session-1/client", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		SignalStrength int    `json:"signal_strength"`
+		BytesIn        uint64 `json:"bytes_in"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.SignalStrength != -60 || resp.BytesIn != 2048 {
+		t.Errorf("Unexpected response: %s", w.Body.String())
+	}
+
+	session, _ := s.db.GetSession("session-1")
+	if session.BytesIn != 2048 || session.BytesOut != 1024 {
+		t.Errorf("Usage not recorded: %d/%d", session.BytesIn, session.BytesOut)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, hostRequest(t, s, http.MethodGet, "/api/v1/sessions/session-2/client", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Session without MAC: expected 404, got %d", w.Code)
+	}
+}
+
+// usageRouter reports traffic counters in one query and fails per-client lookups.
+type usageRouter struct {
+	router.NoopRouter
+	queries int
+	usage   map[string]*router.ClientInfo
+}
+
+func (r *usageRouter) ClientUsage(ctx context.Context) (map[string]*router.ClientInfo, error) {
+	r.queries++
+	return r.usage, nil
+}
+
+func TestRecordUsage_SingleQuery(t *testing.T) {
+	s := newTestServer(t)
+	rt := &usageRouter{usage: map[string]*router.ClientInfo{
+		"aa:bb:cc:dd:ee:01": {BytesIn: 100, BytesOut: 10},
+		"aa:bb:cc:dd:ee:02": {BytesIn: 200, BytesOut: 20},
+	}}
+	s.router = rt
+
+	s.db.CreateSession(&db.Session{ID: "session-1", Status: "active", MACAddress: "AA:BB:CC:DD:EE:01", ExpiresAt: time.Now().Add(time.Hour)})
+	s.db.CreateSession(&db.Session{ID: "session-2", Status: "active", MACAddress: "aa:bb:cc:dd:ee:02", ExpiresAt: time.Now().Add(time.Hour)})
+
+	s.recordUsage(context.Background(), []string{"session-1", "session-2"})
+
+	if rt.queries != 1 {
+		t.Errorf("Expected one router query, got %d", rt.queries)
+	}
+	for id, want := range map[string]int64{"session-1": 100, "session-2": 200} {
+		if session, _ := s.db.GetSession(id); session.BytesIn != want {
+			t.Errorf("%s: expected %d bytes in, got %d", id, want, session.BytesIn)
+		}
+	}
+}
+
+// topologyRouter returns a fixed topology.
+type topologyRouter struct {
+	router.NoopRouter
+	topology *router.NetworkTopology
+}
+
+func (r *topologyRouter) GetTopology(ctx context.Context) (*router.NetworkTopology, error) {
+	return r.topology, nil
+}
+
+func TestHandleRouterTopology(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+	s.router = &topologyRouter{topology: &router.NetworkTopology{APs: []router.AccessPoint{
+		{Name: "main", MAC: "02:00:00:00:00:01", IPAddress: "192.168.1.1", ConnectedClients: 3},
+		{Name: "lobby", MAC: "02:00:00:00:00:02", IPAddress: "192.168.1.2", ConnectedClients: 5, SignalThreshold: -75},
+	}}}
+
+	r := gin.New()
+	r.GET("/api/v1/router/topology", s.handleRouterTopology)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/router/topology", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		APs   []router.AccessPoint `json:"aps"`
+		Count int                  `json:"count"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if resp.Count != 2 || resp.APs[1].Name != "lobby" || resp.APs[1].ConnectedClients != 5 || resp.APs[1].SignalThreshold != -75 {
+		t.Errorf("unexpected topology: %s", w.Body.String())
+	}
+}
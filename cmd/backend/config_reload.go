@@ -0,0 +1,63 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/airfi/airfi-perun-nervous/internal/config"
+)
+
+// deviceLimit returns how many devices may be authorized at once; 0 is no
+// limit.
+func (s *Server) deviceLimit() int {
+	s.maxDevicesMu.RLock()
+	defer s.maxDevicesMu.RUnlock()
+	return s.maxDevices
+}
+
+// setDeviceLimit changes how many devices may be authorized at once.
+func (s *Server) setDeviceLimit(limit int) {
+	s.maxDevicesMu.Lock()
+	s.maxDevices = limit
+	s.maxDevicesMu.Unlock()
+}
+
+// applyConfigChange is the config watcher's callback. It applies the
+// settings that can change while running, wifi.max_concurrent_devices,
+// and logs the sections whose changes wait for a restart.
+func (s *Server) applyConfigChange(old, new *config.Config) {
+	if old.WiFi.MaxConcurrentDevices != new.WiFi.MaxConcurrentDevices {
+		s.setDeviceLimit(new.WiFi.MaxConcurrentDevices)
+		s.logger.Info("max concurrent devices changed",
+			zap.Int("from", old.WiFi.MaxConcurrentDevices),
+			zap.Int("to", new.WiFi.MaxConcurrentDevices),
+		)
+	}
+
+	if sections := changedSections(old, new); len(sections) > 0 {
+		s.logger.Warn("config changed; restart to apply", zap.Strings("sections", sections))
+	}
+}
+
+// changedSections returns the YAML names of the top-level config sections
+// that differ between old and new, leaving out the settings
+// applyConfigChange applies.
+func changedSections(old, new *config.Config) []string {
+	oldCfg, newCfg := *old, *new
+	newCfg.WiFi.MaxConcurrentDevices = oldCfg.WiFi.MaxConcurrentDevices
+
+	var sections []string
+	oldValue, newValue := reflect.ValueOf(oldCfg), reflect.ValueOf(newCfg)
+	for i := 0; i < oldValue.NumField(); i++ {
+		field := oldValue.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if !reflect.DeepEqual(oldValue.Field(i).Interface(), newValue.Field(i).Interface()) {
+			sections = append(sections, strings.Split(field.Tag.Get("yaml"), ",")[0])
+		}
+	}
+	return sections
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/airfi/airfi-perun-nervous/internal/config"
+)
+
+func TestApplyConfigChange(t *testing.T) {
+	s := newTestServer(t)
+	s.maxDevices = 2
+
+	old := config.DefaultConfig()
+	old.WiFi.MaxConcurrentDevices = 2
+	new := config.DefaultConfig()
+	new.WiFi.MaxConcurrentDevices = 5
+
+	s.applyConfigChange(old, new)
+	if got := s.deviceLimit(); got != 5 {
+		t.Errorf("Expected device limit 5, got %d", got)
+	}
+
+	// The applied setting alone needs no restart
+	if sections := changedSections(old, new); len(sections) != 0 {
+		t.Errorf("Expected no sections to restart for, got %v", sections)
+	}
+	new.Server.Port++
+	new.WiFi.RatePerHour++
+	if sections := changedSections(old, new); !reflect.DeepEqual(sections, []string{"server", "wifi"}) {
+		t.Errorf("Expected server and wifi to restart for, got %v", sections)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+)
+
+// settingDashboardPassword holds the bcrypt hash of a dashboard password
+// changed at runtime. Older databases stored it in plaintext.
+const settingDashboardPassword = "dashboard_password"
+
+// hashDashboardPassword returns the bcrypt hash of password.
+func hashDashboardPassword(password string) ([]byte, error) {
+	return bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+}
+
+// loadDashboardPasswordHash returns the hash of the dashboard password: the
+// one stored in the database if it was changed at runtime, otherwise that of
+// configured. A plaintext password left by an older version is hashed and
+// stored again.
+func loadDashboardPasswordHash(database *db.DB, configured string) ([]byte, error) {
+	stored, err := database.GetSetting(settingDashboardPassword)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to read dashboard password: %w", err)
+	}
+	if stored == "" {
+		return hashDashboardPassword(configured)
+	}
+	if _, err := bcrypt.Cost([]byte(stored)); err == nil {
+		return []byte(stored), nil
+	}
+
+	hash, err := hashDashboardPassword(stored)
+	if err != nil {
+		return nil, err
+	}
+	if err := database.SetSetting(settingDashboardPassword, string(hash)); err != nil {
+		return nil, fmt.Errorf("failed to store dashboard password hash: %w", err)
+	}
+	return hash, nil
+}
+
+// checkDashboardPassword reports whether password is the dashboard password.
+func (s *Server) checkDashboardPassword(password string) bool {
+	s.passwordMu.RLock()
+	hash := s.passwordHash
+	s.passwordMu.RUnlock()
+	return len(hash) > 0 && bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil
+}
+
+// setDashboardPasswordHash replaces the dashboard password hash.
+func (s *Server) setDashboardPasswordHash(hash []byte) {
+	s.passwordMu.Lock()
+	s.passwordHash = hash
+	s.passwordMu.Unlock()
+}
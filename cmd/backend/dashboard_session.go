@@ -0,0 +1,75 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// dashboardCookie holds the dashboard session token after login.
+	dashboardCookie = "airfi_host_auth"
+	// dashboardSessionTTL is how long a dashboard login stays valid.
+	dashboardSessionTTL = 24 * time.Hour
+)
+
+// dashboardSessions tracks logged-in dashboard sessions. The cookie only
+// carries a random token, so knowing the password is not enough to skip
+// the second factor. Sessions live in memory and end on restart.
+type dashboardSessions struct {
+	tokens map[string]time.Time // token -> expiry
+	mu     sync.Mutex
+}
+
+func newDashboardSessions() *dashboardSessions {
+	return &dashboardSessions{tokens: make(map[string]time.Time)}
+}
+
+// create starts a session and returns its token.
+func (d *dashboardSessions) create() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate session token: %w", err)
+	}
+	token := hex.EncodeToString(raw)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Drop sessions that have expired anyway
+	now := time.Now()
+	for t, exp := range d.tokens {
+		if now.After(exp) {
+			delete(d.tokens, t)
+		}
+	}
+	d.tokens[token] = now.Add(dashboardSessionTTL)
+	return token, nil
+}
+
+// valid reports whether token belongs to an unexpired session.
+func (d *dashboardSessions) valid(token string) bool {
+	if token == "" {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	exp, ok := d.tokens[token]
+	return ok && time.Now().Before(exp)
+}
+
+// revoke ends the session with token.
+func (d *dashboardSessions) revoke(token string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.tokens, token)
+}
+
+// revokeAll ends every session, e.g. after the password changed.
+func (d *dashboardSessions) revokeAll() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.tokens = make(map[string]time.Time)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+)
+
+// startDBCompactor compacts the database whenever the configured schedule
+// fires. It returns immediately when no schedule is configured.
+func (s *Server) startDBCompactor(ctx context.Context) {
+	if s.compactSchedule == nil {
+		return
+	}
+	for {
+		next := s.compactSchedule.Next(time.Now())
+		if next.IsZero() {
+			s.logger.Warn("database compaction schedule never fires")
+			return
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if !s.compactMu.TryLock() {
+			continue
+		}
+		before, after, err := s.compactDB()
+		s.compactMu.Unlock()
+
+		if err != nil {
+			s.logger.Error("scheduled database compaction failed", zap.Error(err))
+			continue
+		}
+		s.audit(systemActor, auditDBCompacted, "", "", compactDetails(before, after))
+		s.logger.Info("scheduled database compaction finished",
+			zap.Int64("size_before", before.SizeBytes),
+			zap.Int64("size_after", after.SizeBytes),
+		)
+	}
+}
+
+// compactDB runs CompactDB and returns the storage stats from before and
+// after. The caller must hold compactMu.
+func (s *Server) compactDB() (before, after *db.StorageStats, err error) {
+	if before, err = s.db.GetStorageStats(); err != nil {
+		return nil, nil, err
+	}
+	if err = s.db.CompactDB(); err != nil {
+		return nil, nil, err
+	}
+	if after, err = s.db.GetStorageStats(); err != nil {
+		return nil, nil, err
+	}
+	return before, after, nil
+}
+
+// compactDetails formats the audit details of a compaction.
+func compactDetails(before, after *db.StorageStats) string {
+	return fmt.Sprintf("size_before=%d size_after=%d free_pages_before=%d",
+		before.SizeBytes, after.SizeBytes, before.FreePages)
+}
+
+// handleCompactDB compacts the database on demand. Only one compaction may
+// run at a time.
+func (s *Server) handleCompactDB(c *gin.Context) {
+	if !s.compactMu.TryLock() {
+		c.JSON(http.StatusConflict, gin.H{"error": "compaction already in progress"})
+		return
+	}
+	defer s.compactMu.Unlock()
+
+	start := time.Now()
+	before, after, err := s.compactDB()
+	if err != nil {
+		s.logger.Error("failed to compact database", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to compact database"})
+		return
+	}
+	s.audit(s.requestActor(c), auditDBCompacted, "", "", compactDetails(before, after))
+
+	c.JSON(http.StatusOK, gin.H{
+		"size_before":     before.SizeBytes,
+		"size_after":      after.SizeBytes,
+		"reclaimed_bytes": before.SizeBytes - after.SizeBytes,
+		"reclaimed_pages": before.FreePages - after.FreePages,
+		"duration_ms":     time.Since(start).Milliseconds(),
+	})
+}
+
+// handleDBStats reports the size of the database file and its tables.
+func (s *Server) handleDBStats(c *gin.Context) {
+	stats, err := s.db.GetStorageStats()
+	if err != nil {
+		s.logger.Error("failed to read database stats", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read database stats"})
+		return
+	}
+
+	tables := make(gin.H, len(stats.Tables))
+	for name, table := range stats.Tables {
+		tables[name] = gin.H{
+			"rows":    table.Rows,
+			"indexes": table.Indexes,
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"size_bytes":  stats.SizeBytes,
+		"page_size":   stats.PageSize,
+		"page_count":  stats.PageCount,
+		"free_pages":  stats.FreePages,
+		"table_stats": tables,
+	})
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/airfi/airfi-perun-nervous/internal/auth"
+)
+
+func TestHandleDBStatsAndCompact(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+
+	r := gin.New()
+	admin := r.Group("/api/v1/admin", s.dashboardAuthMiddleware(auth.APIKeyScopeAdmin))
+	admin.POST("/db/compact", s.handleCompactDB)
+	admin.GET("/db/stats", s.handleDBStats)
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.AddCookie(hostCookie(t, s))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Requires dashboard credentials
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/db/stats", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected 401 without credentials, got %d", w.Code)
+	}
+
+	w = do(http.MethodGet, "/api/v1/admin/db/stats")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var stats struct {
+		SizeBytes  int64 `json:"size_bytes"`
+		PageCount  int64 `json:"page_count"`
+		FreePages  int64 `json:"free_pages"`
+		TableStats map[string]struct {
+			Rows int64 `json:"rows"`
+		} `json:"table_stats"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("failed to decode stats: %v", err)
+	}
+	if stats.SizeBytes <= 0 || stats.PageCount <= 0 {
+		t.Errorf("Expected positive size and page count, got %+v", stats)
+	}
+	if _, ok := stats.TableStats["sessions"]; !ok {
+		t.Errorf("Expected sessions in table_stats, got %v", stats.TableStats)
+	}
+
+	w = do(http.MethodPost, "/api/v1/admin/db/compact")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 compacting, got %d: %s", w.Code, w.Body.String())
+	}
+	var compacted map[string]int64
+	if err := json.Unmarshal(w.Body.Bytes(), &compacted); err != nil {
+		t.Fatalf("failed to decode compaction result: %v", err)
+	}
+	if compacted["size_after"] <= 0 {
+		t.Errorf("Expected size_after, got %v", compacted)
+	}
+
+	// A compaction already running is rejected
+	s.compactMu.Lock()
+	w = do(http.MethodPost, "/api/v1/admin/db/compact")
+	s.compactMu.Unlock()
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 while compacting, got %d", w.Code)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
+)
+
+// sessionDisputes records channel disputes on the session funded through
+// the channel, as perun.DisputeRecorder.
+type sessionDisputes struct {
+	db *db.DB
+}
+
+// RecordChannelDispute marks the channel's session as disputed.
+func (d sessionDisputes) RecordChannelDispute(channelID perun.ChannelID, txHash string) error {
+	session, err := d.db.GetSessionByChannelID(channelID)
+	if err != nil {
+		return fmt.Errorf("no session for channel %s: %w", channelID.Short(), err)
+	}
+	return d.db.RecordDispute(session.ID, txHash)
+}
+
+// RecordChannelResolution marks the channel's session dispute as resolved.
+func (d sessionDisputes) RecordChannelResolution(channelID perun.ChannelID, txHash string) error {
+	session, err := d.db.GetSessionByChannelID(channelID)
+	if err != nil {
+		return fmt.Errorf("no session for channel %s: %w", channelID.Short(), err)
+	}
+	return d.db.RecordResolution(session.ID, txHash)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
+)
+
+func TestSessionDisputes(t *testing.T) {
+	s := newTestServer(t)
+	channelID := perun.ChannelID{1, 2, 3}
+	s.db.CreateSession(&db.Session{ID: "s1", ChannelID: channelID, Status: "active", ExpiresAt: time.Now().Add(time.Hour)})
+
+	disputes := sessionDisputes{db: s.db}
+	if err := disputes.RecordChannelDispute(channelID, "0xdispute"); err != nil {
+		t.Fatalf("RecordChannelDispute failed: %v", err)
+	}
+	if err := disputes.RecordChannelResolution(channelID, "0xresolve"); err != nil {
+		t.Fatalf("RecordChannelResolution failed: %v", err)
+	}
+	session, _ := s.db.GetSession("s1")
+	if session.DisputeTxHash != "0xdispute" || session.ResolutionTxHash != "0xresolve" || session.ResolvedAt == nil {
+		t.Errorf("Expected the dispute recorded on the session, got %+v", session)
+	}
+
+	if err := disputes.RecordChannelDispute(perun.ChannelID{9}, ""); err == nil {
+		t.Error("Expected an error for a channel without a session")
+	}
+}
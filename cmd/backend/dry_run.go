@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
+)
+
+// enableDryRun replaces every channel operation with the in-memory ledger of
+// a mock channel client, so the full session flow runs without spending CKB.
+// Guest cell preparation is skipped and refunds are not sent.
+func (s *Server) enableDryRun() {
+	s.logger.Warn("DRY-RUN mode: no on-chain transactions will be sent")
+	s.dryRun = perun.NewMockChannelClient()
+	s.channelOpener = s.openDryRunChannel
+	s.channelSettled = func(ctx context.Context, channelID perun.ChannelID) (bool, error) {
+		return s.dryRun.Settled(channelID), nil
+	}
+	s.fundingStatus = func(ctx context.Context, channelID perun.ChannelID) (*perun.FundingStatus, error) {
+		if _, ok := s.dryRun.Channel(channelID); !ok {
+			return &perun.FundingStatus{Phase: perun.FundingPhasePending}, nil
+		}
+		return &perun.FundingStatus{Phase: perun.FundingPhaseConfirmed, Confirmed: true}, nil
+	}
+	s.settlementFee = func(ctx context.Context, session *GuestSession) (uint64, error) {
+		return 0, nil
+	}
+	s.peerHeartbeat = func(ctx context.Context, session *GuestSession) error {
+		return nil
+	}
+	s.channelCloser = func(ctx context.Context, session *GuestSession) error {
+		return s.dryRun.SettleChannel(session.ChannelID)
+	}
+}
+
+// openDryRunChannel is openChannelForSession against the dry-run ledger: the
+// channel opens at once with the same funding split and catch-up payment.
+func (s *Server) openDryRunChannel(ctx context.Context, wallet *db.GuestWallet, sessionID string, balanceCKB int64) {
+	if balanceCKB < s.channelSetupCKB {
+		s.logger.Error("insufficient balance for channel",
+			zap.Int64("balance", balanceCKB),
+			zap.Int64("minimum_required", s.channelSetupCKB),
+		)
+		s.db.UpdateSessionStatus(sessionID, "insufficient_funds")
+		return
+	}
+
+	fundingCKB := balanceCKB - s.channelSetupCKB
+	guestFunding := big.NewInt(fundingCKB * 100000000)
+	channelID := s.dryRun.OpenChannel(guestFunding, big.NewInt(10000000000))
+
+	err := s.db.Transaction(func(tx *db.DB) error {
+		if err := tx.UpdateSessionChannel(sessionID, channelID, "active"); err != nil {
+			return fmt.Errorf("failed to update session channel: %w", err)
+		}
+		if err := tx.UpdateWalletStatus(wallet.ID, "channel_open"); err != nil {
+			return fmt.Errorf("failed to update wallet status: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("failed to record channel opening", zap.String("session_id", sessionID), zap.Error(err))
+		return
+	}
+	s.audit(systemActor, auditChannelOpened, sessionID, wallet.ID, "channel_id="+channelID.String()+" dry_run=true")
+	s.recordDryRunSnapshot(sessionID, channelID)
+
+	dbSession, err := s.db.GetSession(sessionID)
+	if err != nil {
+		s.logger.Error("failed to get session for catch-up calculation", zap.Error(err))
+		return
+	}
+
+	elapsedMinutes := max(int64(time.Since(dbSession.CreatedAt).Minutes()), 1)
+	catchUpShannons := new(big.Int).Mul(big.NewInt(elapsedMinutes), s.ratePerMin)
+	if err := s.dryRun.SendPaymentBatch(channelID, intervalPayments(s.ratePerMin, elapsedMinutes)); err != nil {
+		s.logger.Error("failed to send catch-up payment", zap.Error(err))
+		catchUpShannons = new(big.Int)
+	} else {
+		s.recordDryRunSnapshot(sessionID, channelID)
+		s.recordPayment(sessionID, db.PaymentCatchUp, catchUpShannons)
+	}
+
+	guestSession := &GuestSession{
+		ID:            sessionID,
+		ChannelID:     channelID,
+		GuestAddress:  wallet.Address,
+		FundingAmount: guestFunding,
+		TotalPaid:     catchUpShannons,
+		CreatedAt:     dbSession.CreatedAt,
+		ExpiresAt:     dbSession.ExpiresAt,
+		LastPaymentAt: time.Now(),
+	}
+	s.sessionsMu.Lock()
+	s.sessions[sessionID] = guestSession
+	s.sessionsMu.Unlock()
+	s.storeSessionOpened(guestSession)
+
+	_, spentCKB, balance := guestSession.wholeCKB()
+	s.db.UpdateSessionBalance(sessionID, balance, spentCKB)
+
+	s.logger.Info("dry-run channel opened",
+		zap.String("session_id", sessionID),
+		zap.String("channel_id", channelID.String()),
+		zap.Int64("guest_funding", balanceCKB),
+	)
+}
+
+// sendSessionPayments sends payments on a session's channel in one update
+// without waiting for the peer, or records them in the dry-run ledger. The
+// caller holds sessionsMu; credit runs with it held once the update is
+// acknowledged. A dropped update is only logged, so its intervals are
+// caught up on a later tick.
+func (s *Server) sendSessionPayments(session *GuestSession, payments []*big.Int, credit func()) error {
+	if s.dryRun != nil {
+		if err := s.dryRun.SendPaymentBatch(session.ChannelID, payments); err != nil {
+			return err
+		}
+		credit()
+		return nil
+	}
+	return session.Client.SendPaymentBatchAsync(session.Channel, payments, func(err error) {
+		if err != nil {
+			s.logger.Error("micropayment not acknowledged", zap.String("session_id", session.ID), zap.Error(err))
+			return
+		}
+		s.sessionsMu.Lock()
+		defer s.sessionsMu.Unlock()
+		credit()
+	})
+}
+
+// recordSessionSnapshot records the latest state of a session's channel.
+func (s *Server) recordSessionSnapshot(session *GuestSession) {
+	if s.dryRun != nil {
+		s.recordDryRunSnapshot(session.ID, session.ChannelID)
+		return
+	}
+	s.recordChannelSnapshot(session.ID, session.Channel)
+}
+
+// recordDryRunSnapshot records a dry-run channel's balances like
+// recordChannelSnapshot does for a real channel.
+func (s *Server) recordDryRunSnapshot(sessionID string, channelID perun.ChannelID) {
+	ch, ok := s.dryRun.Channel(channelID)
+	if !ok {
+		return
+	}
+	err := s.db.SaveChannelSnapshot(&db.ChannelSnapshot{
+		SessionID:           sessionID,
+		ChannelID:           channelID,
+		Version:             ch.Version,
+		HostInitialShannons: ch.HostBalance.Int64(),
+		HostBalanceShannons: ch.HostBalance.Int64(),
+	})
+	if err != nil {
+		s.logger.Warn("failed to record channel snapshot", zap.String("session_id", sessionID), zap.Error(err))
+	}
+}
+
+// handleDryRunChannels returns the dry-run ledger of every channel.
+func (s *Server) handleDryRunChannels(c *gin.Context) {
+	if s.dryRun == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "dry-run mode is not enabled"})
+		return
+	}
+	c.JSON(http.StatusOK, s.dryRun)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+)
+
+func TestDryRunSessionFlow(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+	s.enableDryRun()
+	ctx := context.Background()
+
+	wallet := &db.GuestWallet{ID: "wallet-1", Address: "ckt1guest", FundingCKB: 1500, Status: "funded", CreatedAt: time.Now()}
+	s.db.CreateGuestWallet(wallet)
+	s.db.CreateSession(&db.Session{
+		ID:        "session-1",
+		WalletID:  "wallet-1",
+		Status:    "active",
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+
+	// 1500 CKB less the 1000 CKB setup reserve funds the channel
+	s.channelOpener(ctx, wallet, "session-1", 1500)
+	session, ok := s.sessions["session-1"]
+	if !ok {
+		t.Fatal("Expected the dry-run session to be active in memory")
+	}
+	dbSession, _ := s.db.GetSession("session-1")
+	if dbSession.Status != "active" || dbSession.ChannelID != session.ChannelID || session.ChannelID.IsZero() {
+		t.Errorf("Expected the session recorded active on its dry-run channel, got %q", dbSession.Status)
+	}
+
+	// One minute of catch-up was paid on opening; two more are due
+	session.LastPaymentAt = time.Now().Add(-2 * time.Minute)
+	s.processMicropayments(ctx)
+	ch, _ := s.dryRun.Channel(session.ChannelID)
+	if want := 3 * s.ratePerMin.Int64(); ch.Payments != 3 || session.TotalPaid.Int64() != want || ch.HostBalance.Int64() != 10000000000+want {
+		t.Errorf("Expected 3 payments of %s shannons, got %+v with %s paid", s.ratePerMin, ch, session.TotalPaid)
+	}
+
+	// The session store follows the session, counting payments after opening
+	stored, err := s.sessionStore.Get("session-1")
+	if err != nil {
+		t.Fatalf("Expected the session in the store: %v", err)
+	}
+	if want := 2 * s.ratePerMin.Int64(); !stored.IsActive() || stored.TotalPaid.Int64() != want || stored.ChannelID != session.ChannelID.String() {
+		t.Errorf("Expected an active stored session with %d paid, got %+v", want, stored)
+	}
+
+	s.walletLock("wallet-1")
+	detached, _ := s.detachSession(ctx, "session-1", systemActor)
+	if err := s.settleSession(detached); err != nil {
+		t.Fatalf("settleSession failed: %v", err)
+	}
+	if _, ok := s.walletLocks.Load("wallet-1"); ok {
+		t.Error("Expected the wallet lock dropped after settlement")
+	}
+	if !s.dryRun.Settled(session.ChannelID) {
+		t.Error("Expected the dry-run channel to be settled")
+	}
+	if dbSession, _ := s.db.GetSession("session-1"); dbSession.Status != "settled" {
+		t.Errorf("Expected the session settled, got %q", dbSession.Status)
+	}
+	if stored.Status != "ended" {
+		t.Errorf("Expected the stored session ended, got %q", stored.Status)
+	}
+
+	r := gin.New()
+	r.GET("/api/v1/admin/dry-run/channels", s.handleDryRunChannels)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/dry-run/channels", nil))
+	var ledger struct {
+		Channels []json.RawMessage `json:"channels"`
+	}
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &ledger) != nil || len(ledger.Channels) != 1 {
+		t.Errorf("Expected the ledger with one channel, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHandleDryRunChannels_Disabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+
+	r := gin.New()
+	r.GET("/api/v1/admin/dry-run/channels", s.handleDryRunChannels)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/dry-run/channels", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 outside dry-run mode, got %d", w.Code)
+	}
+}
+
+func TestHandleManualRefund_DryRun(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+	s.enableDryRun()
+
+	s.db.CreateGuestWallet(&db.GuestWallet{ID: "wallet-1", Address: "ckt1guest", SessionID: "session-1", Status: "settled", CreatedAt: time.Now()})
+
+	r := gin.New()
+	r.POST("/api/v1/sessions/:sessionId/refund", s.handleManualRefund)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/sessions/session-1/refund", strings.NewReader(`{"to_address":"ckt1sender"}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected 409, got %d: %s", w.Code, w.Body.String())
+	}
+}
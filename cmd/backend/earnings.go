@@ -0,0 +1,93 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	gpclient "perun.network/go-perun/client"
+
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
+)
+
+// recordChannelSnapshot stores the channel's current signed state for the
+// session. The server runs the guest's client, so the host is the peer.
+func (s *Server) recordChannelSnapshot(sessionID string, ch *gpclient.Channel) {
+	state := ch.State()
+	hostBalance := state.Allocation.Balance(1-ch.Idx(), perun.ChannelAsset(&state.Allocation)).Int64()
+	err := s.db.SaveChannelSnapshot(&db.ChannelSnapshot{
+		SessionID:           sessionID,
+		ChannelID:           perun.ChannelID(ch.ID()),
+		Version:             state.Version,
+		HostInitialShannons: hostBalance,
+		HostBalanceShannons: hostBalance,
+	})
+	if err != nil {
+		s.logger.Warn("failed to record channel snapshot", zap.String("session_id", sessionID), zap.Error(err))
+	}
+}
+
+// sessionEarnings returns the session's earnings from its latest channel
+// state, or nil if none was recorded.
+func (s *Server) sessionEarnings(sessionID string) *int64 {
+	earnings, err := s.db.GetSessionEarnings(sessionID)
+	if err != nil {
+		return nil
+	}
+	return &earnings
+}
+
+// handleSyncSessionEarnings overwrites a session's spent_ckb with the
+// earnings of its latest signed channel state, for when the two drifted
+// apart after failed payments or a restart.
+func (s *Server) handleSyncSessionEarnings(c *gin.Context) {
+	sessionID := c.Param("sessionId")
+
+	dbSession, err := s.db.GetSession(sessionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return
+	}
+
+	earnings, err := s.db.GetSessionEarnings(sessionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no channel state recorded for session"})
+		return
+	}
+	if err != nil {
+		s.logger.Error("failed to get session earnings", zap.String("session_id", sessionID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get session earnings"})
+		return
+	}
+
+	balance := dbSession.FundingCKB - earnings
+	if err := s.db.UpdateSessionBalance(sessionID, balance, earnings); err != nil {
+		s.logger.Error("failed to sync session earnings", zap.String("session_id", sessionID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update session"})
+		return
+	}
+
+	previous := dbSession.SpentCKB
+	if previous != earnings {
+		s.logger.Info("session spent amount synced to channel state",
+			zap.String("session_id", sessionID),
+			zap.Int64("previous_spent_ckb", previous),
+			zap.Int64("spent_ckb", earnings),
+		)
+	}
+	s.audit(s.requestActor(c), auditEarningsSynced, sessionID, dbSession.WalletID,
+		fmt.Sprintf("previous_spent_ckb=%d spent_ckb=%d", previous, earnings))
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id":         sessionID,
+		"previous_spent_ckb": previous,
+		"spent_ckb":          earnings,
+		"balance_ckb":        balance,
+		"earnings_ckb":       earnings,
+	})
+}
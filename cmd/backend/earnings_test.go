@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/airfi/airfi-perun-nervous/internal/auth"
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+)
+
+func TestHandleSyncSessionEarnings(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+
+	// The processor recorded 12 CKB spent, the channel says 20 CKB was paid
+	s.db.CreateSession(&db.Session{
+		ID:         "sess-drift",
+		WalletID:   "wallet-drift",
+		FundingCKB: 500,
+		BalanceCKB: 488,
+		SpentCKB:   12,
+		Status:     "active",
+		ExpiresAt:  time.Now().Add(time.Hour),
+	})
+	s.db.SaveChannelSnapshot(&db.ChannelSnapshot{SessionID: "sess-drift"})
+	s.db.SaveChannelSnapshot(&db.ChannelSnapshot{SessionID: "sess-drift", Version: 4, HostBalanceShannons: 20 * 100000000})
+	s.db.CreateSession(&db.Session{ID: "sess-unsigned", WalletID: "wallet-unsigned", Status: "active"})
+
+	r := gin.New()
+	admin := r.Group("/api/v1/admin", s.dashboardAuthMiddleware(auth.APIKeyScopeAdmin))
+	admin.POST("/sessions/:sessionId/sync-earnings", s.handleSyncSessionEarnings)
+
+	sync := func(sessionID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/sessions/"+sessionID+"/sync-earnings", nil)
+		req.AddCookie(hostCookie(t, s))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := sync("sess-drift")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		PreviousSpentCKB int64 `json:"previous_spent_ckb"`
+		SpentCKB         int64 `json:"spent_ckb"`
+		BalanceCKB       int64 `json:"balance_ckb"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.PreviousSpentCKB != 12 || resp.SpentCKB != 20 || resp.BalanceCKB != 480 {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+
+	sess, _ := s.db.GetSession("sess-drift")
+	if sess.SpentCKB != 20 || sess.BalanceCKB != 480 {
+		t.Errorf("Expected spent 20 and balance 480 stored, got %d and %d", sess.SpentCKB, sess.BalanceCKB)
+	}
+
+	if w := sync("sess-unsigned"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 without channel state, got %d", w.Code)
+	}
+	if w := sync("missing"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown session, got %d", w.Code)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/airfi/airfi-perun-nervous/internal/router"
+)
+
+const (
+	// expiryWarningWindow is how long before expiry a guest is warned.
+	expiryWarningWindow = 5 * time.Minute
+	// expiryCheckInterval is how often expiring sessions are looked for.
+	expiryCheckInterval = time.Minute
+	// expiryWarningKbps is the bandwidth an expiring session is throttled
+	// to, so the guest notices even without the session page open.
+	expiryWarningKbps = 512
+)
+
+// startExpiryNotifier runs a background loop that warns guests whose
+// session is about to expire.
+func (s *Server) startExpiryNotifier(ctx context.Context) {
+	ticker := time.NewTicker(expiryCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.notifyExpiringSessions(ctx, time.Now())
+		}
+	}
+}
+
+// notifyExpiringSessions sends a session.expiring_soon webhook for each
+// active session expiring within expiryWarningWindow and throttles its
+// device. Each session is notified once per expiry; an extension moves the
+// expiry and makes it eligible again. Returns how many were notified.
+func (s *Server) notifyExpiringSessions(ctx context.Context, now time.Time) int {
+	expiring, err := s.db.GetSessionsExpiringWithin(expiryWarningWindow)
+	if err != nil {
+		s.logger.Error("failed to list expiring sessions", zap.Error(err))
+		return 0
+	}
+
+	notified := 0
+	for _, dbSession := range expiring {
+		if !dbSession.ExpiresAt.After(now) {
+			continue
+		}
+		if n := dbSession.ExpiryNotifiedAt; n != nil && !n.Before(dbSession.ExpiresAt.Add(-expiryWarningWindow)) {
+			continue
+		}
+
+		if err := s.db.UpdateSessionExpiryNotified(dbSession.ID, now); err != nil {
+			s.logger.Error("failed to record expiry notification", zap.String("session_id", dbSession.ID), zap.Error(err))
+			continue
+		}
+		notified++
+
+		remaining := dbSession.ExpiresAt.Sub(now)
+		s.logger.Info("session expiring soon",
+			zap.String("session_id", dbSession.ID),
+			zap.Duration("remaining", remaining),
+		)
+		s.webhooks.Emit("session.expiring_soon", map[string]any{
+			"session_id":        dbSession.ID,
+			"wallet_id":         dbSession.WalletID,
+			"expires_at":        dbSession.ExpiresAt.UTC(),
+			"remaining_seconds": int64(remaining.Seconds()),
+		})
+
+		if limiter, ok := s.router.(router.BandwidthLimiter); ok && dbSession.MACAddress != "" && s.sessionStillActive(dbSession.ID) {
+			if err := limiter.LimitBandwidth(ctx, dbSession.MACAddress, expiryWarningKbps); err != nil {
+				s.logger.Warn("failed to throttle expiring session",
+					zap.String("session_id", dbSession.ID),
+					zap.String("mac_address", dbSession.MACAddress),
+					zap.Error(err),
+				)
+			}
+		}
+	}
+	return notified
+}
+
+// liftExpiryThrottle removes the bandwidth limit from a session that was
+// warned about expiring and now has more than expiryWarningWindow left.
+func (s *Server) liftExpiryThrottle(ctx context.Context, sessionID string) {
+	limiter, ok := s.router.(router.BandwidthLimiter)
+	if !ok {
+		return
+	}
+	dbSession, err := s.db.GetSession(sessionID)
+	if err != nil || dbSession.Status != "active" || dbSession.ExpiryNotifiedAt == nil || dbSession.MACAddress == "" {
+		return
+	}
+	if time.Until(dbSession.ExpiresAt) <= expiryWarningWindow {
+		return
+	}
+	if err := limiter.LimitBandwidth(ctx, dbSession.MACAddress, 0); err != nil {
+		s.logger.Warn("failed to lift expiry throttle",
+			zap.String("session_id", sessionID),
+			zap.String("mac_address", dbSession.MACAddress),
+			zap.Error(err),
+		)
+	}
+}
+
+// sessionStillActive re-reads the session so a device is not throttled,
+// and thereby briefly re-authorized, after settlement revoked its access.
+func (s *Server) sessionStillActive(sessionID string) bool {
+	dbSession, err := s.db.GetSession(sessionID)
+	return err == nil && dbSession.Status == "active"
+}
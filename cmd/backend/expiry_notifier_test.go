@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+	"github.com/airfi/airfi-perun-nervous/internal/webhook"
+	"github.com/airfi/airfi-perun-nervous/tests/mocks"
+)
+
+func TestNotifyExpiringSessions(t *testing.T) {
+	events := make(chan webhook.Event, 4)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event webhook.Event
+		json.NewDecoder(r.Body).Decode(&event)
+		events <- event
+	}))
+	defer hook.Close()
+
+	s := newTestServer(t)
+	s.webhooks = webhook.NewNotifier([]string{hook.URL}, "", zap.NewNop())
+	mockRouter := mocks.NewMockRouter()
+	s.router = mockRouter
+
+	now := time.Now()
+	s.db.CreateSession(&db.Session{
+		ID:         "sess-expiring",
+		WalletID:   "wallet-expiring",
+		Status:     "active",
+		ExpiresAt:  now.Add(3 * time.Minute),
+		MACAddress: "aa:bb:cc:dd:ee:ff",
+	})
+	s.db.CreateSession(&db.Session{ID: "sess-later", Status: "active", ExpiresAt: now.Add(time.Hour)})
+
+	ctx := context.Background()
+	mockRouter.AuthorizeMAC(ctx, "aa:bb:cc:dd:ee:ff", "", "", "")
+	if n := s.notifyExpiringSessions(ctx, now.Add(expiryCheckInterval)); n != 1 {
+		t.Fatalf("Expected 1 session notified on the next tick, got %d", n)
+	}
+
+	select {
+	case event := <-events:
+		if event.Event != "session.expiring_soon" || event.Data["session_id"] != "sess-expiring" {
+			t.Errorf("Unexpected webhook event: %+v", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a session.expiring_soon webhook")
+	}
+
+	if kbps := mockRouter.GetBandwidthLimit("aa:bb:cc:dd:ee:ff"); kbps != expiryWarningKbps {
+		t.Errorf("Expected device throttled to %d kbps, got %d", expiryWarningKbps, kbps)
+	}
+	dbSession, _ := s.db.GetSession("sess-expiring")
+	if dbSession.ExpiryNotifiedAt == nil {
+		t.Error("Expected ExpiryNotifiedAt to be recorded")
+	}
+
+	// The following tick does not notify again
+	if n := s.notifyExpiringSessions(ctx, now.Add(2*expiryCheckInterval)); n != 0 {
+		t.Errorf("Expected no duplicate notification, got %d", n)
+	}
+
+	// Extending the session lifts the throttle
+	s.db.UpdateSessionExpiry("sess-expiring", now.Add(time.Hour))
+	s.liftExpiryThrottle(ctx, "sess-expiring")
+	if kbps := mockRouter.GetBandwidthLimit("aa:bb:cc:dd:ee:ff"); kbps != 0 {
+		t.Errorf("Expected throttle lifted after extension, got %d kbps", kbps)
+	}
+}
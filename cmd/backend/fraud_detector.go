@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/airfi/airfi-perun-nervous/internal/session"
+)
+
+const (
+	// fraudRateMultiplier is how many times its contracted rate a session
+	// may pay before it is reported as suspicious.
+	fraudRateMultiplier = 2.0
+	// fraudCheckInterval is how often sessions are checked.
+	fraudCheckInterval = time.Minute
+)
+
+// startFraudDetector runs a background loop that reports sessions paying
+// faster than their contracted rate.
+func (s *Server) startFraudDetector(ctx context.Context) {
+	ticker := time.NewTicker(fraudCheckInterval)
+	defer ticker.Stop()
+
+	reported := make(map[string]bool)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.reportSuspiciousSessions(reported)
+		}
+	}
+}
+
+// suspiciousSessions returns the running sessions paying faster than
+// fraudRateMultiplier times their contracted rate. The session store mirrors
+// them with their per-minute payments; sessions it restored from a snapshot
+// whose channel is gone are left out.
+func (s *Server) suspiciousSessions() []*session.Session {
+	var live []*session.Session
+	for _, sess := range s.sessionStore.FindSuspiciousSessions(fraudRateMultiplier) {
+		if s.sessionLive(sess.ID) {
+			live = append(live, sess)
+		}
+	}
+	return live
+}
+
+// reportSuspiciousSessions logs a warning and sends a
+// session.suspicious_activity webhook for each suspicious session not yet in
+// reported, then adds it. Sessions that are no longer suspicious are dropped
+// from reported so they are reported again if they recur. Returns how many
+// were reported.
+func (s *Server) reportSuspiciousSessions(reported map[string]bool) int {
+	suspicious := s.suspiciousSessions()
+
+	current := make(map[string]bool, len(suspicious))
+	count := 0
+	for _, sess := range suspicious {
+		current[sess.ID] = true
+		if reported[sess.ID] {
+			continue
+		}
+		count++
+
+		velocity := sess.PaymentVelocity()
+		s.logger.Warn("suspicious session payment velocity",
+			zap.String("session_id", sess.ID),
+			zap.String("channel_id", sess.ChannelID),
+			zap.String("velocity_per_min", velocity.String()),
+			zap.String("rate_per_min", sess.RatePerMin.String()),
+		)
+		s.webhooks.Emit("session.suspicious_activity", map[string]any{
+			"session_id":       sess.ID,
+			"channel_id":       sess.ChannelID,
+			"guest_address":    sess.GuestAddr,
+			"velocity_per_min": velocity.String(),
+			"rate_per_min":     sess.RatePerMin.String(),
+		})
+	}
+
+	for id := range reported {
+		delete(reported, id)
+	}
+	for id := range current {
+		reported[id] = true
+	}
+	return count
+}
+
+// handleListSuspiciousSessions lists active sessions paying faster than
+// fraudRateMultiplier times their contracted rate.
+func (s *Server) handleListSuspiciousSessions(c *gin.Context) {
+	suspicious := s.suspiciousSessions()
+	sort.Slice(suspicious, func(i, j int) bool {
+		return suspicious[i].StartTime.Before(suspicious[j].StartTime)
+	})
+
+	result := make([]gin.H, 0, len(suspicious))
+	for _, sess := range suspicious {
+		result = append(result, gin.H{
+			"session_id":       sess.ID,
+			"channel_id":       sess.ChannelID,
+			"guest_address":    sess.GuestAddr,
+			"started_at":       sess.StartTime.Format(time.RFC3339),
+			"total_paid":       sess.TotalPaid.String(),
+			"total_paid_ckb":   sess.TotalPaidCKBString(),
+			"velocity_per_min": sess.PaymentVelocity().String(),
+			"rate_per_min":     sess.RatePerMin.String(),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sessions":        result,
+		"count":           len(result),
+		"rate_multiplier": fraudRateMultiplier,
+	})
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
+	"github.com/airfi/airfi-perun-nervous/internal/session"
+	"github.com/airfi/airfi-perun-nervous/internal/webhook"
+)
+
+func TestReportSuspiciousSessions(t *testing.T) {
+	events := make(chan webhook.Event, 4)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event webhook.Event
+		json.NewDecoder(r.Body).Decode(&event)
+		events <- event
+	}))
+	defer hook.Close()
+
+	s := newTestServer(t)
+	s.webhooks = webhook.NewNotifier([]string{hook.URL}, "", zap.NewNop())
+
+	// A running session paying three times its rate in its first minute
+	sess := &GuestSession{ID: "session-1", ChannelID: perun.ChannelID{1}, GuestAddress: "ckt1guest", ExpiresAt: time.Now().Add(time.Hour)}
+	s.sessions[sess.ID] = sess
+	s.storeSessionOpened(sess)
+	s.storeSessionPaid(sess.ID, new(big.Int).Mul(s.ratePerMin, big.NewInt(3)))
+
+	// One restored from a snapshot whose channel is gone
+	restored, _ := s.sessionStore.Create("channel-2", "ckt1other")
+	s.sessionStore.Activate(restored.ID, time.Hour, "", new(big.Int).Mul(s.ratePerMin, big.NewInt(3)))
+	s.sessionStore.SetRate(restored.ID, s.ratePerMin)
+
+	reported := make(map[string]bool)
+	if n := s.reportSuspiciousSessions(reported); n != 1 {
+		t.Fatalf("Expected 1 session reported, got %d", n)
+	}
+
+	select {
+	case event := <-events:
+		if event.Event != "session.suspicious_activity" || event.Data["session_id"] != sess.ID {
+			t.Errorf("Unexpected webhook event: %+v", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a session.suspicious_activity webhook")
+	}
+
+	// The following check does not report it again
+	if n := s.reportSuspiciousSessions(reported); n != 0 {
+		t.Errorf("Expected no duplicate report, got %d", n)
+	}
+
+	r := gin.New()
+	r.GET("/api/v1/admin/sessions/suspicious", s.handleListSuspiciousSessions)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/sessions/suspicious", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Sessions []struct {
+			SessionID    string `json:"session_id"`
+			TotalPaidCKB string `json:"total_paid_ckb"`
+		} `json:"sessions"`
+		Count int `json:"count"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Count != 1 || resp.Sessions[0].SessionID != sess.ID {
+		t.Fatalf("Expected the session listed, got %+v", resp)
+	}
+	if paid := session.FormatCKB(new(big.Int).Mul(s.ratePerMin, big.NewInt(3))); resp.Sessions[0].TotalPaidCKB != paid {
+		t.Errorf("Expected total_paid_ckb %s, got %s", paid, resp.Sessions[0].TotalPaidCKB)
+	}
+}
+
+func TestUpdateRatePerMin_UpdatesContractedRate(t *testing.T) {
+	s := newTestServer(t)
+	sess := &GuestSession{ID: "session-1", ChannelID: perun.ChannelID{1}, ExpiresAt: time.Now().Add(time.Hour)}
+	s.sessions[sess.ID] = sess
+	s.storeSessionOpened(sess)
+
+	s.updateRatePerMin(120)
+	stored, _ := s.sessionStore.Get(sess.ID)
+	if stored.RatePerMin.Cmp(big.NewInt(200000000)) != 0 {
+		t.Errorf("Expected the contracted rate to follow the new rate, got %s", stored.RatePerMin)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nervosnetwork/ckb-sdk-go/v2/indexer"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/rpc"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
+	"go.uber.org/zap"
+
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+	"github.com/airfi/airfi-perun-nervous/internal/guest"
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
+	"github.com/airfi/airfi-perun-nervous/tests/mocks"
+)
+
+// fundedRPCClient reports every wallet as holding one 2000 CKB cell.
+// Unimplemented methods panic through the embedded nil rpc.Client.
+type fundedRPCClient struct {
+	rpc.Client
+}
+
+func (m *fundedRPCClient) GetCells(ctx context.Context, searchKey *indexer.SearchKey, order indexer.SearchOrder, limit uint64, afterCursor string) (*indexer.LiveCells, error) {
+	return &indexer.LiveCells{
+		Objects: []*indexer.LiveCell{{
+			Output:   &types.CellOutput{Capacity: 2000 * 100000000, Lock: searchKey.Script},
+			OutPoint: &types.OutPoint{TxHash: types.HexToHash("0x01")},
+		}},
+	}, nil
+}
+
+func (m *fundedRPCClient) GetTransactions(ctx context.Context, searchKey *indexer.SearchKey, order indexer.SearchOrder, limit uint64, afterCursor string) (*indexer.TxsWithCell, error) {
+	return nil, errors.New("not available")
+}
+
+func TestCheckPendingWallets_ConcurrentFunding(t *testing.T) {
+	s := newTestServer(t)
+	s.ckbClient = &fundedRPCClient{}
+	s.withdrawer = perun.NewWithdrawer(s.ckbClient, zap.NewNop())
+
+	walletMgr := guest.NewWalletManager(types.NetworkTest)
+	for i := 0; i < 10; i++ {
+		w, err := walletMgr.GenerateWallet()
+		if err != nil {
+			t.Fatalf("GenerateWallet failed: %v", err)
+		}
+		s.db.CreateGuestWallet(&db.GuestWallet{
+			ID:            fmt.Sprintf("wallet-%d", i),
+			Address:       w.Address,
+			PrivateKeyHex: w.GetPrivateKeyHex(),
+			FundingCKB:    1500,
+			Status:        "created",
+			CreatedAt:     time.Now(),
+		})
+	}
+
+	var mu sync.Mutex
+	var opening sync.WaitGroup
+	opened := make(map[string]int)
+	s.channelOpener = func(ctx context.Context, wallet *db.GuestWallet, sessionID string, balanceCKB int64) {
+		defer opening.Done()
+		walletMu := s.walletLock(wallet.ID)
+		walletMu.Lock()
+		defer walletMu.Unlock()
+
+		time.Sleep(10 * time.Millisecond) // simulate channel setup
+		mu.Lock()
+		opened[wallet.ID]++
+		mu.Unlock()
+	}
+	opening.Add(10)
+
+	// Several detector passes racing over the same pending wallets
+	var detectors sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		detectors.Add(1)
+		go func() {
+			defer detectors.Done()
+			s.checkPendingWallets(context.Background())
+		}()
+	}
+	detectors.Wait()
+	opening.Wait()
+
+	if len(opened) != 10 {
+		t.Errorf("Expected 10 distinct channels, got %d", len(opened))
+	}
+	for walletID, count := range opened {
+		if count != 1 {
+			t.Errorf("Wallet %s opened %d channels", walletID, count)
+		}
+	}
+
+	sessions, _ := s.db.ListSessions("")
+	if len(sessions) != 10 {
+		t.Errorf("Expected 10 sessions, got %d", len(sessions))
+	}
+}
+
+func TestChannelOpeningWithSlowRouter(t *testing.T) {
+	s := newTestServer(t)
+	s.ckbClient = &fundedRPCClient{}
+	s.withdrawer = perun.NewWithdrawer(s.ckbClient, zap.NewNop())
+	slowRouter := mocks.NewSimulatedLatencyRouter(1)
+	slowRouter.SetLatency(500*time.Millisecond, 50*time.Millisecond)
+	s.router = slowRouter
+
+	walletMgr := guest.NewWalletManager(types.NetworkTest)
+	const walletCount = 4
+	for i := 0; i < walletCount; i++ {
+		w, err := walletMgr.GenerateWallet()
+		if err != nil {
+			t.Fatalf("GenerateWallet failed: %v", err)
+		}
+		s.db.CreateGuestWallet(&db.GuestWallet{
+			ID:            fmt.Sprintf("wallet-%d", i),
+			Address:       w.Address,
+			PrivateKeyHex: w.GetPrivateKeyHex(),
+			FundingCKB:    1500,
+			Status:        "created",
+			CreatedAt:     time.Now(),
+			MACAddress:    fmt.Sprintf("AA:BB:CC:DD:EE:%02X", i),
+			IPAddress:     fmt.Sprintf("192.168.1.%d", 100+i),
+		})
+	}
+
+	var mu sync.Mutex
+	opened := make(map[string]string)
+	calls := 0
+	allOpened := make(chan struct{})
+	s.channelOpener = func(ctx context.Context, wallet *db.GuestWallet, sessionID string, balanceCKB int64) {
+		mu.Lock()
+		defer mu.Unlock()
+		opened[sessionID] = wallet.ID
+		if calls++; calls == walletCount {
+			close(allOpened)
+		}
+	}
+
+	// Detector passes overlap while each router call takes ~500ms
+	var detectors sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		detectors.Add(1)
+		go func() {
+			defer detectors.Done()
+			s.checkPendingWallets(context.Background())
+		}()
+	}
+	detectors.Wait()
+	select {
+	case <-allOpened:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for channel openings")
+	}
+
+	for i := 0; i < walletCount; i++ {
+		if mac := fmt.Sprintf("AA:BB:CC:DD:EE:%02X", i); !slowRouter.IsAuthorized(mac) {
+			t.Errorf("MAC %s not authorized", mac)
+		}
+	}
+
+	// Every session got exactly one channel opening
+	sessions, _ := s.db.ListSessions("")
+	if len(sessions) != walletCount {
+		t.Errorf("Expected %d sessions, got %d", walletCount, len(sessions))
+	}
+	for _, sess := range sessions {
+		if opened[sess.ID] != sess.WalletID {
+			t.Errorf("Session %s has no channel opening", sess.ID)
+		}
+	}
+
+	// Each opening clears its wallet's in-flight mark when it returns
+	inFlight := func() []string {
+		var wallets []string
+		for i := 0; i < walletCount; i++ {
+			if _, ok := s.inFlightWallets.Load(fmt.Sprintf("wallet-%d", i)); ok {
+				wallets = append(wallets, fmt.Sprintf("wallet-%d", i))
+			}
+		}
+		return wallets
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(inFlight()) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if wallets := inFlight(); len(wallets) > 0 {
+		t.Errorf("Wallets still marked in flight: %v", wallets)
+	}
+
+	// No wallet was opened twice
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != walletCount {
+		t.Errorf("Expected %d channel openings, got %d", walletCount, calls)
+	}
+}
@@ -1,12 +1,16 @@
 package main
 
 import (
-	"context"
 	"fmt"
+	"math/big"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/airfi/airfi-perun-nervous/internal/auth"
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+	"github.com/airfi/airfi-perun-nervous/internal/session"
 )
 
 // handleIndex serves the landing page.
@@ -18,14 +22,16 @@ func (s *Server) handleIndex(c *gin.Context) {
 
 // handleConnect serves the connect/payment page.
 func (s *Server) handleConnect(c *gin.Context) {
-	// Capture MAC and IP from OpenNDS captive portal redirect
+	// Capture MAC, IP and access point from OpenNDS captive portal redirect
 	mac := c.Query("mac")
 	ip := c.Query("ip")
+	ap := c.Query("ap")
 
 	c.HTML(http.StatusOK, "connect.html", gin.H{
-		"title":      "Connect - AirFi",
-		"macAddress": mac,
-		"ipAddress":  ip,
+		"title":       "Connect - AirFi",
+		"macAddress":  mac,
+		"ipAddress":   ip,
+		"accessPoint": ap,
 	})
 }
 
@@ -36,10 +42,7 @@ func (s *Server) handleSession(c *gin.Context) {
 	// Check database for session
 	dbSession, err := s.db.GetSession(sessionID)
 	if err == nil {
-		remaining := time.Until(dbSession.ExpiresAt)
-		if remaining < 0 {
-			remaining = 0
-		}
+		remaining := dbSession.TimeUntilExpiry()
 		status := dbSession.Status
 		if remaining <= 0 && status == "active" {
 			status = "expired"
@@ -52,12 +55,8 @@ func (s *Server) handleSession(c *gin.Context) {
 		}
 
 		channelDisplay := "Pending"
-		if dbSession.ChannelID != "" {
-			if len(dbSession.ChannelID) > 16 {
-				channelDisplay = dbSession.ChannelID[:16] + "..."
-			} else {
-				channelDisplay = dbSession.ChannelID
-			}
+		if !dbSession.ChannelID.IsZero() {
+			channelDisplay = dbSession.ChannelID.Short()
 		}
 
 		c.HTML(http.StatusOK, "session.html", gin.H{
@@ -65,12 +64,14 @@ func (s *Server) handleSession(c *gin.Context) {
 			"remainingTime": formatDuration(remaining),
 			"session": gin.H{
 				"ID":         displayID,
+				"FullID":     dbSession.ID,
 				"ChannelID":  channelDisplay,
 				"BalanceCKB": fmt.Sprintf("%d", dbSession.BalanceCKB),
 				"SpentCKB":   fmt.Sprintf("%d", dbSession.SpentCKB),
 				"FundingCKB": fmt.Sprintf("%d", dbSession.FundingCKB),
 				"Status":     status,
 			},
+			"refund": s.sessionRefundView(sessionID),
 		})
 		return
 	}
@@ -85,32 +86,54 @@ func (s *Server) handleSession(c *gin.Context) {
 		return
 	}
 
-	remaining := time.Until(session.ExpiresAt)
-	if remaining < 0 {
-		remaining = 0
-	}
+	remaining := session.TimeUntilExpiry()
 
 	c.HTML(http.StatusOK, "session.html", gin.H{
 		"title":         "Session - AirFi",
 		"remainingTime": formatDuration(remaining),
 		"session": gin.H{
 			"ID":         session.ID,
-			"ChannelID":  fmt.Sprintf("%x", session.Channel.ID())[:16] + "...",
-			"BalanceCKB": fmt.Sprintf("%.0f", float64(session.FundingAmount.Int64()-session.TotalPaid.Int64())/100000000),
-			"SpentCKB":   fmt.Sprintf("%.0f", float64(session.TotalPaid.Int64())/100000000),
-			"FundingCKB": fmt.Sprintf("%.0f", float64(session.FundingAmount.Int64())/100000000),
+			"ChannelID":  session.ChannelID.Short(),
+			"BalanceCKB": fmt.Sprintf("%.0f", session.RemainingBalanceCKB()),
+			"SpentCKB":   fmt.Sprintf("%.0f", session.TotalPaidCKB()),
+			"FundingCKB": fmt.Sprintf("%.0f", session.FundingCKB()),
 			"Status":     "active",
 		},
 	})
 }
 
+// sessionRefundView returns the refund shown on the session page, or nil
+// until a refund has been attempted.
+func (s *Server) sessionRefundView(sessionID string) gin.H {
+	wallet, err := s.db.GetWalletBySessionID(sessionID)
+	if err != nil {
+		return nil
+	}
+	status := walletRefundStatus(wallet)
+	if status == db.RefundStatusPending {
+		return nil
+	}
+	view := gin.H{
+		"Status":    status,
+		"AmountCKB": session.FormatCKB(big.NewInt(wallet.RefundAmount)),
+	}
+	if wallet.RefundTxHash != "" {
+		view["TxHash"] = wallet.RefundTxHash
+		view["TxURL"] = explorerTxURL(s.network, wallet.RefundTxHash)
+	}
+	return view
+}
+
 // handleDashboard serves the host dashboard.
 func (s *Server) handleDashboard(c *gin.Context) {
-	authCookie, err := c.Cookie("airfi_host_auth")
-	if err != nil || authCookie != s.dashboardPassword {
+	if !s.isDashboardAuthorized(c, auth.APIKeyScopeRead) {
 		c.Redirect(http.StatusFound, "/dashboard/login")
 		return
 	}
+	if !s.allowAPIKey(c) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+		return
+	}
 
 	c.HTML(http.StatusOK, "dashboard.html", gin.H{
 		"title": "Host Dashboard - AirFi",
@@ -120,7 +143,8 @@ func (s *Server) handleDashboard(c *gin.Context) {
 // handleDashboardLogin serves the dashboard login page.
 func (s *Server) handleDashboardLogin(c *gin.Context) {
 	c.HTML(http.StatusOK, "dashboard_login.html", gin.H{
-		"title": "Login - Host Dashboard",
+		"title":         "Login - Host Dashboard",
+		"totp_required": s.totpSecret() != "",
 	})
 }
 
@@ -128,34 +152,50 @@ func (s *Server) handleDashboardLogin(c *gin.Context) {
 func (s *Server) handleDashboardLoginPost(c *gin.Context) {
 	password := c.PostForm("password")
 
-	if password == s.dashboardPassword {
-		c.SetCookie("airfi_host_auth", password, 86400, "/", "", false, true)
-		c.Redirect(http.StatusFound, "/dashboard")
+	totpSecret := s.totpSecret()
+	if !s.checkDashboardPassword(password) {
+		c.HTML(http.StatusOK, "dashboard_login.html", gin.H{
+			"title":         "Login - Host Dashboard",
+			"error":         "Invalid password",
+			"totp_required": totpSecret != "",
+		})
 		return
 	}
 
-	c.HTML(http.StatusOK, "dashboard_login.html", gin.H{
-		"title": "Login - Host Dashboard",
-		"error": "Invalid password",
-	})
+	// With 2FA enabled a TOTP code or an unused backup code is also required
+	if totpSecret != "" {
+		ok, isBackupCode := s.totp.ValidateOrBackupCode(totpSecret, c.PostForm("totp_code"))
+		if !ok {
+			c.HTML(http.StatusOK, "dashboard_login.html", gin.H{
+				"title":         "Login - Host Dashboard",
+				"error":         "Invalid authentication code",
+				"totp_required": true,
+			})
+			return
+		}
+		if isBackupCode {
+			s.audit(s.requestActor(c), auditBackupCodeUsed, "", "", "dashboard login")
+		}
+	}
+
+	token, err := s.dashboardSessions.create()
+	if err != nil {
+		c.HTML(http.StatusInternalServerError, "dashboard_login.html", gin.H{
+			"title":         "Login - Host Dashboard",
+			"error":         "Login failed, please try again",
+			"totp_required": totpSecret != "",
+		})
+		return
+	}
+	c.SetCookie(dashboardCookie, token, int(dashboardSessionTTL/time.Second), "/", "", false, true)
+	c.Redirect(http.StatusFound, "/dashboard")
 }
 
 // handleDashboardLogout handles dashboard logout.
 func (s *Server) handleDashboardLogout(c *gin.Context) {
-	c.SetCookie("airfi_host_auth", "", -1, "/", "", false, true)
+	if token, err := c.Cookie(dashboardCookie); err == nil {
+		s.dashboardSessions.revoke(token)
+	}
+	c.SetCookie(dashboardCookie, "", -1, "/", "", false, true)
 	c.Redirect(http.StatusFound, "/dashboard/login")
 }
-
-// handleHealth returns server health status.
-func (s *Server) handleHealth(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
-	defer cancel()
-	_, err := s.hostClient.GetBalance(ctx)
-	connected := err == nil
-
-	c.JSON(http.StatusOK, gin.H{
-		"status":    "healthy",
-		"timestamp": time.Now().UTC().Format(time.RFC3339),
-		"connected": connected,
-	})
-}
@@ -0,0 +1,617 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/airfi/airfi-perun-nervous/internal/auth"
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
+)
+
+// isDashboardAuthorized checks the dashboard session cookie or a Bearer
+// API key granting scope. The cookie grants every scope. It does not apply
+// the API key rate limit; see authorizeDashboard.
+func (s *Server) isDashboardAuthorized(c *gin.Context, scope string) bool {
+	if s.hasDashboardSession(c) {
+		return true
+	}
+
+	token := bearerToken(c)
+	if token == "" {
+		return false
+	}
+
+	apiKey, err := s.apiKeys.Validate(token)
+	if err != nil {
+		if !errors.Is(err, auth.ErrInvalidAPIKey) {
+			s.logger.Error("failed to validate API key", zap.Error(err))
+		}
+		return false
+	}
+	if !apiKey.HasScope(scope) {
+		c.Set("api_key_missing_scope", scope)
+		return false
+	}
+	c.Set("api_key_id", apiKey.ID)
+	return true
+}
+
+// hasDashboardSession reports whether the request carries the cookie of a
+// logged-in dashboard session.
+func (s *Server) hasDashboardSession(c *gin.Context) bool {
+	token, err := c.Cookie(dashboardCookie)
+	return err == nil && s.dashboardSessions.valid(token)
+}
+
+// allowAPIKey reports whether a request authorized by API key is within the
+// key's rate limit, counting it. Requests without a key are always allowed.
+func (s *Server) allowAPIKey(c *gin.Context) bool {
+	keyID := c.GetString("api_key_id")
+	return keyID == "" || s.apiKeys.Allow(keyID)
+}
+
+// authorizeDashboard checks dashboard credentials for scope and the API key
+// rate limit. On failure the response is written and false returned.
+func (s *Server) authorizeDashboard(c *gin.Context, scope string) bool {
+	if !s.isDashboardAuthorized(c, scope) {
+		if missing := c.GetString("api_key_missing_scope"); missing != "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API key lacks the " + missing + " scope"})
+			return false
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return false
+	}
+	if !s.allowAPIKey(c) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+		return false
+	}
+	return true
+}
+
+// dashboardAuthMiddleware rejects requests without dashboard credentials
+// granting scope.
+func (s *Server) dashboardAuthMiddleware(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !s.authorizeDashboard(c, scope) {
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// handleCreateAPIKey generates a new API key with the read or admin scope,
+// admin when none is given. The plaintext key is only returned here.
+func (s *Server) handleCreateAPIKey(c *gin.Context) {
+	var req struct {
+		Label  string   `json:"label" binding:"required"`
+		Scopes []string `json:"scopes"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "label is required"})
+		return
+	}
+
+	plaintext, key, err := s.apiKeys.Generate(req.Label, req.Scopes)
+	if errors.Is(err, auth.ErrInvalidScope) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		s.logger.Error("failed to create API key", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create API key"})
+		return
+	}
+
+	s.logger.Info("API key created", zap.String("key_id", key.ID), zap.String("label", key.Label))
+	c.JSON(http.StatusCreated, gin.H{
+		"id":         key.ID,
+		"key":        plaintext,
+		"label":      key.Label,
+		"scopes":     key.Scopes,
+		"created_at": key.CreatedAt.Format(time.RFC3339),
+		"message":    "Store this key now, it will not be shown again",
+	})
+}
+
+// handleListAPIKeys lists API keys without their secrets.
+func (s *Server) handleListAPIKeys(c *gin.Context) {
+	keys, err := s.db.ListAPIKeys()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list API keys"})
+		return
+	}
+
+	result := make([]gin.H, 0, len(keys))
+	for _, key := range keys {
+		lastUsed := ""
+		if key.LastUsedAt != nil {
+			lastUsed = key.LastUsedAt.Format(time.RFC3339)
+		}
+		result = append(result, gin.H{
+			"id":           key.ID,
+			"label":        key.Label,
+			"scopes":       key.Scopes,
+			"created_at":   key.CreatedAt.Format(time.RFC3339),
+			"last_used_at": lastUsed,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"keys":  result,
+		"count": len(result),
+	})
+}
+
+// handleRevokeAPIKey deletes an API key.
+func (s *Server) handleRevokeAPIKey(c *gin.Context) {
+	id := c.Param("id")
+	if err := s.db.DeleteAPIKey(id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke API key"})
+		return
+	}
+
+	s.logger.Info("API key revoked", zap.String("key_id", id))
+	c.JSON(http.StatusOK, gin.H{
+		"id":      id,
+		"message": "API key revoked",
+	})
+}
+
+// defaultJWTKeyOverlap is how long tokens signed by the previous key stay
+// valid after a rotation. It covers the longest session token.
+const defaultJWTKeyOverlap = 24 * time.Hour
+
+// handleRotateJWTKey replaces the JWT signing key. Tokens signed by the old
+// key are accepted until the overlap ends, after which the key is dropped.
+func (s *Server) handleRotateJWTKey(c *gin.Context) {
+	var req struct {
+		OverlapSeconds int `json:"overlap_seconds"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.OverlapSeconds < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "overlap_seconds must not be negative"})
+		return
+	}
+	overlap := defaultJWTKeyOverlap
+	if req.OverlapSeconds > 0 {
+		overlap = time.Duration(req.OverlapSeconds) * time.Second
+	}
+
+	keyPair, err := auth.GenerateKeyPair()
+	if err != nil {
+		s.logger.Error("failed to generate JWT key", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate key"})
+		return
+	}
+
+	s.jwtMu.Lock()
+	rotated, err := s.jwtService.RotateKey(keyPair.PrivateKey, overlap)
+	if err != nil {
+		s.jwtMu.Unlock()
+		s.logger.Error("failed to rotate JWT key", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to rotate key"})
+		return
+	}
+	// Persist first so a restart keeps signing with the new key
+	if privPath, pubPath := s.jwtKeyPaths[0], s.jwtKeyPaths[1]; privPath != "" && pubPath != "" {
+		if err := keyPair.SaveKeys(privPath, pubPath); err != nil {
+			s.jwtMu.Unlock()
+			s.logger.Error("failed to save rotated JWT key", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save key"})
+			return
+		}
+	}
+	s.jwtService = rotated
+	s.jwtMu.Unlock()
+
+	time.AfterFunc(overlap, rotated.DeprecateOldKey)
+
+	expiresAt := time.Now().Add(overlap)
+	s.logger.Info("JWT signing key rotated", zap.Time("old_key_expires_at", expiresAt))
+	c.JSON(http.StatusOK, gin.H{
+		"rotated":            true,
+		"overlap_seconds":    int64(overlap.Seconds()),
+		"old_key_expires_at": expiresAt.Format(time.RFC3339),
+	})
+}
+
+// minDashboardPasswordLength is the shortest accepted dashboard password.
+const minDashboardPasswordLength = 8
+
+// handleUpdateDashboardPassword changes the dashboard password.
+// Existing dashboard sessions end once the password changes.
+func (s *Server) handleUpdateDashboardPassword(c *gin.Context) {
+	var req struct {
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "password is required"})
+		return
+	}
+
+	if len(req.Password) < minDashboardPasswordLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "password must be at least 8 characters"})
+		return
+	}
+
+	hash, err := hashDashboardPassword(req.Password)
+	if err != nil {
+		s.logger.Error("failed to hash dashboard password", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update password"})
+		return
+	}
+	if err := s.db.SetSetting(settingDashboardPassword, string(hash)); err != nil {
+		s.logger.Error("failed to store dashboard password", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update password"})
+		return
+	}
+
+	s.setDashboardPasswordHash(hash)
+	s.dashboardSessions.revokeAll()
+
+	s.logger.Info("dashboard password changed")
+	c.JSON(http.StatusOK, gin.H{"message": "Password updated successfully"})
+}
+
+// handleListRefundableWallets lists wallets still holding CKB that were
+// never withdrawn, for manual recovery. min_ckb defaults to one cell's
+// minimum capacity, below which a wallet can't be emptied.
+func (s *Server) handleListRefundableWallets(c *gin.Context) {
+	minCKB, err := queryInt(c, "min_ckb", perun.MinCellCapacity/100000000)
+	if err != nil || minCKB < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid min_ckb"})
+		return
+	}
+
+	wallets, err := s.db.ListWalletsWithBalanceAbove(int64(minCKB))
+	if err != nil {
+		s.logger.Error("failed to list refundable wallets", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list wallets"})
+		return
+	}
+
+	result := make([]gin.H, 0, len(wallets))
+	var totalCKB int64
+	for _, w := range wallets {
+		totalCKB += w.BalanceCKB
+		result = append(result, gin.H{
+			"wallet_id":      w.ID,
+			"address":        w.Address,
+			"balance_ckb":    w.BalanceCKB,
+			"sender_address": w.SenderAddress,
+			"session_id":     w.SessionID,
+			"status":         w.Status,
+			"created_at":     w.CreatedAt.Format(time.RFC3339),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"wallets":   result,
+		"count":     len(result),
+		"total_ckb": totalCKB,
+		"min_ckb":   minCKB,
+	})
+}
+
+// handleListExpiredWallets lists wallets that expired unfunded, optionally
+// limited to those whose expiry falls between from and to.
+func (s *Server) handleListExpiredWallets(c *gin.Context) {
+	var req struct {
+		From time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+		To   time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid query: " + err.Error()})
+		return
+	}
+
+	wallets, err := s.db.ListExpiredWallets(req.From, req.To)
+	if err != nil {
+		s.logger.Error("failed to list expired wallets", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list wallets"})
+		return
+	}
+
+	result := make([]gin.H, 0, len(wallets))
+	for _, w := range wallets {
+		result = append(result, gin.H{
+			"wallet_id":   w.ID,
+			"address":     w.Address,
+			"mac_address": w.MACAddress,
+			"status":      w.Status,
+			"created_at":  w.CreatedAt.Format(time.RFC3339),
+			"expires_at":  w.ExpiresAt.Format(time.RFC3339),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"wallets": result,
+		"count":   len(result),
+	})
+}
+
+// handleSearchWallets searches guest wallets by multiple optional criteria.
+// status takes a comma-separated list and matches any of them.
+func (s *Server) handleSearchWallets(c *gin.Context) {
+	var req struct {
+		Status        string    `form:"status"`
+		MACAddress    string    `form:"mac"`
+		MinBalance    int64     `form:"min_balance"`
+		MaxBalance    int64     `form:"max_balance"`
+		CreatedAfter  time.Time `form:"created_after" time_format:"2006-01-02T15:04:05Z07:00"`
+		CreatedBefore time.Time `form:"created_before" time_format:"2006-01-02T15:04:05Z07:00"`
+		Limit         int       `form:"limit"`
+		Offset        int       `form:"offset"`
+	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid query: " + err.Error()})
+		return
+	}
+
+	if req.Limit <= 0 || req.Limit > 500 {
+		req.Limit = 100
+	}
+
+	var statuses []string
+	for _, status := range strings.Split(req.Status, ",") {
+		if status = strings.TrimSpace(status); status != "" {
+			statuses = append(statuses, status)
+		}
+	}
+
+	wallets, err := s.db.SearchGuestWallets(&db.WalletQuery{
+		Statuses:      statuses,
+		MACAddress:    req.MACAddress,
+		BalanceCKBMin: req.MinBalance,
+		BalanceCKBMax: req.MaxBalance,
+		CreatedAfter:  req.CreatedAfter,
+		CreatedBefore: req.CreatedBefore,
+		Limit:         req.Limit,
+		Offset:        req.Offset,
+	})
+	if err != nil {
+		s.logger.Error("failed to search wallets", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search wallets"})
+		return
+	}
+
+	result := make([]gin.H, 0, len(wallets))
+	for _, w := range wallets {
+		result = append(result, gin.H{
+			"wallet_id":      w.ID,
+			"address":        w.Address,
+			"balance_ckb":    w.BalanceCKB,
+			"sender_address": w.SenderAddress,
+			"session_id":     w.SessionID,
+			"status":         w.Status,
+			"mac_address":    w.MACAddress,
+			"ip_address":     w.IPAddress,
+			"created_at":     w.CreatedAt.Format(time.RFC3339),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"wallets": result,
+		"count":   len(result),
+		"limit":   req.Limit,
+		"offset":  req.Offset,
+	})
+}
+
+// settleAllConcurrency bounds how many channels settle-all closes at once.
+const settleAllConcurrency = 5
+
+// handleSettleAllSessions ends every active session and settles their
+// channels in the background, answering 202 with the number of sessions.
+// Only one settle-all may run at a time.
+func (s *Server) handleSettleAllSessions(c *gin.Context) {
+	if !s.settleAllMu.TryLock() {
+		c.JSON(http.StatusConflict, gin.H{"error": "settle-all already in progress"})
+		return
+	}
+
+	s.sessionsMu.RLock()
+	sessionIDs := make([]string, 0, len(s.sessions))
+	for id := range s.sessions {
+		sessionIDs = append(sessionIDs, id)
+	}
+	s.sessionsMu.RUnlock()
+
+	s.logger.Info("settling all active sessions", zap.Int("count", len(sessionIDs)))
+	actor := s.requestActor(c)
+	go func() {
+		defer s.settleAllMu.Unlock()
+		s.settleAllSessions(s.runCtx, sessionIDs, actor)
+	}()
+
+	c.JSON(http.StatusAccepted, gin.H{"sessions": len(sessionIDs)})
+}
+
+// settleAllSessions detaches and settles sessionIDs, at most
+// settleAllConcurrency at a time, and logs the outcome. Sessions already
+// ended are skipped.
+func (s *Server) settleAllSessions(ctx context.Context, sessionIDs []string, actor auditActor) {
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		settled int
+		failed  int
+	)
+	slots := make(chan struct{}, settleAllConcurrency)
+	for _, id := range sessionIDs {
+		if ctx.Err() != nil {
+			break
+		}
+		session, ok := s.detachSession(ctx, id, actor)
+		if !ok {
+			// Ended by the guest or expiry since the snapshot
+			continue
+		}
+
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			err := s.settleSession(session)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				s.logger.Error("settle-all failed to settle session", zap.String("session_id", session.ID), zap.Error(err))
+				failed++
+				return
+			}
+			settled++
+		}()
+	}
+	wg.Wait()
+
+	s.logger.Info("settle-all finished", zap.Int("settled", settled), zap.Int("failed", failed))
+}
+
+// handleUpdateSessionExpiry sets an active session's expiry directly, so
+// operators can grant or take back time without a payment.
+func (s *Server) handleUpdateSessionExpiry(c *gin.Context) {
+	sessionID := c.Param("sessionId")
+
+	var req struct {
+		ExpiresAt time.Time `json:"expires_at" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be an RFC3339 time"})
+		return
+	}
+
+	now := time.Now()
+	if req.ExpiresAt.Before(now.Add(time.Minute)) || req.ExpiresAt.After(now.Add(s.maxSessionTime)) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("expires_at must be between 1m and %s from now", s.maxSessionTime),
+		})
+		return
+	}
+
+	dbSession, err := s.db.GetSession(sessionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return
+	}
+	if dbSession.Status != "active" {
+		c.JSON(http.StatusConflict, gin.H{"error": "session is " + dbSession.Status})
+		return
+	}
+
+	if err := s.db.UpdateSessionExpiry(sessionID, req.ExpiresAt); err != nil {
+		s.logger.Error("failed to update session expiry", zap.String("session_id", sessionID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update session expiry"})
+		return
+	}
+
+	s.sessionsMu.Lock()
+	if gs, ok := s.sessions[sessionID]; ok {
+		s.storeSessionExtended(sessionID, req.ExpiresAt.Sub(gs.ExpiresAt))
+		gs.ExpiresAt = req.ExpiresAt
+	}
+	s.sessionsMu.Unlock()
+	s.liftExpiryThrottle(c.Request.Context(), sessionID)
+
+	previous := dbSession.ExpiresAt
+	s.logger.Info("session expiry changed",
+		zap.String("session_id", sessionID),
+		zap.Time("previous", previous),
+		zap.Time("expires_at", req.ExpiresAt),
+	)
+	s.audit(s.requestActor(c), auditSessionExpiryChanged, sessionID, dbSession.WalletID,
+		fmt.Sprintf("previous=%s new=%s", previous.Format(time.RFC3339), req.ExpiresAt.Format(time.RFC3339)))
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id":      sessionID,
+		"previous_expiry": previous.Format(time.RFC3339),
+		"expires_at":      req.ExpiresAt.Format(time.RFC3339),
+		"remaining_time":  formatDuration(time.Until(req.ExpiresAt)),
+	})
+}
+
+// handleTransferSession moves an active session to another guest wallet's
+// device, e.g. when a guest switches from their phone to a laptop. The old
+// device loses access and the new one gets it. The target wallet must be
+// unused and carry the new device's MAC address.
+func (s *Server) handleTransferSession(c *gin.Context) {
+	sessionID := c.Param("sessionId")
+
+	var req struct {
+		ToWalletID string `json:"to_wallet_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to_wallet_id is required"})
+		return
+	}
+
+	dbSession, toWallet, err := s.db.TransferSession(sessionID, req.ToWalletID)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session or wallet not found"})
+		return
+	}
+	if errors.Is(err, db.ErrTransferRejected) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		s.logger.Error("failed to transfer session", zap.String("session_id", sessionID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to transfer session"})
+		return
+	}
+	fromWalletID := dbSession.WalletID
+
+	ctx := c.Request.Context()
+	actor := s.requestActor(c)
+	if dbSession.MACAddress != "" {
+		if err := s.router.DeauthorizeMAC(ctx, dbSession.MACAddress); err != nil {
+			s.logger.Error("failed to deauthorize MAC", zap.Error(err), zap.String("mac", dbSession.MACAddress))
+		} else {
+			s.audit(actor, auditMACDeauthorized, sessionID, fromWalletID, "mac="+dbSession.MACAddress)
+		}
+	}
+	comment := fmt.Sprintf("AirFi session (transferred): %s", sessionID)
+	if err := s.router.AuthorizeMAC(ctx, toWallet.MACAddress, toWallet.IPAddress, comment, toWallet.AccessPoint); err != nil {
+		s.logger.Error("failed to authorize MAC", zap.Error(err), zap.String("mac", toWallet.MACAddress))
+	} else {
+		s.audit(actor, auditMACAuthorized, sessionID, toWallet.ID, "mac="+toWallet.MACAddress)
+	}
+
+	s.logger.Info("session transferred",
+		zap.String("session_id", sessionID),
+		zap.String("from_wallet_id", fromWalletID),
+		zap.String("to_wallet_id", toWallet.ID),
+	)
+	s.audit(actor, auditSessionTransferred, sessionID, toWallet.ID,
+		fmt.Sprintf("from_wallet=%s to_wallet=%s", fromWalletID, toWallet.ID))
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id":     sessionID,
+		"from_wallet_id": fromWalletID,
+		"to_wallet_id":   toWallet.ID,
+		"mac_address":    toWallet.MACAddress,
+		"status":         "transferred",
+	})
+}
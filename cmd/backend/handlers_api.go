@@ -2,24 +2,33 @@ package main
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"math"
 	"math/big"
 	"net/http"
 	"time"
 
 	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 	"github.com/gin-gonic/gin"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
 	"go.uber.org/zap"
 
+	gpclient "perun.network/go-perun/client"
+
+	"github.com/airfi/airfi-perun-nervous/internal/auth"
+	"github.com/airfi/airfi-perun-nervous/internal/db"
 	"github.com/airfi/airfi-perun-nervous/internal/guest"
 	"github.com/airfi/airfi-perun-nervous/internal/perun"
+	"github.com/airfi/airfi-perun-nervous/internal/session"
 )
 
 // handleWalletStatus returns the host wallet status.
 func (s *Server) handleWalletStatus(c *gin.Context) {
 	balance, err := s.hostClient.GetBalance(c.Request.Context())
-	balanceCKB := float64(balance.Int64()) / 100000000
+	balanceCKB := session.ShannonsToCKB(balance)
 
 	c.JSON(http.StatusOK, gin.H{
 		"address":     s.hostClient.GetAddress(),
@@ -32,15 +41,15 @@ func (s *Server) handleWalletStatus(c *gin.Context) {
 // handleListSessions returns all sessions.
 func (s *Server) handleListSessions(c *gin.Context) {
 	type sessionInfo struct {
-		SessionID     string `json:"session_id"`
-		GuestAddress  string `json:"guest_address"`
-		BalanceCKB    int64  `json:"balance_ckb"`
-		FundingCKB    int64  `json:"funding_ckb"`
-		SpentCKB      int64  `json:"spent_ckb"`
-		RemainingTime string `json:"remaining_time"`
-		Status        string `json:"status"`
-		ChannelID     string `json:"channel_id"`
-		CreatedAt     string `json:"created_at"`
+		SessionID     string          `json:"session_id"`
+		GuestAddress  string          `json:"guest_address"`
+		BalanceCKB    int64           `json:"balance_ckb"`
+		FundingCKB    int64           `json:"funding_ckb"`
+		SpentCKB      int64           `json:"spent_ckb"`
+		RemainingTime string          `json:"remaining_time"`
+		Status        string          `json:"status"`
+		ChannelID     perun.ChannelID `json:"channel_id"`
+		CreatedAt     string          `json:"created_at"`
 	}
 
 	sessions := make([]sessionInfo, 0)
@@ -55,10 +64,7 @@ func (s *Server) handleListSessions(c *gin.Context) {
 			if status == "channel_opening" {
 				remainingTimeStr = "-"
 			} else {
-				remaining := time.Until(session.ExpiresAt)
-				if remaining < 0 {
-					remaining = 0
-				}
+				remaining := session.TimeUntilExpiry()
 				if remaining <= 0 && status == "active" {
 					status = "expired"
 				}
@@ -92,18 +98,13 @@ func (s *Server) handleListSessions(c *gin.Context) {
 			continue
 		}
 
-		remaining := time.Until(session.ExpiresAt)
-		if remaining < 0 {
-			remaining = 0
-		}
+		remaining := session.TimeUntilExpiry()
 		status := "active"
 		if remaining <= 0 {
 			status = "expired"
 		}
 
-		fundingCKB := session.FundingAmount.Int64() / 100000000
-		spentCKB := session.TotalPaid.Int64() / 100000000
-		balanceCKB := fundingCKB - spentCKB
+		fundingCKB, spentCKB, balanceCKB := session.wholeCKB()
 
 		sessions = append(sessions, sessionInfo{
 			SessionID:     session.ID,
@@ -113,7 +114,7 @@ func (s *Server) handleListSessions(c *gin.Context) {
 			SpentCKB:      spentCKB,
 			RemainingTime: formatDuration(remaining),
 			Status:        status,
-			ChannelID:     fmt.Sprintf("%x", session.Channel.ID())[:16],
+			ChannelID:     session.ChannelID,
 			CreatedAt:     session.CreatedAt.Format(time.RFC3339),
 		})
 	}
@@ -125,10 +126,89 @@ func (s *Server) handleListSessions(c *gin.Context) {
 	})
 }
 
+// handleSearchSessions searches sessions by multiple optional criteria. It
+// is host-only: results include guest addresses and channel IDs.
+func (s *Server) handleSearchSessions(c *gin.Context) {
+	var req struct {
+		Status             string    `form:"status"`
+		GuestAddressPrefix string    `form:"guest_address_prefix"`
+		ChannelIDPrefix    string    `form:"channel_id_prefix"`
+		FundingCKBMin      int64     `form:"funding_ckb_min"`
+		FundingCKBMax      int64     `form:"funding_ckb_max"`
+		CreatedAfter       time.Time `form:"created_after" time_format:"2006-01-02T15:04:05Z07:00"`
+		CreatedBefore      time.Time `form:"created_before" time_format:"2006-01-02T15:04:05Z07:00"`
+		Disputed           *bool     `form:"disputed"`
+		SortBy             string    `form:"sort_by"`
+		SortDesc           bool      `form:"sort_desc"`
+		Limit              int       `form:"limit"`
+		Offset             int       `form:"offset"`
+	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid query: " + err.Error()})
+		return
+	}
+
+	if req.Limit <= 0 || req.Limit > 500 {
+		req.Limit = 100
+	}
+
+	dbSessions, err := s.db.SearchSessions(&db.SessionQuery{
+		Status:             req.Status,
+		GuestAddressPrefix: req.GuestAddressPrefix,
+		ChannelIDPrefix:    req.ChannelIDPrefix,
+		FundingCKBMin:      req.FundingCKBMin,
+		FundingCKBMax:      req.FundingCKBMax,
+		CreatedAfter:       req.CreatedAfter,
+		CreatedBefore:      req.CreatedBefore,
+		Disputed:           req.Disputed,
+		SortBy:             req.SortBy,
+		SortDesc:           req.SortDesc,
+		Limit:              req.Limit,
+		Offset:             req.Offset,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sessions := make([]gin.H, 0, len(dbSessions))
+	for _, session := range dbSessions {
+		remaining := session.TimeUntilExpiry()
+		sessions = append(sessions, gin.H{
+			"session_id":     session.ID,
+			"guest_address":  session.GuestAddress,
+			"balance_ckb":    session.BalanceCKB,
+			"funding_ckb":    session.FundingCKB,
+			"spent_ckb":      session.SpentCKB,
+			"remaining_time": formatDuration(remaining),
+			"status":         session.Status,
+			"channel_id":     session.ChannelID,
+			"created_at":     session.CreatedAt.Format(time.RFC3339),
+			"disputed":       session.DisputedAt != nil,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sessions": sessions,
+		"count":    len(sessions),
+		"limit":    req.Limit,
+		"offset":   req.Offset,
+	})
+}
+
 // handleGetSession returns a specific session.
 func (s *Server) handleGetSession(c *gin.Context) {
 	sessionID := c.Param("sessionId")
 
+	if !s.authorizeSessionScope(c, sessionID, auth.ScopeRead) {
+		return
+	}
+
+	s.sessionsMu.RLock()
+	session, exists := s.sessions[sessionID]
+	s.sessionsMu.RUnlock()
+	pendingCount, pendingShannons := pendingPayments(session)
+
 	// Check database
 	dbSession, err := s.db.GetSession(sessionID)
 	if err == nil {
@@ -138,10 +218,7 @@ func (s *Server) handleGetSession(c *gin.Context) {
 		if status == "channel_opening" {
 			remainingTimeStr = "-"
 		} else {
-			remaining := time.Until(dbSession.ExpiresAt)
-			if remaining < 0 {
-				remaining = 0
-			}
+			remaining := dbSession.TimeUntilExpiry()
 			if remaining <= 0 && status == "active" {
 				status = "expired"
 			}
@@ -149,54 +226,61 @@ func (s *Server) handleGetSession(c *gin.Context) {
 		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"session_id":     dbSession.ID,
-			"wallet_id":      dbSession.WalletID,
-			"channel_id":     dbSession.ChannelID,
-			"guest_address":  dbSession.GuestAddress,
-			"host_address":   dbSession.HostAddress,
-			"funding_ckb":    dbSession.FundingCKB,
-			"balance_ckb":    dbSession.BalanceCKB,
-			"spent_ckb":      dbSession.SpentCKB,
-			"remaining_time": remainingTimeStr,
-			"expires_at":     dbSession.ExpiresAt.Format(time.RFC3339),
-			"status":         status,
+			"session_id":         dbSession.ID,
+			"wallet_id":          dbSession.WalletID,
+			"channel_id":         dbSession.ChannelID,
+			"guest_address":      dbSession.GuestAddress,
+			"host_address":       dbSession.HostAddress,
+			"funding_ckb":        dbSession.FundingCKB,
+			"balance_ckb":        dbSession.BalanceCKB,
+			"spent_ckb":          dbSession.SpentCKB,
+			"earnings_ckb":       s.sessionEarnings(sessionID),
+			"remaining_time":     remainingTimeStr,
+			"expires_at":         dbSession.ExpiresAt.Format(time.RFC3339),
+			"status":             status,
+			"settle_tx_hash":     dbSession.SettleTxHash,
+			"bytes_in":           dbSession.BytesIn,
+			"bytes_out":          dbSession.BytesOut,
+			"disputed_at":        formatOptionalTime(dbSession.DisputedAt),
+			"dispute_tx_hash":    dbSession.DisputeTxHash,
+			"resolved_at":        formatOptionalTime(dbSession.ResolvedAt),
+			"resolution_tx_hash": dbSession.ResolutionTxHash,
+			"sender_address":     dbSession.SenderAddress,
+			"peer_status":        peerStatus(dbSession),
+
+			"pending_payments_count": pendingCount,
+			"pending_shannons":       pendingShannons.String(),
 		})
 		return
 	}
 
-	// Check in-memory sessions
-	s.sessionsMu.RLock()
-	session, exists := s.sessions[sessionID]
-	s.sessionsMu.RUnlock()
-
+	// Fall back to in-memory sessions
 	if !exists {
 		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
 		return
 	}
 
-	remaining := time.Until(session.ExpiresAt)
-	if remaining < 0 {
-		remaining = 0
-	}
+	remaining := session.TimeUntilExpiry()
 
 	status := "active"
 	if remaining <= 0 {
 		status = "expired"
 	}
 
-	fundingCKB := session.FundingAmount.Int64() / 100000000
-	spentCKB := session.TotalPaid.Int64() / 100000000
-	balanceCKB := fundingCKB - spentCKB
+	fundingCKB, spentCKB, balanceCKB := session.wholeCKB()
 
 	c.JSON(http.StatusOK, gin.H{
 		"session_id":     session.ID,
-		"channel_id":     fmt.Sprintf("%x", session.Channel.ID())[:16],
+		"channel_id":     session.ChannelID,
 		"guest_address":  session.GuestAddress,
 		"funding_ckb":    fundingCKB,
 		"balance_ckb":    balanceCKB,
 		"spent_ckb":      spentCKB,
 		"remaining_time": formatDuration(remaining),
 		"status":         status,
+
+		"pending_payments_count": pendingCount,
+		"pending_shannons":       pendingShannons.String(),
 	})
 }
 
@@ -204,6 +288,10 @@ func (s *Server) handleGetSession(c *gin.Context) {
 func (s *Server) handleExtendSession(c *gin.Context) {
 	sessionID := c.Param("sessionId")
 
+	if !s.authorizeSessionScope(c, sessionID, auth.ScopeExtend) {
+		return
+	}
+
 	var req struct {
 		Amount string `json:"amount" binding:"required"`
 	}
@@ -225,10 +313,42 @@ func (s *Server) handleExtendSession(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "session not found or channel not active"})
 		return
 	}
+	if session.Paused {
+		s.sessionsMu.Unlock()
+		c.JSON(http.StatusConflict, gin.H{"error": "session is paused; resume it before extending"})
+		return
+	}
 
 	amountShannons := new(big.Int).Mul(amountCKB, big.NewInt(100000000))
 
-	err := session.Client.SendPayment(session.Channel, amountShannons)
+	// Top up the channel when the guest's balance can't cover the extension;
+	// without top-up support they have to start a new session instead. The
+	// dry-run ledger has no top-ups, so a short balance fails the payment.
+	if s.dryRun == nil {
+		if shortfall := session.Client.Shortfall(session.Channel, amountShannons); shortfall.Sign() > 0 {
+			if err := session.Client.AdjustFunding(session.Channel, shortfall); err != nil {
+				s.sessionsMu.Unlock()
+				if errors.Is(err, perun.ErrTopUpUnsupported) {
+					c.JSON(http.StatusConflict, gin.H{
+						"error":                "channel balance too low to extend; end this session and open a new one",
+						"shortfall_shannons":   shortfall.String(),
+						"requires_new_channel": true,
+					})
+					return
+				}
+				s.logger.Error("channel top-up failed", zap.String("session_id", sessionID), zap.Error(err))
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to top up channel"})
+				return
+			}
+		}
+	}
+
+	var err error
+	if s.dryRun != nil {
+		err = s.dryRun.SendPayment(session.ChannelID, amountShannons)
+	} else {
+		err = session.Client.SendPayment(session.Channel, amountShannons)
+	}
 	if err != nil {
 		s.sessionsMu.Unlock()
 		s.logger.Error("extend payment failed", zap.Error(err))
@@ -240,12 +360,15 @@ func (s *Server) handleExtendSession(c *gin.Context) {
 	additionalMins := new(big.Int).Div(amountShannons, s.ratePerMin).Int64()
 	session.ExpiresAt = session.ExpiresAt.Add(time.Duration(additionalMins) * time.Minute)
 	s.sessionsMu.Unlock()
+	s.storeSessionExtended(sessionID, time.Duration(additionalMins)*time.Minute)
 
 	if err := s.db.ExtendSession(sessionID, additionalMins, amountCKB.Int64()); err != nil {
 		s.logger.Error("failed to update session in database", zap.Error(err))
 	}
+	s.recordPayment(sessionID, db.PaymentExtension, amountShannons)
+	s.liftExpiryThrottle(c.Request.Context(), sessionID)
 
-	remaining := time.Until(session.ExpiresAt)
+	remaining := session.TimeUntilExpiry()
 
 	s.logger.Info("session extended",
 		zap.String("session_id", sessionID),
@@ -266,38 +389,18 @@ func (s *Server) handleExtendSession(c *gin.Context) {
 func (s *Server) handleEndSession(c *gin.Context) {
 	sessionID := c.Param("sessionId")
 
-	s.sessionsMu.Lock()
-	session, exists := s.sessions[sessionID]
-	if !exists {
-		s.sessionsMu.Unlock()
-		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+	if !s.authorizeSessionScope(c, sessionID, auth.ScopeSettle) {
 		return
 	}
-	delete(s.sessions, sessionID)
-	s.sessionsMu.Unlock()
 
-	s.logger.Info("ending session - settlement will run in background",
-		zap.String("session_id", sessionID),
-	)
-
-	// Update status to settling
-	s.db.UpdateSessionStatus(sessionID, "settling")
-
-	// Deauthorize MAC immediately
-	dbSession, err := s.db.GetSession(sessionID)
-	if err == nil && dbSession.MACAddress != "" {
-		if err := s.router.DeauthorizeMAC(context.Background(), dbSession.MACAddress); err != nil {
-			s.logger.Error("failed to deauthorize MAC",
-				zap.Error(err),
-				zap.String("mac", dbSession.MACAddress),
-			)
-		} else {
-			s.logger.Info("MAC deauthorized", zap.String("mac", dbSession.MACAddress))
-		}
+	session, exists := s.detachSession(context.Background(), sessionID, s.requestActor(c))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return
 	}
 
 	// Run settlement in background
-	go s.settleSessionInBackground(session)
+	go s.settleSession(session)
 
 	c.JSON(http.StatusOK, gin.H{
 		"session_id": session.ID,
@@ -306,17 +409,80 @@ func (s *Server) handleEndSession(c *gin.Context) {
 	})
 }
 
-// handleManualRefund refunds remaining CKB to a specified address.
+// settleEstimateTimeout bounds building and pricing the close transaction.
+const settleEstimateTimeout = 15 * time.Second
+
+// handleEstimateSettlement reports what settling an active session's
+// channel would cost on-chain and whether its remaining balance covers it.
+func (s *Server) handleEstimateSettlement(c *gin.Context) {
+	sessionID := c.Param("sessionId")
+
+	if !s.authorizeSessionScope(c, sessionID, auth.ScopeRead) {
+		return
+	}
+
+	s.sessionsMu.RLock()
+	gs, exists := s.sessions[sessionID]
+	s.sessionsMu.RUnlock()
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found or not active"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), settleEstimateTimeout)
+	defer cancel()
+	fee, err := s.settlementFee(ctx, gs)
+	if err != nil {
+		s.logger.Error("failed to estimate settlement fee", zap.String("session_id", sessionID), zap.Error(err))
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to estimate settlement fee"})
+		return
+	}
+
+	feeShannons := new(big.Int).SetUint64(fee)
+	balance := gs.remainingShannons()
+	c.JSON(http.StatusOK, gin.H{
+		"session_id":             sessionID,
+		"estimated_fee_shannons": fee,
+		"estimated_fee_ckb":      session.ShannonsToCKB(feeShannons),
+		"balance_ckb":            session.ShannonsToCKB(balance),
+		"can_afford":             balance.Cmp(feeShannons) >= 0,
+	})
+}
+
+// maxRefundCKB is the largest amount_ckb whose shannons fit in an int64.
+const maxRefundCKB = math.MaxInt64 / 100000000
+
+// handleManualRefund refunds remaining CKB to a specified address, or to
+// the session's detected sender when to_address is omitted. With
+// amount_ckb set, only that much is refunded and the rest stays in the
+// wallet. Nothing is sent in dry-run mode.
 func (s *Server) handleManualRefund(c *gin.Context) {
 	sessionID := c.Param("sessionId")
 
+	if s.dryRun != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "refunds are not sent in dry-run mode"})
+		return
+	}
+
 	var req struct {
-		ToAddress string `json:"to_address" binding:"required"`
+		ToAddress string `json:"to_address"`
+		AmountCKB int64  `json:"amount_ckb"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	partial := req.AmountCKB != 0
+	if req.AmountCKB < 0 || partial && uint64(req.AmountCKB)*100000000 < perun.MinCellCapacity {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("amount_ckb must be at least %d", perun.MinCellCapacity/100000000),
+		})
+		return
+	}
+	if req.AmountCKB > maxRefundCKB {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("amount_ckb must be at most %d", maxRefundCKB)})
+		return
+	}
 
 	wallet, err := s.db.GetWalletBySessionID(sessionID)
 	if err != nil {
@@ -329,6 +495,19 @@ func (s *Server) handleManualRefund(c *gin.Context) {
 		return
 	}
 
+	if req.ToAddress == "" {
+		if dbSession, err := s.db.GetSession(sessionID); err == nil {
+			req.ToAddress = dbSession.SenderAddress
+		}
+		if req.ToAddress == "" {
+			req.ToAddress = wallet.SenderAddress
+		}
+		if req.ToAddress == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to_address is required: no sender address detected for this session"})
+			return
+		}
+	}
+
 	s.logger.Info("manual refund requested",
 		zap.String("session_id", sessionID),
 		zap.String("to_address", req.ToAddress),
@@ -347,8 +526,19 @@ func (s *Server) handleManualRefund(c *gin.Context) {
 		return
 	}
 
-	withdrawer := perun.NewWithdrawer(s.ckbClient, s.logger.Named("withdrawer"))
-	txHash, err := withdrawer.WithdrawAll(c.Request.Context(), guestPrivKey, guestLockScript, req.ToAddress)
+	var txHash types.Hash
+	if partial {
+		txHash, err = s.withdrawer.WithdrawPartial(c.Request.Context(), guestPrivKey, guestLockScript, req.ToAddress, uint64(req.AmountCKB)*100000000)
+	} else {
+		txHash, err = s.withdrawer.WithdrawAll(c.Request.Context(), guestPrivKey, guestLockScript, req.ToAddress)
+	}
+	if errors.Is(err, perun.ErrInsufficientBalance) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "wallet balance too low for this refund",
+			"details": err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		s.logger.Error("manual refund failed", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -358,34 +548,215 @@ func (s *Server) handleManualRefund(c *gin.Context) {
 		return
 	}
 
-	s.db.UpdateWalletStatus(wallet.ID, "withdrawn")
+	status := "refunded"
+	amountCKB := wallet.BalanceCKB
+	if partial {
+		status = "partially_refunded"
+		amountCKB = req.AmountCKB
+		s.storeRefund(wallet.ID, db.RefundStatusPartial, txHash.Hex(), req.AmountCKB*100000000)
+	} else {
+		s.db.UpdateWalletStatus(wallet.ID, "withdrawn")
+		s.recordRefund(wallet.ID, db.RefundStatusSent, txHash)
+	}
 
-	s.logger.Info("manual refund successful", zap.String("tx_hash", txHash.Hex()))
+	s.logger.Info("manual refund successful", zap.String("tx_hash", txHash.Hex()), zap.Bool("partial", partial))
+	details := fmt.Sprintf("tx_hash=%s to_address=%s", txHash.Hex(), req.ToAddress)
+	if partial {
+		details += fmt.Sprintf(" amount_ckb=%d", req.AmountCKB)
+	}
+	s.audit(s.requestActor(c), auditRefund, sessionID, wallet.ID, details)
 
 	c.JSON(http.StatusOK, gin.H{
 		"session_id": sessionID,
 		"tx_hash":    txHash.Hex(),
 		"to_address": req.ToAddress,
-		"status":     "refunded",
+		"amount_ckb": amountCKB,
+		"status":     status,
+	})
+}
+
+// refundPendingStatuses are session states whose channel still holds the
+// guest's funds, so the wallet can't be emptied yet.
+var refundPendingStatuses = map[string]bool{
+	"channel_opening": true,
+	"active":          true,
+	"settling":        true,
+}
+
+// handleGetRefundStatus reports from the database whether a session's
+// wallet can be refunded, where the refund goes and how far it has got.
+func (s *Server) handleGetRefundStatus(c *gin.Context) {
+	sessionID := c.Param("sessionId")
+
+	if !s.authorizeSessionScope(c, sessionID, auth.ScopeRead) {
+		return
+	}
+
+	dbSession, err := s.db.GetSession(sessionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return
+	}
+	wallet, err := s.db.GetWalletBySessionID(sessionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "wallet not found for session"})
+		return
+	}
+
+	senderAddress := dbSession.SenderAddress
+	if senderAddress == "" {
+		senderAddress = wallet.SenderAddress
+	}
+
+	// Less than one cell's capacity can't be moved out of the wallet
+	eligible := wallet.Status != "withdrawn" && wallet.BalanceCKB >= perun.MinCellCapacity/100000000
+	canWithdraw := eligible && senderAddress != "" && !refundPendingStatuses[dbSession.Status]
+
+	var feeShannons uint64
+	if s.withdrawer != nil {
+		feeShannons = s.withdrawer.Fee()
+	}
+
+	refundStatus := walletRefundStatus(wallet)
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id":        sessionID,
+		"status":            dbSession.Status,
+		"eligible":          eligible,
+		"sender_address":    senderAddress,
+		"estimated_ckb":     wallet.BalanceCKB,
+		"estimated_fee_ckb": session.ShannonsToCKB(new(big.Int).SetUint64(feeShannons)),
+		"can_withdraw":      canWithdraw,
+		"refund_status":     refundStatus,
+		"refund_tx_hash":    wallet.RefundTxHash,
+		"refund_amount_ckb": session.ShannonsToCKB(big.NewInt(wallet.RefundAmount)),
+		"estimated_arrival": refundEstimatedArrival(wallet, refundStatus),
 	})
 }
 
-// handleGetSessionToken returns the JWT token for a session.
+// refundTxTimeout bounds looking up a refund transaction on-chain.
+const refundTxTimeout = 10 * time.Second
+
+// handleGetRefundTx reports the on-chain status of a session's refund
+// transaction: pending, proposed, committed, rejected or unknown.
+func (s *Server) handleGetRefundTx(c *gin.Context) {
+	sessionID := c.Param("sessionId")
+
+	if !s.authorizeSessionScope(c, sessionID, auth.ScopeRead) {
+		return
+	}
+
+	wallet, err := s.db.GetWalletBySessionID(sessionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "wallet not found for session"})
+		return
+	}
+	if wallet.RefundTxHash == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no refund transaction for session"})
+		return
+	}
+	if s.ckbClient == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "CKB client not available"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), refundTxTimeout)
+	defer cancel()
+	tx, err := s.ckbClient.GetTransaction(ctx, types.HexToHash(wallet.RefundTxHash))
+	if err != nil {
+		s.logger.Error("failed to look up refund transaction", zap.String("tx_hash", wallet.RefundTxHash), zap.Error(err))
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to look up refund transaction"})
+		return
+	}
+
+	txStatus := types.TransactionStatusUnknown
+	if tx != nil && tx.TxStatus != nil {
+		txStatus = tx.TxStatus.Status
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id": sessionID,
+		"tx_hash":    wallet.RefundTxHash,
+		"tx_status":  txStatus,
+	})
+}
+
+// refundArrivalEstimate is how long after a refund starts processing the
+// guest can expect it: the first attempt waits for the settlement to
+// confirm, then the withdrawal needs its own confirmations.
+const refundArrivalEstimate = 2 * time.Minute
+
+// explorerTxURL links a transaction on the explorer of network, which is
+// "mainnet" or, by default, "testnet".
+func explorerTxURL(network, txHash string) string {
+	if network == "mainnet" {
+		return "https://explorer.nervos.org/transaction/" + txHash
+	}
+	return "https://pudge.explorer.nervos.org/transaction/" + txHash
+}
+
+// walletRefundStatus returns the refund state of a wallet. Wallets emptied
+// before refund tracking existed, or by hand, count as sent.
+func walletRefundStatus(wallet *db.GuestWallet) string {
+	if wallet.RefundStatus != "" {
+		return wallet.RefundStatus
+	}
+	if wallet.Status == "withdrawn" {
+		return db.RefundStatusSent
+	}
+	return db.RefundStatusPending
+}
+
+// refundEstimatedArrival returns when the refund is expected to arrive, or
+// an empty string when that isn't known yet.
+func refundEstimatedArrival(wallet *db.GuestWallet, status string) string {
+	if wallet.RefundUpdatedAt == nil {
+		return ""
+	}
+	switch status {
+	case db.RefundStatusSent, db.RefundStatusPartial:
+		return wallet.RefundUpdatedAt.UTC().Format(time.RFC3339)
+	case db.RefundStatusProcessing:
+		return wallet.RefundUpdatedAt.Add(refundArrivalEstimate).UTC().Format(time.RFC3339)
+	}
+	return ""
+}
+
+// handleGetSessionToken returns a short-lived access token for a session,
+// and a refresh token valid until the session expires that exchanges for
+// new access tokens at POST /api/v1/auth/refresh. Only the host or the guest
+// holding the session's wallet, identified by the wallet_id query parameter,
+// may fetch them.
 func (s *Server) handleGetSessionToken(c *gin.Context) {
 	sessionID := c.Param("sessionId")
 
+	host := s.isDashboardAuthorized(c, auth.APIKeyScopeAdmin)
+	if host && !s.allowAPIKey(c) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+		return
+	}
+	walletID := c.Query("wallet_id")
+	if !host && walletID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "wallet_id required"})
+		return
+	}
+
 	dbSession, err := s.db.GetSession(sessionID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
 		return
 	}
+	if !host && subtle.ConstantTimeCompare([]byte(walletID), []byte(dbSession.WalletID)) != 1 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "wallet does not belong to this session"})
+		return
+	}
 
-	if time.Now().After(dbSession.ExpiresAt) {
+	if dbSession.IsExpired() {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "session expired"})
 		return
 	}
 
-	if dbSession.Status != "active" {
+	if !tokenIssuable(dbSession.Status) {
 		c.JSON(http.StatusPreconditionFailed, gin.H{
 			"error":   "channel not ready",
 			"status":  dbSession.Status,
@@ -394,20 +765,27 @@ func (s *Server) handleGetSessionToken(c *gin.Context) {
 		return
 	}
 
-	remaining := time.Until(dbSession.ExpiresAt)
-	token, err := s.jwtService.GenerateToken(dbSession.ID, dbSession.ChannelID, dbSession.MACAddress, dbSession.IPAddress, remaining)
+	remaining := dbSession.TimeUntilExpiry()
+	token, accessExpiresAt, err := s.issueAccessToken(dbSession)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return
+	}
+	refreshToken, err := s.jwt().GenerateRefreshToken(dbSession.ID, remaining)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"session_id":   dbSession.ID,
-		"access_token": token,
-		"expires_at":   dbSession.ExpiresAt.Format(time.RFC3339),
-		"channel_id":   dbSession.ChannelID,
-		"mac_address":  dbSession.MACAddress,
-		"ip_address":   dbSession.IPAddress,
+		"session_id":              dbSession.ID,
+		"access_token":            token,
+		"access_token_expires_at": accessExpiresAt.Format(time.RFC3339),
+		"refresh_token":           refreshToken,
+		"expires_at":              dbSession.ExpiresAt.Format(time.RFC3339),
+		"channel_id":              dbSession.ChannelID,
+		"mac_address":             dbSession.MACAddress,
+		"ip_address":              dbSession.IPAddress,
 	})
 }
 
@@ -421,8 +799,9 @@ func (s *Server) handleValidateToken(c *gin.Context) {
 		return
 	}
 
-	claims, err := s.jwtService.ValidateToken(req.Token)
+	claims, err := s.jwt().ValidateAccessToken(req.Token)
 	if err != nil {
+		auth.LogRejectedToken(s.logger, req.Token, err)
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"valid": false,
 			"error": err.Error(),
@@ -445,6 +824,7 @@ func (s *Server) handleValidateToken(c *gin.Context) {
 		"channel_id":     claims.ChannelID,
 		"mac_address":    claims.MACAddress,
 		"ip_address":     claims.IPAddress,
+		"scope":          claims.Scope,
 		"expires_at":     claims.ExpiresAt.Time.Format(time.RFC3339),
 		"remaining_secs": int(time.Until(claims.ExpiresAt.Time).Seconds()),
 	})
@@ -469,9 +849,7 @@ func (s *Server) handleGetSettings(c *gin.Context) {
 
 // handleUpdateRate updates the rate per hour.
 func (s *Server) handleUpdateRate(c *gin.Context) {
-	authCookie, err := c.Cookie("airfi_host_auth")
-	if err != nil || authCookie != s.dashboardPassword {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+	if !s.authorizeDashboard(c, auth.APIKeyScopeAdmin) {
 		return
 	}
 
@@ -483,26 +861,68 @@ func (s *Server) handleUpdateRate(c *gin.Context) {
 		return
 	}
 
-	if req.RatePerHour < 1 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "rate must be at least 1 CKB per hour"})
+	if err := validateRate(req.RatePerHour, s.minSessionTime, s.maxSessionTime); err != nil {
+		var rateErr *session.RateConfigError
+		if !errors.As(err, &rateErr) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		field := rateErr.Field
+		if field == "ckbytes_per_minute" {
+			field = "rate_per_hour"
+		}
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"field": field, "error": rateErr.Reason})
 		return
 	}
 
-	if err := s.db.SetRatePerHour(req.RatePerHour); err != nil {
+	if err := s.SetRate(req.RatePerHour); err != nil {
 		s.logger.Error("failed to set rate", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update rate"})
 		return
 	}
 
-	s.updateRatePerMin(req.RatePerHour)
-
 	s.logger.Info("rate updated", zap.Int64("rate", req.RatePerHour))
+	s.audit(s.requestActor(c), auditRateChanged, "", "", fmt.Sprintf("rate_per_hour=%d", req.RatePerHour))
 	c.JSON(http.StatusOK, gin.H{
 		"rate_per_hour": req.RatePerHour,
 		"message":       "Rate updated successfully",
 	})
 }
 
+// handleUpdateChannelSetup updates the CKB reserved for channel setup.
+func (s *Server) handleUpdateChannelSetup(c *gin.Context) {
+	if !s.authorizeDashboard(c, auth.APIKeyScopeAdmin) {
+		return
+	}
+
+	var req struct {
+		ChannelSetupCKB int64 `json:"channel_setup_ckb"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	if req.ChannelSetupCKB < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "channel setup must be at least 1 CKB"})
+		return
+	}
+
+	if err := s.db.SetChannelSetupCKB(req.ChannelSetupCKB); err != nil {
+		s.logger.Error("failed to set channel setup", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update channel setup"})
+		return
+	}
+
+	s.channelSetupCKB = req.ChannelSetupCKB
+
+	s.logger.Info("channel setup updated", zap.Int64("channel_setup_ckb", req.ChannelSetupCKB))
+	c.JSON(http.StatusOK, gin.H{
+		"channel_setup_ckb": req.ChannelSetupCKB,
+		"message":           "Channel setup updated successfully",
+	})
+}
+
 // handleOpenChannel opens a new payment channel (demo endpoint).
 func (s *Server) handleOpenChannel(c *gin.Context) {
 	var req struct {
@@ -526,70 +946,77 @@ func (s *Server) handleOpenChannel(c *gin.Context) {
 		zap.String("funding", fundingCKB.String()),
 	)
 
-	// Demo: use pre-funded guest wallet
-	guestPrivKeyHex := "afa8e30da03b2dc13a8eccc2546d1d7a36c4a9bbdcdc3e94d18e44cb4eb73b41"
-	guestKeyBytes, _ := hex.DecodeString(guestPrivKeyHex)
-	guestPrivKey := secp256k1.PrivKeyFromBytes(guestKeyBytes)
-
-	guestClient, err := perun.NewChannelClient(&perun.ChannelClientConfig{
-		RPCURL:     perun.TestnetRPCURL,
-		PrivateKey: guestPrivKey,
-		Deployment: perun.GetTestnetDeployment(),
-		Logger:     s.logger.Named("guest"),
-		WireBus:    s.wireBus,
-	})
-	if err != nil {
-		s.logger.Error("failed to create guest client", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create channel"})
-		return
-	}
-
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Minute)
-	defer cancel()
-
 	hostFunding := big.NewInt(10000000000) // 100 CKB
 
-	channel, err := guestClient.ProposeChannel(
-		ctx,
-		s.hostClient.GetWireAddress(),
-		s.hostClient.GetAccount().Address(),
-		fundingShannons,
-		hostFunding,
+	var (
+		guestClient *perun.ChannelClient
+		channel     *gpclient.Channel
+		channelID   perun.ChannelID
 	)
-	if err != nil {
-		guestClient.Close()
-		s.logger.Error("failed to open channel", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	if s.dryRun != nil {
+		channelID = s.dryRun.OpenChannel(fundingShannons, hostFunding)
+	} else {
+		// Demo: use pre-funded guest wallet
+		guestPrivKeyHex := "afa8e30da03b2dc13a8eccc2546d1d7a36c4a9bbdcdc3e94d18e44cb4eb73b41"
+		guestKeyBytes, _ := hex.DecodeString(guestPrivKeyHex)
+		guestPrivKey := secp256k1.PrivKeyFromBytes(guestKeyBytes)
+
+		var err error
+		guestClient, err = s.guestClients.Get(c.Request.Context(), guestPrivKey)
+		if err != nil {
+			s.logger.Error("failed to create guest client", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create channel"})
+			return
+		}
+
+		channel, err = guestClient.ProposeChannel(
+			c.Request.Context(),
+			s.hostClient.GetWireAddress(),
+			s.hostClient.GetAccount().Address(),
+			fundingShannons,
+			hostFunding,
+		)
+		if err != nil {
+			s.guestClients.Put(guestClient)
+			s.logger.Error("failed to open channel", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		channelID = perun.ChannelID(channel.ID())
 	}
 
 	minutes := new(big.Int).Div(fundingShannons, s.ratePerMin).Int64()
 	duration := time.Duration(minutes) * time.Minute
 
-	sessionID := fmt.Sprintf("%x", channel.ID())[:16]
+	// Demo sessions are keyed by the first 8 bytes of the channel ID
+	sessionID := hex.EncodeToString(channelID[:8])
 	session := &GuestSession{
 		ID:            sessionID,
 		Client:        guestClient,
 		Channel:       channel,
+		ChannelID:     channelID,
 		GuestAddress:  req.GuestAddress,
 		FundingAmount: fundingShannons,
 		TotalPaid:     big.NewInt(0),
 		CreatedAt:     time.Now(),
 		ExpiresAt:     time.Now().Add(duration),
+		LastPaymentAt: time.Now(),
 	}
 
 	s.sessionsMu.Lock()
 	s.sessions[sessionID] = session
 	s.sessionsMu.Unlock()
+	s.storeSessionOpened(session)
 
 	s.logger.Info("channel opened",
 		zap.String("session_id", sessionID),
-		zap.String("channel_id", fmt.Sprintf("%x", channel.ID())),
+		zap.String("channel_id", channelID.String()),
 	)
+	s.audit(s.requestActor(c), auditChannelOpened, sessionID, "", "channel_id="+channelID.String())
 
 	c.JSON(http.StatusOK, gin.H{
 		"session_id":     sessionID,
-		"channel_id":     fmt.Sprintf("%x", channel.ID()),
+		"channel_id":     channelID,
 		"funding_amount": fundingCKB.String(),
 		"duration_mins":  minutes,
 	})
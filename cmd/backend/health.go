@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	healthCheckTimeout = 2 * time.Second
+
+	healthStatusHealthy   = "healthy"
+	healthStatusDegraded  = "degraded"
+	healthStatusUnhealthy = "unhealthy"
+)
+
+// componentHealth is the result of checking a single subsystem.
+type componentHealth struct {
+	Status         string `json:"status"`
+	LatencyMS      *int64 `json:"latency_ms,omitempty"`
+	ActiveChannels *int   `json:"active_channels,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// criticalComponents make the server unhealthy when they fail.
+var criticalComponents = map[string]bool{"rpc": true, "database": true}
+
+// handleHealth reports overall health with a per-component breakdown.
+// It returns 503 when a critical component is down.
+func (s *Server) handleHealth(c *gin.Context) {
+	checks := map[string]func(ctx context.Context) componentHealth{
+		"rpc":         s.checkRPCHealth,
+		"indexer":     s.checkIndexerHealth,
+		"database":    s.checkDatabaseHealth,
+		"channel_bus": s.checkChannelBusHealth,
+		"router":      s.checkRouterHealth,
+	}
+
+	components := make(map[string]componentHealth, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(ctx context.Context) componentHealth) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(c.Request.Context(), healthCheckTimeout)
+			defer cancel()
+			result := check(ctx)
+
+			mu.Lock()
+			components[name] = result
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	status := healthStatusHealthy
+	for name, component := range components {
+		if component.Status == "ok" {
+			continue
+		}
+		if criticalComponents[name] {
+			status = healthStatusUnhealthy
+			break
+		}
+		status = healthStatusDegraded
+	}
+
+	code := http.StatusOK
+	if status == healthStatusUnhealthy {
+		code = http.StatusServiceUnavailable
+	}
+
+	resp := gin.H{
+		"status":         status,
+		"timestamp":      time.Now().UTC().Format(time.RFC3339),
+		"uptime_seconds": int64(time.Since(s.startedAt).Seconds()),
+		"connected":      components["rpc"].Status == "ok",
+		"components":     components,
+	}
+	// Left out when the database is down; its component reports why
+	if active, err := s.db.GetActiveSessionCount(); err == nil {
+		resp["active_sessions"] = active
+	}
+	c.JSON(code, resp)
+}
+
+// checkRPCHealth measures a round trip to the CKB node.
+func (s *Server) checkRPCHealth(ctx context.Context) componentHealth {
+	if s.ckbClient == nil {
+		return failedComponent(errors.New("not configured"))
+	}
+	start := time.Now()
+	if _, err := s.ckbClient.GetTipBlockNumber(ctx); err != nil {
+		return failedComponent(err)
+	}
+	return timedComponent(start)
+}
+
+// checkIndexerHealth measures a round trip to the CKB indexer.
+func (s *Server) checkIndexerHealth(ctx context.Context) componentHealth {
+	if s.ckbClient == nil {
+		return failedComponent(errors.New("not configured"))
+	}
+	start := time.Now()
+	if _, err := s.ckbClient.GetIndexerTip(ctx); err != nil {
+		return failedComponent(err)
+	}
+	return timedComponent(start)
+}
+
+// checkDatabaseHealth pings the session database.
+func (s *Server) checkDatabaseHealth(ctx context.Context) componentHealth {
+	if s.db == nil {
+		return failedComponent(errors.New("not configured"))
+	}
+	if err := s.db.Ping(ctx); err != nil {
+		return failedComponent(err)
+	}
+	return componentHealth{Status: "ok"}
+}
+
+// checkChannelBusHealth reports whether the Perun host client is running.
+func (s *Server) checkChannelBusHealth(ctx context.Context) componentHealth {
+	if s.hostClient == nil || s.wireBus == nil {
+		return failedComponent(errors.New("host client not running"))
+	}
+	s.sessionsMu.RLock()
+	active := len(s.sessions)
+	s.sessionsMu.RUnlock()
+	return componentHealth{Status: "ok", ActiveChannels: &active}
+}
+
+// checkRouterHealth measures a round trip to the WiFi router.
+func (s *Server) checkRouterHealth(ctx context.Context) componentHealth {
+	if s.router == nil {
+		return failedComponent(errors.New("not configured"))
+	}
+	start := time.Now()
+	if err := s.router.Ping(ctx); err != nil {
+		return failedComponent(err)
+	}
+	return timedComponent(start)
+}
+
+// timedComponent returns a healthy result with the latency since start.
+func timedComponent(start time.Time) componentHealth {
+	latency := time.Since(start).Milliseconds()
+	return componentHealth{Status: "ok", LatencyMS: &latency}
+}
+
+// failedComponent returns an unhealthy result carrying err.
+func failedComponent(err error) componentHealth {
+	return componentHealth{Status: "error", Error: err.Error()}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/indexer"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/rpc"
+
+	"github.com/airfi/airfi-perun-nervous/internal/router"
+)
+
+// healthRPCClient answers the node and indexer probes used by /health.
+type healthRPCClient struct {
+	rpc.Client
+	rpcErr     error
+	indexerErr error
+}
+
+func (m *healthRPCClient) GetTipBlockNumber(ctx context.Context) (uint64, error) {
+	return 100, m.rpcErr
+}
+
+func (m *healthRPCClient) GetIndexerTip(ctx context.Context) (*indexer.TipHeader, error) {
+	return &indexer.TipHeader{BlockNumber: 100}, m.indexerErr
+}
+
+func TestHandleHealth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		client     *healthRPCClient
+		closeDB    bool
+		wantCode   int
+		wantStatus string
+		wantFailed string
+	}{
+		{"host client missing", &healthRPCClient{}, false, http.StatusOK, healthStatusDegraded, "channel_bus"},
+		{"indexer down", &healthRPCClient{indexerErr: errors.New("timeout")}, false, http.StatusOK, healthStatusDegraded, "indexer"},
+		{"rpc down", &healthRPCClient{rpcErr: errors.New("connection refused")}, false, http.StatusServiceUnavailable, healthStatusUnhealthy, "rpc"},
+		{"database down", &healthRPCClient{}, true, http.StatusServiceUnavailable, healthStatusUnhealthy, "database"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.ckbClient = tt.client
+			s.router = &router.NoopRouter{}
+			if tt.closeDB {
+				s.db.Close()
+			}
+
+			r := gin.New()
+			r.GET("/health", s.handleHealth)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("expected %d, got %d: %s", tt.wantCode, w.Code, w.Body.String())
+			}
+
+			var resp struct {
+				Status         string                     `json:"status"`
+				UptimeSeconds  *int64                     `json:"uptime_seconds"`
+				ActiveSessions *int                       `json:"active_sessions"`
+				Components     map[string]componentHealth `json:"components"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid JSON: %v", err)
+			}
+			if resp.Status != tt.wantStatus {
+				t.Errorf("status: expected %s, got %s", tt.wantStatus, resp.Status)
+			}
+			if resp.UptimeSeconds == nil {
+				t.Error("missing uptime_seconds")
+			}
+			if len(resp.Components) != 5 {
+				t.Errorf("expected 5 components, got %d", len(resp.Components))
+			}
+			if got := resp.Components[tt.wantFailed]; got.Status != "error" || got.Error == "" {
+				t.Errorf("%s: expected error status, got %+v", tt.wantFailed, got)
+			}
+			if !tt.closeDB && resp.Components["database"].Status != "ok" {
+				t.Errorf("database: expected ok, got %+v", resp.Components["database"])
+			}
+			if tt.closeDB != (resp.ActiveSessions == nil) {
+				t.Errorf("active_sessions: expected it only with the database up, got %v", resp.ActiveSessions)
+			}
+			if tt.client.rpcErr == nil && resp.Components["rpc"].LatencyMS == nil {
+				t.Error("rpc: missing latency_ms")
+			}
+		})
+	}
+}
+
+// unreachableRouter fails every ping.
+type unreachableRouter struct {
+	router.NoopRouter
+}
+
+func (r *unreachableRouter) Ping(ctx context.Context) error {
+	return errors.New("ssh: connection refused")
+}
+
+func TestHandleHealth_RouterDown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+	s.ckbClient = &healthRPCClient{}
+
+	r := gin.New()
+	r.GET("/health", s.handleHealth)
+
+	var resp struct {
+		Status     string                     `json:"status"`
+		Components map[string]componentHealth `json:"components"`
+	}
+
+	s.router = &router.NoopRouter{}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if got := resp.Components["router"]; got.Status != "ok" || got.LatencyMS == nil {
+		t.Errorf("router: expected ok with latency_ms, got %+v", got)
+	}
+
+	s.router = &unreachableRouter{}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 with the router down, got %d", w.Code)
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Status != healthStatusDegraded {
+		t.Errorf("status: expected %s, got %s", healthStatusDegraded, resp.Status)
+	}
+	if got := resp.Components["router"]; got.Status != "error" || got.Error == "" {
+		t.Errorf("router: expected error status, got %+v", got)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/airfi/airfi-perun-nervous/internal/auth"
+)
+
+// maxPauseDuration is how long a guest's session page may go without a
+// heartbeat, e.g. while the device sleeps. Sessions silent for twice as
+// long are considered abandoned and expired.
+const maxPauseDuration = 5 * time.Minute
+
+// heartbeatCheckInterval is how often stale sessions are looked for.
+const heartbeatCheckInterval = time.Minute
+
+// handleSessionHeartbeat records a keep-alive from the session page and
+// returns the countdown and balance. It only touches the database, so the
+// page can call it often instead of the full session endpoint.
+func (s *Server) handleSessionHeartbeat(c *gin.Context) {
+	sessionID := c.Param("sessionId")
+
+	if !s.authorizeSessionScope(c, sessionID, auth.ScopeRead) {
+		return
+	}
+
+	session, err := s.db.GetSession(sessionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return
+	}
+
+	remaining := session.TimeUntilExpiry()
+	status := session.Status
+	if status == "active" && remaining <= 0 {
+		status = "expired"
+	}
+
+	alive := status == "active" || status == "channel_opening"
+	if alive {
+		if err := s.db.UpdateSessionHeartbeat(sessionID, time.Now()); err != nil {
+			s.logger.Warn("failed to record heartbeat", zap.String("session_id", sessionID), zap.Error(err))
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"alive":             alive,
+		"remaining_seconds": int64(remaining.Seconds()),
+		"balance_ckb":       session.BalanceCKB,
+		"status":            status,
+	})
+}
+
+// startHeartbeatMonitor runs a background loop that expires sessions whose
+// page has stopped sending heartbeats.
+func (s *Server) startHeartbeatMonitor(ctx context.Context) {
+	ticker := time.NewTicker(heartbeatCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, session := range s.detachStaleSessions(time.Now()) {
+				go s.settleExpiredSession(ctx, session)
+			}
+		}
+	}
+}
+
+// detachStaleSessions removes active sessions with no heartbeat for
+// 2×maxPauseDuration from the in-memory set and returns them for settlement.
+// Sessions that never sent a heartbeat are left alone.
+func (s *Server) detachStaleSessions(now time.Time) []*GuestSession {
+	stale, err := s.db.ListStaleSessions(now.Add(-2 * maxPauseDuration))
+	if err != nil {
+		s.logger.Error("failed to list stale sessions", zap.Error(err))
+		return nil
+	}
+
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+
+	var detached []*GuestSession
+	for _, dbSession := range stale {
+		session, exists := s.sessions[dbSession.ID]
+		if !exists {
+			continue
+		}
+		delete(s.sessions, dbSession.ID)
+		s.storeSessionEnded(dbSession.ID, false)
+		detached = append(detached, session)
+
+		s.logger.Info("session heartbeat lost, settling channel",
+			zap.String("session_id", dbSession.ID),
+			zap.Time("last_heartbeat_at", *dbSession.LastHeartbeatAt),
+		)
+	}
+	return detached
+}
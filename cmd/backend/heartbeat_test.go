@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+)
+
+func TestHandleSessionHeartbeat(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+
+	now := time.Now()
+	s.db.CreateSession(&db.Session{ID: "active", Status: "active", BalanceCKB: 420, CreatedAt: now, ExpiresAt: now.Add(10 * time.Minute)})
+	s.db.CreateSession(&db.Session{ID: "lapsed", Status: "active", BalanceCKB: 0, CreatedAt: now, ExpiresAt: now.Add(-time.Minute)})
+
+	r := gin.New()
+	r.POST("/api/v1/sessions/:sessionId/heartbeat", s.handleSessionHeartbeat)
+
+	beat := func(id string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, hostRequest(t, s, http.MethodPost, "/api/v1/sessions/"+id+"/heartbeat", nil))
+		var resp map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+
+	code, resp := beat("active")
+	if code != http.StatusOK || resp["alive"] != true || resp["balance_ckb"].(float64) != 420 {
+		t.Fatalf("unexpected response %d: %v", code, resp)
+	}
+	if secs := resp["remaining_seconds"].(float64); secs < 590 || secs > 600 {
+		t.Errorf("remaining_seconds: expected ~600, got %v", secs)
+	}
+	if session, _ := s.db.GetSession("active"); session.LastHeartbeatAt == nil {
+		t.Error("heartbeat was not recorded")
+	}
+
+	code, resp = beat("lapsed")
+	if code != http.StatusOK || resp["alive"] != false || resp["status"] != "expired" || resp["remaining_seconds"].(float64) != 0 {
+		t.Errorf("expired session: unexpected response %d: %v", code, resp)
+	}
+	if session, _ := s.db.GetSession("lapsed"); session.LastHeartbeatAt != nil {
+		t.Error("heartbeat recorded for an expired session")
+	}
+
+	if code, _ = beat("missing"); code != http.StatusNotFound {
+		t.Errorf("unknown session: expected 404, got %d", code)
+	}
+}
+
+func TestDetachStaleSessions(t *testing.T) {
+	s := newTestServer(t)
+
+	now := time.Now()
+	for _, id := range []string{"stale", "fresh", "silent"} {
+		s.db.CreateSession(&db.Session{ID: id, Status: "active", CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+		s.sessions[id] = &GuestSession{ID: id}
+	}
+	s.db.UpdateSessionHeartbeat("stale", now.Add(-2*maxPauseDuration-time.Minute))
+	s.db.UpdateSessionHeartbeat("fresh", now.Add(-maxPauseDuration))
+
+	detached := s.detachStaleSessions(now)
+	if len(detached) != 1 || detached[0].ID != "stale" {
+		t.Fatalf("expected only the stale session, got %v", detached)
+	}
+	if _, ok := s.sessions["stale"]; ok {
+		t.Error("stale session still in memory")
+	}
+	if len(s.sessions) != 2 {
+		t.Errorf("expected fresh and silent sessions to remain, got %d", len(s.sessions))
+	}
+}
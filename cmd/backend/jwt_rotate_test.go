@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/airfi/airfi-perun-nervous/internal/auth"
+)
+
+func TestHandleRotateJWTKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+	s.jwtService = newTestJWTService(t)
+	dir := t.TempDir()
+	s.jwtKeyPaths = [2]string{filepath.Join(dir, "private.pem"), filepath.Join(dir, "public.pem")}
+
+	oldToken, _, _ := s.jwt().GenerateToken("session-1", "channel-1", "", "", time.Hour)
+
+	r := gin.New()
+	r.POST("/api/v1/admin/keys/rotate", s.handleRotateJWTKey)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/keys/rotate", strings.NewReader(`{"overlap_seconds":3600}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		OverlapSeconds int64 `json:"overlap_seconds"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if resp.OverlapSeconds != 3600 {
+		t.Errorf("overlap_seconds: expected 3600, got %d", resp.OverlapSeconds)
+	}
+
+	if _, err := s.jwt().ValidateToken(oldToken); err != nil {
+		t.Errorf("old token should stay valid during overlap: %v", err)
+	}
+
+	// New tokens are signed with the key saved to disk
+	newToken, _, _ := s.jwt().GenerateToken("session-2", "channel-2", "", "", time.Hour)
+	saved, err := auth.LoadKeyPair(s.jwtKeyPaths[0], s.jwtKeyPaths[1])
+	if err != nil {
+		t.Fatalf("rotated key not saved: %v", err)
+	}
+	if _, err := auth.NewJWTService(saved, "test").ValidateToken(newToken); err != nil {
+		t.Errorf("new token not signed with saved key: %v", err)
+	}
+}
+
+func TestHandleJWKS_FollowsRotation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+	s.jwtService = newTestJWTService(t)
+	dir := t.TempDir()
+	s.jwtKeyPaths = [2]string{filepath.Join(dir, "private.pem"), filepath.Join(dir, "public.pem")}
+
+	r := gin.New()
+	r.GET("/.well-known/jwks.json", s.handleJWKS)
+	r.POST("/api/v1/admin/keys/rotate", s.handleRotateJWTKey)
+
+	fetch := func() auth.JWKSet {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var set auth.JWKSet
+		if err := json.Unmarshal(w.Body.Bytes(), &set); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		return set
+	}
+
+	before := fetch()
+	if len(before.Keys) != 1 {
+		t.Fatalf("Expected 1 key, got %d", len(before.Keys))
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/keys/rotate", strings.NewReader(`{"overlap_seconds":3600}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	after := fetch()
+	if len(after.Keys) != 2 {
+		t.Fatalf("Expected 2 keys during overlap, got %d", len(after.Keys))
+	}
+	if after.Keys[1].Kid != before.Keys[0].Kid {
+		t.Error("Expected the old key to stay published during overlap")
+	}
+}
@@ -4,10 +4,13 @@ package main
 import (
 	"context"
 	"encoding/hex"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 	"github.com/nervosnetwork/ckb-sdk-go/v2/rpc"
@@ -18,13 +21,24 @@ import (
 
 	"github.com/airfi/airfi-perun-nervous/internal/auth"
 	"github.com/airfi/airfi-perun-nervous/internal/config"
+	"github.com/airfi/airfi-perun-nervous/internal/cron"
 	"github.com/airfi/airfi-perun-nervous/internal/db"
 	"github.com/airfi/airfi-perun-nervous/internal/guest"
 	"github.com/airfi/airfi-perun-nervous/internal/perun"
 	"github.com/airfi/airfi-perun-nervous/internal/router"
+	"github.com/airfi/airfi-perun-nervous/internal/session"
+	"github.com/airfi/airfi-perun-nervous/internal/webhook"
 )
 
 func main() {
+	checkKeys := flag.Bool("check-keys", false, "Report guest wallets whose private key is stored in plaintext and exit 1 if any remain")
+	migrateKeys := flag.Bool("migrate-keys", false, "Encrypt every plaintext guest wallet private key with database.key_encryption_key and exit")
+	replicaPath := flag.String("db-path-replica", "", "Serve analytics reads from a read-only connection to this SQLite file (usually the same as database.path)")
+	forceSettle := flag.Bool("force-settle-on-shutdown", false, "Settle every open channel on shutdown (overrides server.force_settle_on_shutdown)")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 5*time.Minute, "How long settling channels on shutdown may take (overrides server.shutdown_timeout)")
+	dryRunChannels := flag.Bool("dry-run-channels", false, "Open, pay and settle channels in memory only; no on-chain transactions are sent")
+	flag.Parse()
+
 	// Initialize logger
 	logger, err := zap.NewDevelopment()
 	if err != nil {
@@ -38,11 +52,44 @@ func main() {
 	if configPath == "" {
 		configPath = "./config/config.yaml"
 	}
-	cfg, err := config.Load(configPath)
+	// Environment overlay (config.testnet.yaml / config.mainnet.yaml)
+	configEnv := os.Getenv("AIRFI_ENV")
+	if configEnv == "" {
+		configEnv = "testnet"
+	}
+	cfg, err := config.LoadWithOverride(configPath, config.OverridePath(configPath, configEnv))
 	if err != nil {
 		logger.Fatal("failed to load config", zap.Error(err))
 	}
 
+	// Shutdown flags override the config only when given
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "force-settle-on-shutdown":
+			cfg.Server.ForceSettleOnShutdown = *forceSettle
+		case "shutdown-timeout":
+			cfg.Server.ShutdownTimeout = *shutdownTimeout
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		var validationErr *config.ValidationError
+		if !errors.As(err, &validationErr) {
+			logger.Fatal("invalid config", zap.Error(err))
+		}
+		for _, msg := range validationErr.ValidationErrors {
+			logger.Error("invalid config", zap.String("error", msg))
+		}
+		os.Exit(1)
+	}
+
+	if *migrateKeys {
+		os.Exit(migratePlaintextKeys(cfg, logger))
+	}
+	if *checkKeys {
+		os.Exit(checkPlaintextKeys(cfg, logger))
+	}
+
 	fmt.Println("═══════════════════════════════════════════════════════════════")
 	fmt.Println("  AirFi WiFi Access Backend")
 	fmt.Println("  Real Perun State Channels on CKB Testnet")
@@ -65,12 +112,21 @@ func main() {
 
 	// Create Host channel client
 	fmt.Println("\n  Initializing Host channel client...")
+	var updateValidator perun.UpdateValidator
+	if cfg.Perun.StrictUpdateValidation {
+		// The guest proposes the channel, so it is participant 0
+		updateValidator = &perun.MonotonicBalanceValidator{PayerIdx: 0}
+	}
 	hostClient, err := perun.NewChannelClient(&perun.ChannelClientConfig{
 		RPCURL:     perun.TestnetRPCURL,
 		PrivateKey: hostPrivKey,
 		Deployment: perun.GetTestnetDeployment(),
 		Logger:     logger.Named("host"),
 		WireBus:    wireBus,
+
+		CoopCloseTimeout: cfg.Perun.CoopCloseTimeout,
+		BalanceCacheTTL:  cfg.Perun.BalanceCacheTTL,
+		UpdateValidator:  updateValidator,
 	})
 	if err != nil {
 		logger.Fatal("failed to create Host client", zap.Error(err))
@@ -88,27 +144,31 @@ func main() {
 	// Check host balance
 	ctx := context.Background()
 	balance, _ := hostClient.GetBalance(ctx)
-	hostBalanceCKB := float64(balance.Int64()) / 100000000
-	fmt.Printf("  Host Balance: %.2f CKB\n", hostBalanceCKB)
+	fmt.Printf("  Host Balance: %s CKB\n", session.FormatCKB(balance))
 
-	if hostBalanceCKB < 200 {
-		fmt.Printf("  WARNING: Host balance (%.2f CKB) may be too low for channel operations!\n", hostBalanceCKB)
+	if session.ShannonsToCKB(balance) < 200 {
+		fmt.Printf("  WARNING: Host balance (%s CKB) may be too low for channel operations!\n", session.FormatCKB(balance))
 		fmt.Println("           Recommended minimum: 200 CKB")
 		fmt.Println("           Please fund from: https://faucet.nervos.org")
 	}
 
 	// Prepare host wallet cells
 	fmt.Println("  Preparing Host wallet cells for Perun...")
-	hostLockScript, err := guest.DecodeAddress(hostClient.GetAddress())
-	if err != nil {
-		logger.Fatal("failed to decode host address", zap.Error(err))
-	}
-	hostCellSplitter := perun.NewCellSplitter(ckbClient, logger.Named("host-cell-splitter"))
-	if err := hostCellSplitter.EnsureMinimumCells(ctx, hostPrivKey, hostLockScript, 3); err != nil {
-		logger.Fatal("failed to prepare host wallet cells", zap.Error(err))
+	hostLockScript := hostClient.GetLockScript()
+	feeOracle := perun.NewNetworkFeeOracle(ckbClient, logger.Named("fee-oracle"))
+	hostClient.SetFeeOracle(feeOracle)
+	if *dryRunChannels {
+		// Splitting cells is an on-chain transaction
+		fmt.Println("  Host wallet cells: skipped (dry-run)")
+	} else {
+		hostCellSplitter := perun.NewCellSplitter(ckbClient, logger.Named("host-cell-splitter"))
+		hostCellSplitter.SetFeeOracle(feeOracle)
+		if err := hostCellSplitter.EnsureMinimumCells(ctx, hostPrivKey, hostLockScript, 3); err != nil {
+			logger.Fatal("failed to prepare host wallet cells", zap.Error(err))
+		}
+		hostCellCount, _ := hostCellSplitter.CountCells(ctx, hostLockScript)
+		fmt.Printf("  Host wallet cells ready (count: %d)\n", hostCellCount)
 	}
-	hostCellCount, _ := hostCellSplitter.CountCells(ctx, hostLockScript)
-	fmt.Printf("  Host wallet cells ready (count: %d)\n", hostCellCount)
 
 	// Initialize JWT service - from config
 	keyPair, err := auth.LoadOrGenerateKeyPair(cfg.Auth.PrivateKeyPath, cfg.Auth.PublicKeyPath)
@@ -125,12 +185,18 @@ func main() {
 	}
 
 	// Initialize database - from config
-	database, err := db.Open(cfg.Database.Path)
+	database, err := openDatabase(cfg)
 	if err != nil {
 		logger.Fatal("failed to open database", zap.Error(err))
 	}
 	defer database.Close()
 	fmt.Printf("  Database: SQLite initialized (%s)\n", cfg.Database.Path)
+	if *replicaPath != "" {
+		if _, err := database.OpenReadReplica(*replicaPath); err != nil {
+			logger.Fatal("failed to open read replica", zap.Error(err))
+		}
+		fmt.Printf("  Read Replica: %s (read-only)\n", *replicaPath)
+	}
 
 	// Initialize router (OpenWrt/OpenNDS) - from config
 	wifiRouter := initializeRouter(cfg, logger)
@@ -145,24 +211,115 @@ func main() {
 		ratePerHour = cfg.WiFi.RatePerHour
 	}
 	fmt.Printf("  Rate: %d CKB/hour (%.2f CKB/min)\n", ratePerHour, float64(ratePerHour)/60)
-	fmt.Printf("  Channel Setup: %d CKB (reserved)\n", cfg.Perun.ChannelSetupCKB)
+
+	// Time-of-day pricing tiers override the base rate during their hours
+	tiers := make([]session.PricingTier, 0, len(cfg.WiFi.PricingTiers))
+	for _, tier := range cfg.WiFi.PricingTiers {
+		tiers = append(tiers, session.PricingTier{
+			StartHour:      tier.StartHour,
+			EndHour:        tier.EndHour,
+			RatePerHourCKB: tier.RatePerHour,
+		})
+	}
+	rateCalculator, err := session.NewRateCalculator(tiers, ratePerHour)
+	if err != nil {
+		logger.Fatal("invalid pricing tiers", zap.Error(err))
+	}
+	if len(tiers) > 0 {
+		fmt.Printf("  Pricing Tiers: %d configured\n", len(tiers))
+	}
+
+	// Load channel setup reserve from database (or use config default)
+	channelSetupCKB, err := database.GetChannelSetupCKB()
+	if err != nil {
+		channelSetupCKB = cfg.Perun.ChannelSetupCKB
+	}
+	fmt.Printf("  Channel Setup: %d CKB (reserved)\n", channelSetupCKB)
+
+	// Load guest wallet TTL from database (or use config default)
+	walletTTL, err := database.GetWalletTTL()
+	if err != nil {
+		walletTTL = cfg.WiFi.WalletTTL
+	}
+
+	// Password changed from the dashboard or CLI overrides the config value
+	passwordHash, err := loadDashboardPasswordHash(database, dashboardPassword)
+	if err != nil {
+		logger.Fatal("failed to load dashboard password", zap.Error(err))
+	}
+
+	// Scheduled database compaction (already validated with the config)
+	var compactSchedule *cron.Schedule
+	if cfg.Database.CompactSchedule != "" {
+		if compactSchedule, err = cron.Parse(cfg.Database.CompactSchedule); err != nil {
+			logger.Fatal("invalid database compaction schedule", zap.Error(err))
+		}
+		fmt.Printf("  DB Compaction: %s\n", cfg.Database.CompactSchedule)
+	}
+
+	// Outgoing event webhooks (optional)
+	webhooks := webhook.NewNotifier(cfg.Webhooks.URLs, cfg.Webhooks.Secret, logger.Named("webhook"))
+	if len(cfg.Webhooks.URLs) > 0 {
+		fmt.Printf("  Webhooks: %d URL(s) configured\n", len(cfg.Webhooks.URLs))
+	}
+
+	// Channel assets: CKBytes plus any configured Simple UDT tokens
+	assets := perun.NewAssetRegistry()
+	for _, a := range cfg.WiFi.Assets {
+		if err := assets.RegisterSUDT(types.HexToHash(a.IssuerLockHash), a.Name); err != nil {
+			logger.Fatal("failed to register asset", zap.String("asset", a.Name), zap.Error(err))
+		}
+	}
+	fmt.Printf("  Payment Asset: %s\n", cfg.WiFi.PaymentAsset)
 
 	// Create server
-	server := NewServer(&ServerConfig{
+	server, err := NewServer(&ServerConfig{
 		HostClient:        hostClient,
 		HostPrivKey:       hostPrivKey,
 		HostLockScript:    hostLockScript,
 		WireBus:           wireBus,
 		CKBClient:         ckbClient,
 		JWTService:        jwtService,
+		JWTPrivateKeyPath: cfg.Auth.PrivateKeyPath,
+		JWTPublicKeyPath:  cfg.Auth.PublicKeyPath,
 		DB:                database,
 		WalletManager:     walletMgr,
 		Logger:            logger,
 		RatePerHour:       ratePerHour,
-		ChannelSetupCKB:   cfg.Perun.ChannelSetupCKB,
-		DashboardPassword: dashboardPassword,
+		RateCalculator:    rateCalculator,
+		SessionStorePath:  cfg.Session.PersistPath,
+		ChannelSetupCKB:   channelSetupCKB,
+		PasswordHash:      passwordHash,
 		Router:            wifiRouter,
+		FeeOracle:         feeOracle,
+		Webhooks:          webhooks,
+		FundingTimeout:    cfg.Perun.FundingTimeout,
+		WalletTTL:         walletTTL,
+		MinSessionTime:    cfg.WiFi.MinSessionTime,
+		MaxSessionTime:    cfg.WiFi.MaxSessionTime,
+		MaxDevices:        cfg.WiFi.MaxConcurrentDevices,
+		RetryFunding:      cfg.Perun.RetryFundingOnTimeout,
+		CoopCloseTimeout:  cfg.Perun.CoopCloseTimeout,
+		CompactSchedule:   compactSchedule,
+		ForceSettle:       cfg.Server.ForceSettleOnShutdown,
+		ShutdownTimeout:   cfg.Server.ShutdownTimeout,
+		Assets:            assets,
+		PaymentAsset:      cfg.WiFi.PaymentAsset,
+		PriceOracleURL:    cfg.WiFi.PriceOracleURL,
+		DryRunChannels:    *dryRunChannels,
+		Network:           cfg.CKB.Network,
 	})
+	if err != nil {
+		logger.Fatal("failed to create server", zap.Error(err))
+	}
+
+	// Settings that can change while running follow edits to the config files
+	configWatcher, err := config.WatchWithOverride(configPath, config.OverridePath(configPath, configEnv), 0, server.applyConfigChange)
+	if err != nil {
+		logger.Warn("config reload disabled", zap.Error(err))
+	} else {
+		defer configWatcher.Stop()
+	}
 
 	// Get server address - from config
 	addr := fmt.Sprintf(":%d", cfg.Server.Port)
@@ -203,17 +360,40 @@ func initializeRouter(cfg *config.Config, logger *zap.Logger) router.Router {
 		openwrtPort = 22
 	}
 
+	// private_key is a path; hand the router loaded auth methods instead
+	auth, err := cfg.OpenWrt.AuthMethods()
+	if err != nil {
+		logger.Fatal("failed to set up OpenWrt SSH authentication", zap.Error(err))
+	}
+
 	openwrtConfig := router.OpenWrtConfig{
 		Address:     cfg.OpenWrt.Address,
 		Port:        openwrtPort,
 		Username:    cfg.OpenWrt.Username,
-		Password:    cfg.OpenWrt.Password,
-		PrivateKey:  cfg.OpenWrt.PrivateKey,
 		AuthTimeout: cfg.OpenWrt.AuthTimeout,
-	}
 
-	if openwrtConfig.Username == "" {
-		openwrtConfig.Username = "root"
+		Auth:           auth,
+		KnownHostsPath: cfg.OpenWrt.KnownHostsPath,
+
+		PoolSize:          cfg.OpenWrt.PoolSize,
+		HeartbeatInterval: cfg.OpenWrt.HeartbeatInterval,
+
+		Name:            cfg.OpenWrt.Name,
+		SignalThreshold: cfg.OpenWrt.SignalThreshold,
+	}
+	for i, ap := range cfg.OpenWrt.AccessPoints {
+		apAuth, err := ap.AuthMethods()
+		if err != nil {
+			logger.Fatal("failed to set up access point SSH authentication", zap.Int("access_point", i+1), zap.Error(err))
+		}
+		openwrtConfig.AccessPoints = append(openwrtConfig.AccessPoints, router.OpenWrtConfig{
+			Name:            ap.Name,
+			Address:         ap.Address,
+			Port:            ap.Port,
+			Username:        ap.Username,
+			Auth:            apAuth,
+			SignalThreshold: ap.SignalThreshold,
+		})
 	}
 
 	wifiRouter, err := router.NewOpenWrtClient(openwrtConfig, logger.Named("openwrt"))
@@ -221,6 +401,9 @@ func initializeRouter(cfg *config.Config, logger *zap.Logger) router.Router {
 		logger.Fatal("failed to create OpenWrt client", zap.Error(err))
 	}
 	fmt.Printf("  Router: OpenWrt/OpenNDS @ %s:%d\n", cfg.OpenWrt.Address, openwrtPort)
+	if n := len(cfg.OpenWrt.AccessPoints); n > 0 {
+		fmt.Printf("  Access Points: %d additional\n", n)
+	}
 
 	if err := wifiRouter.TestConnection(context.Background()); err != nil {
 		logger.Warn("OpenWrt connection test failed", zap.Error(err))
@@ -231,3 +414,85 @@ func initializeRouter(cfg *config.Config, logger *zap.Logger) router.Router {
 
 	return wifiRouter
 }
+
+// openDatabase opens the configured database and, with
+// database.key_encryption_key set, encrypts guest wallet keys at rest.
+func openDatabase(cfg *config.Config) (*db.DB, error) {
+	keyCipher, err := keyCipherFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	database, err := db.Open(cfg.Database.Path)
+	if err != nil {
+		return nil, err
+	}
+	if keyCipher != nil {
+		database.SetKeyCipher(keyCipher)
+	}
+	return database, nil
+}
+
+// keyCipherFromConfig returns the cipher for database.key_encryption_key,
+// or nil if it isn't set.
+func keyCipherFromConfig(cfg *config.Config) (*db.KeyCipher, error) {
+	if cfg.Database.KeyEncryptionKey == "" {
+		return nil, nil
+	}
+	key, err := hex.DecodeString(cfg.Database.KeyEncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid database.key_encryption_key: %w", err)
+	}
+	return db.NewKeyCipher(key)
+}
+
+// checkPlaintextKeys reports how many guest wallets still store a plaintext
+// private key and returns the process exit code: 1 if any remain or the
+// database can't be read, 0 otherwise.
+func checkPlaintextKeys(cfg *config.Config, logger *zap.Logger) int {
+	database, err := openDatabase(cfg)
+	if err != nil {
+		logger.Error("failed to open database", zap.Error(err))
+		return 1
+	}
+	defer database.Close()
+
+	count, err := database.CountPlaintextKeys()
+	if err != nil {
+		logger.Error("failed to check private keys", zap.Error(err))
+		return 1
+	}
+	if count > 0 {
+		fmt.Printf("%d guest wallet(s) store a plaintext private key; run with --migrate-keys to encrypt them\n", count)
+		return 1
+	}
+	fmt.Println("All guest wallet private keys are encrypted")
+	return 0
+}
+
+// migratePlaintextKeys encrypts the plaintext guest wallet keys and returns
+// the process exit code.
+func migratePlaintextKeys(cfg *config.Config, logger *zap.Logger) int {
+	keyCipher, err := keyCipherFromConfig(cfg)
+	if err != nil {
+		logger.Error("invalid key encryption key", zap.Error(err))
+		return 1
+	}
+	if keyCipher == nil {
+		logger.Error("--migrate-keys needs database.key_encryption_key (DB_KEY_ENCRYPTION_KEY)")
+		return 1
+	}
+	database, err := openDatabase(cfg)
+	if err != nil {
+		logger.Error("failed to open database", zap.Error(err))
+		return 1
+	}
+	defer database.Close()
+
+	count, err := database.MigratePrivateKeysWith(keyCipher)
+	if err != nil {
+		logger.Error("failed to encrypt private keys, nothing was changed", zap.Error(err))
+		return 1
+	}
+	fmt.Printf("Encrypted %d guest wallet private key(s)\n", count)
+	return 0
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
+)
+
+// orphanRecoveryInterval is how often wallets orphaned by a crash are
+// looked for.
+const orphanRecoveryInterval = 7 * 24 * time.Hour
+
+// walletBalanceChecker adapts checkWalletBalance to db.BalanceChecker.
+type walletBalanceChecker struct {
+	server *Server
+}
+
+// CheckBalance returns the address's on-chain balance in shannons.
+func (c walletBalanceChecker) CheckBalance(ctx context.Context, address string) (int64, error) {
+	return c.server.checkWalletBalance(ctx, address)
+}
+
+// startOrphanRecovery runs recoverOrphanedWallets once a week.
+func (s *Server) startOrphanRecovery(ctx context.Context) {
+	ticker := time.NewTicker(orphanRecoveryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.recoverOrphanedWallets(ctx)
+		}
+	}
+}
+
+// recoverOrphanedWallets reclaims wallets left in channel_open by a crash
+// and force-settles every orphaned_recovery session: its channel is settled
+// first, then the wallet is refunded to the sender. Sessions whose channel
+// can't be settled or whose refund fails stay queued for the next run.
+func (s *Server) recoverOrphanedWallets(ctx context.Context) {
+	reclaimed, err := s.db.ReclaimOrphanedWallets(ctx, walletBalanceChecker{server: s}, s.sessionLive)
+	if err != nil {
+		s.logger.Error("orphaned wallet check incomplete", zap.Error(err))
+	}
+	if reclaimed > 0 {
+		s.logger.Warn("reclaimed orphaned wallets", zap.Int("count", reclaimed))
+	}
+
+	sessions, err := s.db.ListSessions(db.SessionStatusOrphanedRecovery)
+	if err != nil {
+		s.logger.Error("failed to list orphaned sessions", zap.Error(err))
+		return
+	}
+	for _, session := range sessions {
+		if ctx.Err() != nil {
+			return
+		}
+		s.logger.Warn("force-settling orphaned wallet",
+			zap.String("session_id", session.ID),
+			zap.String("wallet_id", session.WalletID),
+			zap.Int64("balance_ckb", session.BalanceCKB),
+		)
+		if err := s.settleOrphanedChannel(ctx, session.ChannelID); err != nil {
+			s.logger.Error("failed to settle orphaned channel",
+				zap.String("session_id", session.ID),
+				zap.String("channel_id", session.ChannelID.String()),
+				zap.Error(err),
+			)
+			continue
+		}
+		txHash, err := s.withdrawToSender(ctx, session.ID)
+		if err != nil {
+			s.logger.Error("failed to refund orphaned wallet",
+				zap.String("session_id", session.ID),
+				zap.Error(err),
+			)
+			continue
+		}
+		if err := s.db.SettleSession(session.ID); err != nil {
+			s.logger.Error("failed to settle orphaned session", zap.String("session_id", session.ID), zap.Error(err))
+			continue
+		}
+		s.logger.Info("orphaned wallet recovered",
+			zap.String("session_id", session.ID),
+			zap.String("tx_hash", txHash),
+		)
+	}
+}
+
+// sessionLive reports whether sessionID is held in memory.
+func (s *Server) sessionLive(sessionID string) bool {
+	s.sessionsMu.RLock()
+	defer s.sessionsMu.RUnlock()
+	_, ok := s.sessions[sessionID]
+	return ok
+}
+
+// settleOrphanedChannel makes sure the channel of an orphaned session is
+// closed on-chain so its funds are back in the wallet before the refund.
+// Channels still held by the host are settled; any other channel that is
+// still open is an error, as the refund would miss its funds.
+func (s *Server) settleOrphanedChannel(ctx context.Context, channelID perun.ChannelID) error {
+	if channelID == (perun.ChannelID{}) || s.dryRun != nil {
+		return nil
+	}
+	settled, err := s.hostClient.ChannelSettledOnChain(ctx, channelID)
+	if err != nil {
+		return err
+	}
+	if settled {
+		return nil
+	}
+	if err := s.hostClient.SettleChannelByID(ctx, channelID); err != nil {
+		return fmt.Errorf("channel still open on-chain: %w", err)
+	}
+	s.audit(systemActor, auditChannelClosed, "", "", "channel_id="+channelID.String())
+	return nil
+}
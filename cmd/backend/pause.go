@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/airfi/airfi-perun-nervous/internal/auth"
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
+)
+
+// handlePauseSession stops a session's micropayments and WiFi access. The
+// host rejects any update on its channel until the session is resumed.
+// Pausing doesn't stop the expiry clock and isn't kept across restarts.
+func (s *Server) handlePauseSession(c *gin.Context) {
+	sessionID := c.Param("sessionId")
+
+	if !s.authorizeSessionScope(c, sessionID, auth.ScopeSettle) {
+		return
+	}
+	if s.updateHandlerSetter == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "host channel client not available"})
+		return
+	}
+	dbSession, err := s.db.GetSession(sessionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return
+	}
+
+	s.sessionsMu.Lock()
+	session, exists := s.sessions[sessionID]
+	if !exists {
+		s.sessionsMu.Unlock()
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found or channel not active"})
+		return
+	}
+	if session.Paused {
+		s.sessionsMu.Unlock()
+		c.JSON(http.StatusConflict, gin.H{"error": "session is already paused"})
+		return
+	}
+	session.Paused = true
+	s.sessionsMu.Unlock()
+
+	s.updateHandlerSetter(dbSession.ChannelID, &perun.PausedSessionHandler{Logger: s.logger.Named("paused-session")})
+
+	if dbSession.MACAddress != "" {
+		if err := s.router.DeauthorizeMAC(c.Request.Context(), dbSession.MACAddress); err != nil {
+			s.logger.Error("failed to deauthorize MAC for paused session", zap.String("session_id", sessionID), zap.Error(err))
+		}
+	}
+
+	s.logger.Info("session paused", zap.String("session_id", sessionID))
+	c.JSON(http.StatusOK, gin.H{
+		"session_id": sessionID,
+		"status":     "paused",
+	})
+}
+
+// handleResumeSession undoes handlePauseSession. Micropayments restart from
+// now, so the paused time isn't billed.
+func (s *Server) handleResumeSession(c *gin.Context) {
+	sessionID := c.Param("sessionId")
+
+	if !s.authorizeSessionScope(c, sessionID, auth.ScopeSettle) {
+		return
+	}
+	if s.updateHandlerSetter == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "host channel client not available"})
+		return
+	}
+	dbSession, err := s.db.GetSession(sessionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return
+	}
+
+	s.sessionsMu.Lock()
+	session, exists := s.sessions[sessionID]
+	if !exists {
+		s.sessionsMu.Unlock()
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found or channel not active"})
+		return
+	}
+	if !session.Paused {
+		s.sessionsMu.Unlock()
+		c.JSON(http.StatusConflict, gin.H{"error": "session is not paused"})
+		return
+	}
+	session.Paused = false
+	session.LastPaymentAt = time.Now()
+	s.sessionsMu.Unlock()
+
+	s.updateHandlerSetter(dbSession.ChannelID, nil)
+
+	if dbSession.MACAddress != "" {
+		var accessPoint string
+		if wallet, err := s.db.GetGuestWallet(dbSession.WalletID); err == nil {
+			accessPoint = wallet.AccessPoint
+		}
+		comment := fmt.Sprintf("AirFi session (resumed): %s", sessionID)
+		if err := s.router.AuthorizeMAC(c.Request.Context(), dbSession.MACAddress, dbSession.IPAddress, comment, accessPoint); err != nil {
+			s.logger.Error("failed to authorize MAC for resumed session", zap.String("session_id", sessionID), zap.Error(err))
+		}
+	}
+
+	s.logger.Info("session resumed", zap.String("session_id", sessionID))
+	c.JSON(http.StatusOK, gin.H{
+		"session_id": sessionID,
+		"status":     "active",
+	})
+}
+
+// unpauseChannel lets the host accept updates on a detached session's
+// channel again, so the final state can be signed when it is settled.
+func (s *Server) unpauseChannel(session *GuestSession) {
+	if !session.Paused || s.updateHandlerSetter == nil {
+		return
+	}
+	s.updateHandlerSetter(session.ChannelID, nil)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
+	"github.com/airfi/airfi-perun-nervous/internal/router"
+)
+
+func TestHandlePauseSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+	s.router = &router.NoopRouter{}
+
+	channelID := perun.ChannelID{0xab}
+	handlers := map[perun.ChannelID]perun.UpdateHandler{}
+	s.updateHandlerSetter = func(id perun.ChannelID, handler perun.UpdateHandler) {
+		if handler == nil {
+			delete(handlers, id)
+			return
+		}
+		handlers[id] = handler
+	}
+
+	s.db.CreateSession(&db.Session{
+		ID:        "sess-pause",
+		WalletID:  "wallet-pause",
+		ChannelID: channelID,
+		Status:    "active",
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	s.sessions["sess-pause"] = &GuestSession{ID: "sess-pause", ExpiresAt: time.Now().Add(time.Hour)}
+
+	r := gin.New()
+	r.POST("/api/v1/sessions/:sessionId/pause", s.handlePauseSession)
+	r.POST("/api/v1/sessions/:sessionId/resume", s.handleResumeSession)
+	post := func(path string) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, hostRequest(t, s, http.MethodPost, path, nil))
+		return w.Code
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/sessions/sess-pause/pause", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Pause without credentials: expected 401, got %d", w.Code)
+	}
+
+	if code := post("/api/v1/sessions/sess-pause/resume"); code != http.StatusConflict {
+		t.Errorf("Resume before pause: expected 409, got %d", code)
+	}
+	if code := post("/api/v1/sessions/sess-pause/pause"); code != http.StatusOK {
+		t.Fatalf("Pause: expected 200, got %d", code)
+	}
+	if code := post("/api/v1/sessions/sess-pause/pause"); code != http.StatusConflict {
+		t.Errorf("Second pause: expected 409, got %d", code)
+	}
+
+	// Updates on the paused session's channel are rejected
+	handler, ok := handlers[channelID].(*perun.PausedSessionHandler)
+	if !ok {
+		t.Fatalf("Expected a PausedSessionHandler for the channel, got %T", handlers[channelID])
+	}
+	if err := handler.ValidateUpdate(nil, nil); !errors.Is(err, perun.ErrSessionPaused) {
+		t.Errorf("Expected updates rejected with ErrSessionPaused, got %v", err)
+	}
+	if !s.sessions["sess-pause"].Paused {
+		t.Error("Expected the session to be marked paused")
+	}
+
+	if code := post("/api/v1/sessions/sess-pause/resume"); code != http.StatusOK {
+		t.Fatalf("Resume: expected 200, got %d", code)
+	}
+	if _, ok := handlers[channelID]; ok || s.sessions["sess-pause"].Paused {
+		t.Error("Expected the update handler removed and the session active after resume")
+	}
+
+	if code := post("/api/v1/sessions/missing/pause"); code != http.StatusNotFound {
+		t.Errorf("Unknown session: expected 404, got %d", code)
+	}
+}
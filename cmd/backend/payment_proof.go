@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/airfi/airfi-perun-nervous/internal/auth"
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
+)
+
+// handleGetPaymentProof returns a signed proof of what the session has paid
+// so far, for the guest to show to a third-party verifier.
+func (s *Server) handleGetPaymentProof(c *gin.Context) {
+	sessionID := c.Param("sessionId")
+
+	if !s.authorizeSessionScope(c, sessionID, auth.ScopeRead) {
+		return
+	}
+
+	s.sessionsMu.RLock()
+	session, exists := s.sessions[sessionID]
+	s.sessionsMu.RUnlock()
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found or not active"})
+		return
+	}
+
+	if s.dryRun != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "payment proofs are not available in dry-run mode"})
+		return
+	}
+
+	proof, err := session.Client.GeneratePaymentProof(session.Channel, session.TotalPaid)
+	if err != nil {
+		s.logger.Error("failed to generate payment proof", zap.String("session_id", sessionID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate payment proof"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id": sessionID,
+		"proof":      proof,
+	})
+}
+
+// handleVerifyPayment checks a payment proof for an active session. It's
+// public so external captive portals can verify payment independently.
+func (s *Server) handleVerifyPayment(c *gin.Context) {
+	var req struct {
+		SessionID string          `json:"session_id" binding:"required"`
+		ProofJSON json.RawMessage `json:"proof_json" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	proof, err := parsePaymentProof(req.ProofJSON)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid proof_json"})
+		return
+	}
+	payment, err := proof.PaymentAmount()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.sessionsMu.RLock()
+	session, exists := s.sessions[req.SessionID]
+	s.sessionsMu.RUnlock()
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found or not active"})
+		return
+	}
+
+	if s.dryRun != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "payment proofs are not available in dry-run mode"})
+		return
+	}
+
+	if err := session.Client.VerifyPaymentProof(session.Channel, payment, proof); err != nil {
+		if !errors.Is(err, perun.ErrInvalidPaymentProof) {
+			s.logger.Error("failed to verify payment proof", zap.String("session_id", req.SessionID), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify payment proof"})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"valid": false,
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"valid":      true,
+		"amount_ckb": new(big.Int).Div(payment, big.NewInt(100000000)).Int64(),
+	})
+}
+
+// parsePaymentProof decodes proof_json given either as an object or as a
+// JSON-encoded string.
+func parsePaymentProof(raw json.RawMessage) (*perun.PaymentProof, error) {
+	var encoded string
+	if err := json.Unmarshal(raw, &encoded); err == nil {
+		raw = json.RawMessage(encoded)
+	}
+
+	var proof perun.PaymentProof
+	if err := json.Unmarshal(raw, &proof); err != nil {
+		return nil, err
+	}
+	return &proof, nil
+}
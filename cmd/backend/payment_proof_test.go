@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParsePaymentProof(t *testing.T) {
+	object := `{"channel_id":"ab","version":3,"payment":"100","signature":"cd"}`
+	for _, raw := range []string{object, `"` + strings.ReplaceAll(object, `"`, `\"`) + `"`} {
+		proof, err := parsePaymentProof([]byte(raw))
+		if err != nil {
+			t.Fatalf("parsePaymentProof(%s) failed: %v", raw, err)
+		}
+		if proof.ChannelID != "ab" || proof.Version != 3 || proof.Payment != "100" || proof.Signature != "cd" {
+			t.Errorf("unexpected proof: %+v", proof)
+		}
+	}
+
+	if _, err := parsePaymentProof([]byte(`"not json"`)); err == nil {
+		t.Error("expected error for malformed proof")
+	}
+}
+
+func TestHandleVerifyPayment_BadRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+
+	r := gin.New()
+	r.POST("/api/v1/verify-payment", s.handleVerifyPayment)
+
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"missing fields", `{}`, http.StatusBadRequest},
+		{"malformed proof", `{"session_id":"s1","proof_json":[1]}`, http.StatusBadRequest},
+		{"malformed payment", `{"session_id":"s1","proof_json":{"payment":"abc"}}`, http.StatusBadRequest},
+		{"unknown session", `{"session_id":"s1","proof_json":{"payment":"100"}}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/verify-payment", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		if w.Code != tt.code {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.code, w.Code, w.Body.String())
+		}
+	}
+}
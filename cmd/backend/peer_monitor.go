@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+	"github.com/airfi/airfi-perun-nervous/internal/router"
+)
+
+const (
+	// peerHeartbeatInterval is how often each session's guest device is
+	// checked for.
+	peerHeartbeatInterval = 30 * time.Second
+	// peerOfflineThreshold is how many checks in a row the device may miss
+	// before the session is settled.
+	peerOfflineThreshold = 3
+)
+
+// errDeviceOffline is returned by devicePresent when nothing has been heard
+// from the guest's device.
+var errDeviceOffline = errors.New("guest device offline")
+
+// monitorPeer checks for the session's guest device until the session ends,
+// and settles the session once the device has missed peerOfflineThreshold
+// checks in a row.
+func (s *Server) monitorPeer(ctx context.Context, session *GuestSession) {
+	ticker := time.NewTicker(peerHeartbeatInterval)
+	defer ticker.Stop()
+
+	missed := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.sessionsMu.RLock()
+			current, active := s.sessions[session.ID]
+			paused := active && current.Paused
+			s.sessionsMu.RUnlock()
+			if !active {
+				return
+			}
+			// A paused guest may well leave, so there is nothing to learn
+			// until the session resumes
+			if paused {
+				continue
+			}
+
+			var detached bool
+			missed, detached = s.recordPeerHeartbeat(session.ID, s.peerHeartbeat(ctx, session), missed, time.Now())
+			if detached {
+				s.settleExpiredSession(ctx, session)
+				return
+			}
+		}
+	}
+}
+
+// devicePresent checks for a sign of life from the session's guest device.
+// The guest's channel peer runs inside this process, so the channel itself
+// says nothing about the device. Instead a keep-alive from the session page
+// within maxPauseDuration counts, so a sleeping device isn't cut off, and
+// failing that the router still seeing the device connected. Sessions with neither signal, e.g. a
+// device without the portal open and no router configured, can't be judged
+// and count as present.
+func (s *Server) devicePresent(ctx context.Context, session *GuestSession) error {
+	dbSession, err := s.db.GetSession(session.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load session: %w", err)
+	}
+	if dbSession.LastHeartbeatAt != nil && time.Since(*dbSession.LastHeartbeatAt) < maxPauseDuration {
+		return nil
+	}
+
+	if _, noop := s.router.(*router.NoopRouter); noop || dbSession.MACAddress == "" {
+		if dbSession.LastHeartbeatAt != nil {
+			return errDeviceOffline
+		}
+		return nil
+	}
+	_, err = s.router.GetClientInfo(ctx, dbSession.MACAddress)
+	if errors.Is(err, router.ErrClientNotFound) {
+		return errDeviceOffline
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up client: %w", err)
+	}
+	return nil
+}
+
+// recordPeerHeartbeat stores the outcome of a device check made at now,
+// given missed checks before it. It returns the new count of missed checks
+// and whether the session was detached for settlement because the device
+// is offline. Errors other than errDeviceOffline mean the check itself
+// failed, e.g. the router was unreachable, and leave the count unchanged.
+func (s *Server) recordPeerHeartbeat(sessionID string, err error, missed int, now time.Time) (int, bool) {
+	switch {
+	case err == nil:
+		if err := s.db.RecordPeerHeartbeat(sessionID, now); err != nil {
+			s.logger.Warn("failed to record peer heartbeat", zap.String("session_id", sessionID), zap.Error(err))
+		}
+		return 0, false
+	case !errors.Is(err, errDeviceOffline):
+		s.logger.Warn("device check failed", zap.String("session_id", sessionID), zap.Error(err))
+		return missed, false
+	}
+
+	missed++
+	s.logger.Warn("guest device missed heartbeat",
+		zap.String("session_id", sessionID),
+		zap.Int("missed", missed),
+	)
+	if err := s.db.MarkPeerOffline(sessionID, now); err != nil {
+		s.logger.Warn("failed to record offline peer", zap.String("session_id", sessionID), zap.Error(err))
+	}
+	if missed < peerOfflineThreshold {
+		return missed, false
+	}
+
+	s.sessionsMu.Lock()
+	_, exists := s.sessions[sessionID]
+	delete(s.sessions, sessionID)
+	s.sessionsMu.Unlock()
+	if exists {
+		s.storeSessionEnded(sessionID, false)
+		s.logger.Info("guest device offline, settling channel", zap.String("session_id", sessionID))
+	}
+	return missed, exists
+}
+
+// peerStatus reports whether the session's guest device was seen at the
+// latest check.
+func peerStatus(session *db.Session) string {
+	if session.PeerOfflineSince != nil {
+		return "offline"
+	}
+	return "online"
+}
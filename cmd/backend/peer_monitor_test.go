@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+	"github.com/airfi/airfi-perun-nervous/internal/router"
+)
+
+func TestRecordPeerHeartbeat(t *testing.T) {
+	s := newTestServer(t)
+	s.db.CreateSession(&db.Session{ID: "sess-peer", WalletID: "wallet-peer", Status: "active"})
+	session := &GuestSession{ID: "sess-peer"}
+	s.sessions[session.ID] = session
+
+	offline := fmt.Errorf("check: %w", errDeviceOffline)
+	start := time.Now().Truncate(time.Second)
+
+	// Two misses mark the peer offline without settling
+	missed, detached := s.recordPeerHeartbeat(session.ID, offline, 0, start)
+	missed, detached = s.recordPeerHeartbeat(session.ID, offline, missed, start.Add(30*time.Second))
+	if missed != 2 || detached {
+		t.Fatalf("Expected 2 misses and no settlement, got %d, %v", missed, detached)
+	}
+	dbSession, _ := s.db.GetSession(session.ID)
+	if peerStatus(dbSession) != "offline" || !dbSession.PeerOfflineSince.Equal(start) {
+		t.Errorf("Expected peer offline since %v, got %v", start, dbSession.PeerOfflineSince)
+	}
+
+	// A failed check is not a miss
+	missed, detached = s.recordPeerHeartbeat(session.ID, errors.New("router unreachable"), missed, start.Add(time.Minute))
+	if missed != 2 || detached {
+		t.Errorf("Expected the miss count kept, got %d, %v", missed, detached)
+	}
+
+	// An answered heartbeat resets the count and the offline period
+	answered := start.Add(90 * time.Second)
+	missed, _ = s.recordPeerHeartbeat(session.ID, nil, missed, answered)
+	if missed != 0 {
+		t.Errorf("Expected miss count reset, got %d", missed)
+	}
+	dbSession, _ = s.db.GetSession(session.ID)
+	if peerStatus(dbSession) != "online" || !dbSession.LastHeartbeatSuccess.Equal(answered) {
+		t.Errorf("Expected peer online, answered at %v, got %+v", answered, dbSession)
+	}
+
+	// Three misses in a row detach the session for settlement, once
+	for i := 0; i < peerOfflineThreshold; i++ {
+		missed, detached = s.recordPeerHeartbeat(session.ID, offline, missed, answered.Add(time.Duration(i+1)*30*time.Second))
+	}
+	if !detached {
+		t.Fatal("Expected the session to be detached after 3 misses")
+	}
+	if _, exists := s.sessions[session.ID]; exists {
+		t.Error("Expected the session removed from the active sessions")
+	}
+	if _, detached = s.recordPeerHeartbeat(session.ID, offline, missed, answered.Add(2*time.Minute)); detached {
+		t.Error("Expected a detached session not to be settled twice")
+	}
+}
+
+func TestDevicePresent(t *testing.T) {
+	s := newTestServer(t)
+	s.router = &router.NoopRouter{}
+	ctx := context.Background()
+	session := &GuestSession{ID: "sess-device"}
+	s.db.CreateSession(&db.Session{ID: session.ID, WalletID: "wallet-device", Status: "active", MACAddress: "aa:bb:cc:dd:ee:ff"})
+
+	// Nothing to go by yet
+	if err := s.devicePresent(ctx, session); err != nil {
+		t.Errorf("Expected a session without signals to count as present, got %v", err)
+	}
+
+	s.db.UpdateSessionHeartbeat(session.ID, time.Now())
+	if err := s.devicePresent(ctx, session); err != nil {
+		t.Errorf("Expected a recent keep-alive to count, got %v", err)
+	}
+
+	s.db.UpdateSessionHeartbeat(session.ID, time.Now().Add(-2*maxPauseDuration))
+	if err := s.devicePresent(ctx, session); !errors.Is(err, errDeviceOffline) {
+		t.Errorf("Expected a silent page to be offline, got %v", err)
+	}
+
+	// The router still seeing the device keeps it online
+	s.router = &stubRouter{mac: "aa:bb:cc:dd:ee:ff", info: &router.ClientInfo{MAC: "aa:bb:cc:dd:ee:ff"}}
+	if err := s.devicePresent(ctx, session); err != nil {
+		t.Errorf("Expected a connected device to count as present, got %v", err)
+	}
+	s.router = &stubRouter{mac: "11:22:33:44:55:66"}
+	if err := s.devicePresent(ctx, session); !errors.Is(err, errDeviceOffline) {
+		t.Errorf("Expected a device gone from the router to be offline, got %v", err)
+	}
+}
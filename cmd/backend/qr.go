@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/skip2/go-qrcode"
+
+	"github.com/airfi/airfi-perun-nervous/internal/auth"
+)
+
+const (
+	// defaultQRSize is the default QR code image size in pixels.
+	defaultQRSize = 256
+	// maxQRSize is the largest QR code image the API will render.
+	maxQRSize = 512
+)
+
+// fundingURI formats a CKB payment URI for wallets that scan QR codes.
+func fundingURI(address string, shannons int64) string {
+	return fmt.Sprintf("ckb:%s?amount=%d", address, shannons)
+}
+
+// parseQRSize reads the size query param, clamped to maxQRSize.
+func parseQRSize(c *gin.Context) (int, error) {
+	sizeStr := c.Query("size")
+	if sizeStr == "" {
+		return defaultQRSize, nil
+	}
+	size, err := strconv.Atoi(sizeStr)
+	if err != nil || size <= 0 {
+		return 0, fmt.Errorf("invalid size")
+	}
+	if size > maxQRSize {
+		size = maxQRSize
+	}
+	return size, nil
+}
+
+// handleSessionQR returns a PNG QR code encoding the session wallet's funding URI.
+func (s *Server) handleSessionQR(c *gin.Context) {
+	sessionID := c.Param("sessionId")
+
+	size, err := parseQRSize(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, err := s.db.GetSession(sessionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return
+	}
+
+	wallet, err := s.db.GetGuestWallet(session.WalletID)
+	if err != nil {
+		wallet, err = s.db.GetWalletBySessionID(sessionID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "wallet not found for session"})
+			return
+		}
+	}
+
+	requiredCKB := wallet.FundingCKB
+	if requiredCKB <= 0 {
+		requiredCKB = s.getMinimumFunding()
+	}
+
+	png, err := qrcode.Encode(fundingURI(wallet.Address, requiredCKB*100000000), qrcode.Medium, size)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate QR code"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "image/png", png)
+}
+
+// connectURL returns the captive portal URL guests open to connect. The url
+// query param overrides it for portals served from a custom domain; the
+// caller checks the request may set it.
+func connectURL(c *gin.Context) (string, error) {
+	if custom := c.Query("url"); custom != "" {
+		u, err := url.Parse(custom)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return "", fmt.Errorf("invalid url")
+		}
+		return custom, nil
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/connect", scheme, c.Request.Host), nil
+}
+
+// handleConnectQR returns a PNG QR code encoding the captive portal's connect
+// URL, for printing and posting where guests can scan it. Only the host may
+// override the URL, so the public route can't be used to mint QR codes for
+// arbitrary sites.
+func (s *Server) handleConnectQR(c *gin.Context) {
+	size, err := parseQRSize(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if c.Query("url") != "" && !s.authorizeDashboard(c, auth.APIKeyScopeRead) {
+		return
+	}
+
+	target, err := connectURL(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	png, err := qrcode.Encode(target, qrcode.Medium, size)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate QR code"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "image/png", png)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/airfi/airfi-perun-nervous/internal/auth"
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+)
+
+// newTestServer creates a server backed by a temporary database.
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	database, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	s, err := NewServer(&ServerConfig{
+		DB:              database,
+		Logger:          zap.NewNop(),
+		RatePerHour:     500,
+		ChannelSetupCKB: 1000,
+	})
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	return s
+}
+
+func TestHandleSessionQR(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+
+	s.db.CreateGuestWallet(&db.GuestWallet{
+		ID:         "wallet-1",
+		Address:    "ckt1qzda0cr08m85hc8jlnfp3zer7xulejywt49kt2rr0vthywaa50xwsqdeadbeef",
+		FundingCKB: 1500,
+		Status:     "created",
+		CreatedAt:  time.Now(),
+	})
+	s.db.CreateSession(&db.Session{
+		ID:        "session-1",
+		WalletID:  "wallet-1",
+		Status:    "pending_funding",
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+
+	r := gin.New()
+	r.GET("/api/v1/sessions/:sessionId/qr", s.handleSessionQR)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/sessions/session-1/qr?size=128", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "image/png" {
+		t.Errorf("Content-Type: expected image/png, got %s", ct)
+	}
+	pngMagic := []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'}
+	if !bytes.HasPrefix(w.Body.Bytes(), pngMagic) {
+		t.Error("Body is not a PNG image")
+	}
+}
+
+func TestHandleSessionQR_Errors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+
+	r := gin.New()
+	r.GET("/api/v1/sessions/:sessionId/qr", s.handleSessionQR)
+
+	tests := []struct {
+		name string
+		url  string
+		code int
+	}{
+		{"unknown session", "/api/v1/sessions/missing/qr", http.StatusNotFound},
+		{"invalid size", "/api/v1/sessions/missing/qr?size=abc", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			if w.Code != tt.code {
+				t.Errorf("Expected %d, got %d", tt.code, w.Code)
+			}
+		})
+	}
+}
+
+func TestHandleConnectQR(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+
+	r := gin.New()
+	r.GET("/api/v1/qr.png", s.handleConnectQR)
+
+	key, _, err := s.apiKeys.Generate("printer", []string{auth.APIKeyScopeRead})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		url  string
+		key  string
+		code int
+	}{
+		{"default url", "/api/v1/qr.png?size=512", "", http.StatusOK},
+		{"custom url", "/api/v1/qr.png?url=https://wifi.example.com/connect", key, http.StatusOK},
+		{"custom url without credentials", "/api/v1/qr.png?url=https://wifi.example.com/connect", "", http.StatusUnauthorized},
+		{"invalid url", "/api/v1/qr.png?url=not-a-url", key, http.StatusBadRequest},
+		{"invalid size", "/api/v1/qr.png?size=0", "", http.StatusBadRequest},
+	}
+
+	pngMagic := []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.key != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.key)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.code {
+				t.Fatalf("Expected %d, got %d: %s", tt.code, w.Code, w.Body.String())
+			}
+			if tt.code == http.StatusOK && !bytes.HasPrefix(w.Body.Bytes(), pngMagic) {
+				t.Error("Body is not a PNG image")
+			}
+		})
+	}
+}
+
+func TestFundingURI(t *testing.T) {
+	uri := fundingURI("ckt1abc", 150000000000)
+	if uri != "ckb:ckt1abc?amount=150000000000" {
+		t.Errorf("Unexpected URI: %s", uri)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/gin-gonic/gin"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/crypto/blake2b"
+	"go.uber.org/zap"
+
+	"github.com/airfi/airfi-perun-nervous/internal/auth"
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+)
+
+// sessionReceipt is the body of a guest receipt. Its JSON encoding is what
+// the receipt hash covers, so field order must not change.
+type sessionReceipt struct {
+	SessionID             string           `json:"session_id"`
+	StartTime             time.Time        `json:"start_time"`
+	EndTime               time.Time        `json:"end_time"`
+	TotalDurationSeconds  int64            `json:"total_duration_seconds"`
+	BilledDurationSeconds int64            `json:"billed_duration_seconds"`
+	TotalCKBSpent         int64            `json:"total_ckb_spent"`
+	Payments              []receiptPayment `json:"payments"`
+	ChannelID             string           `json:"channel_id"`
+	GuestAddress          string           `json:"guest_address"`
+	HostAddress           string           `json:"host_address"`
+}
+
+// receiptPayment is one payment listed on a receipt.
+type receiptPayment struct {
+	AmountShannons int64     `json:"amount_shannons"`
+	Kind           string    `json:"kind"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// signedReceipt is a receipt with its BLAKE2b hash and the host's signature
+// of that hash, so a guest can prove the host issued it.
+type signedReceipt struct {
+	sessionReceipt
+	ReceiptHash      string `json:"receipt_hash"`
+	ReceiptSignature string `json:"receipt_signature,omitempty"`
+	HostPublicKey    string `json:"host_public_key,omitempty"`
+}
+
+// newSessionReceipt converts a payment summary into a receipt body.
+func newSessionReceipt(summary *db.PaymentSummary) sessionReceipt {
+	payments := make([]receiptPayment, len(summary.Payments))
+	for i, p := range summary.Payments {
+		payments[i] = receiptPayment{
+			AmountShannons: p.AmountShannons,
+			Kind:           p.Kind,
+			CreatedAt:      p.CreatedAt.UTC(),
+		}
+	}
+	return sessionReceipt{
+		SessionID:             summary.SessionID,
+		StartTime:             summary.StartTime.UTC(),
+		EndTime:               summary.EndTime.UTC(),
+		TotalDurationSeconds:  int64(summary.TotalDuration.Seconds()),
+		BilledDurationSeconds: int64(summary.BilledDuration.Seconds()),
+		TotalCKBSpent:         summary.TotalCKBSpent,
+		Payments:              payments,
+		ChannelID:             summary.ChannelID,
+		GuestAddress:          summary.GuestAddress,
+		HostAddress:           summary.HostAddress,
+	}
+}
+
+// signReceipt hashes the receipt's JSON encoding with BLAKE2b and signs the
+// hash with the host key. The receipt is left unsigned when the server has
+// no host key.
+func (s *Server) signReceipt(receipt sessionReceipt) (*signedReceipt, error) {
+	body, err := json.Marshal(receipt)
+	if err != nil {
+		return nil, err
+	}
+	hash := blake2b.Blake256(body)
+
+	signed := &signedReceipt{
+		sessionReceipt: receipt,
+		ReceiptHash:    "0x" + hex.EncodeToString(hash),
+	}
+	if s.hostPrivKey != nil {
+		sig := ecdsa.SignCompact(s.hostPrivKey, hash, true)
+		signed.ReceiptSignature = "0x" + hex.EncodeToString(sig)
+		signed.HostPublicKey = "0x" + hex.EncodeToString(s.hostPrivKey.PubKey().SerializeCompressed())
+	}
+	return signed, nil
+}
+
+// recordPayment adds a channel payment to the session's payment history.
+// A failure is only logged: the payment itself already went through.
+func (s *Server) recordPayment(sessionID, kind string, amount *big.Int) {
+	err := s.db.RecordPayment(&db.PaymentRecord{
+		SessionID:      sessionID,
+		AmountShannons: amount.Int64(),
+		Kind:           kind,
+	})
+	if err != nil {
+		s.logger.Warn("failed to record payment",
+			zap.String("session_id", sessionID),
+			zap.String("kind", kind),
+			zap.Error(err),
+		)
+	}
+}
+
+// handleSessionReceipt returns a signed receipt of what the guest paid for
+// a session, as a JSON file download.
+func (s *Server) handleSessionReceipt(c *gin.Context) {
+	sessionID := c.Param("sessionId")
+
+	if !s.authorizeSessionScope(c, sessionID, auth.ScopeRead) {
+		return
+	}
+
+	summary, err := s.db.GetSessionPaymentSummary(sessionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return
+	}
+	if err != nil {
+		s.logger.Error("failed to get payment summary", zap.String("session_id", sessionID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build receipt"})
+		return
+	}
+
+	receipt, err := s.signReceipt(newSessionReceipt(summary))
+	if err != nil {
+		s.logger.Error("failed to sign receipt", zap.String("session_id", sessionID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build receipt"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=receipt-%s.json", sessionID))
+	c.JSON(http.StatusOK, receipt)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/gin-gonic/gin"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/crypto/blake2b"
+
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+)
+
+func TestHandleSessionReceipt(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+	hostKey, _ := secp256k1.GeneratePrivateKey()
+	s.hostPrivKey = hostKey
+
+	start := time.Now().Add(-10 * time.Minute)
+	s.db.CreateSession(&db.Session{
+		ID:           "session-1",
+		WalletID:     "wallet-1",
+		GuestAddress: "ckt1guest",
+		SpentCKB:     10,
+		CreatedAt:    start,
+		ExpiresAt:    start.Add(60 * time.Minute),
+		Status:       "active",
+	})
+	s.recordPayment("session-1", db.PaymentMicropayment, s.ratePerMin)
+
+	r := gin.New()
+	r.GET("/api/v1/sessions/:sessionId/receipt", s.handleSessionReceipt)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, hostRequest(t, s, http.MethodGet, "/api/v1/sessions/missing/receipt", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown session, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, hostRequest(t, s, http.MethodGet, "/api/v1/sessions/session-1/receipt", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Disposition"); got != "attachment; filename=receipt-session-1.json" {
+		t.Errorf("Unexpected Content-Disposition %q", got)
+	}
+
+	var receipt signedReceipt
+	if err := json.Unmarshal(w.Body.Bytes(), &receipt); err != nil {
+		t.Fatalf("Failed to parse receipt: %v", err)
+	}
+	if receipt.TotalCKBSpent != 10 || receipt.BilledDurationSeconds != 3600 || len(receipt.Payments) != 1 {
+		t.Errorf("Unexpected receipt: %s", w.Body.String())
+	}
+	if receipt.Payments[0].AmountShannons != s.ratePerMin.Int64() {
+		t.Errorf("Expected one payment of %s shannons, got %d", s.ratePerMin, receipt.Payments[0].AmountShannons)
+	}
+
+	// The hash covers the receipt body and the signature recovers the host key
+	body, _ := json.Marshal(receipt.sessionReceipt)
+	hash := blake2b.Blake256(body)
+	if receipt.ReceiptHash != "0x"+hex.EncodeToString(hash) {
+		t.Errorf("Receipt hash %s doesn't match the body", receipt.ReceiptHash)
+	}
+	sig, _ := hex.DecodeString(strings.TrimPrefix(receipt.ReceiptSignature, "0x"))
+	pub, _, err := ecdsa.RecoverCompact(sig, hash)
+	if err != nil || !pub.IsEqual(hostKey.PubKey()) {
+		t.Errorf("Signature doesn't recover the host key: %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
+)
+
+// reconcileHour is the local hour the nightly session reconciliation runs.
+const reconcileHour = 3
+
+// reconcileTimeout bounds one reconciliation pass.
+const reconcileTimeout = 10 * time.Minute
+
+// errReconcileUnavailable is returned when there is no channel client to
+// query the chain with.
+var errReconcileUnavailable = errors.New("channel client not available")
+
+// nextReconcileAt returns the next reconcileHour o'clock after now.
+func nextReconcileAt(now time.Time) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), reconcileHour, 0, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// startSessionReconciler runs reconcileSessions every night, catching
+// sessions whose channel was settled on-chain without the database noticing.
+func (s *Server) startSessionReconciler(ctx context.Context) {
+	for {
+		timer := time.NewTimer(time.Until(nextReconcileAt(time.Now())))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if !s.reconcileMu.TryLock() {
+			continue
+		}
+		runCtx, cancel := context.WithTimeout(ctx, reconcileTimeout)
+		reconciled, err := s.reconcileSessions(runCtx, systemActor)
+		cancel()
+		s.reconcileMu.Unlock()
+
+		if err != nil {
+			s.logger.Error("session reconciliation incomplete", zap.Error(err))
+		}
+		s.logger.Info("nightly session reconciliation finished", zap.Strings("session_ids", reconciled))
+	}
+}
+
+// handleReconcileSessions marks active sessions whose channel is already
+// settled on-chain as settled. Only one reconciliation may run at a time.
+func (s *Server) handleReconcileSessions(c *gin.Context) {
+	if !s.reconcileMu.TryLock() {
+		c.JSON(http.StatusConflict, gin.H{"error": "reconciliation already in progress"})
+		return
+	}
+	defer s.reconcileMu.Unlock()
+
+	reconciled, err := s.reconcileSessions(c.Request.Context(), s.requestActor(c))
+	if errors.Is(err, errReconcileUnavailable) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+
+	errs := make([]string, 0)
+	if err != nil {
+		errs = append(errs, err.Error())
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"reconciled":  len(reconciled),
+		"session_ids": reconciled,
+		"errors":      errs,
+	})
+}
+
+// reconcileSessions settles active sessions whose channel cell is gone and
+// returns their IDs. Failed checks are reported in the error alongside the
+// sessions that were reconciled.
+func (s *Server) reconcileSessions(ctx context.Context, actor auditActor) ([]string, error) {
+	if s.channelSettled == nil {
+		return nil, errReconcileUnavailable
+	}
+
+	stale, checkErr := s.db.GetSessionsWithStaleStatus(ctx, func(channelID string) (bool, error) {
+		id, err := perun.ParseChannelID(channelID)
+		if err != nil {
+			return false, err
+		}
+		return s.channelSettled(ctx, id)
+	})
+
+	reconciled := make([]string, 0, len(stale))
+	errs := []error{checkErr}
+	for _, dbSession := range stale {
+		if err := s.reconcileSession(ctx, dbSession, actor); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		reconciled = append(reconciled, dbSession.ID)
+	}
+	return reconciled, errors.Join(errs...)
+}
+
+// reconcileSession records a session as settled after its channel was
+// closed on-chain, dropping its in-memory channel and WiFi access.
+func (s *Server) reconcileSession(ctx context.Context, dbSession *db.Session, actor auditActor) error {
+	s.sessionsMu.Lock()
+	session, exists := s.sessions[dbSession.ID]
+	delete(s.sessions, dbSession.ID)
+	s.sessionsMu.Unlock()
+	s.storeSessionEnded(dbSession.ID, false)
+	if exists {
+		// The channel is still tracked, so the client is closed, not pooled
+		s.guestClients.Put(session.Client)
+	}
+
+	if err := s.db.SettleSession(dbSession.ID); err != nil {
+		return fmt.Errorf("failed to settle session %s: %w", dbSession.ID, err)
+	}
+	s.logger.Warn("session reconciled: channel already settled on-chain",
+		zap.String("session_id", dbSession.ID),
+		zap.String("channel_id", dbSession.ChannelID.String()),
+	)
+	s.audit(actor, auditSessionEnded, dbSession.ID, dbSession.WalletID, "reconciled channel_id="+dbSession.ChannelID.String())
+
+	if dbSession.MACAddress != "" {
+		if err := s.router.DeauthorizeMAC(ctx, dbSession.MACAddress); err != nil {
+			s.logger.Error("failed to deauthorize MAC", zap.Error(err), zap.String("mac", dbSession.MACAddress))
+		} else {
+			s.audit(actor, auditMACDeauthorized, dbSession.ID, dbSession.WalletID, "mac="+dbSession.MACAddress)
+		}
+	}
+	return nil
+}
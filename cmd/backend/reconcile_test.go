@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
+)
+
+func TestHandleReconcileSessions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+
+	settledID := perun.ChannelID{1}
+	openID := perun.ChannelID{2}
+	expires := time.Now().Add(time.Hour)
+	s.db.CreateSession(&db.Session{ID: "session-stale", ChannelID: settledID, Status: "active", ExpiresAt: expires})
+	s.db.CreateSession(&db.Session{ID: "session-open", ChannelID: openID, Status: "active", ExpiresAt: expires})
+	s.sessions["session-stale"] = &GuestSession{ID: "session-stale"}
+
+	s.channelSettled = func(_ context.Context, id perun.ChannelID) (bool, error) {
+		return id == settledID, nil
+	}
+
+	r := gin.New()
+	r.POST("/api/v1/admin/sessions/reconcile", s.handleReconcileSessions)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/admin/sessions/reconcile", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Reconciled int      `json:"reconciled"`
+		SessionIDs []string `json:"session_ids"`
+		Errors     []string `json:"errors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if resp.Reconciled != 1 || len(resp.SessionIDs) != 1 || resp.SessionIDs[0] != "session-stale" {
+		t.Errorf("unexpected summary: %s", w.Body.String())
+	}
+	if len(resp.Errors) != 0 {
+		t.Errorf("unexpected errors: %v", resp.Errors)
+	}
+
+	stale, _ := s.db.GetSession("session-stale")
+	if stale.Status != "settled" || stale.SettledAt == nil {
+		t.Errorf("expected stale session to be settled, got status %q", stale.Status)
+	}
+	open, _ := s.db.GetSession("session-open")
+	if open.Status != "active" {
+		t.Errorf("expected open session to stay active, got %q", open.Status)
+	}
+	if _, ok := s.sessions["session-stale"]; ok {
+		t.Error("expected stale session to be removed from memory")
+	}
+}
+
+func TestHandleReconcileSessions_NoChannelClient(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+
+	r := gin.New()
+	r.POST("/api/v1/admin/sessions/reconcile", s.handleReconcileSessions)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/admin/sessions/reconcile", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestNextReconcileAt(t *testing.T) {
+	loc := time.UTC
+	tests := []struct {
+		now  time.Time
+		want time.Time
+	}{
+		{time.Date(2025, 6, 1, 1, 30, 0, 0, loc), time.Date(2025, 6, 1, 3, 0, 0, 0, loc)},
+		{time.Date(2025, 6, 1, 3, 0, 0, 0, loc), time.Date(2025, 6, 2, 3, 0, 0, 0, loc)},
+		{time.Date(2025, 6, 30, 22, 0, 0, 0, loc), time.Date(2025, 7, 1, 3, 0, 0, 0, loc)},
+	}
+	for _, tt := range tests {
+		if got := nextReconcileAt(tt.now); !got.Equal(tt.want) {
+			t.Errorf("nextReconcileAt(%v) = %v, want %v", tt.now, got, tt.want)
+		}
+	}
+}
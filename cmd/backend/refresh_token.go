@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/airfi/airfi-perun-nervous/internal/auth"
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+)
+
+// errSessionNotActive is returned when refreshing a token for a session
+// that has ended, expired or not yet opened its channel.
+var errSessionNotActive = errors.New("session is not active")
+
+// tokenIssuable reports whether sessions in status may be issued tokens.
+// Opening sessions get them too, so the guest portal can follow the
+// channel opening with the same credential it uses once the session is
+// active.
+func tokenIssuable(status string) bool {
+	return status == "active" || status == "channel_opening"
+}
+
+// issueAccessToken generates an access token for an active session, valid
+// for auth.AccessTokenTTL or until the session expires if that is sooner,
+// and records it on the session for handleLookupSessionByToken.
+func (s *Server) issueAccessToken(dbSession *db.Session) (string, time.Time, error) {
+	ttl := time.Until(dbSession.ExpiresAt)
+	if ttl > auth.AccessTokenTTL {
+		ttl = auth.AccessTokenTTL
+	}
+	token, tokenID, err := s.jwt().GenerateToken(dbSession.ID, dbSession.ChannelID.String(), dbSession.MACAddress, dbSession.IPAddress, ttl)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	if err := s.db.UpdateSessionTokenJTI(dbSession.ID, tokenID); err != nil {
+		s.logger.Warn("failed to record token id", zap.String("session_id", dbSession.ID), zap.Error(err))
+	}
+	s.storeSessionToken(dbSession.ID, token)
+	return token, time.Now().Add(ttl), nil
+}
+
+// refreshAccessToken exchanges a refresh token for a new access token,
+// provided its session is still active.
+func (s *Server) refreshAccessToken(refreshToken string) (string, time.Time, error) {
+	claims, err := s.jwt().ValidateRefreshToken(refreshToken)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	dbSession, err := s.db.GetSession(claims.SessionID)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if !tokenIssuable(dbSession.Status) || dbSession.IsExpired() {
+		return "", time.Time{}, errSessionNotActive
+	}
+
+	return s.issueAccessToken(dbSession)
+}
+
+// handleRefreshToken exchanges a refresh token for a new access token.
+func (s *Server) handleRefreshToken(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, expiresAt, err := s.refreshAccessToken(req.RefreshToken)
+	if errors.Is(err, errSessionNotActive) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		auth.LogRejectedToken(s.logger, req.RefreshToken, err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token":            token,
+		"access_token_expires_at": expiresAt.Format(time.RFC3339),
+	})
+}
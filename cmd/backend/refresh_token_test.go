@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/airfi/airfi-perun-nervous/internal/auth"
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+)
+
+func TestRefreshTokenCycle(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+	s.jwtService = newTestJWTService(t)
+
+	s.db.CreateSession(&db.Session{
+		ID:        "session-1",
+		WalletID:  "wallet-1",
+		Status:    "active",
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(2 * time.Hour),
+	})
+
+	r := gin.New()
+	r.GET("/api/v1/sessions/:sessionId/token", s.handleGetSessionToken)
+	r.POST("/api/v1/auth/refresh", s.handleRefreshToken)
+	r.POST("/api/v1/auth/validate", s.handleValidateToken)
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/sessions/session-1/token?wallet_id=wallet-1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var tokens struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &tokens)
+
+	// The access token is short-lived even though the session runs 2 hours
+	claims, err := s.jwtService.ValidateAccessToken(tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("Expected a valid access token: %v", err)
+	}
+	if ttl := time.Until(claims.ExpiresAt.Time); ttl > auth.AccessTokenTTL {
+		t.Errorf("Expected access token valid for at most %s, got %s", auth.AccessTokenTTL, ttl)
+	}
+
+	// A refresh token grants no access
+	if w := post("/api/v1/auth/validate", `{"token":"`+tokens.RefreshToken+`"}`); w.Code != http.StatusUnauthorized {
+		t.Errorf("Refresh token validated for access: %d %s", w.Code, w.Body.String())
+	}
+	// An access token can't be used to refresh
+	if w := post("/api/v1/auth/refresh", `{"refresh_token":"`+tokens.AccessToken+`"}`); w.Code != http.StatusUnauthorized {
+		t.Errorf("Access token accepted for refresh: %d %s", w.Code, w.Body.String())
+	}
+
+	w = post("/api/v1/auth/refresh", `{"refresh_token":"`+tokens.RefreshToken+`"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var refreshed struct {
+		AccessToken string `json:"access_token"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &refreshed)
+	if w := post("/api/v1/auth/validate", `{"token":"`+refreshed.AccessToken+`"}`); w.Code != http.StatusOK {
+		t.Errorf("Refreshed access token rejected: %d %s", w.Code, w.Body.String())
+	}
+
+	// Once the session ends, refreshing stops
+	s.db.UpdateSessionStatus("session-1", "settled")
+	if w := post("/api/v1/auth/refresh", `{"refresh_token":"`+tokens.RefreshToken+`"}`); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a settled session, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHandleGetSessionToken_RequiresWalletOrHost(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+	s.jwtService = newTestJWTService(t)
+
+	s.db.CreateSession(&db.Session{
+		ID:        "session-1",
+		WalletID:  "wallet-1",
+		Status:    "active",
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(2 * time.Hour),
+	})
+
+	r := gin.New()
+	r.GET("/api/v1/sessions/:sessionId/token", s.handleGetSessionToken)
+
+	tests := []struct {
+		name     string
+		query    string
+		host     bool
+		expected int
+	}{
+		{"anonymous", "", false, http.StatusUnauthorized},
+		{"other wallet", "?wallet_id=wallet-2", false, http.StatusForbidden},
+		{"session wallet", "?wallet_id=wallet-1", false, http.StatusOK},
+		{"host", "", true, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/sessions/session-1/token"+tt.query, nil)
+			if tt.host {
+				req.AddCookie(hostCookie(t, s))
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.expected {
+				t.Errorf("Expected %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/rpc"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
+
+	"github.com/airfi/airfi-perun-nervous/internal/auth"
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+)
+
+func TestHandleListRefundableWallets(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+
+	now := time.Now()
+	s.db.CreateGuestWallet(&db.GuestWallet{ID: "w1", Address: "ckt1a", PrivateKeyHex: "k1", BalanceCKB: 900, Status: "funded", SenderAddress: "ckt1sender", CreatedAt: now})
+	s.db.CreateGuestWallet(&db.GuestWallet{ID: "w2", Address: "ckt1b", PrivateKeyHex: "k2", BalanceCKB: 40, Status: "created", CreatedAt: now})
+	s.db.CreateGuestWallet(&db.GuestWallet{ID: "w3", Address: "ckt1c", PrivateKeyHex: "k3", BalanceCKB: 900, Status: "withdrawn", CreatedAt: now})
+
+	r := gin.New()
+	r.GET("/api/v1/admin/wallets/refundable", s.handleListRefundableWallets)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/wallets/refundable", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Wallets []struct {
+			Address       string `json:"address"`
+			BalanceCKB    int64  `json:"balance_ckb"`
+			SenderAddress string `json:"sender_address"`
+		} `json:"wallets"`
+		TotalCKB int64 `json:"total_ckb"`
+		MinCKB   int64 `json:"min_ckb"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if resp.MinCKB != 61 {
+		t.Errorf("min_ckb: expected default 61, got %d", resp.MinCKB)
+	}
+	if len(resp.Wallets) != 1 || resp.Wallets[0].Address != "ckt1a" || resp.Wallets[0].SenderAddress != "ckt1sender" {
+		t.Fatalf("unexpected wallets: %s", w.Body.String())
+	}
+	if resp.TotalCKB != 900 {
+		t.Errorf("total_ckb: expected 900, got %d", resp.TotalCKB)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/wallets/refundable?min_ckb=10", nil))
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Wallets) != 2 {
+		t.Errorf("min_ckb=10: expected 2 wallets, got %d", len(resp.Wallets))
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/wallets/refundable?min_ckb=-1", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("negative min_ckb: expected 400, got %d", w.Code)
+	}
+}
+
+func TestHandleGetRefundStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+
+	now := time.Now()
+	s.db.CreateSession(&db.Session{ID: "settled", WalletID: "w1", Status: "settled", ExpiresAt: now, SenderAddress: "ckt1sender"})
+	s.db.CreateGuestWallet(&db.GuestWallet{ID: "w1", Address: "ckt1a", PrivateKeyHex: "k1", BalanceCKB: 900, Status: "funded", SessionID: "settled", CreatedAt: now})
+	s.db.CreateSession(&db.Session{ID: "active", WalletID: "w2", Status: "active", ExpiresAt: now.Add(time.Hour)})
+	s.db.CreateGuestWallet(&db.GuestWallet{ID: "w2", Address: "ckt1b", PrivateKeyHex: "k2", BalanceCKB: 900, Status: "funded", SessionID: "active", SenderAddress: "ckt1wallet", CreatedAt: now})
+	s.db.CreateSession(&db.Session{ID: "withdrawn", WalletID: "w3", Status: "settled", ExpiresAt: now, SenderAddress: "ckt1sender"})
+	s.db.CreateGuestWallet(&db.GuestWallet{ID: "w3", Address: "ckt1c", PrivateKeyHex: "k3", BalanceCKB: 900, Status: "withdrawn", SessionID: "withdrawn", CreatedAt: now})
+
+	r := gin.New()
+	r.GET("/api/v1/sessions/:sessionId/refund/status", s.handleGetRefundStatus)
+
+	tests := []struct {
+		sessionID   string
+		eligible    bool
+		canWithdraw bool
+		sender      string
+	}{
+		{"settled", true, true, "ckt1sender"},
+		{"active", true, false, "ckt1wallet"},
+		{"withdrawn", false, false, "ckt1sender"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sessionID, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, hostRequest(t, s, http.MethodGet, "/api/v1/sessions/"+tt.sessionID+"/refund/status", nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+			}
+
+			var resp struct {
+				Eligible      bool   `json:"eligible"`
+				SenderAddress string `json:"sender_address"`
+				EstimatedCKB  int64  `json:"estimated_ckb"`
+				CanWithdraw   bool   `json:"can_withdraw"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid JSON: %v", err)
+			}
+			if resp.Eligible != tt.eligible || resp.CanWithdraw != tt.canWithdraw || resp.SenderAddress != tt.sender {
+				t.Errorf("unexpected status: %s", w.Body.String())
+			}
+			if resp.EstimatedCKB != 900 {
+				t.Errorf("estimated_ckb: expected 900, got %d", resp.EstimatedCKB)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, hostRequest(t, s, http.MethodGet, "/api/v1/sessions/missing/refund/status", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("missing session: expected 404, got %d", w.Code)
+	}
+}
+
+func TestHandleGetRefundStatus_Progress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+
+	now := time.Now()
+	s.db.CreateSession(&db.Session{ID: "sent", WalletID: "w1", Status: "settled", ExpiresAt: now, SenderAddress: "ckt1sender"})
+	s.db.CreateGuestWallet(&db.GuestWallet{ID: "w1", Address: "ckt1a", PrivateKeyHex: "k1", Status: "withdrawn", SessionID: "sent", CreatedAt: now})
+	s.db.UpdateWalletRefund("w1", db.RefundStatusSent, "0xfeed", 89900000000)
+	s.db.CreateSession(&db.Session{ID: "processing", WalletID: "w2", Status: "settled", ExpiresAt: now})
+	s.db.CreateGuestWallet(&db.GuestWallet{ID: "w2", Address: "ckt1b", PrivateKeyHex: "k2", Status: "funded", SessionID: "processing", SenderAddress: "ckt1wallet", CreatedAt: now})
+	s.db.UpdateWalletRefund("w2", db.RefundStatusProcessing, "", 0)
+	s.db.CreateSession(&db.Session{ID: "active", WalletID: "w3", Status: "active", ExpiresAt: now.Add(time.Hour)})
+	s.db.CreateGuestWallet(&db.GuestWallet{ID: "w3", Address: "ckt1c", PrivateKeyHex: "k3", Status: "funded", SessionID: "active", CreatedAt: now})
+	s.db.CreateSession(&db.Session{ID: "legacy", WalletID: "w4", Status: "settled", ExpiresAt: now})
+	s.db.CreateGuestWallet(&db.GuestWallet{ID: "w4", Address: "ckt1d", PrivateKeyHex: "k4", Status: "withdrawn", SessionID: "legacy", CreatedAt: now})
+
+	r := gin.New()
+	r.GET("/api/v1/sessions/:sessionId/refund/status", s.handleGetRefundStatus)
+
+	tests := []struct {
+		sessionID  string
+		status     string
+		txHash     string
+		address    string
+		amountCKB  float64
+		hasArrival bool
+	}{
+		{"sent", db.RefundStatusSent, "0xfeed", "ckt1sender", 899, true},
+		{"processing", db.RefundStatusProcessing, "", "ckt1wallet", 0, true},
+		{"active", db.RefundStatusPending, "", "", 0, false},
+		{"legacy", db.RefundStatusSent, "", "", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sessionID, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, hostRequest(t, s, http.MethodGet, "/api/v1/sessions/"+tt.sessionID+"/refund/status", nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+			}
+
+			var resp struct {
+				SessionID        string  `json:"session_id"`
+				RefundStatus     string  `json:"refund_status"`
+				RefundTxHash     string  `json:"refund_tx_hash"`
+				SenderAddress    string  `json:"sender_address"`
+				RefundAmountCKB  float64 `json:"refund_amount_ckb"`
+				EstimatedArrival string  `json:"estimated_arrival"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid JSON: %v", err)
+			}
+			if resp.SessionID != tt.sessionID || resp.RefundStatus != tt.status || resp.RefundTxHash != tt.txHash || resp.SenderAddress != tt.address {
+				t.Errorf("unexpected refund: %s", w.Body.String())
+			}
+			if resp.RefundAmountCKB != tt.amountCKB {
+				t.Errorf("refund_amount_ckb: expected %v, got %v", tt.amountCKB, resp.RefundAmountCKB)
+			}
+			if (resp.EstimatedArrival != "") != tt.hasArrival {
+				t.Errorf("estimated_arrival: unexpected %q", resp.EstimatedArrival)
+			}
+			if resp.EstimatedArrival != "" {
+				if _, err := time.Parse(time.RFC3339, resp.EstimatedArrival); err != nil {
+					t.Errorf("estimated_arrival is not RFC 3339: %v", err)
+				}
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, hostRequest(t, s, http.MethodGet, "/api/v1/sessions/missing/refund/status", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("missing session: expected 404, got %d", w.Code)
+	}
+}
+
+func TestExplorerTxURL(t *testing.T) {
+	if got := explorerTxURL("mainnet", "0xab"); got != "https://explorer.nervos.org/transaction/0xab" {
+		t.Errorf("mainnet: got %s", got)
+	}
+	for _, network := range []string{"testnet", ""} {
+		if got := explorerTxURL(network, "0xab"); got != "https://pudge.explorer.nervos.org/transaction/0xab" {
+			t.Errorf("%q: got %s", network, got)
+		}
+	}
+}
+
+func TestHandleSession_ShowsRefund(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+
+	now := time.Now()
+	s.db.CreateSession(&db.Session{ID: "sent", WalletID: "w1", Status: "settled", ExpiresAt: now})
+	s.db.CreateGuestWallet(&db.GuestWallet{ID: "w1", Address: "ckt1a", PrivateKeyHex: "k1", Status: "withdrawn", SessionID: "sent", CreatedAt: now})
+	s.db.UpdateWalletRefund("w1", db.RefundStatusSent, "0x1234567890abcdef1234", 89900000000)
+	s.db.CreateSession(&db.Session{ID: "active", WalletID: "w2", Status: "active", ExpiresAt: now.Add(time.Hour)})
+	s.db.CreateGuestWallet(&db.GuestWallet{ID: "w2", Address: "ckt1b", PrivateKeyHex: "k2", Status: "funded", SessionID: "active", CreatedAt: now})
+
+	r := gin.New()
+	r.LoadHTMLGlob("../../web/guest/templates/*")
+	r.GET("/session/:sessionId", s.handleSession)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/session/sent", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "Your refund of 899.00 CKB was sent") || !strings.Contains(body, "tx/0x1234567890abcdef...") {
+		t.Errorf("refund message missing from session page")
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/session/active", nil))
+	if strings.Contains(w.Body.String(), "refund-section") {
+		t.Errorf("active session should not show a refund")
+	}
+}
+
+// refundTxRPCClient reports every transaction as committed.
+type refundTxRPCClient struct {
+	rpc.Client
+}
+
+func (m *refundTxRPCClient) GetTransaction(ctx context.Context, hash types.Hash) (*types.TransactionWithStatus, error) {
+	return &types.TransactionWithStatus{TxStatus: &types.TxStatus{Status: types.TransactionStatusCommitted}}, nil
+}
+
+func TestHandleGetRefundTx(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+	s.ckbClient = &refundTxRPCClient{}
+
+	now := time.Now()
+	s.db.CreateGuestWallet(&db.GuestWallet{ID: "w1", Address: "ckt1a", PrivateKeyHex: "k1", Status: "withdrawn", SessionID: "sent", CreatedAt: now})
+	s.db.UpdateWalletRefund("w1", db.RefundStatusSent, "0xfeed", 89900000000)
+	s.db.CreateGuestWallet(&db.GuestWallet{ID: "w2", Address: "ckt1b", PrivateKeyHex: "k2", Status: "funded", SessionID: "unsent", CreatedAt: now})
+
+	r := gin.New()
+	r.GET("/api/v1/sessions/:sessionId/refund/tx", s.handleGetRefundTx)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, hostRequest(t, s, http.MethodGet, "/api/v1/sessions/sent/refund/tx", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		TxHash   string `json:"tx_hash"`
+		TxStatus string `json:"tx_status"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.TxHash != "0xfeed" || resp.TxStatus != "committed" {
+		t.Errorf("Unexpected response: %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, hostRequest(t, s, http.MethodGet, "/api/v1/sessions/unsent/refund/tx", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("no refund yet: expected 404, got %d", w.Code)
+	}
+}
+
+func TestHandleManualRefund_Validation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+	s.db.CreateGuestWallet(&db.GuestWallet{ID: "w1", Address: "ckt1guest", SessionID: "s1", Status: "settled", CreatedAt: time.Now()})
+
+	r := gin.New()
+	r.POST("/api/v1/sessions/:sessionId/refund", s.dashboardAuthMiddleware(auth.APIKeyScopeAdmin), s.handleManualRefund)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/sessions/s1/refund", strings.NewReader(`{"to_address":"ckt1sender"}`)))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without host credentials, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, hostRequest(t, s, http.MethodPost, "/api/v1/sessions/s1/refund", strings.NewReader(`{"to_address":"ckt1sender","amount_ckb":92233720369}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an amount overflowing shannons, got %d: %s", w.Code, w.Body.String())
+	}
+}
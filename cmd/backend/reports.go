@@ -0,0 +1,154 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+)
+
+// monthlyRevenueJSON converts a revenue summary to its API representation.
+func monthlyRevenueJSON(r *db.MonthlyRevenue) gin.H {
+	return gin.H{
+		"year":               r.Year,
+		"month":              r.Month,
+		"total_sessions":     r.TotalSessions,
+		"active_sessions":    r.ActiveSessions,
+		"settled_sessions":   r.SettledSessions,
+		"total_funded_ckb":   r.TotalFundedCKB,
+		"total_spent_ckb":    r.TotalSpentCKB,
+		"total_refunded_ckb": r.TotalRefundedCKB,
+		"unique_guests":      r.UniqueGuests,
+	}
+}
+
+// queryInt reads an integer query parameter, using def when it is absent.
+func queryInt(c *gin.Context, key string, def int) (int, error) {
+	value := c.Query(key)
+	if value == "" {
+		return def, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// handleMonthlyReport returns the revenue summary for one month.
+// Defaults to the current UTC month.
+func (s *Server) handleMonthlyReport(c *gin.Context) {
+	now := time.Now().UTC()
+	year, err := queryInt(c, "year", now.Year())
+	if err != nil || year < 1 || year > 9999 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid year"})
+		return
+	}
+	month, err := queryInt(c, "month", int(now.Month()))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid month"})
+		return
+	}
+	if month < 1 || month > 12 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "month must be between 1 and 12"})
+		return
+	}
+
+	report, err := s.db.GetMonthlyRevenueSummary(year, month)
+	if err != nil {
+		s.logger.Error("failed to build monthly report", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, monthlyRevenueJSON(report))
+}
+
+// handleYearlyReport returns one revenue summary per month of a year.
+// Defaults to the current UTC year.
+func (s *Server) handleYearlyReport(c *gin.Context) {
+	year, err := queryInt(c, "year", time.Now().UTC().Year())
+	if err != nil || year < 1 || year > 9999 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid year"})
+		return
+	}
+
+	reports, err := s.db.GetYearlyRevenueSummary(year)
+	if err != nil {
+		s.logger.Error("failed to build yearly report", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build report"})
+		return
+	}
+
+	months := make([]gin.H, 0, len(reports))
+	for _, r := range reports {
+		months = append(months, monthlyRevenueJSON(r))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"year":   year,
+		"months": months,
+	})
+}
+
+// handleFlowReport returns CKB flows for sessions and wallets created in
+// [from, to). Either bound may be omitted.
+func (s *Server) handleFlowReport(c *gin.Context) {
+	var req struct {
+		From time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+		To   time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid query: " + err.Error()})
+		return
+	}
+	if !req.From.IsZero() && !req.To.IsZero() && !req.From.Before(req.To) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+
+	r, err := s.db.GetCKBFlowSummary(req.From, req.To)
+	if err != nil {
+		s.logger.Error("failed to build flow report", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total_funded_shannons":   r.TotalFundedShannons,
+		"total_settled_shannons":  r.TotalSettledShannons,
+		"total_refunded_shannons": r.TotalRefundedShannons,
+		"total_pending_shannons":  r.TotalPendingShannons,
+		"total_fees_shannons":     r.TotalFeesShannons,
+	})
+}
+
+// maxTopGuests caps the limit accepted by handleTopGuests.
+const maxTopGuests = 100
+
+// handleTopGuests returns the guests with the highest lifetime spend.
+func (s *Server) handleTopGuests(c *gin.Context) {
+	limit, err := queryInt(c, "limit", 10)
+	if err != nil || limit < 1 || limit > maxTopGuests {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+		return
+	}
+
+	stats, err := s.db.GetTopGuests(limit)
+	if err != nil {
+		s.logger.Error("failed to list top guests", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list top guests"})
+		return
+	}
+
+	guests := make([]gin.H, 0, len(stats))
+	for _, g := range stats {
+		guests = append(guests, gin.H{
+			"guest":           g.Guest,
+			"session_count":   g.SessionCount,
+			"total_spent_ckb": g.TotalSpentCKB,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"guests": guests})
+}
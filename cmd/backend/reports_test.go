@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+)
+
+func TestHandleMonthlyReport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+
+	created := time.Date(2025, 6, 10, 0, 0, 0, 0, time.UTC)
+	s.db.CreateSession(&db.Session{ID: "s1", GuestAddress: "ckt1a", FundingCKB: 1500, SpentCKB: 250, Status: "active", CreatedAt: created, ExpiresAt: created})
+
+	r := gin.New()
+	r.GET("/api/v1/admin/reports/monthly", s.handleMonthlyReport)
+	r.GET("/api/v1/admin/reports/yearly", s.handleYearlyReport)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/reports/monthly?year=2025&month=6", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var report struct {
+		TotalSessions int   `json:"total_sessions"`
+		TotalSpentCKB int64 `json:"total_spent_ckb"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &report)
+	if report.TotalSessions != 1 || report.TotalSpentCKB != 250 {
+		t.Errorf("Unexpected report: %s", w.Body.String())
+	}
+
+	for _, path := range []string{
+		"/api/v1/admin/reports/monthly?year=2025&month=13",
+		"/api/v1/admin/reports/monthly?year=abc",
+		"/api/v1/admin/reports/yearly?year=0",
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", path, w.Code)
+		}
+	}
+}
+
+func TestHandleFlowReport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+
+	created := time.Date(2025, 6, 10, 0, 0, 0, 0, time.UTC)
+	s.db.CreateSession(&db.Session{ID: "s1", FundingCKB: 1500, SpentCKB: 250, BalanceCKB: 1250, Status: "settled", CreatedAt: created, ExpiresAt: created})
+
+	r := gin.New()
+	r.GET("/api/v1/admin/reports/flow", s.handleFlowReport)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/reports/flow?from=2025-06-01T00:00:00Z&to=2025-07-01T00:00:00Z", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var report struct {
+		Funded  int64 `json:"total_funded_shannons"`
+		Settled int64 `json:"total_settled_shannons"`
+		Pending int64 `json:"total_pending_shannons"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &report)
+	if report.Funded != 1500*100000000 || report.Settled != 250*100000000 || report.Pending != 1250*100000000 {
+		t.Errorf("Unexpected report: %s", w.Body.String())
+	}
+
+	for _, path := range []string{
+		"/api/v1/admin/reports/flow?from=yesterday",
+		"/api/v1/admin/reports/flow?from=2025-07-01T00:00:00Z&to=2025-06-01T00:00:00Z",
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", path, w.Code)
+		}
+	}
+}
+
+func TestHandleTopGuests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+
+	now := time.Now()
+	s.db.CreateSession(&db.Session{ID: "s1", SenderAddress: "ckt1a", SpentCKB: 100, Status: "settled", CreatedAt: now, ExpiresAt: now})
+	s.db.CreateSession(&db.Session{ID: "s2", SenderAddress: "ckt1b", SpentCKB: 300, Status: "settled", CreatedAt: now, ExpiresAt: now})
+
+	r := gin.New()
+	r.GET("/api/v1/admin/guests/top", s.handleTopGuests)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/guests/top?limit=1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Guests []struct {
+			Guest         string `json:"guest"`
+			TotalSpentCKB int64  `json:"total_spent_ckb"`
+		} `json:"guests"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Guests) != 1 || resp.Guests[0].Guest != "ckt1b" || resp.Guests[0].TotalSpentCKB != 300 {
+		t.Errorf("Unexpected response: %s", w.Body.String())
+	}
+
+	for _, path := range []string{
+		"/api/v1/admin/guests/top?limit=0",
+		"/api/v1/admin/guests/top?limit=abc",
+		"/api/v1/admin/guests/top?limit=101",
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", path, w.Code)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/airfi/airfi-perun-nervous/internal/auth"
+)
+
+const (
+	// defaultScopedTokenTTL is used when the request does not specify a TTL.
+	defaultScopedTokenTTL = 5 * time.Minute
+	// maxScopedTokenTTL caps how long a scoped token stays valid.
+	maxScopedTokenTTL = time.Hour
+)
+
+// authorizeSessionScope checks that a request may perform a session
+// operation. The host (dashboard cookie or API key) may act on any session,
+// though a read API key only for read operations; anyone else needs a
+// Bearer token for the session that is either a full session token or
+// carries the required scope. Scoped tokens are single-use
+// and are revoked here, before the operation runs, so two requests can't
+// both spend one. On failure the response is written and false returned.
+func (s *Server) authorizeSessionScope(c *gin.Context, sessionID, scope string) bool {
+	hostScope := auth.APIKeyScopeAdmin
+	if scope == auth.ScopeRead {
+		hostScope = auth.APIKeyScopeRead
+	}
+	if s.isDashboardAuthorized(c, hostScope) {
+		if !s.allowAPIKey(c) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+			return false
+		}
+		return true
+	}
+
+	token := bearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return false
+	}
+
+	claims, err := s.jwt().ValidateAccessToken(token)
+	if err != nil {
+		auth.LogRejectedToken(s.logger, token, err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		return false
+	}
+	if claims.SessionID != sessionID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "token does not belong to this session"})
+		return false
+	}
+	if claims.Scope == "" {
+		return true
+	}
+	if claims.Scope != scope {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":          "insufficient token scope",
+			"required_scope": scope,
+		})
+		return false
+	}
+	if !s.jwt().Consume(claims) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		return false
+	}
+	return true
+}
+
+// handleCreateScopedToken issues a short-lived, single-use token for one operation.
+// Requires the full session token as a Bearer token.
+func (s *Server) handleCreateScopedToken(c *gin.Context) {
+	sessionID := c.Param("sessionId")
+
+	var req struct {
+		Scope      string `json:"scope" binding:"required"`
+		TTLSeconds int    `json:"ttl_seconds"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !auth.ValidScope(req.Scope) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scope must be one of read, extend, settle"})
+		return
+	}
+
+	token := bearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+	claims, err := s.jwt().ValidateAccessToken(token)
+	if err != nil {
+		auth.LogRejectedToken(s.logger, token, err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		return
+	}
+	if claims.SessionID != sessionID || claims.Scope != "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "full session token required"})
+		return
+	}
+
+	ttl := defaultScopedTokenTTL
+	if req.TTLSeconds > 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+	if ttl > maxScopedTokenTTL {
+		ttl = maxScopedTokenTTL
+	}
+	// A scoped token never outlives the session token that issued it
+	if remaining := time.Until(claims.ExpiresAt.Time); ttl > remaining {
+		ttl = remaining
+	}
+
+	scopedToken, err := s.jwt().GenerateShortLivedToken(sessionID, req.Scope, ttl)
+	if err != nil {
+		s.logger.Error("failed to generate scoped token", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id": sessionID,
+		"token":      scopedToken,
+		"scope":      req.Scope,
+		"expires_at": time.Now().Add(ttl).Format(time.RFC3339),
+	})
+}
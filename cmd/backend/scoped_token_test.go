@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/airfi/airfi-perun-nervous/internal/auth"
+)
+
+func newTestJWTService(t *testing.T) *auth.JWTService {
+	t.Helper()
+	kp, err := auth.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("failed to generate key pair: %v", err)
+	}
+	return auth.NewJWTService(kp, "test")
+}
+
+// hostCookie logs the host in to the dashboard and returns the session cookie.
+func hostCookie(t *testing.T, s *Server) *http.Cookie {
+	t.Helper()
+	token, err := s.dashboardSessions.create()
+	if err != nil {
+		t.Fatalf("failed to create dashboard session: %v", err)
+	}
+	return &http.Cookie{Name: dashboardCookie, Value: token}
+}
+
+// hostRequest builds a request carrying the host's dashboard credential,
+// which may act on any session.
+func hostRequest(t *testing.T, s *Server, method, path string, body io.Reader) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest(method, path, body)
+	req.AddCookie(hostCookie(t, s))
+	return req
+}
+
+func TestHandleEndSession_RequiresToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+	s.jwtService = newTestJWTService(t)
+
+	r := gin.New()
+	r.POST("/api/v1/sessions/:sessionId/end", s.handleEndSession)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/sessions/session-1/end", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a token, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHandleEndSession_RejectsReadScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+	s.jwtService = newTestJWTService(t)
+
+	token, err := s.jwtService.GenerateShortLivedToken("session-1", auth.ScopeRead, time.Minute)
+	if err != nil {
+		t.Fatalf("GenerateShortLivedToken failed: %v", err)
+	}
+
+	r := gin.New()
+	r.POST("/api/v1/sessions/:sessionId/end", s.handleEndSession)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/sessions/session-1/end", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHandleCreateScopedToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+	s.jwtService = newTestJWTService(t)
+
+	sessionToken, _, _ := s.jwtService.GenerateToken("session-1", "channel-1", "", "", time.Hour)
+	scopedToken, _ := s.jwtService.GenerateShortLivedToken("session-1", auth.ScopeRead, time.Minute)
+	otherToken, _, _ := s.jwtService.GenerateToken("session-2", "channel-2", "", "", time.Hour)
+
+	r := gin.New()
+	r.POST("/api/v1/sessions/:sessionId/token/scoped", s.handleCreateScopedToken)
+
+	tests := []struct {
+		name     string
+		token    string
+		body     string
+		expected int
+	}{
+		{"full session token", sessionToken, `{"scope":"settle"}`, http.StatusOK},
+		{"no token", "", `{"scope":"settle"}`, http.StatusUnauthorized},
+		{"scoped token", scopedToken, `{"scope":"settle"}`, http.StatusForbidden},
+		{"other session", otherToken, `{"scope":"settle"}`, http.StatusForbidden},
+		{"invalid scope", sessionToken, `{"scope":"admin"}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/sessions/session-1/token/scoped", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("Expected %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleGetSession_ScopedTokenSingleUse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+	s.jwtService = newTestJWTService(t)
+
+	token, _ := s.jwtService.GenerateShortLivedToken("session-1", auth.ScopeRead, time.Minute)
+
+	r := gin.New()
+	r.GET("/api/v1/sessions/:sessionId", s.handleGetSession)
+
+	doRequest := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/sessions/session-1", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := doRequest(); code == http.StatusUnauthorized || code == http.StatusForbidden {
+		t.Fatalf("First use rejected with %d", code)
+	}
+	if code := doRequest(); code != http.StatusUnauthorized {
+		t.Errorf("Second use: expected 401, got %d", code)
+	}
+}
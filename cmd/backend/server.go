@@ -3,6 +3,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"math/big"
 	"net/http"
 	"sync"
@@ -14,32 +15,104 @@ import (
 	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
 	"go.uber.org/zap"
 
+	gpchannel "perun.network/go-perun/channel"
 	gpwire "perun.network/go-perun/wire"
 
 	"github.com/airfi/airfi-perun-nervous/internal/auth"
+	"github.com/airfi/airfi-perun-nervous/internal/config"
+	"github.com/airfi/airfi-perun-nervous/internal/cron"
 	"github.com/airfi/airfi-perun-nervous/internal/db"
 	"github.com/airfi/airfi-perun-nervous/internal/guest"
 	"github.com/airfi/airfi-perun-nervous/internal/perun"
 	"github.com/airfi/airfi-perun-nervous/internal/router"
+	"github.com/airfi/airfi-perun-nervous/internal/session"
+	"github.com/airfi/airfi-perun-nervous/internal/webhook"
 )
 
 // Server represents the AirFi backend server.
 type Server struct {
 	hostClient        *perun.ChannelClient
+	hostAddress       string
 	hostPrivKey       *secp256k1.PrivateKey
 	hostLockScript    *types.Script
 	wireBus           *gpwire.LocalBus
+	guestClients      *perun.GuestClientPool // reused across sessions of a wallet
 	ckbClient         rpc.Client
 	jwtService        *auth.JWTService
+	jwtMu             sync.RWMutex // guards jwtService, replaced on key rotation
+	jwtKeyPaths       [2]string    // private and public key files updated on rotation
 	db                *db.DB
 	walletManager     *guest.WalletManager
 	sessions          map[string]*GuestSession
 	sessionsMu        sync.RWMutex
 	logger            *zap.Logger
 	ratePerMin        *big.Int
+	rateCalculator    *session.RateCalculator
+	sessionStore      *session.Store
+	sessionStorePath  string // snapshot restored on start and written on shutdown
 	channelSetupCKB   int64
-	dashboardPassword string
+	passwordMu        sync.RWMutex // guards passwordHash
+	passwordHash      []byte       // bcrypt
+	dashboardSessions *dashboardSessions
 	router            router.Router
+	feeOracle         *perun.NetworkFeeOracle
+	withdrawer        *perun.Withdrawer
+	webhooks          *webhook.Notifier
+	fundingTimeout    time.Duration
+	walletTTL         time.Duration
+	minSessionTime    time.Duration
+	maxSessionTime    time.Duration
+	maxDevicesMu      sync.RWMutex // guards maxDevices, changed on config reload
+	maxDevices        int
+	retryFunding      bool
+	coopCloseTimeout  time.Duration
+	forceSettle       bool          // settle all channels on shutdown
+	shutdownTimeout   time.Duration // bounds forceSettle
+	apiKeys           *auth.APIKeyService
+	totp              *auth.TOTPService
+	runCtx            context.Context // Run's context, for work that outlives a request
+	startedAt         time.Time
+	settleAllMu       sync.Mutex // held while settle-all is running
+	reconcileMu       sync.Mutex // held while sessions are reconciled with the chain
+	compactSchedule   *cron.Schedule
+	compactMu         sync.Mutex // held while the database is compacted
+	usageMu           sync.Mutex // held while session traffic counters are recorded
+	assets            *perun.AssetRegistry
+	paymentAssetName  string
+	paymentAsset      gpchannel.Asset    // funds guest channels
+	priceOracleURL    string             // empty reports no exchange rates
+	network           string             // CKB network; picks the explorer for links
+	pricesMu          sync.Mutex         // guards prices and pricesAt
+	prices            map[string]float64 // last price oracle answer
+	pricesAt          time.Time
+	dryRun            *perun.MockChannelClient // channel ledger in dry-run mode; nil otherwise
+
+	// Funding detection runs channel opening in goroutines; these keep a
+	// wallet from being processed twice or opened concurrently.
+	walletLocks     sync.Map // wallet ID -> *sync.Mutex
+	inFlightWallets sync.Map // wallet ID -> bool
+	channelOpener   func(ctx context.Context, wallet *db.GuestWallet, sessionID string, balanceCKB int64)
+	walletTxCounts  sync.Map // wallet ID -> int, found by recheckStuckWallet
+
+	// channelSettled reports whether a channel was settled on-chain; nil
+	// without a host channel client.
+	channelSettled func(ctx context.Context, channelID perun.ChannelID) (bool, error)
+	// channelLister lists the host's open channels; nil without a host
+	// channel client.
+	channelLister func() ([]perun.ChannelSummary, error)
+	// fundingStatus reports a channel's on-chain funding progress; nil
+	// without a host channel client.
+	fundingStatus func(ctx context.Context, channelID perun.ChannelID) (*perun.FundingStatus, error)
+	// settlementFee estimates the on-chain fee of settling a session's
+	// channel.
+	settlementFee func(ctx context.Context, session *GuestSession) (uint64, error)
+	// peerHeartbeat checks that a session's guest device is still around.
+	peerHeartbeat func(ctx context.Context, session *GuestSession) error
+	// channelCloser settles every channel of a session's client on shutdown.
+	channelCloser func(ctx context.Context, session *GuestSession) error
+	// updateHandlerSetter overrides how the host answers updates on a
+	// channel; nil without a host channel client.
+	updateHandlerSetter func(channelID perun.ChannelID, handler perun.UpdateHandler)
 }
 
 // ServerConfig holds configuration for creating a new server.
@@ -50,19 +123,61 @@ type ServerConfig struct {
 	WireBus           *gpwire.LocalBus
 	CKBClient         rpc.Client
 	JWTService        *auth.JWTService
+	JWTPrivateKeyPath string
+	JWTPublicKeyPath  string
 	DB                *db.DB
 	WalletManager     *guest.WalletManager
 	Logger            *zap.Logger
 	RatePerHour       int64
+	RateCalculator    *session.RateCalculator
+	SessionStorePath  string
 	ChannelSetupCKB   int64
-	DashboardPassword string
+	PasswordHash      []byte // bcrypt hash of the dashboard password
 	Router            router.Router
+	FeeOracle         *perun.NetworkFeeOracle
+	Webhooks          *webhook.Notifier
+	FundingTimeout    time.Duration
+	WalletTTL         time.Duration
+	MinSessionTime    time.Duration
+	MaxSessionTime    time.Duration
+	MaxDevices        int // 0 is unlimited
+	RetryFunding      bool
+	CoopCloseTimeout  time.Duration
+	CompactSchedule   *cron.Schedule // nil disables scheduled compaction
+	ForceSettle       bool           // settle all channels on shutdown
+	ShutdownTimeout   time.Duration
+	Assets            *perun.AssetRegistry // nil registers only CKBytes
+	PaymentAsset      string               // empty is CKBytes
+	PriceOracleURL    string
+	DryRunChannels    bool   // record channels in memory instead of on-chain
+	Network           string // CKB network, "mainnet" or "testnet"; empty is testnet
 }
 
-// NewServer creates a new AirFi server instance.
-func NewServer(cfg *ServerConfig) *Server {
+// NewServer creates a new AirFi server instance. It fails when the payment
+// asset can't fund channels; see perun.AssetRegistry.PaymentAsset.
+func NewServer(cfg *ServerConfig) (*Server, error) {
+	minSessionTime := cfg.MinSessionTime
+	if minSessionTime <= 0 {
+		minSessionTime = 5 * time.Minute
+	}
+	maxSessionTime := cfg.MaxSessionTime
+	if maxSessionTime <= 0 {
+		maxSessionTime = 24 * time.Hour
+	}
+
+	// A zero or negative rate would divide by zero when pricing sessions
+	ratePerHour := cfg.RatePerHour
+	if err := validateRate(ratePerHour, minSessionTime, maxSessionTime); err != nil {
+		cfg.Logger.Error("invalid rate config, using default rate",
+			zap.Int64("rate_per_hour", ratePerHour),
+			zap.Int64("default_rate_per_hour", session.DefaultRatePerHourCKB),
+			zap.Error(err),
+		)
+		ratePerHour = session.DefaultRatePerHourCKB
+	}
+
 	// Convert CKB per hour to shannons per minute
-	ratePerMinShannons := (cfg.RatePerHour * 100000000) / 60
+	ratePerMinShannons := (ratePerHour * 100000000) / 60
 
 	// Default channel setup CKB if not specified
 	channelSetupCKB := cfg.ChannelSetupCKB
@@ -70,26 +185,163 @@ func NewServer(cfg *ServerConfig) *Server {
 		channelSetupCKB = 1000
 	}
 
-	return &Server{
+	// Without pricing tiers the calculator always returns the flat rate
+	rateCalculator := cfg.RateCalculator
+	if rateCalculator == nil {
+		rateCalculator, _ = session.NewRateCalculator(nil, ratePerHour)
+	}
+
+	fundingTimeout := cfg.FundingTimeout
+	if fundingTimeout <= 0 {
+		fundingTimeout = 10 * time.Minute
+	}
+
+	walletTTL := cfg.WalletTTL
+	if walletTTL <= 0 {
+		walletTTL = 24 * time.Hour
+	}
+
+	shutdownTimeout := cfg.ShutdownTimeout
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = defaultShutdownTimeout
+	}
+
+	assets := cfg.Assets
+	if assets == nil {
+		assets = perun.NewAssetRegistry()
+	}
+	paymentAssetName := cfg.PaymentAsset
+	if paymentAssetName == "" {
+		paymentAssetName = perun.AssetCKBytes
+	}
+	paymentAsset, err := assets.PaymentAsset(paymentAssetName)
+	if err != nil {
+		return nil, fmt.Errorf("payment asset: %w", err)
+	}
+
+	s := &Server{
+		runCtx:            context.Background(),
 		hostClient:        cfg.HostClient,
 		hostPrivKey:       cfg.HostPrivKey,
 		hostLockScript:    cfg.HostLockScript,
 		wireBus:           cfg.WireBus,
 		ckbClient:         cfg.CKBClient,
 		jwtService:        cfg.JWTService,
+		jwtKeyPaths:       [2]string{cfg.JWTPrivateKeyPath, cfg.JWTPublicKeyPath},
 		db:                cfg.DB,
 		walletManager:     cfg.WalletManager,
 		sessions:          make(map[string]*GuestSession),
 		logger:            cfg.Logger,
 		ratePerMin:        big.NewInt(ratePerMinShannons),
+		rateCalculator:    rateCalculator,
+		sessionStore:      session.NewStore(),
+		sessionStorePath:  cfg.SessionStorePath,
 		channelSetupCKB:   channelSetupCKB,
-		dashboardPassword: cfg.DashboardPassword,
+		passwordHash:      cfg.PasswordHash,
+		dashboardSessions: newDashboardSessions(),
 		router:            cfg.Router,
+		feeOracle:         cfg.FeeOracle,
+		webhooks:          cfg.Webhooks,
+		fundingTimeout:    fundingTimeout,
+		walletTTL:         walletTTL,
+		minSessionTime:    minSessionTime,
+		maxSessionTime:    maxSessionTime,
+		maxDevices:        cfg.MaxDevices,
+		retryFunding:      cfg.RetryFunding,
+		coopCloseTimeout:  cfg.CoopCloseTimeout,
+		forceSettle:       cfg.ForceSettle,
+		shutdownTimeout:   shutdownTimeout,
+		compactSchedule:   cfg.CompactSchedule,
+		assets:            assets,
+		paymentAssetName:  paymentAssetName,
+		paymentAsset:      paymentAsset,
+		priceOracleURL:    cfg.PriceOracleURL,
+		network:           cfg.Network,
+		apiKeys:           auth.NewAPIKeyService(apiKeyStore{cfg.DB}, auth.DefaultAPIKeyRateLimit),
+		totp:              auth.NewTOTPService(backupCodeStore{cfg.DB}),
+		startedAt:         time.Now(),
+	}
+	if cfg.HostClient != nil {
+		s.hostAddress = cfg.HostClient.GetAddress()
+		s.channelSettled = cfg.HostClient.ChannelSettledOnChain
+		s.channelLister = cfg.HostClient.ListChannels
+		s.fundingStatus = cfg.HostClient.GetFundingStatus
+		s.updateHandlerSetter = cfg.HostClient.SetUpdateHandler
+	}
+	// One withdrawer is shared so its sender address cache persists
+	s.withdrawer = perun.NewWithdrawer(s.ckbClient, s.logger.Named("withdrawer"))
+	s.withdrawer.SetFeeOracle(s.feeOracle)
+	s.guestClients = perun.NewGuestClientPool(s.newGuestClient)
+	s.channelOpener = s.openChannelForSession
+	s.settlementFee = func(ctx context.Context, session *GuestSession) (uint64, error) {
+		return session.Client.EstimateSettlementFee(ctx, session.Channel)
+	}
+	s.peerHeartbeat = s.devicePresent
+	s.channelCloser = func(ctx context.Context, session *GuestSession) error {
+		return session.Client.CloseAllChannels(ctx)
+	}
+	if cfg.DryRunChannels {
+		s.enableDryRun()
+	}
+	return s, nil
+}
+
+// jwt returns the current JWT service.
+func (s *Server) jwt() *auth.JWTService {
+	s.jwtMu.RLock()
+	defer s.jwtMu.RUnlock()
+	return s.jwtService
+}
+
+// walletLock returns the mutex serializing channel operations for a wallet.
+func (s *Server) walletLock(walletID string) *sync.Mutex {
+	mu, _ := s.walletLocks.LoadOrStore(walletID, &sync.Mutex{})
+	return mu.(*sync.Mutex)
+}
+
+// forgetWalletLock drops the mutex of a wallet whose channel is settled,
+// since no channel will be opened for it again.
+func (s *Server) forgetWalletLock(walletID string) {
+	if walletID != "" {
+		s.walletLocks.Delete(walletID)
+	}
+}
+
+// newGuestClient creates a guest channel client configured like the host's
+// channels. Guest clients are taken from s.guestClients rather than created
+// directly, and go back to the pool once their session ends.
+func (s *Server) newGuestClient(privateKey *secp256k1.PrivateKey) (*perun.ChannelClient, error) {
+	guestClient, err := perun.NewChannelClient(&perun.ChannelClientConfig{
+		RPCURL:     perun.TestnetRPCURL,
+		PrivateKey: privateKey,
+		Deployment: perun.GetTestnetDeployment(),
+		Logger:     s.logger.Named("guest"),
+		WireBus:    s.wireBus,
+
+		FundingTimeout:        s.fundingTimeout,
+		RetryFundingOnTimeout: s.retryFunding,
+		CoopCloseTimeout:      s.coopCloseTimeout,
+		Asset:                 s.paymentAsset,
+		DisputeRecorder:       sessionDisputes{db: s.db},
+	})
+	if err != nil {
+		return nil, err
 	}
+	guestClient.SetFeeOracle(s.feeOracle)
+	return guestClient, nil
+}
+
+// newCellSplitter creates a cell splitter that uses the server's fee oracle.
+func (s *Server) newCellSplitter(logger *zap.Logger) *perun.CellSplitter {
+	cellSplitter := perun.NewCellSplitter(s.ckbClient, logger)
+	cellSplitter.SetFeeOracle(s.feeOracle)
+	return cellSplitter
 }
 
 // Run starts the HTTP server and background workers.
 func (s *Server) Run(ctx context.Context, addr string) error {
+	s.runCtx = ctx
+
 	// Setup proposal handler
 	s.hostClient.HandleProposals(&HostProposalHandler{
 		server: s,
@@ -109,9 +361,18 @@ func (s *Server) Run(ctx context.Context, addr string) error {
 	// Setup routes
 	s.setupRoutes(r)
 
+	// Restore sessions before any worker can touch them
+	s.restoreSessionStore()
+
 	// Start background workers
 	go s.startFundingDetector(ctx)
 	go s.startMicropaymentProcessor(ctx)
+	go s.startHeartbeatMonitor(ctx)
+	go s.startSessionReconciler(ctx)
+	go s.startDBCompactor(ctx)
+	go s.startExpiryNotifier(ctx)
+	go s.startFraudDetector(ctx)
+	go s.startOrphanRecovery(ctx)
 
 	// Create HTTP server
 	httpServer := &http.Server{
@@ -132,7 +393,13 @@ func (s *Server) Run(ctx context.Context, addr string) error {
 	case <-ctx.Done():
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
-		return httpServer.Shutdown(shutdownCtx)
+		err := httpServer.Shutdown(shutdownCtx)
+		if s.forceSettle {
+			s.closeAllChannels()
+		}
+		s.guestClients.Close()
+		s.persistSessionStore()
+		return err
 	case err := <-errCh:
 		return err
 	}
@@ -155,24 +422,147 @@ func (s *Server) setupRoutes(r *gin.Engine) {
 		api.GET("/wallet", s.handleWalletStatus)
 		api.POST("/wallet/guest", s.handleCreateGuestWallet)
 		api.GET("/wallet/guest/:id", s.handleGetGuestWallet)
+		api.GET("/wallet/guest/:id/estimate-cells", s.handleEstimateGuestCells)
 		api.POST("/channels/open", s.handleOpenChannel)
 		api.GET("/sessions", s.handleListSessions)
+		api.GET("/stats/quick", s.handleQuickStats)
+		api.GET("/sessions/search", s.dashboardAuthMiddleware(auth.APIKeyScopeRead), s.handleSearchSessions)
 		api.GET("/sessions/:sessionId", s.handleGetSession)
 		api.GET("/sessions/:sessionId/token", s.handleGetSessionToken)
+		api.POST("/sessions/:sessionId/token/scoped", s.handleCreateScopedToken)
+		api.GET("/sessions/:sessionId/qr", s.handleSessionQR)
+		api.GET("/qr.png", s.handleConnectQR)
+		api.GET("/sessions/:sessionId/client", s.handleGetSessionClient)
+		api.GET("/sessions/:sessionId/payment-proof", s.handleGetPaymentProof)
+		api.GET("/sessions/:sessionId/receipt", s.handleSessionReceipt)
 		api.POST("/sessions/:sessionId/end", s.handleEndSession)
+		api.GET("/sessions/:sessionId/settle/estimate", s.handleEstimateSettlement)
+		api.GET("/sessions/:sessionId/channel/funding", s.handleGetChannelFunding)
 		api.POST("/sessions/:sessionId/extend", s.handleExtendSession)
-		api.POST("/sessions/:sessionId/refund", s.handleManualRefund)
+		api.POST("/sessions/:sessionId/heartbeat", s.handleSessionHeartbeat)
+		api.POST("/sessions/:sessionId/pause", s.handlePauseSession)
+		api.POST("/sessions/:sessionId/resume", s.handleResumeSession)
+		api.POST("/sessions/:sessionId/refund", s.dashboardAuthMiddleware(auth.APIKeyScopeAdmin), s.handleManualRefund)
+		api.GET("/sessions/:sessionId/refund/status", s.handleGetRefundStatus)
+		api.GET("/sessions/:sessionId/refund/tx", s.handleGetRefundTx)
 		api.POST("/auth/validate", s.handleValidateToken)
+		api.POST("/auth/refresh", s.handleRefreshToken)
+		api.POST("/verify-payment", s.handleVerifyPayment)
+		api.GET("/router/topology", s.dashboardAuthMiddleware(auth.APIKeyScopeRead), s.handleRouterTopology)
+		api.GET("/channels", s.dashboardAuthMiddleware(auth.APIKeyScopeRead), s.handleListChannels)
 		api.GET("/settings", s.handleGetSettings)
+		api.GET("/assets", s.handleListAssets)
 		api.PUT("/settings/rate", s.handleUpdateRate)
+		api.PUT("/settings/channel-setup", s.handleUpdateChannelSetup)
 	}
 
+	// Admin routes (dashboard cookie or API key). Read-only routes accept
+	// read API keys; everything else needs the admin scope.
+	readOnly := r.Group("/api/v1/admin", s.dashboardAuthMiddleware(auth.APIKeyScopeRead))
+	{
+		readOnly.GET("/reports/monthly", s.handleMonthlyReport)
+		readOnly.GET("/reports/yearly", s.handleYearlyReport)
+		readOnly.GET("/reports/flow", s.handleFlowReport)
+		readOnly.GET("/guests/top", s.handleTopGuests)
+		readOnly.GET("/wallets", s.handleSearchWallets)
+		readOnly.GET("/wallets/refundable", s.handleListRefundableWallets)
+		readOnly.GET("/wallets/expired", s.handleListExpiredWallets)
+		readOnly.GET("/sessions/suspicious", s.handleListSuspiciousSessions)
+		readOnly.POST("/sessions/lookup-token", s.handleLookupSessionByToken)
+		readOnly.GET("/audit-log", s.handleAuditLog)
+		readOnly.GET("/db/stats", s.handleDBStats)
+		readOnly.GET("/dry-run/channels", s.handleDryRunChannels)
+	}
+	admin := r.Group("/api/v1/admin", s.dashboardAuthMiddleware(auth.APIKeyScopeAdmin))
+	{
+		admin.GET("/keys", s.handleListAPIKeys)
+		admin.POST("/keys", s.handleCreateAPIKey)
+		admin.DELETE("/keys/:id", s.handleRevokeAPIKey)
+		admin.POST("/keys/rotate", s.handleRotateJWTKey)
+		admin.PUT("/password", s.handleUpdateDashboardPassword)
+		admin.POST("/totp/enroll", s.handleEnrollTOTP)
+		admin.POST("/totp/confirm", s.handleConfirmTOTP)
+		admin.GET("/totp/backup-codes", s.handleListBackupCodes)
+		admin.POST("/totp/backup-codes", s.handleGenerateBackupCodes)
+		admin.POST("/wallets/export", s.handleExportWallets)
+		admin.POST("/wallets/import", s.handleImportWallets)
+		admin.POST("/sessions/settle-all", s.handleSettleAllSessions)
+		admin.POST("/sessions/reconcile", s.handleReconcileSessions)
+		admin.PUT("/sessions/:sessionId/expiry", s.handleUpdateSessionExpiry)
+		admin.POST("/sessions/:sessionId/transfer", s.handleTransferSession)
+		admin.POST("/sessions/:sessionId/sync-earnings", s.handleSyncSessionEarnings)
+		admin.POST("/db/compact", s.handleCompactDB)
+	}
+
+	// Public JWT verification keys
+	r.GET("/.well-known/jwks.json", s.handleJWKS)
+	r.OPTIONS("/.well-known/jwks.json", s.handleJWKS)
+
 	// Health check
 	r.GET("/health", s.handleHealth)
 }
 
-// updateRatePerMin updates the in-memory rate per minute from the hourly rate.
+// handleJWKS serves the current JWT key set. The service is looked up per
+// request so keys rotated through the admin API show up immediately.
+func (s *Server) handleJWKS(c *gin.Context) {
+	s.jwt().JWKSHandler()(c.Writer, c.Request)
+}
+
+// rateConfig builds the session rate config for an hourly rate. The per-minute
+// rate is in shannons, like Server.ratePerMin.
+func rateConfig(ratePerHour int64, minSessionTime, maxSessionTime time.Duration) *session.RateConfig {
+	return &session.RateConfig{
+		CKBytesPerMinute: big.NewInt((ratePerHour * 100000000) / 60),
+		MinSessionTime:   minSessionTime,
+		MaxSessionTime:   maxSessionTime,
+	}
+}
+
+// validateRate checks that an hourly rate can price sessions. Rates of
+// config.MaxRatePerHour and above are rejected before the conversion to
+// shannons, which they could overflow.
+func validateRate(ratePerHour int64, minSessionTime, maxSessionTime time.Duration) error {
+	if ratePerHour >= config.MaxRatePerHour {
+		return &session.RateConfigError{Field: "rate_per_hour", Reason: fmt.Sprintf("must be less than %d", config.MaxRatePerHour)}
+	}
+	return rateConfig(ratePerHour, minSessionTime, maxSessionTime).Validate()
+}
+
+// SetRate stores a new default hourly rate and applies it to the in-memory
+// per-minute rate. Both happen under sessionsMu, so micropayments never see
+// the new rate in the database with the old one in memory.
+func (s *Server) SetRate(newRate int64) error {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+
+	if err := s.db.SetRatePerHour(newRate); err != nil {
+		return err
+	}
+	s.rateCalculator.SetDefaultRate(newRate)
+	s.updateRatePerMin(s.rateCalculator.GetCurrentRate(time.Now()))
+	return nil
+}
+
+// updateRatePerMin updates the in-memory rate per minute from the hourly
+// rate. Running sessions are charged the new rate, so it also becomes their
+// contracted rate in the session store. The caller holds sessionsMu.
 func (s *Server) updateRatePerMin(ratePerHour int64) {
 	ratePerMinShannons := (ratePerHour * 100000000) / 60
+	if s.ratePerMin != nil && s.ratePerMin.Int64() == ratePerMinShannons {
+		return
+	}
 	s.ratePerMin = big.NewInt(ratePerMinShannons)
+	for sessionID := range s.sessions {
+		s.sessionStore.SetRate(sessionID, s.ratePerMin)
+	}
+}
+
+// applyCurrentRate sets the per-minute rate from the pricing tier active at now.
+func (s *Server) applyCurrentRate(now time.Time) {
+	ratePerHour := s.rateCalculator.GetCurrentRate(now)
+	previous := s.ratePerMin
+	s.updateRatePerMin(ratePerHour)
+	if previous != nil && previous.Cmp(s.ratePerMin) != 0 {
+		s.logger.Info("pricing tier changed", zap.Int64("rate_per_hour", ratePerHour))
+	}
 }
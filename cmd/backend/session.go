@@ -15,23 +15,68 @@ import (
 	gpclient "perun.network/go-perun/client"
 
 	"github.com/airfi/airfi-perun-nervous/internal/db"
+	"github.com/airfi/airfi-perun-nervous/internal/expiry"
 	"github.com/airfi/airfi-perun-nervous/internal/guest"
 	"github.com/airfi/airfi-perun-nervous/internal/perun"
+	"github.com/airfi/airfi-perun-nervous/internal/session"
 )
 
 // GuestSession represents an active guest session with their channel client.
 type GuestSession struct {
 	ID            string
-	Client        *perun.ChannelClient
-	Channel       *gpclient.Channel
+	Client        *perun.ChannelClient // nil in dry-run mode
+	Channel       *gpclient.Channel    // nil in dry-run mode
+	ChannelID     perun.ChannelID
 	GuestAddress  string
 	FundingAmount *big.Int
 	TotalPaid     *big.Int
 	CreatedAt     time.Time
 	ExpiresAt     time.Time
+	LastPaymentAt time.Time
+	Paused        bool // no micropayments; the host rejects channel updates
 }
 
-// createSessionFromWallet creates a new session when a wallet is funded.
+// IsExpired reports whether the session has expired; see expiry.Expired.
+func (gs *GuestSession) IsExpired() bool {
+	return expiry.Expired(gs.ExpiresAt)
+}
+
+// TimeUntilExpiry returns how long until the session expires; see
+// expiry.Until.
+func (gs *GuestSession) TimeUntilExpiry() time.Duration {
+	return expiry.Until(gs.ExpiresAt)
+}
+
+// FundingCKB returns the guest's channel funding in CKB.
+func (gs *GuestSession) FundingCKB() float64 {
+	return session.ShannonsToCKB(gs.FundingAmount)
+}
+
+// TotalPaidCKB returns the amount paid so far in CKB.
+func (gs *GuestSession) TotalPaidCKB() float64 {
+	return session.ShannonsToCKB(gs.TotalPaid)
+}
+
+// RemainingBalanceCKB returns what is left of the funding in CKB.
+func (gs *GuestSession) RemainingBalanceCKB() float64 {
+	return session.ShannonsToCKB(gs.remainingShannons())
+}
+
+// wholeCKB returns the funding, spent and remaining amounts in whole CKB,
+// as stored in the database.
+func (gs *GuestSession) wholeCKB() (funding, spent, balance int64) {
+	return session.ShannonsToWholeCKB(gs.FundingAmount),
+		session.ShannonsToWholeCKB(gs.TotalPaid),
+		session.ShannonsToWholeCKB(gs.remainingShannons())
+}
+
+// remainingShannons returns the funding minus the payments made so far.
+func (gs *GuestSession) remainingShannons() *big.Int {
+	return new(big.Int).Sub(gs.FundingAmount, gs.TotalPaid)
+}
+
+// createSessionFromWallet creates a new session when a wallet is funded
+// and marks the wallet as funded with it.
 func (s *Server) createSessionFromWallet(wallet *db.GuestWallet, balanceCKB int64) string {
 	idBytes := make([]byte, 8)
 	rand.Read(idBytes)
@@ -56,22 +101,34 @@ func (s *Server) createSessionFromWallet(wallet *db.GuestWallet, balanceCKB int6
 	now := time.Now()
 	sessionDuration := time.Duration(sessionMinutes) * time.Minute
 	session := &db.Session{
-		ID:           sessionID,
-		WalletID:     wallet.ID,
-		GuestAddress: wallet.Address,
-		HostAddress:  s.hostClient.GetAddress(),
-		FundingCKB:   balanceCKB,
-		BalanceCKB:   usableCKB,
-		SpentCKB:     0,
-		CreatedAt:    now,
-		ExpiresAt:    now.Add(sessionDuration),
-		Status:       "active",
-		MACAddress:   wallet.MACAddress,
-		IPAddress:    wallet.IPAddress,
-	}
-
-	if err := s.db.CreateSession(session); err != nil {
-		s.logger.Error("failed to create session", zap.Error(err))
+		ID:            sessionID,
+		WalletID:      wallet.ID,
+		GuestAddress:  wallet.Address,
+		HostAddress:   s.hostAddress,
+		FundingCKB:    balanceCKB,
+		BalanceCKB:    usableCKB,
+		SpentCKB:      0,
+		CreatedAt:     now,
+		ExpiresAt:     now.Add(sessionDuration),
+		Status:        "active",
+		MACAddress:    wallet.MACAddress,
+		IPAddress:     wallet.IPAddress,
+		SenderAddress: wallet.SenderAddress,
+	}
+
+	// The session and the wallet's funded state are written together so a
+	// failure can't leave a funded wallet without its session
+	err = s.db.Transaction(func(tx *db.DB) error {
+		if err := tx.CreateSession(session); err != nil {
+			return fmt.Errorf("failed to create session: %w", err)
+		}
+		if err := tx.UpdateWalletFunded(wallet.ID, balanceCKB, sessionID); err != nil {
+			return fmt.Errorf("failed to mark wallet funded: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("failed to create session", zap.String("wallet_id", wallet.ID), zap.Error(err))
 		return ""
 	}
 
@@ -81,6 +138,7 @@ func (s *Server) createSessionFromWallet(wallet *db.GuestWallet, balanceCKB int6
 		zap.Int64("funded_ckb", balanceCKB),
 		zap.Int64("usable_ckb", usableCKB),
 	)
+	s.audit(systemActor, auditSessionCreated, sessionID, wallet.ID, fmt.Sprintf("funded_ckb=%d", balanceCKB))
 
 	return sessionID
 }
@@ -105,12 +163,17 @@ func (s *Server) processMicropayments(ctx context.Context) {
 	s.sessionsMu.Lock()
 	defer s.sessionsMu.Unlock()
 
+	// Pick up the pricing tier for the current hour
+	s.applyCurrentRate(time.Now())
+
+	var paid []string
 	for sessionID, session := range s.sessions {
 		// Check expiration
-		if time.Now().After(session.ExpiresAt) {
+		if session.IsExpired() {
 			s.logger.Info("session expired, settling channel", zap.String("session_id", sessionID))
 			go s.settleExpiredSession(ctx, session)
 			delete(s.sessions, sessionID)
+			s.storeSessionEnded(sessionID, true)
 			continue
 		}
 
@@ -120,46 +183,212 @@ func (s *Server) processMicropayments(ctx context.Context) {
 			s.logger.Info("insufficient balance, settling channel", zap.String("session_id", sessionID))
 			go s.settleExpiredSession(ctx, session)
 			delete(s.sessions, sessionID)
+			s.storeSessionEnded(sessionID, false)
+			continue
+		}
+
+		if session.Paused {
+			continue
+		}
+
+		// Wait for the peer to acknowledge earlier payments so none is
+		// counted twice; the missed intervals are caught up afterwards
+		if count, _ := pendingPayments(session); count > 0 {
+			s.logger.Warn("payments awaiting acknowledgement, skipping micropayment",
+				zap.String("session_id", sessionID),
+				zap.Int("pending", count),
+			)
 			continue
 		}
 
-		// Send micropayment
-		err := session.Client.SendPayment(session.Channel, s.ratePerMin)
+		// Pay for every interval since the last payment so stalled ticks
+		// are caught up in one state update, bounded by what's left
+		now := time.Now()
+		intervals := int64(1)
+		if !session.LastPaymentAt.IsZero() {
+			intervals = int64(now.Sub(session.LastPaymentAt) / time.Minute)
+		}
+		if affordable := new(big.Int).Div(remaining, s.ratePerMin).Int64(); intervals > affordable {
+			intervals = affordable
+		}
+		if intervals < 1 {
+			intervals = 1
+		}
+
+		// The payment is counted once the peer acknowledges it; until then
+		// it is pending and later ticks wait for it
+		amount := new(big.Int).Mul(s.ratePerMin, big.NewInt(intervals))
+		err := s.sendSessionPayments(session, intervalPayments(s.ratePerMin, intervals), func() {
+			s.creditMicropayment(session, amount, intervals, now)
+		})
 		if err != nil {
 			s.logger.Error("micropayment failed", zap.String("session_id", sessionID), zap.Error(err))
 			continue
 		}
+		paid = append(paid, sessionID)
+	}
 
-		session.TotalPaid.Add(session.TotalPaid, s.ratePerMin)
-		spentCKB := session.TotalPaid.Int64() / 100000000
-		balanceCKB := (session.FundingAmount.Int64() - session.TotalPaid.Int64()) / 100000000
+	// Traffic counters are read off the lock by a single worker; a tick
+	// that finds the previous one still running skips them
+	if len(paid) > 0 && s.usageMu.TryLock() {
+		go func() {
+			defer s.usageMu.Unlock()
+			s.recordUsage(ctx, paid)
+		}()
+	}
+}
 
-		s.db.UpdateSessionBalance(sessionID, balanceCKB, spentCKB)
+// creditMicropayment records an acknowledged payment of amount covering
+// intervals minutes up to paidAt. The caller holds sessionsMu.
+func (s *Server) creditMicropayment(session *GuestSession, amount *big.Int, intervals int64, paidAt time.Time) {
+	if intervals > 1 {
+		s.logger.Info("caught up missed micropayments",
+			zap.String("session_id", session.ID),
+			zap.Int64("intervals", intervals),
+		)
+	}
 
-		s.logger.Debug("micropayment processed",
-			zap.String("session_id", sessionID),
-			zap.Int64("spent_ckb", spentCKB),
-			zap.Int64("balance_ckb", balanceCKB),
+	session.TotalPaid.Add(session.TotalPaid, amount)
+	session.LastPaymentAt = paidAt
+	s.storeSessionPaid(session.ID, amount)
+	s.recordPayment(session.ID, db.PaymentMicropayment, amount)
+	s.recordSessionSnapshot(session)
+	_, spentCKB, balanceCKB := session.wholeCKB()
+
+	s.db.UpdateSessionBalance(session.ID, balanceCKB, spentCKB)
+
+	s.logger.Debug("micropayment processed",
+		zap.String("session_id", session.ID),
+		zap.Int64("spent_ckb", spentCKB),
+		zap.Int64("balance_ckb", balanceCKB),
+	)
+}
+
+// pendingPayments returns how many of a session's payments the host hasn't
+// acknowledged yet and their total in shannons.
+func pendingPayments(session *GuestSession) (int, *big.Int) {
+	total := new(big.Int)
+	if session == nil || session.Client == nil {
+		return 0, total
+	}
+	pending, err := session.Client.GetPendingPayments()
+	if err != nil {
+		return 0, total
+	}
+	for _, p := range pending {
+		total.Add(total, p.Amount)
+	}
+	return len(pending), total
+}
+
+// intervalPayments returns one per-minute payment for each of n intervals.
+func intervalPayments(ratePerMin *big.Int, n int64) []*big.Int {
+	payments := make([]*big.Int, 0, n)
+	for i := int64(0); i < n; i++ {
+		payments = append(payments, ratePerMin)
+	}
+	return payments
+}
+
+// detachSession removes an active session from the in-memory set, marks it
+// settling and revokes its WiFi access on behalf of actor. It reports false
+// if the session isn't active.
+func (s *Server) detachSession(ctx context.Context, sessionID string, actor auditActor) (*GuestSession, bool) {
+	s.sessionsMu.Lock()
+	session, exists := s.sessions[sessionID]
+	if !exists {
+		s.sessionsMu.Unlock()
+		return nil, false
+	}
+	delete(s.sessions, sessionID)
+	s.sessionsMu.Unlock()
+	s.storeSessionEnded(sessionID, false)
+
+	s.logger.Info("ending session - settlement will run in background",
+		zap.String("session_id", sessionID),
+	)
+
+	// Update status to settling
+	s.db.UpdateSessionStatus(sessionID, "settling")
+
+	// Deauthorize MAC immediately
+	dbSession, err := s.db.GetSession(sessionID)
+	if err != nil {
+		s.audit(actor, auditSessionEnded, sessionID, "", "")
+		return session, true
+	}
+	s.audit(actor, auditSessionEnded, sessionID, dbSession.WalletID, "")
+	s.revokeAccess(ctx, dbSession, actor)
+	return session, true
+}
+
+// revokeAccess deauthorizes the MAC address of a session on behalf of actor.
+// Failures are logged.
+func (s *Server) revokeAccess(ctx context.Context, dbSession *db.Session, actor auditActor) {
+	if dbSession.MACAddress == "" {
+		return
+	}
+	if err := s.router.DeauthorizeMAC(ctx, dbSession.MACAddress); err != nil {
+		s.logger.Error("failed to deauthorize MAC",
+			zap.Error(err),
+			zap.String("mac", dbSession.MACAddress),
 		)
+		return
 	}
+	s.logger.Info("MAC deauthorized", zap.String("mac", dbSession.MACAddress))
+	s.audit(actor, auditMACDeauthorized, dbSession.ID, dbSession.WalletID, "mac="+dbSession.MACAddress)
 }
 
-// settleSessionInBackground handles channel settlement without blocking.
-func (s *Server) settleSessionInBackground(session *GuestSession) {
+// settleSession settles a detached session's channel, records the final
+// balance and refunds the remainder. It returns the channel settlement error.
+func (s *Server) settleSession(session *GuestSession) error {
 	s.logger.Info("starting background settlement", zap.String("session_id", session.ID))
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
+	s.unpauseChannel(session)
 
-	err := session.Client.SettleChannel(ctx, session.Channel)
-	if err != nil {
-		s.logger.Error("background settlement failed", zap.Error(err))
+	// Cooperative close avoids the challenge period when the guest is still online
+	var closeTx types.Hash
+	var settleErr error
+	if s.dryRun != nil {
+		settleErr = s.dryRun.SettleChannel(session.ChannelID)
 	} else {
-		s.logger.Info("background settlement completed", zap.String("session_id", session.ID))
+		closeTx, settleErr = session.Client.SubmitCooperativeClose(ctx, session.Channel)
+	}
+	if settleErr != nil {
+		s.logger.Error("background settlement failed", zap.Error(settleErr))
+	} else {
+		s.logger.Info("background settlement completed",
+			zap.String("session_id", session.ID),
+			zap.String("tx_hash", closeTx.Hex()),
+		)
+		s.audit(systemActor, auditChannelClosed, session.ID, "", fmt.Sprintf("channel_id=%s tx_hash=%s", session.ChannelID, closeTx.Hex()))
 	}
 
-	s.db.SettleSession(session.ID)
-	session.Client.Close()
+	// Record the final balance together with the settled status
+	_, spentCKB, balanceCKB := session.wholeCKB()
+	err := s.db.Transaction(func(tx *db.DB) error {
+		if err := tx.UpdateSessionBalance(session.ID, balanceCKB, spentCKB); err != nil {
+			return fmt.Errorf("failed to update session balance: %w", err)
+		}
+		if err := tx.SettleSession(session.ID); err != nil {
+			return fmt.Errorf("failed to settle session: %w", err)
+		}
+		if closeTx != (types.Hash{}) {
+			if err := tx.RecordSettlementTx(session.ID, closeTx.Hex()); err != nil {
+				return fmt.Errorf("failed to record settlement tx: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("failed to record settlement", zap.String("session_id", session.ID), zap.Error(err))
+	}
+	if dbSession, err := s.db.GetSession(session.ID); err == nil {
+		s.forgetWalletLock(dbSession.WalletID)
+	}
+	s.guestClients.Put(session.Client)
 
 	// Try to withdraw remaining CKB
 	withdrawHash, err := s.withdrawToSender(context.Background(), session.ID)
@@ -176,21 +405,36 @@ func (s *Server) settleSessionInBackground(session *GuestSession) {
 	}
 
 	s.logger.Info("background settlement process completed", zap.String("session_id", session.ID))
+	return settleErr
 }
 
 // settleExpiredSession settles a channel when session expires.
 func (s *Server) settleExpiredSession(ctx context.Context, session *GuestSession) {
 	settleCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
 	defer cancel()
+	s.unpauseChannel(session)
+
+	var walletID string
+	if dbSession, err := s.db.GetSession(session.ID); err == nil {
+		walletID = dbSession.WalletID
+	}
+	s.audit(systemActor, auditSessionEnded, session.ID, walletID, "expired")
 
-	err := session.Client.SettleChannel(settleCtx, session.Channel)
+	var err error
+	if s.dryRun != nil {
+		err = s.dryRun.SettleChannel(session.ChannelID)
+	} else {
+		err = session.Client.SettleChannel(settleCtx, session.Channel)
+	}
 	if err != nil {
 		s.logger.Error("failed to settle channel", zap.String("session_id", session.ID), zap.Error(err))
 	} else {
 		s.logger.Info("channel settled", zap.String("session_id", session.ID))
+		s.audit(systemActor, auditChannelClosed, session.ID, walletID, "channel_id="+session.ChannelID.String())
 	}
 
 	s.db.SettleSession(session.ID)
+	s.forgetWalletLock(walletID)
 
 	// Deauthorize MAC
 	dbSession, err := s.db.GetSession(session.ID)
@@ -199,10 +443,11 @@ func (s *Server) settleExpiredSession(ctx context.Context, session *GuestSession
 			s.logger.Error("failed to deauthorize MAC", zap.Error(err), zap.String("mac", dbSession.MACAddress))
 		} else {
 			s.logger.Info("MAC deauthorized", zap.String("mac", dbSession.MACAddress))
+			s.audit(systemActor, auditMACDeauthorized, session.ID, walletID, "mac="+dbSession.MACAddress)
 		}
 	}
 
-	session.Client.Close()
+	s.guestClients.Put(session.Client)
 
 	// Try to withdraw remaining CKB
 	go func() {
@@ -221,8 +466,12 @@ func (s *Server) settleExpiredSession(ctx context.Context, session *GuestSession
 	}()
 }
 
-// withdrawToSender withdraws remaining CKB from guest wallet to sender.
+// withdrawToSender withdraws remaining CKB from guest wallet to sender and
+// waits until the refund is confirmed on chain.
 func (s *Server) withdrawToSender(ctx context.Context, sessionID string) (string, error) {
+	if s.dryRun != nil {
+		return "", fmt.Errorf("refunds are not sent in dry-run mode")
+	}
 	wallet, err := s.db.GetWalletBySessionID(sessionID)
 	if err != nil {
 		return "", fmt.Errorf("failed to get wallet: %w", err)
@@ -237,12 +486,17 @@ func (s *Server) withdrawToSender(ctx context.Context, sessionID string) (string
 	// Detect sender if not found
 	if wallet.SenderAddress == "" {
 		s.logger.Info("sender address not found, attempting detection...")
-		withdrawer := perun.NewWithdrawer(s.ckbClient, s.logger.Named("withdrawer"))
-		senderAddr, err := withdrawer.GetSenderAddress(ctx, wallet.Address, types.NetworkTest)
+		senderAddr, err := s.withdrawer.GetSenderAddress(ctx, wallet.Address, types.NetworkTest)
 		if err != nil {
 			return "", fmt.Errorf("no sender address: %w", err)
 		}
-		s.db.UpdateWalletSenderAddress(wallet.ID, senderAddr)
+		// Persist so a restart doesn't need another lookup
+		if err := s.db.UpdateWalletSenderAddress(wallet.ID, senderAddr); err != nil {
+			s.logger.Warn("failed to save sender address", zap.String("wallet_id", wallet.ID), zap.Error(err))
+		}
+		if err := s.db.UpdateSessionSenderAddress(sessionID, senderAddr); err != nil {
+			s.logger.Warn("failed to save session sender address", zap.String("session_id", sessionID), zap.Error(err))
+		}
 		wallet.SenderAddress = senderAddr
 	}
 
@@ -257,7 +511,7 @@ func (s *Server) withdrawToSender(ctx context.Context, sessionID string) (string
 		return "", fmt.Errorf("failed to decode wallet address: %w", err)
 	}
 
-	withdrawer := perun.NewWithdrawer(s.ckbClient, s.logger.Named("withdrawer"))
+	s.recordRefund(wallet.ID, db.RefundStatusProcessing, types.Hash{})
 
 	waitTimes := []time.Duration{30 * time.Second, 60 * time.Second, 120 * time.Second}
 	var lastErr error
@@ -270,7 +524,17 @@ func (s *Server) withdrawToSender(ctx context.Context, sessionID string) (string
 		)
 		time.Sleep(waitTime)
 
-		txHash, err := withdrawer.WithdrawAll(ctx, guestPrivKey, guestLockScript, wallet.SenderAddress)
+		txHash, err := s.withdrawer.WithdrawAllAndWait(ctx, guestPrivKey, guestLockScript, wallet.SenderAddress, perun.DefaultWithdrawConfirmations)
+		if err != nil && txHash != (types.Hash{}) {
+			// Submitted but not confirmed; resubmitting would double-spend the inputs
+			s.logger.Warn("refund submitted but not confirmed",
+				zap.String("session_id", sessionID),
+				zap.String("tx_hash", txHash.Hex()),
+				zap.Error(err),
+			)
+			s.recordRefund(wallet.ID, db.RefundStatusProcessing, txHash)
+			return txHash.Hex(), err
+		}
 		if err != nil {
 			lastErr = err
 			s.logger.Warn("withdrawal attempt failed",
@@ -282,12 +546,55 @@ func (s *Server) withdrawToSender(ctx context.Context, sessionID string) (string
 		}
 
 		s.db.UpdateWalletStatus(wallet.ID, "withdrawn")
+		s.recordRefund(wallet.ID, db.RefundStatusSent, txHash)
 		s.logger.Info("refund successful",
 			zap.String("session_id", sessionID),
 			zap.String("tx_hash", txHash.Hex()),
 		)
+		s.audit(systemActor, auditRefund, sessionID, wallet.ID, "tx_hash="+txHash.Hex())
 		return txHash.Hex(), nil
 	}
 
+	s.recordRefund(wallet.ID, db.RefundStatusFailed, types.Hash{})
 	return "", fmt.Errorf("failed to withdraw after %d attempts: %w", len(waitTimes), lastErr)
 }
+
+// recordRefund stores a wallet's refund status. For a submitted transaction
+// the refunded amount is read back from the chain; a zero hash leaves the
+// stored hash and amount as they are.
+func (s *Server) recordRefund(walletID, status string, txHash types.Hash) {
+	var hash string
+	var amount int64
+	if txHash != (types.Hash{}) {
+		hash = txHash.Hex()
+		amount = s.refundAmount(txHash)
+	}
+	s.storeRefund(walletID, status, hash, amount)
+}
+
+// storeRefund records a refund of amount shannons on the wallet.
+func (s *Server) storeRefund(walletID, status, txHash string, amount int64) {
+	if err := s.db.UpdateWalletRefund(walletID, status, txHash, amount); err != nil {
+		s.logger.Warn("failed to record refund status",
+			zap.String("wallet_id", walletID),
+			zap.String("status", status),
+			zap.Error(err),
+		)
+	}
+}
+
+// refundAmount returns the shannons a refund transaction pays out, or zero
+// if the transaction can't be fetched. Refunds have a single output.
+func (s *Server) refundAmount(txHash types.Hash) int64 {
+	if s.ckbClient == nil {
+		return 0
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	tx, err := s.ckbClient.GetTransaction(ctx, txHash)
+	if err != nil || tx == nil || tx.Transaction == nil || len(tx.Transaction.Outputs) == 0 {
+		s.logger.Warn("failed to look up refund amount", zap.String("tx_hash", txHash.Hex()), zap.Error(err))
+		return 0
+	}
+	return int64(tx.Transaction.Outputs[0].Capacity)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/airfi/airfi-perun-nervous/internal/auth"
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+)
+
+func TestHandleUpdateSessionExpiry(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+
+	s.db.CreateSession(&db.Session{
+		ID:        "sess-expiry",
+		WalletID:  "wallet-expiry",
+		Status:    "active",
+		ExpiresAt: time.Now().Add(10 * time.Minute),
+	})
+	s.db.CreateSession(&db.Session{
+		ID:        "sess-settled",
+		WalletID:  "wallet-settled",
+		Status:    "settled",
+		ExpiresAt: time.Now().Add(-time.Minute),
+	})
+	s.sessions["sess-expiry"] = &GuestSession{ID: "sess-expiry", ExpiresAt: time.Now().Add(10 * time.Minute)}
+
+	r := gin.New()
+	admin := r.Group("/api/v1/admin", s.dashboardAuthMiddleware(auth.APIKeyScopeAdmin))
+	admin.PUT("/sessions/:sessionId/expiry", s.handleUpdateSessionExpiry)
+
+	update := func(sessionID string, expiresAt string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"expires_at":%q}`, expiresAt)
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/admin/sessions/"+sessionID+"/expiry", strings.NewReader(body))
+		req.AddCookie(hostCookie(t, s))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	now := time.Now()
+	tests := []struct {
+		name      string
+		sessionID string
+		expiresAt string
+		want      int
+	}{
+		{"just over one minute", "sess-expiry", now.Add(time.Minute + 5*time.Second).Format(time.RFC3339), http.StatusOK},
+		{"just under max session time", "sess-expiry", now.Add(s.maxSessionTime - 5*time.Second).Format(time.RFC3339), http.StatusOK},
+		{"under one minute", "sess-expiry", now.Add(30 * time.Second).Format(time.RFC3339), http.StatusBadRequest},
+		{"in the past", "sess-expiry", now.Add(-time.Hour).Format(time.RFC3339), http.StatusBadRequest},
+		{"over max session time", "sess-expiry", now.Add(s.maxSessionTime + time.Minute).Format(time.RFC3339), http.StatusBadRequest},
+		{"not RFC3339", "sess-expiry", "tomorrow", http.StatusBadRequest},
+		{"unknown session", "missing", now.Add(time.Hour).Format(time.RFC3339), http.StatusNotFound},
+		{"settled session", "sess-settled", now.Add(time.Hour).Format(time.RFC3339), http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := update(tt.sessionID, tt.expiresAt); w.Code != tt.want {
+				t.Errorf("Expected %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	newExpiry := now.Add(3 * time.Hour).Truncate(time.Second)
+	if w := update("sess-expiry", newExpiry.Format(time.RFC3339)); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	dbSession, _ := s.db.GetSession("sess-expiry")
+	if !dbSession.ExpiresAt.Equal(newExpiry) {
+		t.Errorf("Database expiry: expected %v, got %v", newExpiry, dbSession.ExpiresAt)
+	}
+	if !s.sessions["sess-expiry"].ExpiresAt.Equal(newExpiry) {
+		t.Errorf("In-memory expiry: expected %v, got %v", newExpiry, s.sessions["sess-expiry"].ExpiresAt)
+	}
+
+	entries, _ := s.db.ListAuditLog(&db.AuditQuery{SessionID: "sess-expiry"})
+	if len(entries) != 3 || entries[2].Action != auditSessionExpiryChanged {
+		t.Errorf("Expected 3 expiry changes in the audit log, got %d", len(entries))
+	}
+}
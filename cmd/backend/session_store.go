@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"math/big"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// restoreSessionStore loads the session store snapshot, if persistence is
+// enabled and a snapshot exists.
+func (s *Server) restoreSessionStore() {
+	if s.sessionStorePath == "" {
+		return
+	}
+
+	f, err := os.Open(s.sessionStorePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		s.logger.Error("failed to open session store snapshot", zap.String("path", s.sessionStorePath), zap.Error(err))
+		return
+	}
+	defer f.Close()
+
+	if err := s.sessionStore.Restore(f); err != nil {
+		s.logger.Error("failed to restore session store", zap.String("path", s.sessionStorePath), zap.Error(err))
+		return
+	}
+	s.logger.Info("session store restored",
+		zap.String("path", s.sessionStorePath),
+		zap.Int("sessions", s.sessionStore.Count()),
+	)
+}
+
+// persistSessionStore writes the session store snapshot, if persistence is
+// enabled. It writes to a temporary file first so a failed write can't
+// clobber the previous snapshot.
+func (s *Server) persistSessionStore() {
+	if s.sessionStorePath == "" {
+		return
+	}
+
+	tmpPath := s.sessionStorePath + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		s.logger.Error("failed to create session store snapshot", zap.String("path", tmpPath), zap.Error(err))
+		return
+	}
+	if err := s.sessionStore.Persist(f); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		s.logger.Error("failed to persist session store", zap.Error(err))
+		return
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		s.logger.Error("failed to write session store snapshot", zap.Error(err))
+		return
+	}
+	if err := os.Rename(tmpPath, s.sessionStorePath); err != nil {
+		s.logger.Error("failed to replace session store snapshot", zap.Error(err))
+		return
+	}
+	s.logger.Info("session store persisted",
+		zap.String("path", s.sessionStorePath),
+		zap.Int("sessions", s.sessionStore.Count()),
+	)
+}
+
+// storeSessionOpened mirrors a session whose channel just opened into the
+// session store. The catch-up payment covers time before the channel
+// opened, so the stored session only counts payments from now on.
+func (s *Server) storeSessionOpened(gs *GuestSession) {
+	if _, err := s.sessionStore.Add(gs.ID, gs.ChannelID.String(), gs.GuestAddress); err != nil {
+		s.logger.Warn("failed to add session to store", zap.String("session_id", gs.ID), zap.Error(err))
+		return
+	}
+	if err := s.sessionStore.Activate(gs.ID, gs.TimeUntilExpiry(), "", new(big.Int)); err != nil {
+		s.logger.Warn("failed to activate stored session", zap.String("session_id", gs.ID), zap.Error(err))
+		return
+	}
+
+	// The fraud detector compares payments against this rate
+	s.sessionsMu.RLock()
+	ratePerMin := s.ratePerMin
+	s.sessionsMu.RUnlock()
+	s.sessionStore.SetRate(gs.ID, ratePerMin)
+}
+
+// storeSessionPaid adds a per-minute payment to the stored session.
+func (s *Server) storeSessionPaid(sessionID string, paid *big.Int) {
+	if err := s.sessionStore.Extend(sessionID, 0, paid); err != nil {
+		s.logger.Debug("stored session not updated", zap.String("session_id", sessionID), zap.Error(err))
+	}
+}
+
+// storeSessionExtended moves the stored session's expiry by d. Extensions
+// pay for time ahead, so their payment isn't added: the stored session's
+// payment velocity only follows the per-minute payments.
+func (s *Server) storeSessionExtended(sessionID string, d time.Duration) {
+	if err := s.sessionStore.Extend(sessionID, d, new(big.Int)); err != nil {
+		s.logger.Debug("stored session not updated", zap.String("session_id", sessionID), zap.Error(err))
+	}
+}
+
+// storeSessionToken indexes the stored session under its latest access
+// token.
+func (s *Server) storeSessionToken(sessionID, token string) {
+	if err := s.sessionStore.SetToken(sessionID, token); err != nil {
+		s.logger.Debug("stored session token not set", zap.String("session_id", sessionID), zap.Error(err))
+	}
+}
+
+// storeSessionEnded ends the stored session, as expired if its time ran out.
+func (s *Server) storeSessionEnded(sessionID string, expired bool) {
+	end := s.sessionStore.End
+	if expired {
+		end = s.sessionStore.MarkExpired
+	}
+	if err := end(sessionID); err != nil {
+		s.logger.Debug("stored session not ended", zap.String("session_id", sessionID), zap.Error(err))
+	}
+}
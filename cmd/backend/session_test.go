@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
+)
+
+func TestHandleGetSession_PendingPayments(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+
+	s.db.CreateSession(&db.Session{
+		ID:        "session-1",
+		Status:    "active",
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	s.sessions["session-1"] = &GuestSession{
+		ID:            "session-1",
+		Client:        &perun.ChannelClient{},
+		FundingAmount: big.NewInt(0),
+		TotalPaid:     big.NewInt(0),
+	}
+
+	r := gin.New()
+	r.GET("/api/v1/sessions/:sessionId", s.handleGetSession)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, hostRequest(t, s, http.MethodGet, "/api/v1/sessions/session-1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		PendingCount    *int   `json:"pending_payments_count"`
+		PendingShannons string `json:"pending_shannons"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if resp.PendingCount == nil || *resp.PendingCount != 0 || resp.PendingShannons != "0" {
+		t.Errorf("Expected no pending payments, got %s", w.Body.String())
+	}
+}
+
+func TestPendingPayments_NoClient(t *testing.T) {
+	if count, total := pendingPayments(nil); count != 0 || total.Sign() != 0 {
+		t.Errorf("Expected nothing pending for missing session, got %d (%s)", count, total)
+	}
+	if count, _ := pendingPayments(&GuestSession{ID: "session-1"}); count != 0 {
+		t.Errorf("Expected nothing pending without a channel client, got %d", count)
+	}
+}
+
+func TestSessionStoreSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.json")
+
+	s := newTestServer(t)
+	s.sessionStorePath = path
+	sess, _ := s.sessionStore.Create("channel-1", "ckt1guest")
+	s.sessionStore.Activate(sess.ID, time.Hour, "token", big.NewInt(100000000))
+	s.persistSessionStore()
+
+	restarted := newTestServer(t)
+	restarted.sessionStorePath = path
+	restarted.restoreSessionStore()
+
+	got, err := restarted.sessionStore.Get(sess.ID)
+	if err != nil {
+		t.Fatalf("session not restored: %v", err)
+	}
+	if got.TotalPaid.Cmp(big.NewInt(100000000)) != 0 || !got.IsActive() {
+		t.Errorf("unexpected restored session: %+v", got)
+	}
+}
+
+func TestSessionStoreSnapshot_Missing(t *testing.T) {
+	s := newTestServer(t)
+	s.sessionStorePath = filepath.Join(t.TempDir(), "missing.json")
+	s.restoreSessionStore()
+	if s.sessionStore.Count() != 0 {
+		t.Errorf("Expected empty store, got %d sessions", s.sessionStore.Count())
+	}
+}
+
+func TestGuestSession_WholeCKB(t *testing.T) {
+	// Funding beyond int64 used to wrap when converted with Int64()
+	funding := new(big.Int).Mul(big.NewInt(1<<62), big.NewInt(4))
+	session := &GuestSession{
+		FundingAmount: funding,
+		TotalPaid:     big.NewInt(150000000),
+	}
+
+	fundingCKB, spentCKB, balanceCKB := session.wholeCKB()
+	wantFunding := new(big.Int).Quo(funding, big.NewInt(100000000)).Int64()
+	if fundingCKB != wantFunding || spentCKB != 1 {
+		t.Errorf("Expected funding %d and spent 1, got %d and %d", wantFunding, fundingCKB, spentCKB)
+	}
+	wantBalance := new(big.Int).Quo(new(big.Int).Sub(funding, big.NewInt(150000000)), big.NewInt(100000000)).Int64()
+	if balanceCKB != wantBalance {
+		t.Errorf("Expected balance %d, got %d", wantBalance, balanceCKB)
+	}
+	if got := session.TotalPaidCKB(); got != 1.5 {
+		t.Errorf("Expected 1.5 CKB paid, got %v", got)
+	}
+	if got := session.RemainingBalanceCKB(); got <= 0 {
+		t.Errorf("Expected positive remaining balance, got %v", got)
+	}
+}
+
+func TestServer_SetRate_ConcurrentWithMicropayments(t *testing.T) {
+	s := newTestServer(t)
+	// A paused session keeps micropayments reading the rate without a channel
+	s.sessions["session-1"] = &GuestSession{
+		ID:            "session-1",
+		FundingAmount: big.NewInt(1000 * 100000000),
+		TotalPaid:     new(big.Int),
+		ExpiresAt:     time.Now().Add(time.Hour),
+		Paused:        true,
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				if err := s.SetRate(int64(100 + i*100 + j)); err != nil {
+					t.Errorf("SetRate failed: %v", err)
+				}
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				s.processMicropayments(context.Background())
+
+				// Under the lock the stored and in-memory rates always agree
+				s.sessionsMu.Lock()
+				stored, _ := s.db.GetRatePerHour()
+				if want := rateConfig(stored, 0, 0).CKBytesPerMinute; s.ratePerMin.Cmp(want) != 0 {
+					t.Errorf("Rate %d/h stored but %s shannons/min in memory", stored, s.ratePerMin)
+				}
+				s.sessionsMu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, ok := s.sessions["session-1"]; !ok {
+		t.Error("Expected the paused session to stay active")
+	}
+}
+
+func TestGuestSession_Expiry(t *testing.T) {
+	gs := &GuestSession{ExpiresAt: time.Now().Add(time.Minute)}
+	if gs.IsExpired() || gs.TimeUntilExpiry() <= 0 {
+		t.Error("Expected a session with a minute left not to be expired")
+	}
+
+	gs.ExpiresAt = time.Now().Add(-time.Second)
+	if !gs.IsExpired() || gs.TimeUntilExpiry() != 0 {
+		t.Errorf("Expected an expired session with no time left, got %v", gs.TimeUntilExpiry())
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/airfi/airfi-perun-nervous/internal/auth"
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+	"github.com/airfi/airfi-perun-nervous/tests/mocks"
+)
+
+func TestHandleTransferSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+	mockRouter := mocks.NewMockRouter()
+	s.router = mockRouter
+
+	const oldMAC, newMAC = "aa:aa:aa:aa:aa:aa", "bb:bb:bb:bb:bb:bb"
+	s.db.CreateGuestWallet(&db.GuestWallet{ID: "w-phone", Address: "ckt1phone", Status: "channel_open", MACAddress: oldMAC, IPAddress: "10.0.0.2"})
+	s.db.CreateGuestWallet(&db.GuestWallet{ID: "w-laptop", Address: "ckt1laptop", Status: "created", MACAddress: newMAC, IPAddress: "10.0.0.3"})
+	s.db.CreateGuestWallet(&db.GuestWallet{ID: "w-used", Address: "ckt1used", Status: "channel_open", SessionID: "other", MACAddress: "cc:cc:cc:cc:cc:cc"})
+	s.db.CreateGuestWallet(&db.GuestWallet{ID: "w-nomac", Address: "ckt1nomac", Status: "created"})
+	s.db.CreateSession(&db.Session{ID: "sess-transfer", WalletID: "w-phone", MACAddress: oldMAC, IPAddress: "10.0.0.2", Status: "active"})
+	mockRouter.AuthorizeMAC(context.Background(), oldMAC, "10.0.0.2", "", "")
+
+	r := gin.New()
+	admin := r.Group("/api/v1/admin", s.dashboardAuthMiddleware(auth.APIKeyScopeAdmin))
+	admin.POST("/sessions/:sessionId/transfer", s.handleTransferSession)
+
+	transfer := func(sessionID, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/sessions/"+sessionID+"/transfer", strings.NewReader(body))
+		req.AddCookie(hostCookie(t, s))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := transfer("sess-transfer", `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without to_wallet_id, got %d", w.Code)
+	}
+	if w := transfer("sess-transfer", `{"to_wallet_id":"missing"}`); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown wallet, got %d", w.Code)
+	}
+	if w := transfer("missing", `{"to_wallet_id":"w-laptop"}`); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown session, got %d", w.Code)
+	}
+	for _, walletID := range []string{"w-used", "w-nomac"} {
+		if w := transfer("sess-transfer", `{"to_wallet_id":"`+walletID+`"}`); w.Code != http.StatusConflict {
+			t.Errorf("%s: expected 409, got %d: %s", walletID, w.Code, w.Body.String())
+		}
+	}
+
+	w := transfer("sess-transfer", `{"to_wallet_id":"w-laptop"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if mockRouter.IsAuthorized(oldMAC) {
+		t.Error("Expected old MAC deauthorized")
+	}
+	if !mockRouter.IsAuthorized(newMAC) {
+		t.Error("Expected new MAC authorized")
+	}
+	sess, _ := s.db.GetSession("sess-transfer")
+	if sess.WalletID != "w-laptop" || sess.MACAddress != newMAC {
+		t.Errorf("Session not transferred: %+v", sess)
+	}
+	if from, _ := s.db.GetGuestWallet("w-phone"); from.Status != "transferred" {
+		t.Errorf("Expected old wallet transferred, got %s", from.Status)
+	}
+
+	// Transferring back onto the wallet it already belongs to is rejected
+	if w := transfer("sess-transfer", `{"to_wallet_id":"w-laptop"}`); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for same wallet, got %d", w.Code)
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/airfi/airfi-perun-nervous/internal/auth"
+	"github.com/airfi/airfi-perun-nervous/internal/config"
+	"github.com/airfi/airfi-perun-nervous/internal/session"
+)
+
+func TestHandleUpdateChannelSetup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+
+	r := gin.New()
+	r.PUT("/api/v1/settings/channel-setup", s.handleUpdateChannelSetup)
+
+	tests := []struct {
+		name     string
+		host     bool
+		body     string
+		expected int
+	}{
+		{"unauthorized", false, `{"channel_setup_ckb": 800}`, http.StatusUnauthorized},
+		{"zero", true, `{"channel_setup_ckb": 0}`, http.StatusBadRequest},
+		{"valid", true, `{"channel_setup_ckb": 800}`, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/api/v1/settings/channel-setup", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.host {
+				req.AddCookie(hostCookie(t, s))
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("Expected %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	if s.channelSetupCKB != 800 {
+		t.Errorf("channelSetupCKB: expected 800, got %d", s.channelSetupCKB)
+	}
+	if stored, err := s.db.GetChannelSetupCKB(); err != nil || stored != 800 {
+		t.Errorf("Stored channel setup: expected 800, got %d (%v)", stored, err)
+	}
+}
+
+func TestHandleUpdateRate_Invalid(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+
+	r := gin.New()
+	r.PUT("/api/v1/settings/rate", s.handleUpdateRate)
+
+	for _, body := range []string{`{"rate_per_hour": 0}`, `{"rate_per_hour": -10}`} {
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/settings/rate", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(hostCookie(t, s))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("%s: expected 422, got %d: %s", body, w.Code, w.Body.String())
+		}
+		var resp struct {
+			Field string `json:"field"`
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if resp.Field != "rate_per_hour" || resp.Error != "must be positive" {
+			t.Errorf("%s: unexpected response %+v", body, resp)
+		}
+	}
+
+	// A rate whose shannons per minute would overflow is rejected too
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/settings/rate", bytes.NewBufferString(`{"rate_per_hour": 9223372036854775807}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(hostCookie(t, s))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), `"field":"rate_per_hour"`) {
+		t.Errorf("Expected 422 for rate_per_hour, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if rate, _ := s.db.GetRatePerHour(); rate <= 0 || rate >= config.MaxRatePerHour {
+		t.Errorf("Expected invalid rate not to be stored, got %d", rate)
+	}
+}
+
+func TestNewServer_InvalidRateUsesDefault(t *testing.T) {
+	s, err := NewServer(&ServerConfig{Logger: zap.NewNop(), RatePerHour: 0})
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+
+	expected := big.NewInt(session.DefaultRatePerHourCKB * 100000000 / 60)
+	if s.ratePerMin.Cmp(expected) != 0 {
+		t.Errorf("Expected rate per minute %s, got %s", expected, s.ratePerMin)
+	}
+}
+
+func TestHandleUpdateDashboardPassword(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+	setTestDashboardPassword(t, s, "old-password")
+
+	r := gin.New()
+	r.PUT("/api/v1/admin/password", s.handleUpdateDashboardPassword)
+
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/admin/password", bytes.NewBufferString(`{"password": "short"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Short password: expected 400, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/admin/password", bytes.NewBufferString(`{"password": "new-password"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !s.checkDashboardPassword("new-password") || s.checkDashboardPassword("old-password") {
+		t.Errorf("dashboard password not updated")
+	}
+	if stored, _ := s.db.GetSetting(settingDashboardPassword); stored == "new-password" {
+		t.Errorf("dashboard password stored in plaintext")
+	}
+}
+
+// setTestDashboardPassword sets the dashboard password of s.
+func setTestDashboardPassword(t *testing.T, s *Server, password string) {
+	t.Helper()
+	hash, err := hashDashboardPassword(password)
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+	s.setDashboardPasswordHash(hash)
+}
+
+func TestLoadDashboardPasswordHash(t *testing.T) {
+	s := newTestServer(t)
+
+	hash, err := loadDashboardPasswordHash(s.db, "configured-pw")
+	if err != nil || bcrypt.CompareHashAndPassword(hash, []byte("configured-pw")) != nil {
+		t.Fatalf("Expected the configured password hashed, got %v", err)
+	}
+
+	// A plaintext password from an older version is hashed in place
+	s.db.SetSetting(settingDashboardPassword, "legacy-pw")
+	hash, err = loadDashboardPasswordHash(s.db, "configured-pw")
+	if err != nil || bcrypt.CompareHashAndPassword(hash, []byte("legacy-pw")) != nil {
+		t.Fatalf("Expected the stored password used, got %v", err)
+	}
+	if stored, _ := s.db.GetSetting(settingDashboardPassword); stored != string(hash) {
+		t.Errorf("Expected the stored password rewritten as its hash, got %q", stored)
+	}
+}
+
+func TestHandleUpdateRate_APIKeyRateLimited(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+	s.apiKeys = auth.NewAPIKeyService(apiKeyStore{s.db}, 1)
+	key, _, err := s.apiKeys.Generate("ops", nil)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	r := gin.New()
+	r.PUT("/api/v1/settings/rate", s.handleUpdateRate)
+
+	for i, expected := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/settings/rate", bytes.NewBufferString(`{"rate_per_hour": 100}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+key)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != expected {
+			t.Fatalf("Request %d: expected %d, got %d: %s", i+1, expected, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+	readKey, _, err := s.apiKeys.Generate("monitoring", []string{auth.APIKeyScopeRead})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	adminKey, _, err := s.apiKeys.Generate("ops", nil)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	r := gin.New()
+	r.GET("/api/v1/admin/audit-log", s.dashboardAuthMiddleware(auth.APIKeyScopeRead), s.handleAuditLog)
+	r.POST("/api/v1/admin/keys", s.dashboardAuthMiddleware(auth.APIKeyScopeAdmin), s.handleCreateAPIKey)
+
+	for _, tc := range []struct {
+		name, method, path, key, body string
+		expected                      int
+	}{
+		{"read key reads", http.MethodGet, "/api/v1/admin/audit-log", readKey, "", http.StatusOK},
+		{"read key can't create keys", http.MethodPost, "/api/v1/admin/keys", readKey, `{"label": "x"}`, http.StatusForbidden},
+		{"admin key reads", http.MethodGet, "/api/v1/admin/audit-log", adminKey, "", http.StatusOK},
+		{"unknown scope", http.MethodPost, "/api/v1/admin/keys", adminKey, `{"label": "x", "scopes": ["write"]}`, http.StatusBadRequest},
+		{"read scope", http.MethodPost, "/api/v1/admin/keys", adminKey, `{"label": "x", "scopes": ["read"]}`, http.StatusCreated},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+tc.key)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.expected {
+			t.Errorf("%s: expected %d, got %d: %s", tc.name, tc.expected, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestSearchSessions_RequiresDashboardAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+	r := gin.New()
+	s.setupRoutes(r)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/sessions/search", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected 401 without credentials, got %d", w.Code)
+	}
+
+	key, _, _ := s.apiKeys.Generate("monitoring", []string{auth.APIKeyScopeRead})
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/sessions/search", nil)
+	req.Header.Set("Authorization", "Bearer "+key)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 with a read key, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHandleSettleAllSessions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+
+	r := gin.New()
+	r.POST("/api/v1/admin/sessions/settle-all", s.handleSettleAllSessions)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/admin/sessions/settle-all", nil))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Sessions int `json:"sessions"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if resp.Sessions != 0 {
+		t.Errorf("unexpected summary: %s", w.Body.String())
+	}
+
+	// The lock is released once the background run finishes
+	deadline := time.Now().Add(5 * time.Second)
+	for !s.settleAllMu.TryLock() {
+		if time.Now().After(deadline) {
+			t.Fatal("settle-all did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.settleAllMu.Unlock()
+}
+
+func TestHandleSettleAllSessions_AlreadyRunning(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+
+	r := gin.New()
+	r.POST("/api/v1/admin/sessions/settle-all", s.handleSettleAllSessions)
+
+	s.settleAllMu.Lock()
+	defer s.settleAllMu.Unlock()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/admin/sessions/settle-all", nil))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected 409, got %d: %s", w.Code, w.Body.String())
+	}
+}
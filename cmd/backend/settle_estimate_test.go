@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHandleEstimateSettlement(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+
+	// 10 CKB left of the funding
+	s.sessions["session-1"] = &GuestSession{
+		ID:            "session-1",
+		FundingAmount: big.NewInt(100 * 100000000),
+		TotalPaid:     big.NewInt(90 * 100000000),
+	}
+
+	r := gin.New()
+	r.GET("/api/v1/sessions/:sessionId/settle/estimate", s.handleEstimateSettlement)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, hostRequest(t, s, http.MethodGet, path, nil))
+		return w
+	}
+
+	tests := []struct {
+		name       string
+		fee        uint64
+		wantAfford bool
+	}{
+		{"fee below balance", 10000, true},
+		{"fee equals balance", 10 * 100000000, true},
+		{"fee above balance", 11 * 100000000, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.settlementFee = func(ctx context.Context, session *GuestSession) (uint64, error) {
+				return tt.fee, nil
+			}
+			w := get("/api/v1/sessions/session-1/settle/estimate")
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+			}
+			var resp struct {
+				EstimatedFeeShannons uint64  `json:"estimated_fee_shannons"`
+				EstimatedFeeCKB      float64 `json:"estimated_fee_ckb"`
+				BalanceCKB           float64 `json:"balance_ckb"`
+				CanAfford            bool    `json:"can_afford"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.EstimatedFeeShannons != tt.fee {
+				t.Errorf("estimated_fee_shannons = %d, want %d", resp.EstimatedFeeShannons, tt.fee)
+			}
+			if resp.EstimatedFeeCKB != float64(tt.fee)/100000000 {
+				t.Errorf("estimated_fee_ckb = %v, want %v", resp.EstimatedFeeCKB, float64(tt.fee)/100000000)
+			}
+			if resp.BalanceCKB != 10 {
+				t.Errorf("balance_ckb = %v, want 10", resp.BalanceCKB)
+			}
+			if resp.CanAfford != tt.wantAfford {
+				t.Errorf("can_afford = %v, want %v", resp.CanAfford, tt.wantAfford)
+			}
+		})
+	}
+
+	s.settlementFee = func(ctx context.Context, session *GuestSession) (uint64, error) {
+		return 0, errors.New("rpc unavailable")
+	}
+	if w := get("/api/v1/sessions/session-1/settle/estimate"); w.Code != http.StatusBadGateway {
+		t.Errorf("Expected 502 when estimation fails, got %d", w.Code)
+	}
+
+	if w := get("/api/v1/sessions/unknown/settle/estimate"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown session, got %d", w.Code)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+)
+
+// defaultShutdownTimeout bounds settling channels on shutdown when no
+// timeout is configured.
+const defaultShutdownTimeout = 5 * time.Minute
+
+// closeAllChannels settles the channel of every active session so none are
+// left open on-chain until their challenge period expires, then revokes the
+// guest's WiFi access and refunds the remainder like settleSession. Sessions
+// are settled in parallel under one shared timeout; a failure is logged and
+// the session kept so the session store snapshot and reconciler still cover
+// it.
+func (s *Server) closeAllChannels() {
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+
+	s.sessionsMu.RLock()
+	sessions := make([]*GuestSession, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+	s.sessionsMu.RUnlock()
+
+	s.logger.Info("settling all channels before shutdown",
+		zap.Int("count", len(sessions)),
+		zap.Duration("timeout", s.shutdownTimeout),
+	)
+
+	var wg sync.WaitGroup
+	for _, session := range sessions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.unpauseChannel(session)
+			if err := s.channelCloser(ctx, session); err != nil {
+				s.logger.Error("failed to settle channel on shutdown",
+					zap.String("session_id", session.ID),
+					zap.Error(err),
+				)
+				return
+			}
+
+			s.sessionsMu.Lock()
+			delete(s.sessions, session.ID)
+			s.sessionsMu.Unlock()
+			s.storeSessionEnded(session.ID, false)
+			s.guestClients.Put(session.Client)
+
+			_, spentCKB, balanceCKB := session.wholeCKB()
+			err := s.db.Transaction(func(tx *db.DB) error {
+				if err := tx.UpdateSessionBalance(session.ID, balanceCKB, spentCKB); err != nil {
+					return fmt.Errorf("failed to update session balance: %w", err)
+				}
+				if err := tx.SettleSession(session.ID); err != nil {
+					return fmt.Errorf("failed to settle session: %w", err)
+				}
+				return nil
+			})
+			if err != nil {
+				s.logger.Error("failed to record settlement", zap.String("session_id", session.ID), zap.Error(err))
+			}
+			s.audit(systemActor, auditChannelClosed, session.ID, "", "shutdown")
+
+			if dbSession, err := s.db.GetSession(session.ID); err == nil {
+				s.revokeAccess(ctx, dbSession, systemActor)
+			}
+			if txHash, err := s.withdrawToSender(ctx, session.ID); err != nil {
+				s.logger.Info("refund on shutdown skipped", zap.String("session_id", session.ID), zap.String("note", err.Error()))
+			} else {
+				s.logger.Info("refund on shutdown sent", zap.String("session_id", session.ID), zap.String("tx_hash", txHash))
+			}
+		}()
+	}
+	wg.Wait()
+}
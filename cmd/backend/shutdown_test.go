@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+	"github.com/airfi/airfi-perun-nervous/tests/mocks"
+)
+
+func TestCloseAllChannels(t *testing.T) {
+	s := newTestServer(t)
+
+	router := mocks.NewMockRouter()
+	s.router = router
+
+	ids := []string{"sess-a", "sess-b", "sess-c"}
+	macs := map[string]string{"sess-a": "aa:aa:aa:aa:aa:aa", "sess-b": "bb:bb:bb:bb:bb:bb", "sess-c": "cc:cc:cc:cc:cc:cc"}
+	for _, id := range ids {
+		s.db.CreateSession(&db.Session{ID: id, Status: "active", MACAddress: macs[id]})
+		router.AuthorizeMAC(context.Background(), macs[id], "", "", "")
+		s.sessions[id] = &GuestSession{
+			ID:            id,
+			FundingAmount: big.NewInt(1000 * 100000000),
+			TotalPaid:     big.NewInt(250 * 100000000),
+		}
+	}
+
+	var mu sync.Mutex
+	closed := make(map[string]int)
+	s.channelCloser = func(ctx context.Context, session *GuestSession) error {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("Expected the close to run under the shutdown timeout")
+		}
+		mu.Lock()
+		closed[session.ID]++
+		mu.Unlock()
+		if session.ID == "sess-b" {
+			return errors.New("peer unreachable")
+		}
+		return nil
+	}
+
+	s.closeAllChannels()
+
+	for _, id := range ids {
+		if closed[id] != 1 {
+			t.Errorf("%s: expected 1 settlement call, got %d", id, closed[id])
+		}
+	}
+
+	for _, id := range []string{"sess-a", "sess-c"} {
+		dbSession, _ := s.db.GetSession(id)
+		if dbSession.Status != "settled" || dbSession.SpentCKB != 250 || dbSession.BalanceCKB != 750 {
+			t.Errorf("%s: expected settled with 250 spent, got %+v", id, dbSession)
+		}
+		if _, exists := s.sessions[id]; exists {
+			t.Errorf("%s: expected the session removed from the active sessions", id)
+		}
+		if router.IsAuthorized(macs[id]) {
+			t.Errorf("%s: expected the MAC deauthorized", id)
+		}
+	}
+
+	// A failed settlement leaves the session for the store snapshot
+	dbSession, _ := s.db.GetSession("sess-b")
+	if dbSession.Status != "active" {
+		t.Errorf("Expected sess-b to stay active, got %s", dbSession.Status)
+	}
+	if _, exists := s.sessions["sess-b"]; !exists {
+		t.Error("Expected sess-b kept in the active sessions")
+	}
+	if !router.IsAuthorized(macs["sess-b"]) {
+		t.Error("Expected sess-b to keep its access")
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// handleQuickStats returns session counts and earnings without loading any
+// sessions, for dashboards that poll.
+func (s *Server) handleQuickStats(c *gin.Context) {
+	total, active, earned, err := s.db.GetStats()
+	if err != nil {
+		s.logger.Error("failed to get session stats", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get stats"})
+		return
+	}
+	pending, err := s.db.GetSessionCountByStatus("pending_funding")
+	if err != nil {
+		s.logger.Error("failed to count pending sessions", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"active_sessions":  active,
+		"pending_sessions": pending,
+		"total_sessions":   total,
+		"total_earned_ckb": earned,
+	})
+}
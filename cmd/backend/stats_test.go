@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+)
+
+func TestHandleQuickStats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+
+	for i, status := range []string{"active", "active", "pending_funding", "settled"} {
+		s.db.CreateSession(&db.Session{
+			ID:        "session-" + string(rune('a'+i)),
+			WalletID:  "wallet-" + string(rune('a'+i)),
+			Status:    status,
+			SpentCKB:  10,
+			ExpiresAt: time.Now().Add(time.Hour),
+		})
+	}
+
+	r := gin.New()
+	r.GET("/api/v1/stats/quick", s.handleQuickStats)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/stats/quick", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		ActiveSessions  int   `json:"active_sessions"`
+		PendingSessions int   `json:"pending_sessions"`
+		TotalSessions   int   `json:"total_sessions"`
+		TotalEarnedCKB  int64 `json:"total_earned_ckb"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.ActiveSessions != 2 || resp.PendingSessions != 1 || resp.TotalSessions != 4 || resp.TotalEarnedCKB != 40 {
+		t.Errorf("Unexpected stats: %s", w.Body.String())
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleLookupSessionByToken maps a raw access token to the session it was
+// issued to without validating it, for debugging tokens guests report. The
+// session store indexes the tokens of sessions with an open channel; other
+// tokens are matched by ID against the last token issued for each session.
+func (s *Server) handleLookupSessionByToken(c *gin.Context) {
+	var req struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+		return
+	}
+
+	sessionID, source := "", "store"
+	if stored, err := s.sessionStore.GetByToken(req.Token); err == nil {
+		sessionID = stored.ID
+	} else {
+		claims, err := s.jwt().ParseUnverified(req.Token)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "malformed token"})
+			return
+		}
+		dbSession, err := s.db.GetSessionByToken(claims.ID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no session holds this token"})
+			return
+		}
+		sessionID, source = dbSession.ID, "database"
+	}
+
+	dbSession, err := s.db.GetSession(sessionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id": dbSession.ID,
+		"status":     dbSession.Status,
+		"expires_at": dbSession.ExpiresAt.Format(time.RFC3339),
+		"source":     source,
+	})
+}
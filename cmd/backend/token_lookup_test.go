@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+)
+
+func TestHandleLookupSessionByToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+	s.jwtService = newTestJWTService(t)
+
+	for _, id := range []string{"session-1", "session-2"} {
+		s.db.CreateSession(&db.Session{
+			ID:        id,
+			WalletID:  "wallet-" + id,
+			Status:    "active",
+			CreatedAt: time.Now(),
+			ExpiresAt: time.Now().Add(time.Hour),
+		})
+	}
+	// Only session-1 has an open channel mirrored in the store
+	s.sessionStore.Add("session-1", "", "")
+	s.sessionStore.Activate("session-1", time.Hour, "", new(big.Int))
+
+	tokens := make(map[string]string)
+	for _, id := range []string{"session-1", "session-2"} {
+		dbSession, _ := s.db.GetSession(id)
+		token, _, err := s.issueAccessToken(dbSession)
+		if err != nil {
+			t.Fatalf("issueAccessToken: %v", err)
+		}
+		tokens[id] = token
+	}
+
+	r := gin.New()
+	r.POST("/api/v1/admin/sessions/lookup-token", s.handleLookupSessionByToken)
+	lookup := func(token string) (int, string, string) {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/sessions/lookup-token", bytes.NewBufferString(`{"token":"`+token+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var resp struct {
+			SessionID string `json:"session_id"`
+			Source    string `json:"source"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp.SessionID, resp.Source
+	}
+
+	if code, id, source := lookup(tokens["session-1"]); code != http.StatusOK || id != "session-1" || source != "store" {
+		t.Errorf("Expected session-1 from the store, got %d %q %q", code, id, source)
+	}
+	if code, id, source := lookup(tokens["session-2"]); code != http.StatusOK || id != "session-2" || source != "database" {
+		t.Errorf("Expected session-2 from the database, got %d %q %q", code, id, source)
+	}
+
+	// A superseded token no longer maps to its session
+	old := tokens["session-2"]
+	dbSession, _ := s.db.GetSession("session-2")
+	s.issueAccessToken(dbSession)
+	if code, _, _ := lookup(old); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a superseded token, got %d", code)
+	}
+	if code, _, _ := lookup("not-a-jwt"); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a malformed token, got %d", code)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/airfi/airfi-perun-nervous/internal/auth"
+)
+
+const (
+	// settingTOTPSecret is the settings key holding the dashboard's base32
+	// TOTP secret. Dashboard login asks for a code only when it is set.
+	settingTOTPSecret = "totp_secret"
+	// settingTOTPPendingSecret holds a secret from handleEnrollTOTP until a
+	// code from the authenticator confirms it.
+	settingTOTPPendingSecret = "totp_pending_secret"
+	// totpIssuer names the account in authenticator apps.
+	totpIssuer = "AirFi"
+)
+
+// totpSecret returns the dashboard TOTP secret, or "" when 2FA is off.
+func (s *Server) totpSecret() string {
+	secret, err := s.db.GetSetting(settingTOTPSecret)
+	if err != nil {
+		return ""
+	}
+	return secret
+}
+
+// handleEnrollTOTP starts enabling 2FA for the dashboard. It returns a new
+// secret and its otpauth:// URI for the authenticator app; the secret takes
+// effect once handleConfirmTOTP accepts a code for it. Enrolling again
+// replaces a pending secret, and an enabled one once confirmed.
+func (s *Server) handleEnrollTOTP(c *gin.Context) {
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		s.logger.Error("failed to generate TOTP secret", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate secret"})
+		return
+	}
+	if err := s.db.SetSetting(settingTOTPPendingSecret, secret); err != nil {
+		s.logger.Error("failed to store TOTP secret", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store secret"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"secret":      secret,
+		"otpauth_uri": auth.TOTPProvisioningURI(secret, totpIssuer, "host"),
+		"message":     "Add the secret to your authenticator app, then confirm with a code from it.",
+	})
+}
+
+// handleConfirmTOTP enables 2FA with the pending secret from
+// handleEnrollTOTP once given a valid code for it.
+func (s *Server) handleConfirmTOTP(c *gin.Context) {
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+		return
+	}
+
+	pending, err := s.db.GetSetting(settingTOTPPendingSecret)
+	if err != nil || pending == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "no pending enrollment; call /totp/enroll first"})
+		return
+	}
+	if !s.totp.Consume(pending, req.Code) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid authentication code"})
+		return
+	}
+
+	if err := s.db.SetSetting(settingTOTPSecret, pending); err != nil {
+		s.logger.Error("failed to enable TOTP", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to enable 2FA"})
+		return
+	}
+	if err := s.db.SetSetting(settingTOTPPendingSecret, ""); err != nil {
+		s.logger.Warn("failed to clear pending TOTP secret", zap.Error(err))
+	}
+	s.audit(s.requestActor(c), auditTOTPEnabled, "", "", "")
+
+	c.JSON(http.StatusOK, gin.H{"message": "2FA enabled; dashboard logins now require a code"})
+}
+
+// handleGenerateBackupCodes replaces the 2FA backup codes with new ones.
+// The plaintext codes are only returned here.
+func (s *Server) handleGenerateBackupCodes(c *gin.Context) {
+	var req struct {
+		Count int `json:"count"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Count < 0 || req.Count > auth.MaxBackupCodeCount {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("count must be between 1 and %d", auth.MaxBackupCodeCount)})
+		return
+	}
+
+	codes, err := s.totp.GenerateBackupCodes(req.Count)
+	if err != nil {
+		s.logger.Error("failed to generate backup codes", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate backup codes"})
+		return
+	}
+	s.audit(s.requestActor(c), auditBackupCodesGenerated, "", "", fmt.Sprintf("count=%d", len(codes)))
+
+	c.JSON(http.StatusCreated, gin.H{
+		"codes":   codes,
+		"count":   len(codes),
+		"message": "Store these codes now, they will not be shown again. Each code works once.",
+	})
+}
+
+// handleListBackupCodes lists the stored backup code hashes and their use.
+func (s *Server) handleListBackupCodes(c *gin.Context) {
+	codes, err := s.totp.ListBackupCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list backup codes"})
+		return
+	}
+
+	result := make([]gin.H, 0, len(codes))
+	remaining := 0
+	for _, code := range codes {
+		usedAt := ""
+		if code.UsedAt != nil {
+			usedAt = code.UsedAt.Format(time.RFC3339)
+		} else {
+			remaining++
+		}
+		result = append(result, gin.H{
+			"code_hash":  code.CodeHash,
+			"created_at": code.CreatedAt.Format(time.RFC3339),
+			"used_at":    usedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"codes":     result,
+		"count":     len(result),
+		"remaining": remaining,
+	})
+}
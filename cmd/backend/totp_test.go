@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/airfi/airfi-perun-nervous/internal/auth"
+)
+
+func TestBackupCodes_GenerateListAndLogin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+	setTestDashboardPassword(t, s, "secret")
+	if err := s.db.SetSetting(settingTOTPSecret, "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+
+	r := gin.New()
+	r.LoadHTMLGlob("../../web/guest/templates/*")
+	r.POST("/dashboard/login", s.handleDashboardLoginPost)
+	admin := r.Group("/api/v1/admin", s.dashboardAuthMiddleware(auth.APIKeyScopeAdmin))
+	admin.GET("/totp/backup-codes", s.handleListBackupCodes)
+	admin.POST("/totp/backup-codes", s.handleGenerateBackupCodes)
+
+	adminRequest := func(method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/v1/admin/totp/backup-codes", strings.NewReader(body))
+		req.AddCookie(hostCookie(t, s))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	login := func(code string) *httptest.ResponseRecorder {
+		form := url.Values{"password": {"secret"}, "totp_code": {code}}
+		req := httptest.NewRequest(http.MethodPost, "/dashboard/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := adminRequest(http.MethodPost, `{"count":3}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var generated struct {
+		Codes []string `json:"codes"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &generated)
+	if len(generated.Codes) != 3 {
+		t.Fatalf("Expected 3 codes, got %v", generated.Codes)
+	}
+
+	if w := adminRequest(http.MethodPost, `{"count":-1}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for negative count, got %d", w.Code)
+	}
+
+	// Password alone is not enough once TOTP is enabled
+	if w := login(""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Invalid authentication code") {
+		t.Errorf("Expected login without code to fail, got %d", w.Code)
+	}
+
+	// A backup code logs in exactly once
+	if w := login(generated.Codes[0]); w.Code != http.StatusFound {
+		t.Fatalf("Expected redirect with backup code, got %d", w.Code)
+	}
+	if w := login(generated.Codes[0]); w.Code != http.StatusOK {
+		t.Errorf("Expected reused backup code to be rejected, got %d", w.Code)
+	}
+
+	w = adminRequest(http.MethodGet, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	var listed struct {
+		Codes []struct {
+			CodeHash string `json:"code_hash"`
+			UsedAt   string `json:"used_at"`
+		} `json:"codes"`
+		Remaining int `json:"remaining"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &listed)
+	if len(listed.Codes) != 3 || listed.Remaining != 2 {
+		t.Errorf("Expected 3 codes with 2 remaining, got %d with %d", len(listed.Codes), listed.Remaining)
+	}
+	for _, code := range listed.Codes {
+		for _, plaintext := range generated.Codes {
+			if strings.Contains(code.CodeHash, plaintext) {
+				t.Error("Listing should only contain hashes")
+			}
+		}
+	}
+}
+
+func TestTOTPEnrollment(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+
+	r := gin.New()
+	admin := r.Group("/api/v1/admin", s.dashboardAuthMiddleware(auth.APIKeyScopeAdmin))
+	admin.POST("/totp/enroll", s.handleEnrollTOTP)
+	admin.POST("/totp/confirm", s.handleConfirmTOTP)
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.AddCookie(hostCookie(t, s))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := post("/api/v1/admin/totp/confirm", `{"code":"123456"}`); w.Code != http.StatusConflict {
+		t.Errorf("Confirm before enroll: expected 409, got %d", w.Code)
+	}
+
+	w := post("/api/v1/admin/totp/enroll", "")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var enrolled struct {
+		Secret     string `json:"secret"`
+		OTPAuthURI string `json:"otpauth_uri"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &enrolled)
+	if enrolled.Secret == "" || !strings.Contains(enrolled.OTPAuthURI, enrolled.Secret) {
+		t.Fatalf("Unexpected enrollment response: %s", w.Body.String())
+	}
+
+	// The secret is pending until confirmed
+	if s.totpSecret() != "" {
+		t.Error("Expected 2FA to stay off before confirmation")
+	}
+	if w := post("/api/v1/admin/totp/confirm", `{"code":"000000"}`); w.Code != http.StatusUnauthorized {
+		t.Errorf("Wrong code: expected 401, got %d", w.Code)
+	}
+	if s.totpSecret() != "" {
+		t.Error("Expected 2FA to stay off after a wrong code")
+	}
+}
+
+func TestDashboardLogin_CookieIsSessionToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+	setTestDashboardPassword(t, s, "secret")
+
+	r := gin.New()
+	r.LoadHTMLGlob("../../web/guest/templates/*")
+	r.POST("/dashboard/login", s.handleDashboardLoginPost)
+	r.GET("/api/v1/admin/check", s.dashboardAuthMiddleware(auth.APIKeyScopeAdmin), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	form := url.Values{"password": {"secret"}}
+	req := httptest.NewRequest(http.MethodPost, "/dashboard/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusFound {
+		t.Fatalf("Expected redirect after login, got %d", w.Code)
+	}
+
+	var session *http.Cookie
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == dashboardCookie {
+			session = cookie
+		}
+	}
+	if session == nil || session.Value == "" || session.Value == "secret" {
+		t.Fatalf("Expected a session token cookie, got %+v", session)
+	}
+
+	check := func(cookie *http.Cookie) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/check", nil)
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := check(session); code != http.StatusOK {
+		t.Errorf("Session cookie: expected 200, got %d", code)
+	}
+	if code := check(&http.Cookie{Name: dashboardCookie, Value: "secret"}); code != http.StatusUnauthorized {
+		t.Errorf("Password as cookie: expected 401, got %d", code)
+	}
+}
@@ -2,28 +2,37 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/nervosnetwork/ckb-sdk-go/v2/indexer"
 	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
 	"go.uber.org/zap"
 
 	"github.com/airfi/airfi-perun-nervous/internal/db"
 	"github.com/airfi/airfi-perun-nervous/internal/guest"
-	"github.com/airfi/airfi-perun-nervous/internal/perun"
 )
 
 // handleCreateGuestWallet generates a new guest wallet for funding.
 func (s *Server) handleCreateGuestWallet(c *gin.Context) {
 	var req struct {
-		MACAddress string `json:"mac_address"`
-		IPAddress  string `json:"ip_address"`
+		MACAddress  string `json:"mac_address"`
+		IPAddress   string `json:"ip_address"`
+		AccessPoint string `json:"access_point"`
 	}
 	c.ShouldBindJSON(&req)
 
+	if current, limit, full := s.atDeviceCapacity(c.Request.Context()); full {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "WiFi at capacity",
+			"current": current,
+			"max":     limit,
+		})
+		return
+	}
+
 	wallet, err := s.walletManager.GenerateWallet()
 	if err != nil {
 		s.logger.Error("failed to generate wallet", zap.Error(err))
@@ -31,19 +40,34 @@ func (s *Server) handleCreateGuestWallet(c *gin.Context) {
 		return
 	}
 
+	now := time.Now()
 	dbWallet := &db.GuestWallet{
-		ID:            wallet.ID,
-		Address:       wallet.Address,
-		PrivateKeyHex: wallet.GetPrivateKeyHex(),
-		FundingCKB:    500,
-		BalanceCKB:    0,
-		CreatedAt:     time.Now(),
-		Status:        "created",
-		MACAddress:    req.MACAddress,
-		IPAddress:     req.IPAddress,
-	}
-
-	if err := s.db.CreateGuestWallet(dbWallet); err != nil {
+		ID:             wallet.ID,
+		Address:        wallet.Address,
+		PrivateKeyHex:  wallet.GetPrivateKeyHex(),
+		PrivateKeyHash: wallet.PrivateKeyHash,
+		FundingCKB:     500,
+		BalanceCKB:     0,
+		CreatedAt:      now,
+		Status:         "created",
+		MACAddress:     req.MACAddress,
+		IPAddress:      req.IPAddress,
+		AccessPoint:    req.AccessPoint,
+		ExpiresAt:      now.Add(s.walletTTL),
+	}
+
+	err = s.db.CreateGuestWallet(dbWallet)
+	if errors.Is(err, db.ErrDuplicateWallet) {
+		// A concurrent request stored the same key first: hand out its wallet
+		if existing, lookupErr := s.db.GetWalletByPrivateKeyHash(wallet.PrivateKeyHash); lookupErr == nil {
+			s.logger.Warn("guest wallet key already stored, returning existing wallet",
+				zap.String("wallet_id", existing.ID),
+			)
+			s.guestWalletResponse(c, existing)
+			return
+		}
+	}
+	if err != nil {
 		s.logger.Error("failed to save wallet", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save wallet"})
 		return
@@ -54,16 +78,40 @@ func (s *Server) handleCreateGuestWallet(c *gin.Context) {
 		zap.String("address", wallet.Address),
 		zap.String("mac_address", req.MACAddress),
 	)
+	s.audit(s.requestActor(c), auditWalletCreated, "", wallet.ID, "address="+wallet.Address)
+
+	s.guestWalletResponse(c, dbWallet)
+}
 
+// guestWalletResponse writes the response of handleCreateGuestWallet.
+func (s *Server) guestWalletResponse(c *gin.Context, wallet *db.GuestWallet) {
 	c.JSON(http.StatusOK, gin.H{
 		"wallet_id":    wallet.ID,
 		"address":      wallet.Address,
 		"funding_ckb":  61,
-		"status":       "created",
-		"host_address": s.hostClient.GetAddress(),
+		"status":       wallet.Status,
+		"host_address": s.hostAddress,
 	})
 }
 
+// atDeviceCapacity reports whether the router already has the device limit
+// of devices authorized, along with the count and the limit. The count
+// comes from the router rather than the database so devices authorized
+// outside AirFi count too. If the router can't be asked, guests are let
+// through.
+func (s *Server) atDeviceCapacity(ctx context.Context) (current, limit int, full bool) {
+	limit = s.deviceLimit()
+	if limit <= 0 || s.router == nil {
+		return 0, limit, false
+	}
+	current, err := s.router.GetSessionCount(ctx)
+	if err != nil {
+		s.logger.Warn("failed to get router session count", zap.Error(err))
+		return 0, limit, false
+	}
+	return current, limit, current >= limit
+}
+
 // getMinimumFunding returns the minimum CKB required (channel_setup + rate_per_hour).
 func (s *Server) getMinimumFunding() int64 {
 	ratePerHour, err := s.db.GetRatePerHour()
@@ -84,46 +132,24 @@ func (s *Server) handleGetGuestWallet(c *gin.Context) {
 		return
 	}
 
-	minimumCKB := s.getMinimumFunding()
-
-	// Check on-chain balance if still waiting for funding
-	if wallet.Status == "created" {
-		balance, err := s.checkWalletBalance(c.Request.Context(), wallet.Address)
-		if err == nil {
-			balanceCKB := balance / 100000000
-
-			if balanceCKB >= minimumCKB {
-				// Detect sender address IMMEDIATELY before any channel operations
-				senderAddr := s.detectSenderAddressSync(c.Request.Context(), wallet.Address)
-				if senderAddr != "" {
-					s.db.UpdateWalletSenderAddress(walletID, senderAddr)
-				}
-
-				// Create session
-				sessionID := s.createSessionFromWallet(wallet, balanceCKB)
-
-				s.db.UpdateWalletFunded(walletID, balanceCKB, sessionID)
-				wallet.Status = "funded"
-				wallet.BalanceCKB = balanceCKB
-				wallet.SessionID = sessionID
-
-				go s.openChannelForSession(context.Background(), wallet, sessionID, balanceCKB)
-			} else if balanceCKB > 0 {
-				// Partial funding - update balance but don't create session
-				wallet.BalanceCKB = balanceCKB
-				s.db.UpdateWalletBalance(walletID, balanceCKB)
-			}
-		}
+	// Balances come from the funding detector, which also opens the
+	// session once the wallet is funded; polling this doesn't hit the chain.
+	// transactions_found is null until the wallet has looked stuck.
+	var transactionsFound interface{}
+	if count, ok := s.walletTxCounts.Load(walletID); ok {
+		transactionsFound = count
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"wallet_id":    wallet.ID,
 		"address":      wallet.Address,
 		"balance_ckb":  wallet.BalanceCKB,
-		"minimum_ckb":  minimumCKB,
+		"minimum_ckb":  s.getMinimumFunding(),
 		"status":       wallet.Status,
 		"session_id":   wallet.SessionID,
 		"created_at":   wallet.CreatedAt.Format(time.RFC3339),
+		"transactions_found": transactionsFound,
+		"last_checked_at":    formatOptionalTime(wallet.LastCheckedAt),
 	})
 }
 
@@ -137,29 +163,26 @@ func (s *Server) checkWalletBalance(ctx context.Context, address string) (int64,
 
 	s.logger.Debug("checking wallet balance", zap.String("address", address))
 
-	searchKey := &indexer.SearchKey{
-		Script:     lockScript,
-		ScriptType: types.ScriptTypeLock,
-	}
-
-	capacity, err := s.ckbClient.GetCellsCapacity(ctx, searchKey)
+	capacity, err := s.newCellSplitter(s.logger.Named("cell-splitter")).GetTotalCapacity(ctx, lockScript)
 	if err != nil {
-		s.logger.Error("failed to get cells capacity", zap.Error(err))
+		s.logger.Error("failed to get wallet capacity", zap.Error(err))
 		return 0, fmt.Errorf("failed to query indexer: %w", err)
 	}
 
 	s.logger.Info("wallet balance checked",
 		zap.String("address", address),
-		zap.Uint64("capacity", capacity.Capacity),
+		zap.Uint64("capacity", capacity),
 	)
 
-	return int64(capacity.Capacity), nil
+	return int64(capacity), nil
 }
 
 // startFundingDetector runs a background loop to detect wallet funding.
 func (s *Server) startFundingDetector(ctx context.Context) {
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
+	expiryTicker := time.NewTicker(time.Hour)
+	defer expiryTicker.Stop()
 
 	for {
 		select {
@@ -167,10 +190,24 @@ func (s *Server) startFundingDetector(ctx context.Context) {
 			return
 		case <-ticker.C:
 			s.checkPendingWallets(ctx)
+		case <-expiryTicker.C:
+			s.expireStaleWallets(ctx)
 		}
 	}
 }
 
+// expireStaleWallets marks wallets past their TTL as expired once their
+// on-chain balance shows they were never funded.
+func (s *Server) expireStaleWallets(ctx context.Context) {
+	n, err := s.db.ExpireStaleWallets(ctx, walletBalanceChecker{s})
+	if err != nil {
+		s.logger.Error("failed to expire stale wallets", zap.Error(err))
+	}
+	if n > 0 {
+		s.logger.Info("expired unfunded wallets", zap.Int("count", n))
+	}
+}
+
 // checkPendingWallets checks all pending wallets for funding.
 func (s *Server) checkPendingWallets(ctx context.Context) {
 	wallets, err := s.db.ListPendingWallets()
@@ -182,50 +219,47 @@ func (s *Server) checkPendingWallets(ctx context.Context) {
 	minimumCKB := s.getMinimumFunding()
 
 	for _, wallet := range wallets {
-		balance, err := s.checkWalletBalance(ctx, wallet.Address)
-		if err != nil {
+		// Skip wallets another detector pass is still handling
+		if _, inFlight := s.inFlightWallets.LoadOrStore(wallet.ID, true); inFlight {
 			continue
 		}
+		if !s.processPendingWallet(ctx, wallet, minimumCKB) {
+			s.inFlightWallets.Delete(wallet.ID)
+		}
+	}
+}
+
+// processPendingWallet checks one wallet for funding and starts channel opening.
+// Returns true if channel opening was started; the in-flight mark is then
+// cleared when it finishes.
+func (s *Server) processPendingWallet(ctx context.Context, wallet *db.GuestWallet, minimumCKB int64) bool {
+	// The wallet list may be stale if another pass funded it meanwhile
+	current, err := s.db.GetGuestWallet(wallet.ID)
+	if err != nil || current.Status != "created" {
+		return false
+	}
 
-		balanceCKB := balance / 100000000
-
-		if balanceCKB >= minimumCKB {
-			// Detect sender address IMMEDIATELY before any channel operations
-			senderAddr := s.detectSenderAddressSync(ctx, wallet.Address)
-			if senderAddr != "" {
-				s.db.UpdateWalletSenderAddress(wallet.ID, senderAddr)
-				s.logger.Info("sender address saved",
-					zap.String("wallet_id", wallet.ID),
-					zap.String("sender_address", senderAddr),
-				)
-			}
-
-			sessionID := s.createSessionFromWallet(wallet, balanceCKB)
-			if sessionID != "" {
-				s.db.UpdateWalletFunded(wallet.ID, balanceCKB, sessionID)
-				s.logger.Info("wallet funded, session created",
-					zap.String("wallet_id", wallet.ID),
-					zap.Int64("balance", balanceCKB),
-					zap.Int64("minimum", minimumCKB),
-					zap.String("session_id", sessionID),
-				)
-
-				// Authorize MAC immediately (optimistic)
-				if wallet.MACAddress != "" {
-					comment := fmt.Sprintf("AirFi session (optimistic): %s", sessionID)
-					if err := s.router.AuthorizeMAC(ctx, wallet.MACAddress, wallet.IPAddress, comment); err != nil {
-						s.logger.Error("failed to authorize MAC", zap.Error(err), zap.String("mac", wallet.MACAddress))
-					} else {
-						s.logger.Info("MAC authorized (optimistic)",
-							zap.String("mac", wallet.MACAddress),
-							zap.String("ip", wallet.IPAddress),
-						)
-					}
-				}
-
-				go s.openChannelForSession(ctx, wallet, sessionID, balanceCKB)
-			}
-		} else if balanceCKB > 0 {
+	balance, err := s.checkWalletBalance(ctx, wallet.Address)
+	if err != nil {
+		return false
+	}
+	// Unfunded wallets past their TTL stop being watched; one paid late is
+	// still processed
+	if balance == 0 && !current.ExpiresAt.IsZero() && time.Now().After(current.ExpiresAt) {
+		if err := s.db.UpdateWalletStatus(wallet.ID, "expired"); err != nil {
+			s.logger.Error("failed to expire wallet", zap.String("wallet_id", wallet.ID), zap.Error(err))
+		}
+		s.walletTxCounts.Delete(wallet.ID)
+		return false
+	}
+	if err := s.db.UpdateWalletLastChecked(wallet.ID, time.Now()); err != nil {
+		s.logger.Warn("failed to record wallet check time", zap.String("wallet_id", wallet.ID), zap.Error(err))
+	}
+
+	balanceCKB := balance / 100000000
+
+	if balanceCKB < minimumCKB {
+		if balanceCKB > 0 {
 			// Partial funding - update balance for display
 			s.db.UpdateWalletBalance(wallet.ID, balanceCKB)
 			s.logger.Debug("partial funding detected",
@@ -234,15 +268,58 @@ func (s *Server) checkPendingWallets(ctx context.Context) {
 				zap.Int64("minimum", minimumCKB),
 			)
 		}
+		s.recheckStuckWallet(ctx, current, balanceCKB)
+		return false
 	}
+
+	// Detect sender address IMMEDIATELY before any channel operations
+	senderAddr := s.detectSenderAddressSync(ctx, wallet.Address)
+	if senderAddr != "" {
+		s.db.UpdateWalletSenderAddress(wallet.ID, senderAddr)
+		wallet.SenderAddress = senderAddr
+		s.logger.Info("sender address saved",
+			zap.String("wallet_id", wallet.ID),
+			zap.String("sender_address", senderAddr),
+		)
+	}
+
+	sessionID := s.createSessionFromWallet(wallet, balanceCKB)
+	if sessionID == "" {
+		return false
+	}
+	s.walletTxCounts.Delete(wallet.ID)
+	s.logger.Info("wallet funded, session created",
+		zap.String("wallet_id", wallet.ID),
+		zap.Int64("balance", balanceCKB),
+		zap.Int64("minimum", minimumCKB),
+		zap.String("session_id", sessionID),
+	)
+
+	// Authorize MAC immediately (optimistic)
+	if wallet.MACAddress != "" {
+		comment := fmt.Sprintf("AirFi session (optimistic): %s", sessionID)
+		if err := s.router.AuthorizeMAC(ctx, wallet.MACAddress, wallet.IPAddress, comment, wallet.AccessPoint); err != nil {
+			s.logger.Error("failed to authorize MAC", zap.Error(err), zap.String("mac", wallet.MACAddress))
+		} else {
+			s.logger.Info("MAC authorized (optimistic)",
+				zap.String("mac", wallet.MACAddress),
+				zap.String("ip", wallet.IPAddress),
+			)
+			s.audit(systemActor, auditMACAuthorized, sessionID, wallet.ID, "mac="+wallet.MACAddress)
+		}
+	}
+
+	go func() {
+		defer s.inFlightWallets.Delete(wallet.ID)
+		s.channelOpener(ctx, wallet, sessionID, balanceCKB)
+	}()
+	return true
 }
 
 // detectSenderAddressSync detects the sender address synchronously.
 // Must be called BEFORE any Perun channel operations to get the correct sender.
 func (s *Server) detectSenderAddressSync(ctx context.Context, walletAddress string) string {
-	withdrawer := perun.NewWithdrawer(s.ckbClient, s.logger.Named("withdrawer"))
-
-	senderAddr, err := withdrawer.GetSenderAddress(ctx, walletAddress, types.NetworkTest)
+	senderAddr, err := s.withdrawer.GetSenderAddress(ctx, walletAddress, types.NetworkTest)
 	if err != nil {
 		s.logger.Warn("sender detection failed",
 			zap.String("wallet", walletAddress),
@@ -253,3 +330,83 @@ func (s *Server) detectSenderAddressSync(ctx context.Context, walletAddress stri
 
 	return senderAddr
 }
+
+// stuckWalletAge is how long a wallet may stay "created" before the
+// detector checks whether funds reached it without being detected.
+const stuckWalletAge = 10 * time.Minute
+
+// recheckStuckWallet handles a wallet that has been below the funding minimum
+// for stuckWalletAge. If the chain shows transactions to it, the balance is
+// queried again and recorded even when zero, so the stored balance reflects
+// the chain rather than the last partial-funding update.
+func (s *Server) recheckStuckWallet(ctx context.Context, wallet *db.GuestWallet, balanceCKB int64) {
+	if time.Since(wallet.CreatedAt) < stuckWalletAge {
+		return
+	}
+
+	txCount, err := s.withdrawer.GetWalletTransactionCount(ctx, wallet.Address)
+	if err != nil {
+		return
+	}
+	s.walletTxCounts.Store(wallet.ID, txCount)
+	if txCount == 0 {
+		return
+	}
+
+	if balance, err := s.checkWalletBalance(ctx, wallet.Address); err == nil {
+		balanceCKB = balance / 100000000
+	}
+	s.logger.Warn("wallet has transactions but is still unfunded",
+		zap.String("wallet_id", wallet.ID),
+		zap.Int("transactions", txCount),
+		zap.Int64("balance_ckb", balanceCKB),
+		zap.Duration("age", time.Since(wallet.CreatedAt)),
+	)
+	s.db.UpdateWalletBalance(wallet.ID, balanceCKB)
+}
+
+// maxEstimateCells bounds the cell count handleEstimateGuestCells plans for.
+const maxEstimateCells = 32
+
+// handleEstimateGuestCells reports whether a guest wallet can be prepared
+// with the cells its channel needs, and at what fee, without touching the
+// chain. ?cells= overrides the target, which defaults to guestChannelCells.
+func (s *Server) handleEstimateGuestCells(c *gin.Context) {
+	walletID := c.Param("id")
+
+	target, err := queryInt(c, "cells", guestChannelCells)
+	if err != nil || target < 1 || target > maxEstimateCells {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("cells must be between 1 and %d", maxEstimateCells)})
+		return
+	}
+
+	wallet, err := s.db.GetGuestWallet(walletID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "wallet not found"})
+		return
+	}
+	lockScript, err := guest.DecodeAddress(wallet.Address)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to decode wallet address"})
+		return
+	}
+
+	estimate, err := s.newCellSplitter(s.logger.Named("cell-splitter")).DryRunSplit(c.Request.Context(), lockScript, target)
+	if err != nil {
+		s.logger.Error("failed to estimate cell preparation", zap.String("wallet_id", walletID), zap.Error(err))
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to query wallet cells"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"wallet_id":                    walletID,
+		"current_cells":                estimate.CurrentCells,
+		"target_cells":                 estimate.TargetCells,
+		"achievable_cells":             estimate.AchievableCells,
+		"total_fee_shannons":           estimate.TotalFeeCost,
+		"per_split_fee_shannons":       estimate.PerSplitFee,
+		"total_capacity_shannons":      estimate.TotalCapacity,
+		"capacity_after_fees_shannons": estimate.CapacityAfterFees,
+		"is_viable":                    estimate.IsViable,
+	})
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+	"github.com/airfi/airfi-perun-nervous/internal/guest"
+)
+
+// handleExportWallets returns a backup of every guest wallet in the
+// database, encrypted when a passphrase is given. The database rather than
+// the wallet manager is the source since the manager only holds wallets
+// created or loaded since the last restart.
+func (s *Server) handleExportWallets(c *gin.Context) {
+	var req struct {
+		Passphrase string `json:"passphrase"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+			return
+		}
+	}
+
+	wallets, err := s.db.ListGuestWallets()
+	if err != nil {
+		s.logger.Error("failed to list wallets", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to export wallets"})
+		return
+	}
+	backups := make([]guest.WalletBackup, 0, len(wallets))
+	for _, w := range wallets {
+		if w.PrivateKeyHex == "" {
+			continue
+		}
+		backups = append(backups, guest.WalletBackup{
+			ID:            w.ID,
+			Address:       w.Address,
+			PrivateKeyHex: w.PrivateKeyHex,
+			CreatedAt:     w.CreatedAt,
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := guest.WriteWalletBackup(&buf, backups, req.Passphrase); err != nil {
+		s.logger.Error("failed to export wallets", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to export wallets"})
+		return
+	}
+
+	s.audit(s.requestActor(c), auditWalletsExported, "", "", fmt.Sprintf("encrypted=%t wallets=%d", req.Passphrase != "", len(backups)))
+	c.Header("Content-Disposition", `attachment; filename="wallets.json"`)
+	c.Data(http.StatusOK, "application/json", buf.Bytes())
+}
+
+// handleImportWallets restores guest wallets from a backup. Wallets missing
+// from the database are recorded as expired so they show up for refunds
+// without being watched for funding.
+func (s *Server) handleImportWallets(c *gin.Context) {
+	var req struct {
+		Passphrase string          `json:"passphrase"`
+		Backup     json.RawMessage `json:"backup" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+
+	backups, err := guest.ReadWalletBackup(bytes.NewReader(req.Backup), req.Passphrase)
+	if errors.Is(err, guest.ErrBackupEncrypted) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "backup is encrypted, passphrase required"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	wallets, err := s.walletManager.AddWalletBackups(backups)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actor := s.requestActor(c)
+	now := time.Now()
+	restored := 0
+	for _, w := range wallets {
+		if _, err := s.db.GetGuestWallet(w.ID); err == nil {
+			continue
+		}
+		err := s.db.CreateGuestWallet(&db.GuestWallet{
+			ID:             w.ID,
+			Address:        w.Address,
+			PrivateKeyHex:  w.GetPrivateKeyHex(),
+			PrivateKeyHash: w.PrivateKeyHash,
+			CreatedAt:      w.CreatedAt,
+			Status:         "expired",
+			ExpiresAt:      now,
+		})
+		if err != nil {
+			s.logger.Error("failed to restore wallet", zap.String("wallet_id", w.ID), zap.Error(err))
+			continue
+		}
+		restored++
+		s.audit(actor, auditWalletsImported, "", w.ID, "address="+w.Address)
+	}
+
+	s.logger.Info("guest wallets imported", zap.Int("imported", len(wallets)), zap.Int("restored", restored))
+	c.JSON(http.StatusOK, gin.H{
+		"imported": len(wallets),
+		"restored": restored,
+	})
+}
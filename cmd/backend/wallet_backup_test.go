@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
+
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+	"github.com/airfi/airfi-perun-nervous/internal/guest"
+)
+
+func TestHandleExportImportWallets(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	src := newTestServer(t)
+	// The wallet is only in the database, as after a restart
+	wallet, _ := guest.NewWalletManager(types.NetworkTest).GenerateWallet()
+	err := src.db.CreateGuestWallet(&db.GuestWallet{
+		ID:             wallet.ID,
+		Address:        wallet.Address,
+		PrivateKeyHex:  wallet.GetPrivateKeyHex(),
+		PrivateKeyHash: wallet.PrivateKeyHash,
+		CreatedAt:      wallet.CreatedAt,
+		Status:         "created",
+		ExpiresAt:      time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("CreateGuestWallet: %v", err)
+	}
+	src.walletManager = guest.NewWalletManager(types.NetworkTest)
+
+	r := gin.New()
+	r.POST("/api/v1/admin/wallets/export", src.handleExportWallets)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/wallets/export", strings.NewReader(`{"passphrase":"secret"}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), wallet.GetPrivateKeyHex()) {
+		t.Fatal("Encrypted export contains the plaintext key")
+	}
+	backup := w.Body.Bytes()
+
+	dst := newTestServer(t)
+	dst.walletManager = guest.NewWalletManager(types.NetworkTest)
+	r = gin.New()
+	r.POST("/api/v1/admin/wallets/import", dst.handleImportWallets)
+
+	importBody := func(passphrase string) *strings.Reader {
+		body, _ := json.Marshal(map[string]interface{}{"passphrase": passphrase, "backup": json.RawMessage(backup)})
+		return strings.NewReader(string(body))
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/admin/wallets/import", importBody("")))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400 without passphrase, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/admin/wallets/import", importBody("secret")))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Imported int `json:"imported"`
+		Restored int `json:"restored"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if resp.Imported != 1 || resp.Restored != 1 {
+		t.Errorf("unexpected summary: %s", w.Body.String())
+	}
+
+	if _, ok := dst.walletManager.GetWallet(wallet.ID); !ok {
+		t.Error("Expected wallet in the manager after import")
+	}
+	dbWallet, err := dst.db.GetGuestWallet(wallet.ID)
+	if err != nil {
+		t.Fatalf("Expected wallet restored to the database: %v", err)
+	}
+	if dbWallet.Status != "expired" || dbWallet.PrivateKeyHex != wallet.GetPrivateKeyHex() {
+		t.Errorf("unexpected restored wallet: status %q", dbWallet.Status)
+	}
+}
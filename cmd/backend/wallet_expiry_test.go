@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/indexer"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/rpc"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
+
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+	"github.com/airfi/airfi-perun-nervous/internal/guest"
+)
+
+// emptyRPCClient reports every wallet as holding no cells.
+type emptyRPCClient struct {
+	rpc.Client
+}
+
+func (m *emptyRPCClient) GetCells(ctx context.Context, searchKey *indexer.SearchKey, order indexer.SearchOrder, limit uint64, afterCursor string) (*indexer.LiveCells, error) {
+	return &indexer.LiveCells{}, nil
+}
+
+// testWalletAddress returns the address of a freshly generated guest wallet.
+func testWalletAddress(t *testing.T) string {
+	t.Helper()
+	w, err := guest.NewWalletManager(types.NetworkTest).GenerateWallet()
+	if err != nil {
+		t.Fatalf("GenerateWallet failed: %v", err)
+	}
+	return w.Address
+}
+
+func TestCheckPendingWallets_ExpiresStaleWallets(t *testing.T) {
+	s := newTestServer(t)
+	s.ckbClient = &emptyRPCClient{}
+
+	now := time.Now()
+	s.db.CreateGuestWallet(&db.GuestWallet{ID: "w1", Address: testWalletAddress(t), PrivateKeyHex: "k1", Status: "created", CreatedAt: now.Add(-25 * time.Hour), ExpiresAt: now.Add(-time.Hour)})
+
+	s.checkPendingWallets(context.Background())
+
+	wallet, _ := s.db.GetGuestWallet("w1")
+	if wallet.Status != "expired" {
+		t.Errorf("Expected expired wallet to be skipped and marked expired, got %s", wallet.Status)
+	}
+	if wallet.SessionID != "" {
+		t.Errorf("Expected no session for expired wallet, got %s", wallet.SessionID)
+	}
+}
+
+func TestExpireStaleWallets_KeepsFundedWallets(t *testing.T) {
+	s := newTestServer(t)
+	s.ckbClient = &fundedRPCClient{}
+
+	now := time.Now()
+	s.db.CreateGuestWallet(&db.GuestWallet{ID: "w1", Address: testWalletAddress(t), PrivateKeyHex: "k1", Status: "created", CreatedAt: now.Add(-25 * time.Hour), ExpiresAt: now.Add(-time.Hour)})
+
+	s.expireStaleWallets(context.Background())
+
+	wallet, _ := s.db.GetGuestWallet("w1")
+	if wallet.Status != "created" {
+		t.Errorf("Expected funded wallet to stay created, got %s", wallet.Status)
+	}
+}
+
+func TestHandleListExpiredWallets(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+	s.ckbClient = &emptyRPCClient{}
+
+	now := time.Now()
+	s.db.CreateGuestWallet(&db.GuestWallet{ID: "w1", Address: testWalletAddress(t), PrivateKeyHex: "k1", Status: "created", CreatedAt: now.Add(-50 * time.Hour), ExpiresAt: now.Add(-26 * time.Hour)})
+	s.db.CreateGuestWallet(&db.GuestWallet{ID: "w2", Address: testWalletAddress(t), PrivateKeyHex: "k2", Status: "created", CreatedAt: now.Add(-25 * time.Hour), ExpiresAt: now.Add(-time.Hour)})
+	s.db.CreateGuestWallet(&db.GuestWallet{ID: "w3", Address: testWalletAddress(t), PrivateKeyHex: "k3", Status: "created", CreatedAt: now, ExpiresAt: now.Add(24 * time.Hour)})
+	s.expireStaleWallets(context.Background())
+
+	r := gin.New()
+	r.GET("/api/v1/admin/wallets/expired", s.handleListExpiredWallets)
+
+	var resp struct {
+		Wallets []struct {
+			WalletID  string `json:"wallet_id"`
+			Status    string `json:"status"`
+			ExpiresAt string `json:"expires_at"`
+		} `json:"wallets"`
+		Count int `json:"count"`
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/wallets/expired", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if resp.Count != 2 || resp.Wallets[0].WalletID != "w1" || resp.Wallets[1].WalletID != "w2" {
+		t.Fatalf("Expected w1 and w2, got %s", w.Body.String())
+	}
+	if resp.Wallets[0].Status != "expired" || resp.Wallets[0].ExpiresAt == "" {
+		t.Errorf("unexpected wallet entry: %+v", resp.Wallets[0])
+	}
+
+	from := url.QueryEscape(now.Add(-2 * time.Hour).Format(time.RFC3339))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/wallets/expired?from="+from, nil))
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Count != 1 || resp.Wallets[0].WalletID != "w2" {
+		t.Errorf("from filter: expected only w2, got %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/wallets/expired?to=yesterday", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid to, got %d", w.Code)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+)
+
+func TestHandleSearchWallets(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+
+	now := time.Now()
+	s.db.CreateGuestWallet(&db.GuestWallet{ID: "w1", Address: "ckt1a", PrivateKeyHex: "k1", BalanceCKB: 900, Status: "funded", MACAddress: "AA:BB:CC:DD:EE:FF", CreatedAt: now})
+	s.db.CreateGuestWallet(&db.GuestWallet{ID: "w2", Address: "ckt1b", PrivateKeyHex: "k2", BalanceCKB: 40, Status: "funded", MACAddress: "AA:BB:CC:DD:EE:FF", CreatedAt: now})
+	s.db.CreateGuestWallet(&db.GuestWallet{ID: "w3", Address: "ckt1c", PrivateKeyHex: "k3", BalanceCKB: 900, Status: "created", CreatedAt: now})
+
+	r := gin.New()
+	r.GET("/api/v1/admin/wallets", s.handleSearchWallets)
+
+	tests := []struct {
+		query    string
+		expected int
+	}{
+		{"", 3},
+		{"?status=funded", 2},
+		{"?status=created,funded", 3},
+		{"?status=created,withdrawn", 1},
+		{"?mac=aa:bb:cc:dd:ee:ff", 2},
+		{"?min_balance=100", 2},
+		{"?status=funded&mac=AA:BB:CC:DD:EE:FF&min_balance=100", 1},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/wallets"+tt.query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%q: expected 200, got %d: %s", tt.query, w.Code, w.Body.String())
+		}
+		var resp struct {
+			Wallets []struct {
+				WalletID   string `json:"wallet_id"`
+				MACAddress string `json:"mac_address"`
+			} `json:"wallets"`
+			Count int `json:"count"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		if resp.Count != tt.expected || len(resp.Wallets) != tt.expected {
+			t.Errorf("%q: expected %d wallets, got %d", tt.query, tt.expected, resp.Count)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/wallets?min_balance=abc", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid min_balance: expected 400, got %d", w.Code)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/airfi/airfi-perun-nervous/internal/db"
+)
+
+func TestHandleGetGuestWallet_ServesRecordedBalance(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// No RPC client: the handler must not query the chain
+	s := newTestServer(t)
+	s.db.CreateGuestWallet(&db.GuestWallet{ID: "w1", Address: "ckt1a", PrivateKeyHex: "k1", BalanceCKB: 200, Status: "created", CreatedAt: time.Now()})
+
+	r := gin.New()
+	r.GET("/api/v1/wallet/guest/:id", s.handleGetGuestWallet)
+
+	var resp struct {
+		BalanceCKB        int64 `json:"balance_ckb"`
+		TransactionsFound *int  `json:"transactions_found"`
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/wallet/guest/w1", nil))
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &resp) != nil {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if resp.BalanceCKB != 200 || resp.TransactionsFound != nil {
+		t.Errorf("Expected the recorded balance and no transaction count, got %s", w.Body.String())
+	}
+
+	// A count found by the stuck-wallet recheck is reported
+	s.walletTxCounts.Store("w1", 2)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/wallet/guest/w1", nil))
+	if json.Unmarshal(w.Body.Bytes(), &resp) != nil || resp.TransactionsFound == nil || *resp.TransactionsFound != 2 {
+		t.Errorf("Expected 2 transactions found, got %s", w.Body.String())
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+var (
+	apiKey            string
+	dashboardPassword string
+
+	// dashboardSession caches the session cookie from logging in with
+	// dashboardPassword.
+	dashboardSession *http.Cookie
+)
+
+// dashboardCookieName is the backend's dashboard session cookie.
+const dashboardCookieName = "airfi_host_auth"
+
+// adminCredentialsFromEnv fills unset admin credentials from the environment.
+func adminCredentialsFromEnv() {
+	if apiKey == "" {
+		apiKey = os.Getenv("AIRFI_API_KEY")
+	}
+	if dashboardPassword == "" {
+		dashboardPassword = os.Getenv("AIRFI_DASHBOARD_PASSWORD")
+	}
+	if dashboardPassword == "" {
+		dashboardPassword = os.Getenv("AIRFI_PASSWORD")
+	}
+}
+
+// adminRequest calls an authenticated admin endpoint and decodes the JSON response.
+// It authenticates with the API key if set, otherwise with the dashboard password.
+func adminRequest(method, path string, payload, result interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, apiURL+path, body)
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if err := authorizeAdminRequest(req); err != nil {
+		return err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &errResp) == nil && errResp.Error != "" {
+			return fmt.Errorf("%s (HTTP %d)", errResp.Error, resp.StatusCode)
+		}
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(data, result); err != nil {
+			return fmt.Errorf("failed to parse response: %w", err)
+		}
+	}
+	return nil
+}
+
+// authorizeAdminRequest adds the admin credentials to req: the API key if
+// set, otherwise the cookie from logging in with the dashboard password.
+func authorizeAdminRequest(req *http.Request) error {
+	adminCredentialsFromEnv()
+
+	switch {
+	case apiKey != "":
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	case dashboardPassword != "":
+		cookie, err := dashboardLogin()
+		if err != nil {
+			return err
+		}
+		req.AddCookie(cookie)
+	default:
+		return fmt.Errorf("no credentials: set --api-key or --password (or AIRFI_API_KEY / AIRFI_DASHBOARD_PASSWORD)")
+	}
+	return nil
+}
+
+// dashboardLogin logs in to the dashboard with dashboardPassword and returns
+// the session cookie. Dashboards with 2FA enabled need an API key instead.
+func dashboardLogin() (*http.Cookie, error) {
+	if dashboardSession != nil {
+		return dashboardSession, nil
+	}
+
+	form := url.Values{"password": {dashboardPassword}}
+	req, err := http.NewRequest("POST", apiURL+"/dashboard/login", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	// A successful login redirects; the cookie is on the redirect itself
+	client := *httpClient
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer resp.Body.Close()
+
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == dashboardCookieName && cookie.Value != "" {
+			dashboardSession = cookie
+			return cookie, nil
+		}
+	}
+	return nil, fmt.Errorf("dashboard login failed: check the password, or use --api-key if 2FA is enabled")
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// chartWidth is the maximum bar length in characters.
+const chartWidth = 40
+
+// MonthlyRevenue represents a monthly revenue report from the backend.
+type MonthlyRevenue struct {
+	Year             int   `json:"year"`
+	Month            int   `json:"month"`
+	TotalSessions    int   `json:"total_sessions"`
+	ActiveSessions   int   `json:"active_sessions"`
+	SettledSessions  int   `json:"settled_sessions"`
+	TotalFundedCKB   int64 `json:"total_funded_ckb"`
+	TotalSpentCKB    int64 `json:"total_spent_ckb"`
+	TotalRefundedCKB int64 `json:"total_refunded_ckb"`
+	UniqueGuests     int   `json:"unique_guests"`
+}
+
+// CKBFlow represents a CKB flow reconciliation report from the backend.
+type CKBFlow struct {
+	TotalFundedShannons   int64 `json:"total_funded_shannons"`
+	TotalSettledShannons  int64 `json:"total_settled_shannons"`
+	TotalRefundedShannons int64 `json:"total_refunded_shannons"`
+	TotalPendingShannons  int64 `json:"total_pending_shannons"`
+	TotalFeesShannons     int64 `json:"total_fees_shannons"`
+}
+
+// newAnalyticsCommand creates the revenue reporting command.
+func newAnalyticsCommand() *cobra.Command {
+	now := time.Now().UTC()
+	var monthly, yearly, flow bool
+	var year, month int
+	var from, to string
+
+	cmd := &cobra.Command{
+		Use:   "analytics",
+		Short: "Show revenue reports",
+		Long:  "Prints monthly or yearly revenue statements as ASCII bar charts, or a CKB flow reconciliation with --flow",
+		Run: func(cmd *cobra.Command, args []string) {
+			if flow {
+				showFlowReport(from, to)
+				return
+			}
+			if yearly {
+				showYearlyReport(year)
+				return
+			}
+			showMonthlyReport(year, month)
+		},
+	}
+	cmd.Flags().BoolVar(&monthly, "monthly", true, "Show a single month")
+	cmd.Flags().BoolVar(&yearly, "yearly", false, "Show all months of a year")
+	cmd.Flags().IntVar(&year, "year", now.Year(), "Report year")
+	cmd.Flags().IntVar(&month, "month", int(now.Month()), "Report month (1-12)")
+	cmd.Flags().BoolVar(&flow, "flow", false, "Reconcile CKB funded against settled and refunded")
+	cmd.Flags().StringVar(&from, "from", "", "Flow report start, YYYY-MM-DD or RFC 3339 (default: open)")
+	cmd.Flags().StringVar(&to, "to", "", "Flow report end, exclusive, YYYY-MM-DD or RFC 3339 (default: open)")
+	cmd.MarkFlagsMutuallyExclusive("monthly", "yearly", "flow")
+
+	cmd.AddCommand(newTopGuestsCommand())
+
+	return cmd
+}
+
+// newTopGuestsCommand creates the top-guests subcommand.
+func newTopGuestsCommand() *cobra.Command {
+	var limit int
+	cmd := &cobra.Command{
+		Use:   "top-guests",
+		Short: "Show guests with the highest lifetime spend",
+		Run: func(cmd *cobra.Command, args []string) {
+			showTopGuests(limit)
+		},
+	}
+	cmd.Flags().IntVar(&limit, "limit", 10, "Number of guests to show (max 100)")
+
+	return cmd
+}
+
+func showMonthlyReport(year, month int) {
+	if month < 1 || month > 12 {
+		fmt.Println("Error: --month must be between 1 and 12")
+		return
+	}
+
+	var report MonthlyRevenue
+	path := fmt.Sprintf("/api/v1/admin/reports/monthly?year=%d&month=%d", year, month)
+	if err := adminRequest("GET", path, nil, &report); err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	fmt.Printf("\nRevenue report: %s %d\n", time.Month(month), year)
+	fmt.Println(strings.Repeat("-", 60))
+	fmt.Printf("Sessions: %d total, %d active, %d settled\n", report.TotalSessions, report.ActiveSessions, report.SettledSessions)
+	fmt.Printf("Unique guests: %d\n\n", report.UniqueGuests)
+
+	maxValue := max(report.TotalFundedCKB, report.TotalSpentCKB, report.TotalRefundedCKB)
+	fmt.Printf("%-10s %s %d CKB\n", "Funded", bar(report.TotalFundedCKB, maxValue), report.TotalFundedCKB)
+	fmt.Printf("%-10s %s %d CKB\n", "Earned", bar(report.TotalSpentCKB, maxValue), report.TotalSpentCKB)
+	fmt.Printf("%-10s %s %d CKB\n", "Refunded", bar(report.TotalRefundedCKB, maxValue), report.TotalRefundedCKB)
+}
+
+func showYearlyReport(year int) {
+	var result struct {
+		Year   int              `json:"year"`
+		Months []MonthlyRevenue `json:"months"`
+	}
+	path := fmt.Sprintf("/api/v1/admin/reports/yearly?year=%d", year)
+	if err := adminRequest("GET", path, nil, &result); err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	var maxValue, totalSpent int64
+	var totalSessions int
+	for _, m := range result.Months {
+		maxValue = max(maxValue, m.TotalSpentCKB)
+		totalSpent += m.TotalSpentCKB
+		totalSessions += m.TotalSessions
+	}
+
+	fmt.Printf("\nEarned CKB by month: %d\n", result.Year)
+	fmt.Println(strings.Repeat("-", 60))
+	for _, m := range result.Months {
+		fmt.Printf("%-4s %s %d CKB (%d sessions)\n",
+			time.Month(m.Month).String()[:3],
+			bar(m.TotalSpentCKB, maxValue),
+			m.TotalSpentCKB,
+			m.TotalSessions,
+		)
+	}
+	fmt.Println(strings.Repeat("-", 60))
+	fmt.Printf("Total: %d CKB from %d sessions\n", totalSpent, totalSessions)
+}
+
+func showFlowReport(from, to string) {
+	params := url.Values{}
+	for name, value := range map[string]string{"from": from, "to": to} {
+		if value == "" {
+			continue
+		}
+		t, err := parseReportTime(value)
+		if err != nil {
+			fmt.Printf("Error: invalid --%s: %s\n", name, value)
+			return
+		}
+		params.Set(name, t.Format(time.RFC3339))
+	}
+
+	var report CKBFlow
+	if err := adminRequest("GET", "/api/v1/admin/reports/flow?"+params.Encode(), nil, &report); err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	period := "all time"
+	if from != "" || to != "" {
+		period = fmt.Sprintf("%s to %s", orDefault(from, "start"), orDefault(to, "now"))
+	}
+	fmt.Printf("\nCKB flow: %s\n", period)
+	fmt.Println(strings.Repeat("-", 60))
+	fmt.Printf("%-10s %18s CKB\n", "Funded", formatShannons(report.TotalFundedShannons))
+	fmt.Printf("%-10s %18s CKB\n", "Settled", formatShannons(report.TotalSettledShannons))
+	fmt.Printf("%-10s %18s CKB\n", "Refunded", formatShannons(report.TotalRefundedShannons))
+	fmt.Println(strings.Repeat("-", 60))
+	fmt.Printf("%-10s %18s CKB\n", "Pending", formatShannons(report.TotalPendingShannons))
+	fmt.Printf("%-10s %18s CKB (estimated, paid from refunds)\n", "Fees", formatShannons(report.TotalFeesShannons))
+}
+
+// GuestStats represents one guest's lifetime spend from the backend.
+type GuestStats struct {
+	Guest         string `json:"guest"` // sender address, or MAC address
+	SessionCount  int    `json:"session_count"`
+	TotalSpentCKB int64  `json:"total_spent_ckb"`
+}
+
+func showTopGuests(limit int) {
+	var result struct {
+		Guests []GuestStats `json:"guests"`
+	}
+	path := fmt.Sprintf("/api/v1/admin/guests/top?limit=%d", limit)
+	if err := adminRequest("GET", path, nil, &result); err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	fmt.Println("\nTop guests by lifetime spend")
+	fmt.Println(strings.Repeat("-", 60))
+	if len(result.Guests) == 0 {
+		fmt.Println("No guests yet")
+		return
+	}
+
+	var maxValue int64
+	for _, g := range result.Guests {
+		maxValue = max(maxValue, g.TotalSpentCKB)
+	}
+	for i, g := range result.Guests {
+		fmt.Printf("%2d. %-20s %s %d CKB (%d sessions)\n",
+			i+1,
+			truncateAddress(g.Guest, 20),
+			bar(g.TotalSpentCKB, maxValue),
+			g.TotalSpentCKB,
+			g.SessionCount,
+		)
+	}
+}
+
+// parseReportTime parses a date (UTC midnight) or an RFC 3339 time.
+func parseReportTime(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// orDefault returns value, or def when value is empty.
+func orDefault(value, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
+
+// formatShannons formats shannons as CKB with 8 decimal places.
+func formatShannons(shannons int64) string {
+	sign := ""
+	if shannons < 0 {
+		sign = "-"
+		shannons = -shannons
+	}
+	return fmt.Sprintf("%s%d.%08d", sign, shannons/100000000, shannons%100000000)
+}
+
+// bar renders value as a bar scaled against maxValue, padded to chartWidth.
+func bar(value, maxValue int64) string {
+	n := 0
+	if maxValue > 0 && value > 0 {
+		n = int(value * chartWidth / maxValue)
+		if n == 0 {
+			n = 1
+		}
+	}
+	return strings.Repeat("#", n) + strings.Repeat(" ", chartWidth-n)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// newChannelsCommand creates the channel inspection command.
+func newChannelsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "channels",
+		Short: "Inspect the host's Perun channels",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List open channels with balances and state",
+		Run: func(cmd *cobra.Command, args []string) {
+			listChannels()
+		},
+	})
+
+	return cmd
+}
+
+func listChannels() {
+	var result struct {
+		Channels []ChannelInfo `json:"channels"`
+	}
+	if err := adminRequest("GET", "/api/v1/channels", nil, &result); err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	if len(result.Channels) == 0 {
+		fmt.Println("No open channels")
+		return
+	}
+
+	fmt.Printf("\n%-20s %-20s %-9s %7s %18s %18s\n", "CHANNEL", "PEER", "STATE", "VERSION", "HOST (CKB)", "PEER (CKB)")
+	fmt.Println(strings.Repeat("-", 97))
+	for _, ch := range result.Channels {
+		fmt.Printf("%-20s %-20s %-9s %7d %18s %18s\n",
+			truncate(ch.ChannelID, 20),
+			truncate(ch.PeerAddress, 20),
+			ch.State,
+			ch.Version,
+			formatBalance(ch.MyBalance),
+			formatBalance(ch.PeerBalance),
+		)
+	}
+}
+
+// formatBalance formats a shannon amount sent as a decimal string in CKB,
+// leaving it unchanged if it does not fit an int64.
+func formatBalance(shannons string) string {
+	n, err := strconv.ParseInt(shannons, 10, 64)
+	if err != nil {
+		return shannons
+	}
+	return formatShannons(n)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+// configSetting describes a server setting that can be changed from the CLI.
+type configSetting struct {
+	method  string
+	path    string
+	field   string
+	numeric bool
+}
+
+// configSettings maps CLI setting names to their backend endpoints.
+var configSettings = map[string]configSetting{
+	"rate-per-hour":      {method: "PUT", path: "/api/v1/settings/rate", field: "rate_per_hour", numeric: true},
+	"channel-setup-ckb":  {method: "PUT", path: "/api/v1/settings/channel-setup", field: "channel_setup_ckb", numeric: true},
+	"dashboard-password": {method: "PUT", path: "/api/v1/admin/password", field: "password"},
+}
+
+// newConfigCommand creates the server settings command.
+func newConfigCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "View and update server settings",
+		Long:  "Show current pricing settings or change them on the running backend",
+	}
+
+	var dryRun bool
+	setCmd := &cobra.Command{
+		Use:   "set [rate-per-hour|channel-setup-ckb|dashboard-password] [value]",
+		Short: "Update a server setting",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			setConfig(args[0], args[1], dryRun)
+		},
+	}
+	setCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the request payload without sending it")
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "get",
+			Short: "Show current server settings",
+			Run: func(cmd *cobra.Command, args []string) {
+				getConfig()
+			},
+		},
+		setCmd,
+	)
+
+	return cmd
+}
+
+func getConfig() {
+	resp, err := httpClient.Get(fmt.Sprintf("%s/api/v1/settings", apiURL))
+	if err != nil {
+		fmt.Printf("Error: failed to connect: %s\n", err.Error())
+		return
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Printf("Error: failed to read response: %s\n", err.Error())
+		return
+	}
+
+	var settings map[string]interface{}
+	if err := json.Unmarshal(body, &settings); err != nil {
+		fmt.Printf("Error: failed to parse response: %s\n", err.Error())
+		return
+	}
+
+	pretty, _ := json.MarshalIndent(settings, "", "  ")
+	fmt.Println(string(pretty))
+}
+
+// buildConfigPayload validates a setting value and returns the request body.
+func buildConfigPayload(name, value string) (configSetting, map[string]interface{}, error) {
+	setting, ok := configSettings[name]
+	if !ok {
+		return configSetting{}, nil, fmt.Errorf("unknown setting %q (valid: rate-per-hour, channel-setup-ckb, dashboard-password)", name)
+	}
+
+	if !setting.numeric {
+		if value == "" {
+			return configSetting{}, nil, fmt.Errorf("%s must not be empty", name)
+		}
+		return setting, map[string]interface{}{setting.field: value}, nil
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n <= 0 {
+		return configSetting{}, nil, fmt.Errorf("%s must be a positive integer, got %q", name, value)
+	}
+	return setting, map[string]interface{}{setting.field: n}, nil
+}
+
+func setConfig(name, value string, dryRun bool) {
+	setting, payload, err := buildConfigPayload(name, value)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	if dryRun {
+		data, _ := json.MarshalIndent(payload, "", "  ")
+		fmt.Printf("%s %s\n%s\n", setting.method, setting.path, data)
+		return
+	}
+
+	if err := adminRequest(setting.method, setting.path, payload, nil); err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+	fmt.Printf("%s updated\n", name)
+}
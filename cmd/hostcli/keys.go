@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// APIKeyInfo represents an API key as listed by the backend.
+type APIKeyInfo struct {
+	ID         string   `json:"id"`
+	Key        string   `json:"key,omitempty"`
+	Label      string   `json:"label"`
+	Scopes     []string `json:"scopes"`
+	CreatedAt  string   `json:"created_at"`
+	LastUsedAt string   `json:"last_used_at"`
+}
+
+// newKeysCommand creates the API key management command.
+func newKeysCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "keys",
+		Short: "Manage API keys",
+		Long:  "Create, list and revoke API keys for machine-to-machine access to admin endpoints",
+	}
+
+	var label string
+	var scopes []string
+	createCmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create a new API key",
+		Run: func(cmd *cobra.Command, args []string) {
+			createAPIKey(label, scopes)
+		},
+	}
+	createCmd.Flags().StringVar(&label, "label", "", "Label describing the key's purpose")
+	createCmd.Flags().StringSliceVar(&scopes, "scope", nil, "Key scope, read or admin (default admin)")
+	createCmd.MarkFlagRequired("label")
+
+	cmd.AddCommand(
+		createCmd,
+		&cobra.Command{
+			Use:   "list",
+			Short: "List API keys",
+			Run: func(cmd *cobra.Command, args []string) {
+				listAPIKeys()
+			},
+		},
+		&cobra.Command{
+			Use:   "revoke [key-id]",
+			Short: "Revoke an API key",
+			Args:  cobra.ExactArgs(1),
+			Run: func(cmd *cobra.Command, args []string) {
+				revokeAPIKey(args[0])
+			},
+		},
+	)
+
+	return cmd
+}
+
+func createAPIKey(label string, scopes []string) {
+	var result APIKeyInfo
+	payload := map[string]interface{}{"label": label, "scopes": scopes}
+	if err := adminRequest("POST", "/api/v1/admin/keys", payload, &result); err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	fmt.Println("\nAPI key created")
+	fmt.Println(strings.Repeat("-", 50))
+	fmt.Printf("ID:    %s\n", result.ID)
+	fmt.Printf("Label: %s\n", result.Label)
+	fmt.Printf("Scope: %s\n", strings.Join(result.Scopes, ","))
+	fmt.Println()
+	fmt.Println(result.Key)
+	fmt.Println(strings.Repeat("-", 50))
+	fmt.Println("Store this key now, it will not be shown again.")
+}
+
+func listAPIKeys() {
+	var result struct {
+		Keys []APIKeyInfo `json:"keys"`
+	}
+	if err := adminRequest("GET", "/api/v1/admin/keys", nil, &result); err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	if len(result.Keys) == 0 {
+		fmt.Println("No API keys")
+		return
+	}
+
+	fmt.Printf("\n%-18s %-20s %-12s %-22s %s\n", "ID", "LABEL", "SCOPES", "CREATED", "LAST USED")
+	fmt.Println(strings.Repeat("-", 90))
+	for _, k := range result.Keys {
+		lastUsed := k.LastUsedAt
+		if lastUsed == "" {
+			lastUsed = "never"
+		}
+		fmt.Printf("%-18s %-20s %-12s %-22s %s\n",
+			k.ID,
+			truncate(k.Label, 20),
+			truncate(strings.Join(k.Scopes, ","), 12),
+			k.CreatedAt,
+			lastUsed,
+		)
+	}
+}
+
+func revokeAPIKey(id string) {
+	if err := adminRequest("DELETE", "/api/v1/admin/keys/"+id, nil, nil); err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+	fmt.Printf("API key %s revoked\n", id)
+}
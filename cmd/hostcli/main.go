@@ -15,6 +15,8 @@ import (
 
 	"github.com/mdp/qrterminal/v3"
 	"github.com/spf13/cobra"
+
+	"github.com/airfi/airfi-perun-nervous/internal/terminal"
 )
 
 var (
@@ -36,6 +38,8 @@ func main() {
 
 	// Global flags
 	rootCmd.PersistentFlags().StringVar(&apiURL, "api", "http://localhost:8080", "Backend API URL")
+	rootCmd.PersistentFlags().StringVar(&apiKey, "api-key", "", "API key for admin commands (or AIRFI_API_KEY)")
+	rootCmd.PersistentFlags().StringVar(&dashboardPassword, "password", "", "Dashboard password for admin commands (or AIRFI_DASHBOARD_PASSWORD)")
 
 	// Commands
 	rootCmd.AddCommand(
@@ -43,9 +47,15 @@ func main() {
 		newQRCommand(),
 		newSessionsCommand(),
 		newSettleCommand(),
+		newRefundCommand(),
 		newStatusCommand(),
 		newWalletCommand(),
 		newTokenCommand(),
+		newKeysCommand(),
+		newConfigCommand(),
+		newAnalyticsCommand(),
+		newChannelsCommand(),
+		newRouterCommand(),
 	)
 
 	if err := rootCmd.Execute(); err != nil {
@@ -56,78 +66,135 @@ func main() {
 
 // newDashboardCommand creates the main dashboard command.
 func newDashboardCommand() *cobra.Command {
-	return &cobra.Command{
+	n := &notifier{}
+	cmd := &cobra.Command{
 		Use:   "dashboard",
 		Short: "Launch the host dashboard (QR + wallet + sessions)",
 		Long:  "Displays an interactive dashboard with QR code, wallet info, and live session monitoring",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return n.validate()
+		},
 		Run: func(cmd *cobra.Command, args []string) {
-			runDashboard()
+			runDashboard(n)
 		},
 	}
+	n.addFlags(cmd)
+
+	return cmd
 }
 
 // newQRCommand creates the QR code display command.
 func newQRCommand() *cobra.Command {
-	return &cobra.Command{
+	opts := &qrOptions{}
+	cmd := &cobra.Command{
 		Use:   "qr",
 		Short: "Display the WiFi access QR code",
-		Long:  "Generates and displays a QR code for guests to scan and access WiFi",
-		Run: func(cmd *cobra.Command, args []string) {
-			displayQRCode()
+		Long: `Generates and displays a QR code for guests to scan and access WiFi.
+With --output the QR code is saved as an image instead (use - for stdout).`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return opts.validate()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.output == "" {
+				displayQRCode(opts.connectURL())
+				return nil
+			}
+			return exportQRCode(opts)
 		},
 	}
+	cmd.Flags().IntVar(&opts.size, "size", 256, "Image size in pixels (PNG max 512)")
+	cmd.Flags().StringVar(&opts.output, "output", "", "Save the QR code to this file, or - for stdout")
+	cmd.Flags().StringVar(&opts.url, "url", "", "Connect URL to encode (default <api>/connect); PNGs need admin credentials")
+	cmd.Flags().StringVar(&opts.format, "format", "png", "Image format for --output: png or svg")
+
+	return cmd
 }
 
 // newSessionsCommand creates the sessions list command.
 func newSessionsCommand() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "sessions",
-		Short: "List active sessions",
-		Long:  "Shows all currently active WiFi sessions with their status",
+		Use:     "sessions",
+		Aliases: []string{"session"},
+		Short:   "List active sessions",
+		Long:    "Shows all currently active WiFi sessions with their status",
 		Run: func(cmd *cobra.Command, args []string) {
 			listSessions()
 		},
 	}
 
-	cmd.AddCommand(&cobra.Command{
+	n := &notifier{}
+	var refreshRateMS int
+	watchCmd := &cobra.Command{
 		Use:   "watch",
 		Short: "Watch sessions in real-time",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if refreshRateMS <= 0 {
+				return fmt.Errorf("--refresh-rate must be positive")
+			}
+			return n.validate()
+		},
 		Run: func(cmd *cobra.Command, args []string) {
-			watchSessions()
+			watchSessions(n, time.Duration(refreshRateMS)*time.Millisecond)
 		},
-	})
+	}
+	n.addFlags(watchCmd)
+	watchCmd.Flags().IntVar(&refreshRateMS, "refresh-rate", 2000, "Refresh interval in milliseconds")
+	cmd.AddCommand(watchCmd)
+	cmd.AddCommand(newSessionReceiptCommand())
+	cmd.AddCommand(newSessionLookupTokenCommand())
 
 	return cmd
 }
 
 // newSettleCommand creates the settle command.
 func newSettleCommand() *cobra.Command {
-	return &cobra.Command{
+	var all, dryRun bool
+	var concurrency int
+
+	cmd := &cobra.Command{
 		Use:   "settle [channel-id]",
 		Short: "Settle a payment channel",
-		Long:  "Initiates settlement of a payment channel to receive funds",
-		Args:  cobra.ExactArgs(1),
+		Long:  "Initiates settlement of a payment channel to receive funds. Use --all to end every active session.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if all {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.ExactArgs(1)(cmd, args)
+		},
 		Run: func(cmd *cobra.Command, args []string) {
+			if all {
+				settleAll(dryRun, concurrency)
+				return
+			}
 			settleChannel(args[0])
 		},
 	}
+	cmd.Flags().BoolVar(&all, "all", false, "End and settle every active session")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "With --all, list the sessions without settling")
+	cmd.Flags().IntVar(&concurrency, "concurrency", 5, "With --all, number of sessions ended in parallel")
+
+	return cmd
 }
 
 // newStatusCommand creates the status command.
 func newStatusCommand() *cobra.Command {
-	return &cobra.Command{
+	var checkRouter bool
+	cmd := &cobra.Command{
 		Use:   "status",
 		Short: "Show system status",
 		Long:  "Displays the current status of the AirFi backend",
 		Run: func(cmd *cobra.Command, args []string) {
-			showStatus()
+			showStatus(checkRouter)
 		},
 	}
+	cmd.Flags().BoolVar(&checkRouter, "check-router", false, "Also report whether the backend can reach the WiFi router")
+
+	return cmd
 }
 
 // newWalletCommand creates the wallet command.
 func newWalletCommand() *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "wallet",
 		Short: "Show wallet info",
 		Long:  "Displays wallet address and balance",
@@ -135,6 +202,11 @@ func newWalletCommand() *cobra.Command {
 			showWallet()
 		},
 	}
+	cmd.AddCommand(newRefundAllCommand())
+	cmd.AddCommand(newWalletSearchCommand())
+	cmd.AddCommand(newWalletExportCommand())
+	cmd.AddCommand(newWalletImportCommand())
+	return cmd
 }
 
 // newTokenCommand creates the token command for getting JWT.
@@ -150,7 +222,7 @@ func newTokenCommand() *cobra.Command {
 	}
 }
 
-func runDashboard() {
+func runDashboard(n *notifier) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -192,7 +264,7 @@ func runDashboard() {
 	defer ticker.Stop()
 
 	// Initial draw
-	drawDashboard(sessions, events)
+	drawDashboard(n, sessions, events, nil)
 
 	for {
 		select {
@@ -207,9 +279,18 @@ func runDashboard() {
 			}
 
 			hasChanges := false
+			// Rows highlighted until the next redraw
+			changed := make(map[string]bool)
+			bells := make(map[string]bool)
 
 			for _, s := range sessions {
 				existing, found := knownSessions[s.ID]
+				if event, rowChanged := sessionChange(existing, s); rowChanged {
+					changed[s.ID] = true
+					if event != "" {
+						bells[event] = true
+					}
+				}
 				if !found {
 					// New session!
 					addEvent(fmt.Sprintf("NEW: %s CKB from %s",
@@ -251,6 +332,7 @@ func runDashboard() {
 					if diff > 0 {
 						addEvent(fmt.Sprintf("BALANCE: +%.2f CKB (Total: %.2f CKB)",
 							diff, wallet.BalanceCKB))
+						bells[eventPaymentReceived] = true
 					}
 					lastBalance = wallet.BalanceCKB
 					hasChanges = true
@@ -258,13 +340,19 @@ func runDashboard() {
 			}
 
 			// Always redraw to update remaining time
-			drawDashboard(sessions, events)
+			drawDashboard(n, sessions, events, changed)
+			// One bell per event type, however many sessions it covers
+			for _, event := range bellEvents {
+				if bells[event] {
+					n.notify(event)
+				}
+			}
 			_ = hasChanges // used for potential optimization later
 		}
 	}
 }
 
-func drawDashboard(sessions []Session, events []string) {
+func drawDashboard(n *notifier, sessions []Session, events []string, changed map[string]bool) {
 	// Clear screen
 	fmt.Print("\033[H\033[2J")
 
@@ -316,8 +404,12 @@ func drawDashboard(sessions []Session, events []string) {
 			if timeLeft == "" {
 				timeLeft = "-"
 			}
-			fmt.Printf("  %-10s %-10s %-10s %-12s %s\n",
+			row := fmt.Sprintf("  %-10s %-10s %-10s %-12s %s",
 				s.Status, s.Type, s.TotalPaid+" CKB", timeLeft, truncateAddress(s.GuestAddress, 18))
+			if changed[s.ID] {
+				row = n.highlight(row)
+			}
+			fmt.Println(row)
 		}
 		if len(sessions) > 8 {
 			fmt.Printf("  ... and %d more\n", len(sessions)-8)
@@ -385,12 +477,11 @@ func displayInitialDashboard() {
 	}
 }
 
-func displayQRCode() {
+func displayQRCode(connectURL string) {
 	fmt.Println("\nAirFi - Scan to Connect")
 	fmt.Println("-----------------------")
 
 	// Generate QR code with connection URL
-	connectURL := fmt.Sprintf("%s/connect", apiURL)
 	qrterminal.GenerateWithConfig(connectURL, qrterminal.Config{
 		Level:     qrterminal.L,
 		Writer:    os.Stdout,
@@ -435,9 +526,17 @@ type WalletInfo struct {
 
 // HealthInfo represents health check response
 type HealthInfo struct {
+	Status     string                     `json:"status"`
+	Timestamp  string                     `json:"timestamp"`
+	Connected  bool                       `json:"connected"`
+	Components map[string]ComponentHealth `json:"components"`
+}
+
+// ComponentHealth represents one subsystem in the health response
+type ComponentHealth struct {
 	Status    string `json:"status"`
-	Timestamp string `json:"timestamp"`
-	Connected bool   `json:"connected"`
+	LatencyMS *int64 `json:"latency_ms"`
+	Error     string `json:"error"`
 }
 
 // ChannelInfo represents channel info from the API
@@ -445,20 +544,27 @@ type ChannelInfo struct {
 	ChannelID   string `json:"channel_id"`
 	PeerAddress string `json:"peer_address"`
 	State       string `json:"state"`
+	Version     uint64 `json:"version"`
 	MyBalance   string `json:"my_balance"`
 	PeerBalance string `json:"peer_balance"`
 }
 
 func listSessions() {
-	fmt.Println("\nActive Sessions")
-	fmt.Println("---------------")
-
 	// Fetch sessions from API
 	sessions, err := fetchSessions()
 	if err != nil {
+		fmt.Println("\nActive Sessions")
+		fmt.Println("---------------")
 		fmt.Printf("Error: %s\n", err.Error())
 		return
 	}
+	printSessions(sessions, nil, nil)
+}
+
+// printSessions prints the session list, highlighting rows in changed.
+func printSessions(sessions []Session, n *notifier, changed map[string]bool) {
+	fmt.Println("\nActive Sessions")
+	fmt.Println("---------------")
 
 	if len(sessions) == 0 {
 		fmt.Println("No active sessions")
@@ -466,20 +572,29 @@ func listSessions() {
 	}
 
 	for _, s := range sessions {
-		timeLeft := s.RemainingTime
-		if timeLeft == "" {
-			timeLeft = "-"
-		}
-		paid := s.TotalPaid
-		if paid == "" {
-			paid = "0"
+		row := sessionRow(s)
+		if changed[s.ID] {
+			row = n.highlight(row)
 		}
-		fmt.Printf("[%s] %s | %s CKB | %s | %s\n",
-			s.Status, s.Type, paid, timeLeft, truncateAddress(s.GuestAddress, 30))
+		fmt.Println(row)
 	}
 	fmt.Println()
 }
 
+// sessionRow formats one session for the session list.
+func sessionRow(s Session) string {
+	timeLeft := s.RemainingTime
+	if timeLeft == "" {
+		timeLeft = "-"
+	}
+	paid := s.TotalPaid
+	if paid == "" {
+		paid = "0"
+	}
+	return fmt.Sprintf("[%s] %s | %s CKB | %s | %s",
+		s.Status, s.Type, paid, timeLeft, truncateAddress(s.GuestAddress, 30))
+}
+
 func fetchSessions() ([]Session, error) {
 	resp, err := httpClient.Get(fmt.Sprintf("%s/api/v1/sessions", apiURL))
 	if err != nil {
@@ -504,7 +619,7 @@ func fetchSessions() ([]Session, error) {
 	return result.Sessions, nil
 }
 
-func watchSessions() {
+func watchSessions(n *notifier, refreshRate time.Duration) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -518,7 +633,14 @@ func watchSessions() {
 
 	fmt.Println("Watching sessions... (Press Ctrl+C to exit)")
 
-	ticker := time.NewTicker(2 * time.Second)
+	// Sessions seen so far; nil until the first fetch so existing sessions
+	// aren't reported as new
+	var known map[string]*Session
+	// When each session first appeared, for the new-session highlight
+	firstSeen := make(map[string]time.Time)
+	renderer := &terminal.DiffRenderer{}
+
+	ticker := time.NewTicker(refreshRate)
 	defer ticker.Stop()
 
 	for {
@@ -527,12 +649,52 @@ func watchSessions() {
 			fmt.Println("\nStopped watching sessions")
 			return
 		case <-ticker.C:
-			// Clear screen and refresh
-			fmt.Print("\033[H\033[2J")
-			fmt.Printf("AirFi Host Monitor - %s\n", time.Now().Format("15:04:05"))
-			fmt.Println(strings.Repeat("─", 74))
-			showWalletCompact()
-			listSessions()
+			now := time.Now()
+			lines := []string{
+				fmt.Sprintf("AirFi Host Monitor - %s", now.Format("15:04:05")),
+				strings.Repeat("─", 74),
+				walletCompactLine(),
+			}
+
+			sessions, err := fetchSessions()
+			if err != nil {
+				lines = append(lines, "", "Error: "+err.Error())
+				fmt.Print(renderer.Render(lines))
+				continue
+			}
+
+			changed := make(map[string]bool)
+			bells := make(map[string]bool)
+			seen := make(map[string]time.Time, len(sessions))
+			for _, s := range sessions {
+				// Sessions present on the first fetch aren't new
+				seen[s.ID] = firstSeen[s.ID]
+				if known == nil {
+					continue
+				}
+				if known[s.ID] == nil {
+					seen[s.ID] = now
+				}
+				if event, rowChanged := sessionChange(known[s.ID], s); rowChanged {
+					changed[s.ID] = true
+					if event != "" {
+						bells[event] = true
+					}
+				}
+			}
+			firstSeen = seen
+			known = make(map[string]*Session, len(sessions))
+			for i := range sessions {
+				known[sessions[i].ID] = &sessions[i]
+			}
+
+			lines = append(lines, watchSessionLines(sessions, n, changed, firstSeen, now)...)
+			fmt.Print(renderer.Render(lines))
+			for _, event := range bellEvents {
+				if bells[event] {
+					n.notify(event)
+				}
+			}
 		}
 	}
 }
@@ -576,7 +738,7 @@ func settleChannel(channelID string) {
 	fmt.Printf("Status: %s\n", result.State)
 }
 
-func showStatus() {
+func showStatus(checkRouter bool) {
 	fmt.Println("\nAirFi System Status")
 	fmt.Println("-------------------")
 
@@ -589,8 +751,11 @@ func showStatus() {
 	}
 
 	status := "offline"
-	if health.Status == "healthy" {
+	switch health.Status {
+	case "healthy":
 		status = "online"
+	case "degraded":
+		status = "online (degraded)"
 	}
 
 	connected := "no"
@@ -601,11 +766,27 @@ func showStatus() {
 	fmt.Printf("API:     %s\n", apiURL)
 	fmt.Printf("Status:  %s\n", status)
 	fmt.Printf("CKB:     %s\n", connected)
+	if checkRouter {
+		fmt.Printf("Router:  %s\n", formatRouterHealth(health.Components["router"]))
+	}
 
 	// Show wallet
 	showWallet()
 }
 
+// formatRouterHealth describes the router component of a health response.
+func formatRouterHealth(router ComponentHealth) string {
+	switch {
+	case router.Status == "":
+		return "unknown (backend doesn't report router health)"
+	case router.Status != "ok":
+		return "unreachable - " + truncate(router.Error, 50)
+	case router.LatencyMS != nil:
+		return fmt.Sprintf("ok (%d ms)", *router.LatencyMS)
+	}
+	return "ok"
+}
+
 func showWallet() {
 	fmt.Println("\nHost Wallet")
 	fmt.Println("-----------")
@@ -622,10 +803,14 @@ func showWallet() {
 }
 
 func showWalletCompact() {
+	fmt.Println(walletCompactLine())
+}
+
+// walletCompactLine formats the wallet address and balance on one line.
+func walletCompactLine() string {
 	wallet, err := fetchWallet()
 	if err != nil {
-		fmt.Printf("Wallet: error - %s\n", err.Error())
-		return
+		return fmt.Sprintf("Wallet: error - %s", err.Error())
 	}
 
 	status := "disconnected"
@@ -633,7 +818,7 @@ func showWalletCompact() {
 		status = "connected"
 	}
 
-	fmt.Printf("Wallet: %s | %.2f CKB (%s)\n",
+	return fmt.Sprintf("Wallet: %s | %.2f CKB (%s)",
 		truncateAddress(wallet.Address, 20),
 		wallet.BalanceCKB,
 		status,
@@ -712,49 +897,24 @@ type TokenResponse struct {
 	AccessToken string `json:"access_token"`
 	ExpiresAt   string `json:"expires_at"`
 	ChannelID   string `json:"channel_id"`
-	Error       string `json:"error"`
-	Status      string `json:"status"`
-	Message     string `json:"message"`
+
+	AccessTokenExpiresAt string `json:"access_token_expires_at"`
 }
 
 func getSessionToken(sessionID string) {
 	fmt.Printf("\nGetting JWT token for session: %s\n", sessionID)
 	fmt.Println(strings.Repeat("-", 50))
 
-	resp, err := httpClient.Get(fmt.Sprintf("%s/api/v1/sessions/%s/token", apiURL, sessionID))
-	if err != nil {
-		fmt.Printf("Error: Failed to connect - %s\n", err.Error())
-		return
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Printf("Error: Failed to read response - %s\n", err.Error())
-		return
-	}
-
 	var result TokenResponse
-	if err := json.Unmarshal(body, &result); err != nil {
-		fmt.Printf("Error: Failed to parse response - %s\n", err.Error())
-		return
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		if result.Status != "" {
-			fmt.Printf("Status:  %s\n", result.Status)
-		}
-		if result.Message != "" {
-			fmt.Printf("Message: %s\n", result.Message)
-		} else if result.Error != "" {
-			fmt.Printf("Error:   %s\n", result.Error)
-		}
+	if err := adminRequest("GET", "/api/v1/sessions/"+sessionID+"/token", nil, &result); err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
 		return
 	}
 
 	fmt.Printf("Session: %s\n", result.SessionID)
 	fmt.Printf("Channel: %s\n", result.ChannelID)
 	fmt.Printf("Expires: %s\n", result.ExpiresAt)
+	fmt.Printf("Token expires: %s\n", result.AccessTokenExpiresAt)
 	fmt.Println()
 	fmt.Println("JWT Access Token:")
 	fmt.Println(strings.Repeat("-", 50))
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/airfi/airfi-perun-nervous/internal/terminal"
+)
+
+// Events that can ring the terminal bell.
+const (
+	eventSessionCreated  = "session.created"
+	eventPaymentReceived = "payment.received"
+	eventSessionSettled  = "session.settled"
+)
+
+var bellEvents = []string{eventSessionCreated, eventPaymentReceived, eventSessionSettled}
+
+// notifier rings the bell and highlights rows when sessions change.
+type notifier struct {
+	noBell  bool
+	noColor bool
+	bellOn  []string
+}
+
+// addFlags registers the notification flags on cmd.
+func (n *notifier) addFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&n.noBell, "no-bell", false, "Don't ring the terminal bell on new activity")
+	cmd.Flags().BoolVar(&n.noColor, "no-color", false, "Don't highlight changed sessions in color")
+	cmd.Flags().StringSliceVar(&n.bellOn, "bell-on", bellEvents, "Events that ring the bell (session.created, payment.received, session.settled)")
+}
+
+// validate checks that --bell-on only names known events.
+func (n *notifier) validate() error {
+	for _, event := range n.bellOn {
+		known := false
+		for _, e := range bellEvents {
+			if event == e {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown --bell-on event %q", event)
+		}
+	}
+	if !terminal.IsColorSupported() {
+		n.noColor = true
+	}
+	return nil
+}
+
+// notify rings the bell if it's enabled for event.
+func (n *notifier) notify(event string) {
+	if n.noBell {
+		return
+	}
+	for _, e := range n.bellOn {
+		if e == event {
+			terminal.Bell()
+			return
+		}
+	}
+}
+
+// highlight colors a changed session row green.
+func (n *notifier) highlight(row string) string {
+	if n.noColor {
+		return row
+	}
+	return terminal.Highlight(row, terminal.Green)
+}
+
+const (
+	// newSessionHighlight is how long a new session stays green in watch mode.
+	newSessionHighlight = 5 * time.Second
+	// expiringThreshold is the remaining time below which a session turns red.
+	expiringThreshold = 5 * time.Minute
+)
+
+// watchSessionLines renders the session list for watch mode. Sessions close
+// to expiry are red; changed sessions and sessions first seen within
+// newSessionHighlight are green.
+func watchSessionLines(sessions []Session, n *notifier, changed map[string]bool, firstSeen map[string]time.Time, now time.Time) []string {
+	lines := []string{"", "Active Sessions", "---------------"}
+	if len(sessions) == 0 {
+		return append(lines, "No active sessions")
+	}
+
+	for _, s := range sessions {
+		row := sessionRow(s)
+		if !n.noColor {
+			if remaining, ok := parseRemainingTime(s.RemainingTime); ok && remaining < expiringThreshold {
+				row = terminal.Highlight(row, terminal.Red)
+			} else if seen := firstSeen[s.ID]; changed[s.ID] || (!seen.IsZero() && now.Sub(seen) < newSessionHighlight) {
+				row = terminal.Highlight(row, terminal.Green)
+			}
+		}
+		lines = append(lines, row)
+	}
+	return lines
+}
+
+// parseRemainingTime parses the backend's "m:ss" or "h:mm:ss" remaining time.
+// It returns false for "-" and anything else it doesn't recognise.
+func parseRemainingTime(s string) (time.Duration, bool) {
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, false
+	}
+	var d time.Duration
+	for _, part := range parts {
+		v, err := strconv.Atoi(part)
+		if err != nil || v < 0 {
+			return 0, false
+		}
+		d = d*60 + time.Duration(v)
+	}
+	return d * time.Second, true
+}
+
+// sessionChange classifies how a session differs from its last known state,
+// returning the bell event for it, if any, and whether its row changed.
+func sessionChange(existing *Session, s Session) (event string, changed bool) {
+	switch {
+	case existing == nil:
+		return eventSessionCreated, true
+	case existing.Status != s.Status:
+		if s.Status == "settled" || s.Status == "ended" {
+			return eventSessionSettled, true
+		}
+		return "", true
+	case existing.TotalPaid != s.TotalPaid:
+		return eventPaymentReceived, true
+	}
+	return "", false
+}
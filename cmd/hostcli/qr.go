@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// qrOptions configures the qr command.
+type qrOptions struct {
+	size   int
+	output string // file path, or "-" for stdout
+	url    string // overrides the default connect URL
+	format string // png or svg
+}
+
+// connectURL returns the URL guests open to connect.
+func (o *qrOptions) connectURL() string {
+	if o.url != "" {
+		return o.url
+	}
+	return fmt.Sprintf("%s/connect", apiURL)
+}
+
+// validate checks the flag values before anything is fetched or written.
+func (o *qrOptions) validate() error {
+	if o.format != "png" && o.format != "svg" {
+		return fmt.Errorf("invalid --format %q: must be png or svg", o.format)
+	}
+	if o.size <= 0 {
+		return fmt.Errorf("invalid --size %d: must be positive", o.size)
+	}
+	if o.url != "" {
+		u, err := url.Parse(o.url)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("invalid --url %q: must be an http(s) URL", o.url)
+		}
+	}
+	return nil
+}
+
+// exportQRCode writes the connect QR code to the output file or stdout.
+// PNGs are rendered by the backend; SVGs are generated locally.
+func exportQRCode(o *qrOptions) error {
+	var data []byte
+	var err error
+	if o.format == "svg" {
+		data, err = renderQRSVG(o.connectURL(), o.size)
+	} else {
+		data, err = fetchQRPNG(o)
+	}
+	if err != nil {
+		return err
+	}
+
+	if o.output == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(o.output, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", o.output, err)
+	}
+	fmt.Fprintf(os.Stderr, "QR code for %s saved to %s\n", o.connectURL(), o.output)
+	return nil
+}
+
+// fetchQRPNG downloads the connect QR code PNG from the backend. The
+// backend only accepts a --url override with admin credentials.
+func fetchQRPNG(o *qrOptions) ([]byte, error) {
+	query := url.Values{"size": {fmt.Sprint(o.size)}}
+	if o.url != "" {
+		query.Set("url", o.url)
+	}
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/api/v1/qr.png?%s", apiURL, query.Encode()), nil)
+	if err != nil {
+		return nil, err
+	}
+	if o.url != "" {
+		if err := authorizeAdminRequest(req); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("backend returned %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+// renderQRSVG renders content as a size x size pixel SVG QR code, one
+// rect per dark module.
+func renderQRSVG(content string, size int) ([]byte, error) {
+	qr, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate QR code: %w", err)
+	}
+	bitmap := qr.Bitmap()
+	modules := len(bitmap)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+"\n",
+		size, size, modules, modules)
+	fmt.Fprintf(&sb, `<rect width="%d" height="%d" fill="#fff"/>`+"\n", modules, modules)
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&sb, `<rect x="%d" y="%d" width="1" height="1"/>`+"\n", x, y)
+			}
+		}
+	}
+	sb.WriteString("</svg>\n")
+	return []byte(sb.String()), nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// ReceiptResponse is the signed receipt returned for a session.
+type ReceiptResponse struct {
+	SessionID             string `json:"session_id"`
+	StartTime             string `json:"start_time"`
+	EndTime               string `json:"end_time"`
+	TotalDurationSeconds  int64  `json:"total_duration_seconds"`
+	BilledDurationSeconds int64  `json:"billed_duration_seconds"`
+	TotalCKBSpent         int64  `json:"total_ckb_spent"`
+	Payments              []struct {
+		AmountShannons int64  `json:"amount_shannons"`
+		Kind           string `json:"kind"`
+		CreatedAt      string `json:"created_at"`
+	} `json:"payments"`
+	ChannelID        string `json:"channel_id"`
+	GuestAddress     string `json:"guest_address"`
+	ReceiptHash      string `json:"receipt_hash"`
+	ReceiptSignature string `json:"receipt_signature"`
+}
+
+// newSessionReceiptCommand creates the receipt command under sessions.
+func newSessionReceiptCommand() *cobra.Command {
+	var save string
+
+	cmd := &cobra.Command{
+		Use:   "receipt [session-id]",
+		Short: "Show or save a session's payment receipt",
+		Long:  "Fetches the host-signed payment receipt for a session. With --save the receipt is written to a file for the guest.",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			showReceipt(args[0], save)
+		},
+	}
+	cmd.Flags().StringVar(&save, "save", "", "Write the receipt JSON to this file")
+
+	return cmd
+}
+
+func showReceipt(sessionID, save string) {
+	var body json.RawMessage
+	if err := adminRequest("GET", "/api/v1/sessions/"+sessionID+"/receipt", nil, &body); err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	var receipt ReceiptResponse
+	if err := json.Unmarshal(body, &receipt); err != nil {
+		fmt.Printf("Error: Failed to parse response - %s\n", err.Error())
+		return
+	}
+
+	if save != "" {
+		if err := os.WriteFile(save, body, 0644); err != nil {
+			fmt.Printf("Error: failed to write %s: %s\n", save, err.Error())
+			return
+		}
+		fmt.Printf("Receipt for session %s written to %s\n", sessionID, save)
+		return
+	}
+
+	fmt.Printf("\nReceipt for session: %s\n", receipt.SessionID)
+	fmt.Println(strings.Repeat("-", 50))
+	fmt.Printf("Start:    %s\n", receipt.StartTime)
+	fmt.Printf("End:      %s\n", receipt.EndTime)
+	fmt.Printf("Duration: %d min (billed %d min)\n", receipt.TotalDurationSeconds/60, receipt.BilledDurationSeconds/60)
+	fmt.Printf("Spent:    %d CKB\n", receipt.TotalCKBSpent)
+	if receipt.ChannelID != "" {
+		fmt.Printf("Channel:  %s\n", receipt.ChannelID)
+	}
+	fmt.Printf("Guest:    %s\n", receipt.GuestAddress)
+	fmt.Printf("Payments: %d\n", len(receipt.Payments))
+	for _, p := range receipt.Payments {
+		fmt.Printf("  %s  %-12s %.8f CKB\n", p.CreatedAt, p.Kind, float64(p.AmountShannons)/1e8)
+	}
+	fmt.Println(strings.Repeat("-", 50))
+	fmt.Printf("Hash:      %s\n", receipt.ReceiptHash)
+	if receipt.ReceiptSignature != "" {
+		fmt.Printf("Signature: %s\n", receipt.ReceiptSignature)
+	}
+}
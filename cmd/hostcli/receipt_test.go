@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestShowReceipt_SendsCredentials(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/sessions/s1/receipt", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "unauthorized"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"session_id": "s1", "receipt_hash": "0xabc"})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	apiURL = server.URL
+	apiKey = "test-key"
+	t.Cleanup(func() { apiURL, apiKey = "", "" })
+
+	path := filepath.Join(t.TempDir(), "receipt.json")
+	showReceipt("s1", path)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Expected the receipt saved: %v", err)
+	}
+	var receipt ReceiptResponse
+	if err := json.Unmarshal(data, &receipt); err != nil || receipt.ReceiptHash != "0xabc" {
+		t.Errorf("Unexpected saved receipt: %s", data)
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// refundPollInterval is how often --wait checks the refund transaction.
+var refundPollInterval = 5 * time.Second
+
+// RefundableWallet represents a wallet that still holds CKB.
+type RefundableWallet struct {
+	WalletID      string `json:"wallet_id"`
+	Address       string `json:"address"`
+	BalanceCKB    int64  `json:"balance_ckb"`
+	SenderAddress string `json:"sender_address"`
+	SessionID     string `json:"session_id"`
+	Status        string `json:"status"`
+}
+
+// newRefundAllCommand creates the bulk refund command under wallet.
+func newRefundAllCommand() *cobra.Command {
+	var minCKB int64
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "refund-all",
+		Short: "Refund wallets that still hold CKB",
+		Long:  "Finds guest wallets that were never withdrawn and refunds each to its original sender. Use --dry-run to only list them.",
+		Run: func(cmd *cobra.Command, args []string) {
+			refundAll(minCKB, dryRun)
+		},
+	}
+	cmd.Flags().Int64Var(&minCKB, "min-ckb", 61, "Only include wallets holding at least this much CKB")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "List matching wallets without withdrawing")
+
+	return cmd
+}
+
+func refundAll(minCKB int64, dryRun bool) {
+	var result struct {
+		Wallets  []RefundableWallet `json:"wallets"`
+		TotalCKB int64              `json:"total_ckb"`
+	}
+	path := fmt.Sprintf("/api/v1/admin/wallets/refundable?min_ckb=%d", minCKB)
+	if err := adminRequest("GET", path, nil, &result); err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	if len(result.Wallets) == 0 {
+		fmt.Printf("No wallets holding at least %d CKB\n", minCKB)
+		return
+	}
+
+	fmt.Printf("\n%-18s %-24s %10s %-24s %s\n", "WALLET", "ADDRESS", "BALANCE", "SENDER", "STATUS")
+	fmt.Println(strings.Repeat("-", 90))
+	for _, w := range result.Wallets {
+		sender := w.SenderAddress
+		if sender == "" {
+			sender = "unknown"
+		}
+		fmt.Printf("%-18s %-24s %6d CKB %-24s %s\n",
+			truncate(w.WalletID, 18),
+			truncateAddress(w.Address, 24),
+			w.BalanceCKB,
+			truncateAddress(sender, 24),
+			w.Status,
+		)
+	}
+	fmt.Println(strings.Repeat("-", 90))
+	fmt.Printf("%d wallets, %d CKB total\n", len(result.Wallets), result.TotalCKB)
+
+	if dryRun {
+		fmt.Println("\nDry run: no withdrawals made")
+		return
+	}
+
+	fmt.Println()
+	refunded := 0
+	for _, w := range result.Wallets {
+		// Refunds go through the session, and need somewhere to send to
+		if w.SessionID == "" || w.SenderAddress == "" {
+			fmt.Printf("%s: skipped (no session or sender address)\n", w.WalletID)
+			continue
+		}
+
+		var refund struct {
+			TxHash string `json:"tx_hash"`
+		}
+		payload := map[string]string{"to_address": w.SenderAddress}
+		if err := adminRequest("POST", "/api/v1/sessions/"+w.SessionID+"/refund", payload, &refund); err != nil {
+			fmt.Printf("%s: failed - %s\n", w.WalletID, err.Error())
+			continue
+		}
+		refunded++
+		fmt.Printf("%s: refunded %d CKB (tx %s)\n", w.WalletID, w.BalanceCKB, refund.TxHash)
+	}
+	fmt.Printf("\nRefunded %d of %d wallets\n", refunded, len(result.Wallets))
+}
+
+// refundResult is the backend's response to a manual refund.
+type refundResult struct {
+	SessionID string `json:"session_id"`
+	TxHash    string `json:"tx_hash"`
+	ToAddress string `json:"to_address"`
+	AmountCKB int64  `json:"amount_ckb"`
+	Status    string `json:"status"`
+}
+
+// refundEstimate is the backend's refund readiness for a session.
+type refundEstimate struct {
+	Eligible        bool    `json:"eligible"`
+	SenderAddress   string  `json:"sender_address"`
+	EstimatedCKB    int64   `json:"estimated_ckb"`
+	EstimatedFeeCKB float64 `json:"estimated_fee_ckb"`
+	CanWithdraw     bool    `json:"can_withdraw"`
+}
+
+// newRefundCommand creates the single-session refund command.
+func newRefundCommand() *cobra.Command {
+	var toAddress string
+	var dryRun, wait bool
+
+	cmd := &cobra.Command{
+		Use:   "refund <session-id>",
+		Short: "Refund a session's wallet",
+		Long:  "Withdraws the CKB left in a session's guest wallet. Without --to, the backend sends it to the sender address it detected for the session.",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			runRefund(args[0], toAddress, dryRun, wait)
+		},
+	}
+	cmd.Flags().StringVar(&toAddress, "to", "", "CKB address to refund to (default: detected sender address)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the amount and fee without refunding")
+	cmd.Flags().BoolVar(&wait, "wait", false, "Wait until the refund transaction is committed")
+
+	return cmd
+}
+
+func runRefund(sessionID, toAddress string, dryRun, wait bool) {
+	if dryRun {
+		estimate, err := fetchRefundEstimate(sessionID)
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			return
+		}
+		if !estimate.Eligible {
+			fmt.Println("Nothing to refund: wallet is empty or already withdrawn")
+			return
+		}
+		fmt.Printf("Would send %d CKB, estimated fee %s CKB\n",
+			estimate.EstimatedCKB, strconv.FormatFloat(estimate.EstimatedFeeCKB, 'f', -1, 64))
+		return
+	}
+
+	result, err := refundSession(sessionID, toAddress)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+	fmt.Printf("Refund sent: %d CKB → %s (tx: %s)\n", result.AmountCKB, result.ToAddress, result.TxHash)
+
+	if !wait {
+		return
+	}
+	fmt.Println("Waiting for the transaction to be committed...")
+	if err := waitForRefundTx(sessionID, refundPollInterval); err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+	fmt.Println("Refund committed")
+}
+
+// refundSession asks the backend to refund a session's wallet. An empty
+// toAddress lets the backend use the detected sender address.
+func refundSession(sessionID, toAddress string) (*refundResult, error) {
+	payload := map[string]string{}
+	if toAddress != "" {
+		payload["to_address"] = toAddress
+	}
+	var result refundResult
+	if err := adminRequest("POST", "/api/v1/sessions/"+sessionID+"/refund", payload, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// fetchRefundEstimate returns how much a refund of the session would send.
+func fetchRefundEstimate(sessionID string) (*refundEstimate, error) {
+	var estimate refundEstimate
+	if err := adminRequest("GET", "/api/v1/sessions/"+sessionID+"/refund/status", nil, &estimate); err != nil {
+		return nil, err
+	}
+	return &estimate, nil
+}
+
+// waitForRefundTx polls the session's refund transaction every interval
+// until it is committed. A rejected transaction is an error.
+func waitForRefundTx(sessionID string, interval time.Duration) error {
+	for {
+		var tx struct {
+			TxStatus string `json:"tx_status"`
+		}
+		if err := adminRequest("GET", "/api/v1/sessions/"+sessionID+"/refund/tx", nil, &tx); err != nil {
+			return err
+		}
+		switch tx.TxStatus {
+		case "committed":
+			return nil
+		case "rejected":
+			return fmt.Errorf("refund transaction was rejected")
+		}
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newRefundTestServer mocks the backend refund endpoints for session s1.
+// The refund transaction reports pending until it has been polled twice.
+func newRefundTestServer(t *testing.T) *map[string]string {
+	t.Helper()
+
+	var lastPayload map[string]string
+	polls := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/sessions/s1/refund", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "unexpected method", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "unauthorized"})
+			return
+		}
+		lastPayload = map[string]string{}
+		json.NewDecoder(r.Body).Decode(&lastPayload)
+		to := lastPayload["to_address"]
+		if to == "" {
+			to = "ckt1sender"
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"session_id": "s1",
+			"tx_hash":    "0xabc",
+			"to_address": to,
+			"amount_ckb": 439,
+			"status":     "refunded",
+		})
+	})
+	mux.HandleFunc("/api/v1/sessions/s1/refund/status", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"eligible":          true,
+			"sender_address":    "ckt1sender",
+			"estimated_ckb":     439,
+			"estimated_fee_ckb": 0.001,
+			"can_withdraw":      true,
+		})
+	})
+	mux.HandleFunc("/api/v1/sessions/s1/refund/tx", func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		status := "pending"
+		if polls > 2 {
+			status = "committed"
+		}
+		json.NewEncoder(w).Encode(map[string]any{"tx_hash": "0xabc", "tx_status": status})
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	apiURL = server.URL
+	apiKey = "test-key"
+	t.Cleanup(func() { apiURL, apiKey = "", "" })
+	return &lastPayload
+}
+
+func TestRefundSession(t *testing.T) {
+	lastPayload := newRefundTestServer(t)
+
+	result, err := refundSession("s1", "ckt1dest")
+	if err != nil {
+		t.Fatalf("refundSession failed: %v", err)
+	}
+	if result.TxHash != "0xabc" || result.ToAddress != "ckt1dest" || result.AmountCKB != 439 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if (*lastPayload)["to_address"] != "ckt1dest" {
+		t.Errorf("Expected to_address sent, got %v", *lastPayload)
+	}
+
+	// Without --to the backend picks the sender address
+	result, err = refundSession("s1", "")
+	if err != nil {
+		t.Fatalf("refundSession failed: %v", err)
+	}
+	if _, sent := (*lastPayload)["to_address"]; sent {
+		t.Errorf("Expected no to_address sent, got %v", *lastPayload)
+	}
+	if result.ToAddress != "ckt1sender" {
+		t.Errorf("Expected refund to the sender address, got %s", result.ToAddress)
+	}
+}
+
+func TestRefundSession_Unauthorized(t *testing.T) {
+	newRefundTestServer(t)
+	apiKey = "wrong-key"
+
+	if _, err := refundSession("s1", ""); err == nil {
+		t.Fatal("Expected error for rejected credentials")
+	}
+}
+
+func TestFetchRefundEstimate(t *testing.T) {
+	newRefundTestServer(t)
+
+	estimate, err := fetchRefundEstimate("s1")
+	if err != nil {
+		t.Fatalf("fetchRefundEstimate failed: %v", err)
+	}
+	if !estimate.Eligible || estimate.EstimatedCKB != 439 || estimate.EstimatedFeeCKB != 0.001 {
+		t.Errorf("Unexpected estimate: %+v", estimate)
+	}
+
+	if _, err := fetchRefundEstimate("missing"); err == nil {
+		t.Error("Expected error for unknown session")
+	}
+}
+
+func TestWaitForRefundTx(t *testing.T) {
+	newRefundTestServer(t)
+
+	if err := waitForRefundTx("s1", time.Millisecond); err != nil {
+		t.Fatalf("waitForRefundTx failed: %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/airfi/airfi-perun-nervous/internal/router"
+)
+
+// newRouterCommand creates the router command.
+func newRouterCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "router",
+		Short: "Router setup helpers",
+	}
+	cmd.AddCommand(newRouterGenerateConfigCommand())
+	return cmd
+}
+
+// newRouterGenerateConfigCommand creates the generate-config command under router.
+func newRouterGenerateConfigCommand() *cobra.Command {
+	var serverURL, routerType string
+	var authTimeout int
+
+	cmd := &cobra.Command{
+		Use:   "generate-config",
+		Short: "Print the router commands that set up the captive portal",
+		Long:  "Prints the UCI commands that configure OpenNDS to redirect guests to the AirFi server. Run them on the router over SSH.",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if routerType != "openwrt" {
+				return fmt.Errorf("unsupported --router-type %q (supported: openwrt)", routerType)
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := router.GenerateOpenWrtUCIConfig(router.OpenWrtConfig{AuthTimeout: authTimeout}, serverURL)
+			if err != nil {
+				return err
+			}
+			fmt.Print(config)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&serverURL, "server-url", "", "AirFi server URL guests are redirected to")
+	cmd.Flags().StringVar(&routerType, "router-type", "openwrt", "Router type: openwrt")
+	cmd.Flags().IntVar(&authTimeout, "auth-timeout", 0, "OpenNDS session timeout in seconds (0 = OpenNDS default)")
+	cmd.MarkFlagRequired("server-url")
+
+	return cmd
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// settlePollInterval is how often an ended session is checked for its
+	// settlement.
+	settlePollInterval = 2 * time.Second
+	// settleWaitTimeout bounds the wait for one session's settlement; the
+	// backend gives up closing a channel after 5 minutes.
+	settleWaitTimeout = 5 * time.Minute
+)
+
+// settleResult is the outcome of ending one session.
+type settleResult struct {
+	SessionID string
+	TxHash    string
+	Err       error
+}
+
+// settleAll ends every active session through the API, up to concurrency at
+// once, and waits for each channel to close to report its settlement
+// transaction.
+func settleAll(dryRun bool, concurrency int) {
+	sessions, err := fetchSessions()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	active := make([]Session, 0, len(sessions))
+	for _, s := range sessions {
+		if s.Status == "active" {
+			active = append(active, s)
+		}
+	}
+	if len(active) == 0 {
+		fmt.Println("No active sessions")
+		return
+	}
+
+	if dryRun {
+		fmt.Printf("\n%-38s %-20s %s\n", "SESSION", "CHANNEL", "REMAINING")
+		fmt.Println(strings.Repeat("-", 70))
+		for _, s := range active {
+			fmt.Printf("%-38s %-20s %s\n", s.ID, truncate(s.ChannelID, 18), s.RemainingTime)
+		}
+		fmt.Printf("\n%d session(s) would be settled\n", len(active))
+		return
+	}
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	results := make([]settleResult, len(active))
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, s := range active {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, sessionID string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			txHash, err := endSession(sessionID)
+			results[i] = settleResult{SessionID: sessionID, TxHash: txHash, Err: err}
+		}(i, s.ID)
+	}
+	wg.Wait()
+
+	fmt.Printf("\n%-38s %-10s %s\n", "SESSION", "RESULT", "TX HASH")
+	fmt.Println(strings.Repeat("-", 118))
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+			fmt.Printf("%-38s %-10s %s\n", r.SessionID, "failed", r.Err.Error())
+			continue
+		}
+		txHash := r.TxHash
+		if txHash == "" {
+			txHash = "none recorded (see backend log)"
+		}
+		fmt.Printf("%-38s %-10s %s\n", r.SessionID, "settled", txHash)
+	}
+	fmt.Printf("\nSettled: %d  Failed: %d\n", len(results)-failed, failed)
+}
+
+// endSession calls the end-session endpoint, then polls the session until
+// the backend has settled it in the background, and returns the settlement
+// transaction hash. The hash is empty when the channel wasn't closed
+// cooperatively.
+func endSession(sessionID string) (string, error) {
+	if err := adminRequest("POST", "/api/v1/sessions/"+sessionID+"/end", nil, nil); err != nil {
+		return "", err
+	}
+
+	deadline := time.Now().Add(settleWaitTimeout)
+	for {
+		var session struct {
+			Status       string `json:"status"`
+			SettleTxHash string `json:"settle_tx_hash"`
+		}
+		if err := adminRequest("GET", "/api/v1/sessions/"+sessionID, nil, &session); err != nil {
+			return "", err
+		}
+		if session.Status == "settled" {
+			return session.SettleTxHash, nil
+		}
+		if time.Now().After(deadline) {
+			return "", fmt.Errorf("ended, but not settled after %s", settleWaitTimeout)
+		}
+		time.Sleep(settlePollInterval)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// newSessionLookupTokenCommand creates the sessions lookup-token command.
+func newSessionLookupTokenCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "lookup-token [token]",
+		Short: "Find the session an access token was issued to",
+		Long:  "Maps a raw access token, e.g. one a guest reports, to its session without validating it. Only a session's latest token is found.",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			lookupToken(args[0])
+		},
+	}
+}
+
+func lookupToken(token string) {
+	var result struct {
+		SessionID string `json:"session_id"`
+		Status    string `json:"status"`
+		ExpiresAt string `json:"expires_at"`
+	}
+	if err := adminRequest("POST", "/api/v1/admin/sessions/lookup-token", map[string]string{"token": token}, &result); err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	fmt.Printf("Session: %s\n", result.SessionID)
+	fmt.Printf("Status:  %s\n", result.Status)
+	fmt.Printf("Expires: %s\n", result.ExpiresAt)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/airfi/airfi-perun-nervous/internal/guest"
+)
+
+// newWalletExportCommand creates the wallet backup command under wallet.
+func newWalletExportCommand() *cobra.Command {
+	var output string
+	var encrypt bool
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Back up guest wallet keys",
+		Long:  "Writes every guest wallet's ID, address and private key to a file. The keys are the only way to recover guest funds if the database is lost, so keep the file safe and prefer --encrypt.",
+		Run: func(cmd *cobra.Command, args []string) {
+			exportWallets(output, encrypt)
+		},
+	}
+	cmd.Flags().StringVar(&output, "output", "", "File to write the backup to")
+	cmd.Flags().BoolVar(&encrypt, "encrypt", false, "Encrypt the backup with a passphrase (AES-256-GCM)")
+	cmd.MarkFlagRequired("output")
+
+	return cmd
+}
+
+// newWalletImportCommand creates the wallet restore command under wallet.
+func newWalletImportCommand() *cobra.Command {
+	var input string
+	var decrypt bool
+
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Restore guest wallet keys from a backup",
+		Long:  "Loads wallets from a file written by wallet export. Wallets missing from the database are restored as expired so they can be refunded.",
+		Run: func(cmd *cobra.Command, args []string) {
+			importWallets(input, decrypt)
+		},
+	}
+	cmd.Flags().StringVar(&input, "input", "", "Backup file to read")
+	cmd.Flags().BoolVar(&decrypt, "decrypt", false, "Prompt for the passphrase of an encrypted backup")
+	cmd.MarkFlagRequired("input")
+
+	return cmd
+}
+
+func exportWallets(output string, encrypt bool) {
+	payload := map[string]string{}
+	if encrypt {
+		passphrase, err := guest.PromptPassphrase("Backup passphrase: ")
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			return
+		}
+		confirm, err := guest.PromptPassphrase("Repeat passphrase: ")
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			return
+		}
+		if passphrase == "" || passphrase != confirm {
+			fmt.Println("Error: passphrases are empty or don't match")
+			return
+		}
+		payload["passphrase"] = passphrase
+	}
+
+	var backup json.RawMessage
+	if err := adminRequest("POST", "/api/v1/admin/wallets/export", payload, &backup); err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	if err := os.WriteFile(output, backup, 0600); err != nil {
+		fmt.Printf("Error: failed to write %s: %s\n", output, err.Error())
+		return
+	}
+	if encrypt {
+		fmt.Printf("Encrypted wallet backup written to %s\n", output)
+	} else {
+		fmt.Printf("Wallet backup written to %s (unencrypted, contains private keys)\n", output)
+	}
+}
+
+func importWallets(input string, decrypt bool) {
+	data, err := os.ReadFile(input)
+	if err != nil {
+		fmt.Printf("Error: failed to read %s: %s\n", input, err.Error())
+		return
+	}
+	if !json.Valid(data) {
+		fmt.Printf("Error: %s is not a wallet backup\n", input)
+		return
+	}
+
+	payload := map[string]interface{}{"backup": json.RawMessage(data)}
+	if decrypt {
+		passphrase, err := guest.PromptPassphrase("Backup passphrase: ")
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			return
+		}
+		payload["passphrase"] = passphrase
+	}
+
+	var result struct {
+		Imported int `json:"imported"`
+		Restored int `json:"restored"`
+	}
+	if err := adminRequest("POST", "/api/v1/admin/wallets/import", payload, &result); err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+	fmt.Printf("Imported %d wallets, %d restored to the database\n", result.Imported, result.Restored)
+}
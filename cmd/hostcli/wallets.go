@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// WalletSearchResult represents a guest wallet returned by a search.
+type WalletSearchResult struct {
+	WalletID   string `json:"wallet_id"`
+	Address    string `json:"address"`
+	BalanceCKB int64  `json:"balance_ckb"`
+	SessionID  string `json:"session_id"`
+	Status     string `json:"status"`
+	MACAddress string `json:"mac_address"`
+	CreatedAt  string `json:"created_at"`
+}
+
+// newWalletSearchCommand creates the wallet search command under wallet.
+func newWalletSearchCommand() *cobra.Command {
+	var mac, status string
+	var minBalance int64
+	var limit int
+
+	cmd := &cobra.Command{
+		Use:   "search",
+		Short: "Search guest wallets",
+		Long:  "Lists guest wallets matching all given filters, e.g. every wallet created for one device's MAC address.",
+		Run: func(cmd *cobra.Command, args []string) {
+			searchWallets(mac, status, minBalance, limit)
+		},
+	}
+	cmd.Flags().StringVar(&mac, "mac", "", "Only include wallets created for this MAC address")
+	cmd.Flags().StringVar(&status, "status", "", "Only include wallets with this status (created, funded, withdrawn)")
+	cmd.Flags().Int64Var(&minBalance, "min-balance", 0, "Only include wallets holding at least this much CKB")
+	cmd.Flags().IntVar(&limit, "limit", 100, "Maximum number of wallets to list")
+
+	return cmd
+}
+
+func searchWallets(mac, status string, minBalance int64, limit int) {
+	params := url.Values{}
+	if mac != "" {
+		params.Set("mac", mac)
+	}
+	if status != "" {
+		params.Set("status", status)
+	}
+	if minBalance > 0 {
+		params.Set("min_balance", strconv.FormatInt(minBalance, 10))
+	}
+	params.Set("limit", strconv.Itoa(limit))
+
+	var result struct {
+		Wallets []WalletSearchResult `json:"wallets"`
+	}
+	if err := adminRequest("GET", "/api/v1/admin/wallets?"+params.Encode(), nil, &result); err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	if len(result.Wallets) == 0 {
+		fmt.Println("No matching wallets")
+		return
+	}
+
+	fmt.Printf("\n%-18s %-24s %10s %-10s %-17s %s\n", "WALLET", "ADDRESS", "BALANCE", "STATUS", "MAC", "SESSION")
+	fmt.Println(strings.Repeat("-", 100))
+	for _, w := range result.Wallets {
+		fmt.Printf("%-18s %-24s %6d CKB %-10s %-17s %s\n",
+			truncate(w.WalletID, 18),
+			truncateAddress(w.Address, 24),
+			w.BalanceCKB,
+			w.Status,
+			w.MACAddress,
+			truncate(w.SessionID, 18),
+		)
+	}
+	fmt.Println(strings.Repeat("-", 100))
+	fmt.Printf("%d wallets\n", len(result.Wallets))
+}
@@ -205,7 +205,7 @@ func (h *Handler) ProcessPayment(c *gin.Context) {
 		SessionID:   sess.ID,
 		Token:       token,
 		Duration:    sess.Duration.String(),
-		ExpiresAt:   sess.StartTime.Add(sess.Duration).Format(time.RFC3339),
+		ExpiresAt:   sess.ExpiresAt().Format(time.RFC3339),
 		MyBalance:   myBalance.String(),
 		PeerBalance: peerBalance.String(),
 	})
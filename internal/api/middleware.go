@@ -5,6 +5,8 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/airfi/airfi-perun-nervous/internal/auth"
 )
 
 // AuthMiddleware returns a middleware that validates JWT tokens.
@@ -30,6 +32,7 @@ func (h *Handler) AuthMiddleware() gin.HandlerFunc {
 		// Validate token and get session
 		session, err := h.sessionManager.ValidateToken(tokenString)
 		if err != nil {
+			auth.LogRejectedToken(h.logger, tokenString, err)
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
 			c.Abort()
 			return
@@ -114,7 +114,7 @@ func (h *PerunHandler) OpenChannel(c *gin.Context) {
 		return
 	}
 
-	channelIDHex := fmt.Sprintf("%x", channel.ID)
+	channelIDHex := perun.ChannelID(channel.ID).String()
 
 	// Create session if session manager available
 	var sessionID string
@@ -294,7 +294,7 @@ func (h *PerunHandler) GetChannel(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"channel_id":   fmt.Sprintf("%x", channel.ID),
+		"channel_id":   perun.ChannelID(channel.ID).String(),
 		"peer_address": channel.PeerAddress,
 		"state":        channel.State,
 		"my_balance":   channel.MyBalance.String(),
@@ -312,7 +312,7 @@ func (h *PerunHandler) ListChannels(c *gin.Context) {
 	result := make([]gin.H, 0, len(channels))
 	for _, ch := range channels {
 		result = append(result, gin.H{
-			"channel_id":   fmt.Sprintf("%x", ch.ID),
+			"channel_id":   perun.ChannelID(ch.ID).String(),
 			"peer_address": ch.PeerAddress,
 			"state":        ch.State,
 			"my_balance":   ch.MyBalance.String(),
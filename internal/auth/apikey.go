@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nervosnetwork/ckb-sdk-go/v2/crypto/blake2b"
+)
+
+const (
+	// APIKeyPrefix marks plaintext AirFi API keys.
+	APIKeyPrefix = "airfi_"
+	// DefaultAPIKeyRateLimit is the default number of requests per minute per key.
+	DefaultAPIKeyRateLimit = 100
+)
+
+// API key scopes. A read key may only call the dashboard's read-only
+// endpoints; an admin key may call every dashboard endpoint.
+const (
+	APIKeyScopeRead  = "read"
+	APIKeyScopeAdmin = "admin"
+)
+
+var (
+	// ErrInvalidAPIKey is returned when an API key is unknown or malformed.
+	ErrInvalidAPIKey = errors.New("invalid API key")
+	// ErrInvalidScope is returned when generating a key with an unknown scope.
+	ErrInvalidScope = errors.New("invalid API key scope")
+)
+
+// APIKey is a stored API key. Only the hash of the plaintext key is kept.
+type APIKey struct {
+	ID         string
+	KeyHash    string
+	Label      string
+	Scopes     []string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+}
+
+// HasScope reports whether the key grants scope. The admin scope grants
+// every scope.
+func (k *APIKey) HasScope(scope string) bool {
+	for _, granted := range k.Scopes {
+		if granted == scope || granted == APIKeyScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyStore persists API key records. GetAPIKeyByHash returns
+// sql.ErrNoRows for an unknown hash.
+type APIKeyStore interface {
+	CreateAPIKey(k *APIKey) error
+	GetAPIKeyByHash(keyHash string) (*APIKey, error)
+	TouchAPIKey(id string) error
+}
+
+// APIKeyService generates and validates API keys.
+type APIKeyService struct {
+	store   APIKeyStore
+	limiter *RateLimiter
+}
+
+// NewAPIKeyService creates an API key service allowing requestsPerMinute per key.
+func NewAPIKeyService(store APIKeyStore, requestsPerMinute int) *APIKeyService {
+	if requestsPerMinute <= 0 {
+		requestsPerMinute = DefaultAPIKeyRateLimit
+	}
+	return &APIKeyService{
+		store:   store,
+		limiter: NewRateLimiter(requestsPerMinute, time.Minute),
+	}
+}
+
+// HashAPIKey returns the hex BLAKE2b-256 hash of a plaintext key.
+func HashAPIKey(key string) string {
+	return hex.EncodeToString(blake2b.Blake256([]byte(key)))
+}
+
+// Generate creates and stores a new API key with the given scopes, or the
+// admin scope when none are given. The plaintext key is returned once and
+// never stored.
+func (s *APIKeyService) Generate(label string, scopes []string) (string, *APIKey, error) {
+	if len(scopes) == 0 {
+		scopes = []string{APIKeyScopeAdmin}
+	}
+	for _, scope := range scopes {
+		if scope != APIKeyScopeRead && scope != APIKeyScopeAdmin {
+			return "", nil, fmt.Errorf("%w: %q", ErrInvalidScope, scope)
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	plaintext := APIKeyPrefix + hex.EncodeToString(secret)
+
+	idBytes := make([]byte, 8)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", nil, fmt.Errorf("failed to generate key id: %w", err)
+	}
+
+	key := &APIKey{
+		ID:        hex.EncodeToString(idBytes),
+		KeyHash:   HashAPIKey(plaintext),
+		Label:     label,
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	}
+	if err := s.store.CreateAPIKey(key); err != nil {
+		return "", nil, fmt.Errorf("failed to store key: %w", err)
+	}
+	return plaintext, key, nil
+}
+
+// Validate looks up a plaintext key and records its use.
+func (s *APIKeyService) Validate(key string) (*APIKey, error) {
+	if !strings.HasPrefix(key, APIKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	apiKey, err := s.store.GetAPIKeyByHash(HashAPIKey(key))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, fmt.Errorf("failed to look up key: %w", err)
+	}
+
+	if err := s.store.TouchAPIKey(apiKey.ID); err != nil {
+		return nil, fmt.Errorf("failed to record key use: %w", err)
+	}
+	return apiKey, nil
+}
+
+// Allow reports whether the key may make another request in the current window.
+func (s *APIKeyService) Allow(keyID string) bool {
+	return s.limiter.Allow(keyID)
+}
+
+// RateLimiter is a fixed-window request counter keyed by caller.
+type RateLimiter struct {
+	limit   int
+	window  time.Duration
+	windows map[string]*rateWindow
+	mu      sync.Mutex
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// NewRateLimiter creates a limiter allowing limit requests per window.
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		limit:   limit,
+		window:  window,
+		windows: make(map[string]*rateWindow),
+	}
+}
+
+// Allow records a request for key and reports whether it is within the limit.
+func (r *RateLimiter) Allow(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	w, ok := r.windows[key]
+	if !ok || now.Sub(w.start) >= r.window {
+		r.windows[key] = &rateWindow{start: now, count: 1}
+		return true
+	}
+	if w.count >= r.limit {
+		return false
+	}
+	w.count++
+	return true
+}
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type memoryKeyStore struct {
+	keys map[string]*APIKey
+}
+
+func (m *memoryKeyStore) CreateAPIKey(k *APIKey) error {
+	m.keys[k.KeyHash] = k
+	return nil
+}
+
+func (m *memoryKeyStore) GetAPIKeyByHash(keyHash string) (*APIKey, error) {
+	k, ok := m.keys[keyHash]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return k, nil
+}
+
+func (m *memoryKeyStore) TouchAPIKey(id string) error {
+	return nil
+}
+
+func TestAPIKeyService_GenerateAndValidate(t *testing.T) {
+	store := &memoryKeyStore{keys: make(map[string]*APIKey)}
+	svc := NewAPIKeyService(store, 0)
+
+	plaintext, key, err := svc.Generate("monitoring", []string{"admin"})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if !strings.HasPrefix(plaintext, APIKeyPrefix) {
+		t.Errorf("Key should start with %s", APIKeyPrefix)
+	}
+	if key.KeyHash == plaintext || strings.Contains(key.KeyHash, plaintext) {
+		t.Error("Plaintext key must not be stored")
+	}
+
+	validated, err := svc.Validate(plaintext)
+	if err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if validated.ID != key.ID {
+		t.Errorf("ID mismatch: expected %s, got %s", key.ID, validated.ID)
+	}
+}
+
+func TestAPIKeyService_ValidateInvalid(t *testing.T) {
+	store := &memoryKeyStore{keys: make(map[string]*APIKey)}
+	svc := NewAPIKeyService(store, 0)
+
+	for _, key := range []string{"", "not-a-key", APIKeyPrefix + "unknown"} {
+		if _, err := svc.Validate(key); err != ErrInvalidAPIKey {
+			t.Errorf("Validate(%q): expected ErrInvalidAPIKey, got %v", key, err)
+		}
+	}
+}
+
+func TestAPIKeyService_Scopes(t *testing.T) {
+	store := &memoryKeyStore{keys: make(map[string]*APIKey)}
+	svc := NewAPIKeyService(store, 0)
+
+	_, admin, err := svc.Generate("ops", nil)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if !admin.HasScope(APIKeyScopeAdmin) || !admin.HasScope(APIKeyScopeRead) {
+		t.Errorf("Expected a key without scopes to get admin, got %v", admin.Scopes)
+	}
+
+	_, read, err := svc.Generate("monitoring", []string{APIKeyScopeRead})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if !read.HasScope(APIKeyScopeRead) || read.HasScope(APIKeyScopeAdmin) {
+		t.Errorf("Expected a read-only key, got %v", read.Scopes)
+	}
+
+	if _, _, err := svc.Generate("ops", []string{"read", "write"}); !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("Expected ErrInvalidScope, got %v", err)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(3, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		if !limiter.Allow("key-1") {
+			t.Fatalf("Request %d should be allowed", i+1)
+		}
+	}
+	if limiter.Allow("key-1") {
+		t.Error("Request over limit should be rejected")
+	}
+	if !limiter.Allow("key-2") {
+		t.Error("Other keys should have their own limit")
+	}
+}
+
+func TestRateLimiter_WindowReset(t *testing.T) {
+	limiter := NewRateLimiter(1, 10*time.Millisecond)
+
+	limiter.Allow("key-1")
+	if limiter.Allow("key-1") {
+		t.Error("Second request should be rejected")
+	}
+
+	time.Sleep(15 * time.Millisecond)
+	if !limiter.Allow("key-1") {
+		t.Error("Request after window reset should be allowed")
+	}
+}
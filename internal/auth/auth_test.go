@@ -1,10 +1,14 @@
 package auth
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestGenerateKeyPair(t *testing.T) {
@@ -69,7 +73,7 @@ func TestJWTService_GenerateAndValidate(t *testing.T) {
 	kp, _ := GenerateKeyPair()
 	svc := NewJWTService(kp, "test-issuer")
 
-	token, err := svc.GenerateToken("sess-1", "chan-1", "AA:BB:CC:DD:EE:FF", "192.168.1.1", 1*time.Hour)
+	token, _, err := svc.GenerateToken("sess-1", "chan-1", "AA:BB:CC:DD:EE:FF", "192.168.1.1", 1*time.Hour)
 	if err != nil {
 		t.Fatalf("GenerateToken failed: %v", err)
 	}
@@ -100,12 +104,60 @@ func TestJWTService_InvalidToken(t *testing.T) {
 	}
 }
 
+func TestJWTService_ParseUnverified(t *testing.T) {
+	kp, _ := GenerateKeyPair()
+	svc := NewJWTService(kp, "test-issuer")
+
+	token, _, _ := svc.GenerateToken("sess-1", "chan-1", "", "", 1*time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+
+	if _, err := svc.ValidateToken(token); err == nil {
+		t.Fatal("Expected ValidateToken to reject expired token")
+	}
+
+	claims, err := svc.ParseUnverified(token)
+	if err != nil {
+		t.Fatalf("ParseUnverified failed: %v", err)
+	}
+	if claims.SessionID != "sess-1" {
+		t.Errorf("Expected session ID sess-1, got %s", claims.SessionID)
+	}
+	if claims.ExpiresAt == nil || time.Now().Before(claims.ExpiresAt.Time) {
+		t.Error("Expected expiry in the past")
+	}
+
+	if _, err := svc.ParseUnverified("invalid-token"); err == nil {
+		t.Error("Expected error for malformed token")
+	}
+}
+
+func TestLogRejectedToken(t *testing.T) {
+	kp, _ := GenerateKeyPair()
+	svc := NewJWTService(kp, "test-issuer")
+	token, _, _ := svc.GenerateToken("sess-1", "chan-1", "", "", time.Minute)
+
+	core, logs := observer.New(zap.WarnLevel)
+	LogRejectedToken(zap.New(core), token, errors.New("revoked"))
+	LogRejectedToken(zap.New(core), "not-a-token", errors.New("malformed"))
+
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 log entries, got %d", len(entries))
+	}
+	if got := entries[0].ContextMap()["session_id"]; got != "sess-1" {
+		t.Errorf("Expected the claimed session logged, got %v", got)
+	}
+	if _, ok := entries[1].ContextMap()["session_id"]; ok {
+		t.Error("Expected no session for a token that doesn't decode")
+	}
+}
+
 func TestJWTService_IsExpired(t *testing.T) {
 	kp, _ := GenerateKeyPair()
 	svc := NewJWTService(kp, "test-issuer")
 
 	// Short-lived token
-	token, _ := svc.GenerateToken("sess-1", "chan-1", "", "", 1*time.Millisecond)
+	token, _, _ := svc.GenerateToken("sess-1", "chan-1", "", "", 1*time.Millisecond)
 	time.Sleep(10 * time.Millisecond)
 
 	if !svc.IsExpired(token) {
@@ -113,7 +165,7 @@ func TestJWTService_IsExpired(t *testing.T) {
 	}
 
 	// Long-lived token
-	token2, _ := svc.GenerateToken("sess-2", "chan-2", "", "", 1*time.Hour)
+	token2, _, _ := svc.GenerateToken("sess-2", "chan-2", "", "", 1*time.Hour)
 	if svc.IsExpired(token2) {
 		t.Error("Token should not be expired")
 	}
@@ -123,7 +175,7 @@ func TestJWTService_GetRemainingTime(t *testing.T) {
 	kp, _ := GenerateKeyPair()
 	svc := NewJWTService(kp, "test-issuer")
 
-	token, _ := svc.GenerateToken("sess-1", "chan-1", "", "", 1*time.Hour)
+	token, _, _ := svc.GenerateToken("sess-1", "chan-1", "", "", 1*time.Hour)
 
 	remaining, err := svc.GetRemainingTime(token)
 	if err != nil {
@@ -135,23 +187,35 @@ func TestJWTService_GetRemainingTime(t *testing.T) {
 	}
 }
 
-func TestJWTService_RefreshToken(t *testing.T) {
+func TestJWTService_GenerateRefreshToken(t *testing.T) {
 	kp, _ := GenerateKeyPair()
 	svc := NewJWTService(kp, "test-issuer")
 
-	token, _ := svc.GenerateToken("sess-1", "chan-1", "AA:BB:CC:DD:EE:FF", "192.168.1.1", 30*time.Minute)
+	refresh, err := svc.GenerateRefreshToken("sess-1", time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateRefreshToken failed: %v", err)
+	}
+	access, _, _ := svc.GenerateToken("sess-1", "chan-1", "", "", AccessTokenTTL)
 
-	refreshed, err := svc.RefreshToken(token, 30*time.Minute)
+	claims, err := svc.ValidateRefreshToken(refresh)
 	if err != nil {
-		t.Fatalf("RefreshToken failed: %v", err)
+		t.Fatalf("ValidateRefreshToken failed: %v", err)
+	}
+	if claims.SessionID != "sess-1" || claims.Type != TokenTypeRefresh {
+		t.Errorf("Unexpected refresh claims: %+v", claims)
 	}
 
-	claims, _ := svc.ValidateToken(refreshed)
-	if claims.SessionID != "sess-1" {
-		t.Error("Session ID not preserved")
+	if _, err := svc.ValidateAccessToken(refresh); !errors.Is(err, ErrWrongTokenType) {
+		t.Errorf("Refresh token used for access: expected ErrWrongTokenType, got %v", err)
 	}
-	if claims.MACAddress != "AA:BB:CC:DD:EE:FF" {
-		t.Error("MAC address not preserved")
+	if _, err := svc.ValidateRefreshToken(access); !errors.Is(err, ErrWrongTokenType) {
+		t.Errorf("Access token used for refresh: expected ErrWrongTokenType, got %v", err)
+	}
+	if _, err := svc.ValidateAccessToken(access); err != nil {
+		t.Errorf("ValidateAccessToken failed: %v", err)
+	}
+	if _, err := svc.GenerateRefreshToken("sess-1", 0); err == nil {
+		t.Error("Expected error for zero ttl")
 	}
 }
 
@@ -162,7 +226,7 @@ func TestJWTService_DifferentKeys(t *testing.T) {
 	svc1 := NewJWTService(kp1, "test")
 	svc2 := NewJWTService(kp2, "test")
 
-	token, _ := svc1.GenerateToken("sess-1", "chan-1", "", "", 1*time.Hour)
+	token, _, _ := svc1.GenerateToken("sess-1", "chan-1", "", "", 1*time.Hour)
 
 	_, err := svc2.ValidateToken(token)
 	if err == nil {
@@ -174,7 +238,7 @@ func TestNewJWTServiceFromKeys(t *testing.T) {
 	kp, _ := GenerateKeyPair()
 	svc := NewJWTServiceFromKeys(kp.PrivateKey, kp.PublicKey, "test")
 
-	token, err := svc.GenerateToken("sess-1", "chan-1", "", "", 1*time.Hour)
+	token, _, err := svc.GenerateToken("sess-1", "chan-1", "", "", 1*time.Hour)
 	if err != nil {
 		t.Fatalf("GenerateToken failed: %v", err)
 	}
@@ -184,3 +248,142 @@ func TestNewJWTServiceFromKeys(t *testing.T) {
 		t.Fatalf("ValidateToken failed: %v", err)
 	}
 }
+
+func TestJWTService_RotateKey(t *testing.T) {
+	kp1, _ := GenerateKeyPair()
+	kp2, _ := GenerateKeyPair()
+
+	oldSvc := NewJWTService(kp1, "test")
+	oldToken, _, _ := oldSvc.GenerateToken("sess-1", "chan-1", "", "", 1*time.Hour)
+
+	newSvc, err := oldSvc.RotateKey(kp2.PrivateKey, time.Hour)
+	if err != nil {
+		t.Fatalf("RotateKey failed: %v", err)
+	}
+	newToken, _, _ := newSvc.GenerateToken("sess-2", "chan-2", "", "", 1*time.Hour)
+
+	if _, err := newSvc.ValidateToken(oldToken); err != nil {
+		t.Errorf("old token should be accepted during overlap: %v", err)
+	}
+	if _, err := newSvc.ValidateToken(newToken); err != nil {
+		t.Errorf("new token rejected: %v", err)
+	}
+	if _, err := oldSvc.ValidateToken(oldToken); err != nil {
+		t.Errorf("old service should keep working: %v", err)
+	}
+
+	newSvc.DeprecateOldKey()
+	if _, err := newSvc.ValidateToken(oldToken); err == nil {
+		t.Error("old token should be rejected after DeprecateOldKey")
+	}
+	if _, err := newSvc.ValidateToken(newToken); err != nil {
+		t.Errorf("new token rejected after DeprecateOldKey: %v", err)
+	}
+}
+
+func TestJWTService_RotateKey_SharesRevocations(t *testing.T) {
+	kp1, _ := GenerateKeyPair()
+	kp2, _ := GenerateKeyPair()
+
+	oldSvc := NewJWTService(kp1, "test")
+	token, _ := oldSvc.GenerateShortLivedToken("sess-1", ScopeSettle, time.Minute)
+	claims, _ := oldSvc.ValidateToken(token)
+
+	newSvc, err := oldSvc.RotateKey(kp2.PrivateKey, time.Hour)
+	if err != nil {
+		t.Fatalf("RotateKey failed: %v", err)
+	}
+
+	// Revoked through the old service after the rotation
+	if !oldSvc.Consume(claims) {
+		t.Fatal("Expected first Consume to succeed")
+	}
+	if newSvc.Consume(claims) {
+		t.Error("Expected token consumed on the old service to stay consumed on the new one")
+	}
+	if _, err := newSvc.ValidateToken(token); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Expected ErrTokenRevoked, got %v", err)
+	}
+}
+
+func TestJWTService_RotateKey_OverlapExpires(t *testing.T) {
+	kp1, _ := GenerateKeyPair()
+	kp2, _ := GenerateKeyPair()
+
+	oldSvc := NewJWTService(kp1, "test")
+	oldToken, _, _ := oldSvc.GenerateToken("sess-1", "chan-1", "", "", 1*time.Hour)
+
+	newSvc, err := oldSvc.RotateKey(kp2.PrivateKey, 0)
+	if err != nil {
+		t.Fatalf("RotateKey failed: %v", err)
+	}
+	if _, err := newSvc.ValidateToken(oldToken); err == nil {
+		t.Error("old token should be rejected once the overlap has passed")
+	}
+}
+
+func TestJWTService_GenerateShortLivedToken(t *testing.T) {
+	kp, _ := GenerateKeyPair()
+	svc := NewJWTService(kp, "test-issuer")
+
+	token, err := svc.GenerateShortLivedToken("session-123", ScopeExtend, time.Minute)
+	if err != nil {
+		t.Fatalf("GenerateShortLivedToken failed: %v", err)
+	}
+
+	claims, err := svc.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("ValidateToken failed: %v", err)
+	}
+	if claims.Scope != ScopeExtend {
+		t.Errorf("Scope: expected %s, got %s", ScopeExtend, claims.Scope)
+	}
+	if claims.SessionID != "session-123" {
+		t.Errorf("SessionID: expected session-123, got %s", claims.SessionID)
+	}
+	if claims.ID == "" {
+		t.Error("Expected token ID to be set")
+	}
+
+	if _, err := svc.GenerateShortLivedToken("session-123", "admin", time.Minute); err == nil {
+		t.Error("Expected error for invalid scope")
+	}
+	if _, err := svc.GenerateShortLivedToken("session-123", ScopeRead, 0); err == nil {
+		t.Error("Expected error for zero ttl")
+	}
+}
+
+func TestJWTService_Revoke(t *testing.T) {
+	kp, _ := GenerateKeyPair()
+	svc := NewJWTService(kp, "test-issuer")
+
+	token, _ := svc.GenerateShortLivedToken("session-123", ScopeSettle, time.Minute)
+	claims, _ := svc.ValidateToken(token)
+
+	svc.Revoke(claims)
+
+	if !svc.IsRevoked(claims.ID) {
+		t.Error("Expected token to be revoked")
+	}
+	if _, err := svc.ValidateToken(token); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Expected ErrTokenRevoked, got %v", err)
+	}
+}
+
+func TestJWTService_Consume(t *testing.T) {
+	kp, _ := GenerateKeyPair()
+	svc := NewJWTService(kp, "test-issuer")
+
+	token, _ := svc.GenerateShortLivedToken("session-123", ScopeExtend, time.Minute)
+	claims, _ := svc.ValidateToken(token)
+
+	if !svc.Consume(claims) {
+		t.Fatal("Expected first Consume to succeed")
+	}
+	if svc.Consume(claims) {
+		t.Error("Expected second Consume to fail")
+	}
+	if svc.Consume(&Claims{}) {
+		t.Error("Expected Consume without token ID to fail")
+	}
+}
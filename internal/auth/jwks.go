@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+)
+
+// p256CoordSize is the byte length of a P-256 coordinate in a JWK.
+const p256CoordSize = 32
+
+// JWK is the public part of an ES256 signing key in JSON Web Key form.
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+}
+
+// JWKSet is a JSON Web Key Set document.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// encodeCoord returns the fixed-size base64url encoding of a curve coordinate.
+func encodeCoord(pub *ecdsa.PublicKey, x bool) string {
+	buf := make([]byte, p256CoordSize)
+	if x {
+		pub.X.FillBytes(buf)
+	} else {
+		pub.Y.FillBytes(buf)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// KeyID returns the RFC 7638 thumbprint of pub, used as the "kid" header
+// of tokens it signs.
+func KeyID(pub *ecdsa.PublicKey) string {
+	// Members in lexicographic order with no whitespace, as RFC 7638 requires
+	canonical := `{"crv":"P-256","kty":"EC","x":"` + encodeCoord(pub, true) + `","y":"` + encodeCoord(pub, false) + `"}`
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// NewJWK converts pub to a JWK.
+func NewJWK(pub *ecdsa.PublicKey) JWK {
+	return JWK{
+		Kty: "EC",
+		Crv: "P-256",
+		X:   encodeCoord(pub, true),
+		Y:   encodeCoord(pub, false),
+		Kid: KeyID(pub),
+		Use: "sig",
+		Alg: "ES256",
+	}
+}
+
+// JWKS returns every key ValidateToken currently accepts, current key first,
+// so verifiers keep working through a key rotation.
+func (s *JWTService) JWKS() JWKSet {
+	pubs := s.publicKeys()
+	set := JWKSet{Keys: make([]JWK, 0, len(pubs))}
+	for _, pub := range pubs {
+		set.Keys = append(set.Keys, NewJWK(pub))
+	}
+	return set
+}
+
+// JWKSHandler serves the key set at /.well-known/jwks.json. It allows any
+// origin so captive portals and router firmware can verify tokens offline.
+func (s *JWTService) JWKSHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, OPTIONS")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		json.NewEncoder(w).Encode(s.JWKS())
+	}
+}
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestJWKSHandler(t *testing.T) {
+	kp, _ := GenerateKeyPair()
+	svc := NewJWTService(kp, "test")
+
+	rec := httptest.NewRecorder()
+	svc.JWKSHandler()(rec, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("expected CORS origin *, got %q", got)
+	}
+
+	var set jose.JSONWebKeySet
+	if err := json.Unmarshal(rec.Body.Bytes(), &set); err != nil {
+		t.Fatalf("failed to parse JWKS: %v", err)
+	}
+	if len(set.Keys) != 1 {
+		t.Fatalf("expected 1 key, got %d", len(set.Keys))
+	}
+
+	key := set.Keys[0]
+	pub, ok := key.Key.(*ecdsa.PublicKey)
+	if !ok {
+		t.Fatalf("expected *ecdsa.PublicKey, got %T", key.Key)
+	}
+	if !pub.Equal(kp.PublicKey) {
+		t.Error("JWK does not reconstruct the signing public key")
+	}
+
+	thumb, err := key.Thumbprint(crypto.SHA256)
+	if err != nil {
+		t.Fatalf("Thumbprint failed: %v", err)
+	}
+	if want := base64.RawURLEncoding.EncodeToString(thumb); key.KeyID != want {
+		t.Errorf("expected kid %q to be the RFC 7638 thumbprint %q", key.KeyID, want)
+	}
+
+	token, _, _ := svc.GenerateToken("session-1", "channel-1", "", "", time.Hour)
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+	if err != nil {
+		t.Fatalf("ParseUnverified failed: %v", err)
+	}
+	if kid := parsed.Header["kid"]; kid != key.KeyID {
+		t.Errorf("expected token kid %q, got %v", key.KeyID, kid)
+	}
+}
+
+func TestJWKSHandler_Options(t *testing.T) {
+	kp, _ := GenerateKeyPair()
+	svc := NewJWTService(kp, "test")
+
+	rec := httptest.NewRecorder()
+	svc.JWKSHandler()(rec, httptest.NewRequest(http.MethodOptions, "/.well-known/jwks.json", nil))
+
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Methods"); got == "" {
+		t.Error("expected Access-Control-Allow-Methods header")
+	}
+}
+
+func TestJWKS_IncludesRotatedKeys(t *testing.T) {
+	kp, _ := GenerateKeyPair()
+	svc := NewJWTService(kp, "test")
+	newKP, _ := GenerateKeyPair()
+
+	rotated, err := svc.RotateKey(newKP.PrivateKey, time.Hour)
+	if err != nil {
+		t.Fatalf("RotateKey failed: %v", err)
+	}
+
+	keys := rotated.JWKS().Keys
+	if len(keys) != 2 {
+		t.Fatalf("expected 2 keys during overlap, got %d", len(keys))
+	}
+	if keys[0].Kid != KeyID(newKP.PublicKey) || keys[1].Kid != KeyID(kp.PublicKey) {
+		t.Error("expected current key first, then the rotated-out key")
+	}
+
+	rotated.DeprecateOldKey()
+	if n := len(rotated.JWKS().Keys); n != 1 {
+		t.Errorf("expected 1 key after deprecation, got %d", n)
+	}
+}
@@ -2,56 +2,168 @@ package auth
 
 import (
 	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
 )
 
+// Token scopes for short-lived operation tokens.
+// Full session tokens carry no scope.
+const (
+	ScopeRead   = "read"
+	ScopeExtend = "extend"
+	ScopeSettle = "settle"
+)
+
+// Token types. Access tokens grant WiFi access and session operations;
+// refresh tokens only obtain new access tokens.
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+// AccessTokenTTL is the longest an access token issued alongside a refresh
+// token stays valid.
+const AccessTokenTTL = 15 * time.Minute
+
+// ErrTokenRevoked is returned when validating a token that has been revoked.
+var ErrTokenRevoked = errors.New("token revoked")
+
+// ErrWrongTokenType is returned when a token is used where another type
+// is required, e.g. a refresh token presented for access.
+var ErrWrongTokenType = errors.New("wrong token type")
+
 // Claims represents the JWT claims for WiFi access.
 type Claims struct {
 	SessionID  string `json:"session_id"`
 	ChannelID  string `json:"channel_id"`
 	MACAddress string `json:"mac_address,omitempty"`
 	IPAddress  string `json:"ip_address,omitempty"`
+	Scope      string `json:"scope,omitempty"`
+	Type       string `json:"type,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// IsAccess reports whether the claims belong to an access token. Tokens
+// issued before token types were introduced carry none and are access
+// tokens.
+func (c *Claims) IsAccess() bool {
+	return c.Type == TokenTypeAccess || c.Type == ""
+}
+
 // JWTService handles JWT generation and validation.
 type JWTService struct {
 	privateKey *ecdsa.PrivateKey
-	publicKey  *ecdsa.PublicKey
 	issuer     string
+	revoked    *revocationList // shared with services from RotateKey
+
+	// trustedPublicKeys[0] matches privateKey; the rest are keys replaced
+	// by RotateKey that are still accepted until oldKeysExpireAt.
+	trustedPublicKeys []*ecdsa.PublicKey
+	oldKeysExpireAt   time.Time
+	keysMu            sync.RWMutex
+}
+
+// revocationList holds revoked token IDs until the tokens expire.
+type revocationList struct {
+	mu     sync.Mutex
+	tokens map[string]time.Time // token ID -> expiry
+}
+
+// ValidScope reports whether scope is a known operation scope.
+func ValidScope(scope string) bool {
+	switch scope {
+	case ScopeRead, ScopeExtend, ScopeSettle:
+		return true
+	default:
+		return false
+	}
 }
 
 // NewJWTService creates a new JWT service with the given key pair.
 func NewJWTService(keyPair *KeyPair, issuer string) *JWTService {
-	return &JWTService{
-		privateKey: keyPair.PrivateKey,
-		publicKey:  keyPair.PublicKey,
-		issuer:     issuer,
-	}
+	return NewJWTServiceFromKeys(keyPair.PrivateKey, keyPair.PublicKey, issuer)
 }
 
 // NewJWTServiceFromKeys creates a JWT service from separate keys.
 func NewJWTServiceFromKeys(privateKey *ecdsa.PrivateKey, publicKey *ecdsa.PublicKey, issuer string) *JWTService {
 	return &JWTService{
-		privateKey: privateKey,
-		publicKey:  publicKey,
-		issuer:     issuer,
+		privateKey:        privateKey,
+		trustedPublicKeys: []*ecdsa.PublicKey{publicKey},
+		issuer:            issuer,
+		revoked:           &revocationList{tokens: make(map[string]time.Time)},
 	}
 }
 
-// GenerateToken creates a signed JWT for a session.
-func (s *JWTService) GenerateToken(sessionID, channelID, macAddress, ipAddress string, duration time.Duration) (string, error) {
-	now := time.Now()
+// RotateKey returns a service that signs with newPrivKey. Tokens signed by
+// this service's key are still accepted by the new one for overlapDuration;
+// this service itself is unchanged and keeps working until it is discarded.
+func (s *JWTService) RotateKey(newPrivKey *ecdsa.PrivateKey, overlapDuration time.Duration) (*JWTService, error) {
+	if newPrivKey == nil {
+		return nil, fmt.Errorf("new private key is required")
+	}
+	if overlapDuration < 0 {
+		return nil, fmt.Errorf("overlap duration must not be negative")
+	}
+
+	s.keysMu.RLock()
+	trusted := make([]*ecdsa.PublicKey, 0, len(s.trustedPublicKeys)+1)
+	trusted = append(trusted, &newPrivKey.PublicKey)
+	trusted = append(trusted, s.trustedPublicKeys...)
+	s.keysMu.RUnlock()
+
+	// Both services share revocations, so a token used with either stays
+	// unusable with the other
+	return &JWTService{
+		privateKey:        newPrivKey,
+		trustedPublicKeys: trusted,
+		oldKeysExpireAt:   time.Now().Add(overlapDuration),
+		issuer:            s.issuer,
+		revoked:           s.revoked,
+	}, nil
+}
+
+// DeprecateOldKey stops accepting tokens signed by keys replaced in RotateKey.
+func (s *JWTService) DeprecateOldKey() {
+	s.keysMu.Lock()
+	defer s.keysMu.Unlock()
+	s.trustedPublicKeys = s.trustedPublicKeys[:1]
+}
+
+// publicKeys returns the keys ValidateToken should try, current key first.
+// Old keys are dropped once their overlap window has passed.
+func (s *JWTService) publicKeys() []*ecdsa.PublicKey {
+	s.keysMu.RLock()
+	defer s.keysMu.RUnlock()
+	if len(s.trustedPublicKeys) > 1 && time.Now().After(s.oldKeysExpireAt) {
+		return s.trustedPublicKeys[:1]
+	}
+	return s.trustedPublicKeys
+}
 
+// GenerateToken creates a signed JWT for a session and returns it with its
+// unique token ID, which sessions record to look up the token later.
+func (s *JWTService) GenerateToken(sessionID, channelID, macAddress, ipAddress string, duration time.Duration) (string, string, error) {
+	id, err := newTokenID()
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
 	claims := &Claims{
 		SessionID:  sessionID,
 		ChannelID:  channelID,
 		MACAddress: macAddress,
 		IPAddress:  ipAddress,
+		Type:       TokenTypeAccess,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        id,
 			Issuer:    s.issuer,
 			Subject:   sessionID,
 			IssuedAt:  jwt.NewNumericDate(now),
@@ -60,24 +172,157 @@ func (s *JWTService) GenerateToken(sessionID, channelID, macAddress, ipAddress s
 		},
 	}
 
+	token, err := s.sign(claims)
+	if err != nil {
+		return "", "", err
+	}
+	return token, id, nil
+}
+
+// GenerateShortLivedToken creates a single-use token limited to one scope.
+// The token carries a unique ID so it can be revoked after use.
+func (s *JWTService) GenerateShortLivedToken(sessionID, scope string, ttl time.Duration) (string, error) {
+	if !ValidScope(scope) {
+		return "", fmt.Errorf("invalid scope: %s", scope)
+	}
+	if ttl <= 0 {
+		return "", fmt.Errorf("ttl must be positive")
+	}
+
+	id, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := &Claims{
+		SessionID: sessionID,
+		Scope:     scope,
+		Type:      TokenTypeAccess,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        id,
+			Issuer:    s.issuer,
+			Subject:   sessionID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+
+	return s.sign(claims)
+}
+
+// GenerateRefreshToken creates a long-lived token that can only be
+// exchanged for new access tokens of the session, typically valid for the
+// rest of the session.
+func (s *JWTService) GenerateRefreshToken(sessionID string, ttl time.Duration) (string, error) {
+	if ttl <= 0 {
+		return "", fmt.Errorf("ttl must be positive")
+	}
+	id, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := &Claims{
+		SessionID: sessionID,
+		Type:      TokenTypeRefresh,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        id,
+			Issuer:    s.issuer,
+			Subject:   sessionID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+	return s.sign(claims)
+}
+
+// sign signs claims with the current key.
+func (s *JWTService) sign(claims *Claims) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = KeyID(&s.privateKey.PublicKey)
 
 	signedToken, err := token.SignedString(s.privateKey)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign token: %w", err)
 	}
-
 	return signedToken, nil
 }
 
-// ValidateToken verifies a JWT and returns the claims.
+// newTokenID returns a random hex token ID for the jti claim.
+func newTokenID() (string, error) {
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", fmt.Errorf("failed to generate token id: %w", err)
+	}
+	return hex.EncodeToString(idBytes), nil
+}
+
+// Revoke adds the token to the revocation list until it expires.
+// Tokens without an ID cannot be revoked.
+func (s *JWTService) Revoke(claims *Claims) {
+	s.Consume(claims)
+}
+
+// Consume revokes a token and reports whether this call revoked it. Of
+// several concurrent requests presenting the same single-use token, only
+// one sees true. Tokens without an ID cannot be revoked and report false.
+func (s *JWTService) Consume(claims *Claims) bool {
+	if claims == nil || claims.ID == "" {
+		return false
+	}
+
+	expiry := time.Now()
+	if claims.ExpiresAt != nil {
+		expiry = claims.ExpiresAt.Time
+	}
+
+	s.revoked.mu.Lock()
+	defer s.revoked.mu.Unlock()
+
+	// Drop entries for tokens that have expired anyway
+	now := time.Now()
+	for id, exp := range s.revoked.tokens {
+		if now.After(exp) {
+			delete(s.revoked.tokens, id)
+		}
+	}
+	if _, ok := s.revoked.tokens[claims.ID]; ok {
+		return false
+	}
+	s.revoked.tokens[claims.ID] = expiry
+	return true
+}
+
+// IsRevoked checks if a token ID is on the revocation list.
+func (s *JWTService) IsRevoked(tokenID string) bool {
+	if tokenID == "" {
+		return false
+	}
+	s.revoked.mu.Lock()
+	defer s.revoked.mu.Unlock()
+	_, ok := s.revoked.tokens[tokenID]
+	return ok
+}
+
+// ValidateToken verifies a JWT against each trusted key and returns the claims.
 func (s *JWTService) ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodECDSA); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	var token *jwt.Token
+	var err error
+	for _, publicKey := range s.publicKeys() {
+		token, err = jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodECDSA); !ok {
+				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+			}
+			return publicKey, nil
+		})
+		if !errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+			break
 		}
-		return s.publicKey, nil
-	})
+	}
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse token: %w", err)
@@ -88,21 +333,66 @@ func (s *JWTService) ValidateToken(tokenString string) (*Claims, error) {
 		return nil, fmt.Errorf("invalid token claims")
 	}
 
+	if s.IsRevoked(claims.ID) {
+		return nil, ErrTokenRevoked
+	}
+
 	return claims, nil
 }
 
-// RefreshToken creates a new token with extended expiration.
-func (s *JWTService) RefreshToken(tokenString string, additionalDuration time.Duration) (string, error) {
+// ValidateAccessToken validates a token and rejects refresh tokens with
+// ErrWrongTokenType.
+func (s *JWTService) ValidateAccessToken(tokenString string) (*Claims, error) {
 	claims, err := s.ValidateToken(tokenString)
 	if err != nil {
-		return "", fmt.Errorf("failed to validate existing token: %w", err)
+		return nil, err
+	}
+	if !claims.IsAccess() {
+		return nil, fmt.Errorf("%w: %s", ErrWrongTokenType, claims.Type)
 	}
+	return claims, nil
+}
 
-	// Calculate new expiration
-	currentExpiry := claims.ExpiresAt.Time
-	newExpiry := currentExpiry.Add(additionalDuration)
+// ValidateRefreshToken validates a token and rejects anything but refresh
+// tokens with ErrWrongTokenType.
+func (s *JWTService) ValidateRefreshToken(tokenString string) (*Claims, error) {
+	claims, err := s.ValidateToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Type != TokenTypeRefresh {
+		return nil, fmt.Errorf("%w: expected refresh token", ErrWrongTokenType)
+	}
+	return claims, nil
+}
+
+// ParseUnverified decodes a token's claims without checking its signature
+// or expiry. The result must not be trusted; it is only for logging which
+// session a rejected token claimed to belong to.
+func (s *JWTService) ParseUnverified(tokenString string) (*Claims, error) {
+	return parseUnverified(tokenString)
+}
 
-	return s.GenerateToken(claims.SessionID, claims.ChannelID, claims.MACAddress, claims.IPAddress, time.Until(newExpiry))
+func parseUnverified(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, claims); err != nil {
+		return nil, fmt.Errorf("failed to decode token: %w", err)
+	}
+	return claims, nil
+}
+
+// LogRejectedToken logs why a token was rejected and which session it
+// claimed, if it decodes. The claims are unverified and only used for
+// debugging.
+func LogRejectedToken(logger *zap.Logger, tokenString string, err error) {
+	fields := []zap.Field{zap.Error(err)}
+	if claims, decodeErr := parseUnverified(tokenString); decodeErr == nil {
+		fields = append(fields, zap.String("session_id", claims.SessionID))
+		if claims.ExpiresAt != nil {
+			fields = append(fields, zap.Time("expires_at", claims.ExpiresAt.Time))
+		}
+	}
+	logger.Warn("rejected token", fields...)
 }
 
 // IsExpired checks if a token is expired.
@@ -0,0 +1,221 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nervosnetwork/ckb-sdk-go/v2/crypto/blake2b"
+)
+
+const (
+	// DefaultBackupCodeCount is how many recovery codes GenerateBackupCodes
+	// creates when asked for none.
+	DefaultBackupCodeCount = 10
+	// MaxBackupCodeCount caps the number of recovery codes per generation.
+	MaxBackupCodeCount = 50
+
+	// totpPeriod is the RFC 6238 time step used by authenticator apps.
+	totpPeriod = 30 * time.Second
+	// totpDigits is the length of a TOTP code.
+	totpDigits = 6
+	// totpSkew is how many time steps before and after now are accepted,
+	// allowing for clock drift between the server and the authenticator.
+	totpSkew = 1
+	// backupCodeHalf is the number of hex characters on each side of the
+	// dash in a backup code.
+	backupCodeHalf = 6
+	// totpSecretBytes is the length of generated TOTP secrets, the 160 bits
+	// RFC 4226 recommends for HMAC-SHA1.
+	totpSecretBytes = 20
+)
+
+// BackupCode is a stored 2FA recovery code hash. UsedAt is nil until the
+// code is used.
+type BackupCode struct {
+	CodeHash  string
+	CreatedAt time.Time
+	UsedAt    *time.Time
+}
+
+// BackupCodeStore persists hashed 2FA recovery codes.
+type BackupCodeStore interface {
+	ReplaceBackupCodes(codeHashes []string) error
+	ListBackupCodes() ([]*BackupCode, error)
+	UseBackupCode(codeHash string) (bool, error)
+}
+
+// TOTPService validates time-based one-time passwords (RFC 6238) and the
+// recovery codes that stand in for them when the authenticator is lost.
+type TOTPService struct {
+	store BackupCodeStore
+	now   func() time.Time
+
+	// lastStep is the latest time step Consume accepted a code for; codes
+	// from it or earlier steps are replays.
+	lastStep int64
+	stepMu   sync.Mutex
+}
+
+// NewTOTPService creates a TOTP service keeping backup codes in store.
+func NewTOTPService(store BackupCodeStore) *TOTPService {
+	return &TOTPService{store: store, now: time.Now}
+}
+
+// Validate reports whether code is the TOTP code for the base32 secret in
+// the current time step or one step either side of it.
+func (s *TOTPService) Validate(secret, code string) bool {
+	_, ok := s.matchStep(secret, code)
+	return ok
+}
+
+// Consume is Validate for logins: a code is accepted only if its time step
+// is later than that of the last code Consume accepted, so an observed code
+// can't be replayed within its validity window.
+func (s *TOTPService) Consume(secret, code string) bool {
+	step, ok := s.matchStep(secret, code)
+	if !ok {
+		return false
+	}
+
+	s.stepMu.Lock()
+	defer s.stepMu.Unlock()
+	if step <= s.lastStep {
+		return false
+	}
+	s.lastStep = step
+	return true
+}
+
+// matchStep returns the time step code is valid for, trying the current
+// step and totpSkew steps either side of it.
+func (s *TOTPService) matchStep(secret, code string) (int64, bool) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+	step := s.now().Unix() / int64(totpPeriod/time.Second)
+	for offset := int64(-totpSkew); offset <= totpSkew; offset++ {
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, uint64(step+offset))), []byte(code)) == 1 {
+			return step + offset, true
+		}
+	}
+	return 0, false
+}
+
+// GenerateTOTPSecret returns a new random TOTP secret, base32 encoded
+// without padding as authenticator apps expect.
+func GenerateTOTPSecret() (string, error) {
+	key := make([]byte, totpSecretBytes)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate TOTP secret: %w", err)
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(key), nil
+}
+
+// TOTPProvisioningURI returns the otpauth:// URI that authenticator apps
+// scan to add secret for account.
+func TOTPProvisioningURI(secret, issuer, account string) string {
+	params := url.Values{
+		"secret": {secret},
+		"issuer": {issuer},
+		"digits": {fmt.Sprintf("%d", totpDigits)},
+		"period": {fmt.Sprintf("%d", int(totpPeriod/time.Second))},
+	}
+	label := url.PathEscape(issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// GenerateBackupCodes replaces all backup codes with n new ones of the form
+// XXXXXX-XXXXXX (12 random hex characters). n defaults to
+// DefaultBackupCodeCount. The plaintext codes are returned once and only
+// their hashes are stored.
+func (s *TOTPService) GenerateBackupCodes(n int) ([]string, error) {
+	if n <= 0 {
+		n = DefaultBackupCodeCount
+	}
+	if n > MaxBackupCodeCount {
+		return nil, fmt.Errorf("cannot generate more than %d backup codes", MaxBackupCodeCount)
+	}
+
+	codes := make([]string, n)
+	hashes := make([]string, n)
+	for i := range codes {
+		raw := make([]byte, backupCodeHalf)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, fmt.Errorf("failed to generate backup code: %w", err)
+		}
+		encoded := strings.ToUpper(hex.EncodeToString(raw))
+		codes[i] = encoded[:backupCodeHalf] + "-" + encoded[backupCodeHalf:]
+		hashes[i] = HashBackupCode(codes[i])
+	}
+
+	if err := s.store.ReplaceBackupCodes(hashes); err != nil {
+		return nil, fmt.Errorf("failed to store backup codes: %w", err)
+	}
+	return codes, nil
+}
+
+// ListBackupCodes returns the stored backup code hashes and when each was used.
+func (s *TOTPService) ListBackupCodes() ([]*BackupCode, error) {
+	return s.store.ListBackupCodes()
+}
+
+// ValidateOrBackupCode accepts either a TOTP code for secret that hasn't
+// been used yet (see Consume) or an unused backup code, which is marked used
+// immediately. isBackupCode reports which kind was accepted. A backup code
+// that fails to be recorded as used is rejected.
+func (s *TOTPService) ValidateOrBackupCode(secret, code string) (ok bool, isBackupCode bool) {
+	code = strings.TrimSpace(code)
+	if len(code) == totpDigits {
+		return s.Consume(secret, code), false
+	}
+	if len(normalizeBackupCode(code)) != 2*backupCodeHalf {
+		return false, false
+	}
+	used, err := s.store.UseBackupCode(HashBackupCode(code))
+	if err != nil || !used {
+		return false, false
+	}
+	return true, true
+}
+
+// HashBackupCode returns the hex BLAKE2b-256 hash of a backup code. Case,
+// dashes and spaces are ignored so codes can be typed loosely.
+func HashBackupCode(code string) string {
+	return hex.EncodeToString(blake2b.Blake256([]byte(normalizeBackupCode(code))))
+}
+
+// normalizeBackupCode upper-cases a backup code and strips separators.
+func normalizeBackupCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// decodeTOTPSecret decodes a base32 secret as shown by authenticator apps,
+// with or without padding and spaces.
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	return base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "="))
+}
+
+// totpCode computes the HOTP value (RFC 4226) of key for counter.
+func totpCode(key []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the RFC 6238 SHA1 test key "12345678901234567890" in base32.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+type memoryCodeStore struct {
+	mu    sync.Mutex
+	codes []*BackupCode
+}
+
+func (m *memoryCodeStore) ReplaceBackupCodes(codeHashes []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.codes = nil
+	for _, hash := range codeHashes {
+		m.codes = append(m.codes, &BackupCode{CodeHash: hash, CreatedAt: time.Now()})
+	}
+	return nil
+}
+
+func (m *memoryCodeStore) ListBackupCodes() ([]*BackupCode, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*BackupCode(nil), m.codes...), nil
+}
+
+func (m *memoryCodeStore) UseBackupCode(codeHash string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, code := range m.codes {
+		if code.CodeHash == codeHash && code.UsedAt == nil {
+			now := time.Now()
+			code.UsedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func newTestTOTPService(t *testing.T) *TOTPService {
+	t.Helper()
+	return NewTOTPService(&memoryCodeStore{})
+}
+
+func TestTOTPService_Validate(t *testing.T) {
+	svc := NewTOTPService(nil)
+
+	// RFC 6238 appendix B vectors, truncated to six digits
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		svc.now = func() time.Time { return time.Unix(tt.unix, 0) }
+		if !svc.Validate(rfc6238Secret, tt.code) {
+			t.Errorf("Validate(%q) at %d should succeed", tt.code, tt.unix)
+		}
+	}
+
+	// One step of clock drift is tolerated, two are not
+	svc.now = func() time.Time { return time.Unix(59+30, 0) }
+	if !svc.Validate(rfc6238Secret, "287082") {
+		t.Error("Code from the previous step should be accepted")
+	}
+	svc.now = func() time.Time { return time.Unix(59+60, 0) }
+	if svc.Validate(rfc6238Secret, "287082") {
+		t.Error("Code from two steps ago should be rejected")
+	}
+
+	if svc.Validate("not base32!", "287082") {
+		t.Error("Invalid secret should be rejected")
+	}
+}
+
+func TestTOTPService_GenerateBackupCodes(t *testing.T) {
+	svc := newTestTOTPService(t)
+
+	codes, err := svc.GenerateBackupCodes(0)
+	if err != nil {
+		t.Fatalf("GenerateBackupCodes failed: %v", err)
+	}
+	if len(codes) != DefaultBackupCodeCount {
+		t.Fatalf("Expected %d codes, got %d", DefaultBackupCodeCount, len(codes))
+	}
+	format := regexp.MustCompile(`^[0-9A-F]{6}-[0-9A-F]{6}$`)
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		if !format.MatchString(code) {
+			t.Errorf("Code %q does not match XXXXXX-XXXXXX", code)
+		}
+		if seen[code] {
+			t.Errorf("Duplicate code %q", code)
+		}
+		seen[code] = true
+	}
+
+	stored, err := svc.ListBackupCodes()
+	if err != nil {
+		t.Fatalf("ListBackupCodes failed: %v", err)
+	}
+	if len(stored) != len(codes) {
+		t.Fatalf("Expected %d stored codes, got %d", len(codes), len(stored))
+	}
+	for _, code := range stored {
+		if seen[code.CodeHash] {
+			t.Error("Plaintext code should not be stored")
+		}
+	}
+
+	// Regenerating invalidates the previous codes
+	if _, err := svc.GenerateBackupCodes(3); err != nil {
+		t.Fatalf("GenerateBackupCodes failed: %v", err)
+	}
+	if ok, _ := svc.ValidateOrBackupCode(rfc6238Secret, codes[0]); ok {
+		t.Error("Code from a previous generation should be rejected")
+	}
+
+	if _, err := svc.GenerateBackupCodes(MaxBackupCodeCount + 1); err == nil {
+		t.Error("Expected error above MaxBackupCodeCount")
+	}
+}
+
+func TestTOTPService_BackupCodeWorksOnce(t *testing.T) {
+	svc := newTestTOTPService(t)
+	svc.now = func() time.Time { return time.Unix(59, 0) }
+
+	codes, err := svc.GenerateBackupCodes(2)
+	if err != nil {
+		t.Fatalf("GenerateBackupCodes failed: %v", err)
+	}
+
+	// Typed in lower case without the dash
+	ok, isBackup := svc.ValidateOrBackupCode(rfc6238Secret, strings.ToLower(strings.ReplaceAll(codes[0], "-", "")))
+	if !ok || !isBackup {
+		t.Fatalf("First use of backup code: ok=%v isBackup=%v, want true true", ok, isBackup)
+	}
+	if ok, _ := svc.ValidateOrBackupCode(rfc6238Secret, codes[0]); ok {
+		t.Error("Backup code should not work a second time")
+	}
+
+	// The other code is unaffected and TOTP codes still work
+	if ok, isBackup := svc.ValidateOrBackupCode(rfc6238Secret, codes[1]); !ok || !isBackup {
+		t.Error("Unused backup code should be accepted")
+	}
+	if ok, isBackup := svc.ValidateOrBackupCode(rfc6238Secret, "287082"); !ok || isBackup {
+		t.Errorf("TOTP code: ok=%v isBackup=%v, want true false", ok, isBackup)
+	}
+	if ok, _ := svc.ValidateOrBackupCode(rfc6238Secret, "ABCDEF-123456"); ok {
+		t.Error("Unknown backup code should be rejected")
+	}
+
+	stored, err := svc.ListBackupCodes()
+	if err != nil {
+		t.Fatalf("ListBackupCodes failed: %v", err)
+	}
+	for _, code := range stored {
+		if code.UsedAt == nil {
+			t.Errorf("Backup code %s should be marked used", code.CodeHash)
+		}
+	}
+}
+
+func TestTOTPService_ConsumeRejectsReplay(t *testing.T) {
+	svc := NewTOTPService(nil)
+	svc.now = func() time.Time { return time.Unix(59, 0) }
+
+	if !svc.Consume(rfc6238Secret, "287082") {
+		t.Fatal("First use of the code should be accepted")
+	}
+	if svc.Consume(rfc6238Secret, "287082") {
+		t.Error("Replayed code should be rejected")
+	}
+
+	// Still within its window one step later, but already used
+	svc.now = func() time.Time { return time.Unix(59+30, 0) }
+	if svc.Consume(rfc6238Secret, "287082") {
+		t.Error("Code from an already used step should be rejected")
+	}
+	if !svc.Validate(rfc6238Secret, "287082") {
+		t.Error("Validate should not track used codes")
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret failed: %v", err)
+	}
+	key, err := decodeTOTPSecret(secret)
+	if err != nil || len(key) != totpSecretBytes {
+		t.Fatalf("Expected a %d-byte base32 secret, got %q (%v)", totpSecretBytes, secret, err)
+	}
+
+	uri := TOTPProvisioningURI(secret, "AirFi", "host")
+	if !strings.HasPrefix(uri, "otpauth://totp/AirFi:host?") || !strings.Contains(uri, "secret="+secret) {
+		t.Errorf("Unexpected provisioning URI %q", uri)
+	}
+}
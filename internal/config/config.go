@@ -4,6 +4,9 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -19,6 +22,12 @@ type Config struct {
 	WiFi     WiFiConfig     `yaml:"wifi"`
 	Database DatabaseConfig `yaml:"database"`
 	OpenWrt  *OpenWrtConfig `yaml:"openwrt,omitempty"`
+	Webhooks WebhookConfig  `yaml:"webhooks"`
+	Session  SessionConfig  `yaml:"session"`
+
+	// set holds the dotted YAML paths written in a loaded config file or
+	// environment variable, e.g. "ckb.rpc_url".
+	set map[string]bool
 }
 
 // CKBConfig holds CKB network settings.
@@ -40,8 +49,31 @@ type PerunConfig struct {
 	FundingTimeout    time.Duration `yaml:"funding_timeout"`
 	SettlementTimeout time.Duration `yaml:"settlement_timeout"`
 	ChannelSetupCKB   int64         `yaml:"channel_setup_ckb"`
+
+	// RetryFundingOnTimeout re-proposes a timed-out channel once with
+	// double the funding timeout, unless its channel cell is already
+	// on-chain.
+	RetryFundingOnTimeout bool `yaml:"retry_funding_on_timeout"`
+
+	// CoopCloseTimeout is how long to wait for the guest to sign the final
+	// state before closing through an on-chain dispute instead.
+	CoopCloseTimeout time.Duration `yaml:"coop_close_timeout"`
+
+	// BalanceCacheTTL is how long the host's on-chain balance is reused
+	// before it is queried again.
+	BalanceCacheTTL time.Duration `yaml:"balance_cache_ttl"`
+
+	// StrictUpdateValidation rejects guest channel updates that raise the
+	// guest's balance, skip a version or change the channel total.
+	StrictUpdateValidation bool `yaml:"strict_update_validation"`
 }
 
+// Bounds for perun.funding_timeout.
+const (
+	MinFundingTimeout = 1 * time.Minute
+	MaxFundingTimeout = 30 * time.Minute
+)
+
 // AuthConfig holds authentication settings.
 type AuthConfig struct {
 	PrivateKeyPath string        `yaml:"private_key_path"`
@@ -54,6 +86,12 @@ type ServerConfig struct {
 	Host              string `yaml:"host"`
 	Port              int    `yaml:"port"`
 	DashboardPassword string `yaml:"dashboard_password"`
+
+	// ForceSettleOnShutdown settles every open channel when the server
+	// stops instead of leaving them to the challenge period.
+	ForceSettleOnShutdown bool `yaml:"force_settle_on_shutdown"`
+	// ShutdownTimeout bounds how long settling channels on shutdown may take.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
 }
 
 // WiFiConfig holds WiFi pricing settings.
@@ -61,11 +99,42 @@ type WiFiConfig struct {
 	RatePerHour    int64         `yaml:"rate_per_hour"`
 	MinSessionTime time.Duration `yaml:"min_session_time"`
 	MaxSessionTime time.Duration `yaml:"max_session_time"`
+	PricingTiers   []PricingTier `yaml:"pricing_tiers"`
+	WalletTTL      time.Duration `yaml:"wallet_ttl"` // unfunded guest wallets expire after this
+
+	// MaxConcurrentDevices caps how many devices the router lets through
+	// at once. New guest wallets are refused at the cap; 0 is unlimited.
+	MaxConcurrentDevices int `yaml:"max_concurrent_devices"`
+
+	// PaymentAsset is what guests pay in. Only "CKBytes" is accepted,
+	// since session amounts are CKB-denominated.
+	PaymentAsset string `yaml:"payment_asset"`
+	// Assets lists the Simple UDT tokens reported by the asset list.
+	Assets []SUDTAssetConfig `yaml:"assets"`
+	// PriceOracleURL returns a JSON object mapping asset names to their
+	// USD price; empty reports no exchange rates.
+	PriceOracleURL string `yaml:"price_oracle_url"`
+}
+
+// SUDTAssetConfig registers a Simple UDT token by its issuer's lock hash.
+type SUDTAssetConfig struct {
+	Name           string `yaml:"name"`
+	IssuerLockHash string `yaml:"issuer_lock_hash"`
+}
+
+// PricingTier overrides the hourly rate between two hours of the day.
+// A tier whose start_hour is after its end_hour wraps past midnight.
+type PricingTier struct {
+	StartHour   int   `yaml:"start_hour"`
+	EndHour     int   `yaml:"end_hour"`
+	RatePerHour int64 `yaml:"rate_per_hour"`
 }
 
 // DatabaseConfig holds database settings.
 type DatabaseConfig struct {
-	Path string `yaml:"path"`
+	Path             string `yaml:"path"`
+	CompactSchedule  string `yaml:"compact_schedule"`   // Cron expression for VACUUM/ANALYZE; empty disables
+	KeyEncryptionKey string `yaml:"key_encryption_key"` // Hex AES-256 key for guest wallet keys; empty stores them in plaintext
 }
 
 // OpenWrtConfig holds OpenWrt router settings.
@@ -74,8 +143,40 @@ type OpenWrtConfig struct {
 	Port        int    `yaml:"port"`
 	Username    string `yaml:"username"`
 	Password    string `yaml:"password"`
-	PrivateKey  string `yaml:"private_key"`
+	PrivateKey  string `yaml:"private_key"` // PEM-encoded key, or a path to one
 	AuthTimeout int    `yaml:"auth_timeout"`
+
+	KnownHostsPath string `yaml:"known_hosts_path"` // Host keys to verify; unset accepts any
+
+	PoolSize          int           `yaml:"pool_size"`
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval"`
+
+	Name            string              `yaml:"name"`             // Main router's name in the topology
+	SignalThreshold int                 `yaml:"signal_threshold"` // dBm
+	AccessPoints    []AccessPointConfig `yaml:"access_points"`
+}
+
+// AccessPointConfig holds the SSH target of an additional access point.
+// Unset credentials are taken from the main router.
+type AccessPointConfig struct {
+	Name            string `yaml:"name"`
+	Address         string `yaml:"address"`
+	Port            int    `yaml:"port"`
+	Username        string `yaml:"username"`
+	Password        string `yaml:"password"`
+	PrivateKey      string `yaml:"private_key"`
+	SignalThreshold int    `yaml:"signal_threshold"`
+}
+
+// SessionConfig holds session store settings.
+type SessionConfig struct {
+	PersistPath string `yaml:"persist_path"` // Session store snapshot file; empty disables persistence
+}
+
+// WebhookConfig holds outgoing event webhook settings.
+type WebhookConfig struct {
+	URLs   []string `yaml:"urls"`
+	Secret string   `yaml:"secret"` // HMAC key for the X-AirFi-Signature header
 }
 
 // DefaultConfig returns the default configuration.
@@ -91,6 +192,10 @@ func DefaultConfig() *Config {
 			FundingTimeout:    10 * time.Minute,
 			SettlementTimeout: 30 * time.Minute,
 			ChannelSetupCKB:   1000,
+			CoopCloseTimeout:  30 * time.Second,
+			BalanceCacheTTL:   10 * time.Second,
+
+			StrictUpdateValidation: true,
 		},
 		Auth: AuthConfig{
 			PrivateKeyPath: "./keys/private.pem",
@@ -101,14 +206,18 @@ func DefaultConfig() *Config {
 			Host:              "0.0.0.0",
 			Port:              8080,
 			DashboardPassword: "airfi2025",
+			ShutdownTimeout:   5 * time.Minute,
 		},
 		WiFi: WiFiConfig{
 			RatePerHour:    500,
 			MinSessionTime: 5 * time.Minute,
 			MaxSessionTime: 24 * time.Hour,
+			WalletTTL:      24 * time.Hour,
+			PaymentAsset:   "CKBytes",
 		},
 		Database: DatabaseConfig{
-			Path: "./airfi.db",
+			Path:            "./airfi.db",
+			CompactSchedule: "0 4 * * 0", // Sundays at 04:00
 		},
 	}
 }
@@ -118,40 +227,223 @@ func DefaultConfig() *Config {
 func Load(path string) (*Config, error) {
 	cfg := DefaultConfig()
 
+	if _, err := loadFile(path, cfg); err != nil {
+		return nil, err
+	}
+	cfg.ApplyDefaults()
+
+	// Apply environment variable overrides
+	cfg.applyEnvOverrides()
+	cfg.applyOpenWrtDefaults()
+
+	return cfg, nil
+}
+
+// LoadWithOverride reads the base config and deep-merges an environment-specific
+// override file (e.g. config.mainnet.yaml) on top of it. A missing override file
+// leaves the base config unchanged. Environment variables are applied last.
+func LoadWithOverride(basePath, overridePath string) (*Config, error) {
+	base := DefaultConfig()
+	if _, err := loadFile(basePath, base); err != nil {
+		return nil, err
+	}
+
+	override := &Config{}
+	found, err := loadFile(overridePath, override)
+	if err != nil {
+		return nil, fmt.Errorf("override %s: %w", overridePath, err)
+	}
+
+	cfg := base
+	if found {
+		cfg = MergeConfigs(base, override)
+	}
+	cfg.ApplyDefaults()
+
+	cfg.applyEnvOverrides()
+	cfg.applyOpenWrtDefaults()
+
+	return cfg, nil
+}
+
+// OverridePath returns the override file path for an environment,
+// e.g. ./config/config.yaml + "mainnet" -> ./config/config.mainnet.yaml.
+func OverridePath(basePath, env string) string {
+	ext := filepath.Ext(basePath)
+	return strings.TrimSuffix(basePath, ext) + "." + env + ext
+}
+
+// loadFile unmarshals a YAML file into cfg. It reports false if the file doesn't exist.
+func loadFile(path string, cfg *Config) (bool, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return cfg, nil
+			return false, nil
 		}
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+		return false, fmt.Errorf("failed to read config file: %w", err)
 	}
 
 	if err := yaml.Unmarshal(data, cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
+		return false, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
-	// Apply environment variable overrides
-	cfg.applyEnvOverrides()
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return false, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	cfg.markSet("", raw)
+	return true, nil
+}
 
-	return cfg, nil
+// markSet records every key path in a decoded YAML mapping as set.
+func (c *Config) markSet(prefix string, raw map[string]interface{}) {
+	for key, value := range raw {
+		path := joinPath(prefix, key)
+		c.markPath(path)
+		if nested, ok := value.(map[string]interface{}); ok {
+			c.markSet(path, nested)
+		}
+	}
+}
+
+// markPath records path and the sections containing it as set.
+func (c *Config) markPath(path string) {
+	if c.set == nil {
+		c.set = make(map[string]bool)
+	}
+	for {
+		c.set[path] = true
+		i := strings.LastIndex(path, ".")
+		if i < 0 {
+			return
+		}
+		path = path[:i]
+	}
+}
+
+// joinPath appends key to a dotted YAML path.
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// IsDefault reports whether field, a dotted YAML path such as
+// "ckb.rpc_url" or "perun", was left unset by every loaded config file and
+// environment variable. A section counts as set when any field in it is.
+func (c *Config) IsDefault(field string) bool {
+	return !c.set[field]
+}
+
+// ApplyDefaults fills every zero-value field that was not set explicitly
+// with its value from DefaultConfig. Fields written as zero in a config
+// file are kept, so an empty database.compact_schedule still disables
+// compaction.
+func (c *Config) ApplyDefaults() {
+	applyDefaults(reflect.ValueOf(c).Elem(), reflect.ValueOf(DefaultConfig()).Elem(), "", c.set)
+}
+
+// applyDefaults walks dst and def in step, tracking each field's YAML path.
+func applyDefaults(dst, def reflect.Value, path string, set map[string]bool) {
+	switch dst.Kind() {
+	case reflect.Struct:
+		t := dst.Type()
+		for i := 0; i < dst.NumField(); i++ {
+			name := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+			if !dst.Field(i).CanSet() || name == "" || name == "-" {
+				continue
+			}
+			applyDefaults(dst.Field(i), def.Field(i), joinPath(path, name), set)
+		}
+	case reflect.Ptr:
+		if dst.IsNil() || def.IsNil() {
+			return
+		}
+		applyDefaults(dst.Elem(), def.Elem(), path, set)
+	default:
+		if dst.IsZero() && !def.IsZero() && !set[path] {
+			dst.Set(def)
+		}
+	}
+}
+
+// MergeConfigs returns a copy of base with every non-zero field of override
+// applied on top. Nested structs are merged field by field. Neither input is modified.
+func MergeConfigs(base, override *Config) *Config {
+	merged := reflect.New(reflect.TypeOf(*base)).Elem()
+	mergeValue(merged, reflect.ValueOf(*base))
+	if override != nil {
+		mergeValue(merged, reflect.ValueOf(*override))
+	}
+	cfg := merged.Interface().(Config)
+	for _, src := range []*Config{base, override} {
+		if src == nil {
+			continue
+		}
+		for path := range src.set {
+			cfg.markPath(path)
+		}
+	}
+	return &cfg
+}
+
+// mergeValue copies non-zero values from src into dst, recursing into
+// structs and pointers so that pointed-to values are never shared.
+func mergeValue(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Struct:
+		for i := 0; i < src.NumField(); i++ {
+			if !dst.Field(i).CanSet() {
+				continue
+			}
+			mergeValue(dst.Field(i), src.Field(i))
+		}
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+		elem := reflect.New(src.Type().Elem())
+		if !dst.IsNil() {
+			mergeValue(elem.Elem(), dst.Elem())
+		}
+		mergeValue(elem.Elem(), src.Elem())
+		dst.Set(elem)
+	case reflect.Slice, reflect.Map:
+		if src.Len() > 0 {
+			dst.Set(src)
+		}
+	default:
+		if !src.IsZero() {
+			dst.Set(src)
+		}
+	}
 }
 
 // applyEnvOverrides applies environment variable overrides to the config.
 func (c *Config) applyEnvOverrides() {
 	if v := os.Getenv("HOST_PRIVATE_KEY"); v != "" {
 		c.CKB.PrivateKey = v
+		c.markPath("ckb.private_key")
 	}
 	if v := os.Getenv("DASHBOARD_PASSWORD"); v != "" {
 		c.Server.DashboardPassword = v
+		c.markPath("server.dashboard_password")
 	}
 	if v := os.Getenv("DB_PATH"); v != "" {
 		c.Database.Path = v
+		c.markPath("database.path")
+	}
+	if v := os.Getenv("DB_KEY_ENCRYPTION_KEY"); v != "" {
+		c.Database.KeyEncryptionKey = v
+		c.markPath("database.key_encryption_key")
 	}
 	if v := os.Getenv("PORT"); v != "" {
 		var port int
 		fmt.Sscanf(v, "%d", &port)
 		if port > 0 {
 			c.Server.Port = port
+			c.markPath("server.port")
 		}
 	}
 	if v := os.Getenv("CHANNEL_SETUP_CKB"); v != "" {
@@ -159,6 +451,7 @@ func (c *Config) applyEnvOverrides() {
 		fmt.Sscanf(v, "%d", &ckb)
 		if ckb > 0 {
 			c.Perun.ChannelSetupCKB = ckb
+			c.markPath("perun.channel_setup_ckb")
 		}
 	}
 	if v := os.Getenv("OPENWRT_ADDRESS"); v != "" {
@@ -166,6 +459,7 @@ func (c *Config) applyEnvOverrides() {
 			c.OpenWrt = &OpenWrtConfig{}
 		}
 		c.OpenWrt.Address = v
+		c.markPath("openwrt.address")
 	}
 	if v := os.Getenv("OPENWRT_PORT"); v != "" {
 		if c.OpenWrt == nil {
@@ -175,6 +469,7 @@ func (c *Config) applyEnvOverrides() {
 		fmt.Sscanf(v, "%d", &port)
 		if port > 0 {
 			c.OpenWrt.Port = port
+			c.markPath("openwrt.port")
 		}
 	}
 	if v := os.Getenv("OPENWRT_USERNAME"); v != "" {
@@ -182,18 +477,28 @@ func (c *Config) applyEnvOverrides() {
 			c.OpenWrt = &OpenWrtConfig{}
 		}
 		c.OpenWrt.Username = v
+		c.markPath("openwrt.username")
 	}
 	if v := os.Getenv("OPENWRT_PASSWORD"); v != "" {
 		if c.OpenWrt == nil {
 			c.OpenWrt = &OpenWrtConfig{}
 		}
 		c.OpenWrt.Password = v
+		c.markPath("openwrt.password")
 	}
 	if v := os.Getenv("OPENWRT_PRIVATE_KEY"); v != "" {
 		if c.OpenWrt == nil {
 			c.OpenWrt = &OpenWrtConfig{}
 		}
 		c.OpenWrt.PrivateKey = v
+		c.markPath("openwrt.private_key")
+	}
+	if v := os.Getenv("OPENWRT_KNOWN_HOSTS"); v != "" {
+		if c.OpenWrt == nil {
+			c.OpenWrt = &OpenWrtConfig{}
+		}
+		c.OpenWrt.KnownHostsPath = v
+		c.markPath("openwrt.known_hosts_path")
 	}
 	if v := os.Getenv("OPENWRT_AUTH_TIMEOUT"); v != "" {
 		if c.OpenWrt == nil {
@@ -202,6 +507,18 @@ func (c *Config) applyEnvOverrides() {
 		var timeout int
 		fmt.Sscanf(v, "%d", &timeout)
 		c.OpenWrt.AuthTimeout = timeout
+		c.markPath("openwrt.auth_timeout")
+	}
+}
+
+// DefaultOpenWrtUsername is the SSH user OpenWrt ships with.
+const DefaultOpenWrtUsername = "root"
+
+// applyOpenWrtDefaults fills unset router settings once the openwrt
+// section exists, which may only happen through environment variables.
+func (c *Config) applyOpenWrtDefaults() {
+	if c.OpenWrt != nil && c.OpenWrt.Username == "" {
+		c.OpenWrt.Username = DefaultOpenWrtUsername
 	}
 }
 
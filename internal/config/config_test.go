@@ -0,0 +1,307 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestMergeConfigs_NestedStruct(t *testing.T) {
+	base := DefaultConfig()
+	override := &Config{
+		CKB: CKBConfig{
+			Network: "mainnet",
+			RPCURL:  "https://mainnet.ckb.dev/rpc",
+		},
+	}
+
+	merged := MergeConfigs(base, override)
+
+	if merged.CKB.Network != "mainnet" {
+		t.Errorf("Network: expected mainnet, got %s", merged.CKB.Network)
+	}
+	if merged.CKB.RPCURL != "https://mainnet.ckb.dev/rpc" {
+		t.Errorf("RPCURL: expected mainnet URL, got %s", merged.CKB.RPCURL)
+	}
+	// Sibling field in the same nested struct is kept from base
+	if merged.CKB.IndexerURL != base.CKB.IndexerURL {
+		t.Errorf("IndexerURL: expected %s, got %s", base.CKB.IndexerURL, merged.CKB.IndexerURL)
+	}
+}
+
+func TestMergeConfigs_ZeroValuePassthrough(t *testing.T) {
+	base := DefaultConfig()
+	merged := MergeConfigs(base, &Config{})
+
+	if merged.Server.Port != base.Server.Port {
+		t.Errorf("Port: expected %d, got %d", base.Server.Port, merged.Server.Port)
+	}
+	if merged.WiFi.RatePerHour != base.WiFi.RatePerHour {
+		t.Errorf("RatePerHour: expected %d, got %d", base.WiFi.RatePerHour, merged.WiFi.RatePerHour)
+	}
+	if merged.Perun.FundingTimeout != base.Perun.FundingTimeout {
+		t.Errorf("FundingTimeout: expected %v, got %v", base.Perun.FundingTimeout, merged.Perun.FundingTimeout)
+	}
+}
+
+func TestMergeConfigs_PointerStruct(t *testing.T) {
+	base := DefaultConfig()
+	base.OpenWrt = &OpenWrtConfig{Address: "192.168.1.1", Port: 22, Username: "root"}
+	override := &Config{OpenWrt: &OpenWrtConfig{Address: "10.0.0.1"}}
+
+	merged := MergeConfigs(base, override)
+
+	if merged.OpenWrt.Address != "10.0.0.1" {
+		t.Errorf("Address: expected 10.0.0.1, got %s", merged.OpenWrt.Address)
+	}
+	if merged.OpenWrt.Username != "root" {
+		t.Errorf("Username: expected root, got %s", merged.OpenWrt.Username)
+	}
+	if base.OpenWrt.Address != "192.168.1.1" {
+		t.Error("MergeConfigs must not modify base")
+	}
+}
+
+func TestLoadWithOverride(t *testing.T) {
+	dir := t.TempDir()
+	basePath := writeConfigFile(t, dir, "config.yaml", `
+server:
+  port: 9000
+  dashboard_password: base-secret
+wifi:
+  rate_per_hour: 500
+  min_session_time: 10m
+`)
+	writeConfigFile(t, dir, "config.mainnet.yaml", `
+ckb:
+  network: mainnet
+wifi:
+  rate_per_hour: 800
+`)
+
+	cfg, err := LoadWithOverride(basePath, OverridePath(basePath, "mainnet"))
+	if err != nil {
+		t.Fatalf("LoadWithOverride failed: %v", err)
+	}
+
+	if cfg.CKB.Network != "mainnet" {
+		t.Errorf("Network: expected mainnet, got %s", cfg.CKB.Network)
+	}
+	if cfg.WiFi.RatePerHour != 800 {
+		t.Errorf("RatePerHour: expected 800, got %d", cfg.WiFi.RatePerHour)
+	}
+	if cfg.WiFi.MinSessionTime != 10*time.Minute {
+		t.Errorf("MinSessionTime: expected 10m from base, got %v", cfg.WiFi.MinSessionTime)
+	}
+	if cfg.Server.Port != 9000 {
+		t.Errorf("Port: expected 9000 from base, got %d", cfg.Server.Port)
+	}
+}
+
+func TestLoadWithOverride_MissingOverride(t *testing.T) {
+	dir := t.TempDir()
+	basePath := writeConfigFile(t, dir, "config.yaml", `
+server:
+  port: 9000
+`)
+
+	cfg, err := LoadWithOverride(basePath, OverridePath(basePath, "testnet"))
+	if err != nil {
+		t.Fatalf("LoadWithOverride failed: %v", err)
+	}
+	if cfg.Server.Port != 9000 {
+		t.Errorf("Port: expected 9000, got %d", cfg.Server.Port)
+	}
+}
+
+func TestLoadWithOverride_EnvPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	basePath := writeConfigFile(t, dir, "config.yaml", `
+server:
+  port: 9000
+`)
+	writeConfigFile(t, dir, "config.mainnet.yaml", `
+server:
+  port: 9100
+`)
+	t.Setenv("PORT", "9200")
+
+	cfg, err := LoadWithOverride(basePath, OverridePath(basePath, "mainnet"))
+	if err != nil {
+		t.Fatalf("LoadWithOverride failed: %v", err)
+	}
+	if cfg.Server.Port != 9200 {
+		t.Errorf("Port: expected env value 9200, got %d", cfg.Server.Port)
+	}
+}
+
+func TestLoad_PartialFileKeepsDefaults(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfigFile(t, dir, "config.yaml", `
+ckb:
+  rpc_url: http://localhost:8114
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	want := DefaultConfig()
+	want.CKB.RPCURL = "http://localhost:8114"
+	got := *cfg
+	got.set = nil
+	if !reflect.DeepEqual(&got, want) {
+		t.Errorf("Expected defaults apart from ckb.rpc_url\ngot:  %+v\nwant: %+v", got, *want)
+	}
+
+	for _, field := range []string{"ckb.rpc_url", "ckb"} {
+		if cfg.IsDefault(field) {
+			t.Errorf("IsDefault(%q): expected false", field)
+		}
+	}
+	for _, field := range []string{"ckb.network", "perun", "server.port"} {
+		if !cfg.IsDefault(field) {
+			t.Errorf("IsDefault(%q): expected true", field)
+		}
+	}
+}
+
+func TestApplyDefaults(t *testing.T) {
+	cfg := &Config{Server: ServerConfig{Port: 9000}}
+	cfg.ApplyDefaults()
+
+	want := DefaultConfig()
+	want.Server.Port = 9000
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("Expected zero fields filled from defaults\ngot:  %+v\nwant: %+v", *cfg, *want)
+	}
+}
+
+func TestApplyDefaults_ExplicitZeroKept(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfigFile(t, dir, "config.yaml", `
+database:
+  compact_schedule: ""
+perun:
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Database.CompactSchedule != "" || cfg.IsDefault("database.compact_schedule") {
+		t.Errorf("Explicitly empty compact_schedule should be kept, got %q", cfg.Database.CompactSchedule)
+	}
+	if cfg.Database.Path != DefaultConfig().Database.Path {
+		t.Errorf("Path: expected default, got %q", cfg.Database.Path)
+	}
+	if cfg.Perun.FundingTimeout != DefaultConfig().Perun.FundingTimeout {
+		t.Errorf("FundingTimeout: expected default for an empty section, got %v", cfg.Perun.FundingTimeout)
+	}
+}
+
+func TestIsDefault_EnvOverride(t *testing.T) {
+	t.Setenv("PORT", "9200")
+
+	cfg, err := Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.IsDefault("server.port") {
+		t.Error("IsDefault(server.port): expected false with PORT set")
+	}
+	if !cfg.IsDefault("server.host") {
+		t.Error("IsDefault(server.host): expected true")
+	}
+}
+
+func TestLoad_OpenWrtUsernameDefault(t *testing.T) {
+	t.Setenv("OPENWRT_ADDRESS", "192.168.1.1")
+
+	cfg, err := Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.OpenWrt == nil || cfg.OpenWrt.Username != "root" {
+		t.Errorf("Expected username root, got %+v", cfg.OpenWrt)
+	}
+
+	dir := t.TempDir()
+	path := writeConfigFile(t, dir, "config.yaml", "openwrt:\n  address: 10.0.0.1\n  username: admin\n")
+	cfg, err = Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.OpenWrt.Username != "admin" {
+		t.Errorf("Expected configured username admin, got %q", cfg.OpenWrt.Username)
+	}
+}
+
+func TestOverridePath(t *testing.T) {
+	got := OverridePath("./config/config.yaml", "mainnet")
+	if got != "./config/config.mainnet.yaml" {
+		t.Errorf("Expected ./config/config.mainnet.yaml, got %s", got)
+	}
+}
+
+func TestLoad_PricingTiers(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfigFile(t, dir, "config.yaml", `
+wifi:
+  rate_per_hour: 500
+  pricing_tiers:
+    - start_hour: 18
+      end_hour: 22
+      rate_per_hour: 800
+    - start_hour: 22
+      end_hour: 6
+      rate_per_hour: 200
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if len(cfg.WiFi.PricingTiers) != 2 {
+		t.Fatalf("PricingTiers: expected 2, got %d", len(cfg.WiFi.PricingTiers))
+	}
+	night := cfg.WiFi.PricingTiers[1]
+	if night.StartHour != 22 || night.EndHour != 6 || night.RatePerHour != 200 {
+		t.Errorf("unexpected night tier: %+v", night)
+	}
+}
+
+func TestValidate_FundingTimeout(t *testing.T) {
+	tests := []struct {
+		timeout time.Duration
+		wantErr bool
+	}{
+		{10 * time.Minute, false},
+		{MinFundingTimeout, false},
+		{MaxFundingTimeout, false},
+		{30 * time.Second, true},
+		{time.Hour, true},
+		{0, true},
+	}
+
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.Perun.FundingTimeout = tt.timeout
+		if err := cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("FundingTimeout %s: expected error %v, got %v", tt.timeout, tt.wantErr, err)
+		}
+	}
+}
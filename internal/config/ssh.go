@@ -0,0 +1,91 @@
+package config
+
+import (
+	"encoding/pem"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// LoadPrivateKey parses the PEM-encoded SSH private key in PrivateKey,
+// either inline or in the file it names. Only RSA, ECDSA and Ed25519 keys
+// are accepted.
+func (o *OpenWrtConfig) LoadPrivateKey() (ssh.Signer, error) {
+	return loadSSHPrivateKey(o.PrivateKey)
+}
+
+// AuthMethods returns the SSH authentication to use for the router: the
+// password if set, else the private key, else the keys of the SSH agent
+// at $SSH_AUTH_SOCK.
+func (o *OpenWrtConfig) AuthMethods() ([]ssh.AuthMethod, error) {
+	return sshAuthMethods(o.Password, o.PrivateKey)
+}
+
+// AuthMethods returns the SSH authentication to use for the access point,
+// chosen like OpenWrtConfig.AuthMethods. It returns nil if the access point
+// sets no credentials and so takes the main router's.
+func (a *AccessPointConfig) AuthMethods() ([]ssh.AuthMethod, error) {
+	if a.Password == "" && a.PrivateKey == "" {
+		return nil, nil
+	}
+	return sshAuthMethods(a.Password, a.PrivateKey)
+}
+
+// sshAuthMethods picks one SSH authentication method, preferring password
+// over private key over SSH agent.
+func sshAuthMethods(password, privateKeyPath string) ([]ssh.AuthMethod, error) {
+	if password != "" {
+		return []ssh.AuthMethod{ssh.Password(password)}, nil
+	}
+	if privateKeyPath != "" {
+		signer, err := loadSSHPrivateKey(privateKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		return []ssh.AuthMethod{ssh.PublicKeys(signer)}, nil
+	}
+
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return nil, fmt.Errorf("no SSH authentication: set a password or private key, or run an SSH agent")
+	}
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to SSH agent: %w", err)
+	}
+	// The connection stays open for the agent to sign each new SSH handshake
+	return []ssh.AuthMethod{ssh.PublicKeysCallback(agent.NewClient(conn).Signers)}, nil
+}
+
+// loadSSHPrivateKey parses a PEM-encoded private key. A value starting
+// with -----BEGIN is the key itself; anything else is a path to its file.
+func loadSSHPrivateKey(path string) (ssh.Signer, error) {
+	var data []byte
+	if strings.HasPrefix(strings.TrimSpace(path), "-----BEGIN") {
+		data = []byte(path)
+		path = "(inline)"
+	} else {
+		var err error
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read private key: %w", err)
+		}
+	}
+	if block, _ := pem.Decode(data); block == nil {
+		return nil, fmt.Errorf("private key %s is not PEM-encoded", path)
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %w", path, err)
+	}
+	switch t := signer.PublicKey().Type(); t {
+	case ssh.KeyAlgoRSA, ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521, ssh.KeyAlgoED25519:
+		return signer, nil
+	default:
+		return nil, fmt.Errorf("private key %s has unsupported type %s", path, t)
+	}
+}
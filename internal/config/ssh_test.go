@@ -0,0 +1,146 @@
+package config
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// writeSSHKey writes key as a PEM-encoded OpenSSH private key file.
+func writeSSHKey(t *testing.T, key crypto.PrivateKey) string {
+	t.Helper()
+	block, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "id_router")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	return path
+}
+
+func TestOpenWrtConfig_LoadPrivateKey(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	for name, key := range map[string]crypto.PrivateKey{"rsa": rsaKey, "ecdsa": ecKey} {
+		signer, err := (&OpenWrtConfig{PrivateKey: writeSSHKey(t, key)}).LoadPrivateKey()
+		if err != nil {
+			t.Errorf("%s: LoadPrivateKey failed: %v", name, err)
+			continue
+		}
+		want, _ := ssh.NewSignerFromKey(key)
+		if string(signer.PublicKey().Marshal()) != string(want.PublicKey().Marshal()) {
+			t.Errorf("%s: loaded a different key", name)
+		}
+	}
+
+	inline, _ := ssh.MarshalPrivateKey(ecKey, "")
+	signer, err := (&OpenWrtConfig{PrivateKey: string(pem.EncodeToMemory(inline))}).LoadPrivateKey()
+	if err != nil {
+		t.Fatalf("LoadPrivateKey with an inline key failed: %v", err)
+	}
+	if want, _ := ssh.NewSignerFromKey(ecKey); string(signer.PublicKey().Marshal()) != string(want.PublicKey().Marshal()) {
+		t.Error("inline: loaded a different key")
+	}
+
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "not-pem")
+	os.WriteFile(notPEM, []byte("ssh-rsa AAAA"), 0600)
+	badPEM := filepath.Join(dir, "bad-pem")
+	os.WriteFile(badPEM, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: []byte("garbage")}), 0600)
+
+	for _, tc := range []struct{ path, wantErr string }{
+		{filepath.Join(dir, "missing"), "failed to read"},
+		{notPEM, "not PEM-encoded"},
+		{badPEM, "failed to parse"},
+	} {
+		_, err := (&OpenWrtConfig{PrivateKey: tc.path}).LoadPrivateKey()
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("LoadPrivateKey(%s): expected error containing %q, got %v", filepath.Base(tc.path), tc.wantErr, err)
+		}
+	}
+}
+
+func TestOpenWrtConfig_AuthMethods(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	keyPath := writeSSHKey(t, ecKey)
+
+	// Password is preferred, so a broken key doesn't matter
+	methods, err := (&OpenWrtConfig{Password: "secret", PrivateKey: "/nonexistent"}).AuthMethods()
+	if err != nil || len(methods) != 1 {
+		t.Errorf("Expected password auth, got %d methods: %v", len(methods), err)
+	}
+
+	methods, err = (&OpenWrtConfig{PrivateKey: keyPath}).AuthMethods()
+	if err != nil || len(methods) != 1 {
+		t.Errorf("Expected key auth, got %d methods: %v", len(methods), err)
+	}
+	if _, err := (&OpenWrtConfig{PrivateKey: "/nonexistent"}).AuthMethods(); err == nil {
+		t.Error("Expected error for a missing key")
+	}
+
+	// Neither: fall back to the SSH agent
+	t.Setenv("SSH_AUTH_SOCK", "")
+	if _, err := (&OpenWrtConfig{}).AuthMethods(); err == nil {
+		t.Error("Expected error without credentials or SSH agent")
+	}
+
+	sock := filepath.Join(t.TempDir(), "agent.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer l.Close()
+	keyring := agent.NewKeyring()
+	keyring.Add(agent.AddedKey{PrivateKey: ecKey})
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go agent.ServeAgent(keyring, conn)
+		}
+	}()
+
+	t.Setenv("SSH_AUTH_SOCK", sock)
+	methods, err = (&OpenWrtConfig{}).AuthMethods()
+	if err != nil || len(methods) != 1 {
+		t.Errorf("Expected agent auth, got %d methods: %v", len(methods), err)
+	}
+
+	// Access points without credentials take the main router's
+	if methods, err := (&AccessPointConfig{}).AuthMethods(); err != nil || methods != nil {
+		t.Errorf("Expected no access point auth, got %d methods: %v", len(methods), err)
+	}
+}
+
+func TestValidate_OpenWrtSSHFiles(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	os.WriteFile(knownHosts, nil, 0600)
+
+	cfg := DefaultConfig()
+	cfg.OpenWrt = &OpenWrtConfig{
+		Address:        "192.168.1.1",
+		Username:       "root",
+		PrivateKey:     writeSSHKey(t, rsaKey),
+		KnownHostsPath: knownHosts,
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected valid config, got %v", err)
+	}
+}
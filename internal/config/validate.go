@@ -0,0 +1,162 @@
+package config
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/airfi/airfi-perun-nervous/internal/cron"
+)
+
+// MaxRatePerHour is the exclusive upper bound for wifi.rate_per_hour, in CKB.
+const MaxRatePerHour = 1000000
+
+// MaxSessionTime is the upper bound for wifi.max_session_time.
+const MaxSessionTime = 7 * 24 * time.Hour
+
+// ValidationError lists every invalid setting found in a configuration.
+type ValidationError struct {
+	ValidationErrors []string
+}
+
+// Error joins all validation messages.
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid config: %s", strings.Join(e.ValidationErrors, "; "))
+}
+
+// Validator collects validation failures so they can all be reported at once
+// instead of fixing a config one error per restart.
+type Validator struct {
+	errs []string
+}
+
+// Check records the formatted message when ok is false.
+func (v *Validator) Check(ok bool, format string, args ...interface{}) {
+	if !ok {
+		v.errs = append(v.errs, fmt.Sprintf(format, args...))
+	}
+}
+
+// Err returns a *ValidationError with the collected messages, or nil.
+func (v *Validator) Err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return &ValidationError{ValidationErrors: v.errs}
+}
+
+// Validate checks settings that would otherwise fail at runtime. All
+// problems are returned together in a *ValidationError.
+func (c *Config) Validate() error {
+	v := &Validator{}
+
+	v.Check(isValidURL(c.CKB.RPCURL), "ckb.rpc_url must be a valid http(s) or ws(s) URL, got %q", c.CKB.RPCURL)
+
+	v.Check(c.Perun.FundingTimeout >= MinFundingTimeout && c.Perun.FundingTimeout <= MaxFundingTimeout,
+		"perun.funding_timeout must be between %s and %s, got %s", MinFundingTimeout, MaxFundingTimeout, c.Perun.FundingTimeout)
+	v.Check(c.Perun.ChannelTimeout > c.Perun.FundingTimeout,
+		"perun.channel_timeout (%s) must be longer than perun.funding_timeout (%s)", c.Perun.ChannelTimeout, c.Perun.FundingTimeout)
+	v.Check(c.Perun.CoopCloseTimeout >= 0, "perun.coop_close_timeout must not be negative, got %s", c.Perun.CoopCloseTimeout)
+	v.Check(c.Perun.BalanceCacheTTL >= 0, "perun.balance_cache_ttl must not be negative, got %s", c.Perun.BalanceCacheTTL)
+
+	c.WiFi.check(v)
+
+	v.Check(c.Auth.PrivateKeyPath != "", "auth.private_key_path is required")
+	v.Check(c.Auth.PublicKeyPath != "", "auth.public_key_path is required")
+
+	v.Check(c.Server.Port >= 1 && c.Server.Port <= 65535, "server.port must be between 1 and 65535, got %d", c.Server.Port)
+	v.Check(c.Server.ShutdownTimeout >= 0, "server.shutdown_timeout must not be negative, got %s", c.Server.ShutdownTimeout)
+
+	if c.Database.CompactSchedule != "" {
+		_, err := cron.Parse(c.Database.CompactSchedule)
+		v.Check(err == nil, "database.compact_schedule must be a valid cron expression: %v", err)
+	}
+
+	if c.Database.KeyEncryptionKey != "" {
+		key, err := hex.DecodeString(c.Database.KeyEncryptionKey)
+		v.Check(err == nil && len(key) == 32, "database.key_encryption_key must be 32 bytes of hex")
+	}
+
+	if c.OpenWrt != nil {
+		v.Check(c.OpenWrt.Address != "", "openwrt.address is required when openwrt is configured")
+		v.Check(c.OpenWrt.Username != "", "openwrt.username is required when openwrt is configured")
+		if c.OpenWrt.PrivateKey != "" {
+			_, err := c.OpenWrt.LoadPrivateKey()
+			v.Check(err == nil, "openwrt.private_key must be a valid RSA or EC key: %v", err)
+		}
+		for i, ap := range c.OpenWrt.AccessPoints {
+			if ap.PrivateKey != "" {
+				_, err := loadSSHPrivateKey(ap.PrivateKey)
+				v.Check(err == nil, "openwrt.access_points[%d].private_key must be a valid RSA or EC key: %v", i, err)
+			}
+		}
+		if c.OpenWrt.KnownHostsPath != "" {
+			_, err := os.Stat(c.OpenWrt.KnownHostsPath)
+			v.Check(err == nil, "openwrt.known_hosts_path must be an existing file: %v", err)
+		}
+	}
+
+	return v.Err()
+}
+
+// Validate checks the pricing and session length settings on their own, for
+// callers that change them without reloading the whole config.
+func (w *WiFiConfig) Validate() error {
+	v := &Validator{}
+	w.check(v)
+	return v.Err()
+}
+
+// check records every invalid wifi setting in v.
+func (w *WiFiConfig) check(v *Validator) {
+	v.Check(w.RatePerHour > 0 && w.RatePerHour < MaxRatePerHour,
+		"wifi.rate_per_hour must be between 1 and %d CKB, got %d", MaxRatePerHour-1, w.RatePerHour)
+	v.Check(w.MinSessionTime >= time.Minute,
+		"wifi.min_session_time must be at least 1m, got %s", w.MinSessionTime)
+	v.Check(w.MaxSessionTime > w.MinSessionTime,
+		"wifi.max_session_time (%s) must be longer than wifi.min_session_time (%s)", w.MaxSessionTime, w.MinSessionTime)
+	v.Check(w.MaxSessionTime <= MaxSessionTime,
+		"wifi.max_session_time must be at most %s, got %s", MaxSessionTime, w.MaxSessionTime)
+	v.Check(w.WalletTTL >= 0, "wifi.wallet_ttl must not be negative, got %s", w.WalletTTL)
+	v.Check(w.MaxConcurrentDevices >= 0, "wifi.max_concurrent_devices must not be negative, got %d", w.MaxConcurrentDevices)
+
+	names := map[string]bool{"CKBytes": true}
+	for i, a := range w.Assets {
+		v.Check(a.Name != "", "wifi.assets[%d].name is required", i)
+		v.Check(a.Name == "" || !names[a.Name], "wifi.assets[%d].name %q is already registered", i, a.Name)
+		v.Check(isValidHash(a.IssuerLockHash),
+			"wifi.assets[%d].issuer_lock_hash must be a 0x-prefixed 32-byte hex hash, got %q", i, a.IssuerLockHash)
+		names[a.Name] = true
+	}
+	// Session rates and channel amounts are CKB-denominated, so guests
+	// can only pay in CKBytes; other assets are listed but not accepted.
+	v.Check(w.PaymentAsset == "" || w.PaymentAsset == "CKBytes",
+		"wifi.payment_asset must be CKBytes, got %q", w.PaymentAsset)
+	v.Check(w.PriceOracleURL == "" || isValidURL(w.PriceOracleURL),
+		"wifi.price_oracle_url must be a valid http(s) URL, got %q", w.PriceOracleURL)
+}
+
+// isValidHash reports whether raw is a 0x-prefixed 32-byte hex string.
+func isValidHash(raw string) bool {
+	if !strings.HasPrefix(raw, "0x") {
+		return false
+	}
+	b, err := hex.DecodeString(raw[2:])
+	return err == nil && len(b) == 32
+}
+
+// isValidURL reports whether raw is an absolute http(s) or ws(s) URL.
+func isValidURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	switch u.Scheme {
+	case "http", "https", "ws", "wss":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,211 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate_Default(t *testing.T) {
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Fatalf("Default config should be valid, got %v", err)
+	}
+}
+
+func TestValidate_Rules(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr string // substring of the single expected message; empty for valid
+	}{
+		// ckb.rpc_url
+		{"rpc url https", func(c *Config) { c.CKB.RPCURL = "https://testnet.ckb.dev/rpc" }, ""},
+		{"rpc url http with port", func(c *Config) { c.CKB.RPCURL = "http://127.0.0.1:8114" }, ""},
+		{"rpc url websocket", func(c *Config) { c.CKB.RPCURL = "wss://testnet.ckb.dev/ws" }, ""},
+		{"rpc url empty", func(c *Config) { c.CKB.RPCURL = "" }, "ckb.rpc_url"},
+		{"rpc url no scheme", func(c *Config) { c.CKB.RPCURL = "testnet.ckb.dev/rpc" }, "ckb.rpc_url"},
+		{"rpc url bad scheme", func(c *Config) { c.CKB.RPCURL = "ftp://testnet.ckb.dev" }, "ckb.rpc_url"},
+		{"rpc url unparsable", func(c *Config) { c.CKB.RPCURL = "http://[::1" }, "ckb.rpc_url"},
+
+		// perun.funding_timeout bounds are covered by TestValidate_FundingTimeout
+		{"funding timeout too long", func(c *Config) {
+			c.Perun.FundingTimeout = time.Hour
+			c.Perun.ChannelTimeout = 2 * time.Hour
+		}, "perun.funding_timeout"},
+
+		// perun.channel_timeout
+		{"channel timeout longer", func(c *Config) { c.Perun.ChannelTimeout = 11 * time.Minute }, ""},
+		{"channel timeout equal", func(c *Config) { c.Perun.ChannelTimeout = 10 * time.Minute }, "perun.channel_timeout"},
+		{"channel timeout shorter", func(c *Config) { c.Perun.ChannelTimeout = 5 * time.Minute }, "perun.channel_timeout"},
+
+		// wifi.rate_per_hour
+		{"rate minimum", func(c *Config) { c.WiFi.RatePerHour = 1 }, ""},
+		{"rate maximum", func(c *Config) { c.WiFi.RatePerHour = MaxRatePerHour - 1 }, ""},
+		{"rate zero", func(c *Config) { c.WiFi.RatePerHour = 0 }, "wifi.rate_per_hour"},
+		{"rate negative", func(c *Config) { c.WiFi.RatePerHour = -5 }, "wifi.rate_per_hour"},
+		{"rate at limit", func(c *Config) { c.WiFi.RatePerHour = MaxRatePerHour }, "wifi.rate_per_hour"},
+
+		// wifi.min_session_time
+		{"min session one minute", func(c *Config) { c.WiFi.MinSessionTime = time.Minute }, ""},
+		{"min session too short", func(c *Config) { c.WiFi.MinSessionTime = 59 * time.Second }, "wifi.min_session_time"},
+		{"min session zero", func(c *Config) { c.WiFi.MinSessionTime = 0 }, "wifi.min_session_time"},
+
+		// wifi.max_session_time
+		{"max session one week", func(c *Config) { c.WiFi.MaxSessionTime = MaxSessionTime }, ""},
+		{"max session over a week", func(c *Config) { c.WiFi.MaxSessionTime = MaxSessionTime + time.Hour }, "wifi.max_session_time"},
+		{"max session equal to min", func(c *Config) { c.WiFi.MaxSessionTime = c.WiFi.MinSessionTime }, "wifi.max_session_time"},
+
+		// wifi.max_concurrent_devices
+		{"max devices unlimited", func(c *Config) { c.WiFi.MaxConcurrentDevices = 0 }, ""},
+		{"max devices set", func(c *Config) { c.WiFi.MaxConcurrentDevices = 20 }, ""},
+		{"max devices negative", func(c *Config) { c.WiFi.MaxConcurrentDevices = -1 }, "wifi.max_concurrent_devices"},
+
+		// wifi.payment_asset and wifi.assets
+		{"payment asset unset", func(c *Config) { c.WiFi.PaymentAsset = "" }, ""},
+		{"payment asset ckbytes", func(c *Config) { c.WiFi.PaymentAsset = "CKBytes" }, ""},
+		{"payment asset sudt", func(c *Config) {
+			c.WiFi.Assets = []SUDTAssetConfig{{Name: "USDI", IssuerLockHash: testIssuerHash}}
+			c.WiFi.PaymentAsset = "USDI"
+		}, "wifi.payment_asset"},
+		{"payment asset unknown", func(c *Config) { c.WiFi.PaymentAsset = "USDI" }, "wifi.payment_asset"},
+		{"asset name empty", func(c *Config) {
+			c.WiFi.Assets = []SUDTAssetConfig{{IssuerLockHash: testIssuerHash}}
+		}, "wifi.assets[0].name"},
+		{"asset name duplicate", func(c *Config) {
+			c.WiFi.Assets = []SUDTAssetConfig{{Name: "CKBytes", IssuerLockHash: testIssuerHash}}
+		}, "already registered"},
+		{"asset hash short", func(c *Config) {
+			c.WiFi.Assets = []SUDTAssetConfig{{Name: "USDI", IssuerLockHash: "0x1234"}}
+		}, "wifi.assets[0].issuer_lock_hash"},
+		{"asset hash no prefix", func(c *Config) {
+			c.WiFi.Assets = []SUDTAssetConfig{{Name: "USDI", IssuerLockHash: testIssuerHash[2:]}}
+		}, "wifi.assets[0].issuer_lock_hash"},
+
+		// wifi.price_oracle_url
+		{"price oracle unset", func(c *Config) { c.WiFi.PriceOracleURL = "" }, ""},
+		{"price oracle https", func(c *Config) { c.WiFi.PriceOracleURL = "https://prices.example.com/v1" }, ""},
+		{"price oracle invalid", func(c *Config) { c.WiFi.PriceOracleURL = "prices" }, "wifi.price_oracle_url"},
+
+		// auth key paths
+		{"private key path empty", func(c *Config) { c.Auth.PrivateKeyPath = "" }, "auth.private_key_path"},
+		{"public key path empty", func(c *Config) { c.Auth.PublicKeyPath = "" }, "auth.public_key_path"},
+
+		// server.port
+		{"port minimum", func(c *Config) { c.Server.Port = 1 }, ""},
+		{"port maximum", func(c *Config) { c.Server.Port = 65535 }, ""},
+		{"port zero", func(c *Config) { c.Server.Port = 0 }, "server.port"},
+		{"port too high", func(c *Config) { c.Server.Port = 65536 }, "server.port"},
+		{"port negative", func(c *Config) { c.Server.Port = -1 }, "server.port"},
+
+		// server.shutdown_timeout
+		{"shutdown timeout default", func(c *Config) { c.Server.ShutdownTimeout = 0 }, ""},
+		{"shutdown timeout negative", func(c *Config) { c.Server.ShutdownTimeout = -time.Second }, "server.shutdown_timeout"},
+
+		// perun.balance_cache_ttl
+		{"balance cache default", func(c *Config) { c.Perun.BalanceCacheTTL = 0 }, ""},
+		{"balance cache negative", func(c *Config) { c.Perun.BalanceCacheTTL = -time.Second }, "perun.balance_cache_ttl"},
+
+		// database.compact_schedule
+		{"compact schedule disabled", func(c *Config) { c.Database.CompactSchedule = "" }, ""},
+		{"compact schedule daily", func(c *Config) { c.Database.CompactSchedule = "30 3 * * *" }, ""},
+		{"compact schedule invalid", func(c *Config) { c.Database.CompactSchedule = "weekly" }, "database.compact_schedule"},
+
+		// database.key_encryption_key
+		{"key encryption key", func(c *Config) { c.Database.KeyEncryptionKey = strings.Repeat("ab", 32) }, ""},
+		{"key encryption key short", func(c *Config) { c.Database.KeyEncryptionKey = "abcd" }, "database.key_encryption_key"},
+		{"key encryption key not hex", func(c *Config) { c.Database.KeyEncryptionKey = strings.Repeat("zz", 32) }, "database.key_encryption_key"},
+
+		// openwrt
+		{"openwrt unset", func(c *Config) { c.OpenWrt = nil }, ""},
+		{"openwrt complete", func(c *Config) {
+			c.OpenWrt = &OpenWrtConfig{Address: "192.168.1.1", Username: "root"}
+		}, ""},
+		{"openwrt missing address", func(c *Config) { c.OpenWrt = &OpenWrtConfig{Username: "root"} }, "openwrt.address"},
+		{"openwrt missing username", func(c *Config) { c.OpenWrt = &OpenWrtConfig{Address: "192.168.1.1"} }, "openwrt.username"},
+		// valid keys and known hosts are covered by TestValidate_OpenWrtSSHFiles
+		{"openwrt missing private key", func(c *Config) {
+			c.OpenWrt = &OpenWrtConfig{Address: "192.168.1.1", Username: "root", PrivateKey: "/nonexistent/id_rsa"}
+		}, "openwrt.private_key"},
+		{"openwrt missing access point key", func(c *Config) {
+			c.OpenWrt = &OpenWrtConfig{Address: "192.168.1.1", Username: "root",
+				AccessPoints: []AccessPointConfig{{Address: "192.168.1.2", PrivateKey: "/nonexistent/id_rsa"}}}
+		}, "openwrt.access_points[0].private_key"},
+		{"openwrt missing known hosts", func(c *Config) {
+			c.OpenWrt = &OpenWrtConfig{Address: "192.168.1.1", Username: "root", KnownHostsPath: "/nonexistent/known_hosts"}
+		}, "openwrt.known_hosts_path"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			tt.modify(cfg)
+			err := cfg.Validate()
+
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Expected valid config, got %v", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Expected *ValidationError, got %v", err)
+			}
+			if len(validationErr.ValidationErrors) != 1 {
+				t.Fatalf("Expected 1 error, got %v", validationErr.ValidationErrors)
+			}
+			if !strings.Contains(validationErr.ValidationErrors[0], tt.wantErr) {
+				t.Errorf("Expected error about %s, got %q", tt.wantErr, validationErr.ValidationErrors[0])
+			}
+		})
+	}
+}
+
+const testIssuerHash = "0x1111111111111111111111111111111111111111111111111111111111111111"
+
+func TestValidate_CollectsAllErrors(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.CKB.RPCURL = "not a url"
+	cfg.WiFi.RatePerHour = 0
+	cfg.Server.Port = 0
+	cfg.OpenWrt = &OpenWrtConfig{}
+
+	err := cfg.Validate()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected *ValidationError, got %v", err)
+	}
+
+	expected := []string{"ckb.rpc_url", "wifi.rate_per_hour", "server.port", "openwrt.address", "openwrt.username"}
+	if len(validationErr.ValidationErrors) != len(expected) {
+		t.Fatalf("Expected %d errors, got %v", len(expected), validationErr.ValidationErrors)
+	}
+	for i, field := range expected {
+		if !strings.Contains(validationErr.ValidationErrors[i], field) {
+			t.Errorf("Error %d: expected %s, got %q", i, field, validationErr.ValidationErrors[i])
+		}
+	}
+	if !strings.Contains(err.Error(), "server.port") {
+		t.Errorf("Error() should include every message, got %q", err.Error())
+	}
+}
+
+func TestWiFiConfig_Validate(t *testing.T) {
+	wifi := DefaultConfig().WiFi
+	if err := wifi.Validate(); err != nil {
+		t.Fatalf("Default wifi config should be valid, got %v", err)
+	}
+
+	wifi.RatePerHour = 0
+	wifi.MaxSessionTime = 0
+	err := wifi.Validate()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected *ValidationError, got %v", err)
+	}
+	if len(validationErr.ValidationErrors) != 2 {
+		t.Fatalf("Expected 2 errors, got %v", validationErr.ValidationErrors)
+	}
+}
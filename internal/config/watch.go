@@ -0,0 +1,242 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultPollInterval is how often a Watcher without fsnotify checks the
+// config file when Watch is given no poll interval.
+const DefaultPollInterval = 5 * time.Second
+
+// envPollInterval is how often a Watcher re-reads environment overrides,
+// which no file event announces.
+const envPollInterval = 60 * time.Second
+
+// debounceWindow groups the several file events one save produces into a
+// single reload.
+const debounceWindow = 100 * time.Millisecond
+
+// Watcher reloads a config file when it or the environment changes and
+// reports each change to a callback.
+type Watcher struct {
+	paths        []string // config files that trigger a reload
+	load         func() (*Config, error)
+	pollInterval time.Duration
+	envInterval  time.Duration
+	onChange     func(old, new *Config)
+
+	current  *Config
+	fsw      *fsnotify.Watcher // nil when polling the files
+	lastStat []fileInfo        // file metadata at the last poll
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// Watch loads the config at path and calls onChange whenever a reload
+// yields a different config. File changes are picked up through fsnotify,
+// or by polling the file every pollInterval where inotify isn't available;
+// environment overrides are re-read every 60 seconds. Reloads that fail to
+// parse or validate are ignored until the file is fixed.
+func Watch(path string, pollInterval time.Duration, onChange func(old, new *Config)) (*Watcher, error) {
+	load := func() (*Config, error) { return Load(path) }
+	return watchFiles([]string{path}, load, pollInterval, onChange)
+}
+
+// WatchWithOverride is Watch for a config loaded with LoadWithOverride:
+// a change to either the base or the override file triggers a reload.
+func WatchWithOverride(basePath, overridePath string, pollInterval time.Duration, onChange func(old, new *Config)) (*Watcher, error) {
+	load := func() (*Config, error) { return LoadWithOverride(basePath, overridePath) }
+	return watchFiles([]string{basePath, overridePath}, load, pollInterval, onChange)
+}
+
+// watchFiles starts a Watcher on paths, using fsnotify when it is available.
+func watchFiles(paths []string, load func() (*Config, error), pollInterval time.Duration, onChange func(old, new *Config)) (*Watcher, error) {
+	fsw, err := fsnotify.NewWatcher()
+	if err == nil {
+		// Watch the directories: editors often save by replacing the file,
+		// which drops a watch on the file itself
+		for _, path := range paths {
+			if err = fsw.Add(filepath.Dir(path)); err != nil {
+				fsw.Close()
+				break
+			}
+		}
+	}
+	if err != nil {
+		fsw = nil
+	}
+	return watch(paths, load, pollInterval, fsw, onChange)
+}
+
+// watch starts a Watcher that uses fsw for file events, or polls the files
+// if fsw is nil.
+func watch(paths []string, load func() (*Config, error), pollInterval time.Duration, fsw *fsnotify.Watcher, onChange func(old, new *Config)) (*Watcher, error) {
+	w := &Watcher{
+		load:         load,
+		pollInterval: pollInterval,
+		envInterval:  envPollInterval,
+		onChange:     onChange,
+		fsw:          fsw,
+		stop:         make(chan struct{}),
+	}
+	for _, path := range paths {
+		w.paths = append(w.paths, filepath.Clean(path))
+	}
+	if w.pollInterval <= 0 {
+		w.pollInterval = DefaultPollInterval
+	}
+
+	// Stat before loading so a write in between is seen by the first poll
+	w.lastStat = w.stat()
+	cfg, err := load()
+	if err != nil {
+		if fsw != nil {
+			fsw.Close()
+		}
+		return nil, err
+	}
+	w.current = cfg
+
+	w.wg.Add(1)
+	if fsw != nil {
+		go w.watchEvents()
+	} else {
+		go w.pollFile()
+	}
+	return w, nil
+}
+
+// Polling reports whether the watcher fell back to polling the files.
+func (w *Watcher) Polling() bool {
+	return w.fsw == nil
+}
+
+// Stop ends the watcher's goroutines and waits for them to exit. No
+// callback runs after Stop returns.
+func (w *Watcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+		w.wg.Wait()
+		if w.fsw != nil {
+			w.fsw.Close()
+			// Close closes both channels once its reader exits
+			for range w.fsw.Events {
+			}
+			for range w.fsw.Errors {
+			}
+		}
+	})
+}
+
+// watchEvents reloads the config a debounce window after the last event
+// on a watched file, and on every environment poll.
+func (w *Watcher) watchEvents() {
+	defer w.wg.Done()
+
+	envTicker := time.NewTicker(w.envInterval)
+	defer envTicker.Stop()
+
+	var debounce *time.Timer
+	var fire <-chan time.Time
+	defer func() {
+		if debounce != nil {
+			debounce.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case event, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			if !slices.Contains(w.paths, filepath.Clean(event.Name)) {
+				continue
+			}
+			if debounce == nil {
+				debounce = time.NewTimer(debounceWindow)
+			} else {
+				debounce.Reset(debounceWindow)
+			}
+			fire = debounce.C
+		case _, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+		case <-fire:
+			fire = nil
+			w.reload()
+		case <-envTicker.C:
+			w.reload()
+		}
+	}
+}
+
+// pollFile reloads the config when a file's size or modification time
+// changes, and on every environment poll.
+func (w *Watcher) pollFile() {
+	defer w.wg.Done()
+
+	fileTicker := time.NewTicker(w.pollInterval)
+	defer fileTicker.Stop()
+	envTicker := time.NewTicker(w.envInterval)
+	defer envTicker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-fileTicker.C:
+			if info := w.stat(); !slices.Equal(info, w.lastStat) {
+				w.lastStat = info
+				w.reload()
+			}
+		case <-envTicker.C:
+			w.reload()
+		}
+	}
+}
+
+// fileInfo is the part of a file's metadata that changes on a write.
+type fileInfo struct {
+	size    int64
+	modTime time.Time
+}
+
+// stat returns each config file's size and modification time, or zero
+// values for a file that doesn't exist.
+func (w *Watcher) stat() []fileInfo {
+	infos := make([]fileInfo, len(w.paths))
+	for i, path := range w.paths {
+		if info, err := os.Stat(path); err == nil {
+			infos[i] = fileInfo{size: info.Size(), modTime: info.ModTime()}
+		}
+	}
+	return infos
+}
+
+// reload loads the config again and reports it if it changed. Only the
+// watcher goroutine calls reload, so current needs no lock.
+func (w *Watcher) reload() {
+	cfg, err := w.load()
+	if err != nil || cfg.Validate() != nil {
+		return
+	}
+	if reflect.DeepEqual(cfg, w.current) {
+		return
+	}
+	old := w.current
+	w.current = cfg
+	w.onChange(old, cfg)
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	path := writeConfigFile(t, t.TempDir(), "config.yaml", "wifi:\n  rate_per_hour: 500\n")
+
+	changes := make(chan *Config, 10)
+	w, err := Watch(path, 50*time.Millisecond, func(old, new *Config) {
+		changes <- new
+	})
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	defer w.Stop()
+
+	// Several writes in quick succession are reported once
+	for _, rate := range []string{"600", "700", "800"} {
+		if err := os.WriteFile(path, []byte("wifi:\n  rate_per_hour: "+rate+"\n"), 0600); err != nil {
+			t.Fatalf("failed to write config: %v", err)
+		}
+	}
+
+	select {
+	case cfg := <-changes:
+		if cfg.WiFi.RatePerHour != 800 {
+			t.Errorf("Expected rate 800, got %d", cfg.WiFi.RatePerHour)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a change callback")
+	}
+	select {
+	case cfg := <-changes:
+		if !w.Polling() {
+			t.Errorf("Expected one callback, got another with rate %d", cfg.WiFi.RatePerHour)
+		}
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	// An invalid config is ignored
+	os.WriteFile(path, []byte("wifi:\n  rate_per_hour: -1\n"), 0600)
+	select {
+	case cfg := <-changes:
+		t.Errorf("Invalid config should not be reported, got rate %d", cfg.WiFi.RatePerHour)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestWatch_Polling(t *testing.T) {
+	path := writeConfigFile(t, t.TempDir(), "config.yaml", "wifi:\n  rate_per_hour: 500\n")
+
+	changes := make(chan *Config, 10)
+	load := func() (*Config, error) { return Load(path) }
+	w, err := watch([]string{path}, load, 20*time.Millisecond, nil, func(old, new *Config) {
+		if old.WiFi.RatePerHour != 500 {
+			t.Errorf("Expected old rate 500, got %d", old.WiFi.RatePerHour)
+		}
+		changes <- new
+	})
+	if err != nil {
+		t.Fatalf("watch failed: %v", err)
+	}
+	if !w.Polling() {
+		t.Fatal("Expected the watcher to poll without fsnotify")
+	}
+
+	// A different size guarantees the change is seen even if the
+	// modification time doesn't move
+	os.WriteFile(path, []byte("wifi:\n  rate_per_hour: 1200\n"), 0600)
+	select {
+	case cfg := <-changes:
+		if cfg.WiFi.RatePerHour != 1200 {
+			t.Errorf("Expected rate 1200, got %d", cfg.WiFi.RatePerHour)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a change callback")
+	}
+
+	w.Stop()
+	w.Stop() // stopping twice is harmless
+}
+
+func TestWatchWithOverride(t *testing.T) {
+	dir := t.TempDir()
+	basePath := writeConfigFile(t, dir, "config.yaml", "wifi:\n  rate_per_hour: 500\n")
+	overridePath := OverridePath(basePath, "testnet")
+
+	changes := make(chan *Config, 10)
+	w, err := WatchWithOverride(basePath, overridePath, 20*time.Millisecond, func(old, new *Config) {
+		changes <- new
+	})
+	if err != nil {
+		t.Fatalf("WatchWithOverride failed: %v", err)
+	}
+	defer w.Stop()
+
+	// Creating the override is a change, merged over the base
+	if err := os.WriteFile(overridePath, []byte("wifi:\n  rate_per_hour: 900\n"), 0600); err != nil {
+		t.Fatalf("failed to write override: %v", err)
+	}
+	select {
+	case cfg := <-changes:
+		if cfg.WiFi.RatePerHour != 900 {
+			t.Errorf("Expected the override rate 900, got %d", cfg.WiFi.RatePerHour)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a change callback")
+	}
+}
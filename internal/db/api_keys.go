@@ -0,0 +1,96 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// APIKey represents a machine-to-machine API key. Only the key hash is stored.
+type APIKey struct {
+	ID         string
+	KeyHash    string
+	Label      string
+	Scopes     []string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+}
+
+// CreateAPIKey inserts a new API key record.
+func (db *DB) CreateAPIKey(k *APIKey) error {
+	_, err := db.conn.Exec(`
+		INSERT INTO api_keys (id, key_hash, label, scopes, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, k.ID, k.KeyHash, k.Label, strings.Join(k.Scopes, ","), k.CreatedAt)
+	return err
+}
+
+// GetAPIKeyByHash retrieves an API key by its hash.
+func (db *DB) GetAPIKeyByHash(keyHash string) (*APIKey, error) {
+	row := db.conn.QueryRow(`
+		SELECT id, key_hash, label, scopes, created_at, last_used_at
+		FROM api_keys WHERE key_hash = ?
+	`, keyHash)
+	return scanAPIKey(row)
+}
+
+// ListAPIKeys returns all API keys, newest first.
+func (db *DB) ListAPIKeys() ([]*APIKey, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, key_hash, label, scopes, created_at, last_used_at
+		FROM api_keys ORDER BY created_at DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// DeleteAPIKey revokes an API key. Returns sql.ErrNoRows if it does not exist.
+func (db *DB) DeleteAPIKey(id string) error {
+	result, err := db.conn.Exec(`DELETE FROM api_keys WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// TouchAPIKey records that an API key was just used.
+func (db *DB) TouchAPIKey(id string) error {
+	_, err := db.conn.Exec(`UPDATE api_keys SET last_used_at = ? WHERE id = ?`, time.Now(), id)
+	return err
+}
+
+func scanAPIKey(row rowScanner) (*APIKey, error) {
+	k := &APIKey{}
+	var label, scopes sql.NullString
+	var lastUsedAt sql.NullTime
+	if err := row.Scan(&k.ID, &k.KeyHash, &label, &scopes, &k.CreatedAt, &lastUsedAt); err != nil {
+		return nil, err
+	}
+	k.Label = label.String
+	if scopes.String != "" {
+		k.Scopes = strings.Split(scopes.String, ",")
+	}
+	if lastUsedAt.Valid {
+		k.LastUsedAt = &lastUsedAt.Time
+	}
+	return k, nil
+}
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDB_APIKeyLifecycle(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	key := &APIKey{
+		ID:        "key-1",
+		KeyHash:   "hash-1",
+		Label:     "monitoring",
+		Scopes:    []string{"admin", "read"},
+		CreatedAt: time.Now(),
+	}
+	if err := db.CreateAPIKey(key); err != nil {
+		t.Fatalf("CreateAPIKey failed: %v", err)
+	}
+
+	retrieved, err := db.GetAPIKeyByHash("hash-1")
+	if err != nil {
+		t.Fatalf("GetAPIKeyByHash failed: %v", err)
+	}
+	if retrieved.Label != "monitoring" {
+		t.Errorf("Label: expected monitoring, got %s", retrieved.Label)
+	}
+	if len(retrieved.Scopes) != 2 || retrieved.Scopes[1] != "read" {
+		t.Errorf("Scopes: expected [admin read], got %v", retrieved.Scopes)
+	}
+	if retrieved.LastUsedAt != nil {
+		t.Error("LastUsedAt should be nil before first use")
+	}
+
+	if err := db.TouchAPIKey("key-1"); err != nil {
+		t.Fatalf("TouchAPIKey failed: %v", err)
+	}
+	retrieved, _ = db.GetAPIKeyByHash("hash-1")
+	if retrieved.LastUsedAt == nil {
+		t.Error("LastUsedAt should be set after use")
+	}
+
+	keys, _ := db.ListAPIKeys()
+	if len(keys) != 1 {
+		t.Errorf("Expected 1 key, got %d", len(keys))
+	}
+
+	if err := db.DeleteAPIKey("key-1"); err != nil {
+		t.Fatalf("DeleteAPIKey failed: %v", err)
+	}
+	if _, err := db.GetAPIKeyByHash("hash-1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows after delete, got %v", err)
+	}
+	if err := db.DeleteAPIKey("key-1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows deleting missing key, got %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ActorType identifies what kind of actor performed an audited action.
+type ActorType string
+
+// Audit actor types.
+const (
+	ActorSystem ActorType = "system"  // Background workers and guest-facing flows
+	ActorHost   ActorType = "host"    // Dashboard session
+	ActorAPIKey ActorType = "api_key" // Machine client; ActorID is the key ID
+)
+
+// AuditEntry is one record in the compliance audit log.
+type AuditEntry struct {
+	ID        int64
+	Timestamp time.Time
+	Action    string
+	SessionID string
+	WalletID  string
+	ActorType ActorType
+	ActorID   string
+	Details   string
+}
+
+// AuditQuery holds optional audit log filters. Zero values are ignored.
+type AuditQuery struct {
+	From      time.Time
+	To        time.Time
+	SessionID string
+	Limit     int
+	Offset    int
+}
+
+// LogAudit appends an entry to the audit log and sets its ID. A zero
+// Timestamp is set to the current time.
+func (db *DB) LogAudit(entry *AuditEntry) error {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	entry.Timestamp = entry.Timestamp.UTC() // stored in UTC so range filters compare correctly
+
+	result, err := db.conn.Exec(`
+		INSERT INTO audit_log (timestamp, action, session_id, wallet_id, actor_type, actor_id, details)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, entry.Timestamp, entry.Action, entry.SessionID, entry.WalletID, string(entry.ActorType), entry.ActorID, entry.Details)
+	if err != nil {
+		return fmt.Errorf("failed to log audit entry: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get audit entry id: %w", err)
+	}
+	entry.ID = id
+	return nil
+}
+
+// ListAuditLog returns audit entries matching q, oldest first.
+func (db *DB) ListAuditLog(q *AuditQuery) ([]*AuditEntry, error) {
+	var conditions []string
+	var args []interface{}
+
+	if !q.From.IsZero() {
+		conditions = append(conditions, "timestamp >= ?")
+		args = append(args, q.From.UTC())
+	}
+	if !q.To.IsZero() {
+		conditions = append(conditions, "timestamp <= ?")
+		args = append(args, q.To.UTC())
+	}
+	if q.SessionID != "" {
+		conditions = append(conditions, "session_id = ?")
+		args = append(args, q.SessionID)
+	}
+
+	query := "SELECT id, timestamp, action, session_id, wallet_id, actor_type, actor_id, details FROM audit_log"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY timestamp ASC, id ASC"
+
+	if q.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, q.Limit, q.Offset)
+	}
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*AuditEntry
+	for rows.Next() {
+		e := &AuditEntry{}
+		var actorType string
+		if err := rows.Scan(&e.ID, &e.Timestamp, &e.Action, &e.SessionID, &e.WalletID, &actorType, &e.ActorID, &e.Details); err != nil {
+			return nil, err
+		}
+		e.ActorType = ActorType(actorType)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
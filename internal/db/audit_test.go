@@ -0,0 +1,62 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDB_AuditLog(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	base := time.Now().Add(-time.Hour)
+	entries := []*AuditEntry{
+		{Timestamp: base, Action: "wallet_created", WalletID: "wallet-1", ActorType: ActorSystem},
+		{Timestamp: base.Add(time.Minute), Action: "session_created", SessionID: "session-1", WalletID: "wallet-1", ActorType: ActorSystem},
+		{Timestamp: base.Add(2 * time.Minute), Action: "rate_changed", ActorType: ActorAPIKey, ActorID: "key-1", Details: "rate_per_hour=600"},
+		{Timestamp: base.Add(3 * time.Minute), Action: "session_ended", SessionID: "session-1", WalletID: "wallet-1", ActorType: ActorHost},
+	}
+	for _, e := range entries {
+		if err := db.LogAudit(e); err != nil {
+			t.Fatalf("LogAudit failed: %v", err)
+		}
+		if e.ID == 0 {
+			t.Error("LogAudit should set the entry ID")
+		}
+	}
+
+	all, err := db.ListAuditLog(&AuditQuery{})
+	if err != nil {
+		t.Fatalf("ListAuditLog failed: %v", err)
+	}
+	if len(all) != 4 {
+		t.Fatalf("Expected 4 entries, got %d", len(all))
+	}
+	if all[2].ActorType != ActorAPIKey || all[2].ActorID != "key-1" || all[2].Details != "rate_per_hour=600" {
+		t.Errorf("Unexpected entry: %+v", all[2])
+	}
+
+	bySession, err := db.ListAuditLog(&AuditQuery{SessionID: "session-1"})
+	if err != nil {
+		t.Fatalf("ListAuditLog failed: %v", err)
+	}
+	if len(bySession) != 2 || bySession[0].Action != "session_created" || bySession[1].Action != "session_ended" {
+		t.Errorf("Expected session_created then session_ended, got %+v", bySession)
+	}
+
+	window, err := db.ListAuditLog(&AuditQuery{From: base.Add(30 * time.Second), To: base.Add(150 * time.Second)})
+	if err != nil {
+		t.Fatalf("ListAuditLog failed: %v", err)
+	}
+	if len(window) != 2 || window[0].Action != "session_created" {
+		t.Errorf("Expected 2 entries in window, got %+v", window)
+	}
+
+	page, err := db.ListAuditLog(&AuditQuery{Limit: 2, Offset: 2})
+	if err != nil {
+		t.Fatalf("ListAuditLog failed: %v", err)
+	}
+	if len(page) != 2 || page[0].Action != "rate_changed" {
+		t.Errorf("Expected second page to start at rate_changed, got %+v", page)
+	}
+}
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// BackupCode is a one-time 2FA recovery code. Only the code hash is stored.
+type BackupCode struct {
+	CodeHash  string
+	CreatedAt time.Time
+	UsedAt    *time.Time
+}
+
+// ReplaceBackupCodes discards all existing backup codes, used or not, and
+// stores the given code hashes in one transaction.
+func (db *DB) ReplaceBackupCodes(codeHashes []string) error {
+	return db.Transaction(func(tx *DB) error {
+		if _, err := tx.conn.Exec(`DELETE FROM backup_codes`); err != nil {
+			return fmt.Errorf("failed to delete backup codes: %w", err)
+		}
+		now := time.Now()
+		for _, hash := range codeHashes {
+			if _, err := tx.conn.Exec(`INSERT INTO backup_codes (code_hash, created_at) VALUES (?, ?)`, hash, now); err != nil {
+				return fmt.Errorf("failed to store backup code: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// ListBackupCodes returns all backup codes, unused first.
+func (db *DB) ListBackupCodes() ([]*BackupCode, error) {
+	rows, err := db.conn.Query(`
+		SELECT code_hash, created_at, used_at
+		FROM backup_codes ORDER BY used_at IS NOT NULL, used_at, code_hash
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var codes []*BackupCode
+	for rows.Next() {
+		code := &BackupCode{}
+		var usedAt sql.NullTime
+		if err := rows.Scan(&code.CodeHash, &code.CreatedAt, &usedAt); err != nil {
+			return nil, err
+		}
+		if usedAt.Valid {
+			code.UsedAt = &usedAt.Time
+		}
+		codes = append(codes, code)
+	}
+	return codes, rows.Err()
+}
+
+// UseBackupCode marks an unused backup code as used. It reports false if
+// the code is unknown or was already used, so each code works only once
+// even when submitted concurrently.
+func (db *DB) UseBackupCode(codeHash string) (bool, error) {
+	result, err := db.conn.Exec(`
+		UPDATE backup_codes SET used_at = ? WHERE code_hash = ? AND used_at IS NULL
+	`, time.Now(), codeHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
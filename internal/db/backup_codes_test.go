@@ -0,0 +1,45 @@
+package db
+
+import "testing"
+
+func TestDB_BackupCodes(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	if err := db.ReplaceBackupCodes([]string{"hash-a", "hash-b"}); err != nil {
+		t.Fatalf("ReplaceBackupCodes failed: %v", err)
+	}
+
+	if used, err := db.UseBackupCode("hash-a"); err != nil || !used {
+		t.Fatalf("First use: used=%v err=%v, want true", used, err)
+	}
+	if used, _ := db.UseBackupCode("hash-a"); used {
+		t.Error("Second use of the same code should fail")
+	}
+	if used, _ := db.UseBackupCode("unknown"); used {
+		t.Error("Unknown code should fail")
+	}
+
+	codes, err := db.ListBackupCodes()
+	if err != nil {
+		t.Fatalf("ListBackupCodes failed: %v", err)
+	}
+	if len(codes) != 2 {
+		t.Fatalf("Expected 2 codes, got %d", len(codes))
+	}
+	if codes[0].CodeHash != "hash-b" || codes[0].UsedAt != nil {
+		t.Errorf("Expected unused hash-b first, got %+v", codes[0])
+	}
+	if codes[1].UsedAt == nil {
+		t.Error("Expected hash-a to be marked used")
+	}
+
+	// Replacing drops used and unused codes alike
+	if err := db.ReplaceBackupCodes([]string{"hash-c"}); err != nil {
+		t.Fatalf("ReplaceBackupCodes failed: %v", err)
+	}
+	codes, _ = db.ListBackupCodes()
+	if len(codes) != 1 || codes[0].CodeHash != "hash-c" {
+		t.Errorf("Expected only hash-c after replace, got %d codes", len(codes))
+	}
+}
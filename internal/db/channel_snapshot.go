@@ -0,0 +1,62 @@
+package db
+
+import (
+	"time"
+
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
+)
+
+// ChannelSnapshot is the latest signed off-chain state of a session's
+// channel, reduced to the host's side of it.
+type ChannelSnapshot struct {
+	SessionID           string
+	ChannelID           perun.ChannelID
+	Version             uint64
+	HostInitialShannons int64 // Host balance in the first state recorded
+	HostBalanceShannons int64 // Host balance in the latest state
+	UpdatedAt           time.Time
+}
+
+// SaveChannelSnapshot records a signed channel state. The first snapshot of
+// a session fixes its initial host balance; later ones only move the current
+// balance forward, and states older than the stored one are ignored.
+func (db *DB) SaveChannelSnapshot(snap *ChannelSnapshot) error {
+	_, err := db.conn.Exec(`
+		INSERT INTO channel_snapshots (session_id, channel_id, version, host_initial_shannons, host_balance_shannons, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(session_id) DO UPDATE SET
+			channel_id = excluded.channel_id,
+			version = excluded.version,
+			host_balance_shannons = excluded.host_balance_shannons,
+			updated_at = excluded.updated_at
+		WHERE excluded.version >= channel_snapshots.version
+	`, snap.SessionID, snap.ChannelID, snap.Version, snap.HostInitialShannons, snap.HostBalanceShannons, time.Now())
+	return err
+}
+
+// GetChannelSnapshot retrieves the latest channel state recorded for a
+// session. Returns sql.ErrNoRows if none was recorded.
+func (db *DB) GetChannelSnapshot(sessionID string) (*ChannelSnapshot, error) {
+	snap := &ChannelSnapshot{}
+	err := db.conn.QueryRow(`
+		SELECT session_id, channel_id, version, host_initial_shannons, host_balance_shannons, updated_at
+		FROM channel_snapshots WHERE session_id = ?
+	`, sessionID).Scan(&snap.SessionID, &snap.ChannelID, &snap.Version, &snap.HostInitialShannons, &snap.HostBalanceShannons, &snap.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// GetSessionEarnings returns what the host has earned from a session in
+// whole CKB, according to the latest signed channel state: the host's
+// current balance minus its initial one. Unlike spent_ckb it can't drift
+// when payments fail or the server restarts. Returns sql.ErrNoRows if no
+// channel state was recorded.
+func (db *DB) GetSessionEarnings(sessionID string) (int64, error) {
+	snap, err := db.GetChannelSnapshot(sessionID)
+	if err != nil {
+		return 0, err
+	}
+	return (snap.HostBalanceShannons - snap.HostInitialShannons) / 100000000, nil
+}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
+)
+
+func TestDB_SessionEarnings(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	if _, err := db.GetSessionEarnings("s1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("Expected sql.ErrNoRows without a snapshot, got %v", err)
+	}
+
+	save := func(version uint64, hostBalance int64) {
+		t.Helper()
+		err := db.SaveChannelSnapshot(&ChannelSnapshot{
+			SessionID:           "s1",
+			ChannelID:           perun.ChannelID{0xab},
+			Version:             version,
+			HostInitialShannons: hostBalance,
+			HostBalanceShannons: hostBalance,
+		})
+		if err != nil {
+			t.Fatalf("SaveChannelSnapshot failed: %v", err)
+		}
+	}
+
+	// Opening state: host put in 100 CKB
+	save(0, 100*100000000)
+	// Guest paid 25 CKB, then a stale state arrives late
+	save(5, 125*100000000)
+	save(3, 110*100000000)
+
+	snap, err := db.GetChannelSnapshot("s1")
+	if err != nil {
+		t.Fatalf("GetChannelSnapshot failed: %v", err)
+	}
+	if snap.Version != 5 || snap.HostInitialShannons != 100*100000000 || snap.ChannelID != (perun.ChannelID{0xab}) {
+		t.Errorf("Unexpected snapshot: %+v", snap)
+	}
+
+	earnings, err := db.GetSessionEarnings("s1")
+	if err != nil {
+		t.Fatalf("GetSessionEarnings failed: %v", err)
+	}
+	if earnings != 25 {
+		t.Errorf("Expected 25 CKB earned, got %d", earnings)
+	}
+}
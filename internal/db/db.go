@@ -2,52 +2,122 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/airfi/airfi-perun-nervous/internal/expiry"
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
 )
 
 // DB represents the database connection.
 type DB struct {
-	conn *sql.DB
+	conn    querier
+	sqlDB   *sql.DB
+	replica *sql.DB // read-only connection for analytics, nil when not opened
+	inTx    bool
+
+	keyCipher *KeyCipher // encrypts wallet private keys at rest, nil stores them in plaintext
+}
+
+// querier is the statement API shared by *sql.DB and *sql.Tx.
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
 }
 
 // Session represents a WiFi session record.
 type Session struct {
 	ID           string
-	WalletID     string // Guest wallet ID
-	ChannelID    string // Perun channel ID
+	WalletID     string          // Guest wallet ID
+	ChannelID    perun.ChannelID // Zero until the channel is open
 	GuestAddress string
 	HostAddress  string
-	FundingCKB   int64     // Initial funding amount
-	BalanceCKB   int64     // Current remaining balance
-	SpentCKB     int64     // Total spent on micropayments
+	FundingCKB   int64 // Initial funding amount
+	BalanceCKB   int64 // Current remaining balance
+	SpentCKB     int64 // Total spent on micropayments
 	CreatedAt    time.Time
 	ExpiresAt    time.Time
-	Status       string // pending_funding, funding_detected, channel_open, active, settled, expired
+	Status       string // pending_funding, funding_detected, channel_open, active, settled, expired, orphaned_recovery
 	SettledAt    *time.Time
+	SettleTxHash string // Cooperative close transaction; empty for other closes
 	MACAddress   string // Guest device MAC address
 	IPAddress    string // Guest device IP address
+	BytesIn      int64  // Bytes downloaded by the guest device (from router)
+	BytesOut     int64  // Bytes uploaded by the guest device (from router)
+
+	DisputedAt       *time.Time // When a channel dispute was registered on-chain
+	DisputeTxHash    string
+	ResolvedAt       *time.Time // When the dispute was resolved (channel concluded)
+	ResolutionTxHash string
+
+	LastHeartbeatAt  *time.Time // Last keep-alive from the guest's session page
+	ExpiryNotifiedAt *time.Time // When the guest was warned the session is about to expire
+
+	SenderAddress string // Where refunds go, copied from the wallet once known
+
+	LastHeartbeatSuccess time.Time  // Last channel heartbeat the peer answered; zero if none yet
+	PeerOfflineSince     *time.Time // First missed channel heartbeat since the last answered one
+
+	TokenJTI string // ID of the last access token issued for the session
+}
+
+// IsExpired reports whether the session has expired; see expiry.Expired.
+func (s *Session) IsExpired() bool {
+	return expiry.Expired(s.ExpiresAt)
+}
+
+// TimeUntilExpiry returns how long until the session expires; see
+// expiry.Until.
+func (s *Session) TimeUntilExpiry() time.Duration {
+	return expiry.Until(s.ExpiresAt)
 }
 
+// ErrDuplicateWallet is returned by CreateGuestWallet when the wallet or its
+// private key is already stored.
+var ErrDuplicateWallet = errors.New("guest wallet already exists")
+
 // GuestWallet represents a generated guest wallet.
 type GuestWallet struct {
-	ID            string
-	Address       string
-	PrivateKeyHex string // Encrypted or hex-encoded private key
-	FundingCKB    int64  // Required funding amount
-	BalanceCKB    int64  // Current on-chain balance
-	CreatedAt     time.Time
-	FundedAt      *time.Time
-	SessionID     string // Associated session after funding
-	Status        string // created, funded, channel_open, settled, withdrawn
-	SenderAddress string // Original sender address for refund
-	MACAddress    string // Guest device MAC address (from captive portal)
-	IPAddress     string // Guest device IP address (from captive portal)
+	ID             string
+	Address        string
+	PrivateKeyHex  string // Hex-encoded private key, encrypted at rest when a KeyCipher is set
+	PrivateKeyHash string // BLAKE2b-256 of the private key, unique per wallet
+	FundingCKB     int64  // Required funding amount
+	BalanceCKB     int64  // Current on-chain balance
+	CreatedAt      time.Time
+	FundedAt       *time.Time
+	SessionID      string     // Associated session after funding
+	Status         string     // created, funded, preparing_cells, channel_open, active, settled, withdrawn, transferred, orphaned_recovery
+	SenderAddress  string     // Original sender address for refund
+	MACAddress     string     // Guest device MAC address (from captive portal)
+	IPAddress      string     // Guest device IP address (from captive portal)
+	AccessPoint    string     // Access point the device joined through (from captive portal)
+	LastCheckedAt  *time.Time // Last funding check by the detector
+	ExpiresAt      time.Time  // Unfunded wallets expire after this; zero never expires
+
+	RefundStatus    string     // pending, processing, sent, partial, failed; empty before any refund attempt
+	RefundTxHash    string     // Refund transaction, once submitted
+	RefundAmount    int64      // Refunded shannons, once sent
+	RefundUpdatedAt *time.Time // Last refund status change
 }
 
+// Refund states recorded on a GuestWallet.
+const (
+	RefundStatusPending    = "pending"
+	RefundStatusProcessing = "processing"
+	RefundStatusSent       = "sent"
+	RefundStatusPartial    = "partial"
+	RefundStatusFailed     = "failed"
+)
+
 // Settings represents configurable system settings.
 type Settings struct {
 	Key   string
@@ -72,12 +142,53 @@ func Open(path string) (*DB, error) {
 		return nil, err
 	}
 
-	return &DB{conn: conn}, nil
+	return &DB{conn: conn, sqlDB: conn}, nil
 }
 
 // Close closes the database connection.
 func (db *DB) Close() error {
-	return db.conn.Close()
+	if db.replica != nil {
+		db.replica.Close()
+	}
+	return db.sqlDB.Close()
+}
+
+// Ping verifies the database connection is usable.
+func (db *DB) Ping(ctx context.Context) error {
+	return db.sqlDB.PingContext(ctx)
+}
+
+// Transaction runs fn inside a SQL transaction. The *DB passed to fn issues
+// every statement on the transaction; it is committed if fn returns nil and
+// rolled back otherwise. Calling Transaction on that *DB joins the outer
+// transaction instead of starting a new one.
+func (db *DB) Transaction(fn func(tx *DB) error) error {
+	if db.inTx {
+		return fn(db)
+	}
+
+	sqlTx, err := db.sqlDB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			sqlTx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(&DB{conn: sqlTx, sqlDB: db.sqlDB, inTx: true, keyCipher: db.keyCipher}); err != nil {
+		if rbErr := sqlTx.Rollback(); rbErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	if err := sqlTx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
 }
 
 func createTables(conn *sql.DB) error {
@@ -116,102 +227,227 @@ func createTables(conn *sql.DB) error {
 
 		CREATE TABLE IF NOT EXISTS settings (
 			key TEXT PRIMARY KEY,
-			value TEXT NOT NULL
+			value TEXT NOT NULL,
+			updated_at DATETIME
+		);
+
+		CREATE TABLE IF NOT EXISTS api_keys (
+			id TEXT PRIMARY KEY,
+			key_hash TEXT UNIQUE NOT NULL,
+			label TEXT DEFAULT '',
+			scopes TEXT DEFAULT '',
+			created_at DATETIME,
+			last_used_at DATETIME
+		);
+
+		CREATE TABLE IF NOT EXISTS backup_codes (
+			code_hash TEXT PRIMARY KEY,
+			created_at DATETIME,
+			used_at DATETIME
+		);
+
+		CREATE TABLE IF NOT EXISTS audit_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			timestamp DATETIME NOT NULL,
+			action TEXT NOT NULL,
+			session_id TEXT DEFAULT '',
+			wallet_id TEXT DEFAULT '',
+			actor_type TEXT NOT NULL,
+			actor_id TEXT DEFAULT '',
+			details TEXT DEFAULT ''
+		);
+
+		CREATE TABLE IF NOT EXISTS channel_snapshots (
+			session_id TEXT PRIMARY KEY,
+			channel_id TEXT,
+			version INTEGER NOT NULL,
+			host_initial_shannons INTEGER NOT NULL,
+			host_balance_shannons INTEGER NOT NULL,
+			updated_at DATETIME
+		);
+
+		CREATE TABLE IF NOT EXISTS payments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			session_id TEXT NOT NULL,
+			amount_shannons INTEGER NOT NULL,
+			kind TEXT NOT NULL,
+			created_at DATETIME NOT NULL
 		);
 
 		CREATE INDEX IF NOT EXISTS idx_sessions_status ON sessions(status);
 		CREATE INDEX IF NOT EXISTS idx_sessions_created ON sessions(created_at);
 		CREATE INDEX IF NOT EXISTS idx_wallets_status ON guest_wallets(status);
 		CREATE INDEX IF NOT EXISTS idx_wallets_address ON guest_wallets(address);
+		CREATE INDEX IF NOT EXISTS idx_audit_timestamp ON audit_log(timestamp);
+		CREATE INDEX IF NOT EXISTS idx_audit_session ON audit_log(session_id);
+		CREATE INDEX IF NOT EXISTS idx_payments_session ON payments(session_id);
 	`)
 	if err != nil {
 		return err
 	}
 
+	if err := migrateColumns(conn); err != nil {
+		return err
+	}
+
+	// Indexes on migrated columns, which older databases only have now
+	if _, err := conn.Exec(`CREATE INDEX IF NOT EXISTS idx_sessions_token_jti ON sessions(token_jti)`); err != nil {
+		return err
+	}
+	// Wallets created before the hash was stored keep NULL, which is never a duplicate
+	if _, err := conn.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_wallets_private_key_hash ON guest_wallets(private_key_hash)`); err != nil {
+		return err
+	}
+
 	// Initialize default settings if not exist
 	_, err = conn.Exec(`
-		INSERT OR IGNORE INTO settings (key, value) VALUES ('rate_per_hour', '500')
-	`)
+		INSERT OR IGNORE INTO settings (key, value, updated_at) VALUES (?, '500', ?)
+	`, settingRatePerHour, time.Now().UTC())
 	return err
 }
 
-// CreateSession inserts a new session.
-func (db *DB) CreateSession(s *Session) error {
-	_, err := db.conn.Exec(`
-		INSERT INTO sessions (id, wallet_id, channel_id, guest_address, host_address, funding_ckb, balance_ckb, spent_ckb, created_at, expires_at, status, settled_at, mac_address, ip_address)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, s.ID, s.WalletID, s.ChannelID, s.GuestAddress, s.HostAddress, s.FundingCKB, s.BalanceCKB, s.SpentCKB, s.CreatedAt, s.ExpiresAt, s.Status, s.SettledAt, s.MACAddress, s.IPAddress)
-	return err
+// columnMigrations adds columns introduced after the initial schema.
+// Each statement is applied once; existing columns are skipped.
+var columnMigrations = []string{
+	`ALTER TABLE sessions ADD COLUMN bytes_in INTEGER DEFAULT 0`,
+	`ALTER TABLE sessions ADD COLUMN bytes_out INTEGER DEFAULT 0`,
+	`ALTER TABLE sessions ADD COLUMN disputed_at DATETIME`,
+	`ALTER TABLE sessions ADD COLUMN dispute_tx_hash TEXT DEFAULT ''`,
+	`ALTER TABLE sessions ADD COLUMN resolved_at DATETIME`,
+	`ALTER TABLE sessions ADD COLUMN resolution_tx_hash TEXT DEFAULT ''`,
+	`ALTER TABLE guest_wallets ADD COLUMN last_checked_at DATETIME`,
+	`ALTER TABLE sessions ADD COLUMN last_heartbeat_at DATETIME`,
+	`ALTER TABLE guest_wallets ADD COLUMN expires_at DATETIME`,
+	`ALTER TABLE settings ADD COLUMN updated_at DATETIME`,
+	`ALTER TABLE sessions ADD COLUMN sender_address TEXT DEFAULT ''`,
+	`ALTER TABLE guest_wallets ADD COLUMN refund_status TEXT DEFAULT ''`,
+	`ALTER TABLE guest_wallets ADD COLUMN refund_tx_hash TEXT DEFAULT ''`,
+	`ALTER TABLE guest_wallets ADD COLUMN refund_amount INTEGER DEFAULT 0`,
+	`ALTER TABLE guest_wallets ADD COLUMN refund_updated_at DATETIME`,
+	`ALTER TABLE sessions ADD COLUMN expiry_notified_at DATETIME`,
+	`ALTER TABLE sessions ADD COLUMN last_heartbeat_success DATETIME`,
+	`ALTER TABLE sessions ADD COLUMN peer_offline_since DATETIME`,
+	`ALTER TABLE guest_wallets ADD COLUMN updated_at DATETIME`,
+	`ALTER TABLE sessions ADD COLUMN token_jti TEXT DEFAULT ''`,
+	`ALTER TABLE guest_wallets ADD COLUMN private_key_hash TEXT`,
+	`ALTER TABLE guest_wallets ADD COLUMN access_point TEXT DEFAULT ''`,
+	`ALTER TABLE sessions ADD COLUMN settle_tx_hash TEXT DEFAULT ''`,
 }
 
-// GetSession retrieves a session by ID.
-func (db *DB) GetSession(id string) (*Session, error) {
-	row := db.conn.QueryRow(`
-		SELECT id, wallet_id, channel_id, guest_address, host_address, funding_ckb, balance_ckb, spent_ckb, created_at, expires_at, status, settled_at, mac_address, ip_address
-		FROM sessions WHERE id = ?
-	`, id)
+func migrateColumns(conn *sql.DB) error {
+	for _, stmt := range columnMigrations {
+		if _, err := conn.Exec(stmt); err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+			return fmt.Errorf("migration failed (%s): %w", stmt, err)
+		}
+	}
+	return nil
+}
+
+// sessionColumns is the column list used when scanning into a Session.
+const sessionColumns = `id, wallet_id, channel_id, guest_address, host_address, funding_ckb, balance_ckb, spent_ckb, created_at, expires_at, status, settled_at, mac_address, ip_address, bytes_in, bytes_out, disputed_at, dispute_tx_hash, resolved_at, resolution_tx_hash, last_heartbeat_at, sender_address, expiry_notified_at, last_heartbeat_success, peer_offline_since, token_jti, settle_tx_hash`
 
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanSession scans a row selected with sessionColumns into a Session.
+func scanSession(row rowScanner) (*Session, error) {
 	s := &Session{}
-	var walletID, channelID, hostAddress, macAddr, ipAddr sql.NullString
-	var settledAt sql.NullTime
-	err := row.Scan(&s.ID, &walletID, &channelID, &s.GuestAddress, &hostAddress, &s.FundingCKB, &s.BalanceCKB, &s.SpentCKB, &s.CreatedAt, &s.ExpiresAt, &s.Status, &settledAt, &macAddr, &ipAddr)
-	if err != nil {
+	var walletID, hostAddress, macAddr, ipAddr sql.NullString
+	var disputeTx, resolutionTx, senderAddr, tokenJTI, settleTx sql.NullString
+	var settledAt, disputedAt, resolvedAt, lastHeartbeatAt, expiryNotifiedAt sql.NullTime
+	var heartbeatSuccess, peerOfflineSince sql.NullTime
+	var bytesIn, bytesOut sql.NullInt64
+	if err := row.Scan(&s.ID, &walletID, &s.ChannelID, &s.GuestAddress, &hostAddress, &s.FundingCKB, &s.BalanceCKB, &s.SpentCKB, &s.CreatedAt, &s.ExpiresAt, &s.Status, &settledAt, &macAddr, &ipAddr, &bytesIn, &bytesOut, &disputedAt, &disputeTx, &resolvedAt, &resolutionTx, &lastHeartbeatAt, &senderAddr, &expiryNotifiedAt, &heartbeatSuccess, &peerOfflineSince, &tokenJTI, &settleTx); err != nil {
 		return nil, err
 	}
-	if walletID.Valid {
-		s.WalletID = walletID.String
+	s.WalletID = walletID.String
+	s.HostAddress = hostAddress.String
+	s.MACAddress = macAddr.String
+	s.IPAddress = ipAddr.String
+	s.BytesIn = bytesIn.Int64
+	s.BytesOut = bytesOut.Int64
+	s.DisputeTxHash = disputeTx.String
+	s.ResolutionTxHash = resolutionTx.String
+	s.SenderAddress = senderAddr.String
+	s.TokenJTI = tokenJTI.String
+	s.SettleTxHash = settleTx.String
+	if settledAt.Valid {
+		s.SettledAt = &settledAt.Time
 	}
-	if channelID.Valid {
-		s.ChannelID = channelID.String
+	if disputedAt.Valid {
+		s.DisputedAt = &disputedAt.Time
 	}
-	if hostAddress.Valid {
-		s.HostAddress = hostAddress.String
+	if resolvedAt.Valid {
+		s.ResolvedAt = &resolvedAt.Time
 	}
-	if settledAt.Valid {
-		s.SettledAt = &settledAt.Time
+	if lastHeartbeatAt.Valid {
+		s.LastHeartbeatAt = &lastHeartbeatAt.Time
 	}
-	if macAddr.Valid {
-		s.MACAddress = macAddr.String
+	if expiryNotifiedAt.Valid {
+		s.ExpiryNotifiedAt = &expiryNotifiedAt.Time
 	}
-	if ipAddr.Valid {
-		s.IPAddress = ipAddr.String
+	s.LastHeartbeatSuccess = heartbeatSuccess.Time
+	if peerOfflineSince.Valid {
+		s.PeerOfflineSince = &peerOfflineSince.Time
 	}
 	return s, nil
 }
 
+// scanSessions collects all rows selected with sessionColumns.
+func scanSessions(rows *sql.Rows) ([]*Session, error) {
+	defer rows.Close()
+
+	var sessions []*Session
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// CreateSession inserts a new session.
+func (db *DB) CreateSession(s *Session) error {
+	_, err := db.conn.Exec(`
+		INSERT INTO sessions (id, wallet_id, channel_id, guest_address, host_address, funding_ckb, balance_ckb, spent_ckb, created_at, expires_at, status, settled_at, mac_address, ip_address, sender_address)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, s.ID, s.WalletID, s.ChannelID, s.GuestAddress, s.HostAddress, s.FundingCKB, s.BalanceCKB, s.SpentCKB, s.CreatedAt, s.ExpiresAt, s.Status, s.SettledAt, s.MACAddress, s.IPAddress, s.SenderAddress)
+	return err
+}
+
+// GetSession retrieves a session by ID.
+func (db *DB) GetSession(id string) (*Session, error) {
+	return scanSession(db.conn.QueryRow(`SELECT `+sessionColumns+` FROM sessions WHERE id = ?`, id))
+}
+
 // GetSessionByWalletID retrieves a session by wallet ID.
 func (db *DB) GetSessionByWalletID(walletID string) (*Session, error) {
-	row := db.conn.QueryRow(`
-		SELECT id, wallet_id, channel_id, guest_address, host_address, funding_ckb, balance_ckb, spent_ckb, created_at, expires_at, status, settled_at, mac_address, ip_address
-		FROM sessions WHERE wallet_id = ?
-	`, walletID)
+	return scanSession(db.conn.QueryRow(`SELECT `+sessionColumns+` FROM sessions WHERE wallet_id = ?`, walletID))
+}
 
-	s := &Session{}
-	var wID, channelID, hostAddress, macAddr, ipAddr sql.NullString
-	var settledAt sql.NullTime
-	err := row.Scan(&s.ID, &wID, &channelID, &s.GuestAddress, &hostAddress, &s.FundingCKB, &s.BalanceCKB, &s.SpentCKB, &s.CreatedAt, &s.ExpiresAt, &s.Status, &settledAt, &macAddr, &ipAddr)
-	if err != nil {
-		return nil, err
-	}
-	if wID.Valid {
-		s.WalletID = wID.String
-	}
-	if channelID.Valid {
-		s.ChannelID = channelID.String
-	}
-	if hostAddress.Valid {
-		s.HostAddress = hostAddress.String
-	}
-	if settledAt.Valid {
-		s.SettledAt = &settledAt.Time
-	}
-	if macAddr.Valid {
-		s.MACAddress = macAddr.String
-	}
-	if ipAddr.Valid {
-		s.IPAddress = ipAddr.String
+// GetSessionByChannelID retrieves the session funded through a channel.
+func (db *DB) GetSessionByChannelID(channelID perun.ChannelID) (*Session, error) {
+	return scanSession(db.conn.QueryRow(`SELECT `+sessionColumns+` FROM sessions WHERE channel_id = ?`, channelID))
+}
+
+// GetSessionByToken retrieves the session whose last access token has the
+// given ID (jti claim). It returns sql.ErrNoRows if none does.
+func (db *DB) GetSessionByToken(tokenJTI string) (*Session, error) {
+	if tokenJTI == "" {
+		return nil, sql.ErrNoRows
 	}
-	return s, nil
+	return scanSession(db.conn.QueryRow(`SELECT `+sessionColumns+` FROM sessions WHERE token_jti = ?`, tokenJTI))
+}
+
+// UpdateSessionTokenJTI records the ID of the access token last issued for
+// a session.
+func (db *DB) UpdateSessionTokenJTI(id, tokenJTI string) error {
+	_, err := db.conn.Exec(`UPDATE sessions SET token_jti = ? WHERE id = ?`, tokenJTI, id)
+	return err
 }
 
 // ListSessions returns all sessions, optionally filtered by status.
@@ -220,50 +456,20 @@ func (db *DB) ListSessions(status string) ([]*Session, error) {
 	var err error
 
 	if status != "" {
-		rows, err = db.conn.Query(`
-			SELECT id, wallet_id, channel_id, guest_address, host_address, funding_ckb, balance_ckb, spent_ckb, created_at, expires_at, status, settled_at, mac_address, ip_address
-			FROM sessions WHERE status = ? ORDER BY created_at DESC
-		`, status)
+		rows, err = db.QueryRead(`SELECT `+sessionColumns+` FROM sessions WHERE status = ? ORDER BY created_at DESC`, status)
 	} else {
-		rows, err = db.conn.Query(`
-			SELECT id, wallet_id, channel_id, guest_address, host_address, funding_ckb, balance_ckb, spent_ckb, created_at, expires_at, status, settled_at, mac_address, ip_address
-			FROM sessions ORDER BY created_at DESC
-		`)
+		rows, err = db.QueryRead(`SELECT ` + sessionColumns + ` FROM sessions ORDER BY created_at DESC`)
 	}
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return scanSessions(rows)
+}
 
-	var sessions []*Session
-	for rows.Next() {
-		s := &Session{}
-		var walletID, channelID, hostAddress, macAddr, ipAddr sql.NullString
-		var settledAt sql.NullTime
-		if err := rows.Scan(&s.ID, &walletID, &channelID, &s.GuestAddress, &hostAddress, &s.FundingCKB, &s.BalanceCKB, &s.SpentCKB, &s.CreatedAt, &s.ExpiresAt, &s.Status, &settledAt, &macAddr, &ipAddr); err != nil {
-			return nil, err
-		}
-		if walletID.Valid {
-			s.WalletID = walletID.String
-		}
-		if channelID.Valid {
-			s.ChannelID = channelID.String
-		}
-		if hostAddress.Valid {
-			s.HostAddress = hostAddress.String
-		}
-		if settledAt.Valid {
-			s.SettledAt = &settledAt.Time
-		}
-		if macAddr.Valid {
-			s.MACAddress = macAddr.String
-		}
-		if ipAddr.Valid {
-			s.IPAddress = ipAddr.String
-		}
-		sessions = append(sessions, s)
-	}
-	return sessions, nil
+// UpdateSessionSenderAddress records where the session's refund goes.
+func (db *DB) UpdateSessionSenderAddress(id, senderAddress string) error {
+	_, err := db.conn.Exec(`UPDATE sessions SET sender_address = ? WHERE id = ?`, senderAddress, id)
+	return err
 }
 
 // UpdateSessionStatus updates the status of a session.
@@ -278,15 +484,73 @@ func (db *DB) UpdateSessionMAC(id, macAddress, ipAddress string) error {
 	return err
 }
 
+// ErrTransferRejected is returned by TransferSession when the session isn't
+// active or the target wallet can't take it over.
+var ErrTransferRejected = errors.New("session transfer rejected")
+
+// TransferSession moves active session sessionID onto the device of wallet
+// toWalletID: the session takes the new wallet's ID, address, MAC and IP,
+// the old wallet becomes transferred and the new one active. The target
+// wallet must be unused: still created, without a session, and with a MAC
+// to authorize. The wallets keep their session_id so the old wallet, whose
+// key funded the channel, still settles and refunds it. It returns the
+// session as it was before the transfer and the target wallet. Unknown
+// sessions and wallets return an error wrapping sql.ErrNoRows.
+func (db *DB) TransferSession(sessionID, toWalletID string) (*Session, *GuestWallet, error) {
+	var sess *Session
+	var to *GuestWallet
+	err := db.Transaction(func(tx *DB) error {
+		var err error
+		sess, err = tx.GetSession(sessionID)
+		if err != nil {
+			return fmt.Errorf("failed to get session %s: %w", sessionID, err)
+		}
+		if sess.Status != "active" {
+			return fmt.Errorf("%w: session is %s", ErrTransferRejected, sess.Status)
+		}
+		to, err = tx.GetGuestWallet(toWalletID)
+		if err != nil {
+			return fmt.Errorf("failed to get wallet %s: %w", toWalletID, err)
+		}
+		switch {
+		case to.ID == sess.WalletID:
+			return fmt.Errorf("%w: session already belongs to wallet %s", ErrTransferRejected, to.ID)
+		case to.Status != "created" || to.SessionID != "":
+			return fmt.Errorf("%w: wallet %s is already in use", ErrTransferRejected, to.ID)
+		case to.MACAddress == "":
+			return fmt.Errorf("%w: wallet %s has no MAC address", ErrTransferRejected, to.ID)
+		}
+
+		if _, err := tx.conn.Exec(`
+			UPDATE sessions SET wallet_id = ?, guest_address = ?, mac_address = ?, ip_address = ? WHERE id = ?
+		`, to.ID, to.Address, to.MACAddress, to.IPAddress, sess.ID); err != nil {
+			return fmt.Errorf("failed to update session: %w", err)
+		}
+		if sess.WalletID != "" {
+			if err := tx.UpdateWalletStatus(sess.WalletID, "transferred"); err != nil {
+				return fmt.Errorf("failed to update wallet %s: %w", sess.WalletID, err)
+			}
+		}
+		if err := tx.UpdateWalletStatus(to.ID, "active"); err != nil {
+			return fmt.Errorf("failed to update wallet %s: %w", to.ID, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return sess, to, nil
+}
+
 // UpdateSessionChannel updates the channel ID and status.
-func (db *DB) UpdateSessionChannel(id, channelID, status string) error {
+func (db *DB) UpdateSessionChannel(id string, channelID perun.ChannelID, status string) error {
 	_, err := db.conn.Exec(`UPDATE sessions SET channel_id = ?, status = ? WHERE id = ?`, channelID, status, id)
 	return err
 }
 
 // UpdateSessionChannelAndActivate updates channel ID, status to active, and starts the timer.
 // This should be called when the Perun channel is successfully opened.
-func (db *DB) UpdateSessionChannelAndActivate(id, channelID string, duration time.Duration) error {
+func (db *DB) UpdateSessionChannelAndActivate(id string, channelID perun.ChannelID, duration time.Duration) error {
 	expiresAt := time.Now().Add(duration)
 	_, err := db.conn.Exec(`UPDATE sessions SET channel_id = ?, status = 'active', expires_at = ? WHERE id = ?`, channelID, expiresAt, id)
 	return err
@@ -298,6 +562,75 @@ func (db *DB) UpdateSessionBalance(id string, balanceCKB, spentCKB int64) error
 	return err
 }
 
+// UpdateSessionUsage records the data usage reported by the router.
+func (db *DB) UpdateSessionUsage(id string, bytesIn, bytesOut int64) error {
+	_, err := db.conn.Exec(`UPDATE sessions SET bytes_in = ?, bytes_out = ? WHERE id = ?`, bytesIn, bytesOut, id)
+	return err
+}
+
+// UpdateSessionHeartbeat records a keep-alive from the guest's session page.
+func (db *DB) UpdateSessionHeartbeat(id string, at time.Time) error {
+	_, err := db.conn.Exec(`UPDATE sessions SET last_heartbeat_at = ? WHERE id = ?`, at, id)
+	return err
+}
+
+// RecordPeerHeartbeat records a channel heartbeat the session's peer
+// answered at, which also ends any offline period.
+func (db *DB) RecordPeerHeartbeat(id string, at time.Time) error {
+	_, err := db.conn.Exec(`UPDATE sessions SET last_heartbeat_success = ?, peer_offline_since = NULL WHERE id = ?`, at, id)
+	return err
+}
+
+// MarkPeerOffline records a channel heartbeat the session's peer missed at.
+// An offline period already under way keeps its start.
+func (db *DB) MarkPeerOffline(id string, at time.Time) error {
+	_, err := db.conn.Exec(`UPDATE sessions SET peer_offline_since = COALESCE(peer_offline_since, ?) WHERE id = ?`, at, id)
+	return err
+}
+
+// ListStaleSessions returns active sessions whose last heartbeat is before
+// cutoff. Sessions that never sent a heartbeat are not included.
+func (db *DB) ListStaleSessions(cutoff time.Time) ([]*Session, error) {
+	rows, err := db.conn.Query(`SELECT `+sessionColumns+` FROM sessions WHERE status = 'active' AND last_heartbeat_at IS NOT NULL AND last_heartbeat_at < ?`, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	return scanSessions(rows)
+}
+
+// GetSessionsExpiringWithin returns active sessions that expire before
+// now + d, soonest first. Sessions already past their expiry are included.
+func (db *DB) GetSessionsExpiringWithin(d time.Duration) ([]*Session, error) {
+	rows, err := db.conn.Query(`SELECT `+sessionColumns+` FROM sessions WHERE status = 'active' AND expires_at < ? ORDER BY expires_at`, time.Now().Add(d))
+	if err != nil {
+		return nil, err
+	}
+	return scanSessions(rows)
+}
+
+// UpdateSessionExpiryNotified records when the guest was warned that the
+// session is about to expire.
+func (db *DB) UpdateSessionExpiryNotified(id string, at time.Time) error {
+	_, err := db.conn.Exec(`UPDATE sessions SET expiry_notified_at = ? WHERE id = ?`, at, id)
+	return err
+}
+
+// RecordDispute records that a channel dispute was registered for a session.
+// Recording it again keeps the first time, and an empty txHash keeps the
+// hash already stored, so the hash can be filled in once it is known.
+func (db *DB) RecordDispute(sessionID, txHash string) error {
+	_, err := db.conn.Exec(`UPDATE sessions SET disputed_at = COALESCE(disputed_at, ?),
+		dispute_tx_hash = CASE WHEN ? = '' THEN dispute_tx_hash ELSE ? END WHERE id = ?`,
+		time.Now(), txHash, txHash, sessionID)
+	return err
+}
+
+// RecordResolution records that a session's channel dispute was resolved.
+func (db *DB) RecordResolution(sessionID, txHash string) error {
+	_, err := db.conn.Exec(`UPDATE sessions SET resolved_at = ?, resolution_tx_hash = ? WHERE id = ?`, time.Now(), txHash, sessionID)
+	return err
+}
+
 // SettleSession marks a session as settled.
 func (db *DB) SettleSession(id string) error {
 	now := time.Now()
@@ -305,124 +638,188 @@ func (db *DB) SettleSession(id string) error {
 	return err
 }
 
-// CreateGuestWallet inserts a new guest wallet.
+// RecordSettlementTx records the transaction that closed a session's
+// channel.
+func (db *DB) RecordSettlementTx(id, txHash string) error {
+	_, err := db.conn.Exec(`UPDATE sessions SET settle_tx_hash = ? WHERE id = ?`, txHash, id)
+	return err
+}
+
+// CreateGuestWallet inserts a new guest wallet. Returns ErrDuplicateWallet
+// if a wallet with the same ID, address or private key already exists.
 func (db *DB) CreateGuestWallet(w *GuestWallet) error {
-	_, err := db.conn.Exec(`
-		INSERT INTO guest_wallets (id, address, private_key_hex, funding_ckb, balance_ckb, created_at, funded_at, session_id, status, sender_address, mac_address, ip_address)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, w.ID, w.Address, w.PrivateKeyHex, w.FundingCKB, w.BalanceCKB, w.CreatedAt, w.FundedAt, w.SessionID, w.Status, w.SenderAddress, w.MACAddress, w.IPAddress)
+	var keyHash interface{}
+	if w.PrivateKeyHash != "" {
+		keyHash = w.PrivateKeyHash
+	}
+	privateKey, err := db.storedKey(w.PrivateKeyHex)
+	if err != nil {
+		return err
+	}
+	_, err = db.conn.Exec(`
+		INSERT INTO guest_wallets (id, address, private_key_hex, private_key_hash, funding_ckb, balance_ckb, created_at, funded_at, session_id, status, sender_address, mac_address, ip_address, access_point, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, w.ID, w.Address, privateKey, keyHash, w.FundingCKB, w.BalanceCKB, w.CreatedAt, w.FundedAt, w.SessionID, w.Status, w.SenderAddress, w.MACAddress, w.IPAddress, w.AccessPoint, expiryTime(w.ExpiresAt))
+	if err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		return fmt.Errorf("%w: %v", ErrDuplicateWallet, err)
+	}
 	return err
 }
 
+// GetWalletByPrivateKeyHash retrieves a guest wallet by the hash of its
+// private key.
+func (db *DB) GetWalletByPrivateKeyHash(hash string) (*GuestWallet, error) {
+	return db.scanWallet(db.conn.QueryRow(`SELECT `+walletColumns+` FROM guest_wallets WHERE private_key_hash = ?`, hash))
+}
+
 // GetGuestWallet retrieves a guest wallet by ID.
 func (db *DB) GetGuestWallet(id string) (*GuestWallet, error) {
-	row := db.conn.QueryRow(`
-		SELECT id, address, private_key_hex, funding_ckb, balance_ckb, created_at, funded_at, session_id, status, sender_address, mac_address, ip_address
-		FROM guest_wallets WHERE id = ?
-	`, id)
+	return db.scanWallet(db.conn.QueryRow(`SELECT `+walletColumns+` FROM guest_wallets WHERE id = ?`, id))
+}
 
+// walletColumns is the column list used when scanning into a GuestWallet.
+const walletColumns = `id, address, private_key_hex, private_key_hash, funding_ckb, balance_ckb, created_at, funded_at, session_id, status, sender_address, mac_address, ip_address, last_checked_at, expires_at, refund_status, refund_tx_hash, refund_amount, refund_updated_at, access_point`
+
+// scanWallet scans a row selected with walletColumns into a GuestWallet,
+// decrypting its private key.
+func (db *DB) scanWallet(row rowScanner) (*GuestWallet, error) {
 	w := &GuestWallet{}
-	var fundedAt sql.NullTime
-	var sessionID, senderAddr, macAddr, ipAddr sql.NullString
-	err := row.Scan(&w.ID, &w.Address, &w.PrivateKeyHex, &w.FundingCKB, &w.BalanceCKB, &w.CreatedAt, &fundedAt, &sessionID, &w.Status, &senderAddr, &macAddr, &ipAddr)
+	var fundedAt, lastCheckedAt, expiresAt, refundUpdatedAt sql.NullTime
+	var keyHash, sessionID, senderAddr, macAddr, ipAddr, refundStatus, refundTxHash, accessPoint sql.NullString
+	var refundAmount sql.NullInt64
+	err := row.Scan(&w.ID, &w.Address, &w.PrivateKeyHex, &keyHash, &w.FundingCKB, &w.BalanceCKB, &w.CreatedAt, &fundedAt, &sessionID, &w.Status, &senderAddr, &macAddr, &ipAddr, &lastCheckedAt, &expiresAt,
+		&refundStatus, &refundTxHash, &refundAmount, &refundUpdatedAt, &accessPoint)
 	if err != nil {
 		return nil, err
 	}
+	if expiresAt.Valid {
+		w.ExpiresAt = expiresAt.Time
+	}
 	if fundedAt.Valid {
 		w.FundedAt = &fundedAt.Time
 	}
-	if sessionID.Valid {
-		w.SessionID = sessionID.String
-	}
-	if senderAddr.Valid {
-		w.SenderAddress = senderAddr.String
+	if lastCheckedAt.Valid {
+		w.LastCheckedAt = &lastCheckedAt.Time
 	}
-	if macAddr.Valid {
-		w.MACAddress = macAddr.String
+	if refundUpdatedAt.Valid {
+		w.RefundUpdatedAt = &refundUpdatedAt.Time
 	}
-	if ipAddr.Valid {
-		w.IPAddress = ipAddr.String
+	w.PrivateKeyHash = keyHash.String
+	w.SessionID = sessionID.String
+	w.SenderAddress = senderAddr.String
+	w.MACAddress = macAddr.String
+	w.IPAddress = ipAddr.String
+	w.AccessPoint = accessPoint.String
+	w.RefundStatus = refundStatus.String
+	w.RefundTxHash = refundTxHash.String
+	w.RefundAmount = refundAmount.Int64
+	if w.PrivateKeyHex, err = db.loadedKey(w.PrivateKeyHex); err != nil {
+		return nil, fmt.Errorf("wallet %s: %w", w.ID, err)
 	}
 	return w, nil
 }
 
+// scanWallets scans every row selected with walletColumns.
+func (db *DB) scanWallets(rows *sql.Rows) ([]*GuestWallet, error) {
+	defer rows.Close()
+
+	var wallets []*GuestWallet
+	for rows.Next() {
+		w, err := db.scanWallet(rows)
+		if err != nil {
+			return nil, err
+		}
+		wallets = append(wallets, w)
+	}
+	return wallets, rows.Err()
+}
+
 // GetGuestWalletByAddress retrieves a guest wallet by CKB address.
 func (db *DB) GetGuestWalletByAddress(address string) (*GuestWallet, error) {
-	row := db.conn.QueryRow(`
-		SELECT id, address, private_key_hex, funding_ckb, balance_ckb, created_at, funded_at, session_id, status, sender_address, mac_address, ip_address
-		FROM guest_wallets WHERE address = ?
-	`, address)
+	return db.scanWallet(db.conn.QueryRow(`SELECT `+walletColumns+` FROM guest_wallets WHERE address = ?`, address))
+}
 
-	w := &GuestWallet{}
-	var fundedAt sql.NullTime
-	var sessionID, senderAddr, macAddr, ipAddr sql.NullString
-	err := row.Scan(&w.ID, &w.Address, &w.PrivateKeyHex, &w.FundingCKB, &w.BalanceCKB, &w.CreatedAt, &fundedAt, &sessionID, &w.Status, &senderAddr, &macAddr, &ipAddr)
+// ListGuestWallets returns every guest wallet, oldest first.
+func (db *DB) ListGuestWallets() ([]*GuestWallet, error) {
+	rows, err := db.conn.Query(`SELECT ` + walletColumns + ` FROM guest_wallets ORDER BY created_at ASC, id ASC`)
 	if err != nil {
 		return nil, err
 	}
-	if fundedAt.Valid {
-		w.FundedAt = &fundedAt.Time
+	return db.scanWallets(rows)
+}
+
+// ListPendingWallets returns wallets waiting for funding.
+func (db *DB) ListPendingWallets() ([]*GuestWallet, error) {
+	return db.GetWalletsByStatus("created")
+}
+
+// GetWalletsByStatus returns wallets in any of the given statuses, oldest
+// first. No statuses matches no wallets.
+func (db *DB) GetWalletsByStatus(statuses ...string) ([]*GuestWallet, error) {
+	return db.GetWalletsByStatusPaginated(statuses, 0, 0)
+}
+
+// GetWalletsByStatusPaginated is GetWalletsByStatus returning at most limit
+// wallets after skipping offset. A non-positive limit returns them all.
+func (db *DB) GetWalletsByStatusPaginated(statuses []string, limit, offset int) ([]*GuestWallet, error) {
+	if len(statuses) == 0 {
+		return nil, nil
 	}
-	if sessionID.Valid {
-		w.SessionID = sessionID.String
+
+	query := `SELECT ` + walletColumns + ` FROM guest_wallets WHERE status IN (` + placeholders(len(statuses)) + `) ORDER BY created_at ASC`
+	args := make([]interface{}, 0, len(statuses)+2)
+	for _, status := range statuses {
+		args = append(args, status)
 	}
-	if senderAddr.Valid {
-		w.SenderAddress = senderAddr.String
+	if limit > 0 {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, limit, offset)
+	} else if offset > 0 {
+		query += ` LIMIT -1 OFFSET ?`
+		args = append(args, offset)
 	}
-	if macAddr.Valid {
-		w.MACAddress = macAddr.String
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
 	}
-	if ipAddr.Valid {
-		w.IPAddress = ipAddr.String
+	return db.scanWallets(rows)
+}
+
+// placeholders returns n comma-separated ? parameters for an IN clause.
+func placeholders(n int) string {
+	if n <= 0 {
+		return ""
 	}
-	return w, nil
+	return strings.Repeat("?, ", n-1) + "?"
 }
 
-// ListPendingWallets returns wallets waiting for funding.
-func (db *DB) ListPendingWallets() ([]*GuestWallet, error) {
+// ListWalletsWithBalanceAbove returns wallets that still hold at least minCKB
+// and have not been withdrawn, richest first. Wallets with an open channel
+// or whose session is still running are excluded since their funds are in use.
+func (db *DB) ListWalletsWithBalanceAbove(minCKB int64) ([]*GuestWallet, error) {
 	rows, err := db.conn.Query(`
-		SELECT id, address, private_key_hex, funding_ckb, balance_ckb, created_at, funded_at, session_id, status, sender_address, mac_address, ip_address
-		FROM guest_wallets WHERE status = 'created' ORDER BY created_at ASC
-	`)
+		SELECT `+walletColumns+` FROM guest_wallets WHERE id IN (
+			SELECT w.id FROM guest_wallets w
+			LEFT JOIN sessions s ON s.id = w.session_id
+			WHERE w.balance_ckb >= ?
+				AND w.status NOT IN ('withdrawn', 'channel_open')
+				AND (s.id IS NULL OR s.status NOT IN ('active', 'channel_opening'))
+		)
+		ORDER BY balance_ckb DESC, created_at ASC
+	`, minCKB)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var wallets []*GuestWallet
-	for rows.Next() {
-		w := &GuestWallet{}
-		var fundedAt sql.NullTime
-		var sessionID, senderAddr, macAddr, ipAddr sql.NullString
-		if err := rows.Scan(&w.ID, &w.Address, &w.PrivateKeyHex, &w.FundingCKB, &w.BalanceCKB, &w.CreatedAt, &fundedAt, &sessionID, &w.Status, &senderAddr, &macAddr, &ipAddr); err != nil {
-			return nil, err
-		}
-		if fundedAt.Valid {
-			w.FundedAt = &fundedAt.Time
-		}
-		if sessionID.Valid {
-			w.SessionID = sessionID.String
-		}
-		if senderAddr.Valid {
-			w.SenderAddress = senderAddr.String
-		}
-		if macAddr.Valid {
-			w.MACAddress = macAddr.String
-		}
-		if ipAddr.Valid {
-			w.IPAddress = ipAddr.String
-		}
-		wallets = append(wallets, w)
-	}
-	return wallets, nil
+	return db.scanWallets(rows)
 }
 
 // UpdateWalletFunded marks a wallet as funded.
 func (db *DB) UpdateWalletFunded(id string, balanceCKB int64, sessionID string) error {
 	now := time.Now()
 	_, err := db.conn.Exec(`
-		UPDATE guest_wallets SET balance_ckb = ?, funded_at = ?, session_id = ?, status = 'funded' WHERE id = ?
-	`, balanceCKB, now, sessionID, id)
+		UPDATE guest_wallets SET balance_ckb = ?, funded_at = ?, session_id = ?, status = 'funded', updated_at = ? WHERE id = ?
+	`, balanceCKB, now, sessionID, now, id)
 	return err
 }
 
@@ -434,65 +831,157 @@ func (db *DB) UpdateWalletBalance(id string, balanceCKB int64) error {
 
 // UpdateWalletStatus updates the wallet status.
 func (db *DB) UpdateWalletStatus(id, status string) error {
-	_, err := db.conn.Exec(`UPDATE guest_wallets SET status = ? WHERE id = ?`, status, id)
+	_, err := db.conn.Exec(`UPDATE guest_wallets SET status = ?, updated_at = ? WHERE id = ?`, status, time.Now(), id)
 	return err
 }
 
+// ResetWalletStatus sets a wallet's status back to status if it is still
+// from. It reports whether the wallet was changed.
+func (db *DB) ResetWalletStatus(id, from, status string) (bool, error) {
+	result, err := db.conn.Exec(`UPDATE guest_wallets SET status = ?, updated_at = ? WHERE id = ? AND status = ?`,
+		status, time.Now(), id, from)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
 // UpdateWalletSenderAddress updates the sender address for refund.
 func (db *DB) UpdateWalletSenderAddress(id, senderAddress string) error {
 	_, err := db.conn.Exec(`UPDATE guest_wallets SET sender_address = ? WHERE id = ?`, senderAddress, id)
 	return err
 }
 
-// GetWalletBySessionID retrieves a guest wallet by session ID.
-func (db *DB) GetWalletBySessionID(sessionID string) (*GuestWallet, error) {
-	row := db.conn.QueryRow(`
-		SELECT id, address, private_key_hex, funding_ckb, balance_ckb, created_at, funded_at, session_id, status, sender_address, mac_address, ip_address
-		FROM guest_wallets WHERE session_id = ?
-	`, sessionID)
+// UpdateWalletRefund records the state of a wallet's refund. An empty
+// txHash or zero amount keeps the values already stored, so a failed retry
+// doesn't erase the hash of an earlier submission.
+func (db *DB) UpdateWalletRefund(id, status, txHash string, amount int64) error {
+	_, err := db.conn.Exec(`
+		UPDATE guest_wallets SET
+			refund_status = ?,
+			refund_tx_hash = CASE WHEN ? = '' THEN refund_tx_hash ELSE ? END,
+			refund_amount = CASE WHEN ? = 0 THEN refund_amount ELSE ? END,
+			refund_updated_at = ?
+		WHERE id = ?
+	`, status, txHash, txHash, amount, amount, time.Now(), id)
+	return err
+}
 
-	w := &GuestWallet{}
-	var fundedAt sql.NullTime
-	var sessID, senderAddr, macAddr, ipAddr sql.NullString
-	err := row.Scan(&w.ID, &w.Address, &w.PrivateKeyHex, &w.FundingCKB, &w.BalanceCKB, &w.CreatedAt, &fundedAt, &sessID, &w.Status, &senderAddr, &macAddr, &ipAddr)
+// UpdateWalletLastChecked records when the wallet was last checked for funding.
+func (db *DB) UpdateWalletLastChecked(id string, checkedAt time.Time) error {
+	_, err := db.conn.Exec(`UPDATE guest_wallets SET last_checked_at = ? WHERE id = ?`, checkedAt, id)
+	return err
+}
+
+// ExpireStaleWallets marks created wallets past their expiry as expired
+// and returns how many were expired. Wallets that checker reports holding
+// funds are left for the funding detector, so a late payment isn't lost.
+func (db *DB) ExpireStaleWallets(ctx context.Context, checker BalanceChecker) (int, error) {
+	rows, err := db.conn.Query(`SELECT `+walletColumns+` FROM guest_wallets
+		WHERE status = 'created' AND expires_at IS NOT NULL AND expires_at <= ?
+		ORDER BY expires_at`, time.Now().UTC())
 	if err != nil {
-		return nil, err
+		return 0, fmt.Errorf("failed to list stale wallets: %w", err)
 	}
-	if fundedAt.Valid {
-		w.FundedAt = &fundedAt.Time
+	wallets, err := db.scanWallets(rows)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list stale wallets: %w", err)
+	}
+
+	var expired int
+	var errs []error
+	for _, w := range wallets {
+		if err := ctx.Err(); err != nil {
+			return expired, errors.Join(append(errs, err)...)
+		}
+		balance, err := checker.CheckBalance(ctx, w.Address)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("wallet %s: %w", w.ID, err))
+			continue
+		}
+		if balance > 0 {
+			continue
+		}
+		result, err := db.conn.Exec(`UPDATE guest_wallets SET status = 'expired' WHERE id = ? AND status = 'created'`, w.ID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("wallet %s: %w", w.ID, err))
+			continue
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			expired++
+		}
 	}
-	if sessID.Valid {
-		w.SessionID = sessID.String
+	return expired, errors.Join(errs...)
+}
+
+// ListExpiredWallets returns expired wallets whose expiry falls between from
+// and to, oldest first. Zero bounds are ignored.
+func (db *DB) ListExpiredWallets(from, to time.Time) ([]*GuestWallet, error) {
+	query := `SELECT ` + walletColumns + ` FROM guest_wallets WHERE status = 'expired'`
+	var args []interface{}
+	if !from.IsZero() {
+		query += ` AND expires_at >= ?`
+		args = append(args, from.UTC())
 	}
-	if senderAddr.Valid {
-		w.SenderAddress = senderAddr.String
+	if !to.IsZero() {
+		query += ` AND expires_at <= ?`
+		args = append(args, to.UTC())
 	}
-	if macAddr.Valid {
-		w.MACAddress = macAddr.String
+	query += ` ORDER BY expires_at ASC`
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
 	}
-	if ipAddr.Valid {
-		w.IPAddress = ipAddr.String
+	return db.scanWallets(rows)
+}
+
+// expiryTime returns t in UTC for storage, or nil for the zero time, so
+// expiry comparisons don't depend on the local time zone.
+func expiryTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
 	}
-	return w, nil
+	return t.UTC()
+}
+
+// GetWalletBySessionID retrieves a guest wallet by session ID.
+func (db *DB) GetWalletBySessionID(sessionID string) (*GuestWallet, error) {
+	return db.scanWallet(db.conn.QueryRow(`SELECT `+walletColumns+` FROM guest_wallets WHERE session_id = ?`, sessionID))
 }
 
 // GetStats returns session statistics.
 func (db *DB) GetStats() (total int, active int, totalEarned int64, err error) {
-	row := db.conn.QueryRow(`SELECT COUNT(*) FROM sessions`)
+	conn := db.readConn()
+	row := conn.QueryRow(`SELECT COUNT(*) FROM sessions`)
 	if err = row.Scan(&total); err != nil {
 		return
 	}
 
-	row = db.conn.QueryRow(`SELECT COUNT(*) FROM sessions WHERE status = 'active'`)
+	row = conn.QueryRow(`SELECT COUNT(*) FROM sessions WHERE status = 'active'`)
 	if err = row.Scan(&active); err != nil {
 		return
 	}
 
-	row = db.conn.QueryRow(`SELECT COALESCE(SUM(spent_ckb), 0) FROM sessions`)
+	row = conn.QueryRow(`SELECT COALESCE(SUM(spent_ckb), 0) FROM sessions`)
 	err = row.Scan(&totalEarned)
 	return
 }
 
+// GetSessionCountByStatus counts sessions with the given status without
+// loading them.
+func (db *DB) GetSessionCountByStatus(status string) (int, error) {
+	var count int
+	err := db.readConn().QueryRow(`SELECT COUNT(*) FROM sessions WHERE status = ?`, status).Scan(&count)
+	return count, err
+}
+
+// GetActiveSessionCount counts active sessions without loading them.
+func (db *DB) GetActiveSessionCount() (int, error) {
+	return db.GetSessionCountByStatus("active")
+}
+
 // ExtendSession extends the session expiry time and updates balances.
 func (db *DB) ExtendSession(id string, additionalMinutes int64, spentCKB int64) error {
 	_, err := db.conn.Exec(`
@@ -505,6 +994,23 @@ func (db *DB) ExtendSession(id string, additionalMinutes int64, spentCKB int64)
 	return err
 }
 
+// UpdateSessionExpiry sets a session's expiry to an exact time. Returns
+// sql.ErrNoRows if the session does not exist.
+func (db *DB) UpdateSessionExpiry(sessionID string, newExpiry time.Time) error {
+	result, err := db.conn.Exec(`UPDATE sessions SET expires_at = ? WHERE id = ?`, newExpiry, sessionID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
 // CleanupExpired marks expired sessions.
 func (db *DB) CleanupExpired() (int64, error) {
 	result, err := db.conn.Exec(`
@@ -517,7 +1023,16 @@ func (db *DB) CleanupExpired() (int64, error) {
 	return result.RowsAffected()
 }
 
+// Setting keys.
+const (
+	settingRatePerHour           = "rate_per_hour"
+	settingChannelSetupCKB       = "channel_setup_ckb"
+	settingWalletTTL             = "wallet_ttl_seconds"
+	settingMaxConcurrentSessions = "max_concurrent_sessions"
+)
+
 // GetSetting retrieves a setting value by key.
+// Returns sql.ErrNoRows if it has never been set.
 func (db *DB) GetSetting(key string) (string, error) {
 	row := db.conn.QueryRow(`SELECT value FROM settings WHERE key = ?`, key)
 	var value string
@@ -528,29 +1043,104 @@ func (db *DB) GetSetting(key string) (string, error) {
 // SetSetting sets a setting value.
 func (db *DB) SetSetting(key, value string) error {
 	_, err := db.conn.Exec(`
-		INSERT INTO settings (key, value) VALUES (?, ?)
-		ON CONFLICT(key) DO UPDATE SET value = excluded.value
-	`, key, value)
+		INSERT INTO settings (key, value, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at
+	`, key, value, time.Now().UTC())
 	return err
 }
 
+// GetSettingInt returns an integer setting, or defaultVal if it's unset or
+// not an integer.
+func (db *DB) GetSettingInt(key string, defaultVal int64) int64 {
+	val, err := db.getSettingInt(key)
+	if err != nil {
+		return defaultVal
+	}
+	return val
+}
+
+// SetSettingInt sets an integer setting.
+func (db *DB) SetSettingInt(key string, val int64) error {
+	return db.SetSetting(key, strconv.FormatInt(val, 10))
+}
+
+// getSettingInt parses an integer setting, returning an error if it's
+// unset or malformed.
+func (db *DB) getSettingInt(key string) (int64, error) {
+	value, err := db.GetSetting(key)
+	if err != nil {
+		return 0, err
+	}
+	val, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s setting: %w", key, err)
+	}
+	return val, nil
+}
+
 // GetRatePerHour returns the configured rate per hour in CKB.
 func (db *DB) GetRatePerHour() (int64, error) {
-	value, err := db.GetSetting("rate_per_hour")
+	return db.GetSettingInt(settingRatePerHour, DefaultRatePerHour), nil
+}
+
+// SetRatePerHour sets the rate per hour in CKB, inserting the setting if
+// it was removed.
+func (db *DB) SetRatePerHour(rate int64) error {
+	now := time.Now().UTC()
+	result, err := db.conn.Exec(`UPDATE settings SET value = ?, updated_at = ? WHERE key = ?`,
+		strconv.FormatInt(rate, 10), now, settingRatePerHour)
 	if err != nil {
-		return DefaultRatePerHour, nil // Return default if not found
+		return fmt.Errorf("failed to update rate: %w", err)
 	}
-	var rate int64
-	_, err = fmt.Sscanf(value, "%d", &rate)
+	n, err := result.RowsAffected()
 	if err != nil {
-		return DefaultRatePerHour, nil
+		return fmt.Errorf("failed to update rate: %w", err)
+	}
+	if n == 1 {
+		return nil
+	}
+	if _, err := db.conn.Exec(`INSERT INTO settings (key, value, updated_at) VALUES (?, ?, ?)`,
+		settingRatePerHour, strconv.FormatInt(rate, 10), now); err != nil {
+		return fmt.Errorf("failed to insert rate: %w", err)
 	}
-	return rate, nil
+	return nil
 }
 
-// SetRatePerHour sets the rate per hour in CKB.
-func (db *DB) SetRatePerHour(rate int64) error {
-	return db.SetSetting("rate_per_hour", fmt.Sprintf("%d", rate))
+// GetChannelSetupCKB returns the stored channel setup reserve in CKB.
+// Returns an error if it has never been set, so callers can fall back to config.
+func (db *DB) GetChannelSetupCKB() (int64, error) {
+	return db.getSettingInt(settingChannelSetupCKB)
+}
+
+// SetChannelSetupCKB sets the channel setup reserve in CKB.
+func (db *DB) SetChannelSetupCKB(ckb int64) error {
+	return db.SetSettingInt(settingChannelSetupCKB, ckb)
+}
+
+// GetWalletTTL returns the stored guest wallet TTL.
+// Returns an error if it has never been set, so callers can fall back to config.
+func (db *DB) GetWalletTTL() (time.Duration, error) {
+	seconds, err := db.getSettingInt(settingWalletTTL)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// SetWalletTTL sets the guest wallet TTL, stored with second precision.
+func (db *DB) SetWalletTTL(ttl time.Duration) error {
+	return db.SetSettingInt(settingWalletTTL, int64(ttl/time.Second))
+}
+
+// GetMaxConcurrentSessions returns the stored limit on concurrent sessions.
+// Returns an error if it has never been set, so callers can fall back to config.
+func (db *DB) GetMaxConcurrentSessions() (int64, error) {
+	return db.getSettingInt(settingMaxConcurrentSessions)
+}
+
+// SetMaxConcurrentSessions sets the limit on concurrent sessions.
+func (db *DB) SetMaxConcurrentSessions(n int64) error {
+	return db.SetSettingInt(settingMaxConcurrentSessions, n)
 }
 
 // GetAllSettings returns all settings as a map.
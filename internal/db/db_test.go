@@ -1,7 +1,13 @@
 package db
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 )
@@ -63,6 +69,31 @@ func TestDB_CreateAndGetSession(t *testing.T) {
 	}
 }
 
+func TestDB_GetSessionByToken(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	db.CreateSession(&Session{ID: "test-1", Status: "active", ExpiresAt: time.Now().Add(time.Hour)})
+	db.CreateSession(&Session{ID: "test-2", Status: "active", ExpiresAt: time.Now().Add(time.Hour)})
+	if err := db.UpdateSessionTokenJTI("test-2", "jti-2"); err != nil {
+		t.Fatalf("UpdateSessionTokenJTI failed: %v", err)
+	}
+
+	session, err := db.GetSessionByToken("jti-2")
+	if err != nil {
+		t.Fatalf("GetSessionByToken failed: %v", err)
+	}
+	if session.ID != "test-2" || session.TokenJTI != "jti-2" {
+		t.Errorf("Expected test-2 with jti-2, got %s with %q", session.ID, session.TokenJTI)
+	}
+
+	for _, jti := range []string{"unknown", ""} {
+		if _, err := db.GetSessionByToken(jti); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetSessionByToken(%q): expected sql.ErrNoRows, got %v", jti, err)
+		}
+	}
+}
+
 func TestDB_UpdateSessionStatus(t *testing.T) {
 	db, cleanup := setupTestDB(t)
 	defer cleanup()
@@ -131,6 +162,17 @@ func TestDB_SettleSession(t *testing.T) {
 	if retrieved.Status != "settled" {
 		t.Errorf("Status: expected settled, got %s", retrieved.Status)
 	}
+	if retrieved.SettleTxHash != "" {
+		t.Errorf("Expected no settlement tx yet, got %q", retrieved.SettleTxHash)
+	}
+
+	if err := db.RecordSettlementTx("test-4", "0xclose"); err != nil {
+		t.Fatalf("RecordSettlementTx failed: %v", err)
+	}
+	retrieved, _ = db.GetSession("test-4")
+	if retrieved.SettleTxHash != "0xclose" {
+		t.Errorf("SettleTxHash: expected 0xclose, got %q", retrieved.SettleTxHash)
+	}
 }
 
 func TestDB_ListSessions(t *testing.T) {
@@ -161,6 +203,7 @@ func TestDB_CreateAndGetGuestWallet(t *testing.T) {
 		Address:       "ckt1test",
 		PrivateKeyHex: "0x123",
 		Status:        "created",
+		AccessPoint:   "lobby",
 	}
 
 	if err := db.CreateGuestWallet(wallet); err != nil {
@@ -175,6 +218,9 @@ func TestDB_CreateAndGetGuestWallet(t *testing.T) {
 	if retrieved.Address != wallet.Address {
 		t.Errorf("Address mismatch")
 	}
+	if retrieved.AccessPoint != "lobby" {
+		t.Errorf("Expected access point lobby, got %q", retrieved.AccessPoint)
+	}
 }
 
 func TestDB_GetGuestWalletByAddress(t *testing.T) {
@@ -200,6 +246,60 @@ func TestDB_GetGuestWalletByAddress(t *testing.T) {
 	}
 }
 
+func TestDB_CreateGuestWallet_DeduplicatesKey(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	const keyHash = "5f4dcc3b5aa765d61d8327deb882cf995f4dcc3b5aa765d61d8327deb882cf99"
+	ids := make([]string, 10)
+	errs := make([]error, 10)
+	var wg sync.WaitGroup
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := &GuestWallet{
+				ID:             fmt.Sprintf("dup%d", i),
+				Address:        fmt.Sprintf("ckt1dup%d", i),
+				PrivateKeyHex:  "0xabc",
+				PrivateKeyHash: keyHash,
+				Status:         "created",
+			}
+			err := db.CreateGuestWallet(w)
+			if errors.Is(err, ErrDuplicateWallet) {
+				w, err = db.GetWalletByPrivateKeyHash(keyHash)
+			}
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			ids[i] = w.ID
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("Insert %d failed: %v", i, err)
+		}
+		if ids[i] != ids[0] {
+			t.Errorf("Insert %d returned wallet %s, expected %s", i, ids[i], ids[0])
+		}
+	}
+	var count int
+	db.conn.QueryRow(`SELECT COUNT(*) FROM guest_wallets WHERE private_key_hash = ?`, keyHash).Scan(&count)
+	if count != 1 {
+		t.Errorf("Expected 1 wallet for the key, got %d", count)
+	}
+
+	// Wallets without a hash never collide
+	for _, id := range []string{"nohash1", "nohash2"} {
+		if err := db.CreateGuestWallet(&GuestWallet{ID: id, Address: "ckt1" + id, Status: "created"}); err != nil {
+			t.Errorf("CreateGuestWallet(%s) failed: %v", id, err)
+		}
+	}
+}
+
 func TestDB_ListPendingWallets(t *testing.T) {
 	db, cleanup := setupTestDB(t)
 	defer cleanup()
@@ -214,6 +314,109 @@ func TestDB_ListPendingWallets(t *testing.T) {
 	}
 }
 
+func TestDB_GetWalletsByStatus(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	now := time.Now()
+	db.CreateGuestWallet(&GuestWallet{ID: "w1", Address: "a1", PrivateKeyHex: "k1", Status: "created", CreatedAt: now.Add(-3 * time.Hour)})
+	db.CreateGuestWallet(&GuestWallet{ID: "w2", Address: "a2", PrivateKeyHex: "k2", Status: "funded", CreatedAt: now.Add(-2 * time.Hour)})
+	db.CreateGuestWallet(&GuestWallet{ID: "w3", Address: "a3", PrivateKeyHex: "k3", Status: "withdrawn", CreatedAt: now.Add(-time.Hour)})
+
+	tests := []struct {
+		name     string
+		statuses []string
+		expected []string
+	}{
+		{"none", nil, nil},
+		{"one", []string{"funded"}, []string{"w2"}},
+		{"several", []string{"created", "funded"}, []string{"w1", "w2"}},
+		{"unknown", []string{"missing"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wallets, err := db.GetWalletsByStatus(tt.statuses...)
+			if err != nil {
+				t.Fatalf("GetWalletsByStatus failed: %v", err)
+			}
+			if len(wallets) != len(tt.expected) {
+				t.Fatalf("Expected %d wallets, got %d", len(tt.expected), len(wallets))
+			}
+			for i, w := range wallets {
+				if w.ID != tt.expected[i] {
+					t.Errorf("Position %d: expected %s, got %s", i, tt.expected[i], w.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestDB_GetWalletsByStatusPaginated(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	now := time.Now()
+	db.CreateGuestWallet(&GuestWallet{ID: "w1", Address: "a1", PrivateKeyHex: "k1", Status: "created", CreatedAt: now.Add(-3 * time.Hour)})
+	db.CreateGuestWallet(&GuestWallet{ID: "w2", Address: "a2", PrivateKeyHex: "k2", Status: "funded", CreatedAt: now.Add(-2 * time.Hour)})
+	db.CreateGuestWallet(&GuestWallet{ID: "w3", Address: "a3", PrivateKeyHex: "k3", Status: "created", CreatedAt: now.Add(-time.Hour)})
+
+	wallets, err := db.GetWalletsByStatusPaginated([]string{"created", "funded"}, 1, 1)
+	if err != nil {
+		t.Fatalf("GetWalletsByStatusPaginated failed: %v", err)
+	}
+	if len(wallets) != 1 || wallets[0].ID != "w2" {
+		t.Errorf("Expected only w2, got %d wallets", len(wallets))
+	}
+}
+
+func TestDB_ExpireStaleWallets(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	now := time.Now()
+	db.CreateGuestWallet(&GuestWallet{ID: "w1", Address: "a1", PrivateKeyHex: "k1", Status: "created", ExpiresAt: now.Add(-2 * time.Hour)})
+	db.CreateGuestWallet(&GuestWallet{ID: "w2", Address: "a2", PrivateKeyHex: "k2", Status: "created", ExpiresAt: now.Add(-time.Minute)})
+	db.CreateGuestWallet(&GuestWallet{ID: "w3", Address: "a3", PrivateKeyHex: "k3", Status: "created", ExpiresAt: now.Add(time.Hour)})
+	db.CreateGuestWallet(&GuestWallet{ID: "w4", Address: "a4", PrivateKeyHex: "k4", Status: "funded", ExpiresAt: now.Add(-time.Hour)})
+	db.CreateGuestWallet(&GuestWallet{ID: "w5", Address: "a5", PrivateKeyHex: "k5", Status: "created"})
+	// Paid after its expiry; left for the funding detector
+	db.CreateGuestWallet(&GuestWallet{ID: "w6", Address: "a6", PrivateKeyHex: "k6", Status: "created", ExpiresAt: now.Add(-time.Hour)})
+	checker := &mockBalanceChecker{balances: map[string]int64{"a6": 500 * shannonsPerCKB}}
+
+	n, err := db.ExpireStaleWallets(context.Background(), checker)
+	if err != nil {
+		t.Fatalf("ExpireStaleWallets failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 wallets expired, got %d", n)
+	}
+
+	for id, want := range map[string]string{"w1": "expired", "w2": "expired", "w3": "created", "w4": "funded", "w5": "created", "w6": "created"} {
+		w, _ := db.GetGuestWallet(id)
+		if w.Status != want {
+			t.Errorf("%s: expected status %s, got %s", id, want, w.Status)
+		}
+	}
+
+	expired, err := db.ListExpiredWallets(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("ListExpiredWallets failed: %v", err)
+	}
+	if len(expired) != 2 || expired[0].ID != "w1" || expired[1].ID != "w2" {
+		t.Fatalf("Expected w1, w2 oldest first, got %+v", expired)
+	}
+
+	expired, _ = db.ListExpiredWallets(now.Add(-time.Hour), time.Time{})
+	if len(expired) != 1 || expired[0].ID != "w2" {
+		t.Errorf("Expected only w2 after from, got %+v", expired)
+	}
+
+	if n, _ := db.ExpireStaleWallets(context.Background(), checker); n != 0 {
+		t.Errorf("Expected nothing left to expire, got %d", n)
+	}
+}
+
 func TestDB_UpdateWalletFunded(t *testing.T) {
 	db, cleanup := setupTestDB(t)
 	defer cleanup()
@@ -232,6 +435,85 @@ func TestDB_UpdateWalletFunded(t *testing.T) {
 	}
 }
 
+func TestDB_ResetWalletStatus(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	db.CreateGuestWallet(&GuestWallet{ID: "w1", Address: "ckt1a", PrivateKeyHex: "k1", Status: "funded", CreatedAt: time.Now()})
+	db.UpdateWalletStatus("w1", "preparing_cells")
+
+	if reset, err := db.ResetWalletStatus("w1", "preparing_cells", "funded"); err != nil || !reset {
+		t.Fatalf("Expected the wallet reset, got %v, %v", reset, err)
+	}
+	if wallet, _ := db.GetGuestWallet("w1"); wallet.Status != "funded" {
+		t.Errorf("Expected status funded, got %s", wallet.Status)
+	}
+
+	// A wallet that has moved on is left alone
+	db.UpdateWalletStatus("w1", "withdrawn")
+	if reset, err := db.ResetWalletStatus("w1", "preparing_cells", "funded"); err != nil || reset {
+		t.Fatalf("Expected no reset, got %v, %v", reset, err)
+	}
+	if wallet, _ := db.GetGuestWallet("w1"); wallet.Status != "withdrawn" {
+		t.Errorf("Expected status withdrawn, got %s", wallet.Status)
+	}
+}
+
+func TestDB_UpdateWalletRefund(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	db.CreateGuestWallet(&GuestWallet{ID: "w1", Address: "a1", PrivateKeyHex: "k1", Status: "funded"})
+
+	retrieved, _ := db.GetGuestWallet("w1")
+	if retrieved.RefundStatus != "" || retrieved.RefundUpdatedAt != nil {
+		t.Errorf("expected no refund before the first attempt, got %q", retrieved.RefundStatus)
+	}
+
+	if err := db.UpdateWalletRefund("w1", RefundStatusProcessing, "0xabc", 0); err != nil {
+		t.Fatalf("UpdateWalletRefund failed: %v", err)
+	}
+	if err := db.UpdateWalletRefund("w1", RefundStatusSent, "", 89900000000); err != nil {
+		t.Fatalf("UpdateWalletRefund failed: %v", err)
+	}
+
+	retrieved, _ = db.GetGuestWallet("w1")
+	if retrieved.RefundStatus != RefundStatusSent {
+		t.Errorf("RefundStatus: expected sent, got %q", retrieved.RefundStatus)
+	}
+	if retrieved.RefundTxHash != "0xabc" {
+		t.Errorf("RefundTxHash: expected the earlier hash to be kept, got %q", retrieved.RefundTxHash)
+	}
+	if retrieved.RefundAmount != 89900000000 {
+		t.Errorf("RefundAmount: expected 89900000000, got %d", retrieved.RefundAmount)
+	}
+	if retrieved.RefundUpdatedAt == nil {
+		t.Error("RefundUpdatedAt not recorded")
+	}
+}
+
+func TestDB_UpdateWalletLastChecked(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	db.CreateGuestWallet(&GuestWallet{ID: "w1", Address: "a1", PrivateKeyHex: "k1", Status: "created"})
+
+	retrieved, _ := db.GetGuestWallet("w1")
+	if retrieved.LastCheckedAt != nil {
+		t.Errorf("LastCheckedAt: expected nil before first check, got %v", retrieved.LastCheckedAt)
+	}
+
+	checkedAt := time.Now().Truncate(time.Second)
+	if err := db.UpdateWalletLastChecked("w1", checkedAt); err != nil {
+		t.Fatalf("UpdateWalletLastChecked failed: %v", err)
+	}
+
+	pending, _ := db.ListPendingWallets()
+	if len(pending) != 1 || pending[0].LastCheckedAt == nil || !pending[0].LastCheckedAt.Equal(checkedAt) {
+		t.Errorf("LastCheckedAt not recorded: %+v", pending)
+	}
+}
+
 func TestDB_GetStats(t *testing.T) {
 	db, cleanup := setupTestDB(t)
 	defer cleanup()
@@ -256,6 +538,67 @@ func TestDB_GetStats(t *testing.T) {
 	}
 }
 
+func TestDB_GetSessionCountByStatus(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	db.CreateSession(&Session{ID: "s1", WalletID: "w1", Status: "active", ExpiresAt: time.Now().Add(time.Hour)})
+	db.CreateSession(&Session{ID: "s2", WalletID: "w2", Status: "active", ExpiresAt: time.Now().Add(time.Hour)})
+	db.CreateSession(&Session{ID: "s3", WalletID: "w3", Status: "settled", ExpiresAt: time.Now()})
+
+	if active, err := db.GetActiveSessionCount(); err != nil || active != 2 {
+		t.Errorf("Active: expected 2, got %d (%v)", active, err)
+	}
+	if settled, err := db.GetSessionCountByStatus("settled"); err != nil || settled != 1 {
+		t.Errorf("Settled: expected 1, got %d (%v)", settled, err)
+	}
+	if expired, err := db.GetSessionCountByStatus("expired"); err != nil || expired != 0 {
+		t.Errorf("Expired: expected 0, got %d (%v)", expired, err)
+	}
+}
+
+func BenchmarkActiveSessionCount(b *testing.B) {
+	db, err := Open(filepath.Join(b.TempDir(), "bench.db"))
+	if err != nil {
+		b.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	for i := 0; i < 1000; i++ {
+		status := "settled"
+		if i%10 == 0 {
+			status = "active"
+		}
+		db.CreateSession(&Session{ID: fmt.Sprintf("s%d", i), Status: status, CreatedAt: now, ExpiresAt: now})
+	}
+
+	b.Run("count", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := db.GetActiveSessionCount(); err != nil {
+				b.Fatalf("GetActiveSessionCount failed: %v", err)
+			}
+		}
+	})
+	b.Run("list", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			sessions, err := db.ListSessions("")
+			if err != nil {
+				b.Fatalf("ListSessions failed: %v", err)
+			}
+			active := 0
+			for _, s := range sessions {
+				if s.Status == "active" {
+					active++
+				}
+			}
+			if active != 100 {
+				b.Fatalf("Expected 100 active sessions, got %d", active)
+			}
+		}
+	})
+}
+
 func TestDB_ExtendSession(t *testing.T) {
 	db, cleanup := setupTestDB(t)
 	defer cleanup()
@@ -278,3 +621,384 @@ func TestDB_ExtendSession(t *testing.T) {
 		t.Errorf("SpentCKB: expected 250, got %d", retrieved.SpentCKB)
 	}
 }
+
+func TestDB_UpdateSessionExpiry(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	db.CreateSession(&Session{
+		ID:        "test-expiry",
+		WalletID:  "wallet-expiry",
+		Status:    "active",
+		ExpiresAt: time.Now().Add(30 * time.Minute),
+	})
+
+	newExpiry := time.Now().Add(2*time.Hour + 15*time.Second).Truncate(time.Second)
+	if err := db.UpdateSessionExpiry("test-expiry", newExpiry); err != nil {
+		t.Fatalf("UpdateSessionExpiry failed: %v", err)
+	}
+	retrieved, _ := db.GetSession("test-expiry")
+	if !retrieved.ExpiresAt.Equal(newExpiry) {
+		t.Errorf("ExpiresAt: expected %v, got %v", newExpiry, retrieved.ExpiresAt)
+	}
+
+	if err := db.UpdateSessionExpiry("missing", newExpiry); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows for missing session, got %v", err)
+	}
+}
+
+func TestDB_TransferSession(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	db.CreateGuestWallet(&GuestWallet{ID: "w-phone", Address: "ckt1phone", PrivateKeyHex: "k1", Status: "channel_open", MACAddress: "aa:aa:aa:aa:aa:aa", IPAddress: "10.0.0.2"})
+	db.CreateGuestWallet(&GuestWallet{ID: "w-laptop", Address: "ckt1laptop", PrivateKeyHex: "k2", Status: "created", MACAddress: "bb:bb:bb:bb:bb:bb", IPAddress: "10.0.0.3"})
+	db.CreateSession(&Session{
+		ID:           "s-transfer",
+		WalletID:     "w-phone",
+		GuestAddress: "ckt1phone",
+		MACAddress:   "aa:aa:aa:aa:aa:aa",
+		IPAddress:    "10.0.0.2",
+		Status:       "active",
+	})
+
+	db.CreateGuestWallet(&GuestWallet{ID: "w-used", Address: "ckt1used", PrivateKeyHex: "k3", Status: "channel_open", SessionID: "other", MACAddress: "cc:cc:cc:cc:cc:cc"})
+	db.CreateGuestWallet(&GuestWallet{ID: "w-nomac", Address: "ckt1nomac", PrivateKeyHex: "k4", Status: "created"})
+
+	// Targets that are in use or have no device are rejected
+	for _, walletID := range []string{"w-used", "w-nomac", "w-phone"} {
+		if _, _, err := db.TransferSession("s-transfer", walletID); !errors.Is(err, ErrTransferRejected) {
+			t.Errorf("%s: expected ErrTransferRejected, got %v", walletID, err)
+		}
+	}
+
+	before, to, err := db.TransferSession("s-transfer", "w-laptop")
+	if err != nil {
+		t.Fatalf("TransferSession failed: %v", err)
+	}
+	if before.WalletID != "w-phone" || to.ID != "w-laptop" {
+		t.Errorf("Unexpected result: session wallet %s, target %s", before.WalletID, to.ID)
+	}
+
+	sess, _ := db.GetSession("s-transfer")
+	if sess.WalletID != "w-laptop" || sess.GuestAddress != "ckt1laptop" || sess.MACAddress != "bb:bb:bb:bb:bb:bb" || sess.IPAddress != "10.0.0.3" {
+		t.Errorf("Session not moved to the new wallet: %+v", sess)
+	}
+	from, _ := db.GetGuestWallet("w-phone")
+	if from.Status != "transferred" {
+		t.Errorf("Old wallet status: expected transferred, got %s", from.Status)
+	}
+	to, _ = db.GetGuestWallet("w-laptop")
+	if to.Status != "active" {
+		t.Errorf("New wallet status: expected active, got %s", to.Status)
+	}
+
+	// A missing target wallet or session leaves everything untouched
+	if _, _, err := db.TransferSession("s-transfer", "missing"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("Expected sql.ErrNoRows for missing wallet, got %v", err)
+	}
+	if _, _, err := db.TransferSession("missing", "w-laptop"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("Expected sql.ErrNoRows for missing session, got %v", err)
+	}
+	sess, _ = db.GetSession("s-transfer")
+	if sess.WalletID != "w-laptop" {
+		t.Errorf("Expected session unchanged, got wallet %s", sess.WalletID)
+	}
+}
+
+func TestDB_PeerHeartbeat(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	db.CreateSession(&Session{ID: "test-peer", WalletID: "wallet-peer", Status: "active"})
+
+	first := time.Now().Truncate(time.Second)
+	db.MarkPeerOffline("test-peer", first)
+	db.MarkPeerOffline("test-peer", first.Add(30*time.Second))
+	retrieved, _ := db.GetSession("test-peer")
+	if retrieved.PeerOfflineSince == nil || !retrieved.PeerOfflineSince.Equal(first) {
+		t.Errorf("Expected offline since the first miss %v, got %v", first, retrieved.PeerOfflineSince)
+	}
+
+	answered := first.Add(time.Minute)
+	if err := db.RecordPeerHeartbeat("test-peer", answered); err != nil {
+		t.Fatalf("RecordPeerHeartbeat failed: %v", err)
+	}
+	retrieved, _ = db.GetSession("test-peer")
+	if retrieved.PeerOfflineSince != nil || !retrieved.LastHeartbeatSuccess.Equal(answered) {
+		t.Errorf("Expected peer back online at %v, got %+v", answered, retrieved)
+	}
+}
+
+func TestDB_UpdateSessionUsage(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	db.CreateSession(&Session{ID: "s1", Status: "active", ExpiresAt: time.Now().Add(time.Hour)})
+
+	if err := db.UpdateSessionUsage("s1", 4096, 1024); err != nil {
+		t.Fatalf("UpdateSessionUsage failed: %v", err)
+	}
+
+	session, _ := db.GetSession("s1")
+	if session.BytesIn != 4096 || session.BytesOut != 1024 {
+		t.Errorf("Usage: expected 4096/1024, got %d/%d", session.BytesIn, session.BytesOut)
+	}
+}
+
+func TestDB_RecordDisputeAndResolution(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	db.CreateSession(&Session{ID: "s1", Status: "active", ExpiresAt: time.Now().Add(time.Hour)})
+
+	session, _ := db.GetSession("s1")
+	if session.DisputedAt != nil || session.ResolvedAt != nil {
+		t.Fatal("New session should have no dispute timestamps")
+	}
+
+	// The dispute is recorded when it starts and its hash filled in later
+	if err := db.RecordDispute("s1", ""); err != nil {
+		t.Fatalf("RecordDispute failed: %v", err)
+	}
+	started, _ := db.GetSession("s1")
+	if started.DisputedAt == nil || started.DisputeTxHash != "" {
+		t.Fatalf("Expected a dispute without a hash, got %v %q", started.DisputedAt, started.DisputeTxHash)
+	}
+	if err := db.RecordDispute("s1", "0xdispute"); err != nil {
+		t.Fatalf("RecordDispute failed: %v", err)
+	}
+	if err := db.RecordDispute("s1", ""); err != nil {
+		t.Fatalf("RecordDispute failed: %v", err)
+	}
+	if err := db.RecordResolution("s1", "0xresolve"); err != nil {
+		t.Fatalf("RecordResolution failed: %v", err)
+	}
+
+	session, err := db.GetSession("s1")
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	if session.DisputedAt == nil || !session.DisputedAt.Equal(*started.DisputedAt) {
+		t.Errorf("DisputedAt should stay at the start of the dispute, got %v", session.DisputedAt)
+	}
+	if session.ResolvedAt == nil {
+		t.Error("ResolvedAt should be set")
+	}
+	if session.DisputeTxHash != "0xdispute" {
+		t.Errorf("DisputeTxHash: expected 0xdispute, got %s", session.DisputeTxHash)
+	}
+	if session.ResolutionTxHash != "0xresolve" {
+		t.Errorf("ResolutionTxHash: expected 0xresolve, got %s", session.ResolutionTxHash)
+	}
+}
+
+func TestDB_Transaction_RollbackOnError(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	db.CreateGuestWallet(&GuestWallet{ID: "w1", Address: "a1", PrivateKeyHex: "k1", Status: "created"})
+
+	errAbort := errors.New("abort")
+	err := db.Transaction(func(tx *DB) error {
+		if err := tx.CreateSession(&Session{ID: "s1", Status: "active", CreatedAt: time.Now()}); err != nil {
+			return err
+		}
+		if err := tx.UpdateWalletFunded("w1", 500, "s1"); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("expected abort error, got %v", err)
+	}
+
+	if _, err := db.GetSession("s1"); err == nil {
+		t.Error("session should have been rolled back")
+	}
+	wallet, _ := db.GetGuestWallet("w1")
+	if wallet.Status != "created" {
+		t.Errorf("wallet status should be rolled back to created, got %s", wallet.Status)
+	}
+}
+
+func TestDB_Transaction_Commit(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	db.CreateGuestWallet(&GuestWallet{ID: "w1", Address: "a1", PrivateKeyHex: "k1", Status: "created"})
+
+	err := db.Transaction(func(tx *DB) error {
+		if err := tx.CreateSession(&Session{ID: "s1", Status: "active", CreatedAt: time.Now()}); err != nil {
+			return err
+		}
+		// Nested calls join the outer transaction
+		return tx.Transaction(func(inner *DB) error {
+			return inner.UpdateWalletFunded("w1", 500, "s1")
+		})
+	})
+	if err != nil {
+		t.Fatalf("Transaction failed: %v", err)
+	}
+
+	if _, err := db.GetSession("s1"); err != nil {
+		t.Errorf("session should be committed: %v", err)
+	}
+	wallet, _ := db.GetGuestWallet("w1")
+	if wallet.Status != "funded" || wallet.SessionID != "s1" {
+		t.Errorf("wallet not committed: status=%s session=%s", wallet.Status, wallet.SessionID)
+	}
+}
+
+func TestDB_Transaction_RollbackOnPanic(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic to propagate")
+			}
+		}()
+		db.Transaction(func(tx *DB) error {
+			tx.CreateSession(&Session{ID: "s1", Status: "active", CreatedAt: time.Now()})
+			panic("boom")
+		})
+	}()
+
+	if _, err := db.GetSession("s1"); err == nil {
+		t.Error("session should have been rolled back")
+	}
+}
+
+func TestDB_ListWalletsWithBalanceAbove(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	now := time.Now()
+	db.CreateSession(&Session{ID: "s-ended", Status: "settled", CreatedAt: now})
+	db.CreateSession(&Session{ID: "s-running", Status: "active", CreatedAt: now})
+
+	wallets := []*GuestWallet{
+		{ID: "refundable", Address: "a1", PrivateKeyHex: "k1", BalanceCKB: 500, Status: "funded", SessionID: "s-ended", SenderAddress: "ckt1sender"},
+		{ID: "unfunded", Address: "a2", PrivateKeyHex: "k2", BalanceCKB: 100, Status: "created"},
+		{ID: "dust", Address: "a3", PrivateKeyHex: "k3", BalanceCKB: 60, Status: "created"},
+		{ID: "withdrawn", Address: "a4", PrivateKeyHex: "k4", BalanceCKB: 500, Status: "withdrawn", SessionID: "s-ended"},
+		{ID: "open", Address: "a5", PrivateKeyHex: "k5", BalanceCKB: 500, Status: "channel_open"},
+		{ID: "running", Address: "a6", PrivateKeyHex: "k6", BalanceCKB: 500, Status: "funded", SessionID: "s-running"},
+	}
+	for _, w := range wallets {
+		w.CreatedAt = now
+		if err := db.CreateGuestWallet(w); err != nil {
+			t.Fatalf("CreateGuestWallet failed: %v", err)
+		}
+	}
+
+	result, err := db.ListWalletsWithBalanceAbove(61)
+	if err != nil {
+		t.Fatalf("ListWalletsWithBalanceAbove failed: %v", err)
+	}
+	if len(result) != 2 {
+		t.Fatalf("expected 2 wallets, got %d", len(result))
+	}
+	if result[0].ID != "refundable" || result[1].ID != "unfunded" {
+		t.Errorf("unexpected wallets: %s, %s", result[0].ID, result[1].ID)
+	}
+	if result[0].SenderAddress != "ckt1sender" {
+		t.Errorf("SenderAddress: expected ckt1sender, got %s", result[0].SenderAddress)
+	}
+}
+
+func TestDB_SessionHeartbeat(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	now := time.Now()
+	db.CreateSession(&Session{ID: "s1", Status: "active", ExpiresAt: now.Add(time.Hour)})
+	db.CreateSession(&Session{ID: "s2", Status: "active", ExpiresAt: now.Add(time.Hour)})
+	db.CreateSession(&Session{ID: "s3", Status: "settled", ExpiresAt: now})
+	db.CreateSession(&Session{ID: "s4", Status: "active", ExpiresAt: now.Add(time.Hour)})
+
+	retrieved, _ := db.GetSession("s1")
+	if retrieved.LastHeartbeatAt != nil {
+		t.Errorf("LastHeartbeatAt: expected nil before first heartbeat, got %v", retrieved.LastHeartbeatAt)
+	}
+
+	beatAt := now.Add(-20 * time.Minute).Truncate(time.Second)
+	if err := db.UpdateSessionHeartbeat("s1", beatAt); err != nil {
+		t.Fatalf("UpdateSessionHeartbeat failed: %v", err)
+	}
+	db.UpdateSessionHeartbeat("s2", now)
+	db.UpdateSessionHeartbeat("s3", beatAt)
+
+	retrieved, _ = db.GetSession("s1")
+	if retrieved.LastHeartbeatAt == nil || !retrieved.LastHeartbeatAt.Equal(beatAt) {
+		t.Errorf("LastHeartbeatAt: expected %v, got %v", beatAt, retrieved.LastHeartbeatAt)
+	}
+
+	// s2 is fresh, s3 isn't active and s4 never sent a heartbeat
+	stale, err := db.ListStaleSessions(now.Add(-10 * time.Minute))
+	if err != nil {
+		t.Fatalf("ListStaleSessions failed: %v", err)
+	}
+	if len(stale) != 1 || stale[0].ID != "s1" {
+		t.Errorf("Expected only s1 to be stale, got %d sessions", len(stale))
+	}
+}
+
+func TestDB_UpdateSessionSenderAddress(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	now := time.Now()
+	db.CreateSession(&Session{ID: "s1", Status: "active", ExpiresAt: now.Add(time.Hour)})
+	db.CreateSession(&Session{ID: "s2", Status: "active", ExpiresAt: now.Add(time.Hour), SenderAddress: "ckt1known"})
+
+	retrieved, _ := db.GetSession("s1")
+	if retrieved.SenderAddress != "" {
+		t.Errorf("SenderAddress: expected empty before detection, got %q", retrieved.SenderAddress)
+	}
+	retrieved, _ = db.GetSession("s2")
+	if retrieved.SenderAddress != "ckt1known" {
+		t.Errorf("SenderAddress: expected ckt1known from create, got %q", retrieved.SenderAddress)
+	}
+
+	if err := db.UpdateSessionSenderAddress("s1", "ckt1sender"); err != nil {
+		t.Fatalf("UpdateSessionSenderAddress failed: %v", err)
+	}
+	retrieved, _ = db.GetSession("s1")
+	if retrieved.SenderAddress != "ckt1sender" {
+		t.Errorf("SenderAddress: expected ckt1sender, got %q", retrieved.SenderAddress)
+	}
+}
+
+func TestDB_GetSessionsExpiringWithin(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	now := time.Now()
+	db.CreateSession(&Session{ID: "soon", Status: "active", ExpiresAt: now.Add(3 * time.Minute)})
+	db.CreateSession(&Session{ID: "sooner", Status: "active", ExpiresAt: now.Add(time.Minute)})
+	db.CreateSession(&Session{ID: "later", Status: "active", ExpiresAt: now.Add(time.Hour)})
+	db.CreateSession(&Session{ID: "settled", Status: "settled", ExpiresAt: now.Add(2 * time.Minute)})
+
+	expiring, err := db.GetSessionsExpiringWithin(5 * time.Minute)
+	if err != nil {
+		t.Fatalf("GetSessionsExpiringWithin failed: %v", err)
+	}
+	if len(expiring) != 2 || expiring[0].ID != "sooner" || expiring[1].ID != "soon" {
+		t.Fatalf("Expected sooner and soon, got %d sessions", len(expiring))
+	}
+	if expiring[0].ExpiryNotifiedAt != nil {
+		t.Errorf("ExpiryNotifiedAt: expected nil before notification, got %v", expiring[0].ExpiryNotifiedAt)
+	}
+
+	notifiedAt := now.Truncate(time.Second)
+	if err := db.UpdateSessionExpiryNotified("soon", notifiedAt); err != nil {
+		t.Fatalf("UpdateSessionExpiryNotified failed: %v", err)
+	}
+	retrieved, _ := db.GetSession("soon")
+	if retrieved.ExpiryNotifiedAt == nil || !retrieved.ExpiryNotifiedAt.Equal(notifiedAt) {
+		t.Errorf("ExpiryNotifiedAt: expected %v, got %v", notifiedAt, retrieved.ExpiryNotifiedAt)
+	}
+}
@@ -0,0 +1,185 @@
+package db
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// Guest wallet private keys are stored in private_key_hex either as the
+// plain 32-byte key in hex or, once a KeyCipher is set, encrypted with
+// AES-256-GCM as the hex of nonce followed by ciphertext and tag. The two
+// are told apart by length.
+const (
+	keyCipherNonceSize = 12
+	keyCipherTagSize   = 16
+	plainKeySize       = 32
+	encryptedKeyHexLen = 2 * (keyCipherNonceSize + plainKeySize + keyCipherTagSize)
+)
+
+// ErrNoKeyCipher is returned when reading an encrypted private key without
+// a KeyCipher set.
+var ErrNoKeyCipher = errors.New("wallet private key is encrypted but no key cipher is set")
+
+// IsEncryptedKey reports whether a stored private key is encrypted.
+func IsEncryptedKey(key string) bool {
+	return len(key) == encryptedKeyHexLen
+}
+
+// KeyCipher encrypts guest wallet private keys at rest.
+type KeyCipher struct {
+	aead cipher.AEAD
+}
+
+// NewKeyCipher creates a KeyCipher from a 32-byte AES-256 key.
+func NewKeyCipher(key []byte) (*KeyCipher, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("key cipher needs a 32-byte key, got %d bytes", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyCipher{aead: aead}, nil
+}
+
+// Encrypt encrypts a hex private key into its stored form.
+func (c *KeyCipher) Encrypt(keyHex string) (string, error) {
+	key, err := hex.DecodeString(keyHex)
+	if err != nil || len(key) != plainKeySize {
+		return "", fmt.Errorf("private key must be %d bytes of hex", plainKeySize)
+	}
+	nonce := make([]byte, keyCipherNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return hex.EncodeToString(c.aead.Seal(nonce, nonce, key, nil)), nil
+}
+
+// Decrypt returns the hex private key of a stored key. Keys that are not
+// encrypted yet are returned as they are.
+func (c *KeyCipher) Decrypt(stored string) (string, error) {
+	if !IsEncryptedKey(stored) {
+		return stored, nil
+	}
+	data, err := hex.DecodeString(stored)
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted private key: %w", err)
+	}
+	key, err := c.aead.Open(nil, data[:keyCipherNonceSize], data[keyCipherNonceSize:], nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt private key: %w", err)
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// SetKeyCipher makes the database encrypt private keys of new wallets and
+// decrypt stored keys when wallets are read. Call it before the database
+// is used.
+func (db *DB) SetKeyCipher(c *KeyCipher) {
+	db.keyCipher = c
+}
+
+// storedKey returns the form of keyHex to write to private_key_hex.
+func (db *DB) storedKey(keyHex string) (string, error) {
+	if db.keyCipher == nil || keyHex == "" {
+		return keyHex, nil
+	}
+	return db.keyCipher.Encrypt(keyHex)
+}
+
+// loadedKey returns the hex private key of a stored key.
+func (db *DB) loadedKey(stored string) (string, error) {
+	if !IsEncryptedKey(stored) {
+		return stored, nil
+	}
+	if db.keyCipher == nil {
+		return "", ErrNoKeyCipher
+	}
+	return db.keyCipher.Decrypt(stored)
+}
+
+// plaintextKeyFilter selects wallets whose private key is still plaintext.
+const plaintextKeyFilter = `private_key_hex != '' AND length(private_key_hex) != ?`
+
+// CountPlaintextKeys returns how many guest wallets store a plaintext private key.
+func (db *DB) CountPlaintextKeys() (int, error) {
+	var count int
+	err := db.conn.QueryRow(`SELECT COUNT(*) FROM guest_wallets WHERE `+plaintextKeyFilter,
+		encryptedKeyHexLen).Scan(&count)
+	return count, err
+}
+
+// MigratePrivateKeys encrypts every plaintext guest wallet key in a single
+// transaction. Each result must be an encrypted key that decrypts back to
+// the original; otherwise nothing is changed, so a bad key or cipher can't
+// lose data.
+func (db *DB) MigratePrivateKeys(encrypt func(string) string, decrypt func(string) string) error {
+	return db.Transaction(func(tx *DB) error {
+		rows, err := tx.conn.Query(`SELECT id, private_key_hex FROM guest_wallets WHERE `+plaintextKeyFilter,
+			encryptedKeyHexLen)
+		if err != nil {
+			return fmt.Errorf("failed to list plaintext keys: %w", err)
+		}
+		keys := make(map[string]string)
+		for rows.Next() {
+			var id, key string
+			if err := rows.Scan(&id, &key); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to read wallet key: %w", err)
+			}
+			keys[id] = key
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to list plaintext keys: %w", err)
+		}
+
+		for id, key := range keys {
+			encrypted := encrypt(key)
+			if !IsEncryptedKey(encrypted) {
+				return fmt.Errorf("failed to encrypt key for wallet %s", id)
+			}
+			if decrypt(encrypted) != key {
+				return fmt.Errorf("failed to encrypt key for wallet %s: result doesn't decrypt to the original", id)
+			}
+			if _, err := tx.conn.Exec(`UPDATE guest_wallets SET private_key_hex = ? WHERE id = ?`, encrypted, id); err != nil {
+				return fmt.Errorf("failed to update key for wallet %s: %w", id, err)
+			}
+		}
+		return nil
+	})
+}
+
+// MigratePrivateKeysWith encrypts every plaintext guest wallet key with c
+// through MigratePrivateKeys and returns how many were encrypted.
+func (db *DB) MigratePrivateKeysWith(c *KeyCipher) (int, error) {
+	count, err := db.CountPlaintextKeys()
+	if err != nil {
+		return 0, err
+	}
+	err = db.MigratePrivateKeys(
+		func(key string) string {
+			encrypted, err := c.Encrypt(key)
+			if err != nil {
+				return ""
+			}
+			return encrypted
+		},
+		func(encrypted string) string {
+			key, _ := c.Decrypt(encrypted)
+			return key
+		},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
@@ -0,0 +1,160 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newTestKeyCipher(t *testing.T, b byte) *KeyCipher {
+	t.Helper()
+	c, err := NewKeyCipher(bytes.Repeat([]byte{b}, 32))
+	if err != nil {
+		t.Fatalf("NewKeyCipher failed: %v", err)
+	}
+	return c
+}
+
+var (
+	testKey1 = strings.Repeat("a1", 32)
+	testKey2 = strings.Repeat("b2", 32)
+)
+
+func TestKeyCipher(t *testing.T) {
+	c := newTestKeyCipher(t, 1)
+
+	encrypted, err := c.Encrypt(testKey1)
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if !IsEncryptedKey(encrypted) || IsEncryptedKey(testKey1) {
+		t.Fatalf("IsEncryptedKey can't tell %q from the plaintext key", encrypted)
+	}
+	if key, err := c.Decrypt(encrypted); err != nil || key != testKey1 {
+		t.Errorf("Decrypt: expected %s, got %q (%v)", testKey1, key, err)
+	}
+	if key, err := c.Decrypt(testKey1); err != nil || key != testKey1 {
+		t.Errorf("Decrypt of a plaintext key: expected it unchanged, got %q (%v)", key, err)
+	}
+	if _, err := newTestKeyCipher(t, 2).Decrypt(encrypted); err == nil {
+		t.Error("Expected decryption with another key to fail")
+	}
+	if _, err := c.Encrypt("abc"); err == nil {
+		t.Error("Expected a key that isn't 32 bytes of hex to be rejected")
+	}
+	if _, err := NewKeyCipher([]byte("short")); err == nil {
+		t.Error("Expected NewKeyCipher to reject a short key")
+	}
+}
+
+func TestDB_KeyCipher(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	db.SetKeyCipher(newTestKeyCipher(t, 1))
+
+	if err := db.CreateGuestWallet(&GuestWallet{ID: "w1", Address: "a1", PrivateKeyHex: testKey1, Status: "created"}); err != nil {
+		t.Fatalf("CreateGuestWallet failed: %v", err)
+	}
+
+	var stored string
+	db.conn.QueryRow(`SELECT private_key_hex FROM guest_wallets WHERE id = 'w1'`).Scan(&stored)
+	if !IsEncryptedKey(stored) {
+		t.Errorf("Expected the key encrypted at rest, got %q", stored)
+	}
+	if w, err := db.GetGuestWallet("w1"); err != nil || w.PrivateKeyHex != testKey1 {
+		t.Errorf("Expected the decrypted key on read, got %+v (%v)", w, err)
+	}
+
+	db.SetKeyCipher(nil)
+	if _, err := db.GetGuestWallet("w1"); !errors.Is(err, ErrNoKeyCipher) {
+		t.Errorf("Expected ErrNoKeyCipher without a cipher, got %v", err)
+	}
+}
+
+func TestDB_MigratePrivateKeys(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	c := newTestKeyCipher(t, 1)
+
+	encrypted, _ := c.Encrypt(strings.Repeat("c3", 32))
+	db.CreateGuestWallet(&GuestWallet{ID: "w1", Address: "a1", PrivateKeyHex: testKey1, Status: "created"})
+	db.CreateGuestWallet(&GuestWallet{ID: "w2", Address: "a2", PrivateKeyHex: testKey2, Status: "funded"})
+	db.CreateGuestWallet(&GuestWallet{ID: "w3", Address: "a3", PrivateKeyHex: encrypted, Status: "funded"})
+
+	if n, err := db.CountPlaintextKeys(); err != nil || n != 2 {
+		t.Fatalf("Expected 2 plaintext keys, got %d (%v)", n, err)
+	}
+
+	if n, err := db.MigratePrivateKeysWith(c); err != nil || n != 2 {
+		t.Fatalf("MigratePrivateKeysWith: expected 2 keys encrypted, got %d (%v)", n, err)
+	}
+	if n, _ := db.CountPlaintextKeys(); n != 0 {
+		t.Errorf("Expected no plaintext keys after migration, got %d", n)
+	}
+
+	db.SetKeyCipher(c)
+	for id, want := range map[string]string{"w1": testKey1, "w2": testKey2, "w3": strings.Repeat("c3", 32)} {
+		if w, err := db.GetGuestWallet(id); err != nil || w.PrivateKeyHex != want {
+			t.Errorf("%s: expected key %s after migration, got %+v (%v)", id, want, w, err)
+		}
+	}
+
+	// A second run has nothing left to do
+	if n, err := db.MigratePrivateKeysWith(c); err != nil || n != 0 {
+		t.Fatalf("second MigratePrivateKeysWith: expected nothing to do, got %d (%v)", n, err)
+	}
+	if w, _ := db.GetGuestWallet("w1"); w.PrivateKeyHex != testKey1 {
+		t.Errorf("w1: expected key encrypted once, got %q", w.PrivateKeyHex)
+	}
+}
+
+func TestDB_MigratePrivateKeys_RollsBackOnFailure(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	c := newTestKeyCipher(t, 1)
+
+	db.CreateGuestWallet(&GuestWallet{ID: "w1", Address: "a1", PrivateKeyHex: testKey1, Status: "created"})
+	db.CreateGuestWallet(&GuestWallet{ID: "w2", Address: "a2", PrivateKeyHex: testKey2, Status: "created"})
+
+	encrypt := func(key string) string {
+		encrypted, _ := c.Encrypt(key)
+		return encrypted
+	}
+	decrypt := func(encrypted string) string {
+		key, _ := c.Decrypt(encrypted)
+		return key
+	}
+	tests := []struct {
+		name    string
+		encrypt func(string) string
+		decrypt func(string) string
+	}{
+		{"not encrypted", func(key string) string {
+			if key == testKey2 {
+				return key
+			}
+			return encrypt(key)
+		}, decrypt},
+		{"lossy", encrypt, func(encrypted string) string {
+			if key := decrypt(encrypted); key != testKey2 {
+				return key
+			}
+			return testKey1
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := db.MigratePrivateKeys(tt.encrypt, tt.decrypt); err == nil {
+				t.Fatal("Expected migration to fail")
+			}
+			if n, _ := db.CountPlaintextKeys(); n != 2 {
+				t.Errorf("Expected both keys left plaintext, got %d", n)
+			}
+			if w, _ := db.GetGuestWallet("w1"); w.PrivateKeyHex != testKey1 {
+				t.Errorf("w1: expected rollback to keep plaintext key, got %q", w.PrivateKeyHex)
+			}
+		})
+	}
+}
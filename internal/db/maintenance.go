@@ -0,0 +1,91 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// compactStatements reclaim free pages and refresh query planner statistics.
+var compactStatements = []string{"VACUUM", "ANALYZE", "PRAGMA optimize"}
+
+// StorageStats describes the size of the database file and its tables.
+type StorageStats struct {
+	SizeBytes int64
+	PageSize  int64
+	PageCount int64
+	FreePages int64 // Pages on the freelist, reclaimed by CompactDB
+	Tables    map[string]TableStats
+}
+
+// TableStats describes one table from sqlite_master.
+type TableStats struct {
+	Rows    int64
+	Indexes int
+}
+
+// CompactDB rebuilds the database file to drop free pages left by deleted
+// and updated rows, then refreshes the query planner statistics. VACUUM
+// rewrites the whole file and blocks writers until it finishes, so run it
+// when traffic is low. It cannot run inside a transaction.
+func (db *DB) CompactDB() error {
+	if db.inTx {
+		return errors.New("cannot compact database inside a transaction")
+	}
+	for _, stmt := range compactStatements {
+		if _, err := db.sqlDB.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to run %s: %w", stmt, err)
+		}
+	}
+	return nil
+}
+
+// GetStorageStats returns the page counts of the database file and the row
+// and index count of every table.
+func (db *DB) GetStorageStats() (*StorageStats, error) {
+	stats := &StorageStats{Tables: make(map[string]TableStats)}
+	for pragma, dest := range map[string]*int64{
+		"page_size":      &stats.PageSize,
+		"page_count":     &stats.PageCount,
+		"freelist_count": &stats.FreePages,
+	} {
+		if err := db.conn.QueryRow("PRAGMA " + pragma).Scan(dest); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", pragma, err)
+		}
+	}
+	stats.SizeBytes = stats.PageSize * stats.PageCount
+
+	rows, err := db.conn.Query(`SELECT type, name, tbl_name FROM sqlite_master
+		WHERE type IN ('table', 'index') AND name NOT LIKE 'sqlite_%'`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	indexes := make(map[string]int)
+	for rows.Next() {
+		var objType, name, table string
+		if err := rows.Scan(&objType, &name, &table); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if objType == "index" {
+			indexes[table]++
+			continue
+		}
+		stats.Tables[name] = TableStats{}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for name := range stats.Tables {
+		var count int64
+		// Table names come from sqlite_master, quoted as identifiers
+		query := fmt.Sprintf(`SELECT COUNT(*) FROM "%s"`, strings.ReplaceAll(name, `"`, `""`))
+		if err := db.conn.QueryRow(query).Scan(&count); err != nil {
+			return nil, fmt.Errorf("failed to count rows in %s: %w", name, err)
+		}
+		stats.Tables[name] = TableStats{Rows: count, Indexes: indexes[name]}
+	}
+	return stats, nil
+}
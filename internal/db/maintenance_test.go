@@ -0,0 +1,85 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDB_CompactDB(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	// Fill a few hundred pages, then delete them to leave free pages behind
+	filler := strings.Repeat("x", 4096)
+	for i := 0; i < 200; i++ {
+		if _, err := db.conn.Exec("INSERT INTO settings (key, value) VALUES (?, ?)", fmt.Sprintf("filler_%d", i), filler); err != nil {
+			t.Fatalf("failed to insert filler: %v", err)
+		}
+	}
+	if _, err := db.conn.Exec("DELETE FROM settings WHERE key LIKE 'filler_%'"); err != nil {
+		t.Fatalf("failed to delete filler: %v", err)
+	}
+
+	before, err := db.GetStorageStats()
+	if err != nil {
+		t.Fatalf("GetStorageStats failed: %v", err)
+	}
+	if before.FreePages == 0 {
+		t.Fatal("Expected free pages after deleting rows")
+	}
+
+	if err := db.CompactDB(); err != nil {
+		t.Fatalf("CompactDB failed: %v", err)
+	}
+
+	after, err := db.GetStorageStats()
+	if err != nil {
+		t.Fatalf("GetStorageStats failed: %v", err)
+	}
+	if after.FreePages != 0 {
+		t.Errorf("Expected no free pages after compaction, got %d", after.FreePages)
+	}
+	if after.PageCount >= before.PageCount {
+		t.Errorf("Expected fewer pages after compaction, got %d (was %d)", after.PageCount, before.PageCount)
+	}
+	if after.SizeBytes != after.PageCount*after.PageSize {
+		t.Errorf("SizeBytes = %d, want %d", after.SizeBytes, after.PageCount*after.PageSize)
+	}
+}
+
+func TestDB_CompactDB_InTransaction(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	err := db.Transaction(func(tx *DB) error {
+		return tx.CompactDB()
+	})
+	if err == nil {
+		t.Error("CompactDB inside a transaction should fail")
+	}
+}
+
+func TestDB_GetStorageStats_Tables(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	if err := db.CreateSession(&Session{ID: "stats-session", Status: "active"}); err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	stats, err := db.GetStorageStats()
+	if err != nil {
+		t.Fatalf("GetStorageStats failed: %v", err)
+	}
+	sessions, ok := stats.Tables["sessions"]
+	if !ok {
+		t.Fatal("Expected sessions table in stats")
+	}
+	if sessions.Rows != 1 {
+		t.Errorf("sessions rows = %d, want 1", sessions.Rows)
+	}
+	if _, ok := stats.Tables["guest_wallets"]; !ok {
+		t.Error("Expected guest_wallets table in stats")
+	}
+}
@@ -0,0 +1,119 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
+)
+
+// SessionStatusOrphanedRecovery marks a session created to recover the
+// funds of a wallet left in channel_open by a crash. The server refunds the
+// wallet to its sender and then settles the session.
+const SessionStatusOrphanedRecovery = "orphaned_recovery"
+
+// OrphanedWalletAge is how long a wallet must have been in channel_open
+// before ReclaimOrphanedWallets treats it as orphaned.
+const OrphanedWalletAge = time.Hour
+
+// BalanceChecker reports the on-chain balance of a wallet address in
+// shannons.
+type BalanceChecker interface {
+	CheckBalance(ctx context.Context, address string) (int64, error)
+}
+
+// ReclaimOrphanedWallets finds wallets that have been in channel_open for
+// longer than OrphanedWalletAge and still hold CKB on-chain. Each one gets a
+// new session in SessionStatusOrphanedRecovery, which queues it for forced
+// settlement, and moves to the same status so it isn't reclaimed twice.
+// The recovery session keeps the channel ID of the wallet's last session so
+// the channel can be settled before the refund.
+//
+// Long sessions keep their wallet in channel_open, so wallets whose session
+// is still opening, active or settling in the database and hasn't expired
+// are skipped, as are those for which live reports true; live may be nil. Wallets without a
+// balance are left alone. A failed check skips that wallet; the failures are
+// returned joined together with the number reclaimed.
+func (db *DB) ReclaimOrphanedWallets(ctx context.Context, checker BalanceChecker, live func(sessionID string) bool) (int, error) {
+	rows, err := db.conn.Query(`SELECT `+walletColumns+` FROM guest_wallets
+		WHERE status = 'channel_open' AND COALESCE(updated_at, funded_at, created_at) < ?
+		AND NOT EXISTS (SELECT 1 FROM sessions
+			WHERE sessions.id = guest_wallets.session_id
+			AND sessions.status IN ('channel_opening', 'active', 'settling')
+			AND sessions.expires_at > ?)
+		ORDER BY created_at`, time.Now().Add(-OrphanedWalletAge), time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to list orphaned wallets: %w", err)
+	}
+	wallets, err := db.scanWallets(rows)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list orphaned wallets: %w", err)
+	}
+
+	var reclaimed int
+	var errs []error
+	for _, w := range wallets {
+		if err := ctx.Err(); err != nil {
+			return reclaimed, errors.Join(append(errs, err)...)
+		}
+		if live != nil && w.SessionID != "" && live(w.SessionID) {
+			continue
+		}
+		balance, err := checker.CheckBalance(ctx, w.Address)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("wallet %s: %w", w.ID, err))
+			continue
+		}
+		if balance <= 0 {
+			continue
+		}
+
+		now := time.Now()
+		balanceCKB := balance / shannonsPerCKB
+		session := &Session{
+			ID:            "recovery-" + w.ID,
+			WalletID:      w.ID,
+			ChannelID:     db.lastChannelID(w.SessionID),
+			GuestAddress:  w.Address,
+			FundingCKB:    balanceCKB,
+			BalanceCKB:    balanceCKB,
+			CreatedAt:     now,
+			ExpiresAt:     now,
+			Status:        SessionStatusOrphanedRecovery,
+			MACAddress:    w.MACAddress,
+			IPAddress:     w.IPAddress,
+			SenderAddress: w.SenderAddress,
+		}
+		err = db.Transaction(func(tx *DB) error {
+			if err := tx.CreateSession(session); err != nil {
+				return fmt.Errorf("failed to create recovery session: %w", err)
+			}
+			if _, err := tx.conn.Exec(`UPDATE guest_wallets SET session_id = ?, status = ?, updated_at = ? WHERE id = ?`,
+				session.ID, SessionStatusOrphanedRecovery, now, w.ID); err != nil {
+				return fmt.Errorf("failed to mark wallet orphaned: %w", err)
+			}
+			return nil
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("wallet %s: %w", w.ID, err))
+			continue
+		}
+		reclaimed++
+	}
+	return reclaimed, errors.Join(errs...)
+}
+
+// lastChannelID returns the channel ID of sessionID, or the zero ID if the
+// session is unknown.
+func (db *DB) lastChannelID(sessionID string) perun.ChannelID {
+	if sessionID == "" {
+		return perun.ChannelID{}
+	}
+	session, err := db.GetSession(sessionID)
+	if err != nil {
+		return perun.ChannelID{}
+	}
+	return session.ChannelID
+}
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
+)
+
+// mockBalanceChecker returns fixed balances per address.
+type mockBalanceChecker struct {
+	balances map[string]int64
+	errs     map[string]error
+	checked  []string
+}
+
+func (m *mockBalanceChecker) CheckBalance(ctx context.Context, address string) (int64, error) {
+	m.checked = append(m.checked, address)
+	if err := m.errs[address]; err != nil {
+		return 0, err
+	}
+	return m.balances[address], nil
+}
+
+func TestDB_ReclaimOrphanedWallets(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	stale := time.Now().Add(-2 * time.Hour)
+	wallets := []struct {
+		id, status string
+		updatedAt  time.Time
+	}{
+		{"w-orphaned", "channel_open", stale},
+		{"w-empty", "channel_open", stale},
+		{"w-failing", "channel_open", stale},
+		{"w-recent", "channel_open", time.Now()},
+		{"w-active", "active", stale},
+		{"w-long", "channel_open", stale},
+		{"w-live", "channel_open", stale},
+	}
+	for _, w := range wallets {
+		db.CreateGuestWallet(&GuestWallet{ID: w.id, Address: "ckt1" + w.id, SenderAddress: "ckt1sender", CreatedAt: stale, Status: w.status})
+		db.conn.Exec(`UPDATE guest_wallets SET updated_at = ? WHERE id = ?`, w.updatedAt, w.id)
+	}
+
+	// The crashed session ran out long ago; the others are still running
+	sessions := []struct{ id, walletID, status string }{
+		{"s-crashed", "w-orphaned", "active"},
+		{"s-long", "w-long", "active"},
+		{"s-live", "w-live", "expired"},
+	}
+	for i, sess := range sessions {
+		expiresAt := time.Now().Add(time.Hour)
+		if sess.id == "s-crashed" {
+			expiresAt = stale
+		}
+		db.CreateSession(&Session{ID: sess.id, WalletID: sess.walletID, ChannelID: perun.ChannelID{byte(i + 1)}, CreatedAt: stale, ExpiresAt: expiresAt, Status: sess.status})
+		db.conn.Exec(`UPDATE guest_wallets SET session_id = ? WHERE id = ?`, sess.id, sess.walletID)
+	}
+	live := func(sessionID string) bool { return sessionID == "s-live" }
+
+	errUnreachable := errors.New("indexer unreachable")
+	checker := &mockBalanceChecker{
+		balances: map[string]int64{
+			"ckt1w-orphaned": 1500 * 100000000,
+			"ckt1w-recent":   1500 * 100000000,
+			"ckt1w-active":   1500 * 100000000,
+			"ckt1w-long":     1500 * 100000000,
+			"ckt1w-live":     1500 * 100000000,
+		},
+		errs: map[string]error{"ckt1w-failing": errUnreachable},
+	}
+
+	reclaimed, err := db.ReclaimOrphanedWallets(context.Background(), checker, live)
+	if reclaimed != 1 {
+		t.Errorf("Expected 1 wallet reclaimed, got %d", reclaimed)
+	}
+	if !errors.Is(err, errUnreachable) {
+		t.Errorf("Expected the failed check to be returned, got %v", err)
+	}
+	if len(checker.checked) != 3 {
+		t.Errorf("Expected only stale channel_open wallets checked, got %v", checker.checked)
+	}
+
+	session, err := db.GetSession("recovery-w-orphaned")
+	if err != nil {
+		t.Fatalf("Expected a recovery session: %v", err)
+	}
+	if session.Status != SessionStatusOrphanedRecovery || session.WalletID != "w-orphaned" || session.BalanceCKB != 1500 || session.SenderAddress != "ckt1sender" || session.ChannelID != (perun.ChannelID{1}) {
+		t.Errorf("Unexpected recovery session: %+v", session)
+	}
+	wallet, _ := db.GetGuestWallet("w-orphaned")
+	if wallet.Status != SessionStatusOrphanedRecovery || wallet.SessionID != session.ID {
+		t.Errorf("Expected wallet linked to the recovery session, got status %s session %s", wallet.Status, wallet.SessionID)
+	}
+
+	for _, id := range []string{"w-empty", "w-failing", "w-recent", "w-long", "w-live"} {
+		if w, _ := db.GetGuestWallet(id); w.Status != "channel_open" {
+			t.Errorf("%s: expected status unchanged, got %s", id, w.Status)
+		}
+	}
+
+	// Reclaimed wallets aren't picked up again
+	checker.checked = nil
+	if reclaimed, _ := db.ReclaimOrphanedWallets(context.Background(), checker, live); reclaimed != 0 {
+		t.Errorf("Expected nothing reclaimed on the second run, got %d", reclaimed)
+	}
+	for _, address := range checker.checked {
+		if address == "ckt1w-orphaned" {
+			t.Error("Expected the reclaimed wallet not to be checked again")
+		}
+	}
+}
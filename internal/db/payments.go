@@ -0,0 +1,123 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
+)
+
+// Kinds of channel payment recorded in the payment history.
+const (
+	PaymentMicropayment = "micropayment" // Per-minute payment while the session runs
+	PaymentCatchUp      = "catch_up"     // Time elapsed before the channel opened
+	PaymentExtension    = "extension"    // Guest-requested session extension
+)
+
+// PaymentRecord is one off-chain payment made on a session's channel.
+type PaymentRecord struct {
+	ID             int64
+	SessionID      string
+	AmountShannons int64
+	Kind           string
+	CreatedAt      time.Time
+}
+
+// PaymentSummary is what a guest paid for a session, as shown on a receipt.
+type PaymentSummary struct {
+	SessionID      string
+	StartTime      time.Time
+	EndTime        time.Time
+	TotalDuration  time.Duration // Time between start and end
+	BilledDuration time.Duration // Time the guest paid for
+	TotalCKBSpent  int64
+	Payments       []PaymentRecord
+	ChannelID      string
+	GuestAddress   string
+	HostAddress    string
+}
+
+// RecordPayment appends a payment to a session's history and sets its ID.
+// A zero CreatedAt is set to the current time.
+func (db *DB) RecordPayment(p *PaymentRecord) error {
+	if p.CreatedAt.IsZero() {
+		p.CreatedAt = time.Now()
+	}
+	p.CreatedAt = p.CreatedAt.UTC()
+
+	result, err := db.conn.Exec(`
+		INSERT INTO payments (session_id, amount_shannons, kind, created_at)
+		VALUES (?, ?, ?, ?)
+	`, p.SessionID, p.AmountShannons, p.Kind, p.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record payment: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get payment id: %w", err)
+	}
+	p.ID = id
+	return nil
+}
+
+// GetPaymentHistory returns a session's payments, oldest first.
+func (db *DB) GetPaymentHistory(sessionID string) ([]PaymentRecord, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, session_id, amount_shannons, kind, created_at
+		FROM payments WHERE session_id = ? ORDER BY created_at, id
+	`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := []PaymentRecord{}
+	for rows.Next() {
+		var p PaymentRecord
+		if err := rows.Scan(&p.ID, &p.SessionID, &p.AmountShannons, &p.Kind, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		payments = append(payments, p)
+	}
+	return payments, rows.Err()
+}
+
+// GetSessionPaymentSummary summarizes a session's payments for a receipt.
+// A session that hasn't been settled ends now, or at its expiry if that
+// has passed. Returns sql.ErrNoRows if the session does not exist.
+func (db *DB) GetSessionPaymentSummary(sessionID string) (*PaymentSummary, error) {
+	session, err := db.GetSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	payments, err := db.GetPaymentHistory(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	end := time.Now()
+	if session.SettledAt != nil {
+		end = *session.SettledAt
+	} else if session.ExpiresAt.Before(end) {
+		end = session.ExpiresAt
+	}
+
+	summary := &PaymentSummary{
+		SessionID:     session.ID,
+		StartTime:     session.CreatedAt,
+		EndTime:       end,
+		TotalDuration: end.Sub(session.CreatedAt),
+		TotalCKBSpent: session.SpentCKB,
+		Payments:      payments,
+		GuestAddress:  session.GuestAddress,
+		HostAddress:   session.HostAddress,
+	}
+	if !session.ExpiresAt.IsZero() {
+		summary.BilledDuration = session.ExpiresAt.Sub(session.CreatedAt)
+	}
+	if session.ChannelID != (perun.ChannelID{}) {
+		summary.ChannelID = session.ChannelID.String()
+	}
+	return summary, nil
+}
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
+)
+
+func TestDB_GetSessionPaymentSummary(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	if _, err := db.GetSessionPaymentSummary("missing"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("Expected sql.ErrNoRows for an unknown session, got %v", err)
+	}
+
+	start := time.Now().Add(-20 * time.Minute).UTC()
+	db.CreateSession(&Session{
+		ID:           "s1",
+		WalletID:     "w1",
+		ChannelID:    perun.ChannelID{0xab},
+		GuestAddress: "ckt1guest",
+		HostAddress:  "ckt1host",
+		SpentCKB:     75,
+		CreatedAt:    start,
+		ExpiresAt:    start.Add(90 * time.Minute),
+		Status:       "active",
+	})
+
+	for i, kind := range []string{PaymentCatchUp, PaymentMicropayment, PaymentExtension} {
+		err := db.RecordPayment(&PaymentRecord{
+			SessionID:      "s1",
+			AmountShannons: int64(i+1) * 100000000,
+			Kind:           kind,
+			CreatedAt:      start.Add(time.Duration(i) * time.Minute),
+		})
+		if err != nil {
+			t.Fatalf("RecordPayment failed: %v", err)
+		}
+	}
+	db.RecordPayment(&PaymentRecord{SessionID: "other", AmountShannons: 1, Kind: PaymentMicropayment})
+
+	summary, err := db.GetSessionPaymentSummary("s1")
+	if err != nil {
+		t.Fatalf("GetSessionPaymentSummary failed: %v", err)
+	}
+	if summary.ChannelID != (perun.ChannelID{0xab}).String() || summary.GuestAddress != "ckt1guest" || summary.TotalCKBSpent != 75 {
+		t.Errorf("Unexpected summary: %+v", summary)
+	}
+	if len(summary.Payments) != 3 || summary.Payments[0].Kind != PaymentCatchUp || summary.Payments[2].AmountShannons != 300000000 {
+		t.Errorf("Unexpected payments: %+v", summary.Payments)
+	}
+	if summary.BilledDuration != 90*time.Minute {
+		t.Errorf("Expected 90m billed, got %s", summary.BilledDuration)
+	}
+	// An active session ends now
+	if summary.TotalDuration < 20*time.Minute || summary.TotalDuration > 21*time.Minute {
+		t.Errorf("Expected about 20m used, got %s", summary.TotalDuration)
+	}
+
+	// A settled session ends when it was settled
+	db.SettleSession("s1")
+	summary, err = db.GetSessionPaymentSummary("s1")
+	if err != nil {
+		t.Fatalf("GetSessionPaymentSummary failed: %v", err)
+	}
+	session, _ := db.GetSession("s1")
+	if session.SettledAt == nil || !summary.EndTime.Equal(*session.SettledAt) {
+		t.Errorf("Expected end time %v, got %v", session.SettledAt, summary.EndTime)
+	}
+}
@@ -0,0 +1,38 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// GetSessionsWithStaleStatus returns active sessions whose channel checker
+// reports as already settled on-chain. Sessions without a channel are
+// skipped. A failed check skips that session; the failures are returned
+// joined together with the sessions that could be checked.
+func (db *DB) GetSessionsWithStaleStatus(ctx context.Context, checker func(channelID string) (bool, error)) ([]*Session, error) {
+	active, err := db.ListSessions("active")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list active sessions: %w", err)
+	}
+
+	var stale []*Session
+	var errs []error
+	for _, s := range active {
+		if err := ctx.Err(); err != nil {
+			return stale, errors.Join(append(errs, err)...)
+		}
+		if s.ChannelID.IsZero() {
+			continue
+		}
+		settled, err := checker(s.ChannelID.String())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("session %s: %w", s.ID, err))
+			continue
+		}
+		if settled {
+			stale = append(stale, s)
+		}
+	}
+	return stale, errors.Join(errs...)
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
+)
+
+func TestDB_GetSessionsWithStaleStatus(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	channelID := func(b byte) perun.ChannelID {
+		var id perun.ChannelID
+		id[0] = b
+		return id
+	}
+
+	expires := time.Now().Add(time.Hour)
+	db.CreateSession(&Session{ID: "settled-on-chain", ChannelID: channelID(1), Status: "active", ExpiresAt: expires})
+	db.CreateSession(&Session{ID: "still-open", ChannelID: channelID(2), Status: "active", ExpiresAt: expires})
+	db.CreateSession(&Session{ID: "check-fails", ChannelID: channelID(3), Status: "active", ExpiresAt: expires})
+	db.CreateSession(&Session{ID: "no-channel", Status: "active", ExpiresAt: expires})
+	db.CreateSession(&Session{ID: "already-settled", ChannelID: channelID(4), Status: "settled", ExpiresAt: expires})
+
+	errLookup := errors.New("indexer unavailable")
+	var checked []string
+	checker := func(id string) (bool, error) {
+		checked = append(checked, id)
+		switch id {
+		case channelID(1).String(), channelID(4).String():
+			return true, nil
+		case channelID(3).String():
+			return false, errLookup
+		default:
+			return false, nil
+		}
+	}
+
+	stale, err := db.GetSessionsWithStaleStatus(context.Background(), checker)
+	if !errors.Is(err, errLookup) {
+		t.Errorf("expected the checker error to be returned, got %v", err)
+	}
+	if len(stale) != 1 || stale[0].ID != "settled-on-chain" {
+		t.Fatalf("expected only settled-on-chain to be stale, got %v", stale)
+	}
+	if len(checked) != 3 {
+		t.Errorf("expected 3 active sessions with channels to be checked, got %d", len(checked))
+	}
+}
+
+func TestDB_GetSessionsWithStaleStatus_Cancelled(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	var id perun.ChannelID
+	id[0] = 1
+	db.CreateSession(&Session{ID: "s1", ChannelID: id, Status: "active", ExpiresAt: time.Now().Add(time.Hour)})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := db.GetSessionsWithStaleStatus(ctx, func(string) (bool, error) {
+		t.Error("checker should not run after cancellation")
+		return false, nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// replicaMaxOpenConns caps concurrent reads on the read replica.
+const replicaMaxOpenConns = 16
+
+// OpenReadReplica opens path read-only and routes analytics reads to it, so
+// long report queries don't hold connections the writers need. path is
+// normally the primary database file itself. Reads inside a transaction
+// still use the transaction so they see its uncommitted writes. SQLite only
+// lets readers and a writer proceed together in WAL journal mode; with the
+// default rollback journal the replica separates the connection pools but
+// reads can still delay writes.
+func (db *DB) OpenReadReplica(path string) (*sql.DB, error) {
+	replica, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?mode=ro", path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open read replica: %w", err)
+	}
+	replica.SetMaxOpenConns(replicaMaxOpenConns)
+	if err := replica.Ping(); err != nil {
+		replica.Close()
+		return nil, fmt.Errorf("failed to open read replica: %w", err)
+	}
+
+	if db.replica != nil {
+		db.replica.Close()
+	}
+	db.replica = replica
+	return replica, nil
+}
+
+// readConn returns the connection reads should use: the replica when one is
+// open, otherwise the primary or the current transaction.
+func (db *DB) readConn() querier {
+	if db.replica != nil && !db.inTx {
+		return db.replica
+	}
+	return db.conn
+}
+
+// QueryRead runs a read-only query on the read replica if one is open, or
+// on the primary otherwise. Writes must go through the primary.
+func (db *DB) QueryRead(query string, args ...interface{}) (*sql.Rows, error) {
+	return db.readConn().Query(query, args...)
+}
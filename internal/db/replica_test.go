@@ -0,0 +1,117 @@
+package db
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDB_OpenReadReplica(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	db.CreateSession(&Session{ID: "s1", Status: "active", CreatedAt: now, ExpiresAt: now.Add(time.Hour), SpentCKB: 40})
+
+	replica, err := db.OpenReadReplica(path)
+	if err != nil {
+		t.Fatalf("OpenReadReplica failed: %v", err)
+	}
+	if _, err := replica.Exec(`UPDATE sessions SET status = 'settled'`); err == nil {
+		t.Error("Expected writes on the read replica to fail")
+	}
+
+	// Reads see data committed on the primary
+	db.CreateSession(&Session{ID: "s2", Status: "settled", CreatedAt: now, ExpiresAt: now, SpentCKB: 60})
+	sessions, err := db.ListSessions("")
+	if err != nil || len(sessions) != 2 {
+		t.Fatalf("Expected 2 sessions from replica, got %d (%v)", len(sessions), err)
+	}
+	total, active, earned, err := db.GetStats()
+	if err != nil || total != 2 || active != 1 || earned != 100 {
+		t.Errorf("GetStats: got total=%d active=%d earned=%d (%v)", total, active, earned, err)
+	}
+
+	// Reads inside a transaction see its uncommitted writes
+	db.Transaction(func(tx *DB) error {
+		tx.CreateSession(&Session{ID: "s3", Status: "active", CreatedAt: now, ExpiresAt: now})
+		sessions, err := tx.ListSessions("")
+		if err != nil || len(sessions) != 3 {
+			t.Errorf("Expected 3 sessions inside transaction, got %d (%v)", len(sessions), err)
+		}
+		return nil
+	})
+}
+
+func TestDB_OpenReadReplica_MissingFile(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	if _, err := db.OpenReadReplica(filepath.Join(t.TempDir(), "missing.db")); err == nil {
+		t.Error("Expected error opening a missing replica file read-only")
+	}
+}
+
+// BenchmarkWriteDuringAnalytics measures session writes while analytics
+// queries run concurrently, on the primary connection and on the replica.
+func BenchmarkWriteDuringAnalytics(b *testing.B) {
+	for _, useReplica := range []bool{false, true} {
+		name := "primary"
+		if useReplica {
+			name = "replica"
+		}
+		b.Run(name, func(b *testing.B) {
+			path := filepath.Join(b.TempDir(), "bench.db")
+			db, err := Open(path)
+			if err != nil {
+				b.Fatalf("Open failed: %v", err)
+			}
+			defer db.Close()
+
+			now := time.Now()
+			for i := 0; i < 1000; i++ {
+				db.CreateSession(&Session{ID: fmt.Sprintf("s%d", i), Status: "settled", CreatedAt: now, ExpiresAt: now, FundingCKB: 1000, SpentCKB: int64(i)})
+			}
+			if useReplica {
+				if _, err := db.OpenReadReplica(path); err != nil {
+					b.Fatalf("OpenReadReplica failed: %v", err)
+				}
+			}
+
+			stop := make(chan struct{})
+			var wg sync.WaitGroup
+			for r := 0; r < 4; r++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for {
+						select {
+						case <-stop:
+							return
+						default:
+							db.GetYearlyRevenueSummary(now.Year())
+							db.SearchSessions(&SessionQuery{Status: "settled"})
+						}
+					}
+				}()
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := db.UpdateSessionStatus(fmt.Sprintf("s%d", i%1000), "active"); err != nil {
+					b.Fatalf("UpdateSessionStatus failed: %v", err)
+				}
+			}
+			b.StopTimer()
+
+			close(stop)
+			wg.Wait()
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
+)
+
+// shannonsPerCKB converts the CKB amounts stored in the database.
+const shannonsPerCKB = 100000000
+
+// CKBFlowSummary reconciles CKB that entered through guest wallets with CKB
+// that left through settlements and refunds. All amounts are in shannons.
+type CKBFlowSummary struct {
+	TotalFundedShannons   int64 // Funding of sessions created in the range
+	TotalSettledShannons  int64 // Spent by guests on settled sessions
+	TotalRefundedShannons int64 // Returned from withdrawn wallets, fees included
+	TotalPendingShannons  int64 // Funded minus settled minus refunded
+	TotalFeesShannons     int64 // Refund transaction fees, estimated at perun.WithdrawFee each
+}
+
+// MonthlyRevenue summarizes sessions created in one calendar month (UTC).
+type MonthlyRevenue struct {
+	Year             int
+	Month            int
+	TotalSessions    int
+	ActiveSessions   int
+	SettledSessions  int
+	TotalFundedCKB   int64
+	TotalSpentCKB    int64
+	TotalRefundedCKB int64 // Unspent funding returned on settled sessions
+	UniqueGuests     int   // Distinct devices (MAC address, or guest address if unknown)
+}
+
+// GetMonthlyRevenueSummary returns revenue totals for sessions created in the given month.
+// Months without sessions return a zero summary.
+func (db *DB) GetMonthlyRevenueSummary(year, month int) (*MonthlyRevenue, error) {
+	if year < 1 || year > 9999 {
+		return nil, fmt.Errorf("invalid year: %d", year)
+	}
+	if month < 1 || month > 12 {
+		return nil, fmt.Errorf("invalid month: %d", month)
+	}
+
+	r := &MonthlyRevenue{Year: year, Month: month}
+	err := db.readConn().QueryRow(`
+		SELECT
+			COUNT(*),
+			COALESCE(SUM(CASE WHEN status = 'active' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status = 'settled' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(funding_ckb), 0),
+			COALESCE(SUM(spent_ckb), 0),
+			COALESCE(SUM(CASE WHEN status = 'settled' THEN funding_ckb - spent_ckb ELSE 0 END), 0),
+			COUNT(DISTINCT COALESCE(NULLIF(mac_address, ''), guest_address))
+		FROM sessions
+		WHERE strftime('%Y-%m', created_at) = ?
+	`, fmt.Sprintf("%04d-%02d", year, month)).Scan(
+		&r.TotalSessions,
+		&r.ActiveSessions,
+		&r.SettledSessions,
+		&r.TotalFundedCKB,
+		&r.TotalSpentCKB,
+		&r.TotalRefundedCKB,
+		&r.UniqueGuests,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query monthly revenue: %w", err)
+	}
+	return r, nil
+}
+
+// GetYearlyRevenueSummary returns one summary per month of the year, January first.
+func (db *DB) GetYearlyRevenueSummary(year int) ([]*MonthlyRevenue, error) {
+	months := make([]*MonthlyRevenue, 0, 12)
+	for month := 1; month <= 12; month++ {
+		r, err := db.GetMonthlyRevenueSummary(year, month)
+		if err != nil {
+			return nil, err
+		}
+		months = append(months, r)
+	}
+	return months, nil
+}
+
+// GetCKBFlowSummary totals CKB flows for sessions and wallets created in
+// [from, to). A zero from or to leaves that end of the range open.
+//
+// A withdrawn wallet's refund is its session's remaining balance, or the
+// wallet balance when it never got a session. Fees aren't recorded per
+// transaction, so TotalFeesShannons assumes the default withdraw fee.
+func (db *DB) GetCKBFlowSummary(from, to time.Time) (*CKBFlowSummary, error) {
+	sessionRange, sessionArgs := createdAtRange("created_at", from, to)
+	r := &CKBFlowSummary{}
+	err := db.readConn().QueryRow(`
+		SELECT
+			COALESCE(SUM(funding_ckb), 0),
+			COALESCE(SUM(CASE WHEN status = 'settled' THEN spent_ckb ELSE 0 END), 0)
+		FROM sessions
+		WHERE 1 = 1`+sessionRange, sessionArgs...).Scan(&r.TotalFundedShannons, &r.TotalSettledShannons)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query session flows: %w", err)
+	}
+
+	walletRange, walletArgs := createdAtRange("w.created_at", from, to)
+	var withdrawn int64
+	err = db.readConn().QueryRow(`
+		SELECT
+			COALESCE(SUM(COALESCE(s.balance_ckb, w.balance_ckb)), 0),
+			COUNT(*)
+		FROM guest_wallets w
+		LEFT JOIN sessions s ON s.id = w.session_id AND w.session_id != ''
+		WHERE w.status = 'withdrawn'`+walletRange, walletArgs...).Scan(&r.TotalRefundedShannons, &withdrawn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query refund flows: %w", err)
+	}
+
+	r.TotalFundedShannons *= shannonsPerCKB
+	r.TotalSettledShannons *= shannonsPerCKB
+	r.TotalRefundedShannons *= shannonsPerCKB
+	r.TotalPendingShannons = r.TotalFundedShannons - r.TotalSettledShannons - r.TotalRefundedShannons
+	r.TotalFeesShannons = withdrawn * int64(perun.WithdrawFee)
+	return r, nil
+}
+
+// GuestStats aggregates a guest's sessions. Each session pays from a fresh
+// guest wallet, so guests are told apart by the address that funded it, or
+// by MAC address when no sender was detected.
+type GuestStats struct {
+	Guest         string
+	SessionCount  int
+	TotalSpentCKB int64
+}
+
+// GetHighValueSessions returns sessions whose spent_ckb is at least
+// minSpentCKB, biggest spenders first.
+func (db *DB) GetHighValueSessions(minSpentCKB int64) ([]*Session, error) {
+	rows, err := db.QueryRead(`SELECT `+sessionColumns+` FROM sessions WHERE spent_ckb >= ? ORDER BY spent_ckb DESC, created_at DESC`, minSpentCKB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query high value sessions: %w", err)
+	}
+	return scanSessions(rows)
+}
+
+// GetTopGuests returns up to limit guests ordered by their lifetime spend
+// across all sessions.
+func (db *DB) GetTopGuests(limit int) ([]GuestStats, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("invalid limit: %d", limit)
+	}
+
+	rows, err := db.QueryRead(`
+		SELECT COALESCE(NULLIF(sender_address, ''), mac_address) AS guest,
+			COUNT(*), COALESCE(SUM(spent_ckb), 0)
+		FROM sessions
+		WHERE COALESCE(NULLIF(sender_address, ''), mac_address, '') != ''
+		GROUP BY guest
+		ORDER BY SUM(spent_ckb) DESC, guest
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query top guests: %w", err)
+	}
+	defer rows.Close()
+
+	var guests []GuestStats
+	for rows.Next() {
+		var g GuestStats
+		if err := rows.Scan(&g.Guest, &g.SessionCount, &g.TotalSpentCKB); err != nil {
+			return nil, fmt.Errorf("failed to scan guest stats: %w", err)
+		}
+		guests = append(guests, g)
+	}
+	return guests, rows.Err()
+}
+
+// createdAtRange returns the SQL condition and arguments restricting column
+// to [from, to), skipping zero bounds. Times are stored with the offset of
+// the zone they were created in, so both sides are compared through
+// datetime(), which converts them to UTC.
+func createdAtRange(column string, from, to time.Time) (string, []interface{}) {
+	var cond string
+	var args []interface{}
+	if !from.IsZero() {
+		cond += ` AND datetime(` + column + `) >= datetime(?)`
+		args = append(args, from.UTC())
+	}
+	if !to.IsZero() {
+		cond += ` AND datetime(` + column + `) < datetime(?)`
+		args = append(args, to.UTC())
+	}
+	return cond, args
+}
@@ -0,0 +1,235 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDB_GetMonthlyRevenueSummary(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	june := time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+	db.CreateSession(&Session{ID: "s1", GuestAddress: "ckt1a", MACAddress: "aa:aa", FundingCKB: 1500, SpentCKB: 400, Status: "settled", CreatedAt: june, ExpiresAt: june})
+	db.CreateSession(&Session{ID: "s2", GuestAddress: "ckt1b", MACAddress: "aa:aa", FundingCKB: 2000, SpentCKB: 100, Status: "active", CreatedAt: june.Add(24 * time.Hour), ExpiresAt: june})
+	db.CreateSession(&Session{ID: "s3", GuestAddress: "ckt1c", FundingCKB: 1000, SpentCKB: 1000, Status: "settled", CreatedAt: june.Add(48 * time.Hour), ExpiresAt: june})
+	db.CreateSession(&Session{ID: "s4", GuestAddress: "ckt1d", FundingCKB: 9999, Status: "settled", CreatedAt: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC), ExpiresAt: june})
+
+	r, err := db.GetMonthlyRevenueSummary(2025, 6)
+	if err != nil {
+		t.Fatalf("GetMonthlyRevenueSummary failed: %v", err)
+	}
+
+	if r.TotalSessions != 3 {
+		t.Errorf("TotalSessions: expected 3, got %d", r.TotalSessions)
+	}
+	if r.ActiveSessions != 1 || r.SettledSessions != 2 {
+		t.Errorf("Active/Settled: expected 1/2, got %d/%d", r.ActiveSessions, r.SettledSessions)
+	}
+	if r.TotalFundedCKB != 4500 {
+		t.Errorf("TotalFundedCKB: expected 4500, got %d", r.TotalFundedCKB)
+	}
+	if r.TotalSpentCKB != 1500 {
+		t.Errorf("TotalSpentCKB: expected 1500, got %d", r.TotalSpentCKB)
+	}
+	if r.TotalRefundedCKB != 1100 {
+		t.Errorf("TotalRefundedCKB: expected 1100, got %d", r.TotalRefundedCKB)
+	}
+	if r.UniqueGuests != 2 {
+		t.Errorf("UniqueGuests: expected 2, got %d", r.UniqueGuests)
+	}
+}
+
+func TestDB_GetMonthlyRevenueSummary_LeapYear(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	leapDay := time.Date(2024, 2, 29, 23, 30, 0, 0, time.UTC)
+	db.CreateSession(&Session{ID: "s1", GuestAddress: "ckt1a", FundingCKB: 1500, Status: "active", CreatedAt: leapDay, ExpiresAt: leapDay})
+	db.CreateSession(&Session{ID: "s2", GuestAddress: "ckt1b", FundingCKB: 1500, Status: "active", CreatedAt: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), ExpiresAt: leapDay})
+
+	feb, err := db.GetMonthlyRevenueSummary(2024, 2)
+	if err != nil {
+		t.Fatalf("GetMonthlyRevenueSummary failed: %v", err)
+	}
+	if feb.TotalSessions != 1 {
+		t.Errorf("February 2024: expected 1 session, got %d", feb.TotalSessions)
+	}
+
+	mar, _ := db.GetMonthlyRevenueSummary(2024, 3)
+	if mar.TotalSessions != 1 {
+		t.Errorf("March 2024: expected 1 session, got %d", mar.TotalSessions)
+	}
+}
+
+func TestDB_GetMonthlyRevenueSummary_Empty(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	r, err := db.GetMonthlyRevenueSummary(2025, 1)
+	if err != nil {
+		t.Fatalf("GetMonthlyRevenueSummary failed: %v", err)
+	}
+	if r.Year != 2025 || r.Month != 1 {
+		t.Errorf("Expected 2025-01, got %d-%d", r.Year, r.Month)
+	}
+	if r.TotalSessions != 0 || r.TotalFundedCKB != 0 || r.UniqueGuests != 0 {
+		t.Errorf("Expected empty summary, got %+v", r)
+	}
+
+	if _, err := db.GetMonthlyRevenueSummary(2025, 13); err == nil {
+		t.Error("Expected error for month 13")
+	}
+}
+
+func TestDB_GetYearlyRevenueSummary(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	created := time.Date(2025, 6, 15, 0, 0, 0, 0, time.UTC)
+	db.CreateSession(&Session{ID: "s1", GuestAddress: "ckt1a", SpentCKB: 300, Status: "settled", CreatedAt: created, ExpiresAt: created})
+
+	months, err := db.GetYearlyRevenueSummary(2025)
+	if err != nil {
+		t.Fatalf("GetYearlyRevenueSummary failed: %v", err)
+	}
+	if len(months) != 12 {
+		t.Fatalf("Expected 12 months, got %d", len(months))
+	}
+	for i, m := range months {
+		if m.Month != i+1 {
+			t.Errorf("Position %d: expected month %d, got %d", i, i+1, m.Month)
+		}
+	}
+	if months[5].TotalSpentCKB != 300 {
+		t.Errorf("June spent: expected 300, got %d", months[5].TotalSpentCKB)
+	}
+}
+
+func TestDB_GetCKBFlowSummary(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	june := time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+	db.CreateSession(&Session{ID: "s1", WalletID: "w1", FundingCKB: 1500, SpentCKB: 400, BalanceCKB: 1100, Status: "settled", CreatedAt: june, ExpiresAt: june})
+	db.CreateSession(&Session{ID: "s2", WalletID: "w2", FundingCKB: 2000, SpentCKB: 100, BalanceCKB: 1900, Status: "active", CreatedAt: june, ExpiresAt: june})
+	db.CreateSession(&Session{ID: "s3", FundingCKB: 9999, SpentCKB: 9999, Status: "settled", CreatedAt: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC), ExpiresAt: june})
+
+	// w1 was refunded its session's remainder; w3 never got a session
+	db.CreateGuestWallet(&GuestWallet{ID: "w1", Address: "ckt1a", BalanceCKB: 1500, SessionID: "s1", Status: "withdrawn", CreatedAt: june})
+	db.CreateGuestWallet(&GuestWallet{ID: "w2", Address: "ckt1b", BalanceCKB: 2000, SessionID: "s2", Status: "funded", CreatedAt: june})
+	db.CreateGuestWallet(&GuestWallet{ID: "w3", Address: "ckt1c", BalanceCKB: 70, Status: "withdrawn", CreatedAt: june})
+
+	r, err := db.GetCKBFlowSummary(time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("GetCKBFlowSummary failed: %v", err)
+	}
+
+	const shannons = 100000000
+	if r.TotalFundedShannons != 3500*shannons {
+		t.Errorf("TotalFundedShannons: expected %d, got %d", 3500*shannons, r.TotalFundedShannons)
+	}
+	if r.TotalSettledShannons != 400*shannons {
+		t.Errorf("TotalSettledShannons: expected %d, got %d", 400*shannons, r.TotalSettledShannons)
+	}
+	if r.TotalRefundedShannons != 1170*shannons {
+		t.Errorf("TotalRefundedShannons: expected %d, got %d", 1170*shannons, r.TotalRefundedShannons)
+	}
+	if want := r.TotalFundedShannons - r.TotalSettledShannons - r.TotalRefundedShannons; r.TotalPendingShannons != want {
+		t.Errorf("TotalPendingShannons: expected %d, got %d", want, r.TotalPendingShannons)
+	}
+	if r.TotalFeesShannons != 2*100000 {
+		t.Errorf("TotalFeesShannons: expected %d, got %d", 2*100000, r.TotalFeesShannons)
+	}
+
+	all, err := db.GetCKBFlowSummary(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("GetCKBFlowSummary failed: %v", err)
+	}
+	if all.TotalFundedShannons != (3500+9999)*shannons {
+		t.Errorf("Open range: expected %d funded, got %d", (3500+9999)*shannons, all.TotalFundedShannons)
+	}
+}
+
+func TestDB_GetCKBFlowSummary_LocalOffset(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	// 01:00 on July 1st at UTC+2 is still June 30th in UTC
+	cest := time.FixedZone("CEST", 2*60*60)
+	db.CreateSession(&Session{ID: "s1", FundingCKB: 100, Status: "active", CreatedAt: time.Date(2025, 7, 1, 1, 0, 0, 0, cest), ExpiresAt: time.Now()})
+	// 23:00 on June 30th at UTC-2 is already July 1st in UTC
+	db.CreateSession(&Session{ID: "s2", FundingCKB: 200, Status: "active", CreatedAt: time.Date(2025, 6, 30, 23, 0, 0, 0, time.FixedZone("", -2*60*60)), ExpiresAt: time.Now()})
+
+	r, err := db.GetCKBFlowSummary(time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("GetCKBFlowSummary failed: %v", err)
+	}
+	if r.TotalFundedShannons != 100*shannonsPerCKB {
+		t.Errorf("TotalFundedShannons: expected only s1 in June, got %d", r.TotalFundedShannons)
+	}
+}
+
+func TestDB_GetTopGuests(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	now := time.Now()
+	// Every session has its own guest wallet; the sender or MAC is the guest
+	sessions := []struct {
+		id, sender, mac string
+		spent           int64
+	}{
+		{"s1", "ckt1a", "", 100},
+		{"s2", "ckt1b", "", 500},
+		{"s3", "ckt1c", "", 300},
+		{"s4", "ckt1d", "", 50},
+		{"s5", "", "aa:bb:cc:dd:ee:ff", 200},
+		{"s6", "ckt1a", "11:22:33:44:55:66", 450}, // ckt1a totals 550 across two sessions
+		{"s7", "ckt1d", "", 0},
+		{"s8", "", "", 10}, // unidentified
+	}
+	for _, s := range sessions {
+		db.CreateSession(&Session{ID: s.id, GuestAddress: "ckt1wallet-" + s.id, SenderAddress: s.sender, MACAddress: s.mac, SpentCKB: s.spent, Status: "settled", CreatedAt: now, ExpiresAt: now})
+	}
+
+	guests, err := db.GetTopGuests(10)
+	if err != nil {
+		t.Fatalf("GetTopGuests failed: %v", err)
+	}
+	want := []GuestStats{
+		{"ckt1a", 2, 550},
+		{"ckt1b", 1, 500},
+		{"ckt1c", 1, 300},
+		{"aa:bb:cc:dd:ee:ff", 1, 200},
+		{"ckt1d", 2, 50},
+	}
+	if len(guests) != len(want) {
+		t.Fatalf("Expected %d guests, got %d", len(want), len(guests))
+	}
+	for i := range want {
+		if guests[i] != want[i] {
+			t.Errorf("Guest %d: expected %+v, got %+v", i, want[i], guests[i])
+		}
+	}
+
+	top, _ := db.GetTopGuests(2)
+	if len(top) != 2 || top[1].Guest != "ckt1b" {
+		t.Errorf("Expected limit to keep the top 2 guests, got %+v", top)
+	}
+	if _, err := db.GetTopGuests(0); err == nil {
+		t.Error("Expected error for zero limit")
+	}
+
+	high, err := db.GetHighValueSessions(300)
+	if err != nil {
+		t.Fatalf("GetHighValueSessions failed: %v", err)
+	}
+	var ids []string
+	for _, s := range high {
+		ids = append(ids, s.ID)
+	}
+	if len(ids) != 3 || ids[0] != "s2" || ids[1] != "s6" || ids[2] != "s3" {
+		t.Errorf("Expected sessions s2, s6, s3, got %v", ids)
+	}
+}
@@ -0,0 +1,220 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Session search sort columns.
+const (
+	SortByCreatedAt  = "created_at"
+	SortBySpentCKB   = "spent_ckb"
+	SortByBalanceCKB = "balance_ckb"
+)
+
+// SessionQuery holds optional session search criteria.
+// Zero values are ignored.
+type SessionQuery struct {
+	Status             string
+	GuestAddressPrefix string
+	ChannelIDPrefix    string
+	FundingCKBMin      int64
+	FundingCKBMax      int64
+	CreatedAfter       time.Time
+	CreatedBefore      time.Time
+	Disputed           *bool  // nil ignores dispute state
+	SortBy             string // created_at, spent_ckb, balance_ckb
+	SortDesc           bool
+	Limit              int
+	Offset             int
+}
+
+// buildSessionSearchQuery builds a parameterized SELECT for the query.
+func buildSessionSearchQuery(q *SessionQuery) (string, []interface{}, error) {
+	var conditions []string
+	var args []interface{}
+
+	if q.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, q.Status)
+	}
+	if q.GuestAddressPrefix != "" {
+		conditions = append(conditions, "guest_address LIKE ? ESCAPE '\\'")
+		args = append(args, escapeLike(q.GuestAddressPrefix)+"%")
+	}
+	if q.ChannelIDPrefix != "" {
+		conditions = append(conditions, "channel_id LIKE ? ESCAPE '\\'")
+		args = append(args, escapeLike(q.ChannelIDPrefix)+"%")
+	}
+	if q.FundingCKBMin > 0 {
+		conditions = append(conditions, "funding_ckb >= ?")
+		args = append(args, q.FundingCKBMin)
+	}
+	if q.FundingCKBMax > 0 {
+		conditions = append(conditions, "funding_ckb <= ?")
+		args = append(args, q.FundingCKBMax)
+	}
+	if !q.CreatedAfter.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, q.CreatedAfter)
+	}
+	if !q.CreatedBefore.IsZero() {
+		conditions = append(conditions, "created_at <= ?")
+		args = append(args, q.CreatedBefore)
+	}
+
+	if q.Disputed != nil {
+		if *q.Disputed {
+			conditions = append(conditions, "disputed_at IS NOT NULL")
+		} else {
+			conditions = append(conditions, "disputed_at IS NULL")
+		}
+	}
+
+	sortBy := q.SortBy
+	switch sortBy {
+	case "":
+		sortBy = SortByCreatedAt
+	case SortByCreatedAt, SortBySpentCKB, SortByBalanceCKB:
+	default:
+		return "", nil, fmt.Errorf("invalid sort column: %s", q.SortBy)
+	}
+	direction := "ASC"
+	if q.SortDesc {
+		direction = "DESC"
+	}
+
+	var sb strings.Builder
+	sb.WriteString("SELECT " + sessionColumns + " FROM sessions")
+	if len(conditions) > 0 {
+		sb.WriteString(" WHERE " + strings.Join(conditions, " AND "))
+	}
+	sb.WriteString(fmt.Sprintf(" ORDER BY %s %s", sortBy, direction))
+	if q.Limit > 0 {
+		sb.WriteString(" LIMIT ?")
+		args = append(args, q.Limit)
+		if q.Offset > 0 {
+			sb.WriteString(" OFFSET ?")
+			args = append(args, q.Offset)
+		}
+	} else if q.Offset > 0 {
+		sb.WriteString(" LIMIT -1 OFFSET ?")
+		args = append(args, q.Offset)
+	}
+
+	return sb.String(), args, nil
+}
+
+// escapeLike escapes LIKE wildcards so user input matches literally.
+func escapeLike(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s)
+}
+
+// SearchSessions returns sessions matching all criteria in the query.
+func (db *DB) SearchSessions(q *SessionQuery) ([]*Session, error) {
+	if q == nil {
+		q = &SessionQuery{}
+	}
+	query, args, err := buildSessionSearchQuery(q)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryRead(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanSessions(rows)
+}
+
+// WalletQuery holds optional guest wallet search criteria.
+// Zero values are ignored.
+type WalletQuery struct {
+	Status        string
+	Statuses      []string // matches any of them, alongside Status
+	MACAddress    string   // matched case-insensitively
+	BalanceCKBMin int64
+	BalanceCKBMax int64
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	Limit         int
+	Offset        int
+}
+
+// buildWalletSearchQuery builds a parameterized SELECT for the query.
+func buildWalletSearchQuery(q *WalletQuery) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	statuses := q.Statuses
+	if q.Status != "" {
+		statuses = append([]string{q.Status}, statuses...)
+	}
+	switch len(statuses) {
+	case 0:
+	case 1:
+		conditions = append(conditions, "status = ?")
+		args = append(args, statuses[0])
+	default:
+		conditions = append(conditions, "status IN ("+placeholders(len(statuses))+")")
+		for _, status := range statuses {
+			args = append(args, status)
+		}
+	}
+	if q.MACAddress != "" {
+		conditions = append(conditions, "mac_address = ? COLLATE NOCASE")
+		args = append(args, q.MACAddress)
+	}
+	if q.BalanceCKBMin > 0 {
+		conditions = append(conditions, "balance_ckb >= ?")
+		args = append(args, q.BalanceCKBMin)
+	}
+	if q.BalanceCKBMax > 0 {
+		conditions = append(conditions, "balance_ckb <= ?")
+		args = append(args, q.BalanceCKBMax)
+	}
+	if !q.CreatedAfter.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, q.CreatedAfter)
+	}
+	if !q.CreatedBefore.IsZero() {
+		conditions = append(conditions, "created_at <= ?")
+		args = append(args, q.CreatedBefore)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("SELECT " + walletColumns + " FROM guest_wallets")
+	if len(conditions) > 0 {
+		sb.WriteString(" WHERE " + strings.Join(conditions, " AND "))
+	}
+	sb.WriteString(" ORDER BY created_at ASC")
+	if q.Limit > 0 {
+		sb.WriteString(" LIMIT ?")
+		args = append(args, q.Limit)
+		if q.Offset > 0 {
+			sb.WriteString(" OFFSET ?")
+			args = append(args, q.Offset)
+		}
+	} else if q.Offset > 0 {
+		sb.WriteString(" LIMIT -1 OFFSET ?")
+		args = append(args, q.Offset)
+	}
+
+	return sb.String(), args
+}
+
+// SearchGuestWallets returns guest wallets matching all criteria in the query.
+func (db *DB) SearchGuestWallets(q *WalletQuery) ([]*GuestWallet, error) {
+	if q == nil {
+		q = &WalletQuery{}
+	}
+	query, args := buildWalletSearchQuery(q)
+
+	rows, err := db.QueryRead(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return db.scanWallets(rows)
+}
@@ -0,0 +1,247 @@
+package db
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
+)
+
+func TestBuildSessionSearchQuery(t *testing.T) {
+	after := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+	disputed := true
+
+	tests := []struct {
+		name     string
+		query    SessionQuery
+		contains []string
+		args     []interface{}
+	}{
+		{
+			name:     "empty",
+			query:    SessionQuery{},
+			contains: []string{"FROM sessions ORDER BY created_at ASC"},
+			args:     nil,
+		},
+		{
+			name:     "status",
+			query:    SessionQuery{Status: "active"},
+			contains: []string{"WHERE status = ?"},
+			args:     []interface{}{"active"},
+		},
+		{
+			name:     "guest address prefix",
+			query:    SessionQuery{GuestAddressPrefix: "ckt1q"},
+			contains: []string{"guest_address LIKE ?"},
+			args:     []interface{}{"ckt1q%"},
+		},
+		{
+			name:     "channel id prefix escapes wildcards",
+			query:    SessionQuery{ChannelIDPrefix: "ab_%"},
+			contains: []string{"channel_id LIKE ?"},
+			args:     []interface{}{`ab\_\%%`},
+		},
+		{
+			name:     "funding range",
+			query:    SessionQuery{FundingCKBMin: 100, FundingCKBMax: 500},
+			contains: []string{"funding_ckb >= ? AND funding_ckb <= ?"},
+			args:     []interface{}{int64(100), int64(500)},
+		},
+		{
+			name:     "created range",
+			query:    SessionQuery{CreatedAfter: after, CreatedBefore: before},
+			contains: []string{"created_at >= ? AND created_at <= ?"},
+			args:     []interface{}{after, before},
+		},
+		{
+			name:     "disputed",
+			query:    SessionQuery{Disputed: &disputed},
+			contains: []string{"WHERE disputed_at IS NOT NULL"},
+			args:     nil,
+		},
+		{
+			name:     "sort desc",
+			query:    SessionQuery{SortBy: SortBySpentCKB, SortDesc: true},
+			contains: []string{"ORDER BY spent_ckb DESC"},
+			args:     nil,
+		},
+		{
+			name:     "pagination",
+			query:    SessionQuery{Limit: 10, Offset: 20},
+			contains: []string{"LIMIT ? OFFSET ?"},
+			args:     []interface{}{10, 20},
+		},
+		{
+			name: "combined",
+			query: SessionQuery{
+				Status:             "settled",
+				GuestAddressPrefix: "ckt",
+				FundingCKBMin:      200,
+				SortBy:             SortByBalanceCKB,
+				Limit:              5,
+			},
+			contains: []string{
+				"WHERE status = ? AND guest_address LIKE ? ESCAPE '\\' AND funding_ckb >= ?",
+				"ORDER BY balance_ckb ASC LIMIT ?",
+			},
+			args: []interface{}{"settled", "ckt%", int64(200), 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args, err := buildSessionSearchQuery(&tt.query)
+			if err != nil {
+				t.Fatalf("buildSessionSearchQuery failed: %v", err)
+			}
+			for _, fragment := range tt.contains {
+				if !strings.Contains(query, fragment) {
+					t.Errorf("Query %q missing %q", query, fragment)
+				}
+			}
+			if len(args) != len(tt.args) {
+				t.Fatalf("Args: expected %v, got %v", tt.args, args)
+			}
+			for i := range args {
+				if args[i] != tt.args[i] {
+					t.Errorf("Arg %d: expected %v, got %v", i, tt.args[i], args[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBuildSessionSearchQuery_InvalidSort(t *testing.T) {
+	_, _, err := buildSessionSearchQuery(&SessionQuery{SortBy: "id; DROP TABLE sessions"})
+	if err == nil {
+		t.Error("Expected error for invalid sort column")
+	}
+}
+
+func TestDB_SearchSessions(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	now := time.Now()
+	db.CreateSession(&Session{ID: "s1", GuestAddress: "ckt1aaa", ChannelID: testChannelID(t, "abc123"), FundingCKB: 100, SpentCKB: 10, Status: "active", CreatedAt: now.Add(-3 * time.Hour), ExpiresAt: now.Add(time.Hour)})
+	db.CreateSession(&Session{ID: "s2", GuestAddress: "ckt1bbb", ChannelID: testChannelID(t, "abd456"), FundingCKB: 300, SpentCKB: 50, Status: "active", CreatedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(time.Hour)})
+	db.CreateSession(&Session{ID: "s3", GuestAddress: "ckt1aab", ChannelID: testChannelID(t, "fff789"), FundingCKB: 500, SpentCKB: 30, Status: "settled", CreatedAt: now.Add(-1 * time.Hour), ExpiresAt: now})
+	db.RecordDispute("s2", "0xdispute")
+	disputed, undisputed := true, false
+
+	tests := []struct {
+		name     string
+		query    *SessionQuery
+		expected []string
+	}{
+		{"all", nil, []string{"s1", "s2", "s3"}},
+		{"status", &SessionQuery{Status: "active"}, []string{"s1", "s2"}},
+		{"guest prefix", &SessionQuery{GuestAddressPrefix: "ckt1aa"}, []string{"s1", "s3"}},
+		{"channel prefix", &SessionQuery{ChannelIDPrefix: "ab"}, []string{"s1", "s2"}},
+		{"funding min", &SessionQuery{FundingCKBMin: 300}, []string{"s2", "s3"}},
+		{"funding max", &SessionQuery{FundingCKBMax: 300}, []string{"s1", "s2"}},
+		{"created after", &SessionQuery{CreatedAfter: now.Add(-150 * time.Minute)}, []string{"s2", "s3"}},
+		{"created before", &SessionQuery{CreatedBefore: now.Add(-150 * time.Minute)}, []string{"s1"}},
+		{"sort spent desc", &SessionQuery{SortBy: SortBySpentCKB, SortDesc: true}, []string{"s2", "s3", "s1"}},
+		{"disputed", &SessionQuery{Disputed: &disputed}, []string{"s2"}},
+		{"not disputed", &SessionQuery{Disputed: &undisputed}, []string{"s1", "s3"}},
+		{"limit offset", &SessionQuery{Limit: 1, Offset: 1}, []string{"s2"}},
+		{"combined", &SessionQuery{Status: "active", GuestAddressPrefix: "ckt1", FundingCKBMin: 200}, []string{"s2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions, err := db.SearchSessions(tt.query)
+			if err != nil {
+				t.Fatalf("SearchSessions failed: %v", err)
+			}
+			if len(sessions) != len(tt.expected) {
+				t.Fatalf("Expected %d sessions, got %d", len(tt.expected), len(sessions))
+			}
+			for i, s := range sessions {
+				if s.ID != tt.expected[i] {
+					t.Errorf("Position %d: expected %s, got %s", i, tt.expected[i], s.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildWalletSearchQuery(t *testing.T) {
+	query, args := buildWalletSearchQuery(&WalletQuery{Status: "funded", MACAddress: "AA:BB:CC:DD:EE:FF", BalanceCKBMin: 100, Limit: 10})
+	expected := "WHERE status = ? AND mac_address = ? COLLATE NOCASE AND balance_ckb >= ? ORDER BY created_at ASC LIMIT ?"
+	if !strings.Contains(query, expected) {
+		t.Errorf("Query %q missing %q", query, expected)
+	}
+	if len(args) != 4 || args[0] != "funded" || args[1] != "AA:BB:CC:DD:EE:FF" || args[2] != int64(100) || args[3] != 10 {
+		t.Errorf("Unexpected args: %v", args)
+	}
+}
+
+func TestDB_SearchGuestWallets(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	now := time.Now()
+	db.CreateGuestWallet(&GuestWallet{ID: "w1", Address: "a1", PrivateKeyHex: "k1", BalanceCKB: 50, Status: "created", MACAddress: "AA:BB:CC:DD:EE:FF", CreatedAt: now.Add(-3 * time.Hour)})
+	db.CreateGuestWallet(&GuestWallet{ID: "w2", Address: "a2", PrivateKeyHex: "k2", BalanceCKB: 200, Status: "funded", MACAddress: "aa:bb:cc:dd:ee:ff", CreatedAt: now.Add(-2 * time.Hour)})
+	db.CreateGuestWallet(&GuestWallet{ID: "w3", Address: "a3", PrivateKeyHex: "k3", BalanceCKB: 500, Status: "funded", MACAddress: "11:22:33:44:55:66", CreatedAt: now.Add(-1 * time.Hour)})
+
+	tests := []struct {
+		name     string
+		query    *WalletQuery
+		expected []string
+	}{
+		{"all", nil, []string{"w1", "w2", "w3"}},
+		{"status", &WalletQuery{Status: "funded"}, []string{"w2", "w3"}},
+		{"mac ignores case", &WalletQuery{MACAddress: "AA:BB:CC:DD:EE:FF"}, []string{"w1", "w2"}},
+		{"balance min", &WalletQuery{BalanceCKBMin: 100}, []string{"w2", "w3"}},
+		{"balance max", &WalletQuery{BalanceCKBMax: 200}, []string{"w1", "w2"}},
+		{"created after", &WalletQuery{CreatedAfter: now.Add(-150 * time.Minute)}, []string{"w2", "w3"}},
+		{"created before", &WalletQuery{CreatedBefore: now.Add(-150 * time.Minute)}, []string{"w1"}},
+		{"limit offset", &WalletQuery{Limit: 1, Offset: 1}, []string{"w2"}},
+		{"statuses", &WalletQuery{Statuses: []string{"created", "funded"}, BalanceCKBMin: 100}, []string{"w2", "w3"}},
+		{"combined", &WalletQuery{Status: "funded", MACAddress: "AA:BB:CC:DD:EE:FF", BalanceCKBMin: 100}, []string{"w2"}},
+		{"injection in status", &WalletQuery{Status: "funded' OR '1'='1"}, nil},
+		{"injection in mac", &WalletQuery{MACAddress: "x' OR 1=1 --"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wallets, err := db.SearchGuestWallets(tt.query)
+			if err != nil {
+				t.Fatalf("SearchGuestWallets failed: %v", err)
+			}
+			if len(wallets) != len(tt.expected) {
+				t.Fatalf("Expected %d wallets, got %d", len(tt.expected), len(wallets))
+			}
+			for i, w := range wallets {
+				if w.ID != tt.expected[i] {
+					t.Errorf("Position %d: expected %s, got %s", i, tt.expected[i], w.ID)
+				}
+			}
+		})
+	}
+
+	// A hostile value must be stored and matched literally, not executed
+	db.CreateGuestWallet(&GuestWallet{ID: "w4", Address: "a4", PrivateKeyHex: "k4", Status: "created", MACAddress: "'; DROP TABLE guest_wallets; --", CreatedAt: now})
+	wallets, err := db.SearchGuestWallets(&WalletQuery{MACAddress: "'; DROP TABLE guest_wallets; --"})
+	if err != nil || len(wallets) != 1 || wallets[0].ID != "w4" {
+		t.Fatalf("Expected literal match on w4, got %v (err %v)", wallets, err)
+	}
+	if all, err := db.SearchGuestWallets(nil); err != nil || len(all) != 4 {
+		t.Fatalf("Expected table intact with 4 wallets, got %d (err %v)", len(all), err)
+	}
+}
+
+// testChannelID returns a channel ID whose hex form starts with prefix.
+func testChannelID(t *testing.T, prefix string) perun.ChannelID {
+	t.Helper()
+	id, err := perun.ParseChannelID(prefix + strings.Repeat("0", 64-len(prefix)))
+	if err != nil {
+		t.Fatalf("invalid channel ID prefix %q: %v", prefix, err)
+	}
+	return id
+}
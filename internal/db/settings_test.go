@@ -0,0 +1,135 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDB_GetSetSetting(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	if _, err := db.GetSetting("missing"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows for unset key, got %v", err)
+	}
+
+	if err := db.SetSetting("totp_secret", "abc"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+	if value, err := db.GetSetting("totp_secret"); err != nil || value != "abc" {
+		t.Errorf("Expected abc, got %q (%v)", value, err)
+	}
+
+	var first time.Time
+	db.conn.QueryRow(`SELECT updated_at FROM settings WHERE key = 'totp_secret'`).Scan(&first)
+	if first.IsZero() {
+		t.Error("Expected updated_at to be set")
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if err := db.SetSetting("totp_secret", "def"); err != nil {
+		t.Fatalf("SetSetting update failed: %v", err)
+	}
+	if value, _ := db.GetSetting("totp_secret"); value != "def" {
+		t.Errorf("Expected def after update, got %q", value)
+	}
+	var second time.Time
+	db.conn.QueryRow(`SELECT updated_at FROM settings WHERE key = 'totp_secret'`).Scan(&second)
+	if !second.After(first) {
+		t.Errorf("Expected updated_at to advance, got %v then %v", first, second)
+	}
+
+	all, err := db.GetAllSettings()
+	if err != nil {
+		t.Fatalf("GetAllSettings failed: %v", err)
+	}
+	if all["totp_secret"] != "def" || all[settingRatePerHour] != "500" {
+		t.Errorf("Unexpected settings: %v", all)
+	}
+}
+
+func TestDB_GetSetSettingInt(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	if got := db.GetSettingInt("missing", 42); got != 42 {
+		t.Errorf("Expected default 42 for unset key, got %d", got)
+	}
+
+	if err := db.SetSettingInt("count", -7); err != nil {
+		t.Fatalf("SetSettingInt failed: %v", err)
+	}
+	if got := db.GetSettingInt("count", 0); got != -7 {
+		t.Errorf("Expected -7, got %d", got)
+	}
+
+	db.SetSetting("count", "seven")
+	if got := db.GetSettingInt("count", 3); got != 3 {
+		t.Errorf("Expected default 3 for malformed value, got %d", got)
+	}
+}
+
+func TestDB_RatePerHourSetting(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	if rate, _ := db.GetRatePerHour(); rate != DefaultRatePerHour {
+		t.Errorf("Expected default rate %d, got %d", DefaultRatePerHour, rate)
+	}
+	if err := db.SetRatePerHour(600); err != nil {
+		t.Fatalf("SetRatePerHour failed: %v", err)
+	}
+	if rate, _ := db.GetRatePerHour(); rate != 600 {
+		t.Errorf("Expected 600, got %d", rate)
+	}
+	if value, _ := db.GetSetting(settingRatePerHour); value != "600" {
+		t.Errorf("Expected rate stored in settings table, got %q", value)
+	}
+
+	// A removed setting is inserted again
+	if _, err := db.conn.Exec(`DELETE FROM settings WHERE key = ?`, settingRatePerHour); err != nil {
+		t.Fatalf("Failed to delete rate: %v", err)
+	}
+	if err := db.SetRatePerHour(700); err != nil {
+		t.Fatalf("SetRatePerHour failed: %v", err)
+	}
+	if rate, _ := db.GetRatePerHour(); rate != 700 {
+		t.Errorf("Expected 700, got %d", rate)
+	}
+}
+
+func TestDB_SettingAccessors(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	if _, err := db.GetChannelSetupCKB(); err == nil {
+		t.Error("Expected error for unset channel setup")
+	}
+	if _, err := db.GetWalletTTL(); err == nil {
+		t.Error("Expected error for unset wallet TTL")
+	}
+	if _, err := db.GetMaxConcurrentSessions(); err == nil {
+		t.Error("Expected error for unset max concurrent sessions")
+	}
+
+	db.SetChannelSetupCKB(800)
+	db.SetWalletTTL(90 * time.Minute)
+	db.SetMaxConcurrentSessions(25)
+
+	if ckb, err := db.GetChannelSetupCKB(); err != nil || ckb != 800 {
+		t.Errorf("Expected channel setup 800, got %d (%v)", ckb, err)
+	}
+	if ttl, err := db.GetWalletTTL(); err != nil || ttl != 90*time.Minute {
+		t.Errorf("Expected wallet TTL 90m, got %s (%v)", ttl, err)
+	}
+	if n, err := db.GetMaxConcurrentSessions(); err != nil || n != 25 {
+		t.Errorf("Expected max concurrent sessions 25, got %d (%v)", n, err)
+	}
+
+	db.SetSetting(settingChannelSetupCKB, "lots")
+	if _, err := db.GetChannelSetupCKB(); err == nil {
+		t.Error("Expected error for malformed channel setup")
+	}
+}
@@ -0,0 +1,24 @@
+// Package expiry holds the rule for when paid session time runs out, shared
+// by the session store, the database layer and the backend.
+package expiry
+
+import "time"
+
+// Expired reports whether time paid until expiresAt has run out, from the
+// instant expiresAt on.
+func Expired(expiresAt time.Time) bool {
+	return expiredAt(expiresAt, time.Now())
+}
+
+// Until returns how long until expiresAt, or zero once it has passed.
+func Until(expiresAt time.Time) time.Duration {
+	return untilAt(expiresAt, time.Now())
+}
+
+func expiredAt(expiresAt, now time.Time) bool {
+	return !now.Before(expiresAt)
+}
+
+func untilAt(expiresAt, now time.Time) time.Duration {
+	return max(0, expiresAt.Sub(now))
+}
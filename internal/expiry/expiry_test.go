@@ -0,0 +1,39 @@
+package expiry
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpiry(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		now       time.Time
+		expired   bool
+		remaining time.Duration
+	}{
+		{"at start", start, false, time.Hour},
+		{"just before expiry", end.Add(-time.Nanosecond), false, time.Nanosecond},
+		{"at exact expiry", end, true, 0},
+		{"just after expiry", end.Add(time.Nanosecond), true, 0},
+		{"long after expiry", end.Add(24 * time.Hour), true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expiredAt(end, tt.now); got != tt.expired {
+				t.Errorf("expiredAt = %v, want %v", got, tt.expired)
+			}
+			if got := untilAt(end, tt.now); got != tt.remaining {
+				t.Errorf("untilAt = %v, want %v", got, tt.remaining)
+			}
+		})
+	}
+
+	// Time paid until now has already run out
+	if !expiredAt(start, start) {
+		t.Error("Expected expiry at start to be expired at once")
+	}
+}
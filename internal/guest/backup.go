@@ -0,0 +1,302 @@
+package guest
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+// Backup encryption parameters. The key is derived from the passphrase with
+// scrypt and used for AES-256-GCM.
+const (
+	backupVersion  = 1
+	backupKDF      = "scrypt"
+	backupSaltSize = 16
+	scryptN        = 1 << 15
+	scryptR        = 8
+	scryptP        = 1
+	backupKeySize  = 32
+)
+
+var (
+	// ErrBackupEncrypted is returned when an encrypted backup is read
+	// without a passphrase.
+	ErrBackupEncrypted = errors.New("wallet backup is encrypted")
+	// ErrBackupDecrypt is returned when the passphrase is wrong or the
+	// backup was modified.
+	ErrBackupDecrypt = errors.New("failed to decrypt wallet backup: wrong passphrase or corrupted file")
+)
+
+// PromptPassphrase asks for the backup passphrase when ExportWallets or
+// ImportWallets is asked to encrypt or decrypt. It reads from the terminal
+// without echo, or a line from stdin when stdin is not a terminal.
+var PromptPassphrase = promptStdinPassphrase
+
+// WalletBackup is one wallet in an exported backup.
+type WalletBackup struct {
+	ID            string    `json:"id"`
+	Address       string    `json:"address"`
+	PrivateKeyHex string    `json:"private_key_hex"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// encryptedBackup is the file format of an encrypted backup. The ciphertext
+// holds the JSON array of WalletBackup.
+type encryptedBackup struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// ExportWallets writes every wallet held by the manager to w as a JSON
+// array, oldest first. With encrypt set, the passphrase is read through
+// PromptPassphrase; use ExportWalletsEncrypted to pass one directly.
+func (wm *WalletManager) ExportWallets(w io.Writer, encrypt bool) error {
+	if !encrypt {
+		return WriteWalletBackup(w, wm.backups(), "")
+	}
+	passphrase, err := PromptPassphrase("Backup passphrase: ")
+	if err != nil {
+		return fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return wm.ExportWalletsEncrypted(w, passphrase)
+}
+
+// ExportWalletsEncrypted writes every wallet to w encrypted with a key
+// derived from passphrase.
+func (wm *WalletManager) ExportWalletsEncrypted(w io.Writer, passphrase string) error {
+	if passphrase == "" {
+		return fmt.Errorf("passphrase is required")
+	}
+	return WriteWalletBackup(w, wm.backups(), passphrase)
+}
+
+// WriteWalletBackup writes backups to w in the format read by
+// ReadWalletBackup, encrypted with a key derived from passphrase unless it
+// is empty.
+func WriteWalletBackup(w io.Writer, backups []WalletBackup, passphrase string) error {
+	if backups == nil {
+		backups = []WalletBackup{}
+	}
+	if passphrase == "" {
+		return json.NewEncoder(w).Encode(backups)
+	}
+	plaintext, err := json.Marshal(backups)
+	if err != nil {
+		return fmt.Errorf("failed to encode wallets: %w", err)
+	}
+
+	salt := make([]byte, backupSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+	aead, err := backupCipher(passphrase, salt)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return json.NewEncoder(w).Encode(&encryptedBackup{
+		Version:    backupVersion,
+		KDF:        backupKDF,
+		Salt:       salt,
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, plaintext, nil),
+	})
+}
+
+// ImportWallets reads a backup written by ExportWallets and adds its wallets
+// to the manager. With decrypt set, the passphrase is read through
+// PromptPassphrase; use ImportWalletsEncrypted to pass one directly.
+func (wm *WalletManager) ImportWallets(r io.Reader, decrypt bool) error {
+	passphrase := ""
+	if decrypt {
+		var err error
+		passphrase, err = PromptPassphrase("Backup passphrase: ")
+		if err != nil {
+			return fmt.Errorf("failed to read passphrase: %w", err)
+		}
+		if passphrase == "" {
+			return fmt.Errorf("passphrase is required")
+		}
+	}
+	backups, err := ReadWalletBackup(r, passphrase)
+	if err != nil {
+		return err
+	}
+	_, err = wm.AddWalletBackups(backups)
+	return err
+}
+
+// ImportWalletsEncrypted reads a backup written by ExportWalletsEncrypted
+// and adds its wallets to the manager.
+func (wm *WalletManager) ImportWalletsEncrypted(r io.Reader, passphrase string) error {
+	if passphrase == "" {
+		return fmt.Errorf("passphrase is required")
+	}
+	backups, err := ReadWalletBackup(r, passphrase)
+	if err != nil {
+		return err
+	}
+	_, err = wm.AddWalletBackups(backups)
+	return err
+}
+
+// ReadWalletBackup decodes a backup, decrypting it with passphrase when it
+// is encrypted. An encrypted backup read with an empty passphrase returns
+// ErrBackupEncrypted.
+func ReadWalletBackup(r io.Reader, passphrase string) ([]WalletBackup, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read wallet backup: %w", err)
+	}
+
+	// A plain backup is an array, an encrypted one an object
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		if passphrase == "" {
+			return nil, ErrBackupEncrypted
+		}
+		data, err = decryptBackup(trimmed, passphrase)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var backups []WalletBackup
+	if err := json.Unmarshal(data, &backups); err != nil {
+		return nil, fmt.Errorf("invalid wallet backup: %w", err)
+	}
+	return backups, nil
+}
+
+// AddWalletBackups restores wallets from backups and adds them to the
+// manager, replacing wallets with the same ID. Every entry is checked
+// before any is added: the key must be valid and, when the backup records
+// an address, derive that address on the manager's network.
+func (wm *WalletManager) AddWalletBackups(backups []WalletBackup) ([]*Wallet, error) {
+	wallets := make([]*Wallet, 0, len(backups))
+	for i, b := range backups {
+		if b.ID == "" {
+			return nil, fmt.Errorf("wallet %d: missing id", i)
+		}
+		keyBytes, err := hex.DecodeString(strings.TrimPrefix(b.PrivateKeyHex, "0x"))
+		if err != nil || len(keyBytes) != 32 {
+			return nil, fmt.Errorf("wallet %s: invalid private key", b.ID)
+		}
+		wallet, err := wm.createWalletFromKey(b.ID, secp256k1.PrivKeyFromBytes(keyBytes))
+		if err != nil {
+			return nil, fmt.Errorf("wallet %s: %w", b.ID, err)
+		}
+		if b.Address != "" && b.Address != wallet.Address {
+			return nil, fmt.Errorf("wallet %s: key derives %s, backup records %s", b.ID, wallet.Address, b.Address)
+		}
+		wallet.CreatedAt = b.CreatedAt
+		wallets = append(wallets, wallet)
+	}
+
+	wm.walletsMu.Lock()
+	for _, wallet := range wallets {
+		wm.wallets[wallet.ID] = wallet
+	}
+	wm.walletsMu.Unlock()
+	return wallets, nil
+}
+
+// backups returns the manager's wallets in backup form, oldest first.
+func (wm *WalletManager) backups() []WalletBackup {
+	wm.walletsMu.RLock()
+	backups := make([]WalletBackup, 0, len(wm.wallets))
+	for _, wallet := range wm.wallets {
+		backups = append(backups, WalletBackup{
+			ID:            wallet.ID,
+			Address:       wallet.Address,
+			PrivateKeyHex: wallet.GetPrivateKeyHex(),
+			CreatedAt:     wallet.CreatedAt,
+		})
+	}
+	wm.walletsMu.RUnlock()
+
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].CreatedAt.Equal(backups[j].CreatedAt) {
+			return backups[i].CreatedAt.Before(backups[j].CreatedAt)
+		}
+		return backups[i].ID < backups[j].ID
+	})
+	return backups
+}
+
+// decryptBackup returns the plaintext of an encrypted backup.
+func decryptBackup(data []byte, passphrase string) ([]byte, error) {
+	var enc encryptedBackup
+	if err := json.Unmarshal(data, &enc); err != nil {
+		return nil, fmt.Errorf("invalid wallet backup: %w", err)
+	}
+	if enc.Version != backupVersion || enc.KDF != backupKDF {
+		return nil, fmt.Errorf("unsupported wallet backup format: version %d, kdf %q", enc.Version, enc.KDF)
+	}
+
+	aead, err := backupCipher(passphrase, enc.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(enc.Nonce) != aead.NonceSize() {
+		return nil, ErrBackupDecrypt
+	}
+	plaintext, err := aead.Open(nil, enc.Nonce, enc.Ciphertext, nil)
+	if err != nil {
+		return nil, ErrBackupDecrypt
+	}
+	return plaintext, nil
+}
+
+// backupCipher derives the AES-256-GCM cipher for passphrase and salt.
+func backupCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, backupKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive backup key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return aead, nil
+}
+
+// promptStdinPassphrase prints prompt to stderr and reads a passphrase.
+func promptStdinPassphrase(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		passphrase, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(passphrase), err
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,131 @@
+package guest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
+)
+
+func newManagerWithWallets(t *testing.T, n int) *WalletManager {
+	t.Helper()
+	wm := NewWalletManager(types.NetworkTest)
+	for i := 0; i < n; i++ {
+		if _, err := wm.GenerateWallet(); err != nil {
+			t.Fatalf("GenerateWallet failed: %v", err)
+		}
+	}
+	return wm
+}
+
+func assertSameWallets(t *testing.T, want, got *WalletManager) {
+	t.Helper()
+	if len(got.wallets) != len(want.wallets) {
+		t.Fatalf("Expected %d wallets, got %d", len(want.wallets), len(got.wallets))
+	}
+	for id, w := range want.wallets {
+		restored, ok := got.GetWallet(id)
+		if !ok {
+			t.Errorf("Wallet %s missing after import", id)
+			continue
+		}
+		if restored.GetPrivateKeyHex() != w.GetPrivateKeyHex() || restored.Address != w.Address {
+			t.Errorf("Wallet %s restored with different key or address", id)
+		}
+		if !restored.CreatedAt.Equal(w.CreatedAt) {
+			t.Errorf("Wallet %s: CreatedAt %v, want %v", id, restored.CreatedAt, w.CreatedAt)
+		}
+		if _, ok := got.GetWalletByAddress(w.Address); !ok {
+			t.Errorf("Wallet %s not found by address", id)
+		}
+	}
+}
+
+func TestWalletManager_ExportImportRoundTrip(t *testing.T) {
+	wm := newManagerWithWallets(t, 3)
+
+	var buf bytes.Buffer
+	if err := wm.ExportWallets(&buf, false); err != nil {
+		t.Fatalf("ExportWallets failed: %v", err)
+	}
+
+	var entries []map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entries); err != nil {
+		t.Fatalf("Export is not a JSON array: %v", err)
+	}
+	for _, key := range []string{"id", "address", "private_key_hex", "created_at"} {
+		if _, ok := entries[0][key]; !ok {
+			t.Errorf("Export entry missing %q", key)
+		}
+	}
+
+	restored := NewWalletManager(types.NetworkTest)
+	if err := restored.ImportWallets(&buf, false); err != nil {
+		t.Fatalf("ImportWallets failed: %v", err)
+	}
+	assertSameWallets(t, wm, restored)
+}
+
+func TestWalletManager_ExportImportEncryptedRoundTrip(t *testing.T) {
+	wm := newManagerWithWallets(t, 2)
+
+	var buf bytes.Buffer
+	if err := wm.ExportWalletsEncrypted(&buf, "correct horse"); err != nil {
+		t.Fatalf("ExportWalletsEncrypted failed: %v", err)
+	}
+	for id := range wm.wallets {
+		if strings.Contains(buf.String(), wm.wallets[id].GetPrivateKeyHex()) {
+			t.Fatal("Encrypted export contains a plaintext private key")
+		}
+	}
+	data := buf.Bytes()
+
+	if err := NewWalletManager(types.NetworkTest).ImportWallets(bytes.NewReader(data), false); !errors.Is(err, ErrBackupEncrypted) {
+		t.Errorf("Expected ErrBackupEncrypted without decrypt, got %v", err)
+	}
+	if err := NewWalletManager(types.NetworkTest).ImportWalletsEncrypted(bytes.NewReader(data), "wrong"); !errors.Is(err, ErrBackupDecrypt) {
+		t.Errorf("Expected ErrBackupDecrypt for wrong passphrase, got %v", err)
+	}
+
+	restored := NewWalletManager(types.NetworkTest)
+	if err := restored.ImportWalletsEncrypted(bytes.NewReader(data), "correct horse"); err != nil {
+		t.Fatalf("ImportWalletsEncrypted failed: %v", err)
+	}
+	assertSameWallets(t, wm, restored)
+}
+
+func TestWalletManager_ExportImportPrompted(t *testing.T) {
+	prompt := PromptPassphrase
+	defer func() { PromptPassphrase = prompt }()
+	PromptPassphrase = func(string) (string, error) { return "prompted", nil }
+
+	wm := newManagerWithWallets(t, 1)
+	var buf bytes.Buffer
+	if err := wm.ExportWallets(&buf, true); err != nil {
+		t.Fatalf("ExportWallets failed: %v", err)
+	}
+
+	restored := NewWalletManager(types.NetworkTest)
+	if err := restored.ImportWallets(&buf, true); err != nil {
+		t.Fatalf("ImportWallets failed: %v", err)
+	}
+	assertSameWallets(t, wm, restored)
+}
+
+func TestWalletManager_ImportRejectsMismatchedAddress(t *testing.T) {
+	wm := newManagerWithWallets(t, 1)
+	var buf bytes.Buffer
+	wm.ExportWallets(&buf, false)
+
+	// Testnet addresses don't derive on a mainnet manager
+	mainnet := NewWalletManager(types.NetworkMain)
+	if err := mainnet.ImportWallets(&buf, false); err == nil {
+		t.Fatal("Expected error importing testnet wallets on mainnet")
+	}
+	if len(mainnet.wallets) != 0 {
+		t.Errorf("Expected no wallets added on failure, got %d", len(mainnet.wallets))
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 	"github.com/nervosnetwork/ckb-sdk-go/v2/crypto/blake2b"
@@ -15,10 +16,12 @@ import (
 
 // Wallet represents a generated guest wallet for Perun channels.
 type Wallet struct {
-	ID         string
-	PrivateKey *secp256k1.PrivateKey
-	Address    string
-	LockScript *types.Script
+	ID             string
+	PrivateKey     *secp256k1.PrivateKey
+	PrivateKeyHash string // See PrivateKeyHash
+	Address        string
+	LockScript     *types.Script
+	CreatedAt      time.Time
 }
 
 // WalletManager manages guest wallets.
@@ -56,6 +59,7 @@ func (wm *WalletManager) GenerateWallet() (*Wallet, error) {
 	if err != nil {
 		return nil, err
 	}
+	wallet.CreatedAt = time.Now()
 
 	// Store wallet
 	wm.walletsMu.Lock()
@@ -81,13 +85,20 @@ func (wm *WalletManager) createWalletFromKey(id string, privKey *secp256k1.Priva
 	address := encodeAddress(lockScript, wm.network)
 
 	return &Wallet{
-		ID:         id,
-		PrivateKey: privKey,
-		Address:    address,
-		LockScript: lockScript,
+		ID:             id,
+		PrivateKey:     privKey,
+		PrivateKeyHash: PrivateKeyHash(privKey),
+		Address:        address,
+		LockScript:     lockScript,
 	}, nil
 }
 
+// PrivateKeyHash returns the hex BLAKE2b-256 hash of a private key. It
+// identifies a key in storage without storing the key itself.
+func PrivateKeyHash(privKey *secp256k1.PrivateKey) string {
+	return hex.EncodeToString(blake2b.Blake256(privKey.Serialize()))
+}
+
 // GetWallet retrieves a wallet by ID.
 func (wm *WalletManager) GetWallet(id string) (*Wallet, bool) {
 	wm.walletsMu.RLock()
@@ -27,6 +27,12 @@ func TestWalletManager_GenerateWallet(t *testing.T) {
 	if wallet.LockScript == nil {
 		t.Error("LockScript is nil")
 	}
+	if len(wallet.PrivateKeyHash) != 64 || wallet.PrivateKeyHash != PrivateKeyHash(wallet.PrivateKey) {
+		t.Errorf("PrivateKeyHash should be the 32-byte key hash, got: %s", wallet.PrivateKeyHash)
+	}
+	if strings.Contains(wallet.PrivateKeyHash, wallet.GetPrivateKeyHex()) {
+		t.Error("PrivateKeyHash contains the private key")
+	}
 
 	if !strings.HasPrefix(wallet.Address, "ckt") {
 		t.Errorf("Testnet address should start with 'ckt', got: %s", wallet.Address)
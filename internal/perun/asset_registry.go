@@ -0,0 +1,142 @@
+package perun
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
+	gpchannel "perun.network/go-perun/channel"
+	"perun.network/perun-ckb-backend/channel/asset"
+)
+
+// AssetCKBytes is the name of the native CKBytes asset, registered in every
+// AssetRegistry.
+const AssetCKBytes = "CKBytes"
+
+// SUDTMaxCapacity is the capacity, in shannons, reserved for each SUDT cell
+// a channel creates.
+const SUDTMaxCapacity = 142 * 100000000
+
+var (
+	// ErrUnknownAsset is returned when looking up an asset that was never
+	// registered.
+	ErrUnknownAsset = errors.New("unknown asset")
+	// ErrAssetExists is returned when registering a name twice.
+	ErrAssetExists = errors.New("asset already registered")
+	// ErrAssetNotFundable is returned by PaymentAsset for assets that can
+	// be listed but not used to fund channels.
+	ErrAssetNotFundable = errors.New("asset cannot fund channels")
+)
+
+// RegisteredAsset is a channel asset known to an AssetRegistry.
+type RegisteredAsset struct {
+	Name  string
+	Asset *asset.Asset
+	// IssuerLockHash identifies a Simple UDT token; zero for CKBytes.
+	IssuerLockHash types.Hash
+}
+
+// IsSUDT reports whether the asset is a Simple UDT token.
+func (a RegisteredAsset) IsSUDT() bool {
+	return !a.Asset.IsCKBytes
+}
+
+// AssetRegistry maps asset names to the go-perun assets channels are
+// funded with.
+type AssetRegistry struct {
+	mu     sync.RWMutex
+	assets map[string]RegisteredAsset
+	names  []string // registration order
+}
+
+// NewAssetRegistry returns a registry holding only CKBytes.
+func NewAssetRegistry() *AssetRegistry {
+	r := &AssetRegistry{assets: make(map[string]RegisteredAsset)}
+	r.register(RegisteredAsset{Name: AssetCKBytes, Asset: asset.NewCKBytesAsset()})
+	return r
+}
+
+// RegisterSUDT registers a Simple UDT token, such as a stablecoin, issued
+// by the owner of issuerLockHash. The token's type script is the SUDT
+// contract with the issuer's lock hash as args.
+func (r *AssetRegistry) RegisterSUDT(issuerLockHash types.Hash, name string) error {
+	if name == "" {
+		return fmt.Errorf("asset name is required")
+	}
+	if issuerLockHash == (types.Hash{}) {
+		return fmt.Errorf("asset %s: issuer lock hash is required", name)
+	}
+
+	typeScript := types.Script{
+		CodeHash: SUDTCodeHash,
+		HashType: types.HashTypeType,
+		Args:     issuerLockHash.Bytes(),
+	}
+	a := RegisteredAsset{
+		Name:           name,
+		Asset:          asset.NewSUDTAsset(asset.NewSUDT(typeScript, SUDTMaxCapacity)),
+		IssuerLockHash: issuerLockHash,
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.assets[name]; exists {
+		return fmt.Errorf("%w: %s", ErrAssetExists, name)
+	}
+	r.register(a)
+	return nil
+}
+
+// register adds a; the caller holds mu or owns r exclusively.
+func (r *AssetRegistry) register(a RegisteredAsset) {
+	r.assets[a.Name] = a
+	r.names = append(r.names, a.Name)
+}
+
+// Lookup returns the channel asset registered under name.
+func (r *AssetRegistry) Lookup(name string) (gpchannel.Asset, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	a, ok := r.assets[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAsset, name)
+	}
+	return a.Asset, nil
+}
+
+// PaymentAsset returns the asset registered under name for funding guest
+// channels. Only CKBytes can fund channels: SUDT funding would need SUDT
+// cell deps in the deployment and token-denominated session pricing, which
+// the backend doesn't have, so SUDT assets are listed but not fundable.
+func (r *AssetRegistry) PaymentAsset(name string) (gpchannel.Asset, error) {
+	a, err := r.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	if name != AssetCKBytes {
+		return nil, fmt.Errorf("%w: %s", ErrAssetNotFundable, name)
+	}
+	return a, nil
+}
+
+// List returns every registered asset in registration order, CKBytes first.
+func (r *AssetRegistry) List() []RegisteredAsset {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	list := make([]RegisteredAsset, 0, len(r.names))
+	for _, name := range r.names {
+		list = append(list, r.assets[name])
+	}
+	return list
+}
+
+// ChannelAsset returns the asset a channel's allocation is denominated in.
+// Channels hold a single asset; allocations without one are treated as
+// CKBytes.
+func ChannelAsset(alloc *gpchannel.Allocation) gpchannel.Asset {
+	if len(alloc.Assets) == 0 {
+		return asset.NewCKBytesAsset()
+	}
+	return alloc.Assets[0]
+}
@@ -0,0 +1,101 @@
+package perun
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
+	gpchannel "perun.network/go-perun/channel"
+	"perun.network/perun-ckb-backend/channel/asset"
+)
+
+func TestAssetRegistry_Lookup(t *testing.T) {
+	r := NewAssetRegistry()
+
+	ckb, err := r.Lookup(AssetCKBytes)
+	if err != nil {
+		t.Fatalf("Expected CKBytes registered by default: %v", err)
+	}
+	if !ckb.Equal(asset.NewCKBytesAsset()) {
+		t.Error("Expected the CKBytes asset")
+	}
+
+	issuer := types.HexToHash("0x1111111111111111111111111111111111111111111111111111111111111111")
+	if err := r.RegisterSUDT(issuer, "USDI"); err != nil {
+		t.Fatalf("RegisterSUDT failed: %v", err)
+	}
+	usdi, err := r.Lookup("USDI")
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	sudt, err := asset.IsSUDTAsset(usdi)
+	if err != nil {
+		t.Fatalf("Expected an SUDT asset: %v", err)
+	}
+	if sudt.TypeScript.CodeHash != SUDTCodeHash || types.BytesToHash(sudt.TypeScript.Args) != issuer {
+		t.Errorf("Unexpected SUDT type script: %+v", sudt.TypeScript)
+	}
+	if usdi.Equal(ckb) {
+		t.Error("Expected the SUDT to differ from CKBytes")
+	}
+
+	if _, err := r.Lookup("DOGE"); !errors.Is(err, ErrUnknownAsset) {
+		t.Errorf("Expected ErrUnknownAsset, got %v", err)
+	}
+}
+
+func TestAssetRegistry_RegisterSUDT_Invalid(t *testing.T) {
+	r := NewAssetRegistry()
+	issuer := types.HexToHash("0x01")
+
+	if err := r.RegisterSUDT(issuer, ""); err == nil {
+		t.Error("Expected error for empty name")
+	}
+	if err := r.RegisterSUDT(types.Hash{}, "USDI"); err == nil {
+		t.Error("Expected error for zero issuer lock hash")
+	}
+	if err := r.RegisterSUDT(issuer, AssetCKBytes); !errors.Is(err, ErrAssetExists) {
+		t.Errorf("Expected ErrAssetExists for CKBytes, got %v", err)
+	}
+	r.RegisterSUDT(issuer, "USDI")
+	if err := r.RegisterSUDT(issuer, "USDI"); !errors.Is(err, ErrAssetExists) {
+		t.Errorf("Expected ErrAssetExists for a duplicate, got %v", err)
+	}
+
+	list := r.List()
+	if len(list) != 2 || list[0].Name != AssetCKBytes || list[1].Name != "USDI" {
+		t.Fatalf("Expected CKBytes then USDI, got %+v", list)
+	}
+	if list[0].IsSUDT() || !list[1].IsSUDT() || list[1].IssuerLockHash != issuer {
+		t.Errorf("Unexpected asset details: %+v", list)
+	}
+}
+
+func TestAssetRegistry_PaymentAsset(t *testing.T) {
+	r := NewAssetRegistry()
+	r.RegisterSUDT(types.HexToHash("0x01"), "USDI")
+
+	if a, err := r.PaymentAsset(AssetCKBytes); err != nil || !a.Equal(asset.NewCKBytesAsset()) {
+		t.Errorf("Expected CKBytes to fund channels, got %v, %v", a, err)
+	}
+	if _, err := r.PaymentAsset("USDI"); !errors.Is(err, ErrAssetNotFundable) {
+		t.Errorf("Expected ErrAssetNotFundable for an SUDT, got %v", err)
+	}
+	if _, err := r.PaymentAsset("DOGE"); !errors.Is(err, ErrUnknownAsset) {
+		t.Errorf("Expected ErrUnknownAsset, got %v", err)
+	}
+}
+
+func TestChannelAsset(t *testing.T) {
+	r := NewAssetRegistry()
+	r.RegisterSUDT(types.HexToHash("0x01"), "USDI")
+	usdi, _ := r.Lookup("USDI")
+
+	alloc := gpchannel.NewAllocation(2, usdi)
+	if !ChannelAsset(alloc).Equal(usdi) {
+		t.Error("Expected the allocation's asset")
+	}
+	if !ChannelAsset(&gpchannel.Allocation{}).Equal(asset.NewCKBytesAsset()) {
+		t.Error("Expected CKBytes for an allocation without assets")
+	}
+}
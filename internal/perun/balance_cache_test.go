@@ -0,0 +1,57 @@
+package perun
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nervosnetwork/ckb-sdk-go/v2/indexer"
+	"go.uber.org/zap"
+	ckbwallet "perun.network/perun-ckb-backend/wallet"
+)
+
+func TestChannelClient_GetBalanceCached(t *testing.T) {
+	account, err := ckbwallet.NewAccount()
+	if err != nil {
+		t.Fatalf("NewAccount failed: %v", err)
+	}
+	rpcClient := &mockRPCClient{cells: []*indexer.LiveCell{newTestCell(500_00000000, 0)}}
+	cc := newTestAccountClient(account)
+	cc.rpcClient = rpcClient
+	cc.logger = zap.NewNop()
+	ctx := context.Background()
+
+	for i := 0; i < 10; i++ {
+		balance, err := cc.GetBalance(ctx)
+		if err != nil {
+			t.Fatalf("GetBalance failed: %v", err)
+		}
+		if balance.Uint64() != 500_00000000 {
+			t.Fatalf("Expected 50000000000 shannons, got %s", balance)
+		}
+	}
+	if rpcClient.capacityCalls != 1 {
+		t.Fatalf("Expected 1 RPC call for 10 GetBalance calls, got %d", rpcClient.capacityCalls)
+	}
+
+	// Refresh bypasses the cache and updates it
+	rpcClient.cells = append(rpcClient.cells, newTestCell(100_00000000, 1))
+	balance, err := cc.RefreshBalance(ctx)
+	if err != nil {
+		t.Fatalf("RefreshBalance failed: %v", err)
+	}
+	if balance.Uint64() != 600_00000000 || rpcClient.capacityCalls != 2 {
+		t.Errorf("Expected refreshed balance 60000000000 after 2 calls, got %s after %d", balance, rpcClient.capacityCalls)
+	}
+	if balance, _ := cc.GetBalance(ctx); balance.Uint64() != 600_00000000 || rpcClient.capacityCalls != 2 {
+		t.Errorf("Expected cached refreshed balance, got %s after %d calls", balance, rpcClient.capacityCalls)
+	}
+
+	// An expired entry is fetched again
+	cc.balanceCacheTTL = time.Millisecond
+	time.Sleep(2 * time.Millisecond)
+	cc.GetBalance(ctx)
+	if rpcClient.capacityCalls != 3 {
+		t.Errorf("Expected expired cache to trigger a fetch, got %d calls", rpcClient.capacityCalls)
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"context"
 	"crypto/ecdsa"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/decred/dcrd/dcrec/secp256k1/v4"
@@ -22,22 +24,82 @@ const (
 	SplitFee uint64 = 100000
 	// CellMinCapacity is the minimum capacity for a CKB cell (61 CKB) - used locally to avoid collision
 	CellMinCapacity uint64 = 6100000000
+	// MergeThreshold is the capacity below which MergeThenSplit consolidates cells
+	MergeThreshold = 3 * CellMinCapacity
 )
 
+// ErrInsufficientCapacity is returned when a wallet cannot reach the requested cell count.
+var ErrInsufficientCapacity = errors.New("insufficient capacity for requested cell count")
+
 // CellSplitter handles splitting single cells into multiple cells for Perun channel operations.
 type CellSplitter struct {
 	rpcClient rpc.Client
 	logger    *zap.Logger
+	feeOracle *NetworkFeeOracle
+	txBuilder TransactionBuilder
+	progress  func(CellSplitProgress)
+}
+
+// CellSplitProgress reports a confirmed split or merge transaction while a
+// wallet's cells are being prepared.
+type CellSplitProgress struct {
+	Step         int // 1-based index of the transaction just confirmed
+	TotalSteps   int // Transactions needed to reach TargetCells
+	CurrentCells int
+	TargetCells  int
+	LastTxHash   string
 }
 
-// NewCellSplitter creates a new cell splitter.
+// NewCellSplitter creates a new cell splitter that submits transactions
+// through rpcClient.
 func NewCellSplitter(rpcClient rpc.Client, logger *zap.Logger) *CellSplitter {
 	return &CellSplitter{
 		rpcClient: rpcClient,
 		logger:    logger,
+		txBuilder: NewDefaultTransactionBuilder(rpcClient, logger),
 	}
 }
 
+// SetTransactionBuilder replaces how transactions are built and submitted.
+// Cell lookups still go through the RPC client.
+func (cs *CellSplitter) SetTransactionBuilder(builder TransactionBuilder) {
+	cs.txBuilder = builder
+}
+
+// SetFeeOracle enables dynamic fees. When nil, SplitFee is used.
+func (cs *CellSplitter) SetFeeOracle(oracle *NetworkFeeOracle) {
+	cs.feeOracle = oracle
+}
+
+// SetProgressCallback registers fn to be called after each split or merge
+// transaction EnsureMinimumCells and MergeThenSplit confirm. Nil disables it.
+func (cs *CellSplitter) SetProgressCallback(fn func(CellSplitProgress)) {
+	cs.progress = fn
+}
+
+// reportProgress passes p to the progress callback, if any.
+func (cs *CellSplitter) reportProgress(p CellSplitProgress) {
+	if cs.progress != nil {
+		cs.progress(p)
+	}
+}
+
+// shannonsToCKB converts shannons to CKB for log fields.
+func shannonsToCKB(shannons uint64) float64 {
+	return float64(shannons) / 100000000
+}
+
+// fee returns the fee to pay for a transaction with the given number of
+// inputs and outputs.
+func (cs *CellSplitter) fee(inputs, outputs int) uint64 {
+	return txFee(cs.feeOracle, SplitFee, inputs, outputs)
+}
+
+// splitFee returns the fee of a SplitCell transaction.
+func (cs *CellSplitter) splitFee() uint64 {
+	return cs.fee(1, 2)
+}
+
 // CountCells returns the number of cells for a given lock script.
 func (cs *CellSplitter) CountCells(ctx context.Context, lockScript *types.Script) (int, error) {
 	searchKey := &indexer.SearchKey{
@@ -86,14 +148,62 @@ func (cs *CellSplitter) GetCells(ctx context.Context, lockScript *types.Script)
 	return result, nil
 }
 
+// GetCellsByCapacity returns all pure CKB cells for a lock script, largest first.
+// Unlike GetCells, it pages through the indexer so no cells are missed.
+func (cs *CellSplitter) GetCellsByCapacity(ctx context.Context, lockScript *types.Script) ([]*indexer.LiveCell, error) {
+	searchKey := &indexer.SearchKey{
+		Script:           lockScript,
+		ScriptType:       types.ScriptTypeLock,
+		ScriptSearchMode: types.ScriptSearchModeExact,
+		WithData:         true,
+	}
+
+	result := make([]*indexer.LiveCell, 0)
+	cursor := ""
+	for {
+		cells, err := cs.rpcClient.GetCells(ctx, searchKey, indexer.SearchOrderAsc, 100, cursor)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get cells: %w", err)
+		}
+		for _, cell := range cells.Objects {
+			if cell.Output.Type == nil {
+				result = append(result, cell)
+			}
+		}
+		if len(cells.Objects) < 100 || cells.LastCursor == "" {
+			break
+		}
+		cursor = cells.LastCursor
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Output.Capacity > result[j].Output.Capacity
+	})
+	return result, nil
+}
+
+// GetTotalCapacity returns the summed capacity of all pure CKB cells for a lock script.
+func (cs *CellSplitter) GetTotalCapacity(ctx context.Context, lockScript *types.Script) (uint64, error) {
+	cells, err := cs.GetCellsByCapacity(ctx, lockScript)
+	if err != nil {
+		return 0, err
+	}
+
+	var total uint64
+	for _, cell := range cells {
+		total += cell.Output.Capacity
+	}
+	return total, nil
+}
+
 // SplitCell splits a cell into two cells.
 // It finds the largest cell that can be split and splits it.
 // Returns the transaction hash if successful.
 func (cs *CellSplitter) SplitCell(ctx context.Context, privateKey *secp256k1.PrivateKey, lockScript *types.Script) (types.Hash, error) {
-	cs.logger.Info("splitting cell for Perun channel preparation")
+	start := time.Now()
 
-	// Get all cells
-	cells, err := cs.GetCells(ctx, lockScript)
+	// Get all cells, largest first
+	cells, err := cs.GetCellsByCapacity(ctx, lockScript)
 	if err != nil {
 		return types.Hash{}, err
 	}
@@ -102,15 +212,12 @@ func (cs *CellSplitter) SplitCell(ctx context.Context, privateKey *secp256k1.Pri
 		return types.Hash{}, fmt.Errorf("no cells found")
 	}
 
-	// Find a cell that can be split (needs capacity for 2 cells + fee)
-	minSplitCapacity := 2*CellMinCapacity + SplitFee
+	// The largest cell must hold 2 cells + fee to be splittable
+	fee := cs.splitFee()
+	minSplitCapacity := 2*CellMinCapacity + fee
 	var cellToSplit *indexer.LiveCell
-	for _, cell := range cells {
-		if cell.Output.Capacity >= minSplitCapacity {
-			if cellToSplit == nil || cell.Output.Capacity > cellToSplit.Output.Capacity {
-				cellToSplit = cell // Pick the largest splittable cell
-			}
-		}
+	if cells[0].Output.Capacity >= minSplitCapacity {
+		cellToSplit = cells[0]
 	}
 
 	if cellToSplit == nil {
@@ -122,7 +229,7 @@ func (cs *CellSplitter) SplitCell(ctx context.Context, privateKey *secp256k1.Pri
 
 	// Calculate split: split evenly (50/50) for better balance
 	// This creates more balanced cells instead of many small + one large
-	availableCapacity := totalCapacity - SplitFee
+	availableCapacity := totalCapacity - fee
 	cell1Capacity := availableCapacity / 2
 	cell2Capacity := availableCapacity - cell1Capacity
 
@@ -133,42 +240,17 @@ func (cs *CellSplitter) SplitCell(ctx context.Context, privateKey *secp256k1.Pri
 	}
 
 	cs.logger.Info("splitting cell",
-		zap.Uint64("total_capacity", totalCapacity),
+		zap.Float64("capacity_ckb", shannonsToCKB(totalCapacity)),
 		zap.Uint64("cell1_capacity", cell1Capacity),
 		zap.Uint64("cell2_capacity", cell2Capacity),
-		zap.Uint64("fee", SplitFee),
-		zap.Int("current_cell_count", len(cells)),
+		zap.Uint64("fee", fee),
+		zap.Int("before_count", len(cells)),
 	)
 
-	// Get secp256k1 cell dep from blockchain
-	secp256k1CellDep := getSecp256k1CellDep()
-
 	// Build transaction
-	tx := &types.Transaction{
-		Version: 0,
-		CellDeps: []*types.CellDep{
-			secp256k1CellDep,
-		},
-		Inputs: []*types.CellInput{
-			{
-				Since:          0,
-				PreviousOutput: cellToSplit.OutPoint,
-			},
-		},
-		Outputs: []*types.CellOutput{
-			{
-				Capacity: cell1Capacity,
-				Lock:     lockScript,
-				Type:     nil,
-			},
-			{
-				Capacity: cell2Capacity,
-				Lock:     lockScript,
-				Type:     nil,
-			},
-		},
-		OutputsData: [][]byte{{}, {}},
-		Witnesses:   [][]byte{make([]byte, 85)}, // Placeholder for signature
+	tx, err := cs.txBuilder.BuildSplitTx(cellToSplit, lockScript, []uint64{cell1Capacity, cell2Capacity})
+	if err != nil {
+		return types.Hash{}, fmt.Errorf("failed to build split transaction: %w", err)
 	}
 
 	// Sign the transaction
@@ -177,21 +259,28 @@ func (cs *CellSplitter) SplitCell(ctx context.Context, privateKey *secp256k1.Pri
 		return types.Hash{}, fmt.Errorf("failed to sign transaction: %w", err)
 	}
 
-	// Submit transaction
-	txHash, err := cs.rpcClient.SendTransaction(ctx, signedTx)
+	// Submit and wait for confirmation
+	cs.logger.Info("waiting for cell split confirmation",
+		zap.String("tx_hash", signedTx.ComputeHash().Hex()),
+		zap.Float64("capacity_ckb", shannonsToCKB(totalCapacity)),
+		zap.Int("before_count", len(cells)),
+	)
+	txHash, err := cs.txBuilder.BroadcastTx(ctx, signedTx)
 	if err != nil {
-		return types.Hash{}, fmt.Errorf("failed to send transaction: %w", err)
+		return txHash, err
 	}
-
-	cs.logger.Info("cell split transaction submitted", zap.String("tx_hash", txHash.Hex()))
-
-	// Wait for confirmation
-	if err := cs.waitForConfirmation(ctx, *txHash); err != nil {
-		return *txHash, fmt.Errorf("transaction not confirmed: %w", err)
+	if err := cs.VerifySplit(ctx, txHash, []uint64{cell1Capacity, cell2Capacity}); err != nil {
+		return txHash, err
 	}
 
-	cs.logger.Info("cell split confirmed", zap.String("tx_hash", txHash.Hex()))
-	return *txHash, nil
+	cs.logger.Info("cell split confirmed",
+		zap.String("tx_hash", txHash.Hex()),
+		zap.Float64("capacity_ckb", shannonsToCKB(totalCapacity)),
+		zap.Int("before_count", len(cells)),
+		zap.Int("after_count", len(cells)+1),
+		zap.Duration("elapsed", time.Since(start)),
+	)
+	return txHash, nil
 }
 
 // getSecp256k1CellDep returns the cell dep for secp256k1 on testnet.
@@ -221,12 +310,14 @@ func (cs *CellSplitter) signTransaction(tx *types.Transaction, privateKey *secp2
 	// Calculate transaction hash
 	txHash := tx.ComputeHash()
 
-	// Calculate message to sign (tx_hash + witness length + witness)
-	witnessLen := len(witnessBytes)
-	message := make([]byte, 32+8+witnessLen)
-	copy(message[:32], txHash[:])
-	binary.LittleEndian.PutUint64(message[32:40], uint64(witnessLen))
-	copy(message[40:], witnessBytes)
+	// Calculate message to sign (tx_hash + length + bytes of every witness).
+	// All inputs share the lock, so they form one group signed at witness 0.
+	message := make([]byte, 0, 32+len(tx.Witnesses)*(8+len(witnessBytes)))
+	message = append(message, txHash[:]...)
+	for _, witness := range tx.Witnesses {
+		message = binary.LittleEndian.AppendUint64(message, uint64(len(witness)))
+		message = append(message, witness...)
+	}
 
 	// Hash the message using blake2b
 	messageHash := blake2b.Blake256(message)
@@ -269,34 +360,6 @@ func signRecoverable(privateKey *ecdsa.PrivateKey, hash []byte) []byte {
 	return result
 }
 
-// waitForConfirmation waits for a transaction to be confirmed.
-func (cs *CellSplitter) waitForConfirmation(ctx context.Context, txHash types.Hash) error {
-	ticker := time.NewTicker(2 * time.Second)
-	defer ticker.Stop()
-
-	timeout := time.After(2 * time.Minute)
-
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-timeout:
-			return fmt.Errorf("timeout waiting for confirmation")
-		case <-ticker.C:
-			txWithStatus, err := cs.rpcClient.GetTransaction(ctx, txHash)
-			if err != nil {
-				continue
-			}
-			if txWithStatus.TxStatus.Status == types.TransactionStatusCommitted {
-				return nil
-			}
-			if txWithStatus.TxStatus.Status == types.TransactionStatusRejected {
-				return fmt.Errorf("transaction rejected: %v", txWithStatus.TxStatus.Reason)
-			}
-		}
-	}
-}
-
 // EnsureMultipleCells ensures the wallet has at least 2 cells for Perun operations.
 func (cs *CellSplitter) EnsureMultipleCells(ctx context.Context, privateKey *secp256k1.PrivateKey, lockScript *types.Script) error {
 	return cs.EnsureMinimumCells(ctx, privateKey, lockScript, 2)
@@ -319,7 +382,6 @@ func (cs *CellSplitter) TransferCellsToGuest(
 	// Each cell needs minimum 61 CKB capacity
 	cellCapacity := CellMinCapacity
 	totalTransfer := uint64(numCells) * cellCapacity
-	totalWithFee := totalTransfer + SplitFee
 
 	// Get host cells
 	hostCells, err := cs.GetCells(ctx, hostLockScript)
@@ -331,16 +393,19 @@ func (cs *CellSplitter) TransferCellsToGuest(
 		return types.Hash{}, 0, fmt.Errorf("host has no cells")
 	}
 
-	// Find cells with enough capacity
+	// Find cells with enough capacity; the fee grows with every input
 	var inputCells []*indexer.LiveCell
 	var inputCapacity uint64
+	fee := cs.fee(1, numCells+1)
 	for _, cell := range hostCells {
 		inputCells = append(inputCells, cell)
 		inputCapacity += cell.Output.Capacity
-		if inputCapacity >= totalWithFee+CellMinCapacity { // Need extra for change
+		fee = cs.fee(len(inputCells), numCells+1)
+		if inputCapacity >= totalTransfer+fee+CellMinCapacity { // Need extra for change
 			break
 		}
 	}
+	totalWithFee := totalTransfer + fee
 
 	if inputCapacity < totalWithFee+CellMinCapacity {
 		return types.Hash{}, 0, fmt.Errorf("insufficient host balance: have %d, need %d",
@@ -362,7 +427,7 @@ func (cs *CellSplitter) TransferCellsToGuest(
 	}
 
 	// Host change cell
-	changeCapacity := inputCapacity - totalTransfer - SplitFee
+	changeCapacity := inputCapacity - totalTransfer - fee
 	outputs[numCells] = &types.CellOutput{
 		Capacity: changeCapacity,
 		Lock:     hostLockScript,
@@ -404,21 +469,13 @@ func (cs *CellSplitter) TransferCellsToGuest(
 		return types.Hash{}, 0, fmt.Errorf("failed to sign transaction: %w", err)
 	}
 
-	// Submit transaction
-	txHash, err := cs.rpcClient.SendTransaction(ctx, signedTx)
+	// Submit and wait for confirmation
+	txHash, err := cs.txBuilder.BroadcastTx(ctx, signedTx)
 	if err != nil {
-		return types.Hash{}, 0, fmt.Errorf("failed to send transaction: %w", err)
-	}
-
-	cs.logger.Info("cell transfer transaction submitted",
-		zap.String("tx_hash", txHash.Hex()),
-		zap.Int("cells_transferred", numCells),
-		zap.Uint64("total_ckb", totalTransfer/100000000),
-	)
-
-	// Wait for confirmation
-	if err := cs.waitForConfirmation(ctx, *txHash); err != nil {
-		return *txHash, totalTransfer, fmt.Errorf("transaction not confirmed: %w", err)
+		if txHash == (types.Hash{}) {
+			return txHash, 0, err
+		}
+		return txHash, totalTransfer, err
 	}
 
 	cs.logger.Info("cell transfer confirmed",
@@ -426,7 +483,7 @@ func (cs *CellSplitter) TransferCellsToGuest(
 		zap.Uint64("total_ckb_transferred", totalTransfer/100000000),
 	)
 
-	return *txHash, totalTransfer, nil
+	return txHash, totalTransfer, nil
 }
 
 // EnsureMinimumCells ensures the wallet has at least minCells cells for Perun operations.
@@ -434,15 +491,17 @@ func (cs *CellSplitter) TransferCellsToGuest(
 // - 1-2 cells for funding contribution
 // - 1 cell for change output
 func (cs *CellSplitter) EnsureMinimumCells(ctx context.Context, privateKey *secp256k1.PrivateKey, lockScript *types.Script, minCells int) error {
+	start := time.Now()
 	count, err := cs.CountCells(ctx, lockScript)
 	if err != nil {
 		return fmt.Errorf("failed to count cells: %w", err)
 	}
+	beforeCount := count
 
-	cs.logger.Info("cell count before preparation", zap.Int("count", count), zap.Int("minimum_required", minCells))
+	cs.logger.Info("cell count before preparation", zap.Int("before_count", count), zap.Int("minimum_required", minCells))
 
 	if count >= minCells {
-		cs.logger.Info("wallet has enough cells", zap.Int("count", count))
+		cs.logger.Info("wallet has enough cells", zap.Int("before_count", count), zap.Int("after_count", count))
 		return nil // Already have enough cells
 	}
 
@@ -450,23 +509,309 @@ func (cs *CellSplitter) EnsureMinimumCells(ctx context.Context, privateKey *secp
 		return fmt.Errorf("no cells found in wallet")
 	}
 
-	// Need to split cells until we have enough
-	for count < minCells {
-		cs.logger.Info("splitting cell to reach minimum", zap.Int("current", count), zap.Int("target", minCells))
+	// Need to split cells until we have enough; each split adds one cell
+	totalSteps := minCells - count
+	for step := 1; count < minCells; step++ {
+		cs.logger.Info("splitting cell to reach minimum",
+			zap.Int("before_count", count),
+			zap.Int("target", minCells),
+			zap.Int("step", step),
+			zap.Int("total_steps", totalSteps),
+		)
 
-		_, err = cs.SplitCell(ctx, privateKey, lockScript)
+		splitStart := time.Now()
+		txHash, err := cs.SplitCell(ctx, privateKey, lockScript)
 		if err != nil {
 			return fmt.Errorf("failed to split cell: %w", err)
 		}
 
 		// Re-count after split
+		before := count
 		count, err = cs.CountCells(ctx, lockScript)
 		if err != nil {
 			return fmt.Errorf("failed to count cells after split: %w", err)
 		}
-		cs.logger.Info("cell count after split", zap.Int("count", count))
+		cs.logger.Info("cell count after split",
+			zap.Int("before_count", before),
+			zap.Int("after_count", count),
+			zap.String("tx_hash", txHash.Hex()),
+			zap.Duration("elapsed", time.Since(splitStart)),
+		)
+		cs.reportProgress(CellSplitProgress{
+			Step:         step,
+			TotalSteps:   max(totalSteps, step),
+			CurrentCells: count,
+			TargetCells:  minCells,
+			LastTxHash:   txHash.Hex(),
+		})
+	}
+
+	cs.logger.Info("wallet cell preparation complete",
+		zap.Int("before_count", beforeCount),
+		zap.Int("after_count", count),
+		zap.Duration("elapsed", time.Since(start)),
+	)
+	return nil
+}
+
+// EstimateSplitCount returns how many cells, up to targetCells, repeated
+// SplitCell calls can produce from a single cell of the given capacity, and
+// the total fee those splits would cost. It reads no cells and submits
+// nothing, so callers can reject a wallet before spending anything on fees;
+// with a fee oracle set, pricing the split may fetch the node's fee rate.
+func (cs *CellSplitter) EstimateSplitCount(capacity uint64, targetCells int) (int, uint64) {
+	if capacity < CellMinCapacity || targetCells <= 0 {
+		return 0, 0
+	}
+	fee := cs.splitFee()
+	achievable := maxCellCount([]uint64{capacity}, fee, targetCells)
+	return achievable, uint64(achievable-1) * fee
+}
+
+// SplitEstimate describes what preparing a wallet's cells would take.
+type SplitEstimate struct {
+	CurrentCells      int    // Pure CKB cells the wallet holds now
+	TargetCells       int    // Cells requested
+	AchievableCells   int    // Cells reachable, merging small cells first if that helps; splitting stops at TargetCells
+	TotalFeeCost      uint64 // Fees for every merge and split transaction needed
+	PerSplitFee       uint64 // Fee of one transaction
+	TotalCapacity     uint64 // Capacity of the pure CKB cells
+	CapacityAfterFees uint64 // TotalCapacity minus TotalFeeCost
+	IsViable          bool   // Whether AchievableCells reaches TargetCells
+}
+
+// DryRunSplit works out whether the wallet can be prepared with targetCells
+// cells, as EnsureMinimumCells followed by the MergeThenSplit fallback would,
+// and what that costs. It only reads cells; nothing is signed or submitted.
+func (cs *CellSplitter) DryRunSplit(ctx context.Context, lockScript *types.Script, targetCells int) (*SplitEstimate, error) {
+	cells, err := cs.GetCellsByCapacity(ctx, lockScript)
+	if err != nil {
+		return nil, err
+	}
+
+	fee := cs.splitFee()
+	estimate := &SplitEstimate{
+		CurrentCells: len(cells),
+		TargetCells:  targetCells,
+		PerSplitFee:  fee,
+	}
+	capacities := make([]uint64, len(cells))
+	var small int
+	for i, cell := range cells {
+		capacities[i] = cell.Output.Capacity
+		estimate.TotalCapacity += cell.Output.Capacity
+		if cell.Output.Capacity < MergeThreshold {
+			small++
+		}
 	}
 
-	cs.logger.Info("wallet cell preparation complete", zap.Int("final_count", count))
-	return nil
+	mergeFee := cs.fee(small, 1)
+	achievable, splits, merged := planCellSplits(capacities, fee, mergeFee, targetCells)
+	estimate.AchievableCells = achievable
+	estimate.TotalFeeCost = uint64(splits) * fee
+	if merged {
+		estimate.TotalFeeCost += mergeFee
+	}
+	if estimate.TotalFeeCost < estimate.TotalCapacity {
+		estimate.CapacityAfterFees = estimate.TotalCapacity - estimate.TotalFeeCost
+	}
+	estimate.IsViable = targetCells > 0 && achievable >= targetCells
+	return estimate, nil
+}
+
+// planCellSplits returns how many cells, up to target, the wallet's
+// capacities can reach, how many split transactions that takes and whether
+// a merge comes first. Splitting alone is tried first; when it falls short,
+// cells below MergeThreshold are merged into one before splitting, as
+// MergeThenSplit does. Each split pays fee and the merge pays mergeFee.
+func planCellSplits(capacities []uint64, fee, mergeFee uint64, target int) (int, int, bool) {
+	achievable := maxCellCount(capacities, fee, target)
+	txCount := achievable - len(capacities)
+	if achievable >= target {
+		return achievable, max(txCount, 0), false
+	}
+
+	var merged, small []uint64
+	var smallTotal uint64
+	for _, capacity := range capacities {
+		if capacity < MergeThreshold {
+			small = append(small, capacity)
+			smallTotal += capacity
+		} else {
+			merged = append(merged, capacity)
+		}
+	}
+	if len(small) < 2 || smallTotal <= mergeFee {
+		return achievable, max(txCount, 0), false
+	}
+	merged = append(merged, smallTotal-mergeFee)
+	if mergedAchievable := maxCellCount(merged, fee, target); mergedAchievable > achievable {
+		return mergedAchievable, mergedAchievable - len(merged), true
+	}
+	return achievable, max(txCount, 0), false
+}
+
+// MergeThenSplit consolidates cells below MergeThreshold into one cell and then
+// splits until the wallet holds targetCount cells. It's the fallback for wallets
+// that EnsureMinimumCells can't prepare because their capacity is spread over
+// cells too small to split. If targetCount can't be reached it returns
+// ErrInsufficientCapacity with the maximum achievable count, before spending
+// anything on fees. Returns the hash of the last transaction submitted.
+func (cs *CellSplitter) MergeThenSplit(ctx context.Context, privateKey *secp256k1.PrivateKey, lockScript *types.Script, targetCount int) (types.Hash, error) {
+	cells, err := cs.GetCellsByCapacity(ctx, lockScript)
+	if err != nil {
+		return types.Hash{}, err
+	}
+	if len(cells) == 0 {
+		return types.Hash{}, fmt.Errorf("no cells found in wallet")
+	}
+
+	var small []*indexer.LiveCell
+	var capacities []uint64
+	for _, cell := range cells {
+		if cell.Output.Capacity < MergeThreshold {
+			small = append(small, cell)
+		} else {
+			capacities = append(capacities, cell.Output.Capacity)
+		}
+	}
+
+	fee := cs.splitFee()
+	mergeFee := cs.fee(len(small), 1)
+	merge := len(small) > 1
+	if merge {
+		capacities = append(capacities, mergedCapacity(small, mergeFee))
+	} else {
+		for _, cell := range small {
+			capacities = append(capacities, cell.Output.Capacity)
+		}
+	}
+
+	if achievable := maxCellCount(capacities, fee, targetCount); achievable < targetCount {
+		return types.Hash{}, fmt.Errorf("%w: can reach %d of %d cells", ErrInsufficientCapacity, achievable, targetCount)
+	}
+
+	start := time.Now()
+	totalSteps := max(targetCount-len(capacities), 0)
+	step := 0
+	var lastHash types.Hash
+	if merge {
+		totalSteps++
+		lastHash, err = cs.mergeCells(ctx, privateKey, lockScript, small, mergeFee)
+		if err != nil {
+			return lastHash, fmt.Errorf("failed to merge cells: %w", err)
+		}
+		step++
+		cs.reportProgress(CellSplitProgress{
+			Step:         step,
+			TotalSteps:   totalSteps,
+			CurrentCells: len(capacities),
+			TargetCells:  targetCount,
+			LastTxHash:   lastHash.Hex(),
+		})
+	}
+
+	for count := len(capacities); count < targetCount; count++ {
+		cs.logger.Info("splitting merged cell", zap.Int("before_count", count), zap.Int("target", targetCount))
+		lastHash, err = cs.SplitCell(ctx, privateKey, lockScript)
+		if err != nil {
+			return lastHash, fmt.Errorf("failed to split cell: %w", err)
+		}
+		step++
+		cs.reportProgress(CellSplitProgress{
+			Step:         step,
+			TotalSteps:   totalSteps,
+			CurrentCells: count + 1,
+			TargetCells:  targetCount,
+			LastTxHash:   lastHash.Hex(),
+		})
+	}
+
+	cs.logger.Info("merge and split complete",
+		zap.Int("before_count", len(cells)),
+		zap.Int("after_count", max(len(capacities), targetCount)),
+		zap.String("tx_hash", lastHash.Hex()),
+		zap.Duration("elapsed", time.Since(start)),
+	)
+	return lastHash, nil
+}
+
+// mergeCells spends cells into a single cell holding their capacity minus fee.
+func (cs *CellSplitter) mergeCells(ctx context.Context, privateKey *secp256k1.PrivateKey, lockScript *types.Script, cells []*indexer.LiveCell, fee uint64) (types.Hash, error) {
+	capacity := mergedCapacity(cells, fee)
+	start := time.Now()
+	cs.logger.Info("merging cells",
+		zap.Int("input_count", len(cells)),
+		zap.Float64("capacity_ckb", shannonsToCKB(capacity)),
+		zap.Uint64("fee", fee),
+	)
+
+	inputs := make([]*types.CellInput, len(cells))
+	witnesses := make([][]byte, len(cells))
+	for i, cell := range cells {
+		inputs[i] = &types.CellInput{
+			Since:          0,
+			PreviousOutput: cell.OutPoint,
+		}
+		witnesses[i] = []byte{}
+	}
+	witnesses[0] = make([]byte, 85) // Placeholder for signature
+
+	tx := &types.Transaction{
+		Version:  0,
+		CellDeps: []*types.CellDep{getSecp256k1CellDep()},
+		Inputs:   inputs,
+		Outputs: []*types.CellOutput{
+			{
+				Capacity: capacity,
+				Lock:     lockScript,
+				Type:     nil,
+			},
+		},
+		OutputsData: [][]byte{{}},
+		Witnesses:   witnesses,
+	}
+
+	signedTx, err := cs.signTransaction(tx, privateKey, lockScript)
+	if err != nil {
+		return types.Hash{}, fmt.Errorf("failed to sign transaction: %w", err)
+	}
+
+	txHash, err := cs.txBuilder.BroadcastTx(ctx, signedTx)
+	if err != nil {
+		return txHash, err
+	}
+
+	cs.logger.Info("cell merge confirmed",
+		zap.String("tx_hash", txHash.Hex()),
+		zap.Float64("capacity_ckb", shannonsToCKB(capacity)),
+		zap.Duration("elapsed", time.Since(start)),
+	)
+	return txHash, nil
+}
+
+// mergedCapacity returns the capacity of the cell that merging cells produces.
+func mergedCapacity(cells []*indexer.LiveCell, fee uint64) uint64 {
+	var total uint64
+	for _, cell := range cells {
+		total += cell.Output.Capacity
+	}
+	return total - fee
+}
+
+// maxCellCount returns how many cells repeated SplitCell calls can reach from
+// capacities, stopping once limit is reached. Like SplitCell, each step halves
+// the largest cell and stops when it can no longer hold two cells plus fee.
+func maxCellCount(capacities []uint64, fee uint64, limit int) int {
+	cells := append([]uint64(nil), capacities...)
+	for len(cells) < limit {
+		sort.Slice(cells, func(i, j int) bool { return cells[i] > cells[j] })
+		if len(cells) == 0 || cells[0] < 2*CellMinCapacity+fee {
+			break
+		}
+		available := cells[0] - fee
+		cells[0] = available / 2
+		cells = append(cells, available-cells[0])
+	}
+	return len(cells)
 }
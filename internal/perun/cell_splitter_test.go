@@ -0,0 +1,203 @@
+package perun
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nervosnetwork/ckb-sdk-go/v2/indexer"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
+	"go.uber.org/zap"
+)
+
+func TestCellSplitter_GetCellsByCapacity(t *testing.T) {
+	typed := newTestCell(900, 3)
+	typed.Output.Type = &types.Script{}
+
+	rpcClient := &mockRPCClient{cells: []*indexer.LiveCell{
+		newTestCell(100, 0),
+		newTestCell(500, 1),
+		newTestCell(300, 2),
+		typed,
+	}}
+	cs := NewCellSplitter(rpcClient, zap.NewNop())
+
+	cells, err := cs.GetCellsByCapacity(context.Background(), &types.Script{})
+	if err != nil {
+		t.Fatalf("GetCellsByCapacity failed: %v", err)
+	}
+
+	expected := []uint64{500, 300, 100}
+	if len(cells) != len(expected) {
+		t.Fatalf("Expected %d cells, got %d", len(expected), len(cells))
+	}
+	for i, cell := range cells {
+		if cell.Output.Capacity != expected[i] {
+			t.Errorf("Position %d: expected %d, got %d", i, expected[i], cell.Output.Capacity)
+		}
+	}
+}
+
+func TestCellSplitter_GetCellsByCapacity_Paging(t *testing.T) {
+	cells := make([]*indexer.LiveCell, 250)
+	for i := range cells {
+		cells[i] = newTestCell(uint64(i+1), uint32(i))
+	}
+	cs := NewCellSplitter(&mockRPCClient{cells: cells}, zap.NewNop())
+
+	result, err := cs.GetCellsByCapacity(context.Background(), &types.Script{})
+	if err != nil {
+		t.Fatalf("GetCellsByCapacity failed: %v", err)
+	}
+	if len(result) != 250 {
+		t.Fatalf("Expected 250 cells across pages, got %d", len(result))
+	}
+	if result[0].Output.Capacity != 250 {
+		t.Errorf("Largest cell should be first, got %d", result[0].Output.Capacity)
+	}
+}
+
+func TestCellSplitter_GetTotalCapacity(t *testing.T) {
+	rpcClient := &mockRPCClient{cells: []*indexer.LiveCell{
+		newTestCell(100, 0),
+		newTestCell(500, 1),
+		newTestCell(300, 2),
+	}}
+	cs := NewCellSplitter(rpcClient, zap.NewNop())
+
+	total, err := cs.GetTotalCapacity(context.Background(), &types.Script{})
+	if err != nil {
+		t.Fatalf("GetTotalCapacity failed: %v", err)
+	}
+	if total != 900 {
+		t.Errorf("Expected 900, got %d", total)
+	}
+}
+
+func TestMaxCellCount(t *testing.T) {
+	tests := []struct {
+		name       string
+		capacities []uint64
+		limit      int
+		expected   int
+	}{
+		{"already enough", []uint64{CellMinCapacity, CellMinCapacity}, 2, 2},
+		{"too small to split", []uint64{2 * CellMinCapacity}, 4, 1},
+		{"one split", []uint64{2*CellMinCapacity + SplitFee}, 4, 2},
+		{"stops at limit", []uint64{100 * CellMinCapacity}, 4, 4},
+		{"halving leaves remainder", []uint64{5 * CellMinCapacity}, 5, 4},
+		{"no cells", nil, 2, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := maxCellCount(tt.capacities, SplitFee, tt.limit); got != tt.expected {
+				t.Errorf("Expected %d cells, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestCellSplitter_EstimateSplitCount(t *testing.T) {
+	cs := NewCellSplitter(nil, zap.NewNop())
+
+	tests := []struct {
+		name        string
+		capacity    uint64
+		target      int
+		expected    int
+		expectedFee uint64
+	}{
+		{"below cell minimum", CellMinCapacity - 1, 4, 0, 0},
+		{"single cell", 2 * CellMinCapacity, 4, 1, 0},
+		{"one split", 2*CellMinCapacity + SplitFee, 4, 2, SplitFee},
+		{"reaches target", 100 * CellMinCapacity, 4, 4, 3 * SplitFee},
+		{"zero target", 100 * CellMinCapacity, 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			achievable, fee := cs.EstimateSplitCount(tt.capacity, tt.target)
+			if achievable != tt.expected {
+				t.Errorf("Expected %d cells, got %d", tt.expected, achievable)
+			}
+			if fee != tt.expectedFee {
+				t.Errorf("Expected fee %d, got %d", tt.expectedFee, fee)
+			}
+		})
+	}
+}
+
+func TestCellSplitter_MergeThenSplit_InsufficientCapacity(t *testing.T) {
+	rpcClient := &mockRPCClient{cells: []*indexer.LiveCell{
+		newTestCell(CellMinCapacity+100, 0),
+		newTestCell(CellMinCapacity+100, 1),
+		newTestCell(CellMinCapacity+100, 2),
+	}}
+	cs := NewCellSplitter(rpcClient, zap.NewNop())
+
+	// Three 61 CKB cells merge into ~183 CKB, which splits into at most 2
+	_, err := cs.MergeThenSplit(context.Background(), nil, &types.Script{}, 4)
+	if !errors.Is(err, ErrInsufficientCapacity) {
+		t.Fatalf("Expected ErrInsufficientCapacity, got %v", err)
+	}
+	if err.Error() != "insufficient capacity for requested cell count: can reach 2 of 4 cells" {
+		t.Errorf("Unexpected error message: %v", err)
+	}
+	if rpcClient.sentTxCount != 0 {
+		t.Errorf("Expected no transactions to be sent, got %d", rpcClient.sentTxCount)
+	}
+}
+
+func TestCellSplitter_DryRunSplit(t *testing.T) {
+	tests := []struct {
+		name       string
+		capacities []uint64
+		target     int
+		achievable int
+		txCount    uint64
+		viable     bool
+	}{
+		{"split one cell", []uint64{100 * CellMinCapacity}, 4, 4, 3, true},
+		{"already enough cells", []uint64{CellMinCapacity, CellMinCapacity, CellMinCapacity, CellMinCapacity, CellMinCapacity}, 4, 5, 0, true},
+		// Each 1.5x cell is too small to split; merged they split into four
+		{"merge then split", []uint64{CellMinCapacity * 3 / 2, CellMinCapacity * 3 / 2, CellMinCapacity * 3 / 2}, 4, 4, 4, true},
+		{"too little capacity", []uint64{CellMinCapacity + 100, CellMinCapacity + 100, CellMinCapacity + 100}, 4, 3, 0, false},
+		{"empty wallet", nil, 4, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rpcClient := &mockRPCClient{}
+			var total uint64
+			for i, capacity := range tt.capacities {
+				rpcClient.cells = append(rpcClient.cells, newTestCell(capacity, uint32(i)))
+				total += capacity
+			}
+			cs := NewCellSplitter(rpcClient, zap.NewNop())
+
+			estimate, err := cs.DryRunSplit(context.Background(), &types.Script{}, tt.target)
+			if err != nil {
+				t.Fatalf("DryRunSplit failed: %v", err)
+			}
+			if estimate.CurrentCells != len(tt.capacities) || estimate.TargetCells != tt.target {
+				t.Errorf("Expected %d current and %d target cells, got %+v", len(tt.capacities), tt.target, estimate)
+			}
+			if estimate.AchievableCells != tt.achievable {
+				t.Errorf("Expected %d achievable cells, got %d", tt.achievable, estimate.AchievableCells)
+			}
+			if estimate.IsViable != tt.viable {
+				t.Errorf("Expected viable=%v, got %v", tt.viable, estimate.IsViable)
+			}
+			if estimate.PerSplitFee != SplitFee || estimate.TotalFeeCost != tt.txCount*SplitFee {
+				t.Errorf("Expected fee %d for %d transactions, got %d (per split %d)", tt.txCount*SplitFee, tt.txCount, estimate.TotalFeeCost, estimate.PerSplitFee)
+			}
+			if estimate.TotalCapacity != total || estimate.CapacityAfterFees != total-estimate.TotalFeeCost {
+				t.Errorf("Expected capacity %d, %d after fees, got %d and %d", total, total-estimate.TotalFeeCost, estimate.TotalCapacity, estimate.CapacityAfterFees)
+			}
+			if rpcClient.sentTxCount != 0 {
+				t.Errorf("Expected no transactions to be sent, got %d", rpcClient.sentTxCount)
+			}
+		})
+	}
+}
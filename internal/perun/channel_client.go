@@ -2,10 +2,12 @@ package perun
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/big"
 	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/decred/dcrd/dcrec/secp256k1/v4"
@@ -34,6 +36,7 @@ import (
 // ChannelClient wraps go-perun client for proper channel management.
 type ChannelClient struct {
 	perunClient  *gpclient.Client
+	privateKey   *secp256k1.PrivateKey
 	account      *ckbwallet.Account
 	wallet       *ckbwallettest.TestEphemeralWallet
 	funder       gpchannel.Funder
@@ -44,9 +47,55 @@ type ChannelClient struct {
 	rpcClient    rpc.Client
 	logger       *zap.Logger
 
+	// Encoded address and lock script of account, fixed for the client's life
+	cachedAddress    string
+	cachedLockScript *types.Script
+
+	fundingTimeout        time.Duration
+	retryFundingOnTimeout bool
+	coopCloseTimeout      time.Duration
+	feeOracle             *NetworkFeeOracle
+
 	// Active channels
 	channels   map[gpchannel.ID]*ActiveChannel
 	channelsMu sync.RWMutex
+
+	// Payment updates sent but not yet acknowledged by the peer
+	pending   []*PendingPayment
+	pendingMu sync.Mutex
+
+	// Last on-chain balance, reused by GetBalance for balanceCacheTTL
+	balanceCacheTTL time.Duration
+	balanceCache    struct {
+		balance   *big.Int
+		fetchedAt time.Time
+	}
+	balanceMu sync.RWMutex
+
+	updateValidator UpdateValidator
+	asset           gpchannel.Asset
+	disputes        DisputeRecorder
+
+	// Per-channel overrides of the update validator
+	updateHandlers   map[ChannelID]UpdateHandler
+	updateHandlersMu sync.RWMutex
+
+	closed atomic.Bool
+}
+
+// DefaultBalanceCacheTTL is how long GetBalance reuses a fetched balance
+// when no BalanceCacheTTL is configured.
+const DefaultBalanceCacheTTL = 10 * time.Second
+
+// PaymentAckTimeout bounds how long a payment waits for the peer to
+// acknowledge it. Unacknowledged updates are discarded by go-perun.
+const PaymentAckTimeout = 30 * time.Second
+
+// PendingPayment is a payment state update the peer hasn't acknowledged yet.
+type PendingPayment struct {
+	Amount  *big.Int
+	SentAt  time.Time
+	Version uint64 // state version the update proposes
 }
 
 // ActiveChannel represents an active Perun channel with proper state management.
@@ -54,8 +103,14 @@ type ActiveChannel struct {
 	Channel     *gpclient.Channel
 	PeerAddress string
 	CreatedAt   time.Time
+
+	// InitialBalance is what this client funded the channel with.
+	InitialBalance *big.Int
 }
 
+// deploymentVerifyTimeout bounds the contract cell lookups in NewChannelClient.
+const deploymentVerifyTimeout = 30 * time.Second
+
 // ChannelClientConfig contains configuration for the channel client.
 type ChannelClientConfig struct {
 	RPCURL     string
@@ -63,6 +118,27 @@ type ChannelClientConfig struct {
 	Deployment backend.Deployment
 	Logger     *zap.Logger
 	WireBus    *gpwire.LocalBus // Shared bus for communication
+
+	// FundingTimeout bounds each channel proposal including on-chain
+	// funding. Zero uses DefaultFundingTimeout.
+	FundingTimeout time.Duration
+	// RetryFundingOnTimeout re-proposes once with a doubled timeout, unless
+	// the timed-out channel already has a cell on-chain.
+	RetryFundingOnTimeout bool
+	// CoopCloseTimeout bounds how long SubmitCooperativeClose waits for the
+	// peer to sign the final state. Zero uses DefaultCoopCloseTimeout.
+	CoopCloseTimeout time.Duration
+	// BalanceCacheTTL is how long GetBalance reuses a fetched balance.
+	// Zero uses DefaultBalanceCacheTTL.
+	BalanceCacheTTL time.Duration
+	// UpdateValidator checks channel updates proposed by the peer before
+	// HandleUpdate signs them. Nil accepts every update.
+	UpdateValidator UpdateValidator
+	// Asset is what ProposeChannel funds channels with. Nil uses CKBytes.
+	Asset gpchannel.Asset
+	// DisputeRecorder is told when SubmitCooperativeClose falls back to a
+	// dispute and when that dispute is resolved. Nil records nothing.
+	DisputeRecorder DisputeRecorder
 }
 
 // NewChannelClient creates a new go-perun based channel client.
@@ -77,6 +153,14 @@ func NewChannelClient(cfg *ChannelClientConfig) (*ChannelClient, error) {
 		return nil, fmt.Errorf("failed to dial RPC: %w", err)
 	}
 
+	// Catch a wrong network or stale contract addresses before funding anything
+	verifyCtx, cancel := context.WithTimeout(context.Background(), deploymentVerifyTimeout)
+	err = (&Deployment{Deployment: cfg.Deployment}).Verify(verifyCtx, rpcClient)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify deployment: %w", err)
+	}
+
 	// Create wallet account from private key
 	account := ckbwallet.NewAccountFromPrivateKey(cfg.PrivateKey)
 
@@ -134,6 +218,7 @@ func NewChannelClient(cfg *ChannelClientConfig) (*ChannelClient, error) {
 
 	return &ChannelClient{
 		perunClient:  perunClient,
+		privateKey:   cfg.PrivateKey,
 		account:      account,
 		wallet:       wallet,
 		funder:       channelFunder,
@@ -144,15 +229,29 @@ func NewChannelClient(cfg *ChannelClientConfig) (*ChannelClient, error) {
 		rpcClient:    rpcClient,
 		logger:       cfg.Logger,
 		channels:     make(map[gpchannel.ID]*ActiveChannel),
+
+		cachedAddress:    addressStr,
+		cachedLockScript: ckbAddress.Script,
+
+		fundingTimeout:        cfg.FundingTimeout,
+		retryFundingOnTimeout: cfg.RetryFundingOnTimeout,
+		coopCloseTimeout:      cfg.CoopCloseTimeout,
+		balanceCacheTTL:       cfg.BalanceCacheTTL,
+		updateValidator:       cfg.UpdateValidator,
+		asset:                 cfg.Asset,
+		disputes:              cfg.DisputeRecorder,
 	}, nil
 }
 
 // GetAddress returns the CKB address of this client.
 func (cc *ChannelClient) GetAddress() string {
-	participant := address.AsParticipant(cc.account.Address())
-	ckbAddr := participant.ToCKBAddress(types.NetworkTest)
-	addrStr, _ := ckbAddr.Encode()
-	return addrStr
+	return cc.cachedAddress
+}
+
+// GetLockScript returns the lock script of this client's address, sparing
+// callers a decode of GetAddress. Callers must not modify it.
+func (cc *ChannelClient) GetLockScript() *types.Script {
+	return cc.cachedLockScript
 }
 
 // GetWireAddress returns the wire address for channel proposals.
@@ -165,24 +264,32 @@ func (cc *ChannelClient) GetAccount() gpwallet.Account {
 	return cc.account
 }
 
-// GetBalance returns the on-chain CKB balance.
+// GetBalance returns the on-chain CKB balance. Results are cached for the
+// configured BalanceCacheTTL, since health checks and dashboards ask for it
+// far more often than it changes. Use RefreshBalance before spending.
 func (cc *ChannelClient) GetBalance(ctx context.Context) (*big.Int, error) {
-	participant := address.AsParticipant(cc.account.Address())
-	ckbAddress := participant.ToCKBAddress(types.NetworkTest)
-
-	addressStr, err := ckbAddress.Encode()
-	if err != nil {
-		return nil, fmt.Errorf("failed to encode address: %w", err)
+	cc.balanceMu.RLock()
+	cached := cc.balanceCache
+	cc.balanceMu.RUnlock()
+	if cached.balance != nil && time.Since(cached.fetchedAt) < cc.balanceTTL() {
+		return new(big.Int).Set(cached.balance), nil
 	}
+	return cc.RefreshBalance(ctx)
+}
+
+// RefreshBalance queries the on-chain CKB balance, bypassing and updating
+// the cache. A failed query returns zero and is not cached.
+func (cc *ChannelClient) RefreshBalance(ctx context.Context) (*big.Int, error) {
+	addressStr, lockScript := cc.cachedAddress, cc.cachedLockScript
 
-	cc.logger.Info("querying balance",
+	cc.logger.Debug("querying balance",
 		zap.String("address", addressStr),
-		zap.String("code_hash", ckbAddress.Script.CodeHash.String()),
-		zap.String("args", fmt.Sprintf("0x%x", ckbAddress.Script.Args)),
+		zap.String("code_hash", lockScript.CodeHash.String()),
+		zap.String("args", fmt.Sprintf("0x%x", lockScript.Args)),
 	)
 
 	capacity, err := cc.rpcClient.GetCellsCapacity(ctx, &indexer.SearchKey{
-		Script:     ckbAddress.Script,
+		Script:     lockScript,
 		ScriptType: types.ScriptTypeLock,
 	})
 	if err != nil {
@@ -190,12 +297,33 @@ func (cc *ChannelClient) GetBalance(ctx context.Context) (*big.Int, error) {
 		return big.NewInt(0), nil
 	}
 
-	cc.logger.Info("balance query result",
+	cc.logger.Debug("balance query result",
 		zap.Uint64("capacity", capacity.Capacity),
 		zap.Float64("capacity_ckb", float64(capacity.Capacity)/100000000),
 	)
 
-	return big.NewInt(int64(capacity.Capacity)), nil
+	balance := new(big.Int).SetUint64(capacity.Capacity)
+	cc.balanceMu.Lock()
+	cc.balanceCache.balance = balance
+	cc.balanceCache.fetchedAt = time.Now()
+	cc.balanceMu.Unlock()
+	return new(big.Int).Set(balance), nil
+}
+
+// balanceTTL returns how long a fetched balance is reused.
+func (cc *ChannelClient) balanceTTL() time.Duration {
+	if cc.balanceCacheTTL > 0 {
+		return cc.balanceCacheTTL
+	}
+	return DefaultBalanceCacheTTL
+}
+
+// paymentAsset returns the asset new channels are funded with.
+func (cc *ChannelClient) paymentAsset() gpchannel.Asset {
+	if cc.asset == nil {
+		return asset.NewCKBytesAsset()
+	}
+	return cc.asset
 }
 
 // ProposeChannel proposes a new channel to a peer.
@@ -220,7 +348,7 @@ func (cc *ChannelClient) ProposeChannel(
 	)
 
 	// Check our balance before proposing
-	balance, err := cc.GetBalance(ctx)
+	balance, err := cc.RefreshBalance(ctx)
 	if err != nil {
 		cc.logger.Warn("failed to check balance before proposal", zap.Error(err))
 	} else {
@@ -232,38 +360,51 @@ func (cc *ChannelClient) ProposeChannel(
 	}
 
 	// Create allocation
-	ckbAsset := asset.NewCKBytesAsset()
-	initAlloc := gpchannel.NewAllocation(2, ckbAsset)
-	initAlloc.SetAssetBalances(ckbAsset, []gpchannel.Bal{myFunding, peerFunding})
-
-	// Create proposal - peers array must include ALL participants (including ourselves)
-	proposal, err := gpclient.NewLedgerChannelProposal(
-		ChallengeBlocks,
-		cc.account.Address(),
-		initAlloc,
-		[]gpwire.Address{cc.wireAddress, peerWireAddr},
-		gpclient.WithoutApp(),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create proposal: %w", err)
-	}
-
-	// Propose channel
-	ch, err := cc.perunClient.ProposeChannel(ctx, proposal)
+	paymentAsset := cc.paymentAsset()
+	initAlloc := gpchannel.NewAllocation(2, paymentAsset)
+	initAlloc.SetAssetBalances(paymentAsset, []gpchannel.Bal{myFunding, peerFunding})
+
+	// Propose channel; a retry needs a fresh proposal (new nonce). go-perun
+	// returns the channel when funding fails, so a retry can first check
+	// whether the timed-out attempt already put a channel cell on-chain.
+	var ch *gpclient.Channel
+	err = runWithFundingTimeout(ctx, cc.fundingTimeout, cc.retryFundingOnTimeout, cc.logger, func(ctx context.Context) error {
+		// Create proposal - peers array must include ALL participants (including ourselves)
+		proposal, err := gpclient.NewLedgerChannelProposal(
+			ChallengeBlocks,
+			cc.account.Address(),
+			initAlloc,
+			[]gpwire.Address{cc.wireAddress, peerWireAddr},
+			gpclient.WithoutApp(),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create proposal: %w", err)
+		}
+
+		ch, err = cc.perunClient.ProposeChannel(ctx, proposal)
+		if err != nil {
+			return fmt.Errorf("failed to propose channel: %w", err)
+		}
+		return nil
+	}, func(ctx context.Context) error {
+		return cc.checkNoChannelCell(ctx, ch)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to propose channel: %w", err)
+		return nil, err
 	}
 
 	// Store active channel
 	cc.channelsMu.Lock()
 	cc.channels[ch.ID()] = &ActiveChannel{
-		Channel:   ch,
-		CreatedAt: time.Now(),
+		Channel:        ch,
+		PeerAddress:    participantAddress(peerPerunAddr),
+		CreatedAt:      time.Now(),
+		InitialBalance: new(big.Int).Set(myFunding),
 	}
 	cc.channelsMu.Unlock()
 
 	cc.logger.Info("channel proposed successfully",
-		zap.String("channel_id", fmt.Sprintf("%x", ch.ID())),
+		zap.String("channel_id", ChannelID(ch.ID()).String()),
 	)
 
 	return ch, nil
@@ -280,60 +421,192 @@ func (cc *ChannelClient) HandleProposals(handler ChannelHandler) {
 	go cc.perunClient.Handle(handler, handler)
 }
 
-// SendPayment sends an off-chain payment in the channel.
-// This properly signs the new state with both parties.
+// SendPayment sends an off-chain payment in the channel and waits for the
+// peer to acknowledge it. This properly signs the new state with both parties.
 func (cc *ChannelClient) SendPayment(ch *gpclient.Channel, amount *big.Int) error {
+	payment, err := cc.startPayment(ch, amount)
+	if err != nil {
+		return err
+	}
+	return cc.finishPayment(ch, payment)
+}
+
+// SendPaymentAsync is SendPayment without waiting for the peer. The payment
+// is listed by GetPendingPayments until the peer acknowledges it or it is
+// dropped after PaymentAckTimeout; done then receives the outcome. An
+// invalid payment is reported straight away and done isn't called.
+func (cc *ChannelClient) SendPaymentAsync(ch *gpclient.Channel, amount *big.Int, done func(error)) error {
+	payment, err := cc.startPayment(ch, amount)
+	if err != nil {
+		return err
+	}
+	go func() {
+		done(cc.finishPayment(ch, payment))
+	}()
+	return nil
+}
+
+// startPayment checks that the channel can afford amount and tracks the
+// payment as pending.
+func (cc *ChannelClient) startPayment(ch *gpclient.Channel, amount *big.Int) (*PendingPayment, error) {
 	cc.logger.Info("sending payment",
-		zap.String("channel_id", fmt.Sprintf("%x", ch.ID())),
+		zap.String("channel_id", ChannelID(ch.ID()).String()),
 		zap.String("amount", amount.String()),
 	)
 
-	// Get current state
 	state := ch.State().Clone()
-
-	// Update balances (send from us to peer)
-	ckbAsset := asset.NewCKBytesAsset()
-	myIdx := ch.Idx()
-	peerIdx := 1 - myIdx
-
-	myBal := state.Allocation.Balance(myIdx, ckbAsset)
-	peerBal := state.Allocation.Balance(peerIdx, ckbAsset)
-
-	if myBal.Cmp(amount) < 0 {
-		return fmt.Errorf("insufficient balance: have %s, want %s", myBal.String(), amount.String())
+	if _, err := applyPayment(&state.Allocation, ch.Idx(), amount); err != nil {
+		return nil, err
 	}
+	return cc.trackPayment(amount, state.Version+1), nil
+}
 
-	// Create new balances
-	newMyBal := new(big.Int).Sub(myBal, amount)
-	newPeerBal := new(big.Int).Add(peerBal, amount)
+// finishPayment proposes the state update for a tracked payment and stops
+// tracking it once the peer acknowledged or the update was dropped.
+func (cc *ChannelClient) finishPayment(ch *gpclient.Channel, payment *PendingPayment) error {
+	defer cc.untrackPayment(payment)
 
-	// Set new balances in the right order
-	newBals := make([]gpchannel.Bal, 2)
-	newBals[myIdx] = newMyBal
-	newBals[peerIdx] = newPeerBal
-	state.Allocation.SetAssetBalances(ckbAsset, newBals)
+	ctx, cancel := context.WithTimeout(context.Background(), PaymentAckTimeout)
+	defer cancel()
 
-	// Update the channel state (this handles signing automatically)
-	err := ch.Update(context.Background(), func(s *gpchannel.State) {
-		s.Allocation = state.Allocation
+	// The payment applies to the state current when the update runs, so
+	// updates queued behind each other don't overwrite one another
+	var newMyBal *big.Int
+	var applyErr error
+	err := ch.Update(ctx, func(s *gpchannel.State) {
+		newMyBal, applyErr = applyPayment(&s.Allocation, ch.Idx(), payment.Amount)
 	})
 	if err != nil {
 		return fmt.Errorf("failed to update channel: %w", err)
 	}
+	if applyErr != nil {
+		return applyErr
+	}
 
 	cc.logger.Info("payment sent",
-		zap.String("channel_id", fmt.Sprintf("%x", ch.ID())),
+		zap.String("channel_id", ChannelID(ch.ID()).String()),
 		zap.String("new_balance", newMyBal.String()),
 	)
-
 	return nil
 }
 
+// GetPendingPayments returns payments sent in this client's channels that
+// the peer hasn't acknowledged yet, oldest first. A payment stops being
+// pending once it is acknowledged, rejected or times out.
+func (cc *ChannelClient) GetPendingPayments() ([]*PendingPayment, error) {
+	cc.pendingMu.Lock()
+	defer cc.pendingMu.Unlock()
+
+	pending := make([]*PendingPayment, 0, len(cc.pending))
+	for _, p := range cc.pending {
+		pending = append(pending, &PendingPayment{
+			Amount:  new(big.Int).Set(p.Amount),
+			SentAt:  p.SentAt,
+			Version: p.Version,
+		})
+	}
+	return pending, nil
+}
+
+// trackPayment records a payment as pending until untrackPayment is called.
+func (cc *ChannelClient) trackPayment(amount *big.Int, version uint64) *PendingPayment {
+	p := &PendingPayment{
+		Amount:  new(big.Int).Set(amount),
+		SentAt:  time.Now(),
+		Version: version,
+	}
+	cc.pendingMu.Lock()
+	cc.pending = append(cc.pending, p)
+	cc.pendingMu.Unlock()
+	return p
+}
+
+// untrackPayment removes a payment from the pending list.
+func (cc *ChannelClient) untrackPayment(p *PendingPayment) {
+	cc.pendingMu.Lock()
+	defer cc.pendingMu.Unlock()
+	for i, pending := range cc.pending {
+		if pending == p {
+			cc.pending = append(cc.pending[:i], cc.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+// SendPaymentBatch sends several payments as a single state update for
+// their total. Used to catch up on missed payment intervals without
+// signing one state per interval.
+func (cc *ChannelClient) SendPaymentBatch(ch *gpclient.Channel, amounts []*big.Int) error {
+	total, err := sumPayments(amounts)
+	if err != nil {
+		return err
+	}
+	if total.Sign() == 0 {
+		return nil
+	}
+
+	cc.logger.Info("sending payment batch",
+		zap.String("channel_id", ChannelID(ch.ID()).String()),
+		zap.Int("payments", len(amounts)),
+		zap.String("total", total.String()),
+	)
+	return cc.SendPayment(ch, total)
+}
+
+// SendPaymentBatchAsync is SendPaymentBatch without waiting for the peer,
+// like SendPaymentAsync.
+func (cc *ChannelClient) SendPaymentBatchAsync(ch *gpclient.Channel, amounts []*big.Int, done func(error)) error {
+	total, err := sumPayments(amounts)
+	if err != nil {
+		return err
+	}
+	if total.Sign() == 0 {
+		go done(nil)
+		return nil
+	}
+	return cc.SendPaymentAsync(ch, total, done)
+}
+
+// sumPayments returns the exact total of amounts, rejecting negative entries.
+func sumPayments(amounts []*big.Int) (*big.Int, error) {
+	total := new(big.Int)
+	for i, amount := range amounts {
+		if amount == nil || amount.Sign() < 0 {
+			return nil, fmt.Errorf("invalid payment amount at index %d", i)
+		}
+		total.Add(total, amount)
+	}
+	return total, nil
+}
+
+// applyPayment moves amount of the channel's asset from participant myIdx
+// to the peer in alloc and returns the payer's new balance.
+func applyPayment(alloc *gpchannel.Allocation, myIdx gpchannel.Index, amount *big.Int) (*big.Int, error) {
+	paymentAsset := ChannelAsset(alloc)
+	peerIdx := 1 - myIdx
+
+	myBal := alloc.Balance(myIdx, paymentAsset)
+	peerBal := alloc.Balance(peerIdx, paymentAsset)
+
+	if myBal.Cmp(amount) < 0 {
+		return nil, fmt.Errorf("insufficient balance: have %s, want %s", myBal.String(), amount.String())
+	}
+
+	// Set new balances in the right order
+	newMyBal := new(big.Int).Sub(myBal, amount)
+	newBals := make([]gpchannel.Bal, 2)
+	newBals[myIdx] = newMyBal
+	newBals[peerIdx] = new(big.Int).Add(peerBal, amount)
+	alloc.SetAssetBalances(paymentAsset, newBals)
+
+	return newMyBal, nil
+}
+
 // SettleChannel settles the channel on-chain.
 // This uses the properly signed state from channel updates.
 func (cc *ChannelClient) SettleChannel(ctx context.Context, ch *gpclient.Channel) error {
 	cc.logger.Info("settling channel",
-		zap.String("channel_id", fmt.Sprintf("%x", ch.ID())),
+		zap.String("channel_id", ChannelID(ch.ID()).String()),
 	)
 
 	// First, finalize the state
@@ -356,14 +629,36 @@ func (cc *ChannelClient) SettleChannel(ctx context.Context, ch *gpclient.Channel
 	cc.channelsMu.Unlock()
 
 	cc.logger.Info("channel settled successfully",
-		zap.String("channel_id", fmt.Sprintf("%x", ch.ID())),
+		zap.String("channel_id", ChannelID(ch.ID()).String()),
 	)
 
 	return nil
 }
 
-// Close closes the channel client.
+// ChannelSettledOnChain reports whether the channel's cell has been spent,
+// i.e. the channel was settled on-chain. Channels that were never funded
+// have no cell either, so only ask about channels known to be open.
+func (cc *ChannelClient) ChannelSettledOnChain(ctx context.Context, id ChannelID) (bool, error) {
+	_, _, _, _, err := cc.ckbClient.GetChannelWithID(ctx, gpchannel.ID(id))
+	if errors.Is(err, ckbclient.ErrNoChannelLiveCell) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to look up channel cell: %w", err)
+	}
+	return false, nil
+}
+
+// Close closes the channel client. Closing it again does nothing.
 func (cc *ChannelClient) Close() error {
+	if !cc.closed.CompareAndSwap(false, true) || cc.perunClient == nil {
+		return nil
+	}
 	return cc.perunClient.Close()
 }
 
+// Closed reports whether Close has been called.
+func (cc *ChannelClient) Closed() bool {
+	return cc.closed.Load()
+}
+
@@ -0,0 +1,163 @@
+package perun
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
+	gpchannel "perun.network/go-perun/channel"
+	"perun.network/perun-ckb-backend/channel/asset"
+	ckbwallet "perun.network/perun-ckb-backend/wallet"
+	"perun.network/perun-ckb-backend/wallet/address"
+)
+
+// newTestAllocation returns a two-party CKBytes allocation.
+func newTestAllocation(payer, payee int64) *gpchannel.Allocation {
+	ckbAsset := asset.NewCKBytesAsset()
+	alloc := gpchannel.NewAllocation(2, ckbAsset)
+	alloc.SetAssetBalances(ckbAsset, []gpchannel.Bal{big.NewInt(payer), big.NewInt(payee)})
+	return alloc
+}
+
+func TestSendPaymentBatch_MatchesSequentialPayments(t *testing.T) {
+	amounts := []*big.Int{big.NewInt(100), big.NewInt(200), big.NewInt(300)}
+
+	sequential := newTestAllocation(1000, 0)
+	for _, amount := range amounts {
+		if _, err := applyPayment(sequential, 0, amount); err != nil {
+			t.Fatalf("applyPayment failed: %v", err)
+		}
+	}
+
+	total, err := sumPayments(amounts)
+	if err != nil {
+		t.Fatalf("sumPayments failed: %v", err)
+	}
+	batched := newTestAllocation(1000, 0)
+	newBal, err := applyPayment(batched, 0, total)
+	if err != nil {
+		t.Fatalf("applyPayment failed: %v", err)
+	}
+
+	ckbAsset := asset.NewCKBytesAsset()
+	for idx := gpchannel.Index(0); idx < 2; idx++ {
+		want := sequential.Balance(idx, ckbAsset)
+		got := batched.Balance(idx, ckbAsset)
+		if got.Cmp(want) != 0 {
+			t.Errorf("participant %d: expected %s, got %s", idx, want, got)
+		}
+	}
+	if newBal.Cmp(big.NewInt(400)) != 0 {
+		t.Errorf("payer balance: expected 400, got %s", newBal)
+	}
+}
+
+func TestApplyPayment_PeerIndex(t *testing.T) {
+	alloc := newTestAllocation(0, 500)
+	if _, err := applyPayment(alloc, 1, big.NewInt(200)); err != nil {
+		t.Fatalf("applyPayment failed: %v", err)
+	}
+
+	ckbAsset := asset.NewCKBytesAsset()
+	if got := alloc.Balance(0, ckbAsset); got.Cmp(big.NewInt(200)) != 0 {
+		t.Errorf("payee balance: expected 200, got %s", got)
+	}
+	if got := alloc.Balance(1, ckbAsset); got.Cmp(big.NewInt(300)) != 0 {
+		t.Errorf("payer balance: expected 300, got %s", got)
+	}
+}
+
+func TestApplyPayment_InsufficientBalance(t *testing.T) {
+	alloc := newTestAllocation(100, 0)
+	if _, err := applyPayment(alloc, 0, big.NewInt(101)); err == nil {
+		t.Fatal("expected insufficient balance error")
+	}
+	if got := alloc.Balance(0, asset.NewCKBytesAsset()); got.Cmp(big.NewInt(100)) != 0 {
+		t.Errorf("allocation changed on failure: %s", got)
+	}
+}
+
+func TestSumPayments(t *testing.T) {
+	total, err := sumPayments([]*big.Int{big.NewInt(1), big.NewInt(0), new(big.Int).Lsh(big.NewInt(1), 70)})
+	if err != nil {
+		t.Fatalf("sumPayments failed: %v", err)
+	}
+	want := new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1), 70), big.NewInt(1))
+	if total.Cmp(want) != 0 {
+		t.Errorf("expected %s, got %s", want, total)
+	}
+
+	if _, err := sumPayments([]*big.Int{big.NewInt(5), big.NewInt(-1)}); err == nil {
+		t.Error("expected error for negative amount")
+	}
+	if _, err := sumPayments([]*big.Int{nil}); err == nil {
+		t.Error("expected error for nil amount")
+	}
+}
+
+func TestGetPendingPayments(t *testing.T) {
+	cc := &ChannelClient{}
+
+	first := cc.trackPayment(big.NewInt(100), 5)
+	second := cc.trackPayment(big.NewInt(200), 6)
+
+	pending, err := cc.GetPendingPayments()
+	if err != nil {
+		t.Fatalf("GetPendingPayments failed: %v", err)
+	}
+	if len(pending) != 2 {
+		t.Fatalf("Expected 2 pending payments, got %d", len(pending))
+	}
+	if pending[0].Version != 5 || pending[0].Amount.Cmp(big.NewInt(100)) != 0 {
+		t.Errorf("Unexpected first payment: version %d amount %s", pending[0].Version, pending[0].Amount)
+	}
+
+	// Returned payments are copies
+	pending[1].Amount.SetInt64(0)
+
+	cc.untrackPayment(first)
+	pending, _ = cc.GetPendingPayments()
+	if len(pending) != 1 || pending[0].Amount.Cmp(big.NewInt(200)) != 0 {
+		t.Fatalf("Expected only the 200 payment pending, got %v", pending)
+	}
+
+	cc.untrackPayment(second)
+	cc.untrackPayment(second) // already removed
+	if pending, _ := cc.GetPendingPayments(); len(pending) != 0 {
+		t.Errorf("Expected no pending payments, got %d", len(pending))
+	}
+}
+
+// newTestAccountClient returns a client for account with its address cached
+// as NewChannelClient does.
+func newTestAccountClient(account *ckbwallet.Account) *ChannelClient {
+	ckbAddress := address.AsParticipant(account.Address()).ToCKBAddress(types.NetworkTest)
+	addressStr, _ := ckbAddress.Encode()
+	return &ChannelClient{account: account, cachedAddress: addressStr, cachedLockScript: ckbAddress.Script}
+}
+
+func TestChannelClient_AddressCached(t *testing.T) {
+	account, err := ckbwallet.NewAccount()
+	if err != nil {
+		t.Fatalf("NewAccount failed: %v", err)
+	}
+	cc := newTestAccountClient(account)
+
+	addr, lockScript := cc.GetAddress(), cc.GetLockScript()
+	for i := 0; i < 1000; i++ {
+		if got := cc.GetAddress(); got != addr {
+			t.Fatalf("Call %d: GetAddress returned %s, expected %s", i, got, addr)
+		}
+		if got := cc.GetLockScript(); got != lockScript {
+			t.Fatalf("Call %d: GetLockScript returned a different script", i)
+		}
+	}
+
+	decoded, err := decodeAddressToScript(addr)
+	if err != nil {
+		t.Fatalf("Failed to decode address: %v", err)
+	}
+	if decoded.Hash() != lockScript.Hash() {
+		t.Errorf("Lock script %s doesn't match address %s", lockScript.Hash().Hex(), addr)
+	}
+}
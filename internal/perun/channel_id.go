@@ -0,0 +1,129 @@
+package perun
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+	gpchannel "perun.network/go-perun/channel"
+	gpclient "perun.network/go-perun/client"
+	gpwallet "perun.network/go-perun/wallet"
+)
+
+// ChannelID is a Perun channel ID. It converts to and from go-perun's
+// channel.ID and is written as 64 hex characters in JSON and the database.
+type ChannelID [32]byte
+
+// ParseChannelID parses a 64-character hex channel ID, with or without a
+// 0x prefix.
+func ParseChannelID(s string) (ChannelID, error) {
+	var id ChannelID
+	raw := strings.TrimPrefix(s, "0x")
+	if len(raw) != hex.EncodedLen(len(id)) {
+		return id, fmt.Errorf("invalid channel ID %q: want %d hex characters", s, hex.EncodedLen(len(id)))
+	}
+	if _, err := hex.Decode(id[:], []byte(raw)); err != nil {
+		return id, fmt.Errorf("invalid channel ID %q: %w", s, err)
+	}
+	return id, nil
+}
+
+// String returns the full 64-character hex ID.
+func (id ChannelID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// Short returns the first 16 hex characters followed by "...", for display.
+func (id ChannelID) Short() string {
+	return id.String()[:16] + "..."
+}
+
+// IsZero reports whether the ID is unset.
+func (id ChannelID) IsZero() bool {
+	return id == ChannelID{}
+}
+
+// ProposedChannelID derives the ID of the channel that answering proposal
+// with accept opens, combining both nonce shares the way go-perun does. It
+// fails if the proposal's parameters are invalid.
+func ProposedChannelID(proposal *gpclient.LedgerChannelProposalMsg, accept *gpclient.LedgerChannelProposalAccMsg) (id ChannelID, err error) {
+	hasher := sha3.New256()
+	hasher.Write(proposal.NonceShare[:])
+	hasher.Write(accept.NonceShare[:])
+	nonce := gpchannel.NonceFromBytes(hasher.Sum(nil))
+
+	params, err := gpchannel.NewParams(proposal.ChallengeDuration,
+		[]gpwallet.Address{proposal.Participant, accept.Participant},
+		proposal.App, nonce, true, false, proposal.Aux)
+	if err != nil {
+		return id, fmt.Errorf("invalid channel parameters: %w", err)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid channel parameters: %v", r)
+		}
+	}()
+	return ChannelID(gpchannel.CalcID(params)), nil
+}
+
+// MarshalJSON encodes the ID as a hex string, or an empty string if unset.
+func (id ChannelID) MarshalJSON() ([]byte, error) {
+	if id.IsZero() {
+		return []byte(`""`), nil
+	}
+	return json.Marshal(id.String())
+}
+
+// UnmarshalJSON decodes an ID from a hex string. An empty string decodes
+// as the zero ID.
+func (id *ChannelID) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s == "" {
+		*id = ChannelID{}
+		return nil
+	}
+	parsed, err := ParseChannelID(s)
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
+// Value stores the ID as hex, or an empty string if unset.
+func (id ChannelID) Value() (driver.Value, error) {
+	if id.IsZero() {
+		return "", nil
+	}
+	return id.String(), nil
+}
+
+// Scan reads an ID stored as hex. NULL and empty values scan as the zero ID.
+func (id *ChannelID) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into ChannelID", src)
+	}
+	if s == "" {
+		*id = ChannelID{}
+		return nil
+	}
+	parsed, err := ParseChannelID(s)
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
@@ -0,0 +1,155 @@
+package perun
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/sha3"
+	gpchannel "perun.network/go-perun/channel"
+	gpclient "perun.network/go-perun/client"
+	gpwallet "perun.network/go-perun/wallet"
+	ckbwallet "perun.network/perun-ckb-backend/wallet"
+)
+
+func testChannelID() ChannelID {
+	var id ChannelID
+	for i := range id {
+		id[i] = byte(i)
+	}
+	return id
+}
+
+func TestChannelID_StringAndShort(t *testing.T) {
+	id := testChannelID()
+	want := "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+	if id.String() != want {
+		t.Errorf("String: expected %s, got %s", want, id.String())
+	}
+	if id.Short() != "0001020304050607..." {
+		t.Errorf("Short: expected 0001020304050607..., got %s", id.Short())
+	}
+}
+
+func TestParseChannelID(t *testing.T) {
+	id := testChannelID()
+	for _, s := range []string{id.String(), "0x" + id.String(), strings.ToUpper(id.String())} {
+		parsed, err := ParseChannelID(s)
+		if err != nil || parsed != id {
+			t.Errorf("ParseChannelID(%q): got %s, %v", s, parsed, err)
+		}
+	}
+	for _, s := range []string{"", "abcd", id.String()[:62] + "zz", id.String() + "00"} {
+		if _, err := ParseChannelID(s); err == nil {
+			t.Errorf("ParseChannelID(%q): expected error", s)
+		}
+	}
+}
+
+func TestChannelID_JSONRoundTrip(t *testing.T) {
+	type wrapper struct {
+		ChannelID ChannelID `json:"channel_id"`
+	}
+	in := wrapper{ChannelID: testChannelID()}
+
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(data) != `{"channel_id":"`+in.ChannelID.String()+`"}` {
+		t.Errorf("unexpected JSON: %s", data)
+	}
+
+	var out wrapper
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if out != in {
+		t.Errorf("round trip: expected %s, got %s", in.ChannelID, out.ChannelID)
+	}
+
+	data, _ = json.Marshal(wrapper{})
+	if string(data) != `{"channel_id":""}` {
+		t.Errorf("unexpected JSON for zero ID: %s", data)
+	}
+	if err := json.Unmarshal(data, &out); err != nil || !out.ChannelID.IsZero() {
+		t.Errorf("expected empty string to decode as zero ID, got %s, %v", out.ChannelID, err)
+	}
+
+	if err := json.Unmarshal([]byte(`{"channel_id":"abcd"}`), &out); err == nil {
+		t.Error("expected error for short channel ID")
+	}
+	if err := json.Unmarshal([]byte(`{"channel_id":42}`), &out); err == nil {
+		t.Error("expected error for non-string channel ID")
+	}
+}
+
+func TestChannelID_ScanValue(t *testing.T) {
+	id := testChannelID()
+
+	v, err := id.Value()
+	if err != nil || v != id.String() {
+		t.Errorf("Value: expected %s, got %v (%v)", id, v, err)
+	}
+	if v, _ := (ChannelID{}).Value(); v != "" {
+		t.Errorf("Value of zero ID: expected empty, got %v", v)
+	}
+
+	var scanned ChannelID
+	for _, src := range []interface{}{id.String(), []byte(id.String())} {
+		if err := scanned.Scan(src); err != nil || scanned != id {
+			t.Errorf("Scan(%T): got %s, %v", src, scanned, err)
+		}
+	}
+	for _, src := range []interface{}{nil, ""} {
+		if err := scanned.Scan(src); err != nil || !scanned.IsZero() {
+			t.Errorf("Scan(%v): expected zero ID, got %s, %v", src, scanned, err)
+		}
+	}
+	if err := scanned.Scan("channel-1"); err == nil {
+		t.Error("expected error scanning malformed ID")
+	}
+}
+
+func TestProposedChannelID(t *testing.T) {
+	host, _ := ckbwallet.NewAccount()
+	guest, _ := ckbwallet.NewAccount()
+	proposal := &gpclient.LedgerChannelProposalMsg{
+		BaseChannelProposal: gpclient.BaseChannelProposal{
+			ChallengeDuration: ChallengeBlocks,
+			NonceShare:        gpclient.NonceShare{1},
+			App:               gpchannel.NoApp(),
+		},
+		Participant: guest.Address(),
+	}
+	accept := proposal.Accept(host.Address(), gpclient.WithNonce(gpclient.NonceShare{2}))
+
+	id, err := ProposedChannelID(proposal, accept)
+	if err != nil {
+		t.Fatalf("ProposedChannelID failed: %v", err)
+	}
+
+	// The nonce is the hash of both shares, proposer first
+	hasher := sha3.New256()
+	hasher.Write(proposal.NonceShare[:])
+	hasher.Write(accept.NonceShare[:])
+	params, err := gpchannel.NewParams(ChallengeBlocks,
+		[]gpwallet.Address{guest.Address(), host.Address()},
+		gpchannel.NoApp(), gpchannel.NonceFromBytes(hasher.Sum(nil)), true, false, gpchannel.Aux{})
+	if err != nil {
+		t.Fatalf("NewParams failed: %v", err)
+	}
+	if id != ChannelID(params.ID()) {
+		t.Errorf("Expected %s, got %s", ChannelID(params.ID()), id)
+	}
+
+	other := proposal.Accept(host.Address(), gpclient.WithNonce(gpclient.NonceShare{3}))
+	if otherID, _ := ProposedChannelID(proposal, other); otherID == id {
+		t.Error("A different nonce share should give a different channel")
+	}
+
+	proposal.ChallengeDuration = 0
+	if _, err := ProposedChannelID(proposal, accept); err == nil {
+		t.Error("Expected an error for invalid parameters")
+	}
+}
@@ -0,0 +1,120 @@
+package perun
+
+import (
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
+	"go.uber.org/zap"
+
+	gpchannel "perun.network/go-perun/channel"
+	gpclient "perun.network/go-perun/client"
+	gpwallet "perun.network/go-perun/wallet"
+	"perun.network/perun-ckb-backend/wallet/address"
+)
+
+// ChannelSummary is a snapshot of a channel tracked by the client.
+type ChannelSummary struct {
+	ID          ChannelID
+	PeerAddress string
+	MyBalance   *big.Int
+	PeerBalance *big.Int
+	Version     uint64
+	State       string
+}
+
+// ListChannels returns a summary of every channel the client opened or
+// accepted that has not been settled yet, oldest first.
+func (cc *ChannelClient) ListChannels() ([]ChannelSummary, error) {
+	cc.channelsMu.RLock()
+	active := make([]*ActiveChannel, 0, len(cc.channels))
+	for _, ac := range cc.channels {
+		active = append(active, ac)
+	}
+	cc.channelsMu.RUnlock()
+
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].CreatedAt.Before(active[j].CreatedAt)
+	})
+
+	summaries := make([]ChannelSummary, 0, len(active))
+	for _, ac := range active {
+		ch := ac.Channel
+		state := ch.State()
+		name := channelStateName(ch.Phase(), state.IsFinal, ch.IsClosed())
+		summaries = append(summaries, summarizeChannel(ch.ID(), ac.PeerAddress, state, ch.Idx(), name))
+	}
+	return summaries, nil
+}
+
+// TrackChannel registers a channel proposed by a peer and accepted by this
+// client, so it shows up in ListChannels. The channel is dropped again once
+// go-perun closes it.
+func (cc *ChannelClient) TrackChannel(ch *gpclient.Channel) {
+	idx := ch.Idx()
+	cc.channelsMu.Lock()
+	cc.channels[ch.ID()] = &ActiveChannel{
+		Channel:        ch,
+		PeerAddress:    participantAddress(ch.Params().Parts[1-idx]),
+		CreatedAt:      time.Now(),
+		InitialBalance: new(big.Int).Set(ch.State().Allocation.Balance(idx, ChannelAsset(&ch.State().Allocation))),
+	}
+	cc.channelsMu.Unlock()
+
+	go func() {
+		<-ch.Ctx().Done()
+		cc.channelsMu.Lock()
+		if ac, ok := cc.channels[ch.ID()]; ok && ac.Channel == ch {
+			delete(cc.channels, ch.ID())
+		}
+		cc.channelsMu.Unlock()
+	}()
+
+	cc.logger.Info("tracking accepted channel",
+		zap.String("channel_id", ChannelID(ch.ID()).String()),
+	)
+}
+
+// summarizeChannel builds the summary of a channel in state as seen by
+// participant myIdx.
+func summarizeChannel(id gpchannel.ID, peerAddress string, state *gpchannel.State, myIdx gpchannel.Index, stateName string) ChannelSummary {
+	paymentAsset := ChannelAsset(&state.Allocation)
+	return ChannelSummary{
+		ID:          ChannelID(id),
+		PeerAddress: peerAddress,
+		MyBalance:   new(big.Int).Set(state.Allocation.Balance(myIdx, paymentAsset)),
+		PeerBalance: new(big.Int).Set(state.Allocation.Balance(1-myIdx, paymentAsset)),
+		Version:     state.Version,
+		State:       stateName,
+	}
+}
+
+// channelStateName maps a go-perun channel phase to a ChannelState. A
+// channel whose state is final but not yet withdrawn counts as settling.
+func channelStateName(phase gpchannel.Phase, isFinal, closed bool) string {
+	switch {
+	case closed || phase == gpchannel.Withdrawn:
+		return string(ChannelStateClosed)
+	case isFinal || phase >= gpchannel.Final:
+		return string(ChannelStateSettling)
+	case phase < gpchannel.Acting:
+		return string(ChannelStateFunding)
+	default:
+		return string(ChannelStateOpen)
+	}
+}
+
+// participantAddress returns the testnet CKB address of a channel
+// participant, or its public key when it is not a CKB participant.
+func participantAddress(addr gpwallet.Address) string {
+	participant, err := address.IsParticipant(addr)
+	if err != nil {
+		return addr.String()
+	}
+	encoded, err := participant.ToCKBAddress(types.NetworkTest).Encode()
+	if err != nil {
+		return addr.String()
+	}
+	return encoded
+}
@@ -0,0 +1,77 @@
+package perun
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	gpchannel "perun.network/go-perun/channel"
+	"perun.network/perun-ckb-backend/channel/asset"
+
+	ckbwallet "perun.network/perun-ckb-backend/wallet"
+)
+
+func TestSummarizeChannel(t *testing.T) {
+	state := &gpchannel.State{
+		ID:         gpchannel.ID{7},
+		Version:    3,
+		Allocation: *newTestAllocation(400, 600),
+	}
+
+	host := summarizeChannel(state.ID, "ckt1guest", state, 1, string(ChannelStateOpen))
+	if host.ID != (ChannelID{7}) || host.PeerAddress != "ckt1guest" || host.Version != 3 || host.State != "open" {
+		t.Errorf("unexpected summary: %+v", host)
+	}
+	if host.MyBalance.Cmp(big.NewInt(600)) != 0 || host.PeerBalance.Cmp(big.NewInt(400)) != 0 {
+		t.Errorf("host balances: got %s / %s, want 600 / 400", host.MyBalance, host.PeerBalance)
+	}
+
+	// The summary must not alias the channel state
+	host.MyBalance.SetInt64(0)
+	if got := state.Allocation.Balance(1, asset.NewCKBytesAsset()); got.Cmp(big.NewInt(600)) != 0 {
+		t.Errorf("summary aliased the allocation: %s", got)
+	}
+}
+
+func TestChannelStateName(t *testing.T) {
+	tests := []struct {
+		phase   gpchannel.Phase
+		isFinal bool
+		closed  bool
+		want    ChannelState
+	}{
+		{gpchannel.Funding, false, false, ChannelStateFunding},
+		{gpchannel.Acting, false, false, ChannelStateOpen},
+		{gpchannel.Signing, false, false, ChannelStateOpen},
+		{gpchannel.Acting, true, false, ChannelStateSettling},
+		{gpchannel.Registering, true, false, ChannelStateSettling},
+		{gpchannel.Withdrawn, true, false, ChannelStateClosed},
+		{gpchannel.Acting, false, true, ChannelStateClosed},
+	}
+	for _, tt := range tests {
+		if got := channelStateName(tt.phase, tt.isFinal, tt.closed); got != string(tt.want) {
+			t.Errorf("channelStateName(%s, %v, %v) = %q, want %q", tt.phase, tt.isFinal, tt.closed, got, tt.want)
+		}
+	}
+}
+
+func TestParticipantAddress(t *testing.T) {
+	acc, err := ckbwallet.NewAccount()
+	if err != nil {
+		t.Fatalf("NewAccount failed: %v", err)
+	}
+	if got := participantAddress(acc.Address()); !strings.HasPrefix(got, "ckt1") {
+		t.Errorf("expected a testnet address, got %q", got)
+	}
+}
+
+func TestListChannels_Empty(t *testing.T) {
+	cc := &ChannelClient{channels: make(map[gpchannel.ID]*ActiveChannel)}
+	channels, err := cc.ListChannels()
+	if err != nil {
+		t.Fatalf("ListChannels failed: %v", err)
+	}
+	if len(channels) != 0 {
+		t.Errorf("expected no channels, got %d", len(channels))
+	}
+}
@@ -0,0 +1,182 @@
+package perun
+
+import (
+	"bytes"
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// poolHealthCheckTimeout bounds the balance query that checks a pooled
+// client before it is reused.
+const poolHealthCheckTimeout = 2 * time.Second
+
+// PoolStats reports how a GuestClientPool has been used.
+type PoolStats struct {
+	Size   int64 // Idle clients in the pool
+	Hits   int64 // Get calls served by a pooled client
+	Misses int64 // Get calls that created a new client
+}
+
+// GuestClientPool keeps idle guest channel clients so a later session for
+// the same wallet skips setting up go-perun and the wire bus again.
+type GuestClientPool struct {
+	newClient func(privateKey *secp256k1.PrivateKey) (*ChannelClient, error)
+
+	mu     sync.Mutex
+	pools  map[[8]byte]*sync.Pool // keyed by the first 8 bytes of the private key
+	closed bool                   // set by Close; later clients are closed, not pooled
+
+	size   atomic.Int64
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// pooledClient is an idle client and the key it was created for, which
+// tells apart keys that share a pool.
+type pooledClient struct {
+	key    []byte
+	client *ChannelClient
+}
+
+// NewGuestClientPool creates a pool that creates clients with newClient
+// when none is available for a key.
+func NewGuestClientPool(newClient func(privateKey *secp256k1.PrivateKey) (*ChannelClient, error)) *GuestClientPool {
+	return &GuestClientPool{
+		newClient: newClient,
+		pools:     make(map[[8]byte]*sync.Pool),
+	}
+}
+
+// Get returns an idle client for privateKey, or a new one if none is
+// pooled. Pooled clients that are closed or fail a balance query within
+// 2 seconds are closed and skipped.
+func (p *GuestClientPool) Get(ctx context.Context, privateKey *secp256k1.PrivateKey) (*ChannelClient, error) {
+	key := privateKey.Serialize()
+	pool := p.pool(key)
+
+	for pool != nil {
+		entry, _ := pool.Get().(*pooledClient)
+		if entry == nil {
+			break
+		}
+		runtime.SetFinalizer(entry, nil)
+		p.size.Add(-1)
+
+		if !bytes.Equal(entry.key, key) {
+			// Another key with the same prefix; leave it for its owner
+			p.put(pool, entry)
+			break
+		}
+		if p.healthy(ctx, entry.client) {
+			p.hits.Add(1)
+			return entry.client, nil
+		}
+		entry.client.Close()
+	}
+
+	p.misses.Add(1)
+	return p.newClient(privateKey)
+}
+
+// Put returns a client to the pool once its session is done with it.
+// Closed clients are dropped. Clients with channels still open, and any
+// client once the pool is closed, are closed instead of pooled.
+func (p *GuestClientPool) Put(cc *ChannelClient) {
+	if cc == nil || cc.Closed() {
+		return
+	}
+	cc.channelsMu.RLock()
+	open := len(cc.channels)
+	cc.channelsMu.RUnlock()
+	if open > 0 {
+		cc.Close()
+		return
+	}
+	key := cc.privateKey.Serialize()
+	pool := p.pool(key)
+	if pool == nil {
+		cc.Close()
+		return
+	}
+	p.put(pool, &pooledClient{key: key, client: cc})
+}
+
+// Close closes every idle client in the pool. Clients put back afterwards
+// are closed too.
+func (p *GuestClientPool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	pools := p.pools
+	p.pools = nil
+	p.mu.Unlock()
+
+	for _, pool := range pools {
+		for {
+			entry, _ := pool.Get().(*pooledClient)
+			if entry == nil {
+				break
+			}
+			runtime.SetFinalizer(entry, nil)
+			p.size.Add(-1)
+			entry.client.Close()
+		}
+	}
+}
+
+// Stats returns the pool's size and hit counts.
+func (p *GuestClientPool) Stats() PoolStats {
+	return PoolStats{
+		Size:   p.size.Load(),
+		Hits:   p.hits.Load(),
+		Misses: p.misses.Load(),
+	}
+}
+
+// put adds entry to pool. sync.Pool drops idle entries during garbage
+// collection; the finalizer closes the client when that happens.
+func (p *GuestClientPool) put(pool *sync.Pool, entry *pooledClient) {
+	p.size.Add(1)
+	runtime.SetFinalizer(entry, func(e *pooledClient) {
+		p.size.Add(-1)
+		e.client.Close()
+	})
+	pool.Put(entry)
+}
+
+// pool returns the sync.Pool for a private key, creating it if needed. It
+// returns nil once the pool is closed.
+func (p *GuestClientPool) pool(key []byte) *sync.Pool {
+	var prefix [8]byte
+	copy(prefix[:], key)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil
+	}
+	pool, ok := p.pools[prefix]
+	if !ok {
+		pool = &sync.Pool{}
+		p.pools[prefix] = pool
+	}
+	return pool
+}
+
+// healthy reports whether a pooled client is still usable.
+func (p *GuestClientPool) healthy(ctx context.Context, cc *ChannelClient) bool {
+	if cc.Closed() {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(ctx, poolHealthCheckTimeout)
+	defer cancel()
+	// A failed balance query reports zero, so a timeout shows in ctx
+	if _, err := cc.GetBalance(ctx); err != nil || ctx.Err() != nil {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,132 @@
+package perun
+
+import (
+	"context"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/indexer"
+	"go.uber.org/zap"
+	gpchannel "perun.network/go-perun/channel"
+	ckbwallet "perun.network/perun-ckb-backend/wallet"
+)
+
+// newTestClientPool returns a pool creating clients that answer balance
+// queries from a mock RPC client, and a counter of clients created.
+func newTestClientPool() (*GuestClientPool, *int) {
+	created := 0
+	pool := NewGuestClientPool(func(privateKey *secp256k1.PrivateKey) (*ChannelClient, error) {
+		created++
+		cc := newTestAccountClient(ckbwallet.NewAccountFromPrivateKey(privateKey))
+		cc.privateKey = privateKey
+		cc.rpcClient = &mockRPCClient{cells: []*indexer.LiveCell{newTestCell(500_00000000, 0)}}
+		cc.logger = zap.NewNop()
+		cc.channels = make(map[gpchannel.ID]*ActiveChannel)
+		return cc, nil
+	})
+	return pool, &created
+}
+
+func TestGuestClientPool(t *testing.T) {
+	pool, created := newTestClientPool()
+	ctx := context.Background()
+	key, _ := secp256k1.GeneratePrivateKey()
+	otherKey, _ := secp256k1.GeneratePrivateKey()
+
+	first, err := pool.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	pool.Put(first)
+	if stats := pool.Stats(); stats.Size != 1 || stats.Misses != 1 {
+		t.Errorf("Expected one pooled client after one miss, got %+v", stats)
+	}
+
+	// Another key doesn't get the pooled client
+	other, _ := pool.Get(ctx, otherKey)
+	if other == first {
+		t.Error("Expected a new client for another key")
+	}
+
+	// The same key gets it back
+	again, _ := pool.Get(ctx, key)
+	if again != first {
+		t.Error("Expected the pooled client to be reused")
+	}
+	if stats := pool.Stats(); stats.Size != 0 || stats.Hits != 1 || stats.Misses != 2 || *created != 2 {
+		t.Errorf("Unexpected stats %+v after %d clients created", stats, *created)
+	}
+
+	// Closed clients are not pooled
+	again.Close()
+	pool.Put(again)
+	if pool.Stats().Size != 0 {
+		t.Error("Expected a closed client to be discarded")
+	}
+}
+
+func TestGuestClientPool_DiscardsUnhealthyClients(t *testing.T) {
+	pool, created := newTestClientPool()
+	key, _ := secp256k1.GeneratePrivateKey()
+
+	client, _ := pool.Get(context.Background(), key)
+	pool.Put(client)
+
+	// A balance query that can't finish in time fails the health check
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	replacement, err := pool.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if replacement == client || !client.Closed() {
+		t.Error("Expected the unhealthy client to be closed and replaced")
+	}
+	if stats := pool.Stats(); stats.Hits != 0 || stats.Misses != 2 || *created != 2 {
+		t.Errorf("Unexpected stats %+v after %d clients created", stats, *created)
+	}
+}
+
+func TestGuestClientPool_ClosesDiscardedClients(t *testing.T) {
+	pool, _ := newTestClientPool()
+	ctx := context.Background()
+	key, _ := secp256k1.GeneratePrivateKey()
+
+	// A client whose channel is still open isn't reused
+	busy, _ := pool.Get(ctx, key)
+	busy.channels[gpchannel.ID{1}] = &ActiveChannel{}
+	pool.Put(busy)
+	if !busy.Closed() || pool.Stats().Size != 0 {
+		t.Error("Expected a client with an open channel to be closed, not pooled")
+	}
+
+	// Closing the pool closes idle clients and any put back later
+	idle, _ := pool.Get(ctx, key)
+	inUse, _ := pool.Get(ctx, key)
+	pool.Put(idle)
+	pool.Close()
+	if !idle.Closed() || pool.Stats().Size != 0 {
+		t.Error("Expected Close to close the idle client")
+	}
+	pool.Put(inUse)
+	if !inUse.Closed() {
+		t.Error("Expected a client put back after Close to be closed")
+	}
+}
+
+func BenchmarkGuestClientPool(b *testing.B) {
+	pool, created := newTestClientPool()
+	ctx := context.Background()
+	key, _ := secp256k1.GeneratePrivateKey()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		client, err := pool.Get(ctx, key)
+		if err != nil {
+			b.Fatalf("Get failed: %v", err)
+		}
+		pool.Put(client)
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(*created), "clients")
+}
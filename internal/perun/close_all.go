@@ -0,0 +1,76 @@
+package perun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+	gpchannel "perun.network/go-perun/channel"
+	gpclient "perun.network/go-perun/client"
+)
+
+// ErrChannelNotTracked is returned by SettleChannelByID for channels this
+// client doesn't hold.
+var ErrChannelNotTracked = errors.New("channel not tracked by this client")
+
+// closeChannels settles each channel in turn, logging failures and moving on
+// so one stuck channel doesn't keep the others open. It returns the failures
+// joined together.
+func closeChannels(ctx context.Context, ids []ChannelID, logger *zap.Logger, settle func(ctx context.Context, id ChannelID) error) error {
+	var errs []error
+	for _, id := range ids {
+		if err := settle(ctx, id); err != nil {
+			logger.Error("failed to settle channel on close-all",
+				zap.String("channel_id", id.String()),
+				zap.Error(err),
+			)
+			errs = append(errs, fmt.Errorf("channel %s: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CloseAllChannels settles every channel this client still tracks, oldest
+// first, sharing ctx between them. Used on shutdown so channels don't stay
+// open on-chain until their challenge period expires.
+func (cc *ChannelClient) CloseAllChannels(ctx context.Context) error {
+	summaries, err := cc.ListChannels()
+	if err != nil {
+		return fmt.Errorf("failed to list channels: %w", err)
+	}
+
+	ids := make([]ChannelID, 0, len(summaries))
+	for _, summary := range summaries {
+		ids = append(ids, summary.ID)
+	}
+
+	cc.logger.Info("closing all channels", zap.Int("count", len(ids)))
+	return closeChannels(ctx, ids, cc.logger, func(ctx context.Context, id ChannelID) error {
+		ch := cc.trackedChannel(id)
+		if ch == nil {
+			// Settled or closed since the listing
+			return nil
+		}
+		return cc.SettleChannel(ctx, ch)
+	})
+}
+
+// SettleChannelByID settles the tracked channel with id on-chain.
+func (cc *ChannelClient) SettleChannelByID(ctx context.Context, id ChannelID) error {
+	ch := cc.trackedChannel(id)
+	if ch == nil {
+		return ErrChannelNotTracked
+	}
+	return cc.SettleChannel(ctx, ch)
+}
+
+// trackedChannel returns the tracked channel with id, or nil.
+func (cc *ChannelClient) trackedChannel(id ChannelID) *gpclient.Channel {
+	cc.channelsMu.RLock()
+	defer cc.channelsMu.RUnlock()
+	if ac, ok := cc.channels[gpchannel.ID(id)]; ok {
+		return ac.Channel
+	}
+	return nil
+}
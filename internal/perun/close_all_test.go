@@ -0,0 +1,41 @@
+package perun
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestCloseChannels(t *testing.T) {
+	ids := []ChannelID{{1}, {2}, {3}}
+	errStuck := errors.New("peer unreachable")
+
+	var settled []ChannelID
+	err := closeChannels(context.Background(), ids, zap.NewNop(), func(ctx context.Context, id ChannelID) error {
+		settled = append(settled, id)
+		if id == ids[1] {
+			return errStuck
+		}
+		return nil
+	})
+
+	// A failure doesn't stop the remaining channels from settling
+	if len(settled) != len(ids) {
+		t.Fatalf("Expected %d settlement calls, got %d", len(ids), len(settled))
+	}
+	for i, id := range ids {
+		if settled[i] != id {
+			t.Errorf("Call %d: expected channel %s, got %s", i, id, settled[i])
+		}
+	}
+	if !errors.Is(err, errStuck) {
+		t.Errorf("Expected the failure to be returned, got %v", err)
+	}
+
+	err = closeChannels(context.Background(), ids, zap.NewNop(), func(context.Context, ChannelID) error { return nil })
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
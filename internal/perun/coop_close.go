@@ -0,0 +1,152 @@
+package perun
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nervosnetwork/ckb-sdk-go/v2/indexer"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
+	"go.uber.org/zap"
+	gpchannel "perun.network/go-perun/channel"
+	gpclient "perun.network/go-perun/client"
+)
+
+// DefaultCoopCloseTimeout bounds how long cooperative close waits for the
+// peer to sign the final state when none is configured.
+const DefaultCoopCloseTimeout = 30 * time.Second
+
+// Close paths reported by runCooperativeClose.
+const (
+	closePathCooperative = "cooperative"
+	closePathDispute     = "dispute"
+)
+
+// runCooperativeClose asks the peer to sign a final state within timeout and
+// then settles. A final, fully signed state lets the adjudicator close the
+// channel directly, skipping registration and the challenge period. If the
+// peer doesn't sign in time, settle runs on the latest non-final state, which
+// registers it on chain and withdraws once the challenge period has passed.
+// Cancellation of ctx itself is returned without falling back.
+func runCooperativeClose(ctx context.Context, timeout time.Duration, logger *zap.Logger, finalize, settle func(ctx context.Context) error) (string, error) {
+	if timeout <= 0 {
+		timeout = DefaultCoopCloseTimeout
+	}
+
+	finalizeCtx, cancel := context.WithTimeout(ctx, timeout)
+	err := finalize(finalizeCtx)
+	cancel()
+
+	path := closePathCooperative
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("failed to finalize state: %w", err)
+		}
+		logger.Warn("peer did not sign final state, falling back to dispute close",
+			zap.Duration("timeout", timeout),
+			zap.Error(err),
+		)
+		path = closePathDispute
+	}
+
+	if err := settle(ctx); err != nil {
+		return path, fmt.Errorf("failed to settle channel (%s close): %w", path, err)
+	}
+	return path, nil
+}
+
+// SubmitCooperativeClose closes the channel with a final state signed by both
+// parties, falling back to registering the latest state and withdrawing after
+// the challenge period if the peer doesn't respond within the configured
+// cooperative close timeout. It returns the hash of the transaction that
+// spent the channel cell, or a zero hash if it couldn't be looked up.
+// A fallback dispute is reported to the DisputeRecorder when it starts and
+// again with its transactions once the funds are withdrawn.
+func (cc *ChannelClient) SubmitCooperativeClose(ctx context.Context, ch *gpclient.Channel) (types.Hash, error) {
+	channelID := ChannelID(ch.ID())
+	cc.logger.Info("closing channel", zap.String("channel_id", channelID.String()))
+
+	// The channel type script identifies the close transaction afterwards
+	var pcts *types.Script
+	if _, script, _, _, err := cc.ckbClient.GetChannelWithID(ctx, ch.ID()); err != nil {
+		cc.logger.Warn("failed to look up channel cell", zap.String("channel_id", channelID.String()), zap.Error(err))
+	} else {
+		pcts = script
+	}
+
+	finalized := false
+	path, err := runCooperativeClose(ctx, cc.coopCloseTimeout, cc.logger,
+		func(ctx context.Context) error {
+			err := ch.Update(ctx, func(s *gpchannel.State) {
+				s.IsFinal = true
+			})
+			finalized = err == nil
+			return err
+		},
+		func(ctx context.Context) error {
+			if !finalized {
+				// Settling registers the state and waits out the challenge period
+				recordDispute(cc.disputes, cc.logger, channelID, "")
+			}
+			return ch.Settle(ctx, false)
+		},
+	)
+	if err != nil {
+		return types.Hash{}, err
+	}
+
+	cc.channelsMu.Lock()
+	delete(cc.channels, ch.ID())
+	cc.channelsMu.Unlock()
+
+	// The close transaction is the latest on the channel cell; after a
+	// dispute, the registration comes right before it
+	txs := cc.recentChannelTxs(ctx, pcts, 2)
+	var txHash types.Hash
+	if len(txs) > 0 {
+		txHash = txs[0]
+	}
+	if path == closePathDispute {
+		if len(txs) > 1 {
+			recordDispute(cc.disputes, cc.logger, channelID, txs[1].Hex())
+		}
+		recordResolution(cc.disputes, cc.logger, channelID, txHash.Hex())
+	}
+	cc.logger.Info("channel closed",
+		zap.String("channel_id", channelID.String()),
+		zap.String("path", path),
+		zap.String("tx_hash", txHash.Hex()),
+	)
+	return txHash, nil
+}
+
+// recentChannelTxs returns up to limit of the most recent transactions
+// involving the channel type script, newest first. After settlement the
+// first is the one that spent the channel cell. The indexer lists a
+// transaction once per matching input and output, so it is deduplicated.
+func (cc *ChannelClient) recentChannelTxs(ctx context.Context, pcts *types.Script, limit uint64) []types.Hash {
+	if pcts == nil {
+		return nil
+	}
+	txs, err := cc.rpcClient.GetTransactions(ctx, &indexer.SearchKey{
+		Script:           pcts,
+		ScriptType:       types.ScriptTypeType,
+		ScriptSearchMode: types.ScriptSearchModeExact,
+		WithData:         false,
+	}, indexer.SearchOrderDesc, 2*limit, "")
+	if err != nil || len(txs.Objects) == 0 {
+		cc.logger.Warn("failed to look up close transaction", zap.Error(err))
+		return nil
+	}
+	var hashes []types.Hash
+	for _, tx := range txs.Objects {
+		if n := len(hashes); n > 0 && hashes[n-1] == tx.TxHash {
+			continue
+		}
+		if uint64(len(hashes)) == limit {
+			break
+		}
+		hashes = append(hashes, tx.TxHash)
+	}
+	return hashes
+}
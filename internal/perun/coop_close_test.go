@@ -0,0 +1,70 @@
+package perun
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestRunCooperativeClose(t *testing.T) {
+	// blockUntilDone simulates a peer that never signs the final state
+	blockUntilDone := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	t.Run("cooperative", func(t *testing.T) {
+		settled := false
+		path, err := runCooperativeClose(context.Background(), time.Second, zap.NewNop(),
+			func(ctx context.Context) error { return nil },
+			func(ctx context.Context) error { settled = true; return nil },
+		)
+		if err != nil || path != closePathCooperative || !settled {
+			t.Fatalf("expected cooperative close, got path %q err %v settled %v", path, err, settled)
+		}
+	})
+
+	t.Run("peer timeout falls back to dispute", func(t *testing.T) {
+		settled := false
+		path, err := runCooperativeClose(context.Background(), 10*time.Millisecond, zap.NewNop(),
+			blockUntilDone,
+			func(ctx context.Context) error {
+				if ctx.Err() != nil {
+					t.Error("settle should not inherit the finalize timeout")
+				}
+				settled = true
+				return nil
+			},
+		)
+		if err != nil || path != closePathDispute || !settled {
+			t.Fatalf("expected dispute close, got path %q err %v settled %v", path, err, settled)
+		}
+	})
+
+	t.Run("settle error is returned", func(t *testing.T) {
+		settleErr := errors.New("close transaction rejected")
+		path, err := runCooperativeClose(context.Background(), time.Second, zap.NewNop(),
+			func(ctx context.Context) error { return nil },
+			func(ctx context.Context) error { return settleErr },
+		)
+		if !errors.Is(err, settleErr) || path != closePathCooperative {
+			t.Fatalf("expected settle error on cooperative path, got path %q err %v", path, err)
+		}
+	})
+
+	t.Run("cancelled context does not fall back", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		settled := false
+		_, err := runCooperativeClose(ctx, time.Second, zap.NewNop(),
+			blockUntilDone,
+			func(ctx context.Context) error { settled = true; return nil },
+		)
+		if !errors.Is(err, context.Canceled) || settled {
+			t.Fatalf("expected cancellation without settling, got err %v settled %v", err, settled)
+		}
+	})
+}
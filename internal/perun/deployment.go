@@ -1,6 +1,11 @@
 package perun
 
 import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nervosnetwork/ckb-sdk-go/v2/rpc"
 	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
 	"perun.network/perun-ckb-backend/backend"
 )
@@ -121,3 +126,99 @@ const TestnetIndexerURL = "https://testnet.ckb.dev/indexer"
 
 // ChallengeBlocks is the number of blocks for dispute period on testnet.
 const ChallengeBlocks = 9
+
+// ErrDeploymentMismatch is returned by Deployment.Verify when a contract cell
+// doesn't hold the expected script.
+var ErrDeploymentMismatch = errors.New("deployment does not match chain")
+
+// ErrUnknownNetwork is returned when no deployment is registered for a network.
+var ErrUnknownNetwork = errors.New("no Perun deployment registered for network")
+
+// deployments maps each network to its contract addresses. Mainnet has no
+// published Perun deployment yet, so only testnet is registered.
+var deployments = map[types.Network]func() backend.Deployment{
+	types.NetworkTest: GetTestnetDeployment,
+}
+
+// Deployment wraps the backend deployment with on-chain verification.
+type Deployment struct {
+	backend.Deployment
+}
+
+// DeploymentFor returns the registered deployment for network.
+func DeploymentFor(network types.Network) (*Deployment, error) {
+	get, ok := deployments[network]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownNetwork, network)
+	}
+	return &Deployment{Deployment: get()}, nil
+}
+
+// VerifiedDeployment returns the deployment for network after checking its
+// contract cells against the chain rpcClient is connected to.
+func VerifiedDeployment(ctx context.Context, rpcClient rpc.Client, network types.Network) (*Deployment, error) {
+	d, err := DeploymentFor(network)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.Verify(ctx, rpcClient); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// deploymentContract is a contract cell and the code hash scripts use to
+// reference it.
+type deploymentContract struct {
+	name     string
+	dep      types.CellDep
+	codeHash types.Hash
+	hashType types.ScriptHashType
+}
+
+// contracts lists the Perun contracts in the deployment.
+func (d *Deployment) contracts() []deploymentContract {
+	return []deploymentContract{
+		{"PCTS", d.PCTSDep, d.PCTSCodeHash, d.PCTSHashType},
+		{"PCLS", d.PCLSDep, d.PCLSCodeHash, d.PCLSHashType},
+		{"PFLS", d.PFLSDep, d.PFLSCodeHash, d.PFLSHashType},
+		{"VCTS", d.VCTSDep, d.VCTSCodeHash, d.VCTSHashType},
+		{"VCLS", d.VCLSDep, d.VCLSCodeHash, d.VCLSHashType},
+	}
+}
+
+// Verify checks that every contract cell is live and holds the script its
+// code hash expects: the cell's type script hash for "type" hash types, or
+// its data hash otherwise. A mismatch usually means the RPC endpoint is on
+// a different network or the deployment addresses are stale.
+func (d *Deployment) Verify(ctx context.Context, rpcClient rpc.Client) error {
+	for _, c := range d.contracts() {
+		outPoint := c.dep.OutPoint
+		cell, err := rpcClient.GetLiveCell(ctx, outPoint, true)
+		if err != nil {
+			return fmt.Errorf("failed to get %s contract cell: %w", c.name, err)
+		}
+		if cell == nil || cell.Status != "live" || cell.Cell == nil || cell.Cell.Output == nil {
+			status := "unknown"
+			if cell != nil {
+				status = cell.Status
+			}
+			return fmt.Errorf("%w: %s contract cell %s:%d is not live (status %s)",
+				ErrDeploymentMismatch, c.name, outPoint.TxHash, outPoint.Index, status)
+		}
+
+		var actual types.Hash
+		if c.hashType == types.HashTypeType {
+			if cell.Cell.Output.Type != nil {
+				actual = cell.Cell.Output.Type.Hash()
+			}
+		} else if cell.Cell.Data != nil {
+			actual = cell.Cell.Data.Hash
+		}
+		if actual != c.codeHash {
+			return fmt.Errorf("%w: %s contract cell %s:%d has code hash %s, expected %s",
+				ErrDeploymentMismatch, c.name, outPoint.TxHash, outPoint.Index, actual, c.codeHash)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package perun
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
+)
+
+// typeIDScript returns a distinct type script standing in for a contract's type ID.
+func typeIDScript(n byte) *types.Script {
+	return &types.Script{CodeHash: types.HexToHash("0x00000000000000000000000000000000000000000000000000545950455f4944"), HashType: types.HashTypeType, Args: []byte{n}}
+}
+
+// deploymentChain returns a mock chain holding a live cell for each contract,
+// and a deployment whose code hashes match those cells.
+func deploymentChain() (*mockRPCClient, *Deployment) {
+	d, _ := DeploymentFor(types.NetworkTest)
+	rpcClient := &mockRPCClient{liveCells: make(map[types.OutPoint]*types.CellWithStatus)}
+	hashes := []*types.Hash{&d.PCTSCodeHash, &d.PCLSCodeHash, &d.PFLSCodeHash, &d.VCTSCodeHash, &d.VCLSCodeHash}
+	for i, c := range d.contracts() {
+		script := typeIDScript(byte(i))
+		rpcClient.liveCells[*c.dep.OutPoint] = &types.CellWithStatus{
+			Status: "live",
+			Cell:   &types.CellInfo{Output: &types.CellOutput{Type: script}},
+		}
+		*hashes[i] = script.Hash()
+	}
+	return rpcClient, d
+}
+
+func TestDeployment_Verify(t *testing.T) {
+	rpcClient, d := deploymentChain()
+	if err := d.Verify(context.Background(), rpcClient); err != nil {
+		t.Fatalf("Expected matching deployment to verify, got %v", err)
+	}
+}
+
+func TestDeployment_Verify_HashMismatch(t *testing.T) {
+	rpcClient, d := deploymentChain()
+	d.PFLSCodeHash = types.HexToHash("0xdead")
+
+	err := d.Verify(context.Background(), rpcClient)
+	if !errors.Is(err, ErrDeploymentMismatch) {
+		t.Fatalf("Expected ErrDeploymentMismatch, got %v", err)
+	}
+}
+
+func TestDeployment_Verify_DataHash(t *testing.T) {
+	rpcClient, d := deploymentChain()
+	dataHash := types.HexToHash("0xc0de")
+	d.PCTSHashType = types.HashTypeData1
+	d.PCTSCodeHash = dataHash
+	rpcClient.liveCells[*d.PCTSDep.OutPoint].Cell.Data = &types.CellData{Hash: dataHash}
+
+	if err := d.Verify(context.Background(), rpcClient); err != nil {
+		t.Fatalf("Expected data hash to verify, got %v", err)
+	}
+}
+
+func TestDeployment_Verify_DeadCell(t *testing.T) {
+	rpcClient, d := deploymentChain()
+	delete(rpcClient.liveCells, *d.VCLSDep.OutPoint)
+
+	err := d.Verify(context.Background(), rpcClient)
+	if !errors.Is(err, ErrDeploymentMismatch) {
+		t.Fatalf("Expected ErrDeploymentMismatch, got %v", err)
+	}
+}
+
+func TestVerifiedDeployment(t *testing.T) {
+	// The mock chain's scripts don't hash to the real testnet type IDs
+	rpcClient, _ := deploymentChain()
+	if _, err := VerifiedDeployment(context.Background(), rpcClient, types.NetworkTest); !errors.Is(err, ErrDeploymentMismatch) {
+		t.Errorf("Expected ErrDeploymentMismatch, got %v", err)
+	}
+
+	if _, err := VerifiedDeployment(context.Background(), rpcClient, types.NetworkMain); !errors.Is(err, ErrUnknownNetwork) {
+		t.Errorf("Expected ErrUnknownNetwork, got %v", err)
+	}
+}
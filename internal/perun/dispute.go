@@ -0,0 +1,32 @@
+package perun
+
+import "go.uber.org/zap"
+
+// DisputeRecorder is told when a channel's dispute is registered on-chain
+// and when the dispute is resolved by withdrawing the channel funds. A hash
+// is empty when the transaction isn't known yet.
+type DisputeRecorder interface {
+	RecordChannelDispute(channelID ChannelID, txHash string) error
+	RecordChannelResolution(channelID ChannelID, txHash string) error
+}
+
+// recordDispute reports a dispute to recorder, if there is one. Failures
+// are logged: the dispute itself has already happened.
+func recordDispute(recorder DisputeRecorder, logger *zap.Logger, channelID ChannelID, txHash string) {
+	if recorder == nil {
+		return
+	}
+	if err := recorder.RecordChannelDispute(channelID, txHash); err != nil {
+		logger.Warn("failed to record channel dispute", zap.String("channel_id", channelID.String()), zap.Error(err))
+	}
+}
+
+// recordResolution reports a resolved dispute to recorder, if there is one.
+func recordResolution(recorder DisputeRecorder, logger *zap.Logger, channelID ChannelID, txHash string) {
+	if recorder == nil {
+		return
+	}
+	if err := recorder.RecordChannelResolution(channelID, txHash); err != nil {
+		logger.Warn("failed to record dispute resolution", zap.String("channel_id", channelID.String()), zap.Error(err))
+	}
+}
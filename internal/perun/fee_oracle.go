@@ -0,0 +1,203 @@
+// Package perun provides network fee estimation for CKB transactions.
+package perun
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nervosnetwork/ckb-sdk-go/v2/rpc"
+	"go.uber.org/zap"
+)
+
+const (
+	// FeeRateCacheTTL is how long fetched fee rate statistics are reused.
+	FeeRateCacheTTL = 60 * time.Second
+	// MinFeeRate is the CKB node default minimum fee rate (shannons per kB).
+	MinFeeRate uint64 = 1000
+	// feeRateFetchTimeout bounds a single fee rate statistics RPC call.
+	feeRateFetchTimeout = 5 * time.Second
+)
+
+// Serialized sizes, in bytes, of the parts of the secp256k1 transactions
+// built for wallets, rounded up. They price a transaction before it is
+// built, when only its input and output counts are known.
+const (
+	txBaseSize   = 200 // Version, cell dep, block header and the signature witness
+	txInputSize  = 56  // Cell input and its empty witness
+	txOutputSize = 112 // Cell output with a secp256k1 lock and its empty data
+)
+
+// EstimateTxSize returns an upper bound on the size in a block of a wallet
+// transaction with the given number of inputs and outputs.
+func EstimateTxSize(inputs, outputs int) uint64 {
+	return txBaseSize + uint64(max(inputs, 1))*txInputSize + uint64(max(outputs, 1))*txOutputSize
+}
+
+// FeeForSize returns the fee in shannons for size bytes at rate shannons per
+// kB, rounded up.
+func FeeForSize(rate, size uint64) uint64 {
+	return (rate*size + 999) / 1000
+}
+
+// txFee returns the fee for a wallet transaction with the given number of
+// inputs and outputs: oracle's medium rate applied to its estimated size,
+// or fallback when there is no oracle.
+func txFee(oracle *NetworkFeeOracle, fallback uint64, inputs, outputs int) uint64 {
+	if oracle == nil {
+		return fallback
+	}
+	return FeeForSize(oracle.RecommendFee(UrgencyMedium), EstimateTxSize(inputs, outputs))
+}
+
+// Urgency selects how aggressively a fee should be priced.
+type Urgency int
+
+const (
+	// UrgencyLow pays the median fee rate.
+	UrgencyLow Urgency = iota
+	// UrgencyMedium pays 1.5x the median fee rate.
+	UrgencyMedium
+	// UrgencyHigh pays the maximum observed fee rate.
+	UrgencyHigh
+)
+
+// String returns the urgency name.
+func (u Urgency) String() string {
+	switch u {
+	case UrgencyLow:
+		return "low"
+	case UrgencyMedium:
+		return "medium"
+	case UrgencyHigh:
+		return "high"
+	default:
+		return fmt.Sprintf("urgency(%d)", int(u))
+	}
+}
+
+// FeeRate holds fee rate statistics in shannons per kB.
+type FeeRate struct {
+	Min    uint64
+	Median uint64
+	Max    uint64
+}
+
+// FeeRateSource fetches current fee rate statistics from the network.
+type FeeRateSource interface {
+	FetchFeeRate(ctx context.Context) (*FeeRate, error)
+}
+
+// rpcFeeRateSource reads fee rate statistics from a CKB node.
+type rpcFeeRateSource struct {
+	rpcClient rpc.Client
+}
+
+// FetchFeeRate calls get_fee_rate_statistics on the node.
+// The node only reports mean and median, so Min and Max are derived from
+// those two values and clamped to MinFeeRate.
+func (s *rpcFeeRateSource) FetchFeeRate(ctx context.Context) (*FeeRate, error) {
+	stats, err := s.rpcClient.GetFeeRateStatics(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fee rate statistics: %w", err)
+	}
+	if stats == nil {
+		return nil, fmt.Errorf("node returned no fee rate statistics")
+	}
+
+	rate := &FeeRate{
+		Min:    min(stats.Mean, stats.Median),
+		Median: stats.Median,
+		Max:    max(stats.Mean, stats.Median),
+	}
+	rate.Min = max(rate.Min, MinFeeRate)
+	rate.Median = max(rate.Median, MinFeeRate)
+	rate.Max = max(rate.Max, MinFeeRate)
+	return rate, nil
+}
+
+// NetworkFeeOracle recommends transaction fees based on recent network activity.
+// Results are cached for FeeRateCacheTTL to avoid an RPC call per transaction.
+type NetworkFeeOracle struct {
+	source    FeeRateSource
+	logger    *zap.Logger
+	ttl       time.Duration
+	cached    *FeeRate
+	fetchedAt time.Time
+	mu        sync.Mutex
+}
+
+// NewNetworkFeeOracle creates a fee oracle backed by a CKB node.
+func NewNetworkFeeOracle(rpcClient rpc.Client, logger *zap.Logger) *NetworkFeeOracle {
+	return NewNetworkFeeOracleWithSource(&rpcFeeRateSource{rpcClient: rpcClient}, logger)
+}
+
+// NewNetworkFeeOracleWithSource creates a fee oracle using a custom fee rate source.
+func NewNetworkFeeOracleWithSource(source FeeRateSource, logger *zap.Logger) *NetworkFeeOracle {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &NetworkFeeOracle{
+		source: source,
+		logger: logger,
+		ttl:    FeeRateCacheTTL,
+	}
+}
+
+// FeeRate returns the current fee rate statistics, using the cache when fresh.
+// If the source fails, the last known rate is returned when available.
+func (o *NetworkFeeOracle) FeeRate(ctx context.Context) (*FeeRate, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.cached != nil && time.Since(o.fetchedAt) < o.ttl {
+		rate := *o.cached
+		return &rate, nil
+	}
+
+	fetched, err := o.source.FetchFeeRate(ctx)
+	if err != nil {
+		if o.cached != nil {
+			o.logger.Warn("using stale fee rate", zap.Error(err))
+			rate := *o.cached
+			return &rate, nil
+		}
+		return nil, err
+	}
+
+	o.cached = fetched
+	o.fetchedAt = time.Now()
+	rate := *fetched
+	return &rate, nil
+}
+
+// RecommendFee returns a fee rate in shannons per kB for the given urgency.
+// Falls back to MinFeeRate when no statistics are available. Use FeeForSize
+// to turn it into a transaction fee.
+func (o *NetworkFeeOracle) RecommendFee(urgency Urgency) uint64 {
+	ctx, cancel := context.WithTimeout(context.Background(), feeRateFetchTimeout)
+	defer cancel()
+
+	rate, err := o.FeeRate(ctx)
+	if err != nil {
+		o.logger.Warn("fee rate unavailable, using minimum", zap.Error(err))
+		return MinFeeRate
+	}
+
+	switch urgency {
+	case UrgencyLow:
+		return rate.Median
+	case UrgencyHigh:
+		return rate.Max
+	default:
+		return rate.Median * 3 / 2
+	}
+}
+
+// Invalidate drops the cached fee rate so the next call refetches it.
+func (o *NetworkFeeOracle) Invalidate() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.cached = nil
+}
@@ -0,0 +1,45 @@
+package perun
+
+import (
+	"testing"
+
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
+)
+
+func TestEstimateTxSize(t *testing.T) {
+	lock := &types.Script{HashType: types.HashTypeType, Args: make([]byte, 20)}
+	for _, shape := range []struct{ inputs, outputs int }{{1, 1}, {1, 2}, {5, 1}, {20, 1}, {1, 11}} {
+		tx := &types.Transaction{CellDeps: []*types.CellDep{getSecp256k1CellDep()}}
+		for i := 0; i < shape.inputs; i++ {
+			tx.Inputs = append(tx.Inputs, &types.CellInput{PreviousOutput: &types.OutPoint{}})
+			tx.Witnesses = append(tx.Witnesses, []byte{})
+		}
+		tx.Witnesses[0] = make([]byte, 85)
+		for i := 0; i < shape.outputs; i++ {
+			tx.Outputs = append(tx.Outputs, &types.CellOutput{Capacity: 100 * 100000000, Lock: lock})
+			tx.OutputsData = append(tx.OutputsData, []byte{})
+		}
+
+		actual := tx.SizeInBlock()
+		estimate := EstimateTxSize(shape.inputs, shape.outputs)
+		if estimate < actual || estimate > actual+actual/5 {
+			t.Errorf("%d inputs, %d outputs: estimated %d bytes, actual %d", shape.inputs, shape.outputs, estimate, actual)
+		}
+	}
+}
+
+func TestFeeForSize(t *testing.T) {
+	tests := []struct {
+		rate, size, want uint64
+	}{
+		{1000, 1000, 1000},
+		{1000, 368, 368},
+		{1500, 333, 500}, // 499.5 rounds up
+		{0, 500, 0},
+	}
+	for _, tt := range tests {
+		if got := FeeForSize(tt.rate, tt.size); got != tt.want {
+			t.Errorf("FeeForSize(%d, %d) = %d, want %d", tt.rate, tt.size, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package perun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nervosnetwork/ckb-sdk-go/v2/indexer"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types/molecule"
+
+	gpchannel "perun.network/go-perun/channel"
+	gpclient "perun.network/go-perun/client"
+	ckbclient "perun.network/perun-ckb-backend/client"
+	"perun.network/perun-ckb-backend/encoding"
+)
+
+// Funding phases reported by GetFundingStatus.
+const (
+	// FundingPhasePending means no channel cell is on-chain yet.
+	FundingPhasePending = "pending"
+	// FundingPhaseOpened means the guest's open transaction created the
+	// channel cell and the host has not funded it yet.
+	FundingPhaseOpened = "opened"
+	// FundingPhaseFunded means the channel cell is marked funded but the
+	// transaction that did so is not committed yet.
+	FundingPhaseFunded = "funded"
+	// FundingPhaseConfirmed means the funded channel cell is committed.
+	FundingPhaseConfirmed = "confirmed"
+)
+
+// FundingStatus is the on-chain funding progress of a channel. The guest
+// proposes, so its transaction opens the channel cell and the host's
+// transaction then marks it funded.
+type FundingStatus struct {
+	Phase              string
+	PCTS               *types.OutPoint // current channel cell; nil while pending
+	GuestFundingTxHash string
+	HostFundingTxHash  string
+	Confirmed          bool
+	BlockNumber        uint64 // block holding the channel cell; 0 while pending
+}
+
+// GetFundingStatus looks up the channel's cell through the indexer and
+// reports how far its funding has progressed. A channel with no cell yet is
+// pending rather than an error.
+func (cc *ChannelClient) GetFundingStatus(ctx context.Context, channelID ChannelID) (*FundingStatus, error) {
+	cell, status, err := cc.findChannelCell(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	if cell == nil {
+		return &FundingStatus{Phase: FundingPhasePending}, nil
+	}
+
+	fs := &FundingStatus{
+		Phase:       FundingPhaseOpened,
+		PCTS:        cell.OutPoint,
+		BlockNumber: cell.BlockNumber,
+	}
+
+	if !encoding.ToBool(*status.Funded()) {
+		fs.GuestFundingTxHash = cell.OutPoint.TxHash.Hex()
+		return fs, nil
+	}
+
+	tx, err := cc.rpcClient.GetTransaction(ctx, cell.OutPoint.TxHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get funding transaction: %w", err)
+	}
+	fs.Phase = FundingPhaseFunded
+	if tx.TxStatus != nil && tx.TxStatus.Status == types.TransactionStatusCommitted {
+		fs.Phase = FundingPhaseConfirmed
+		fs.Confirmed = true
+	}
+
+	// A fund transaction spends the open transaction's channel cell as its
+	// first input. Without one, the open transaction funded the channel
+	// by itself and the host put nothing on-chain.
+	openTxHash, err := cc.previousChannelCellTx(ctx, tx.Transaction, cell.Output.Type)
+	if err != nil {
+		return nil, err
+	}
+	if openTxHash == nil {
+		fs.GuestFundingTxHash = cell.OutPoint.TxHash.Hex()
+		return fs, nil
+	}
+	fs.GuestFundingTxHash = openTxHash.Hex()
+	fs.HostFundingTxHash = cell.OutPoint.TxHash.Hex()
+	return fs, nil
+}
+
+// checkNoChannelCell returns an error unless ch, a channel whose funding
+// failed, is known to have no cell on-chain, so that proposing again can't
+// lock funds twice. A nil ch never got past the proposal.
+func (cc *ChannelClient) checkNoChannelCell(ctx context.Context, ch *gpclient.Channel) error {
+	if ch == nil {
+		return nil
+	}
+	cell, _, err := cc.findChannelCell(ctx, ChannelID(ch.ID()))
+	if err != nil {
+		return fmt.Errorf("failed to check channel cell: %w", err)
+	}
+	if cell != nil {
+		return fmt.Errorf("channel %s already has a cell on-chain", ChannelID(ch.ID()))
+	}
+	return nil
+}
+
+// findChannelCell returns the live channel cell of channelID, or nil if
+// there is none yet.
+func (cc *ChannelClient) findChannelCell(ctx context.Context, channelID ChannelID) (*indexer.LiveCell, *molecule.ChannelStatus, error) {
+	_, pcts, _, status, err := cc.ckbClient.GetChannelWithID(ctx, gpchannel.ID(channelID))
+	if errors.Is(err, ckbclient.ErrNoChannelLiveCell) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get channel: %w", err)
+	}
+
+	cells, err := cc.rpcClient.GetCells(ctx, &indexer.SearchKey{
+		Script:           pcts,
+		ScriptType:       types.ScriptTypeType,
+		ScriptSearchMode: types.ScriptSearchModeExact,
+	}, indexer.SearchOrderDesc, 1, "")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to find channel cell: %w", err)
+	}
+	if len(cells.Objects) == 0 {
+		return nil, nil, nil
+	}
+	return cells.Objects[0], status, nil
+}
+
+// previousChannelCellTx returns the hash of the transaction that created
+// the channel cell tx spends as its first input, or nil if that input
+// isn't a channel cell of pcts.
+func (cc *ChannelClient) previousChannelCellTx(ctx context.Context, tx *types.Transaction, pcts *types.Script) (*types.Hash, error) {
+	if tx == nil || len(tx.Inputs) == 0 {
+		return nil, nil
+	}
+	prev := tx.Inputs[0].PreviousOutput
+
+	prevTx, err := cc.rpcClient.GetTransaction(ctx, prev.TxHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get open transaction: %w", err)
+	}
+	if prevTx.Transaction == nil || int(prev.Index) >= len(prevTx.Transaction.Outputs) {
+		return nil, nil
+	}
+	if t := prevTx.Transaction.Outputs[prev.Index].Type; t == nil || t.Hash() != pcts.Hash() {
+		return nil, nil
+	}
+	return &prev.TxHash, nil
+}
@@ -0,0 +1,106 @@
+package perun
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nervosnetwork/ckb-sdk-go/v2/indexer"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types/molecule"
+	"go.uber.org/zap"
+
+	"perun.network/perun-ckb-backend/backend"
+	ckbclient "perun.network/perun-ckb-backend/client"
+	"perun.network/perun-ckb-backend/encoding"
+	molecule2 "perun.network/perun-ckb-backend/encoding/molecule"
+)
+
+// newTestChannelCell returns a channel cell for id created by txHash.
+func newTestChannelCell(id ChannelID, pcts *types.Script, txHash types.Hash, funded bool) *indexer.LiveCell {
+	status := molecule.NewChannelStatusBuilder().
+		State(molecule.NewChannelStateBuilder().ChannelId(*molecule2.PackByte32(id)).Build()).
+		Funded(encoding.FromBool(funded)).
+		Build()
+	return &indexer.LiveCell{
+		BlockNumber: 4218305,
+		Output:      &types.CellOutput{Capacity: 1000, Type: pcts},
+		OutputData:  status.AsSlice(),
+		OutPoint:    &types.OutPoint{TxHash: txHash},
+	}
+}
+
+func TestChannelClient_GetFundingStatus(t *testing.T) {
+	deployment := backend.Deployment{
+		PCTSCodeHash: types.HexToHash("0x0a"),
+		PCTSHashType: types.HashTypeType,
+	}
+	constants := molecule.NewChannelConstantsBuilder().Build()
+	pcts := &types.Script{
+		CodeHash: deployment.PCTSCodeHash,
+		HashType: deployment.PCTSHashType,
+		Args:     constants.AsSlice(),
+	}
+	id := ChannelID{0xab}
+	openTx := types.HexToHash("0x0b")
+	fundTx := types.HexToHash("0x0c")
+
+	rpcClient := &mockRPCClient{
+		txStatus: types.TransactionStatusCommitted,
+		txs: map[types.Hash]*types.Transaction{
+			openTx: {Outputs: []*types.CellOutput{{Type: pcts}}},
+			fundTx: {Inputs: []*types.CellInput{{PreviousOutput: &types.OutPoint{TxHash: openTx}}}},
+		},
+	}
+	ckbClient, err := ckbclient.NewClient(rpcClient, nil, deployment)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	cc := &ChannelClient{rpcClient: rpcClient, ckbClient: ckbClient, deployment: deployment, logger: zap.NewNop()}
+	ctx := context.Background()
+
+	// No channel cell yet, only another channel's
+	rpcClient.cells = []*indexer.LiveCell{newTestChannelCell(ChannelID{0xcd}, pcts, openTx, false)}
+	fs, err := cc.GetFundingStatus(ctx, id)
+	if err != nil {
+		t.Fatalf("GetFundingStatus failed: %v", err)
+	}
+	if fs.Phase != FundingPhasePending || fs.PCTS != nil {
+		t.Errorf("Expected pending status, got %+v", fs)
+	}
+
+	// Opened by the guest, not funded by the host yet
+	rpcClient.cells = []*indexer.LiveCell{newTestChannelCell(id, pcts, openTx, false)}
+	fs, err = cc.GetFundingStatus(ctx, id)
+	if err != nil {
+		t.Fatalf("GetFundingStatus failed: %v", err)
+	}
+	if fs.Phase != FundingPhaseOpened || fs.Confirmed || fs.GuestFundingTxHash != openTx.Hex() || fs.HostFundingTxHash != "" {
+		t.Errorf("Expected opened status, got %+v", fs)
+	}
+	if fs.BlockNumber != 4218305 {
+		t.Errorf("Expected block 4218305, got %d", fs.BlockNumber)
+	}
+
+	// Funded by the host and committed
+	rpcClient.cells = []*indexer.LiveCell{newTestChannelCell(id, pcts, fundTx, true)}
+	fs, err = cc.GetFundingStatus(ctx, id)
+	if err != nil {
+		t.Fatalf("GetFundingStatus failed: %v", err)
+	}
+	if fs.Phase != FundingPhaseConfirmed || !fs.Confirmed {
+		t.Errorf("Expected confirmed status, got %+v", fs)
+	}
+	if fs.GuestFundingTxHash != openTx.Hex() || fs.HostFundingTxHash != fundTx.Hex() {
+		t.Errorf("Expected guest tx %s and host tx %s, got %+v", openTx.Hex(), fundTx.Hex(), fs)
+	}
+
+	// Funded but not committed yet
+	rpcClient.txStatus = types.TransactionStatusPending
+	fs, err = cc.GetFundingStatus(ctx, id)
+	if err != nil {
+		t.Fatalf("GetFundingStatus failed: %v", err)
+	}
+	if fs.Phase != FundingPhaseFunded || fs.Confirmed {
+		t.Errorf("Expected funded status, got %+v", fs)
+	}
+}
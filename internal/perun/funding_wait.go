@@ -0,0 +1,151 @@
+package perun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nervosnetwork/ckb-sdk-go/v2/indexer"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
+	"go.uber.org/zap"
+	gpchannel "perun.network/go-perun/channel"
+
+	"perun.network/perun-ckb-backend/encoding"
+)
+
+const (
+	// fundingPollInterval is the delay between funding checks.
+	fundingPollInterval = 3 * time.Second
+	// fundingBackoffAfter is the number of consecutive misses before the
+	// poll interval starts doubling.
+	fundingBackoffAfter = 5
+	// fundingMaxPollInterval caps the backed-off poll interval.
+	fundingMaxPollInterval = 30 * time.Second
+)
+
+// ErrFundingTimeout is returned when a channel isn't funded on-chain in time.
+var ErrFundingTimeout = errors.New("timed out waiting for channel funding")
+
+// WaitForFunding blocks until the channel's PCTS cell is marked funded and
+// the transaction that created it is committed, or timeout elapses.
+func (cc *ChannelClient) WaitForFunding(ctx context.Context, channelID ChannelID, timeout time.Duration) error {
+	cc.logger.Info("waiting for channel funding",
+		zap.String("channel_id", channelID.String()),
+		zap.Duration("timeout", timeout),
+	)
+	return pollFunding(ctx, timeout, fundingPollInterval, func(ctx context.Context) (bool, error) {
+		ok, err := cc.fundingConfirmed(ctx, gpchannel.ID(channelID))
+		if err != nil {
+			cc.logger.Debug("channel funding not visible yet", zap.Error(err))
+		}
+		return ok, err
+	})
+}
+
+// fundingConfirmed reports whether the channel cell is funded and committed.
+func (cc *ChannelClient) fundingConfirmed(ctx context.Context, channelID gpchannel.ID) (bool, error) {
+	_, pcts, _, status, err := cc.ckbClient.GetChannelWithID(ctx, channelID)
+	if err != nil {
+		return false, err
+	}
+	if !encoding.ToBool(*status.Funded()) {
+		return false, nil
+	}
+
+	cells, err := cc.rpcClient.GetCells(ctx, &indexer.SearchKey{
+		Script:           pcts,
+		ScriptType:       types.ScriptTypeType,
+		ScriptSearchMode: types.ScriptSearchModeExact,
+	}, indexer.SearchOrderDesc, 1, "")
+	if err != nil {
+		return false, fmt.Errorf("failed to find channel cell: %w", err)
+	}
+	if len(cells.Objects) == 0 {
+		return false, nil
+	}
+
+	tx, err := cc.rpcClient.GetTransaction(ctx, cells.Objects[0].OutPoint.TxHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to get funding transaction: %w", err)
+	}
+	return tx.TxStatus != nil && tx.TxStatus.Status == types.TransactionStatusCommitted, nil
+}
+
+// pollFunding calls check until it reports true or timeout elapses. Check
+// errors count as misses, since the channel cell may not be indexed yet.
+func pollFunding(ctx context.Context, timeout, interval time.Duration, check func(ctx context.Context) (bool, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for misses := 1; ; misses++ {
+		if ok, _ := check(ctx); ok {
+			return nil
+		}
+		interval = nextFundingInterval(interval, misses)
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return ErrFundingTimeout
+			}
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// nextFundingInterval doubles the poll interval once more than
+// fundingBackoffAfter consecutive checks have missed, up to fundingMaxPollInterval.
+func nextFundingInterval(interval time.Duration, misses int) time.Duration {
+	if misses <= fundingBackoffAfter {
+		return interval
+	}
+	interval *= 2
+	if interval > fundingMaxPollInterval {
+		interval = fundingMaxPollInterval
+	}
+	return interval
+}
+
+// DefaultFundingTimeout bounds a channel proposal when none is configured.
+const DefaultFundingTimeout = 5 * time.Minute
+
+// runWithFundingTimeout runs propose with ctx limited to timeout. When the
+// attempt times out and retry is set, it runs once more with double the
+// timeout, unless canRetry, if set, reports that the first attempt may
+// already have locked funds. Running out of time returns an error wrapping
+// ErrFundingTimeout; cancellation of ctx itself is returned as is.
+func runWithFundingTimeout(ctx context.Context, timeout time.Duration, retry bool, logger *zap.Logger, propose func(ctx context.Context) error, canRetry func(ctx context.Context) error) error {
+	if timeout <= 0 {
+		timeout = DefaultFundingTimeout
+	}
+
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := propose(attemptCtx)
+		timedOut := errors.Is(attemptCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil
+		cancel()
+
+		if err == nil {
+			return nil
+		}
+		if !timedOut {
+			return err
+		}
+		if !retry || attempt > 1 {
+			return fmt.Errorf("%w after %s: %v", ErrFundingTimeout, timeout, err)
+		}
+		if canRetry != nil {
+			if retryErr := canRetry(ctx); retryErr != nil {
+				return fmt.Errorf("%w after %s: %v; not retrying: %v", ErrFundingTimeout, timeout, err, retryErr)
+			}
+		}
+
+		timeout *= 2
+		logger.Warn("channel funding timed out, retrying with longer timeout",
+			zap.Duration("timeout", timeout),
+			zap.Error(err),
+		)
+	}
+}
@@ -0,0 +1,167 @@
+package perun
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestPollFunding_Success(t *testing.T) {
+	calls := 0
+	err := pollFunding(context.Background(), time.Second, time.Millisecond, func(ctx context.Context) (bool, error) {
+		calls++
+		if calls < 3 {
+			return false, errors.New("no channel live cell")
+		}
+		return true, nil
+	})
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 checks, got %d", calls)
+	}
+}
+
+func TestPollFunding_Timeout(t *testing.T) {
+	calls := 0
+	start := time.Now()
+	err := pollFunding(context.Background(), 50*time.Millisecond, time.Millisecond, func(ctx context.Context) (bool, error) {
+		calls++
+		return false, nil
+	})
+	if !errors.Is(err, ErrFundingTimeout) {
+		t.Fatalf("expected ErrFundingTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("poll loop ran past its timeout: %v", elapsed)
+	}
+	if calls < 2 {
+		t.Errorf("expected repeated checks, got %d", calls)
+	}
+}
+
+func TestPollFunding_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := pollFunding(ctx, time.Second, time.Millisecond, func(ctx context.Context) (bool, error) {
+		return false, nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestNextFundingInterval(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		misses   int
+		want     time.Duration
+	}{
+		{3 * time.Second, 1, 3 * time.Second},
+		{3 * time.Second, fundingBackoffAfter, 3 * time.Second},
+		{3 * time.Second, fundingBackoffAfter + 1, 6 * time.Second},
+		{24 * time.Second, fundingBackoffAfter + 4, fundingMaxPollInterval},
+		{fundingMaxPollInterval, fundingBackoffAfter + 9, fundingMaxPollInterval},
+	}
+
+	for _, tt := range tests {
+		if got := nextFundingInterval(tt.interval, tt.misses); got != tt.want {
+			t.Errorf("nextFundingInterval(%v, %d): expected %v, got %v", tt.interval, tt.misses, tt.want, got)
+		}
+	}
+}
+
+func TestRunWithFundingTimeout(t *testing.T) {
+	// blockUntilDone simulates a proposal whose funding never completes
+	blockUntilDone := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	t.Run("success", func(t *testing.T) {
+		err := runWithFundingTimeout(context.Background(), time.Second, false, zap.NewNop(), func(ctx context.Context) error {
+			return nil
+		}, nil)
+		if err != nil {
+			t.Fatalf("expected success, got %v", err)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		err := runWithFundingTimeout(context.Background(), 10*time.Millisecond, false, zap.NewNop(), blockUntilDone, nil)
+		if !errors.Is(err, ErrFundingTimeout) {
+			t.Fatalf("expected ErrFundingTimeout, got %v", err)
+		}
+	})
+
+	t.Run("retry doubles timeout", func(t *testing.T) {
+		var deadlines []time.Duration
+		err := runWithFundingTimeout(context.Background(), 20*time.Millisecond, true, zap.NewNop(), func(ctx context.Context) error {
+			deadline, _ := ctx.Deadline()
+			deadlines = append(deadlines, time.Until(deadline))
+			return blockUntilDone(ctx)
+		}, nil)
+		if !errors.Is(err, ErrFundingTimeout) {
+			t.Fatalf("expected ErrFundingTimeout, got %v", err)
+		}
+		if len(deadlines) != 2 {
+			t.Fatalf("expected 2 attempts, got %d", len(deadlines))
+		}
+		if deadlines[1] <= deadlines[0] {
+			t.Errorf("retry should get a longer timeout: %v then %v", deadlines[0], deadlines[1])
+		}
+	})
+
+	t.Run("retry succeeds", func(t *testing.T) {
+		attempts := 0
+		err := runWithFundingTimeout(context.Background(), 10*time.Millisecond, true, zap.NewNop(), func(ctx context.Context) error {
+			attempts++
+			if attempts == 1 {
+				return blockUntilDone(ctx)
+			}
+			return nil
+		}, nil)
+		if err != nil || attempts != 2 {
+			t.Fatalf("expected success on retry, got %v after %d attempts", err, attempts)
+		}
+	})
+
+	t.Run("landed funding is not retried", func(t *testing.T) {
+		attempts := 0
+		err := runWithFundingTimeout(context.Background(), 10*time.Millisecond, true, zap.NewNop(), func(ctx context.Context) error {
+			attempts++
+			return blockUntilDone(ctx)
+		}, func(ctx context.Context) error {
+			return errors.New("channel cell on-chain")
+		})
+		if !errors.Is(err, ErrFundingTimeout) || attempts != 1 {
+			t.Fatalf("expected single timed-out attempt, got %v after %d attempts", err, attempts)
+		}
+	})
+
+	t.Run("other errors are not retried", func(t *testing.T) {
+		attempts := 0
+		proposeErr := errors.New("peer rejected proposal")
+		err := runWithFundingTimeout(context.Background(), time.Second, true, zap.NewNop(), func(ctx context.Context) error {
+			attempts++
+			return proposeErr
+		}, nil)
+		if !errors.Is(err, proposeErr) || errors.Is(err, ErrFundingTimeout) || attempts != 1 {
+			t.Fatalf("expected single non-timeout failure, got %v after %d attempts", err, attempts)
+		}
+	})
+
+	t.Run("parent cancellation", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := runWithFundingTimeout(ctx, time.Second, true, zap.NewNop(), blockUntilDone, nil)
+		if errors.Is(err, ErrFundingTimeout) || !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	})
+}
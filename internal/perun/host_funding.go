@@ -0,0 +1,96 @@
+package perun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"go.uber.org/zap"
+
+	gpclient "perun.network/go-perun/client"
+	gpwallet "perun.network/go-perun/wallet"
+	gpwire "perun.network/go-perun/wire"
+)
+
+// hostInsufficientFundsReason prefixes proposal rejections sent by a host
+// that cannot cover the requested funding.
+const hostInsufficientFundsReason = "host insufficient funds"
+
+// ErrHostInsufficientFunds is returned when the host rejects a channel
+// proposal because it cannot cover the requested host funding.
+type ErrHostInsufficientFunds struct {
+	Requested *big.Int // Host funding requested in the proposal (shannons)
+	Available *big.Int // Host balance at the time of the proposal (shannons)
+}
+
+// Error implements the error interface.
+func (e *ErrHostInsufficientFunds) Error() string {
+	return fmt.Sprintf("%s: requested %s, available %s shannons", hostInsufficientFundsReason, e.Requested, e.Available)
+}
+
+// HostInsufficientFundsReason returns the rejection reason a host sends when
+// it cannot fund a proposal. The guest side parses it back into
+// ErrHostInsufficientFunds.
+func HostInsufficientFundsReason(requested, available *big.Int) string {
+	return fmt.Sprintf("%s: requested=%s available=%s", hostInsufficientFundsReason, requested, available)
+}
+
+// asHostInsufficientFunds extracts an insufficient-funds rejection from a
+// proposal error.
+func asHostInsufficientFunds(err error) (*ErrHostInsufficientFunds, bool) {
+	var rejected gpclient.PeerRejectedError
+	if !errors.As(err, &rejected) || !strings.HasPrefix(rejected.Reason, hostInsufficientFundsReason) {
+		return nil, false
+	}
+
+	result := &ErrHostInsufficientFunds{Requested: big.NewInt(0), Available: big.NewInt(0)}
+	var requested, available string
+	detail := strings.TrimPrefix(rejected.Reason, hostInsufficientFundsReason+":")
+	if _, err := fmt.Sscanf(detail, " requested=%s available=%s", &requested, &available); err == nil {
+		result.Requested.SetString(requested, 10)
+		result.Available.SetString(available, 10)
+	}
+	return result, true
+}
+
+// ProposeChannelWithFallback proposes a channel asking the host for
+// requestedHostFunding. If the host rejects for insufficient funds, the
+// proposal is retried once with minHostFunding.
+func (cc *ChannelClient) ProposeChannelWithFallback(
+	ctx context.Context,
+	hostWireAddr gpwire.Address,
+	hostAcct gpwallet.Address,
+	guestFunding *big.Int,
+	requestedHostFunding *big.Int,
+	minHostFunding *big.Int,
+) (*gpclient.Channel, error) {
+	ch, err := cc.ProposeChannel(ctx, hostWireAddr, hostAcct, guestFunding, requestedHostFunding)
+	if err == nil {
+		return ch, nil
+	}
+
+	insufficient, ok := asHostInsufficientFunds(err)
+	if !ok {
+		return nil, err
+	}
+	if minHostFunding == nil || minHostFunding.Cmp(requestedHostFunding) >= 0 {
+		return nil, insufficient
+	}
+
+	cc.logger.Warn("host cannot cover requested funding, retrying with minimum",
+		zap.String("requested_host_funding", requestedHostFunding.String()),
+		zap.String("available_host_balance", insufficient.Available.String()),
+		zap.String("min_host_funding", minHostFunding.String()),
+	)
+
+	ch, err = cc.ProposeChannel(ctx, hostWireAddr, hostAcct, guestFunding, minHostFunding)
+	if err != nil {
+		if insufficient, ok := asHostInsufficientFunds(err); ok {
+			return nil, insufficient
+		}
+		return nil, err
+	}
+	return ch, nil
+}
@@ -0,0 +1,41 @@
+package perun
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	gpclient "perun.network/go-perun/client"
+)
+
+func TestAsHostInsufficientFunds(t *testing.T) {
+	reason := HostInsufficientFundsReason(big.NewInt(10000000000), big.NewInt(7000000000))
+	err := fmt.Errorf("failed to propose channel: %w", gpclient.PeerRejectedError{
+		ItemType: "channel proposal",
+		Reason:   reason,
+	})
+
+	insufficient, ok := asHostInsufficientFunds(err)
+	if !ok {
+		t.Fatal("Expected insufficient funds rejection to be detected")
+	}
+	if insufficient.Requested.Cmp(big.NewInt(10000000000)) != 0 {
+		t.Errorf("Requested: expected 10000000000, got %s", insufficient.Requested)
+	}
+	if insufficient.Available.Cmp(big.NewInt(7000000000)) != 0 {
+		t.Errorf("Available: expected 7000000000, got %s", insufficient.Available)
+	}
+}
+
+func TestAsHostInsufficientFunds_OtherErrors(t *testing.T) {
+	tests := []error{
+		errors.New("network down"),
+		gpclient.PeerRejectedError{ItemType: "channel proposal", Reason: "not accepting channels"},
+	}
+	for _, err := range tests {
+		if _, ok := asHostInsufficientFunds(err); ok {
+			t.Errorf("Unexpected insufficient funds match for %v", err)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package perun
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// MockChannelClient stands in for channel clients in dry-run mode. Channels
+// open at once and payments and settlements only change its in-memory
+// ledger; nothing is sent on-chain. It marshals to JSON for inspection.
+type MockChannelClient struct {
+	mu       sync.Mutex
+	channels map[ChannelID]*MockChannel
+}
+
+// MockChannel is the ledger entry of a dry-run channel. Balances are in
+// shannons.
+type MockChannel struct {
+	ID           ChannelID  `json:"channel_id"`
+	GuestBalance *big.Int   `json:"guest_balance"`
+	HostBalance  *big.Int   `json:"host_balance"`
+	Version      uint64     `json:"version"`
+	Payments     int        `json:"payments"`
+	OpenedAt     time.Time  `json:"opened_at"`
+	SettledAt    *time.Time `json:"settled_at,omitempty"`
+}
+
+// NewMockChannelClient creates an empty dry-run ledger.
+func NewMockChannelClient() *MockChannelClient {
+	return &MockChannelClient{channels: make(map[ChannelID]*MockChannel)}
+}
+
+// OpenChannel "opens" a channel with the given funding and returns its
+// random ID.
+func (m *MockChannelClient) OpenChannel(guestFunding, hostFunding *big.Int) ChannelID {
+	var id ChannelID
+	rand.Read(id[:])
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.channels[id] = &MockChannel{
+		ID:           id,
+		GuestBalance: new(big.Int).Set(guestFunding),
+		HostBalance:  new(big.Int).Set(hostFunding),
+		OpenedAt:     time.Now(),
+	}
+	return id
+}
+
+// SendPayment moves amount from the guest to the host in one update.
+func (m *MockChannelClient) SendPayment(id ChannelID, amount *big.Int) error {
+	return m.SendPaymentBatch(id, []*big.Int{amount})
+}
+
+// SendPaymentBatch moves the sum of payments from the guest to the host in
+// one update. Returns ErrInsufficientBalance if the guest can't cover it.
+func (m *MockChannelClient) SendPaymentBatch(id ChannelID, payments []*big.Int) error {
+	total := new(big.Int)
+	for _, p := range payments {
+		total.Add(total, p)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ch, err := m.openChannel(id)
+	if err != nil {
+		return err
+	}
+	if ch.GuestBalance.Cmp(total) < 0 {
+		return fmt.Errorf("%w: payment of %s exceeds guest balance %s", ErrInsufficientBalance, total, ch.GuestBalance)
+	}
+	ch.GuestBalance.Sub(ch.GuestBalance, total)
+	ch.HostBalance.Add(ch.HostBalance, total)
+	ch.Version++
+	ch.Payments += len(payments)
+	return nil
+}
+
+// SettleChannel records the channel as settled at its current balances.
+func (m *MockChannelClient) SettleChannel(id ChannelID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ch, err := m.openChannel(id)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	ch.SettledAt = &now
+	return nil
+}
+
+// Settled reports whether a channel was settled.
+func (m *MockChannelClient) Settled(id ChannelID) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ch, ok := m.channels[id]
+	return ok && ch.SettledAt != nil
+}
+
+// Channel returns a copy of a channel's ledger entry.
+func (m *MockChannelClient) Channel(id ChannelID) (MockChannel, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ch, ok := m.channels[id]
+	if !ok {
+		return MockChannel{}, false
+	}
+	return ch.copy(), true
+}
+
+// MarshalJSON encodes every channel in the ledger, in no particular order.
+func (m *MockChannelClient) MarshalJSON() ([]byte, error) {
+	m.mu.Lock()
+	channels := make([]MockChannel, 0, len(m.channels))
+	for _, ch := range m.channels {
+		channels = append(channels, ch.copy())
+	}
+	m.mu.Unlock()
+	return json.Marshal(struct {
+		Channels []MockChannel `json:"channels"`
+	}{channels})
+}
+
+// openChannel returns a channel that exists and isn't settled. Callers
+// hold m.mu.
+func (m *MockChannelClient) openChannel(id ChannelID) (*MockChannel, error) {
+	ch, ok := m.channels[id]
+	if !ok {
+		return nil, fmt.Errorf("channel %s not found", id.Short())
+	}
+	if ch.SettledAt != nil {
+		return nil, fmt.Errorf("channel %s already settled", id.Short())
+	}
+	return ch, nil
+}
+
+// copy returns ch with its own balances.
+func (ch *MockChannel) copy() MockChannel {
+	c := *ch
+	c.GuestBalance = new(big.Int).Set(ch.GuestBalance)
+	c.HostBalance = new(big.Int).Set(ch.HostBalance)
+	return c
+}
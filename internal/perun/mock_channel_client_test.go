@@ -0,0 +1,71 @@
+package perun
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestMockChannelClient(t *testing.T) {
+	m := NewMockChannelClient()
+	id := m.OpenChannel(big.NewInt(1000), big.NewInt(100))
+
+	if err := m.SendPayment(id, big.NewInt(200)); err != nil {
+		t.Fatalf("SendPayment failed: %v", err)
+	}
+	if err := m.SendPaymentBatch(id, []*big.Int{big.NewInt(100), big.NewInt(100)}); err != nil {
+		t.Fatalf("SendPaymentBatch failed: %v", err)
+	}
+	if err := m.SendPayment(id, big.NewInt(601)); !errors.Is(err, ErrInsufficientBalance) {
+		t.Errorf("Expected ErrInsufficientBalance for overpaying, got %v", err)
+	}
+
+	ch, ok := m.Channel(id)
+	if !ok {
+		t.Fatal("Expected the channel in the ledger")
+	}
+	if ch.GuestBalance.Int64() != 600 || ch.HostBalance.Int64() != 500 || ch.Version != 2 || ch.Payments != 3 {
+		t.Errorf("Unexpected ledger entry %+v", ch)
+	}
+
+	// Copies don't alias the ledger
+	ch.GuestBalance.SetInt64(0)
+	if again, _ := m.Channel(id); again.GuestBalance.Int64() != 600 {
+		t.Error("Expected Channel to return a copy")
+	}
+
+	if m.Settled(id) {
+		t.Error("Expected an open channel not to be settled")
+	}
+	if err := m.SettleChannel(id); err != nil {
+		t.Fatalf("SettleChannel failed: %v", err)
+	}
+	if !m.Settled(id) {
+		t.Error("Expected the channel to be settled")
+	}
+	if err := m.SendPayment(id, big.NewInt(1)); err == nil {
+		t.Error("Expected error paying on a settled channel")
+	}
+	if err := m.SettleChannel(ChannelID{}); err == nil {
+		t.Error("Expected error settling an unknown channel")
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var decoded struct {
+		Channels []struct {
+			ChannelID    string `json:"channel_id"`
+			GuestBalance int64  `json:"guest_balance"`
+			SettledAt    string `json:"settled_at"`
+		} `json:"channels"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if len(decoded.Channels) != 1 || decoded.Channels[0].GuestBalance != 600 || decoded.Channels[0].SettledAt == "" {
+		t.Errorf("Unexpected JSON %s", data)
+	}
+}
@@ -0,0 +1,138 @@
+package perun
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/nervosnetwork/ckb-sdk-go/v2/indexer"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/rpc"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
+)
+
+// mockRPCClient serves canned indexer and chain data. Unimplemented methods
+// panic through the embedded nil rpc.Client.
+type mockRPCClient struct {
+	rpc.Client
+	cells []*indexer.LiveCell
+
+	// Chain state for transaction confirmation tests. The tip advances by
+	// one block on every GetTipBlockNumber call.
+	txStatus    types.TransactionStatus
+	txBlock     uint64
+	tip         uint64
+	tipCalls    int
+	sentTxCount int
+
+	// Transaction history for sender lookups. GetTransaction returns the
+	// body from txs when present.
+	walletTxs   []types.Hash
+	txs         map[types.Hash]*types.Transaction
+	getTxsCalls int
+
+	// Contract cells for deployment verification, by out point.
+	liveCells map[types.OutPoint]*types.CellWithStatus
+
+	// Result of EstimateCycles.
+	cycles    uint64
+	cyclesErr error
+
+	// GetCellsCapacity sums the configured cells and counts its calls.
+	capacityCalls int
+}
+
+// SendTransaction accepts any transaction, records it for GetTransaction
+// and returns its hash.
+func (m *mockRPCClient) SendTransaction(ctx context.Context, tx *types.Transaction) (*types.Hash, error) {
+	m.sentTxCount++
+	hash := tx.ComputeHash()
+	if m.txs == nil {
+		m.txs = make(map[types.Hash]*types.Transaction)
+	}
+	m.txs[hash] = tx
+	return &hash, nil
+}
+
+// GetTransaction reports the configured status for any hash.
+func (m *mockRPCClient) GetTransaction(ctx context.Context, hash types.Hash) (*types.TransactionWithStatus, error) {
+	blockHash := types.HexToHash("0x02")
+	return &types.TransactionWithStatus{
+		Transaction: m.txs[hash],
+		TxStatus:    &types.TxStatus{Status: m.txStatus, BlockHash: &blockHash},
+	}, nil
+}
+
+// GetTransactions lists the configured wallet transactions.
+func (m *mockRPCClient) GetTransactions(ctx context.Context, searchKey *indexer.SearchKey, order indexer.SearchOrder, limit uint64, afterCursor string) (*indexer.TxsWithCell, error) {
+	m.getTxsCalls++
+	result := &indexer.TxsWithCell{}
+	for _, hash := range m.walletTxs {
+		result.Objects = append(result.Objects, &indexer.TxWithCell{TxHash: hash})
+	}
+	return result, nil
+}
+
+// GetHeader returns a header at the configured transaction block.
+func (m *mockRPCClient) GetHeader(ctx context.Context, hash types.Hash) (*types.Header, error) {
+	return &types.Header{Hash: hash, Number: m.txBlock}, nil
+}
+
+// GetTipBlockNumber returns the tip and advances it by one block.
+func (m *mockRPCClient) GetTipBlockNumber(ctx context.Context) (uint64, error) {
+	m.tipCalls++
+	tip := m.tip
+	m.tip++
+	return tip, nil
+}
+
+// GetLiveCell returns the configured contract cell, or an unknown cell.
+func (m *mockRPCClient) GetLiveCell(ctx context.Context, outPoint *types.OutPoint, withData bool) (*types.CellWithStatus, error) {
+	if cell, ok := m.liveCells[*outPoint]; ok {
+		return cell, nil
+	}
+	return &types.CellWithStatus{Status: "unknown"}, nil
+}
+
+// EstimateCycles returns the configured cycles or error.
+func (m *mockRPCClient) EstimateCycles(ctx context.Context, tx *types.Transaction) (*types.EstimateCycles, error) {
+	if m.cyclesErr != nil {
+		return nil, m.cyclesErr
+	}
+	return &types.EstimateCycles{Cycles: m.cycles}, nil
+}
+
+// GetCellsCapacity returns the total capacity of the configured cells.
+func (m *mockRPCClient) GetCellsCapacity(ctx context.Context, searchKey *indexer.SearchKey) (*indexer.Capacity, error) {
+	m.capacityCalls++
+	var total uint64
+	for _, cell := range m.cells {
+		total += cell.Output.Capacity
+	}
+	return &indexer.Capacity{Capacity: total}, nil
+}
+
+// GetCells pages through the configured cells using the index as cursor.
+func (m *mockRPCClient) GetCells(ctx context.Context, searchKey *indexer.SearchKey, order indexer.SearchOrder, limit uint64, afterCursor string) (*indexer.LiveCells, error) {
+	start := 0
+	if afterCursor != "" {
+		start, _ = strconv.Atoi(afterCursor)
+	}
+	end := start + int(limit)
+	if end > len(m.cells) {
+		end = len(m.cells)
+	}
+	return &indexer.LiveCells{
+		Objects:    m.cells[start:end],
+		LastCursor: strconv.Itoa(end),
+	}, nil
+}
+
+// newTestCell creates a live cell with the given capacity in shannons.
+func newTestCell(capacity uint64, index uint32) *indexer.LiveCell {
+	return &indexer.LiveCell{
+		Output: &types.CellOutput{Capacity: capacity},
+		OutPoint: &types.OutPoint{
+			TxHash: types.HexToHash("0x01"),
+			Index:  index,
+		},
+	}
+}
@@ -0,0 +1,143 @@
+package perun
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+
+	gpchannel "perun.network/go-perun/channel"
+	gpclient "perun.network/go-perun/client"
+	gpwallet "perun.network/go-perun/wallet"
+
+	ckbwallet "perun.network/perun-ckb-backend/wallet"
+)
+
+// ErrInvalidPaymentProof is returned when a payment proof fails verification.
+var ErrInvalidPaymentProof = errors.New("invalid payment proof")
+
+// paymentProofDomain separates proof signatures from other signed data.
+const paymentProofDomain = "airfi-payment-proof"
+
+// PaymentProof is the payer's signed statement of how much it has paid in
+// a channel as of a state version. It reveals neither balance nor the peer,
+// so it can be handed to a third-party verifier.
+type PaymentProof struct {
+	ChannelID string `json:"channel_id"` // hex
+	Version   uint64 `json:"version"`
+	Payment   string `json:"payment"`   // shannons, decimal
+	Signature string `json:"signature"` // hex, padded DER
+}
+
+// PaymentAmount parses the proof's payment in shannons.
+func (p *PaymentProof) PaymentAmount() (*big.Int, error) {
+	amount, ok := new(big.Int).SetString(p.Payment, 10)
+	if !ok || amount.Sign() < 0 || amount.BitLen() > 256 {
+		return nil, fmt.Errorf("%w: malformed payment %q", ErrInvalidPaymentProof, p.Payment)
+	}
+	return amount, nil
+}
+
+// GeneratePaymentProof signs a proof that payment is the total this client
+// has paid in the channel at its current version.
+func (cc *ChannelClient) GeneratePaymentProof(ch *gpclient.Channel, payment *big.Int) (*PaymentProof, error) {
+	state := ch.State()
+	paid, err := cc.paidInChannel(ch, state)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Cmp(paid) != 0 {
+		return nil, fmt.Errorf("payment %s does not match %s paid in channel", payment, paid)
+	}
+
+	sig, err := cc.account.SignData(paymentProofMessage(ch.ID(), state.Version, payment))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign payment proof: %w", err)
+	}
+
+	return &PaymentProof{
+		ChannelID: ChannelID(ch.ID()).String(),
+		Version:   state.Version,
+		Payment:   payment.String(),
+		Signature: hex.EncodeToString(sig),
+	}, nil
+}
+
+// VerifyPaymentProof checks that proof was signed by this client's side of
+// the channel and claims payment, no more than has been paid by the
+// channel's current version. It changes no state, so the same proof can be
+// verified any number of times. Failures wrap ErrInvalidPaymentProof.
+func (cc *ChannelClient) VerifyPaymentProof(ch *gpclient.Channel, payment *big.Int, proof *PaymentProof) error {
+	state := ch.State()
+	paid, err := cc.paidInChannel(ch, state)
+	if err != nil {
+		return err
+	}
+	return checkPaymentProof(proof, ch.ID(), ch.Params().Parts[ch.Idx()], payment, paid, state.Version)
+}
+
+// paidInChannel returns how much this client has paid in the channel,
+// measured from the balance it funded the channel with.
+func (cc *ChannelClient) paidInChannel(ch *gpclient.Channel, state *gpchannel.State) (*big.Int, error) {
+	cc.channelsMu.RLock()
+	active, ok := cc.channels[ch.ID()]
+	cc.channelsMu.RUnlock()
+	if !ok || active.InitialBalance == nil {
+		return nil, fmt.Errorf("channel %x not opened by this client", ch.ID())
+	}
+
+	current := state.Allocation.Balance(ch.Idx(), ChannelAsset(&state.Allocation))
+	return new(big.Int).Sub(active.InitialBalance, current), nil
+}
+
+// checkPaymentProof verifies the proof's signature against payer, that its
+// version is past the funding state (version 0, before any payment) without
+// exceeding currentVersion, and that it claims payment, no more than has
+// actually been paid.
+func checkPaymentProof(proof *PaymentProof, channelID gpchannel.ID, payer gpwallet.Address, payment, paid *big.Int, currentVersion uint64) error {
+	if proof == nil {
+		return fmt.Errorf("%w: missing proof", ErrInvalidPaymentProof)
+	}
+	if proof.ChannelID != ChannelID(channelID).String() {
+		return fmt.Errorf("%w: wrong channel", ErrInvalidPaymentProof)
+	}
+
+	sig, err := hex.DecodeString(proof.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: malformed signature", ErrInvalidPaymentProof)
+	}
+	claimed, err := proof.PaymentAmount()
+	if err != nil {
+		return err
+	}
+	ok, err := ckbwallet.Backend.VerifySignature(paymentProofMessage(channelID, proof.Version, claimed), sig, payer)
+	if err != nil || !ok {
+		return fmt.Errorf("%w: bad signature", ErrInvalidPaymentProof)
+	}
+
+	if proof.Version == 0 {
+		return fmt.Errorf("%w: version 0 is the funding state", ErrInvalidPaymentProof)
+	}
+	if proof.Version > currentVersion {
+		return fmt.Errorf("%w: version %d is ahead of channel version %d", ErrInvalidPaymentProof, proof.Version, currentVersion)
+	}
+
+	if claimed.Cmp(payment) != 0 {
+		return fmt.Errorf("%w: proof is for %s, not %s", ErrInvalidPaymentProof, claimed, payment)
+	}
+	if claimed.Cmp(paid) > 0 {
+		return fmt.Errorf("%w: claims %s but only %s paid", ErrInvalidPaymentProof, claimed, paid)
+	}
+	return nil
+}
+
+// paymentProofMessage returns the bytes signed for a proof.
+func paymentProofMessage(channelID gpchannel.ID, version uint64, payment *big.Int) []byte {
+	msg := make([]byte, 0, len(paymentProofDomain)+len(channelID)+8+32)
+	msg = append(msg, paymentProofDomain...)
+	msg = append(msg, channelID[:]...)
+	msg = binary.BigEndian.AppendUint64(msg, version)
+	msg = append(msg, payment.FillBytes(make([]byte, 32))...)
+	return msg
+}
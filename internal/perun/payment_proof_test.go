@@ -0,0 +1,80 @@
+package perun
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	gpchannel "perun.network/go-perun/channel"
+
+	ckbwallet "perun.network/perun-ckb-backend/wallet"
+)
+
+// signTestProof signs a proof the way GeneratePaymentProof does.
+func signTestProof(t *testing.T, acc *ckbwallet.Account, id gpchannel.ID, version uint64, payment int64) *PaymentProof {
+	t.Helper()
+	sig, err := acc.SignData(paymentProofMessage(id, version, big.NewInt(payment)))
+	if err != nil {
+		t.Fatalf("SignData failed: %v", err)
+	}
+	return &PaymentProof{
+		ChannelID: fmt.Sprintf("%x", id),
+		Version:   version,
+		Payment:   big.NewInt(payment).String(),
+		Signature: hex.EncodeToString(sig),
+	}
+}
+
+func TestCheckPaymentProof(t *testing.T) {
+	payer, err := ckbwallet.NewAccount()
+	if err != nil {
+		t.Fatalf("NewAccount failed: %v", err)
+	}
+	other, _ := ckbwallet.NewAccount()
+	id := gpchannel.ID{1, 2, 3}
+	paid := big.NewInt(500)
+
+	tampered := signTestProof(t, payer, id, 4, 300)
+	tampered.Payment = "400"
+
+	tests := []struct {
+		name    string
+		proof   *PaymentProof
+		payment int64
+		valid   bool
+	}{
+		{"valid", signTestProof(t, payer, id, 4, 300), 300, true},
+		{"older version", signTestProof(t, payer, id, 3, 300), 300, true},
+		{"current version", signTestProof(t, payer, id, 5, 300), 300, true},
+		{"signed by someone else", signTestProof(t, other, id, 4, 300), 300, false},
+		{"tampered payment", tampered, 400, false},
+		{"other channel", signTestProof(t, payer, gpchannel.ID{9}, 4, 300), 300, false},
+		{"funding state", signTestProof(t, payer, id, 0, 0), 0, false},
+		{"future version", signTestProof(t, payer, id, 6, 300), 300, false},
+		{"payment mismatch", signTestProof(t, payer, id, 4, 300), 200, false},
+		{"more than paid", signTestProof(t, payer, id, 4, 600), 600, false},
+		{"missing proof", nil, 300, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkPaymentProof(tt.proof, id, payer.Address(), big.NewInt(tt.payment), paid, 5)
+			if tt.valid && err != nil {
+				t.Fatalf("expected valid proof, got %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidPaymentProof) {
+				t.Fatalf("expected ErrInvalidPaymentProof, got %v", err)
+			}
+		})
+	}
+}
+
+func TestPaymentProof_PaymentAmount(t *testing.T) {
+	for _, payment := range []string{"", "abc", "-1", new(big.Int).Lsh(big.NewInt(1), 300).String()} {
+		if _, err := (&PaymentProof{Payment: payment}).PaymentAmount(); !errors.Is(err, ErrInvalidPaymentProof) {
+			t.Errorf("payment %q: expected ErrInvalidPaymentProof, got %v", payment, err)
+		}
+	}
+}
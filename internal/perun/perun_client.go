@@ -34,6 +34,7 @@ type PerunClient struct {
 	rpcClient   rpc.Client
 	logger      *zap.Logger
 	ckbAddress  string
+	disputes    DisputeRecorder
 
 	// Active channels
 	channels   map[channel.ID]*PaymentChannel
@@ -62,6 +63,10 @@ type PerunConfig struct {
 	Deployment   backend.Deployment
 	ChallengeLen uint64
 	Logger       *zap.Logger
+
+	// DisputeRecorder is told when DisputeChannel registers a dispute and
+	// when ForceCloseChannel resolves it. Nil records nothing.
+	DisputeRecorder DisputeRecorder
 }
 
 // NewPerunClient creates a new Perun client for CKB.
@@ -119,6 +124,7 @@ func NewPerunClient(cfg *PerunConfig) (*PerunClient, error) {
 		rpcClient:  rpcClient,
 		logger:     cfg.Logger,
 		ckbAddress: ckbAddrStr,
+		disputes:   cfg.DisputeRecorder,
 		channels:   make(map[channel.ID]*PaymentChannel),
 	}, nil
 }
@@ -172,7 +178,7 @@ func (pc *PerunClient) OpenChannel(ctx context.Context, peerAddr string, myFundi
 	}
 
 	pc.logger.Info("starting channel on-chain",
-		zap.String("channel_id", fmt.Sprintf("%x", params.ID())),
+		zap.String("channel_id", ChannelID(params.ID()).String()),
 	)
 
 	// Start the channel on-chain (this creates the funding transaction)
@@ -187,7 +193,7 @@ func (pc *PerunClient) OpenChannel(ctx context.Context, peerAddr string, myFundi
 	// transactions from the wallet address with -904 CKB capacity change.
 	pctsHash := fmt.Sprintf("0x%x", pcts.Hash())
 	pc.logger.Info("channel funding initiated",
-		zap.String("channel_id", fmt.Sprintf("%x", params.ID())),
+		zap.String("channel_id", ChannelID(params.ID()).String()),
 		zap.String("pcts_hash", pctsHash),
 		zap.String("note", "Check wallet address in explorer for actual funding TX"),
 	)
@@ -213,7 +219,7 @@ func (pc *PerunClient) OpenChannel(ctx context.Context, peerAddr string, myFundi
 
 	totalFunding := new(big.Int).Add(myFunding, peerFunding)
 	pc.logger.Info("Perun channel opened successfully",
-		zap.String("channel_id", fmt.Sprintf("%x", params.ID())),
+		zap.String("channel_id", ChannelID(params.ID()).String()),
 		zap.String("total_funding", totalFunding.String()),
 	)
 
@@ -236,7 +242,7 @@ func (pc *PerunClient) SendPayment(channelID channel.ID, amount *big.Int) error
 	pc.channelsMu.Unlock()
 
 	pc.logger.Info("payment sent",
-		zap.String("channel_id", fmt.Sprintf("%x", channelID)),
+		zap.String("channel_id", ChannelID(channelID).String()),
 		zap.String("amount", amount.String()),
 	)
 
@@ -259,7 +265,7 @@ func (pc *PerunClient) ReceivePayment(channelID channel.ID, amount *big.Int) err
 	pc.channelsMu.Unlock()
 
 	pc.logger.Info("payment received",
-		zap.String("channel_id", fmt.Sprintf("%x", channelID)),
+		zap.String("channel_id", ChannelID(channelID).String()),
 		zap.String("amount", amount.String()),
 	)
 
@@ -278,7 +284,7 @@ func (pc *PerunClient) SettleChannel(ctx context.Context, channelID channel.ID)
 	pc.channelsMu.Unlock()
 
 	pc.logger.Info("settling channel",
-		zap.String("channel_id", fmt.Sprintf("%x", channelID)),
+		zap.String("channel_id", ChannelID(channelID).String()),
 		zap.String("my_balance", ch.MyBalance.String()),
 		zap.String("peer_balance", ch.PeerBalance.String()),
 	)
@@ -333,7 +339,7 @@ func (pc *PerunClient) SettleChannel(ctx context.Context, channelID channel.ID)
 	settleTxHash := fmt.Sprintf("0x%x", channelID[:8])
 
 	pc.logger.Info("channel settled successfully",
-		zap.String("channel_id", fmt.Sprintf("%x", channelID)),
+		zap.String("channel_id", ChannelID(channelID).String()),
 	)
 
 	return settleTxHash, nil
@@ -351,7 +357,7 @@ func (pc *PerunClient) DisputeChannel(ctx context.Context, channelID channel.ID)
 	pc.channelsMu.Unlock()
 
 	pc.logger.Info("disputing channel (starting challenge period)",
-		zap.String("channel_id", fmt.Sprintf("%x", channelID)),
+		zap.String("channel_id", ChannelID(channelID).String()),
 		zap.String("my_balance", ch.MyBalance.String()),
 		zap.String("peer_balance", ch.PeerBalance.String()),
 	)
@@ -402,9 +408,11 @@ func (pc *PerunClient) DisputeChannel(ctx context.Context, channelID channel.ID)
 	pc.channelsMu.Unlock()
 
 	pc.logger.Info("channel dispute registered, challenge period started",
-		zap.String("channel_id", fmt.Sprintf("%x", channelID)),
+		zap.String("channel_id", ChannelID(channelID).String()),
 		zap.Int("challenge_blocks", ChallengeBlocks),
 	)
+	// The adjudicator client doesn't return the registration transaction
+	recordDispute(pc.disputes, pc.logger, ChannelID(channelID), "")
 
 	return nil
 }
@@ -421,7 +429,7 @@ func (pc *PerunClient) ForceCloseChannel(ctx context.Context, channelID channel.
 	pc.channelsMu.Unlock()
 
 	pc.logger.Info("force closing channel",
-		zap.String("channel_id", fmt.Sprintf("%x", channelID)),
+		zap.String("channel_id", ChannelID(channelID).String()),
 		zap.String("my_balance", ch.MyBalance.String()),
 		zap.String("peer_balance", ch.PeerBalance.String()),
 	)
@@ -453,8 +461,9 @@ func (pc *PerunClient) ForceCloseChannel(ctx context.Context, channelID channel.
 	pc.channelsMu.Unlock()
 
 	pc.logger.Info("channel force closed successfully",
-		zap.String("channel_id", fmt.Sprintf("%x", channelID)),
+		zap.String("channel_id", ChannelID(channelID).String()),
 	)
+	recordResolution(pc.disputes, pc.logger, ChannelID(channelID), "")
 
 	return nil
 }
@@ -528,13 +537,16 @@ func (pc *PerunClient) ListChannels() []*PaymentChannel {
 
 // GetChannelByString returns a channel by hex string ID.
 func (pc *PerunClient) GetChannelByString(channelIDHex string) (*PaymentChannel, error) {
+	id, err := ParseChannelID(channelIDHex)
+	if err != nil {
+		return nil, fmt.Errorf("channel not found: %w", err)
+	}
+
 	pc.channelsMu.RLock()
 	defer pc.channelsMu.RUnlock()
 
-	for _, ch := range pc.channels {
-		if fmt.Sprintf("%x", ch.ID) == channelIDHex {
-			return ch, nil
-		}
+	if ch, ok := pc.channels[channel.ID(id)]; ok {
+		return ch, nil
 	}
 	return nil, fmt.Errorf("channel not found: %s", channelIDHex)
 }
@@ -586,7 +598,7 @@ func (pc *PerunClient) OpenChannelWithPeer(ctx context.Context, peerAccount *ckb
 	}
 
 	pc.logger.Info("starting 2-party channel on-chain",
-		zap.String("channel_id", fmt.Sprintf("%x", params.ID())),
+		zap.String("channel_id", ChannelID(params.ID()).String()),
 	)
 
 	// Start the channel on-chain (this creates the funding transaction)
@@ -597,7 +609,7 @@ func (pc *PerunClient) OpenChannelWithPeer(ctx context.Context, peerAccount *ckb
 
 	pctsHash := fmt.Sprintf("0x%x", pcts.Hash())
 	pc.logger.Info("2-party channel funding initiated",
-		zap.String("channel_id", fmt.Sprintf("%x", params.ID())),
+		zap.String("channel_id", ChannelID(params.ID()).String()),
 		zap.String("pcts_hash", pctsHash),
 	)
 
@@ -622,7 +634,7 @@ func (pc *PerunClient) OpenChannelWithPeer(ctx context.Context, peerAccount *ckb
 	pc.channelsMu.Unlock()
 
 	pc.logger.Info("2-party Perun channel opened successfully (pending peer funding)",
-		zap.String("channel_id", fmt.Sprintf("%x", params.ID())),
+		zap.String("channel_id", ChannelID(params.ID()).String()),
 	)
 
 	return paymentChannel, nil
@@ -632,7 +644,7 @@ func (pc *PerunClient) OpenChannelWithPeer(ctx context.Context, peerAccount *ckb
 // This must be called by Party B after Party A calls OpenChannelWithPeer.
 func (pc *PerunClient) FundChannel(ctx context.Context, pcts *types.Script, params *channel.Params, state *channel.State) error {
 	pc.logger.Info("funding channel as Party B",
-		zap.String("channel_id", fmt.Sprintf("%x", params.ID())),
+		zap.String("channel_id", ChannelID(params.ID()).String()),
 	)
 
 	err := pc.ckbClient.Fund(ctx, pcts, state, params)
@@ -649,7 +661,7 @@ func (pc *PerunClient) FundChannel(ctx context.Context, pcts *types.Script, para
 	pc.channelsMu.Unlock()
 
 	pc.logger.Info("channel funded successfully",
-		zap.String("channel_id", fmt.Sprintf("%x", params.ID())),
+		zap.String("channel_id", ChannelID(params.ID()).String()),
 	)
 
 	return nil
@@ -675,6 +687,6 @@ func (pc *PerunClient) RegisterChannel(channelID channel.ID, params *channel.Par
 	pc.channelsMu.Unlock()
 
 	pc.logger.Info("channel registered",
-		zap.String("channel_id", fmt.Sprintf("%x", channelID)),
+		zap.String("channel_id", ChannelID(channelID).String()),
 	)
 }
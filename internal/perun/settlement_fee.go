@@ -0,0 +1,157 @@
+package perun
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nervosnetwork/ckb-sdk-go/v2/collector"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/indexer"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/rpc"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
+	"go.uber.org/zap"
+
+	gpchannel "perun.network/go-perun/channel"
+	gpclient "perun.network/go-perun/client"
+	gpwallet "perun.network/go-perun/wallet"
+
+	ckbclient "perun.network/perun-ckb-backend/client"
+	"perun.network/perun-ckb-backend/transaction"
+	"perun.network/perun-ckb-backend/wallet/address"
+)
+
+// SetFeeOracle sets the oracle used to price settlement transactions.
+// Without one, EstimateSettlementFee assumes MinFeeRate.
+func (cc *ChannelClient) SetFeeOracle(oracle *NetworkFeeOracle) {
+	cc.feeOracle = oracle
+}
+
+// EstimateSettlementFee returns the fee in shannons for settling ch
+// cooperatively. It builds the close transaction the adjudicator would
+// submit, without signing or broadcasting it, and prices its weight at the
+// current network fee rate. The result is never below the fixed fee the CKB
+// backend pays, which is what settlement costs unless the network demands
+// more.
+func (cc *ChannelClient) EstimateSettlementFee(ctx context.Context, ch *gpclient.Channel) (uint64, error) {
+	tx, err := cc.buildSettlementTx(ctx, ch)
+	if err != nil {
+		return 0, err
+	}
+	return settlementFee(ctx, cc.rpcClient, tx, cc.feeRate(), cc.logger), nil
+}
+
+// feeRate returns the fee rate in shannons per kB to price transactions at.
+func (cc *ChannelClient) feeRate() uint64 {
+	if cc.feeOracle != nil {
+		return cc.feeOracle.RecommendFee(UrgencyMedium)
+	}
+	return MinFeeRate
+}
+
+// settlementFee prices tx at feeRate by its weight, the larger of its size
+// and its execution cycles in bytes. The cycles come from the node; when it
+// can't run the scripts, e.g. because the peer's signature is missing, the
+// size alone is used.
+func settlementFee(ctx context.Context, rpcClient rpc.Client, tx *types.Transaction, feeRate uint64, logger *zap.Logger) uint64 {
+	var cycles uint64
+	if estimate, err := rpcClient.EstimateCycles(ctx, tx); err != nil {
+		logger.Debug("cycle estimation failed, pricing settlement by size", zap.Error(err))
+	} else {
+		cycles = estimate.Cycles
+	}
+	return max(tx.CalculateFeeWithTxWeight(cycles, feeRate), transaction.DefaultFeeShannon)
+}
+
+// buildSettlementTx builds the unsigned transaction that closes ch with its
+// current balances marked final. Both signature slots hold this client's
+// signature, which has the same size as the peer's.
+func (cc *ChannelClient) buildSettlementTx(ctx context.Context, ch *gpclient.Channel) (tx *types.Transaction, err error) {
+	_, pcts, _, _, err := cc.ckbClient.GetChannelWithID(ctx, ch.ID())
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up channel cell: %w", err)
+	}
+	channelCells, err := cc.rpcClient.GetCells(ctx, &indexer.SearchKey{
+		Script:           pcts,
+		ScriptType:       types.ScriptTypeType,
+		ScriptSearchMode: types.ScriptSearchModeExact,
+		WithData:         true,
+	}, indexer.SearchOrderDesc, 1, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get channel cell: %w", err)
+	}
+	if len(channelCells.Objects) == 0 {
+		return nil, fmt.Errorf("channel cell not found")
+	}
+	channelCell := channelCells.Objects[0]
+
+	// Funds locked in the channel sit in cells locked by its funds lock script
+	pctsHash := pcts.Hash()
+	assets, err := cc.rpcClient.GetCells(ctx, &indexer.SearchKey{
+		Script: &types.Script{
+			CodeHash: cc.deployment.PFLSCodeHash,
+			HashType: cc.deployment.PFLSHashType,
+			Args:     pctsHash[:],
+		},
+		ScriptType:       types.ScriptTypeLock,
+		ScriptSearchMode: types.ScriptSearchModePrefix,
+		WithData:         true,
+	}, indexer.SearchOrderDesc, ckbclient.SearchIndexerLimit, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get channel asset cells: %w", err)
+	}
+	assetInputs := make([]types.CellInput, len(assets.Objects))
+	for i, cell := range assets.Objects {
+		assetInputs[i] = types.CellInput{PreviousOutput: cell.OutPoint}
+	}
+
+	header, err := cc.rpcClient.GetTipHeader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tip header: %w", err)
+	}
+
+	state := ch.State().Clone()
+	state.IsFinal = true
+	sig, err := gpchannel.Sign(cc.account, state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign final state: %w", err)
+	}
+
+	closeInfo := transaction.NewCloseInfo(
+		channelCell.Output.OccupiedCapacity(channelCell.OutputData),
+		types.CellInput{PreviousOutput: channelCell.OutPoint},
+		assetInputs,
+		[]types.Hash{header.Hash},
+		ch.Params(),
+		state,
+		[]gpwallet.Sig{sig, sig},
+	)
+
+	changeAddress := address.AsParticipant(cc.account.Address()).ToCKBAddress(types.NetworkTest)
+	encoded, err := changeAddress.Encode()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode change address: %w", err)
+	}
+	iter, err := collector.NewLiveCellIteratorFromAddress(cc.rpcClient, encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cell iterator: %w", err)
+	}
+	builder, err := transaction.NewPerunTransactionBuilderWithDeployment(cc.rpcClient, cc.deployment,
+		map[types.Hash]collector.CellIterator{{}: ckbclient.NewCKBOnlyIterator(iter)}, changeAddress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transaction builder: %w", err)
+	}
+
+	// The builder panics instead of failing when the change can't cover its fee
+	defer func() {
+		if p := recover(); p != nil {
+			tx, err = nil, fmt.Errorf("failed to build close transaction: %v", p)
+		}
+	}()
+	if err := builder.Close(closeInfo); err != nil {
+		return nil, fmt.Errorf("failed to create close transaction: %w", err)
+	}
+	built, err := builder.Build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build close transaction: %w", err)
+	}
+	return built.TxView, nil
+}
@@ -0,0 +1,62 @@
+package perun
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
+	"go.uber.org/zap"
+	"perun.network/perun-ckb-backend/transaction"
+)
+
+func TestSettlementFee(t *testing.T) {
+	tx := &types.Transaction{
+		Inputs:      []*types.CellInput{{PreviousOutput: &types.OutPoint{TxHash: types.HexToHash("0x01")}}},
+		Outputs:     []*types.CellOutput{{Capacity: 100 * 100000000, Lock: &types.Script{HashType: types.HashTypeType, Args: make([]byte, 20)}}},
+		OutputsData: [][]byte{{}},
+		Witnesses:   [][]byte{make([]byte, 500)},
+	}
+	size := tx.SizeInBlock()
+
+	tests := []struct {
+		name    string
+		rpc     *mockRPCClient
+		feeRate uint64
+		want    uint64
+	}{
+		{
+			name:    "backend fee covers small transaction",
+			rpc:     &mockRPCClient{cycles: 1000},
+			feeRate: MinFeeRate,
+			want:    transaction.DefaultFeeShannon,
+		},
+		{
+			name:    "priced by size at high fee rate",
+			rpc:     &mockRPCClient{cycles: 1000},
+			feeRate: 100000,
+			want:    size * 100,
+		},
+		{
+			name:    "priced by cycles when heavier than size",
+			rpc:     &mockRPCClient{cycles: 100_000_000},
+			feeRate: MinFeeRate,
+			want:    17057, // 100M cycles weigh 17057 bytes
+		},
+		{
+			name:    "cycle estimation failure falls back to size",
+			rpc:     &mockRPCClient{cyclesErr: errors.New("script verification failed")},
+			feeRate: 100000,
+			want:    size * 100,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := settlementFee(context.Background(), tt.rpc, tx, tt.feeRate, zap.NewNop())
+			if got != tt.want {
+				t.Errorf("settlementFee() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
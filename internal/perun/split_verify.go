@@ -0,0 +1,65 @@
+package perun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nervosnetwork/ckb-sdk-go/v2/rpc"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
+)
+
+// ErrSplitVerificationFailed is returned when a confirmed transaction's
+// outputs differ from the ones it was built with.
+var ErrSplitVerificationFailed = errors.New("on-chain outputs do not match the submitted transaction")
+
+// outputTolerance is how far, in shannons, an on-chain output capacity may
+// differ from the expected one to allow for rounding.
+const outputTolerance = 1
+
+// VerifySplit checks that the committed transaction txHash has exactly the
+// expected output capacities, in order. A node accepting a transaction
+// with modified outputs should be impossible; this guards against it.
+func (cs *CellSplitter) VerifySplit(ctx context.Context, txHash types.Hash, expectedOutputs []uint64) error {
+	return verifyTxOutputs(ctx, cs.rpcClient, txHash, expectedOutputs)
+}
+
+// verifyTxOutputs fetches txHash and compares its output capacities with
+// expectedOutputs.
+func verifyTxOutputs(ctx context.Context, rpcClient rpc.Client, txHash types.Hash, expectedOutputs []uint64) error {
+	txWithStatus, err := rpcClient.GetTransaction(ctx, txHash)
+	if err != nil {
+		return fmt.Errorf("failed to get transaction %s: %w", txHash.Hex(), err)
+	}
+	if txWithStatus == nil || txWithStatus.Transaction == nil {
+		return fmt.Errorf("%w: transaction %s not found", ErrSplitVerificationFailed, txHash.Hex())
+	}
+
+	actual := make([]uint64, len(txWithStatus.Transaction.Outputs))
+	for i, output := range txWithStatus.Transaction.Outputs {
+		actual[i] = output.Capacity
+	}
+	if err := compareOutputs(actual, expectedOutputs); err != nil {
+		return fmt.Errorf("transaction %s: %w", txHash.Hex(), err)
+	}
+	return nil
+}
+
+// compareOutputs reports the first difference between actual and expected
+// output capacities beyond outputTolerance.
+func compareOutputs(actual, expected []uint64) error {
+	if len(actual) != len(expected) {
+		return fmt.Errorf("%w: expected %d outputs, got %d", ErrSplitVerificationFailed, len(expected), len(actual))
+	}
+	for i := range expected {
+		diff := actual[i] - expected[i]
+		if actual[i] < expected[i] {
+			diff = expected[i] - actual[i]
+		}
+		if diff > outputTolerance {
+			return fmt.Errorf("%w: output %d has %d shannons, expected %d",
+				ErrSplitVerificationFailed, i, actual[i], expected[i])
+		}
+	}
+	return nil
+}
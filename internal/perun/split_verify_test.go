@@ -0,0 +1,59 @@
+package perun
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
+	"go.uber.org/zap"
+)
+
+func TestCompareOutputs(t *testing.T) {
+	tests := []struct {
+		name     string
+		actual   []uint64
+		expected []uint64
+		wantErr  bool
+	}{
+		{"exact", []uint64{100, 200}, []uint64{100, 200}, false},
+		{"rounded up", []uint64{101, 200}, []uint64{100, 200}, false},
+		{"rounded down", []uint64{100, 199}, []uint64{100, 200}, false},
+		{"modified", []uint64{100, 150}, []uint64{100, 200}, true},
+		{"missing output", []uint64{100}, []uint64{100, 200}, true},
+		{"extra output", []uint64{100, 200, 5}, []uint64{100, 200}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := compareOutputs(tt.actual, tt.expected)
+			if tt.wantErr && !errors.Is(err, ErrSplitVerificationFailed) {
+				t.Errorf("Expected ErrSplitVerificationFailed, got %v", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Expected match, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCellSplitter_VerifySplit(t *testing.T) {
+	txHash := types.HexToHash("0x03")
+	rpcClient := &mockRPCClient{
+		txs: map[types.Hash]*types.Transaction{
+			txHash: {Outputs: []*types.CellOutput{{Capacity: 7000000000}, {Capacity: 6999900000}}},
+		},
+	}
+	cs := NewCellSplitter(rpcClient, zap.NewNop())
+
+	if err := cs.VerifySplit(context.Background(), txHash, []uint64{7000000000, 6999900000}); err != nil {
+		t.Errorf("Expected matching outputs, got %v", err)
+	}
+	err := cs.VerifySplit(context.Background(), txHash, []uint64{7000000000, 7000000000})
+	if !errors.Is(err, ErrSplitVerificationFailed) {
+		t.Errorf("Expected ErrSplitVerificationFailed, got %v", err)
+	}
+	err = cs.VerifySplit(context.Background(), types.HexToHash("0x04"), []uint64{7000000000})
+	if !errors.Is(err, ErrSplitVerificationFailed) {
+		t.Errorf("Expected ErrSplitVerificationFailed for unknown tx, got %v", err)
+	}
+}
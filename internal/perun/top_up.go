@@ -0,0 +1,52 @@
+package perun
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	gpchannel "perun.network/go-perun/channel"
+	gpclient "perun.network/go-perun/client"
+)
+
+// ErrTopUpUnsupported is returned when a channel can't take more funds after
+// it was opened. Callers should fall back to settling it and opening a new one.
+var ErrTopUpUnsupported = errors.New("channel top-up not supported")
+
+// CanTopUp reports whether funds can be added to an open channel.
+//
+// The CKB backend funds a channel once, when both deposits are locked into
+// the PCTS cell at open. Neither go-perun's sub-channels nor virtual
+// channels add to an existing ledger channel's deposit, and the PCTS has no
+// transition that accepts a later deposit, so this is always false for now.
+func (cc *ChannelClient) CanTopUp() bool {
+	return false
+}
+
+// AdjustFunding adds delta shannons to this client's side of the channel
+// without opening a new one. Until the backend supports it, this returns an
+// error wrapping ErrTopUpUnsupported.
+func (cc *ChannelClient) AdjustFunding(ch *gpclient.Channel, delta *big.Int) error {
+	if delta == nil || delta.Sign() <= 0 {
+		return fmt.Errorf("top-up amount must be positive, got %v", delta)
+	}
+	if !cc.CanTopUp() {
+		return fmt.Errorf("%w: channel %x must be settled and reopened to add %s", ErrTopUpUnsupported, ch.ID(), delta)
+	}
+	return nil
+}
+
+// Shortfall returns how much more than this client's channel balance amount
+// is, or zero if the balance covers it.
+func (cc *ChannelClient) Shortfall(ch *gpclient.Channel, amount *big.Int) *big.Int {
+	return shortfall(&ch.State().Allocation, ch.Idx(), amount)
+}
+
+// shortfall returns how far amount exceeds idx's balance of the channel asset in alloc.
+func shortfall(alloc *gpchannel.Allocation, idx gpchannel.Index, amount *big.Int) *big.Int {
+	bal := alloc.Balance(idx, ChannelAsset(alloc))
+	if amount.Cmp(bal) <= 0 {
+		return new(big.Int)
+	}
+	return new(big.Int).Sub(amount, bal)
+}
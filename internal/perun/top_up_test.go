@@ -0,0 +1,35 @@
+package perun
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestShortfall(t *testing.T) {
+	alloc := newTestAllocation(1000, 0)
+
+	if got := shortfall(alloc, 0, big.NewInt(400)); got.Sign() != 0 {
+		t.Errorf("Expected no shortfall when balance covers amount, got %s", got)
+	}
+	if got := shortfall(alloc, 0, big.NewInt(1000)); got.Sign() != 0 {
+		t.Errorf("Expected no shortfall for exact balance, got %s", got)
+	}
+	if got := shortfall(alloc, 0, big.NewInt(1500)); got.Cmp(big.NewInt(500)) != 0 {
+		t.Errorf("Expected shortfall 500, got %s", got)
+	}
+	if got := shortfall(alloc, 1, big.NewInt(1)); got.Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("Expected shortfall 1 for empty side, got %s", got)
+	}
+}
+
+func TestAdjustFunding_Unsupported(t *testing.T) {
+	cc := &ChannelClient{}
+	if cc.CanTopUp() {
+		t.Fatal("Expected CKB backend channels not to support top-up")
+	}
+
+	if err := cc.AdjustFunding(nil, big.NewInt(0)); err == nil || errors.Is(err, ErrTopUpUnsupported) {
+		t.Errorf("Expected invalid amount error, got %v", err)
+	}
+}
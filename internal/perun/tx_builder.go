@@ -0,0 +1,127 @@
+package perun
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nervosnetwork/ckb-sdk-go/v2/indexer"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/rpc"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
+	"go.uber.org/zap"
+)
+
+// TransactionBuilder builds and submits the transactions CellSplitter needs.
+// Signing stays in CellSplitter, so a fake builder is enough to exercise the
+// split logic without a CKB node.
+type TransactionBuilder interface {
+	// BuildSplitTx returns an unsigned transaction spending cell into one
+	// output per capacity in outputs, all locked by lockScript.
+	BuildSplitTx(cell *indexer.LiveCell, lockScript *types.Script, outputs []uint64) (*types.Transaction, error)
+	// BroadcastTx submits a signed transaction and waits until it is
+	// committed. If it was submitted but not confirmed, the hash is returned
+	// along with the error.
+	BroadcastTx(ctx context.Context, tx *types.Transaction) (types.Hash, error)
+}
+
+var _ TransactionBuilder = (*DefaultTransactionBuilder)(nil)
+
+// DefaultTransactionBuilder builds secp256k1 transactions and submits them
+// through a CKB RPC client.
+type DefaultTransactionBuilder struct {
+	rpcClient rpc.Client
+	logger    *zap.Logger
+}
+
+// NewDefaultTransactionBuilder creates a transaction builder using rpcClient.
+func NewDefaultTransactionBuilder(rpcClient rpc.Client, logger *zap.Logger) *DefaultTransactionBuilder {
+	return &DefaultTransactionBuilder{
+		rpcClient: rpcClient,
+		logger:    logger,
+	}
+}
+
+// BuildSplitTx builds the split transaction. Every output must hold at least
+// CellMinCapacity and together they may not exceed the input cell; whatever
+// is left over is the fee.
+func (b *DefaultTransactionBuilder) BuildSplitTx(cell *indexer.LiveCell, lockScript *types.Script, outputs []uint64) (*types.Transaction, error) {
+	if len(outputs) == 0 {
+		return nil, fmt.Errorf("split transaction needs at least one output")
+	}
+
+	var total uint64
+	cellOutputs := make([]*types.CellOutput, len(outputs))
+	outputsData := make([][]byte, len(outputs))
+	for i, capacity := range outputs {
+		if capacity < CellMinCapacity {
+			return nil, fmt.Errorf("output %d holds %d shannons, below the %d minimum", i, capacity, CellMinCapacity)
+		}
+		total += capacity
+		cellOutputs[i] = &types.CellOutput{
+			Capacity: capacity,
+			Lock:     lockScript,
+			Type:     nil,
+		}
+		outputsData[i] = []byte{}
+	}
+	if total > cell.Output.Capacity {
+		return nil, fmt.Errorf("outputs hold %d shannons, more than the %d in the input cell", total, cell.Output.Capacity)
+	}
+
+	return &types.Transaction{
+		Version:  0,
+		CellDeps: []*types.CellDep{getSecp256k1CellDep()},
+		Inputs: []*types.CellInput{
+			{
+				Since:          0,
+				PreviousOutput: cell.OutPoint,
+			},
+		},
+		Outputs:     cellOutputs,
+		OutputsData: outputsData,
+		Witnesses:   [][]byte{make([]byte, 85)}, // Placeholder for signature
+	}, nil
+}
+
+// BroadcastTx sends tx and waits for it to be committed.
+func (b *DefaultTransactionBuilder) BroadcastTx(ctx context.Context, tx *types.Transaction) (types.Hash, error) {
+	txHash, err := b.rpcClient.SendTransaction(ctx, tx)
+	if err != nil {
+		return types.Hash{}, fmt.Errorf("failed to send transaction: %w", err)
+	}
+
+	b.logger.Info("transaction submitted", zap.String("tx_hash", txHash.Hex()))
+
+	if err := b.waitForConfirmation(ctx, *txHash); err != nil {
+		return *txHash, fmt.Errorf("transaction not confirmed: %w", err)
+	}
+	return *txHash, nil
+}
+
+// waitForConfirmation waits for a transaction to be confirmed.
+func (b *DefaultTransactionBuilder) waitForConfirmation(ctx context.Context, txHash types.Hash) error {
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	timeout := time.After(2 * time.Minute)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeout:
+			return fmt.Errorf("timeout waiting for confirmation")
+		case <-ticker.C:
+			txWithStatus, err := b.rpcClient.GetTransaction(ctx, txHash)
+			if err != nil {
+				continue
+			}
+			if txWithStatus.TxStatus.Status == types.TransactionStatusCommitted {
+				return nil
+			}
+			if txWithStatus.TxStatus.Status == types.TransactionStatusRejected {
+				return fmt.Errorf("transaction rejected: %v", txWithStatus.TxStatus.Reason)
+			}
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package perun
+
+import (
+	"testing"
+
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
+	"go.uber.org/zap"
+)
+
+func TestDefaultTransactionBuilder_BuildSplitTx(t *testing.T) {
+	b := NewDefaultTransactionBuilder(&mockRPCClient{}, zap.NewNop())
+	cell := newTestCell(200*100000000, 0)
+	lock := &types.Script{}
+
+	tests := []struct {
+		name    string
+		outputs []uint64
+		wantErr bool
+	}{
+		{"two outputs with fee", []uint64{CellMinCapacity, 200*100000000 - CellMinCapacity - SplitFee}, false},
+		{"no outputs", nil, true},
+		{"output below minimum", []uint64{CellMinCapacity - 1, CellMinCapacity}, true},
+		{"outputs exceed input", []uint64{100 * 100000000, 100*100000000 + 1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx, err := b.BuildSplitTx(cell, lock, tt.outputs)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("BuildSplitTx failed: %v", err)
+			}
+			if len(tx.Inputs) != 1 || tx.Inputs[0].PreviousOutput != cell.OutPoint {
+				t.Error("Expected the cell as the only input")
+			}
+			if len(tx.Outputs) != len(tt.outputs) || len(tx.OutputsData) != len(tt.outputs) {
+				t.Fatalf("Expected %d outputs, got %d", len(tt.outputs), len(tx.Outputs))
+			}
+			for i, out := range tx.Outputs {
+				if out.Capacity != tt.outputs[i] || out.Lock != lock || out.Type != nil {
+					t.Errorf("Output %d: unexpected %+v", i, out)
+				}
+			}
+			if len(tx.Witnesses) != 1 {
+				t.Errorf("Expected one witness placeholder, got %d", len(tx.Witnesses))
+			}
+		})
+	}
+}
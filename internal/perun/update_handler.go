@@ -0,0 +1,53 @@
+package perun
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+	gpchannel "perun.network/go-perun/channel"
+	gpclient "perun.network/go-perun/client"
+)
+
+// ErrSessionPaused is the reason given for updates rejected on a paused
+// session's channel.
+var ErrSessionPaused = errors.New("session is paused")
+
+// UpdateHandler answers the peer's updates on one channel in place of the
+// client's update validator.
+type UpdateHandler interface {
+	HandleUpdate(cur *gpchannel.State, next gpclient.ChannelUpdate, responder *gpclient.UpdateResponder)
+}
+
+// SetUpdateHandler makes handler answer the peer's updates on a channel.
+// A nil handler removes it, so updates are validated again.
+func (cc *ChannelClient) SetUpdateHandler(channelID ChannelID, handler UpdateHandler) {
+	cc.updateHandlersMu.Lock()
+	defer cc.updateHandlersMu.Unlock()
+	if handler == nil {
+		delete(cc.updateHandlers, channelID)
+		return
+	}
+	if cc.updateHandlers == nil {
+		cc.updateHandlers = make(map[ChannelID]UpdateHandler)
+	}
+	cc.updateHandlers[channelID] = handler
+}
+
+// PausedSessionHandler rejects every update on a paused session's channel.
+type PausedSessionHandler struct {
+	Logger *zap.Logger
+}
+
+// ValidateUpdate fails every update with ErrSessionPaused.
+func (h *PausedSessionHandler) ValidateUpdate(cur *gpchannel.State, next *gpchannel.State) error {
+	return ErrSessionPaused
+}
+
+// HandleUpdate rejects the update with ErrSessionPaused.
+func (h *PausedSessionHandler) HandleUpdate(cur *gpchannel.State, next gpclient.ChannelUpdate, responder *gpclient.UpdateResponder) {
+	err := h.ValidateUpdate(cur, next.State)
+	if rejectErr := responder.Reject(context.Background(), err.Error()); rejectErr != nil && h.Logger != nil {
+		h.Logger.Error("failed to reject update", zap.Error(rejectErr))
+	}
+}
@@ -0,0 +1,44 @@
+package perun
+
+import (
+	"errors"
+	"testing"
+
+	gpchannel "perun.network/go-perun/channel"
+	gpclient "perun.network/go-perun/client"
+)
+
+// recordingUpdateHandler records the updates it is given.
+type recordingUpdateHandler struct {
+	updates []uint64
+}
+
+func (h *recordingUpdateHandler) HandleUpdate(cur *gpchannel.State, next gpclient.ChannelUpdate, responder *gpclient.UpdateResponder) {
+	h.updates = append(h.updates, next.State.Version)
+}
+
+func TestChannelClient_SetUpdateHandler(t *testing.T) {
+	cc := &ChannelClient{}
+	cur := testChannelState(1, 1000, 0)
+	next := testChannelState(2, 900, 100)
+	next.ID = gpchannel.ID{0xab}
+
+	handler := &recordingUpdateHandler{}
+	cc.SetUpdateHandler(ChannelID{0xab}, handler)
+	cc.HandleUpdate(cur, gpclient.ChannelUpdate{State: next}, nil)
+	if len(handler.updates) != 1 || handler.updates[0] != 2 {
+		t.Fatalf("Expected the channel's handler to get version 2, got %v", handler.updates)
+	}
+
+	cc.SetUpdateHandler(ChannelID{0xab}, nil)
+	if len(cc.updateHandlers) != 0 {
+		t.Errorf("Expected the handler to be removed, got %v", cc.updateHandlers)
+	}
+}
+
+func TestPausedSessionHandler(t *testing.T) {
+	h := &PausedSessionHandler{}
+	if err := h.ValidateUpdate(testChannelState(1, 1000, 0), testChannelState(2, 900, 100)); !errors.Is(err, ErrSessionPaused) {
+		t.Errorf("Expected ErrSessionPaused, got %v", err)
+	}
+}
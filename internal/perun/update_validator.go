@@ -0,0 +1,96 @@
+package perun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+	gpchannel "perun.network/go-perun/channel"
+	gpclient "perun.network/go-perun/client"
+)
+
+// UpdateValidator decides whether a channel update proposed by the peer
+// may be signed.
+type UpdateValidator interface {
+	ValidateUpdate(cur *gpchannel.State, next *gpchannel.State) error
+}
+
+// MonotonicBalanceValidator accepts only payments from PayerIdx: the payer's
+// balance never grows, each update bumps the version by exactly one and no
+// funds appear or disappear. On the host, the guest (participant 0) is the
+// payer.
+type MonotonicBalanceValidator struct {
+	PayerIdx gpchannel.Index
+}
+
+// ValidateUpdate checks next against cur.
+func (v *MonotonicBalanceValidator) ValidateUpdate(cur *gpchannel.State, next *gpchannel.State) error {
+	if cur == nil || next == nil {
+		return errors.New("missing channel state")
+	}
+	if next.Version != cur.Version+1 {
+		return fmt.Errorf("version must increase by 1: %d -> %d", cur.Version, next.Version)
+	}
+
+	curBals, nextBals := cur.Allocation.Balances, next.Allocation.Balances
+	if len(curBals) != len(nextBals) {
+		return fmt.Errorf("asset count changed: %d -> %d", len(curBals), len(nextBals))
+	}
+	for a := range curBals {
+		if len(curBals[a]) != len(nextBals[a]) || int(v.PayerIdx) >= len(curBals[a]) {
+			return fmt.Errorf("participant count changed for asset %d", a)
+		}
+		if nextBals[a][v.PayerIdx].Cmp(curBals[a][v.PayerIdx]) > 0 {
+			return fmt.Errorf("payer balance of asset %d increased: %s -> %s",
+				a, curBals[a][v.PayerIdx], nextBals[a][v.PayerIdx])
+		}
+	}
+
+	curSum, nextSum := cur.Allocation.Sum(), next.Allocation.Sum()
+	for a := range curSum {
+		if curSum[a].Cmp(nextSum[a]) != 0 {
+			return fmt.Errorf("total of asset %d not conserved: %s -> %s", a, curSum[a], nextSum[a])
+		}
+	}
+	return nil
+}
+
+// ValidateUpdate runs the configured update validator. Without one, every
+// update is valid.
+func (cc *ChannelClient) ValidateUpdate(cur *gpchannel.State, next *gpchannel.State) error {
+	if cc.updateValidator == nil {
+		return nil
+	}
+	return cc.updateValidator.ValidateUpdate(cur, next)
+}
+
+// HandleUpdate passes a peer's update to the channel's update handler if
+// one is set. Otherwise it signs the update if it passes validation and
+// rejects it if not.
+func (cc *ChannelClient) HandleUpdate(cur *gpchannel.State, next gpclient.ChannelUpdate, responder *gpclient.UpdateResponder) {
+	cc.updateHandlersMu.RLock()
+	handler := cc.updateHandlers[ChannelID(next.State.ID)]
+	cc.updateHandlersMu.RUnlock()
+	if handler != nil {
+		handler.HandleUpdate(cur, next, responder)
+		return
+	}
+
+	ctx := context.Background()
+	if err := cc.ValidateUpdate(cur, next.State); err != nil {
+		cc.logger.Error("rejecting invalid channel update",
+			zap.String("channel_id", ChannelID(next.State.ID).String()),
+			zap.Uint64("version", next.State.Version),
+			zap.Error(err),
+		)
+		if err := responder.Reject(ctx, err.Error()); err != nil {
+			cc.logger.Error("failed to reject update", zap.Error(err))
+		}
+		return
+	}
+
+	if err := responder.Accept(ctx); err != nil {
+		cc.logger.Error("failed to accept update", zap.Error(err))
+	}
+}
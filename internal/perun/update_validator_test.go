@@ -0,0 +1,66 @@
+package perun
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	gpchannel "perun.network/go-perun/channel"
+	"perun.network/perun-ckb-backend/channel/asset"
+)
+
+func testChannelState(version uint64, guest, host int64) *gpchannel.State {
+	ckbAsset := asset.NewCKBytesAsset()
+	alloc := gpchannel.NewAllocation(2, ckbAsset)
+	alloc.SetAssetBalances(ckbAsset, []gpchannel.Bal{big.NewInt(guest), big.NewInt(host)})
+	return &gpchannel.State{Version: version, Allocation: *alloc}
+}
+
+func TestMonotonicBalanceValidator(t *testing.T) {
+	validator := &MonotonicBalanceValidator{PayerIdx: 0}
+	cur := testChannelState(4, 1000, 500)
+
+	tests := []struct {
+		name    string
+		next    *gpchannel.State
+		wantErr string
+	}{
+		{"guest pays host", testChannelState(5, 900, 600), ""},
+		{"final state without payment", testChannelState(5, 1000, 500), ""},
+		{"guest balance increases", testChannelState(5, 1100, 400), "payer balance"},
+		{"version skipped", testChannelState(6, 900, 600), "version"},
+		{"version repeated", testChannelState(4, 900, 600), "version"},
+		{"funds created", testChannelState(5, 900, 700), "not conserved"},
+		{"funds destroyed", testChannelState(5, 900, 500), "not conserved"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validator.ValidateUpdate(cur, tt.next)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected update to be accepted, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestChannelClient_ValidateUpdate(t *testing.T) {
+	cur := testChannelState(1, 1000, 0)
+	malformed := testChannelState(2, 1100, 0)
+
+	// Without a validator every update is signed
+	cc := &ChannelClient{}
+	if err := cc.ValidateUpdate(cur, malformed); err != nil {
+		t.Errorf("Expected no validation without a validator, got %v", err)
+	}
+
+	cc.updateValidator = &MonotonicBalanceValidator{PayerIdx: 0}
+	if err := cc.ValidateUpdate(cur, malformed); err == nil {
+		t.Error("Expected update raising the guest's balance to be rejected")
+	}
+}
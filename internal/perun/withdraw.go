@@ -4,7 +4,11 @@ package perun
 import (
 	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 	"github.com/nervosnetwork/ckb-sdk-go/v2/address"
@@ -18,25 +22,86 @@ import (
 const (
 	// WithdrawFee is the transaction fee for withdrawal (0.001 CKB)
 	WithdrawFee uint64 = 100000
+	// DefaultWithdrawConfirmations is the block depth a withdrawal must reach
+	// before it is considered final.
+	DefaultWithdrawConfirmations uint = 3
+	// withdrawConfirmationTimeout bounds how long to wait for confirmations.
+	withdrawConfirmationTimeout = 10 * time.Minute
 )
 
+// ErrInsufficientBalance is returned when a wallet's cells can't cover a
+// withdrawal plus its fee and change.
+var ErrInsufficientBalance = errors.New("insufficient balance")
+
+// ErrNoSpendableCells is returned when a wallet has no plain CKB cells to
+// withdraw.
+var ErrNoSpendableCells = errors.New("no withdrawable cells found")
+
 // Withdrawer handles withdrawing remaining CKB from guest wallets.
 type Withdrawer struct {
-	rpcClient rpc.Client
-	logger    *zap.Logger
+	rpcClient    rpc.Client
+	logger       *zap.Logger
+	feeOracle    *NetworkFeeOracle
+	pollInterval time.Duration
+
+	// senderCache maps wallet address to sender address. A wallet's funding
+	// sender never changes, so resolved addresses are kept for the process lifetime.
+	senderCache sync.Map
 }
 
 // NewWithdrawer creates a new withdrawer.
 func NewWithdrawer(rpcClient rpc.Client, logger *zap.Logger) *Withdrawer {
 	return &Withdrawer{
-		rpcClient: rpcClient,
-		logger:    logger,
+		rpcClient:    rpcClient,
+		logger:       logger,
+		pollInterval: 2 * time.Second,
 	}
 }
 
+// SetFeeOracle enables dynamic fees. When nil, WithdrawFee is used.
+func (w *Withdrawer) SetFeeOracle(oracle *NetworkFeeOracle) {
+	w.feeOracle = oracle
+}
+
+// Fee returns the transaction fee, in shannons, a withdrawal of a wallet
+// holding a single cell currently pays. Wallets with more cells pay a
+// little more per cell.
+func (w *Withdrawer) Fee() uint64 {
+	return w.fee(1, 1)
+}
+
+// fee returns the fee for a withdrawal with the given number of inputs and
+// outputs.
+func (w *Withdrawer) fee(inputs, outputs int) uint64 {
+	return txFee(w.feeOracle, WithdrawFee, inputs, outputs)
+}
+
 // GetSenderAddress finds the sender address from the funding transaction.
-// It looks at the first input of transactions that sent CKB to the wallet.
+// Resolved addresses are cached, so only the first call per wallet hits RPC.
 func (w *Withdrawer) GetSenderAddress(ctx context.Context, walletAddress string, network types.Network) (string, error) {
+	if cached, ok := w.senderCache.Load(walletAddress); ok {
+		return cached.(string), nil
+	}
+
+	senderAddr, err := w.lookupSenderAddress(ctx, walletAddress, network)
+	if err != nil {
+		return "", err
+	}
+	w.senderCache.Store(walletAddress, senderAddr)
+	return senderAddr, nil
+}
+
+// ClearSenderCache forgets all cached sender addresses.
+func (w *Withdrawer) ClearSenderCache() {
+	w.senderCache.Range(func(key, _ interface{}) bool {
+		w.senderCache.Delete(key)
+		return true
+	})
+}
+
+// lookupSenderAddress queries the chain for the wallet's funding sender.
+// It looks at the first input of transactions that sent CKB to the wallet.
+func (w *Withdrawer) lookupSenderAddress(ctx context.Context, walletAddress string, network types.Network) (string, error) {
 	// Decode wallet address to get lock script
 	lockScript, err := decodeAddressToScript(walletAddress)
 	if err != nil {
@@ -114,8 +179,50 @@ func (w *Withdrawer) GetSenderAddress(ctx context.Context, walletAddress string,
 	return "", fmt.Errorf("could not determine sender address")
 }
 
+// walletTxPageSize is the indexer page size used when counting transactions.
+const walletTxPageSize = 100
+
+// GetWalletTransactionCount returns the number of distinct transactions
+// that created or spent cells locked by the wallet address.
+func (w *Withdrawer) GetWalletTransactionCount(ctx context.Context, walletAddress string) (int, error) {
+	lockScript, err := decodeAddressToScript(walletAddress)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode wallet address: %w", err)
+	}
+
+	searchKey := &indexer.SearchKey{
+		Script:           lockScript,
+		ScriptType:       types.ScriptTypeLock,
+		ScriptSearchMode: types.ScriptSearchModeExact,
+	}
+
+	seen := make(map[types.Hash]bool)
+	cursor := ""
+	for {
+		txs, err := w.rpcClient.GetTransactions(ctx, searchKey, indexer.SearchOrderAsc, walletTxPageSize, cursor)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get transactions: %w", err)
+		}
+		// A transaction appears once per matching input and output
+		for _, tx := range txs.Objects {
+			seen[tx.TxHash] = true
+		}
+		if len(txs.Objects) < walletTxPageSize || txs.LastCursor == "" {
+			return len(seen), nil
+		}
+		cursor = txs.LastCursor
+	}
+}
+
 // WithdrawAll sends all remaining CKB from wallet to the destination address.
 func (w *Withdrawer) WithdrawAll(ctx context.Context, privateKey *secp256k1.PrivateKey, fromLockScript *types.Script, toAddress string) (types.Hash, error) {
+	txHash, _, err := w.withdrawAll(ctx, privateKey, fromLockScript, toAddress)
+	return txHash, err
+}
+
+// withdrawAll is WithdrawAll, also returning the capacity of the single
+// output it sends.
+func (w *Withdrawer) withdrawAll(ctx context.Context, privateKey *secp256k1.PrivateKey, fromLockScript *types.Script, toAddress string) (types.Hash, uint64, error) {
 	w.logger.Info("withdrawing all CKB to sender",
 		zap.String("to_address", toAddress),
 	)
@@ -123,10 +230,94 @@ func (w *Withdrawer) WithdrawAll(ctx context.Context, privateKey *secp256k1.Priv
 	// Decode destination address
 	toLockScript, err := decodeAddressToScript(toAddress)
 	if err != nil {
-		return types.Hash{}, fmt.Errorf("failed to decode destination address: %w", err)
+		return types.Hash{}, 0, fmt.Errorf("failed to decode destination address: %w", err)
+	}
+
+	cells, err := w.spendableCells(ctx, fromLockScript)
+	if err != nil {
+		return types.Hash{}, 0, err
+	}
+
+	// Calculate total capacity and build inputs
+	var totalCapacity uint64
+	inputs := make([]*types.CellInput, 0, len(cells))
+	for _, cell := range cells {
+		totalCapacity += cell.Output.Capacity
+		inputs = append(inputs, &types.CellInput{
+			Since:          0,
+			PreviousOutput: cell.OutPoint,
+		})
+
+		w.logger.Debug("adding cell to withdrawal",
+			zap.String("outpoint", fmt.Sprintf("%s:%d", cell.OutPoint.TxHash.Hex(), cell.OutPoint.Index)),
+			zap.Uint64("capacity", cell.Output.Capacity),
+		)
+	}
+
+	fee := w.fee(len(inputs), 1)
+	if totalCapacity <= fee+MinCellCapacity {
+		return types.Hash{}, 0, fmt.Errorf("insufficient balance for withdrawal: %d shannons", totalCapacity)
+	}
+
+	// Calculate output capacity (total - fee)
+	outputCapacity := totalCapacity - fee
+
+	w.logger.Info("withdrawal details",
+		zap.Uint64("total_capacity", totalCapacity),
+		zap.Uint64("output_capacity", outputCapacity),
+		zap.Uint64("fee", fee),
+		zap.Int("input_cells", len(inputs)),
+	)
+
+	// Build transaction
+	secp256k1CellDep := getSecp256k1CellDep()
+
+	tx := &types.Transaction{
+		Version: 0,
+		CellDeps: []*types.CellDep{
+			secp256k1CellDep,
+		},
+		Inputs: inputs,
+		Outputs: []*types.CellOutput{
+			{
+				Capacity: outputCapacity,
+				Lock:     toLockScript,
+				Type:     nil,
+			},
+		},
+		OutputsData: [][]byte{{}},
+		Witnesses:   make([][]byte, len(inputs)),
+	}
+
+	// First witness is the signature, rest are empty
+	tx.Witnesses[0] = make([]byte, 85)
+	for i := 1; i < len(inputs); i++ {
+		tx.Witnesses[i] = []byte{}
+	}
+
+	// Sign the transaction
+	signedTx, err := w.signTransaction(tx, privateKey)
+	if err != nil {
+		return types.Hash{}, 0, fmt.Errorf("failed to sign transaction: %w", err)
+	}
+
+	// Submit transaction
+	txHash, err := w.rpcClient.SendTransaction(ctx, signedTx)
+	if err != nil {
+		return types.Hash{}, 0, fmt.Errorf("failed to send transaction: %w", err)
 	}
 
-	// Get all cells from the wallet
+	w.logger.Info("withdrawal transaction submitted",
+		zap.String("tx_hash", txHash.Hex()),
+		zap.Uint64("amount_ckb", outputCapacity/100000000),
+	)
+
+	return *txHash, outputCapacity, nil
+}
+
+// spendableCells returns the wallet's plain CKB cells: those locked by
+// fromLockScript and carrying no type script.
+func (w *Withdrawer) spendableCells(ctx context.Context, fromLockScript *types.Script) ([]*indexer.LiveCell, error) {
 	searchKey := &indexer.SearchKey{
 		Script:           fromLockScript,
 		ScriptType:       types.ScriptTypeLock,
@@ -136,11 +327,11 @@ func (w *Withdrawer) WithdrawAll(ctx context.Context, privateKey *secp256k1.Priv
 
 	cells, err := w.rpcClient.GetCells(ctx, searchKey, indexer.SearchOrderAsc, 100, "")
 	if err != nil {
-		return types.Hash{}, fmt.Errorf("failed to get cells: %w", err)
+		return nil, fmt.Errorf("failed to get cells: %w", err)
 	}
 
 	if len(cells.Objects) == 0 {
-		return types.Hash{}, fmt.Errorf("no cells found in wallet")
+		return nil, fmt.Errorf("%w: wallet has no cells", ErrNoSpendableCells)
 	}
 
 	w.logger.Info("found cells in wallet",
@@ -148,13 +339,10 @@ func (w *Withdrawer) WithdrawAll(ctx context.Context, privateKey *secp256k1.Priv
 		zap.String("wallet_lock_hash", fromLockScript.Hash().Hex()),
 	)
 
-	// Calculate total capacity and build inputs
-	var totalCapacity uint64
-	inputs := make([]*types.CellInput, 0)
-
 	// Calculate expected lock script hash for verification
 	expectedLockHash := fromLockScript.Hash()
 
+	spendable := make([]*indexer.LiveCell, 0, len(cells.Objects))
 	for _, cell := range cells.Objects {
 		// Only use cells without type scripts (pure CKB cells)
 		if cell.Output.Type != nil {
@@ -174,58 +362,86 @@ func (w *Withdrawer) WithdrawAll(ctx context.Context, privateKey *secp256k1.Priv
 			continue
 		}
 
-		totalCapacity += cell.Output.Capacity
-		inputs = append(inputs, &types.CellInput{
-			Since:          0,
-			PreviousOutput: cell.OutPoint,
-		})
-
-		w.logger.Debug("adding cell to withdrawal",
-			zap.String("outpoint", fmt.Sprintf("%s:%d", cell.OutPoint.TxHash.Hex(), cell.OutPoint.Index)),
-			zap.Uint64("capacity", cell.Output.Capacity),
-		)
+		spendable = append(spendable, cell)
 	}
 
-	if len(inputs) == 0 {
+	if len(spendable) == 0 {
 		// Log what cells were found for debugging
 		w.logger.Warn("no withdrawable cells found - cells may have been consumed by Perun channel",
 			zap.Int("total_cells_found", len(cells.Objects)),
 			zap.String("expected_lock_hash", expectedLockHash.Hex()),
 		)
-		return types.Hash{}, fmt.Errorf("no withdrawable cells found (cells may have been consumed by Perun channel - use manual refund API)")
+		return nil, fmt.Errorf("%w (cells may have been consumed by Perun channel - use manual refund API)", ErrNoSpendableCells)
+	}
+	return spendable, nil
+}
+
+// WithdrawPartial sends amountShannons from the wallet to toAddress and
+// returns the rest, less the fee, to fromLockScript as change. Cells are
+// picked largest first until they cover the amount, the fee and a change
+// cell. Returns ErrInsufficientBalance if the wallet can't cover that.
+func (w *Withdrawer) WithdrawPartial(ctx context.Context, privateKey *secp256k1.PrivateKey, fromLockScript *types.Script, toAddress string, amountShannons uint64) (types.Hash, error) {
+	w.logger.Info("withdrawing partial CKB",
+		zap.String("to_address", toAddress),
+		zap.Uint64("amount_shannons", amountShannons),
+	)
+
+	if amountShannons < MinCellCapacity {
+		return types.Hash{}, fmt.Errorf("amount %d shannons is below the minimum cell capacity %d", amountShannons, MinCellCapacity)
 	}
 
-	if totalCapacity <= WithdrawFee+MinCellCapacity {
-		return types.Hash{}, fmt.Errorf("insufficient balance for withdrawal: %d shannons", totalCapacity)
+	toLockScript, err := decodeAddressToScript(toAddress)
+	if err != nil {
+		return types.Hash{}, fmt.Errorf("failed to decode destination address: %w", err)
 	}
 
-	// Calculate output capacity (total - fee)
-	outputCapacity := totalCapacity - WithdrawFee
+	cells, err := w.spendableCells(ctx, fromLockScript)
+	if err != nil {
+		return types.Hash{}, err
+	}
 
-	w.logger.Info("withdrawal details",
+	// The fee depends on how many cells are picked, so pick again until
+	// the selection covers its own fee
+	fee := w.fee(1, 2)
+	var selected []*indexer.LiveCell
+	var totalCapacity uint64
+	for {
+		var ok bool
+		selected, totalCapacity, ok = selectCells(cells, amountShannons+fee+MinCellCapacity)
+		if !ok {
+			return types.Hash{}, fmt.Errorf("%w: need %d shannons plus fee and change, wallet has %d",
+				ErrInsufficientBalance, amountShannons, totalCapacity)
+		}
+		needed := w.fee(len(selected), 2)
+		if needed <= fee {
+			break
+		}
+		fee = needed
+	}
+	changeCapacity := totalCapacity - amountShannons - fee
+
+	inputs := make([]*types.CellInput, len(selected))
+	for i, cell := range selected {
+		inputs[i] = &types.CellInput{Since: 0, PreviousOutput: cell.OutPoint}
+	}
+
+	w.logger.Info("partial withdrawal details",
 		zap.Uint64("total_capacity", totalCapacity),
-		zap.Uint64("output_capacity", outputCapacity),
-		zap.Uint64("fee", WithdrawFee),
+		zap.Uint64("amount", amountShannons),
+		zap.Uint64("change", changeCapacity),
+		zap.Uint64("fee", fee),
 		zap.Int("input_cells", len(inputs)),
 	)
 
-	// Build transaction
-	secp256k1CellDep := getSecp256k1CellDep()
-
 	tx := &types.Transaction{
-		Version: 0,
-		CellDeps: []*types.CellDep{
-			secp256k1CellDep,
-		},
-		Inputs: inputs,
+		Version:  0,
+		CellDeps: []*types.CellDep{getSecp256k1CellDep()},
+		Inputs:   inputs,
 		Outputs: []*types.CellOutput{
-			{
-				Capacity: outputCapacity,
-				Lock:     toLockScript,
-				Type:     nil,
-			},
+			{Capacity: amountShannons, Lock: toLockScript},
+			{Capacity: changeCapacity, Lock: fromLockScript},
 		},
-		OutputsData: [][]byte{{}},
+		OutputsData: [][]byte{{}, {}},
 		Witnesses:   make([][]byte, len(inputs)),
 	}
 
@@ -235,48 +451,160 @@ func (w *Withdrawer) WithdrawAll(ctx context.Context, privateKey *secp256k1.Priv
 		tx.Witnesses[i] = []byte{}
 	}
 
-	// Sign the transaction
 	signedTx, err := w.signTransaction(tx, privateKey)
 	if err != nil {
 		return types.Hash{}, fmt.Errorf("failed to sign transaction: %w", err)
 	}
 
-	// Submit transaction
 	txHash, err := w.rpcClient.SendTransaction(ctx, signedTx)
 	if err != nil {
 		return types.Hash{}, fmt.Errorf("failed to send transaction: %w", err)
 	}
 
-	w.logger.Info("withdrawal transaction submitted",
+	w.logger.Info("partial withdrawal transaction submitted",
 		zap.String("tx_hash", txHash.Hex()),
-		zap.Uint64("amount_ckb", outputCapacity/100000000),
+		zap.Uint64("amount_ckb", amountShannons/100000000),
 	)
 
 	return *txHash, nil
 }
 
+// selectCells picks cells largest first until their capacity reaches need,
+// so as few inputs as possible are spent. It returns the chosen cells and
+// their total, or every cell's total and false if need can't be reached.
+func selectCells(cells []*indexer.LiveCell, need uint64) ([]*indexer.LiveCell, uint64, bool) {
+	sorted := append([]*indexer.LiveCell(nil), cells...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Output.Capacity > sorted[j].Output.Capacity
+	})
+
+	var total uint64
+	for i, cell := range sorted {
+		total += cell.Output.Capacity
+		if total >= need {
+			return sorted[:i+1], total, true
+		}
+	}
+	return nil, total, false
+}
+
+// WithdrawAllAndWait withdraws all CKB and waits until the transaction is
+// buried under the given number of blocks. Zero uses DefaultWithdrawConfirmations.
+// If the transaction was submitted but not confirmed, its hash is returned
+// along with the error so the caller does not resubmit. A confirmed
+// transaction whose output differs from the one sent returns
+// ErrSplitVerificationFailed.
+func (w *Withdrawer) WithdrawAllAndWait(ctx context.Context, privateKey *secp256k1.PrivateKey, fromLockScript *types.Script, toAddress string, confirmations uint) (types.Hash, error) {
+	if confirmations == 0 {
+		confirmations = DefaultWithdrawConfirmations
+	}
+
+	txHash, outputCapacity, err := w.withdrawAll(ctx, privateKey, fromLockScript, toAddress)
+	if err != nil {
+		return types.Hash{}, err
+	}
+
+	if err := w.waitForConfirmations(ctx, txHash, confirmations); err != nil {
+		return txHash, fmt.Errorf("withdrawal %s not confirmed: %w", txHash.Hex(), err)
+	}
+	if err := verifyTxOutputs(ctx, w.rpcClient, txHash, []uint64{outputCapacity}); err != nil {
+		return txHash, err
+	}
+
+	w.logger.Info("withdrawal confirmed",
+		zap.String("tx_hash", txHash.Hex()),
+		zap.Uint("confirmations", confirmations),
+	)
+
+	return txHash, nil
+}
+
+// waitForConfirmations polls until the transaction is committed and the tip
+// is at least confirmations blocks past it, counting the including block.
+func (w *Withdrawer) waitForConfirmations(ctx context.Context, txHash types.Hash, confirmations uint) error {
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+
+	timeout := time.After(withdrawConfirmationTimeout)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeout:
+			return fmt.Errorf("timeout waiting for %d confirmations", confirmations)
+		case <-ticker.C:
+			depth, err := w.confirmationDepth(ctx, txHash)
+			if err != nil {
+				return err
+			}
+			if depth >= uint64(confirmations) {
+				return nil
+			}
+		}
+	}
+}
+
+// confirmationDepth returns how many blocks deep the transaction is,
+// or 0 if it is not yet committed. RPC errors are treated as not yet
+// confirmed; only a rejected transaction returns an error.
+func (w *Withdrawer) confirmationDepth(ctx context.Context, txHash types.Hash) (uint64, error) {
+	txWithStatus, err := w.rpcClient.GetTransaction(ctx, txHash)
+	if err != nil || txWithStatus == nil || txWithStatus.TxStatus == nil {
+		return 0, nil
+	}
+
+	switch txWithStatus.TxStatus.Status {
+	case types.TransactionStatusRejected:
+		return 0, fmt.Errorf("transaction rejected: %v", txWithStatus.TxStatus.Reason)
+	case types.TransactionStatusCommitted:
+	default:
+		return 0, nil
+	}
+	if txWithStatus.TxStatus.BlockHash == nil {
+		return 0, nil
+	}
+
+	header, err := w.rpcClient.GetHeader(ctx, *txWithStatus.TxStatus.BlockHash)
+	if err != nil {
+		return 0, nil
+	}
+	tip, err := w.rpcClient.GetTipBlockNumber(ctx)
+	if err != nil || tip < header.Number {
+		return 0, nil
+	}
+
+	return tip - header.Number + 1, nil
+}
+
 // signTransaction signs a transaction with the given private key.
 // For multiple inputs in the same lock group, the signature message must include ALL witnesses.
 func (w *Withdrawer) signTransaction(tx *types.Transaction, privateKey *secp256k1.PrivateKey) (*types.Transaction, error) {
+	signLockGroup(tx, 0, len(tx.Inputs), privateKey)
+	return tx, nil
+}
+
+// signLockGroup signs the inputs tx.Inputs[start:start+count], which share
+// one secp256k1 lock, putting the signature in the group's first witness.
+// Placeholders must already be set in every group's first witness.
+func signLockGroup(tx *types.Transaction, start, count int, privateKey *secp256k1.PrivateKey) {
 	// Create empty witness for placeholder
 	witnessArgs := &types.WitnessArgs{
 		Lock: make([]byte, 65), // 65 bytes for signature
 	}
-	witnessBytes := witnessArgs.Serialize()
-
-	// Set witness placeholder before computing hash
-	tx.Witnesses[0] = witnessBytes
+	tx.Witnesses[start] = witnessArgs.Serialize()
 
 	// Calculate transaction hash
 	txHash := tx.ComputeHash()
 
-	// Calculate message to sign: tx_hash + len(witness0) + witness0 + len(witness1) + witness1 + ...
-	// For multiple inputs in the same lock group, ALL witnesses must be included
+	// Calculate message to sign: tx_hash + len(witness) + witness for every
+	// witness in the group, then for every witness past the inputs
 	message := make([]byte, 32)
 	copy(message[:32], txHash[:])
 
-	// Add all witnesses to the message
-	for _, witness := range tx.Witnesses {
+	witnesses := tx.Witnesses[start : start+count]
+	witnesses = append(witnesses[:count:count], tx.Witnesses[len(tx.Inputs):]...)
+	for _, witness := range witnesses {
 		lenBytes := make([]byte, 8)
 		binary.LittleEndian.PutUint64(lenBytes, uint64(len(witness)))
 		message = append(message, lenBytes...)
@@ -286,14 +614,9 @@ func (w *Withdrawer) signTransaction(tx *types.Transaction, privateKey *secp256k
 	// Hash the message using blake2b
 	messageHash := blake2b.Blake256(message)
 
-	// Sign with secp256k1
-	sig := signWithKey(messageHash, privateKey)
-
-	// Update witness with signature
-	witnessArgs.Lock = sig
-	tx.Witnesses[0] = witnessArgs.Serialize()
-
-	return tx, nil
+	// Sign with secp256k1 and update the witness with the signature
+	witnessArgs.Lock = signWithKey(messageHash, privateKey)
+	tx.Witnesses[start] = witnessArgs.Serialize()
 }
 
 // decodeAddressToScript converts a CKB address string to a lock script.
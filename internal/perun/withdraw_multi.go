@@ -0,0 +1,126 @@
+package perun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
+	"go.uber.org/zap"
+)
+
+// WalletKey is a wallet to withdraw from: its key and the lock script of
+// its cells.
+type WalletKey struct {
+	PrivateKey *secp256k1.PrivateKey
+	LockScript *types.Script
+}
+
+// WithdrawFromMultipleWallets sends all CKB from every wallet to toAddress
+// in a single transaction, paying one fee instead of one per wallet. Each
+// wallet's inputs form their own lock group, signed with that wallet's key.
+// Wallets without spendable cells are skipped; it fails only if all are
+// empty. Otherwise every other wallet is emptied or none is.
+func (w *Withdrawer) WithdrawFromMultipleWallets(ctx context.Context, wallets []WalletKey, toAddress string) (types.Hash, error) {
+	w.logger.Info("withdrawing all CKB from multiple wallets",
+		zap.String("to_address", toAddress),
+		zap.Int("wallets", len(wallets)),
+	)
+
+	if len(wallets) == 0 {
+		return types.Hash{}, fmt.Errorf("no wallets to withdraw from")
+	}
+
+	toLockScript, err := decodeAddressToScript(toAddress)
+	if err != nil {
+		return types.Hash{}, fmt.Errorf("failed to decode destination address: %w", err)
+	}
+
+	// Inputs are laid out wallet by wallet, so each lock group is contiguous
+	type lockGroup struct {
+		start, count int
+		privateKey   *secp256k1.PrivateKey
+	}
+	var (
+		inputs        []*types.CellInput
+		groups        []lockGroup
+		totalCapacity uint64
+	)
+	seen := make(map[types.Hash]bool, len(wallets))
+	for i, wallet := range wallets {
+		lockHash := wallet.LockScript.Hash()
+		if seen[lockHash] {
+			return types.Hash{}, fmt.Errorf("wallet %d: lock script %s is listed twice", i, lockHash.Hex())
+		}
+		seen[lockHash] = true
+
+		cells, err := w.spendableCells(ctx, wallet.LockScript)
+		if errors.Is(err, ErrNoSpendableCells) {
+			w.logger.Info("skipping wallet without spendable cells",
+				zap.Int("wallet", i),
+				zap.String("wallet_lock_hash", lockHash.Hex()),
+			)
+			continue
+		}
+		if err != nil {
+			return types.Hash{}, fmt.Errorf("wallet %d: %w", i, err)
+		}
+		groups = append(groups, lockGroup{start: len(inputs), count: len(cells), privateKey: wallet.PrivateKey})
+		for _, cell := range cells {
+			totalCapacity += cell.Output.Capacity
+			inputs = append(inputs, &types.CellInput{Since: 0, PreviousOutput: cell.OutPoint})
+		}
+	}
+
+	if len(inputs) == 0 {
+		return types.Hash{}, fmt.Errorf("%w in any of %d wallets", ErrNoSpendableCells, len(wallets))
+	}
+
+	fee := w.fee(len(inputs), 1)
+	if totalCapacity <= fee+MinCellCapacity {
+		return types.Hash{}, fmt.Errorf("%w for withdrawal: %d shannons", ErrInsufficientBalance, totalCapacity)
+	}
+	outputCapacity := totalCapacity - fee
+
+	w.logger.Info("multi-wallet withdrawal details",
+		zap.Uint64("total_capacity", totalCapacity),
+		zap.Uint64("output_capacity", outputCapacity),
+		zap.Uint64("fee", fee),
+		zap.Int("input_cells", len(inputs)),
+	)
+
+	tx := &types.Transaction{
+		Version:  0,
+		CellDeps: []*types.CellDep{getSecp256k1CellDep()},
+		Inputs:   inputs,
+		Outputs: []*types.CellOutput{
+			{Capacity: outputCapacity, Lock: toLockScript},
+		},
+		OutputsData: [][]byte{{}},
+		Witnesses:   make([][]byte, len(inputs)),
+	}
+
+	// Each group's first witness holds its signature, the rest are empty
+	for i := range tx.Witnesses {
+		tx.Witnesses[i] = []byte{}
+	}
+	for _, g := range groups {
+		tx.Witnesses[g.start] = make([]byte, 85)
+	}
+	for _, g := range groups {
+		signLockGroup(tx, g.start, g.count, g.privateKey)
+	}
+
+	txHash, err := w.rpcClient.SendTransaction(ctx, tx)
+	if err != nil {
+		return types.Hash{}, fmt.Errorf("failed to send transaction: %w", err)
+	}
+
+	w.logger.Info("multi-wallet withdrawal transaction submitted",
+		zap.String("tx_hash", txHash.Hex()),
+		zap.Uint64("amount_ckb", outputCapacity/100000000),
+	)
+
+	return *txHash, nil
+}
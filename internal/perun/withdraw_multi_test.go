@@ -0,0 +1,162 @@
+package perun
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/crypto/blake2b"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/indexer"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
+)
+
+// newTestWallet returns a wallet key whose lock args are its index, with
+// cells of the given capacities.
+func newTestWallet(t *testing.T, index byte, capacities ...uint64) (WalletKey, []*indexer.LiveCell) {
+	t.Helper()
+	privKey, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	lock := &types.Script{
+		CodeHash: types.HexToHash("0x9bd7e06f3ecf4be0f2fcd2188b23f1b9fcc88e5d4b65a8637b17723bbda3cce8"),
+		HashType: types.HashTypeType,
+		Args:     append(make([]byte, 19), index),
+	}
+	var cells []*indexer.LiveCell
+	for _, capacity := range capacities {
+		cell := newTestCell(capacity, uint32(index)*10+uint32(len(cells)))
+		cell.Output.Lock = lock
+		cells = append(cells, cell)
+	}
+	return WalletKey{PrivateKey: privKey, LockScript: lock}, cells
+}
+
+// recoverGroupSigner returns the public key that signed the lock group
+// starting at input start with count inputs.
+func recoverGroupSigner(t *testing.T, tx *types.Transaction, start, count int) *secp256k1.PublicKey {
+	t.Helper()
+	witnessArgs, err := types.DeserializeWitnessArgs(tx.Witnesses[start])
+	if err != nil {
+		t.Fatalf("failed to parse witness %d: %v", start, err)
+	}
+	sig := witnessArgs.Lock
+
+	txHash := tx.ComputeHash()
+	message := append([]byte(nil), txHash[:]...)
+	for i := start; i < start+count; i++ {
+		witness := tx.Witnesses[i]
+		if i == start {
+			witness = (&types.WitnessArgs{Lock: make([]byte, 65)}).Serialize()
+		}
+		message = binary.LittleEndian.AppendUint64(message, uint64(len(witness)))
+		message = append(message, witness...)
+	}
+
+	// [R || S || V] back to dcrd's [V+27 || R || S]
+	compact := append([]byte{sig[64] + 27}, sig[:64]...)
+	pub, _, err := ecdsa.RecoverCompact(compact, blake2b.Blake256(message))
+	if err != nil {
+		t.Fatalf("failed to recover signer of group at %d: %v", start, err)
+	}
+	return pub
+}
+
+func TestWithdrawer_WithdrawFromMultipleWallets(t *testing.T) {
+	rpcClient := &mockRPCClient{}
+	w, _, _, toAddress := newTestWithdrawer(t, rpcClient)
+
+	walletA, cellsA := newTestWallet(t, 1, 70*100000000)
+	walletB, cellsB := newTestWallet(t, 2, 30*100000000, 20*100000000)
+	walletC, cellsC := newTestWallet(t, 3, 15*100000000)
+	rpcClient.cells = append(append(append([]*indexer.LiveCell(nil), cellsA...), cellsB...), cellsC...)
+
+	txHash, err := w.WithdrawFromMultipleWallets(context.Background(), []WalletKey{walletA, walletB, walletC}, toAddress)
+	if err != nil {
+		t.Fatalf("WithdrawFromMultipleWallets failed: %v", err)
+	}
+	if rpcClient.sentTxCount != 1 {
+		t.Fatalf("Expected 1 transaction sent, got %d", rpcClient.sentTxCount)
+	}
+
+	tx := rpcClient.txs[txHash]
+	if len(tx.Inputs) != 4 || len(tx.Outputs) != 1 {
+		t.Fatalf("Expected 4 inputs and 1 output, got %d and %d", len(tx.Inputs), len(tx.Outputs))
+	}
+	if want := uint64(135*100000000) - WithdrawFee; tx.Outputs[0].Capacity != want {
+		t.Errorf("Expected output of %d shannons after one fee, got %d", want, tx.Outputs[0].Capacity)
+	}
+
+	// Each wallet's group is signed by its own key; B's second witness is empty
+	for _, g := range []struct {
+		start, count int
+		wallet       WalletKey
+	}{{0, 1, walletA}, {1, 2, walletB}, {3, 1, walletC}} {
+		if !recoverGroupSigner(t, tx, g.start, g.count).IsEqual(g.wallet.PrivateKey.PubKey()) {
+			t.Errorf("Group at input %d not signed by its wallet's key", g.start)
+		}
+	}
+	if len(tx.Witnesses[2]) != 0 {
+		t.Errorf("Expected empty witness for the second input of a group, got %d bytes", len(tx.Witnesses[2]))
+	}
+}
+
+func TestWithdrawer_WithdrawFromMultipleWallets_Errors(t *testing.T) {
+	rpcClient := &mockRPCClient{}
+	w, _, _, toAddress := newTestWithdrawer(t, rpcClient)
+
+	walletA, cellsA := newTestWallet(t, 1, 30*100000000)
+	walletB, _ := newTestWallet(t, 2)
+	rpcClient.cells = cellsA
+
+	if _, err := w.WithdrawFromMultipleWallets(context.Background(), nil, toAddress); err == nil {
+		t.Error("Expected error without wallets")
+	}
+	if _, err := w.WithdrawFromMultipleWallets(context.Background(), []WalletKey{walletA, walletA}, toAddress); err == nil {
+		t.Error("Expected error for a wallet listed twice")
+	}
+	if _, err := w.WithdrawFromMultipleWallets(context.Background(), []WalletKey{walletB}, toAddress); !errors.Is(err, ErrNoSpendableCells) {
+		t.Errorf("Expected ErrNoSpendableCells when every wallet is empty, got %v", err)
+	}
+	// 30 CKB can't cover the fee and a minimum cell
+	if _, err := w.WithdrawFromMultipleWallets(context.Background(), []WalletKey{walletA}, toAddress); !errors.Is(err, ErrInsufficientBalance) {
+		t.Errorf("Expected ErrInsufficientBalance, got %v", err)
+	}
+	if rpcClient.sentTxCount != 0 {
+		t.Errorf("Expected no transaction sent, got %d", rpcClient.sentTxCount)
+	}
+}
+
+func TestWithdrawer_WithdrawFromMultipleWallets_SkipsEmptyWallets(t *testing.T) {
+	rpcClient := &mockRPCClient{}
+	w, _, _, toAddress := newTestWithdrawer(t, rpcClient)
+
+	walletA, cellsA := newTestWallet(t, 1, 70*100000000)
+	walletB, _ := newTestWallet(t, 2)
+	walletC, cellsC := newTestWallet(t, 3, 30*100000000)
+	rpcClient.cells = append(append([]*indexer.LiveCell(nil), cellsA...), cellsC...)
+
+	txHash, err := w.WithdrawFromMultipleWallets(context.Background(), []WalletKey{walletA, walletB, walletC}, toAddress)
+	if err != nil {
+		t.Fatalf("Expected the empty wallet skipped, got %v", err)
+	}
+
+	tx := rpcClient.txs[txHash]
+	if len(tx.Inputs) != 2 {
+		t.Fatalf("Expected 2 inputs, got %d", len(tx.Inputs))
+	}
+	if want := uint64(100*100000000) - WithdrawFee; tx.Outputs[0].Capacity != want {
+		t.Errorf("Expected output of %d shannons, got %d", want, tx.Outputs[0].Capacity)
+	}
+	for _, g := range []struct {
+		start  int
+		wallet WalletKey
+	}{{0, walletA}, {1, walletC}} {
+		if !recoverGroupSigner(t, tx, g.start, 1).IsEqual(g.wallet.PrivateKey.PubKey()) {
+			t.Errorf("Group at input %d not signed by its wallet's key", g.start)
+		}
+	}
+}
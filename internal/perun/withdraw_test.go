@@ -0,0 +1,235 @@
+package perun
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/indexer"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
+	"go.uber.org/zap"
+)
+
+// newTestWithdrawer returns a withdrawer over a wallet holding one 500 CKB cell.
+func newTestWithdrawer(t *testing.T, rpcClient *mockRPCClient) (*Withdrawer, *secp256k1.PrivateKey, *types.Script, string) {
+	t.Helper()
+
+	privKey, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	fromLock := &types.Script{
+		CodeHash: types.HexToHash("0x9bd7e06f3ecf4be0f2fcd2188b23f1b9fcc88e5d4b65a8637b17723bbda3cce8"),
+		HashType: types.HashTypeType,
+		Args:     make([]byte, 20),
+	}
+	toLock := &types.Script{
+		CodeHash: fromLock.CodeHash,
+		HashType: types.HashTypeType,
+		Args:     []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20},
+	}
+	toAddress, err := scriptToAddress(toLock, types.NetworkTest)
+	if err != nil {
+		t.Fatalf("failed to encode address: %v", err)
+	}
+
+	cell := newTestCell(500*100000000, 0)
+	cell.Output.Lock = fromLock
+	rpcClient.cells = []*indexer.LiveCell{cell}
+
+	w := NewWithdrawer(rpcClient, zap.NewNop())
+	w.pollInterval = time.Millisecond
+	return w, privKey, fromLock, toAddress
+}
+
+func TestWithdrawer_WithdrawAllAndWait(t *testing.T) {
+	rpcClient := &mockRPCClient{
+		txStatus: types.TransactionStatusCommitted,
+		txBlock:  100,
+		tip:      100,
+	}
+	w, privKey, fromLock, toAddress := newTestWithdrawer(t, rpcClient)
+
+	txHash, err := w.WithdrawAllAndWait(context.Background(), privKey, fromLock, toAddress, 3)
+	if err != nil {
+		t.Fatalf("WithdrawAllAndWait failed: %v", err)
+	}
+	if txHash == (types.Hash{}) {
+		t.Error("Expected non-zero tx hash")
+	}
+	if rpcClient.sentTxCount != 1 {
+		t.Errorf("Expected 1 transaction sent, got %d", rpcClient.sentTxCount)
+	}
+	// Tips 100, 101, 102 give depths 1, 2, 3
+	if rpcClient.tipCalls != 3 {
+		t.Errorf("Expected 3 tip polls, got %d", rpcClient.tipCalls)
+	}
+}
+
+func TestWithdrawer_WithdrawAllAndWait_Rejected(t *testing.T) {
+	rpcClient := &mockRPCClient{txStatus: types.TransactionStatusRejected}
+	w, privKey, fromLock, toAddress := newTestWithdrawer(t, rpcClient)
+
+	txHash, err := w.WithdrawAllAndWait(context.Background(), privKey, fromLock, toAddress, 3)
+	if err == nil {
+		t.Fatal("Expected error for rejected transaction")
+	}
+	if txHash == (types.Hash{}) {
+		t.Error("Expected submitted tx hash alongside the error")
+	}
+}
+
+func TestWithdrawer_WithdrawAllAndWait_ContextCancelled(t *testing.T) {
+	rpcClient := &mockRPCClient{txStatus: types.TransactionStatusPending}
+	w, privKey, fromLock, toAddress := newTestWithdrawer(t, rpcClient)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := w.WithdrawAllAndWait(ctx, privKey, fromLock, toAddress, 3); err == nil {
+		t.Fatal("Expected error when context is cancelled before confirmation")
+	}
+	if rpcClient.tipCalls != 0 {
+		t.Errorf("Pending transaction should not query tip, got %d calls", rpcClient.tipCalls)
+	}
+}
+
+func TestWithdrawer_WithdrawPartial(t *testing.T) {
+	rpcClient := &mockRPCClient{}
+	w, privKey, fromLock, toAddress := newTestWithdrawer(t, rpcClient)
+
+	if _, err := w.WithdrawPartial(context.Background(), privKey, fromLock, toAddress, 200*100000000); err != nil {
+		t.Fatalf("WithdrawPartial failed: %v", err)
+	}
+	if rpcClient.sentTxCount != 1 {
+		t.Errorf("Expected 1 transaction sent, got %d", rpcClient.sentTxCount)
+	}
+}
+
+func TestWithdrawer_WithdrawPartial_InsufficientBalance(t *testing.T) {
+	rpcClient := &mockRPCClient{}
+	w, privKey, fromLock, toAddress := newTestWithdrawer(t, rpcClient)
+
+	// 500 CKB can't cover 450 CKB plus a 61 CKB change cell
+	_, err := w.WithdrawPartial(context.Background(), privKey, fromLock, toAddress, 450*100000000)
+	if !errors.Is(err, ErrInsufficientBalance) {
+		t.Fatalf("Expected ErrInsufficientBalance, got %v", err)
+	}
+	if rpcClient.sentTxCount != 0 {
+		t.Errorf("Expected no transaction sent, got %d", rpcClient.sentTxCount)
+	}
+}
+
+func TestSelectCells(t *testing.T) {
+	cells := []*indexer.LiveCell{
+		newTestCell(100, 0),
+		newTestCell(300, 1),
+		newTestCell(200, 2),
+	}
+
+	selected, total, ok := selectCells(cells, 450)
+	if !ok {
+		t.Fatal("Expected selection to succeed")
+	}
+	if len(selected) != 2 || total != 500 {
+		t.Errorf("Expected the 300 and 200 cells (500 total), got %d cells totalling %d", len(selected), total)
+	}
+
+	if _, total, ok := selectCells(cells, 700); ok || total != 600 {
+		t.Errorf("Expected failure with 600 total, got ok=%v total=%d", ok, total)
+	}
+}
+
+func TestWithdrawer_GetSenderAddress_Cached(t *testing.T) {
+	codeHash := types.HexToHash("0x9bd7e06f3ecf4be0f2fcd2188b23f1b9fcc88e5d4b65a8637b17723bbda3cce8")
+	walletLock := &types.Script{CodeHash: codeHash, HashType: types.HashTypeType, Args: make([]byte, 20)}
+	senderLock := &types.Script{CodeHash: codeHash, HashType: types.HashTypeType, Args: []byte{7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7}}
+	walletAddress, _ := scriptToAddress(walletLock, types.NetworkTest)
+	senderAddress, _ := scriptToAddress(senderLock, types.NetworkTest)
+
+	// The sender's cell is spent by the funding transaction into the wallet.
+	senderTxHash := types.HexToHash("0x0a")
+	fundingTxHash := types.HexToHash("0x0b")
+	rpcClient := &mockRPCClient{
+		walletTxs: []types.Hash{fundingTxHash},
+		txs: map[types.Hash]*types.Transaction{
+			senderTxHash: {Outputs: []*types.CellOutput{{Capacity: 1000, Lock: senderLock}}},
+			fundingTxHash: {
+				Inputs:  []*types.CellInput{{PreviousOutput: &types.OutPoint{TxHash: senderTxHash, Index: 0}}},
+				Outputs: []*types.CellOutput{{Capacity: 900, Lock: walletLock}},
+			},
+		},
+	}
+	w := NewWithdrawer(rpcClient, zap.NewNop())
+
+	for i := 0; i < 3; i++ {
+		got, err := w.GetSenderAddress(context.Background(), walletAddress, types.NetworkTest)
+		if err != nil {
+			t.Fatalf("GetSenderAddress failed: %v", err)
+		}
+		if got != senderAddress {
+			t.Errorf("expected %s, got %s", senderAddress, got)
+		}
+	}
+	if rpcClient.getTxsCalls != 1 {
+		t.Errorf("expected 1 RPC lookup, got %d", rpcClient.getTxsCalls)
+	}
+
+	w.ClearSenderCache()
+	if _, err := w.GetSenderAddress(context.Background(), walletAddress, types.NetworkTest); err != nil {
+		t.Fatalf("GetSenderAddress after clear failed: %v", err)
+	}
+	if rpcClient.getTxsCalls != 2 {
+		t.Errorf("expected lookup after clearing cache, got %d calls", rpcClient.getTxsCalls)
+	}
+}
+
+func TestWithdrawer_GetSenderAddress_ErrorNotCached(t *testing.T) {
+	walletLock := &types.Script{
+		CodeHash: types.HexToHash("0x9bd7e06f3ecf4be0f2fcd2188b23f1b9fcc88e5d4b65a8637b17723bbda3cce8"),
+		HashType: types.HashTypeType,
+		Args:     make([]byte, 20),
+	}
+	walletAddress, _ := scriptToAddress(walletLock, types.NetworkTest)
+	rpcClient := &mockRPCClient{}
+	w := NewWithdrawer(rpcClient, zap.NewNop())
+
+	for i := 0; i < 2; i++ {
+		if _, err := w.GetSenderAddress(context.Background(), walletAddress, types.NetworkTest); err == nil {
+			t.Fatal("expected error for wallet without transactions")
+		}
+	}
+	if rpcClient.getTxsCalls != 2 {
+		t.Errorf("failed lookups must not be cached: expected 2 calls, got %d", rpcClient.getTxsCalls)
+	}
+}
+
+func TestWithdrawer_GetWalletTransactionCount(t *testing.T) {
+	walletLock := &types.Script{
+		CodeHash: types.HexToHash("0x9bd7e06f3ecf4be0f2fcd2188b23f1b9fcc88e5d4b65a8637b17723bbda3cce8"),
+		HashType: types.HashTypeType,
+		Args:     make([]byte, 20),
+	}
+	walletAddress, _ := scriptToAddress(walletLock, types.NetworkTest)
+
+	// The same transaction is listed once per matching cell
+	rpcClient := &mockRPCClient{
+		walletTxs: []types.Hash{types.HexToHash("0x0a"), types.HexToHash("0x0b"), types.HexToHash("0x0a")},
+	}
+	w := NewWithdrawer(rpcClient, zap.NewNop())
+
+	count, err := w.GetWalletTransactionCount(context.Background(), walletAddress)
+	if err != nil {
+		t.Fatalf("GetWalletTransactionCount failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 transactions, got %d", count)
+	}
+
+	empty, err := NewWithdrawer(&mockRPCClient{}, zap.NewNop()).GetWalletTransactionCount(context.Background(), walletAddress)
+	if err != nil || empty != 0 {
+		t.Errorf("expected 0 transactions, got %d (%v)", empty, err)
+	}
+}
@@ -3,13 +3,18 @@ package router
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
 )
 
 // OpenWrtConfig holds the configuration for OpenWrt router with OpenNDS.
@@ -20,13 +25,29 @@ type OpenWrtConfig struct {
 	Password    string // SSH password
 	PrivateKey  string // SSH private key (alternative to password)
 	AuthTimeout int    // Session timeout in seconds (0 = use OpenNDS default)
+
+	Auth           []ssh.AuthMethod // SSH authentication, used instead of Password and PrivateKey if set
+	KnownHostsPath string           // known_hosts file to verify the router against (default: accept any)
+
+	PoolSize          int           // Persistent SSH connections (default: 3)
+	HeartbeatInterval time.Duration // Idle time before a connection is re-checked (default: 30s)
+
+	Name            string          // Access point name in the topology (default: "main")
+	SignalThreshold int             // Minimum client signal in dBm, reported in the topology
+	AccessPoints    []OpenWrtConfig // Additional access points, each with its own SSH target
 }
 
 // OpenWrtClient handles communication with OpenWrt router running OpenNDS.
 type OpenWrtClient struct {
 	config    OpenWrtConfig
 	sshConfig *ssh.ClientConfig
+	pool      *sshPool
 	logger    *zap.Logger
+	aps       []*OpenWrtClient // additional access points, in config order
+
+	authMu       sync.Mutex
+	authorizedOn map[string]*OpenWrtClient // normalized MAC to the router that authorized it
+	macLocks     sync.Map                  // normalized MAC -> *sync.Mutex
 }
 
 // NewOpenWrtClient creates a new OpenWrt/OpenNDS client.
@@ -34,14 +55,17 @@ func NewOpenWrtClient(config OpenWrtConfig, logger *zap.Logger) (*OpenWrtClient,
 	if config.Port == 0 {
 		config.Port = 22
 	}
+	if config.Name == "" {
+		config.Name = "main"
+	}
 
-	var authMethods []ssh.AuthMethod
+	authMethods := config.Auth
 
-	if config.Password != "" {
+	if len(authMethods) == 0 && config.Password != "" {
 		authMethods = append(authMethods, ssh.Password(config.Password))
 	}
 
-	if config.PrivateKey != "" {
+	if len(config.Auth) == 0 && config.PrivateKey != "" {
 		signer, err := ssh.ParsePrivateKey([]byte(config.PrivateKey))
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse private key: %w", err)
@@ -53,22 +77,155 @@ func NewOpenWrtClient(config OpenWrtConfig, logger *zap.Logger) (*OpenWrtClient,
 		return nil, fmt.Errorf("no authentication method provided (password or private key required)")
 	}
 
+	hostKeyCallback := ssh.InsecureIgnoreHostKey()
+	if config.KnownHostsPath != "" {
+		var err error
+		hostKeyCallback, err = knownhosts.New(config.KnownHostsPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load known hosts: %w", err)
+		}
+	}
+
 	sshConfig := &ssh.ClientConfig{
 		User:            config.Username,
 		Auth:            authMethods,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // For simplicity; use known_hosts in production
+		HostKeyCallback: hostKeyCallback,
 		Timeout:         10 * time.Second,
 	}
 
-	return &OpenWrtClient{
-		config:    config,
-		sshConfig: sshConfig,
-		logger:    logger,
-	}, nil
+	c := &OpenWrtClient{
+		config:       config,
+		sshConfig:    sshConfig,
+		logger:       logger,
+		authorizedOn: make(map[string]*OpenWrtClient),
+	}
+	c.pool = newSSHPool(c.dial, config.PoolSize, config.HeartbeatInterval)
+
+	for i, apConfig := range config.AccessPoints {
+		apConfig = accessPointConfig(config, apConfig, i)
+		ap, err := NewOpenWrtClient(apConfig, logger.With(zap.String("ap", apConfig.Name)))
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("access point %d: %w", i+1, err)
+		}
+		c.aps = append(c.aps, ap)
+	}
+	return c, nil
+}
+
+// accessPointConfig fills unset access point settings from the main router's.
+func accessPointConfig(main, ap OpenWrtConfig, index int) OpenWrtConfig {
+	if ap.Name == "" {
+		ap.Name = fmt.Sprintf("ap%d", index+1)
+	}
+	if ap.Username == "" {
+		ap.Username = main.Username
+	}
+	if ap.Password == "" && ap.PrivateKey == "" && len(ap.Auth) == 0 {
+		ap.Password = main.Password
+		ap.PrivateKey = main.PrivateKey
+		ap.Auth = main.Auth
+	}
+	if ap.KnownHostsPath == "" {
+		ap.KnownHostsPath = main.KnownHostsPath
+	}
+	if ap.AuthTimeout == 0 {
+		ap.AuthTimeout = main.AuthTimeout
+	}
+	if ap.PoolSize == 0 {
+		ap.PoolSize = main.PoolSize
+	}
+	if ap.HeartbeatInterval == 0 {
+		ap.HeartbeatInterval = main.HeartbeatInterval
+	}
+	if ap.SignalThreshold == 0 {
+		ap.SignalThreshold = main.SignalThreshold
+	}
+	ap.AccessPoints = nil
+	return ap
+}
+
+// Stats returns the SSH connection pool counts.
+func (c *OpenWrtClient) Stats() PoolStats {
+	return c.pool.stats()
+}
+
+// Close closes the pooled SSH connections, including the access points'.
+func (c *OpenWrtClient) Close() error {
+	err := c.pool.close()
+	for _, ap := range c.aps {
+		if apErr := ap.Close(); err == nil {
+			err = apErr
+		}
+	}
+	return err
+}
+
+// dial opens a new SSH connection to the router. Connecting and the SSH
+// handshake are bounded by both ctx and the configured timeout.
+func (c *OpenWrtClient) dial(ctx context.Context) (*ssh.Client, error) {
+	addr := net.JoinHostPort(c.config.Address, fmt.Sprintf("%d", c.config.Port))
+	dialer := net.Dialer{Timeout: c.sshConfig.Timeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errDial, err)
+	}
+
+	deadline := time.Now().Add(c.sshConfig.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	netConn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { netConn.Close() })
+	sshConn, chans, reqs, err := ssh.NewClientConn(netConn, addr, c.sshConfig)
+	if !stop() {
+		if err == nil {
+			sshConn.Close()
+		}
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		netConn.Close()
+		return nil, fmt.Errorf("%w: %w", errDial, err)
+	}
+	netConn.SetDeadline(time.Time{})
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
+
+// macLock returns the mutex serializing authorization changes for mac.
+func (c *OpenWrtClient) macLock(mac string) *sync.Mutex {
+	mu, _ := c.macLocks.LoadOrStore(mac, &sync.Mutex{})
+	return mu.(*sync.Mutex)
 }
 
 // AuthorizeMAC allows a MAC address to access the internet via OpenNDS.
-func (c *OpenWrtClient) AuthorizeMAC(ctx context.Context, macAddress, ipAddress, comment string) error {
+// With a preferredAP, the OpenNDS instance on that access point is used.
+// The access point is remembered so DeauthorizeMAC revokes access there.
+func (c *OpenWrtClient) AuthorizeMAC(ctx context.Context, macAddress, ipAddress, comment, preferredAP string) error {
+	mu := c.macLock(normalizeMACAddress(macAddress))
+	mu.Lock()
+	defer mu.Unlock()
+
+	target := c
+	if preferredAP != "" && preferredAP != c.config.Name {
+		if ap := c.accessPoint(preferredAP); ap != nil {
+			target = ap
+		} else {
+			c.logger.Warn("unknown access point, authorizing on main router", zap.String("ap", preferredAP))
+		}
+	}
+
+	if err := target.authorize(ctx, macAddress, ipAddress); err != nil {
+		return err
+	}
+	c.authMu.Lock()
+	c.authorizedOn[normalizeMACAddress(macAddress)] = target
+	c.authMu.Unlock()
+	return nil
+}
+
+// authorize runs `ndsctl auth` on this router's own OpenNDS.
+func (c *OpenWrtClient) authorize(ctx context.Context, macAddress, ipAddress string) error {
 	c.logger.Info("authorizing MAC address via OpenNDS",
 		zap.String("mac", macAddress),
 		zap.String("ip", ipAddress),
@@ -109,8 +266,56 @@ func (c *OpenWrtClient) AuthorizeMAC(ctx context.Context, macAddress, ipAddress,
 	return nil
 }
 
-// DeauthorizeMAC removes a MAC address from the authorized list.
+// DeauthorizeMAC removes a MAC address from the authorized list on the
+// access point that authorized it. A MAC authorized before a restart is
+// unknown, so it is deauthorized on every access point.
 func (c *OpenWrtClient) DeauthorizeMAC(ctx context.Context, macAddress string) error {
+	mac := normalizeMACAddress(macAddress)
+	mu := c.macLock(mac)
+	mu.Lock()
+	defer mu.Unlock()
+
+	c.authMu.Lock()
+	target, known := c.authorizedOn[mac]
+	c.authMu.Unlock()
+
+	if known {
+		if err := target.deauthorize(ctx, mac); err != nil {
+			return err
+		}
+		c.forgetAuthorization(mac)
+		return nil
+	}
+
+	var firstErr error
+	for _, r := range append([]*OpenWrtClient{c}, c.aps...) {
+		if err := r.deauthorize(ctx, mac); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %w", r.config.Name, err)
+		}
+	}
+	return firstErr
+}
+
+// forgetAuthorization drops the access point recorded for mac.
+func (c *OpenWrtClient) forgetAuthorization(mac string) {
+	c.authMu.Lock()
+	delete(c.authorizedOn, mac)
+	c.authMu.Unlock()
+}
+
+// authorizedAccessPoint returns the router that authorized mac, or the
+// main router if it isn't known.
+func (c *OpenWrtClient) authorizedAccessPoint(mac string) *OpenWrtClient {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+	if target, ok := c.authorizedOn[mac]; ok {
+		return target
+	}
+	return c
+}
+
+// deauthorize runs `ndsctl deauth` on this router's own OpenNDS.
+func (c *OpenWrtClient) deauthorize(ctx context.Context, macAddress string) error {
 	c.logger.Info("deauthorizing MAC address via OpenNDS", zap.String("mac", macAddress))
 
 	mac := normalizeMACAddress(macAddress)
@@ -135,6 +340,76 @@ func (c *OpenWrtClient) DeauthorizeMAC(ctx context.Context, macAddress string) e
 	return nil
 }
 
+// LimitBandwidth re-authorizes a client with OpenNDS rate limits. OpenNDS
+// ignores new limits for a client that is already authenticated, so the
+// client is deauthorized first and loses access for a moment. If the
+// rate-limited authorization fails, it is authorized again without limits.
+// It holds the MAC's lock throughout, so a concurrent DeauthorizeMAC either
+// runs first, leaving the client unauthorized, or after the re-authorization.
+func (c *OpenWrtClient) LimitBandwidth(ctx context.Context, macAddress string, kbps int) error {
+	mac := normalizeMACAddress(macAddress)
+	mu := c.macLock(mac)
+	mu.Lock()
+	defer mu.Unlock()
+
+	target := c.authorizedAccessPoint(mac)
+	authenticated, err := target.isAuthenticated(ctx, mac)
+	if err != nil {
+		return err
+	}
+	if !authenticated {
+		return ErrClientNotAuthorized
+	}
+	if err := target.deauthorize(ctx, mac); err != nil {
+		return err
+	}
+	if kbps <= 0 {
+		return target.authorize(ctx, mac, "")
+	}
+
+	// ndsctl auth <mac> <timeout> <upload_rate> <download_rate> <upload_quota> <download_quota>
+	cmd := fmt.Sprintf("ndsctl auth %s %d %d %d 0 0", mac, target.config.AuthTimeout, kbps, kbps)
+	output, err := target.runSSHCommand(ctx, cmd)
+	if err == nil && strings.Contains(strings.ToLower(output), "authenticated") {
+		c.logger.Info("MAC bandwidth limited", zap.String("mac", mac), zap.Int("kbps", kbps))
+		return nil
+	}
+
+	c.logger.Warn("rate-limited authorization failed, restoring access",
+		zap.String("mac", mac),
+		zap.String("output", output),
+		zap.Error(err),
+	)
+	if err := target.authorize(ctx, mac, ""); err != nil {
+		return fmt.Errorf("failed to restore access after bandwidth limit: %w", err)
+	}
+	return fmt.Errorf("failed to limit bandwidth for %s", mac)
+}
+
+// isAuthenticated reports whether this router's OpenNDS currently lets mac
+// through.
+func (c *OpenWrtClient) isAuthenticated(ctx context.Context, mac string) (bool, error) {
+	output, err := c.runSSHCommand(ctx, fmt.Sprintf("ndsctl json %s", mac))
+	if err != nil {
+		return false, fmt.Errorf("failed to query OpenNDS: %w", err)
+	}
+	return parseNDSAuthenticated(output)
+}
+
+// parseNDSAuthenticated reports whether `ndsctl json <mac>` output shows an
+// authenticated client.
+func parseNDSAuthenticated(output string) (bool, error) {
+	output = strings.TrimSpace(output)
+	if output == "" || output == "{}" || !strings.HasPrefix(output, "{") {
+		return false, nil
+	}
+	var client ndsClient
+	if err := json.Unmarshal([]byte(output), &client); err != nil {
+		return false, fmt.Errorf("failed to parse ndsctl output: %w", err)
+	}
+	return strings.EqualFold(client.State, "Authenticated"), nil
+}
+
 // TestConnection tests the connection to the OpenWrt router.
 func (c *OpenWrtClient) TestConnection(ctx context.Context) error {
 	// Try to run ndsctl status to verify OpenNDS is running
@@ -159,6 +434,18 @@ func (c *OpenWrtClient) TestConnection(ctx context.Context) error {
 	return fmt.Errorf("OpenNDS does not appear to be running")
 }
 
+// Ping runs a trivial command over a pooled SSH connection.
+func (c *OpenWrtClient) Ping(ctx context.Context) error {
+	output, err := c.runSSHCommand(ctx, "echo ok")
+	if err != nil {
+		return fmt.Errorf("ping failed: %w", err)
+	}
+	if strings.TrimSpace(output) != "ok" {
+		return fmt.Errorf("ping failed: unexpected output %q", output)
+	}
+	return nil
+}
+
 // ListAuthenticatedClients returns all currently authenticated clients.
 func (c *OpenWrtClient) ListAuthenticatedClients(ctx context.Context) ([]string, error) {
 	output, err := c.runSSHCommand(ctx, "ndsctl json")
@@ -185,32 +472,251 @@ func (c *OpenWrtClient) GetClientStatus(ctx context.Context, macAddress string)
 	return strings.Contains(strings.ToLower(output), strings.ToLower(mac)), nil
 }
 
-// runSSHCommand executes a command on the router via SSH.
-func (c *OpenWrtClient) runSSHCommand(ctx context.Context, cmd string) (string, error) {
-	addr := net.JoinHostPort(c.config.Address, fmt.Sprintf("%d", c.config.Port))
+// GetSessionCount returns the number of clients OpenNDS has authenticated.
+func (c *OpenWrtClient) GetSessionCount(ctx context.Context) (int, error) {
+	output, err := c.runSSHCommand(ctx, "ndsctl json")
+	if err != nil {
+		return 0, fmt.Errorf("failed to query OpenNDS: %w", err)
+	}
+	return parseNDSSessionCount(output)
+}
+
+// parseNDSSessionCount counts authenticated clients in `ndsctl json` output.
+// Clients still on the splash page are listed too and are not counted.
+func parseNDSSessionCount(output string) (int, error) {
+	var status struct {
+		Clients map[string]ndsClient `json:"clients"`
+	}
+	if err := json.Unmarshal([]byte(output), &status); err != nil {
+		return 0, fmt.Errorf("failed to parse ndsctl output: %w", err)
+	}
+	count := 0
+	for _, client := range status.Clients {
+		if strings.EqualFold(client.State, "Authenticated") {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// ClientUsage returns every OpenNDS client's traffic counters from a single
+// `ndsctl json` call.
+func (c *OpenWrtClient) ClientUsage(ctx context.Context) (map[string]*ClientInfo, error) {
+	output, err := c.runSSHCommand(ctx, "ndsctl json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query OpenNDS: %w", err)
+	}
+	return parseNDSClients(output)
+}
+
+// parseNDSClients parses the client list in `ndsctl json` output.
+func parseNDSClients(output string) (map[string]*ClientInfo, error) {
+	var status struct {
+		Clients map[string]ndsClient `json:"clients"`
+	}
+	if err := json.Unmarshal([]byte(output), &status); err != nil {
+		return nil, fmt.Errorf("failed to parse ndsctl output: %w", err)
+	}
+	clients := make(map[string]*ClientInfo, len(status.Clients))
+	for _, client := range status.Clients {
+		if client.MAC == "" {
+			continue
+		}
+		info := client.info()
+		clients[info.MAC] = info
+	}
+	return clients, nil
+}
+
+// ndsClient is the subset of `ndsctl json <mac>` output used for ClientInfo.
+type ndsClient struct {
+	IP         string `json:"ip"`
+	MAC        string `json:"mac"`
+	Added      int64  `json:"added"`
+	State      string `json:"state"`
+	Downloaded uint64 `json:"downloaded"` // kB
+	Uploaded   uint64 `json:"uploaded"`   // kB
+}
+
+// wifiStationsCmd prints each wireless interface's ESSID followed by its associated stations.
+const wifiStationsCmd = `for dev in $(iwinfo | awk '/ESSID/ {print $1}'); do ` +
+	`echo "ESSID $(iwinfo $dev info | sed -n 's/.*ESSID: "\(.*\)"/\1/p')"; iwinfo $dev assoclist; done`
 
-	client, err := ssh.Dial("tcp", addr, c.sshConfig)
+// GetClientInfo returns live connection data from OpenNDS and iwinfo.
+// Traffic counters come from OpenNDS; signal and rates come from the wireless driver.
+func (c *OpenWrtClient) GetClientInfo(ctx context.Context, macAddress string) (*ClientInfo, error) {
+	mac := normalizeMACAddress(macAddress)
+
+	output, err := c.runSSHCommand(ctx, fmt.Sprintf("ndsctl json %s", mac))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query OpenNDS: %w", err)
+	}
+	info, err := parseNDSClient(output)
+	if err != nil {
+		return nil, err
+	}
+	info.MAC = mac
+
+	// Wireless stats are best effort; wired clients have none
+	stations, err := c.runSSHCommand(ctx, wifiStationsCmd)
 	if err != nil {
-		return "", fmt.Errorf("SSH connection failed: %w", err)
+		c.logger.Debug("failed to query iwinfo", zap.Error(err))
+		return info, nil
+	}
+	parseIwinfoAssoclist(stations, mac, info)
+
+	return info, nil
+}
+
+// parseNDSClient parses `ndsctl json <mac>` output.
+func parseNDSClient(output string) (*ClientInfo, error) {
+	output = strings.TrimSpace(output)
+	if output == "" || output == "{}" || !strings.HasPrefix(output, "{") {
+		return nil, ErrClientNotFound
+	}
+
+	var client ndsClient
+	if err := json.Unmarshal([]byte(output), &client); err != nil {
+		return nil, fmt.Errorf("failed to parse ndsctl output: %w", err)
+	}
+	if client.MAC == "" {
+		return nil, ErrClientNotFound
+	}
+	return client.info(), nil
+}
+
+// info converts an OpenNDS client record to a ClientInfo.
+func (client ndsClient) info() *ClientInfo {
+	info := &ClientInfo{
+		MAC:      normalizeMACAddress(client.MAC),
+		IP:       client.IP,
+		BytesIn:  client.Downloaded * 1024,
+		BytesOut: client.Uploaded * 1024,
+	}
+	if client.Added > 0 {
+		info.ConnectedAt = time.Unix(client.Added, 0)
+	}
+	return info
+}
+
+// parseIwinfoAssoclist fills wireless fields for mac from wifiStationsCmd output.
+// Rates are as reported by the access point (RX = received from the client).
+func parseIwinfoAssoclist(output, mac string, info *ClientInfo) bool {
+	ssid := ""
+	inStation := false
+	found := false
+
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "ESSID "):
+			ssid = strings.TrimPrefix(line, "ESSID ")
+			inStation = false
+		case len(trimmed) >= 17 && !strings.HasPrefix(line, "\t") && !strings.HasPrefix(line, " "):
+			inStation = normalizeMACAddress(trimmed[:17]) == mac
+			if inStation {
+				found = true
+				info.SSID = ssid
+				fields := strings.Fields(trimmed[17:])
+				if len(fields) >= 2 && fields[1] == "dBm" {
+					info.SignalStrength, _ = strconv.Atoi(fields[0])
+				}
+			}
+		case inStation && strings.HasPrefix(trimmed, "RX:"):
+			info.RxRate = parseIwinfoRate(trimmed)
+		case inStation && strings.HasPrefix(trimmed, "TX:"):
+			info.TxRate = parseIwinfoRate(trimmed)
+		}
+	}
+	return found
+}
+
+// parseIwinfoRate extracts the MBit/s value from an iwinfo "RX: 144.4 MBit/s, ..." line.
+func parseIwinfoRate(line string) float64 {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return 0
+	}
+	rate, _ := strconv.ParseFloat(fields[1], 64)
+	return rate
+}
+
+// runSSHCommand executes a command on the router over a pooled SSH connection.
+// A connection that breaks mid-command is dropped and the command is retried
+// once on a fresh one.
+func (c *OpenWrtClient) runSSHCommand(ctx context.Context, cmd string) (string, error) {
+	var lastErr error
+	for attempt := 0; attempt < 2; attempt++ {
+		conn, err := c.pool.get(ctx)
+		if err != nil {
+			if !errors.Is(err, errDial) {
+				return "", err
+			}
+			lastErr = err
+			c.logger.Debug("SSH dial failed", zap.Error(err))
+			continue
+		}
+
+		output, broken, err := runOnConn(ctx, conn.client, cmd)
+		c.pool.put(conn, broken)
+		// Once the command was sent, retrying could run it twice
+		if !errors.Is(err, errNoSession) {
+			return output, err
+		}
+		lastErr = err
+		c.logger.Debug("dropping broken SSH connection", zap.Error(err))
 	}
-	defer client.Close()
+	return "", lastErr
+}
 
+var (
+	// errDial is returned when an SSH connection can't be established.
+	errDial = errors.New("SSH connection failed")
+	// errNoSession is returned when a connection can't open a session, so
+	// the command was never sent.
+	errNoSession = errors.New("failed to create SSH session")
+)
+
+// runOnConn runs cmd in a new session on client. It reports broken when the
+// connection itself failed rather than the command. When ctx is done first
+// the session is closed and the connection reported broken.
+func runOnConn(ctx context.Context, client *ssh.Client, cmd string) (string, bool, error) {
 	session, err := client.NewSession()
 	if err != nil {
-		return "", fmt.Errorf("failed to create SSH session: %w", err)
+		return "", true, fmt.Errorf("%w: %w", errNoSession, err)
 	}
 	defer session.Close()
 
-	output, err := session.CombinedOutput(cmd)
+	type result struct {
+		output []byte
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		output, err := session.CombinedOutput(cmd)
+		done <- result{output, err}
+	}()
+
+	var output []byte
+	select {
+	case <-ctx.Done():
+		return "", true, ctx.Err()
+	case r := <-done:
+		output, err = r.output, r.err
+	}
 	if err != nil {
+		var exitErr *ssh.ExitError
+		if !errors.As(err, &exitErr) {
+			return "", true, fmt.Errorf("command failed: %w", err)
+		}
 		// Check if it's just a non-zero exit code with useful output
 		if len(output) > 0 {
-			return string(output), nil
+			return string(output), false, nil
 		}
-		return "", fmt.Errorf("command failed: %w", err)
+		return "", false, fmt.Errorf("command failed: %w", err)
 	}
 
-	return string(output), nil
+	return string(output), false, nil
 }
 
 // normalizeMACAddress converts MAC address to lowercase colon-separated format.
@@ -0,0 +1,177 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestParseNDSClient(t *testing.T) {
+	output := `{"id":3,"ip":"192.168.1.120","mac":"AA:BB:CC:DD:EE:FF","added":1700000000,"active":1700000600,"duration":600,"state":"Authenticated","downloaded":2048,"avg_down_speed":12.5,"uploaded":512,"avg_up_speed":1.2}`
+
+	info, err := parseNDSClient(output)
+	if err != nil {
+		t.Fatalf("parseNDSClient failed: %v", err)
+	}
+	if info.MAC != "aa:bb:cc:dd:ee:ff" {
+		t.Errorf("MAC: expected aa:bb:cc:dd:ee:ff, got %s", info.MAC)
+	}
+	if info.IP != "192.168.1.120" {
+		t.Errorf("IP: expected 192.168.1.120, got %s", info.IP)
+	}
+	if info.BytesIn != 2048*1024 || info.BytesOut != 512*1024 {
+		t.Errorf("Bytes: expected 2097152/524288, got %d/%d", info.BytesIn, info.BytesOut)
+	}
+	if !info.ConnectedAt.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("ConnectedAt: unexpected %v", info.ConnectedAt)
+	}
+}
+
+func TestParseNDSAuthenticated(t *testing.T) {
+	tests := []struct {
+		output string
+		want   bool
+	}{
+		{`{"mac":"aa:bb:cc:dd:ee:ff","state":"Authenticated"}`, true},
+		{`{"mac":"aa:bb:cc:dd:ee:ff","state":"Preauthenticated"}`, false},
+		{"{}", false},
+		{"ndsctl: client not found", false},
+	}
+	for _, tt := range tests {
+		got, err := parseNDSAuthenticated(tt.output)
+		if err != nil {
+			t.Errorf("parseNDSAuthenticated(%q) failed: %v", tt.output, err)
+		}
+		if got != tt.want {
+			t.Errorf("parseNDSAuthenticated(%q) = %v, want %v", tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestParseNDSClient_NotFound(t *testing.T) {
+	for _, output := range []string{"", "{}", "ndsctl: client not found"} {
+		if _, err := parseNDSClient(output); !errors.Is(err, ErrClientNotFound) {
+			t.Errorf("parseNDSClient(%q): expected ErrClientNotFound, got %v", output, err)
+		}
+	}
+}
+
+func TestParseNDSSessionCount(t *testing.T) {
+	output := `{"client_list_length":"3","clients":{` +
+		`"aa:bb:cc:dd:ee:01":{"id":1,"ip":"192.168.1.101","mac":"aa:bb:cc:dd:ee:01","state":"Authenticated"},` +
+		`"aa:bb:cc:dd:ee:02":{"id":2,"ip":"192.168.1.102","mac":"aa:bb:cc:dd:ee:02","state":"Preauthenticated"},` +
+		`"aa:bb:cc:dd:ee:03":{"id":3,"ip":"192.168.1.103","mac":"aa:bb:cc:dd:ee:03","state":"Authenticated"}}}`
+
+	count, err := parseNDSSessionCount(output)
+	if err != nil {
+		t.Fatalf("parseNDSSessionCount failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 authenticated clients, got %d", count)
+	}
+
+	if count, err := parseNDSSessionCount(`{"client_list_length":"0","clients":{}}`); err != nil || count != 0 {
+		t.Errorf("Empty client list: expected 0, got %d (%v)", count, err)
+	}
+	if _, err := parseNDSSessionCount("ndsctl: opennds is not running"); err == nil {
+		t.Error("Expected error for non-JSON output")
+	}
+}
+
+func TestParseNDSClients(t *testing.T) {
+	output := `{"client_list_length":"2","clients":{` +
+		`"AA:BB:CC:DD:EE:01":{"ip":"192.168.1.101","mac":"AA:BB:CC:DD:EE:01","downloaded":10,"uploaded":2},` +
+		`"aa:bb:cc:dd:ee:02":{"ip":"192.168.1.102","mac":"aa:bb:cc:dd:ee:02","downloaded":0,"uploaded":0}}}`
+
+	clients, err := parseNDSClients(output)
+	if err != nil {
+		t.Fatalf("parseNDSClients failed: %v", err)
+	}
+	if len(clients) != 2 {
+		t.Fatalf("Expected 2 clients, got %d", len(clients))
+	}
+	info := clients["aa:bb:cc:dd:ee:01"]
+	if info == nil || info.BytesIn != 10*1024 || info.BytesOut != 2*1024 {
+		t.Errorf("Unexpected usage for aa:bb:cc:dd:ee:01: %+v", info)
+	}
+
+	if _, err := parseNDSClients("ndsctl: opennds is not running"); err == nil {
+		t.Error("Expected error for non-JSON output")
+	}
+}
+
+func TestParseIwinfoAssoclist(t *testing.T) {
+	output := "ESSID AirFi-Guest\n" +
+		"11:22:33:44:55:66  -70 dBm / -95 dBm (SNR 25)  40 ms ago\n" +
+		"\tRX: 6.0 MBit/s                                   120 Pkts.\n" +
+		"\tTX: 6.0 MBit/s                                   110 Pkts.\n" +
+		"\n" +
+		"AA:BB:CC:DD:EE:FF  -52 dBm / -95 dBm (SNR 43)  120 ms ago\n" +
+		"\tRX: 144.4 MBit/s, MCS 15, 20MHz                  3245 Pkts.\n" +
+		"\tTX: 130.0 MBit/s, MCS 14, 20MHz                  2890 Pkts.\n" +
+		"\texpected throughput: unknown\n"
+
+	info := &ClientInfo{}
+	if !parseIwinfoAssoclist(output, "aa:bb:cc:dd:ee:ff", info) {
+		t.Fatal("Station not found")
+	}
+	if info.SSID != "AirFi-Guest" {
+		t.Errorf("SSID: expected AirFi-Guest, got %s", info.SSID)
+	}
+	if info.SignalStrength != -52 {
+		t.Errorf("SignalStrength: expected -52, got %d", info.SignalStrength)
+	}
+	if info.RxRate != 144.4 || info.TxRate != 130.0 {
+		t.Errorf("Rates: expected 144.4/130.0, got %.1f/%.1f", info.RxRate, info.TxRate)
+	}
+}
+
+func TestParseIwinfoAssoclist_Missing(t *testing.T) {
+	info := &ClientInfo{}
+	if parseIwinfoAssoclist("ESSID AirFi\n", "aa:bb:cc:dd:ee:ff", info) {
+		t.Error("Should not find station in empty assoclist")
+	}
+}
+
+func TestOpenWrtClient_DeauthorizesOnAuthorizingAP(t *testing.T) {
+	mainServer, apServer := newMockSSHServer(t), newMockSSHServer(t)
+	mainAddr := mainServer.listener.Addr().(*net.TCPAddr)
+	apAddr := apServer.listener.Addr().(*net.TCPAddr)
+
+	client, err := NewOpenWrtClient(OpenWrtConfig{
+		Address:  mainAddr.IP.String(),
+		Port:     mainAddr.Port,
+		Username: "root",
+		Password: "secret",
+		AccessPoints: []OpenWrtConfig{
+			{Name: "lobby", Address: apAddr.IP.String(), Port: apAddr.Port},
+		},
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewOpenWrtClient failed: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	ctx := context.Background()
+	if err := client.AuthorizeMAC(ctx, "AA:BB:CC:DD:EE:FF", "", "", "lobby"); err != nil {
+		t.Fatalf("AuthorizeMAC failed: %v", err)
+	}
+	if err := client.DeauthorizeMAC(ctx, "aa:bb:cc:dd:ee:ff"); err != nil {
+		t.Fatalf("DeauthorizeMAC failed: %v", err)
+	}
+	if main, ap := mainServer.commands.Load(), apServer.commands.Load(); main != 0 || ap != 2 {
+		t.Errorf("Expected both commands on the lobby AP, got main=%d lobby=%d", main, ap)
+	}
+
+	// An unknown MAC is deauthorized everywhere
+	if err := client.DeauthorizeMAC(ctx, "aa:bb:cc:dd:ee:01"); err != nil {
+		t.Fatalf("DeauthorizeMAC failed: %v", err)
+	}
+	if main, ap := mainServer.commands.Load(), apServer.commands.Load(); main != 1 || ap != 3 {
+		t.Errorf("Expected the unknown MAC deauthorized on both, got main=%d lobby=%d", main, ap)
+	}
+}
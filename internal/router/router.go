@@ -1,25 +1,96 @@
 // Package router provides WiFi router integration for access control.
 package router
 
-import "context"
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrClientNotFound is returned when the router has no record of a client.
+var ErrClientNotFound = errors.New("client not found")
+
+// ErrClientNotAuthorized is returned when throttling a client that the
+// router does not currently let through.
+var ErrClientNotAuthorized = errors.New("client not authorized")
+
+// ClientInfo describes a connected guest device as seen by the router.
+type ClientInfo struct {
+	MAC            string    `json:"mac"`
+	IP             string    `json:"ip"`
+	SSID           string    `json:"ssid"`
+	SignalStrength int       `json:"signal_strength"` // dBm
+	TxRate         float64   `json:"tx_rate"`         // Mbit/s
+	RxRate         float64   `json:"rx_rate"`         // Mbit/s
+	BytesIn        uint64    `json:"bytes_in"`        // Downloaded by the client
+	BytesOut       uint64    `json:"bytes_out"`       // Uploaded by the client
+	ConnectedAt    time.Time `json:"connected_at"`
+}
+
+// AccessPoint describes one access point in a deployment.
+type AccessPoint struct {
+	Name             string `json:"name"`
+	MAC              string `json:"mac"`
+	IPAddress        string `json:"ip_address"`
+	ConnectedClients int    `json:"connected_clients"`
+	SignalThreshold  int    `json:"signal_threshold"` // dBm, 0 if unset
+}
+
+// NetworkTopology lists the access points served by one backend.
+type NetworkTopology struct {
+	APs []AccessPoint `json:"aps"`
+}
 
 // Router defines the interface for WiFi access control.
 type Router interface {
-	// AuthorizeMAC allows a MAC address to access the internet.
-	AuthorizeMAC(ctx context.Context, macAddress, ipAddress, comment string) error
+	// AuthorizeMAC allows a MAC address to access the internet. A non-empty
+	// preferredAP names the access point the client is connected to.
+	AuthorizeMAC(ctx context.Context, macAddress, ipAddress, comment, preferredAP string) error
 
 	// DeauthorizeMAC removes a MAC address from the authorized list.
 	DeauthorizeMAC(ctx context.Context, macAddress string) error
 
 	// TestConnection tests the connection to the router.
 	TestConnection(ctx context.Context) error
+
+	// Ping checks that the router is reachable. Unlike TestConnection it
+	// doesn't check the captive portal, so it's cheap enough for health checks.
+	Ping(ctx context.Context) error
+
+	// GetClientInfo returns live connection data for a MAC address.
+	GetClientInfo(ctx context.Context, macAddress string) (*ClientInfo, error)
+
+	// GetTopology returns the access points and their client counts.
+	GetTopology(ctx context.Context) (*NetworkTopology, error)
+
+	// GetSessionCount returns how many devices the router currently lets
+	// through the captive portal.
+	GetSessionCount(ctx context.Context) (int, error)
+}
+
+// BandwidthLimiter is implemented by routers that can throttle an authorized
+// client. Access may drop for a moment while the limit is applied, but a
+// client is never left authorized that was not authorized before.
+type BandwidthLimiter interface {
+	// LimitBandwidth caps the client's upload and download rate in kbit/s.
+	// A kbps of 0 or less removes the limit. A client that is not
+	// authorized returns ErrClientNotAuthorized and stays unauthorized.
+	LimitBandwidth(ctx context.Context, macAddress string, kbps int) error
+}
+
+// UsageReporter is implemented by routers that can report every client's
+// traffic counters in a single query.
+type UsageReporter interface {
+	// ClientUsage returns the traffic counters of every client the router
+	// knows, keyed by normalized MAC address.
+	ClientUsage(ctx context.Context) (map[string]*ClientInfo, error)
 }
 
 // NoopRouter is a no-op router for testing or when no router is configured.
 type NoopRouter struct{}
 
 // AuthorizeMAC does nothing.
-func (r *NoopRouter) AuthorizeMAC(ctx context.Context, macAddress, ipAddress, comment string) error {
+func (r *NoopRouter) AuthorizeMAC(ctx context.Context, macAddress, ipAddress, comment, preferredAP string) error {
 	return nil
 }
 
@@ -32,3 +103,23 @@ func (r *NoopRouter) DeauthorizeMAC(ctx context.Context, macAddress string) erro
 func (r *NoopRouter) TestConnection(ctx context.Context) error {
 	return nil
 }
+
+// Ping always succeeds.
+func (r *NoopRouter) Ping(ctx context.Context) error {
+	return nil
+}
+
+// GetClientInfo always reports the client as unknown.
+func (r *NoopRouter) GetClientInfo(ctx context.Context, macAddress string) (*ClientInfo, error) {
+	return nil, ErrClientNotFound
+}
+
+// GetTopology reports no access points.
+func (r *NoopRouter) GetTopology(ctx context.Context) (*NetworkTopology, error) {
+	return &NetworkTopology{APs: []AccessPoint{}}, nil
+}
+
+// GetSessionCount reports no authorized devices.
+func (r *NoopRouter) GetSessionCount(ctx context.Context) (int, error) {
+	return 0, nil
+}
@@ -0,0 +1,170 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// DefaultSSHPoolSize is the number of SSH connections kept to the router.
+	DefaultSSHPoolSize = 3
+	// DefaultSSHHeartbeatInterval is how long an idle connection is trusted
+	// before it is checked with a heartbeat command on reuse.
+	DefaultSSHHeartbeatInterval = 30 * time.Second
+
+	heartbeatCommand = "echo ok"
+)
+
+// errPoolClosed is returned when a connection is requested after Close.
+var errPoolClosed = errors.New("ssh pool closed")
+
+// PoolStats reports the state of the SSH connection pool.
+type PoolStats struct {
+	Active int `json:"active"` // Connections currently running a command
+	Idle   int `json:"idle"`   // Open connections waiting for reuse
+}
+
+// pooledConn is an SSH connection with the time it was last known good.
+type pooledConn struct {
+	client    *ssh.Client
+	lastAlive time.Time
+}
+
+// sshPool keeps up to maxConns persistent SSH connections to the router.
+type sshPool struct {
+	dial              func(ctx context.Context) (*ssh.Client, error)
+	heartbeatInterval time.Duration
+
+	slots  chan struct{} // one token per connection allowed to be in use
+	mu     sync.Mutex
+	idle   []*pooledConn
+	active int
+	closed bool
+}
+
+// newSSHPool creates a pool. Non-positive values use the defaults.
+func newSSHPool(dial func(ctx context.Context) (*ssh.Client, error), maxConns int, heartbeatInterval time.Duration) *sshPool {
+	if maxConns <= 0 {
+		maxConns = DefaultSSHPoolSize
+	}
+	if heartbeatInterval <= 0 {
+		heartbeatInterval = DefaultSSHHeartbeatInterval
+	}
+	return &sshPool{
+		dial:              dial,
+		heartbeatInterval: heartbeatInterval,
+		slots:             make(chan struct{}, maxConns),
+	}
+}
+
+// get returns a live connection, reusing an idle one when possible. Idle
+// connections unused for longer than the heartbeat interval are checked
+// first and replaced if stale. The caller must hand it back with put.
+func (p *sshPool) get(ctx context.Context) (*pooledConn, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			<-p.slots
+			return nil, errPoolClosed
+		}
+		var conn *pooledConn
+		if n := len(p.idle); n > 0 {
+			conn = p.idle[n-1]
+			p.idle = p.idle[:n-1]
+		}
+		p.mu.Unlock()
+
+		if conn == nil {
+			break
+		}
+		if time.Since(conn.lastAlive) < p.heartbeatInterval || heartbeat(conn.client) == nil {
+			p.checkedOut()
+			return conn, nil
+		}
+		conn.client.Close()
+	}
+
+	client, err := p.dial(ctx)
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	p.checkedOut()
+	return &pooledConn{client: client, lastAlive: time.Now()}, nil
+}
+
+// checkedOut records that a connection is in use.
+func (p *sshPool) checkedOut() {
+	p.mu.Lock()
+	p.active++
+	p.mu.Unlock()
+}
+
+// put returns a connection to the pool. Broken connections are closed
+// instead of being kept for reuse.
+func (p *sshPool) put(conn *pooledConn, broken bool) {
+	p.mu.Lock()
+	p.active--
+	keep := !broken && !p.closed
+	if keep {
+		conn.lastAlive = time.Now()
+		p.idle = append(p.idle, conn)
+	}
+	p.mu.Unlock()
+
+	if !keep {
+		conn.client.Close()
+	}
+	<-p.slots
+}
+
+// stats returns the current active and idle connection counts.
+func (p *sshPool) stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return PoolStats{Active: p.active, Idle: len(p.idle)}
+}
+
+// close closes idle connections. Connections in use are closed when returned.
+func (p *sshPool) close() error {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	p.mu.Unlock()
+
+	for _, conn := range idle {
+		conn.client.Close()
+	}
+	return nil
+}
+
+// heartbeat checks that an SSH connection can still run commands.
+func heartbeat(client *ssh.Client) error {
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	output, err := session.Output(heartbeatCommand)
+	if err != nil {
+		return err
+	}
+	if string(output) != "ok\n" {
+		return fmt.Errorf("unexpected heartbeat response %q", output)
+	}
+	return nil
+}
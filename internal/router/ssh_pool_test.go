@@ -0,0 +1,243 @@
+package router
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+)
+
+// mockSSHServer accepts password logins and answers every exec request
+// with "ok", or never once hang is set. It counts handshakes so tests can
+// observe connection reuse.
+type mockSSHServer struct {
+	listener net.Listener
+	config   *ssh.ServerConfig
+	accepted atomic.Int32
+	commands atomic.Int32
+	hang     atomic.Bool
+
+	mu    sync.Mutex
+	conns []*ssh.ServerConn
+}
+
+func newMockSSHServer(t *testing.T) *mockSSHServer {
+	t.Helper()
+
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate host key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	s := &mockSSHServer{listener: listener, config: config}
+	go s.serve()
+	t.Cleanup(func() { listener.Close() })
+	return s
+}
+
+func (s *mockSSHServer) serve() {
+	for {
+		netConn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(netConn)
+	}
+}
+
+func (s *mockSSHServer) handle(netConn net.Conn) {
+	conn, chans, reqs, err := ssh.NewServerConn(netConn, s.config)
+	if err != nil {
+		netConn.Close()
+		return
+	}
+	s.accepted.Add(1)
+	s.mu.Lock()
+	s.conns = append(s.conns, conn)
+	s.mu.Unlock()
+
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unsupported")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer channel.Close()
+			for req := range requests {
+				if req.Type != "exec" {
+					req.Reply(false, nil)
+					continue
+				}
+				s.commands.Add(1)
+				req.Reply(true, nil)
+				if s.hang.Load() {
+					continue
+				}
+				channel.Write([]byte("ok\n"))
+				channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+				return
+			}
+		}()
+	}
+}
+
+// dropConnections closes every connection from the server side.
+func (s *mockSSHServer) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+func newPooledTestClient(t *testing.T, server *mockSSHServer, heartbeat time.Duration) *OpenWrtClient {
+	t.Helper()
+	addr := server.listener.Addr().(*net.TCPAddr)
+	client, err := NewOpenWrtClient(OpenWrtConfig{
+		Address:           addr.IP.String(),
+		Port:              addr.Port,
+		Username:          "root",
+		Password:          "secret",
+		HeartbeatInterval: heartbeat,
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewOpenWrtClient failed: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestOpenWrtClient_ReusesSSHConnection(t *testing.T) {
+	server := newMockSSHServer(t)
+	client := newPooledTestClient(t, server, time.Minute)
+
+	for i := 0; i < 5; i++ {
+		output, err := client.runSSHCommand(context.Background(), "ndsctl json")
+		if err != nil {
+			t.Fatalf("call %d failed: %v", i, err)
+		}
+		if output != "ok\n" {
+			t.Errorf("call %d: unexpected output %q", i, output)
+		}
+	}
+
+	if got := server.accepted.Load(); got != 1 {
+		t.Errorf("expected 1 SSH connection, got %d", got)
+	}
+	if stats := client.Stats(); stats.Active != 0 || stats.Idle != 1 {
+		t.Errorf("unexpected pool stats: %+v", stats)
+	}
+}
+
+func TestOpenWrtClient_ReconnectsStaleConnection(t *testing.T) {
+	server := newMockSSHServer(t)
+	// Every reuse is preceded by a heartbeat
+	client := newPooledTestClient(t, server, time.Nanosecond)
+
+	if _, err := client.runSSHCommand(context.Background(), "ndsctl json"); err != nil {
+		t.Fatalf("first call failed: %v", err)
+	}
+	server.dropConnections()
+
+	if _, err := client.runSSHCommand(context.Background(), "ndsctl json"); err != nil {
+		t.Fatalf("call after drop failed: %v", err)
+	}
+	if got := server.accepted.Load(); got != 2 {
+		t.Errorf("expected reconnect, got %d connections", got)
+	}
+}
+
+func TestOpenWrtClient_PoolLimitsConnections(t *testing.T) {
+	server := newMockSSHServer(t)
+	client := newPooledTestClient(t, server, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 12; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.runSSHCommand(context.Background(), "ndsctl json"); err != nil {
+				t.Errorf("concurrent call failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := server.accepted.Load(); got > DefaultSSHPoolSize {
+		t.Errorf("expected at most %d connections, got %d", DefaultSSHPoolSize, got)
+	}
+	if stats := client.Stats(); stats.Active != 0 || stats.Idle > DefaultSSHPoolSize {
+		t.Errorf("unexpected pool stats: %+v", stats)
+	}
+}
+
+func TestOpenWrtClient_Ping(t *testing.T) {
+	server := newMockSSHServer(t)
+	client := newPooledTestClient(t, server, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		if err := client.Ping(context.Background()); err != nil {
+			t.Fatalf("ping %d failed: %v", i, err)
+		}
+	}
+	if got := server.accepted.Load(); got != 1 {
+		t.Errorf("expected pings to share 1 SSH connection, got %d", got)
+	}
+
+	server.listener.Close()
+	server.dropConnections()
+	if err := client.Ping(context.Background()); err == nil {
+		t.Error("expected ping to fail with the router down")
+	}
+}
+
+func TestOpenWrtClient_CommandHonorsContext(t *testing.T) {
+	server := newMockSSHServer(t)
+	client := newPooledTestClient(t, server, time.Minute)
+	server.hang.Store(true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := client.Ping(ctx); err == nil {
+		t.Fatal("expected ping to fail when the command hangs")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected ping to return at the deadline, took %s", elapsed)
+	}
+	if got := server.commands.Load(); got != 1 {
+		t.Errorf("expected the command sent once, got %d", got)
+	}
+	if stats := client.Stats(); stats.Active != 0 || stats.Idle != 0 {
+		t.Errorf("expected the hung connection dropped, got %+v", stats)
+	}
+}
@@ -0,0 +1,113 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// wifiAssoclistCmd prints each wireless interface's name followed by its associated stations.
+const wifiAssoclistCmd = `for dev in $(iwinfo | awk '/ESSID/ {print $1}'); do echo "IFACE $dev"; iwinfo $dev assoclist; done`
+
+// ubusDevice is the subset of `ubus call network.device status` output used for topology.
+type ubusDevice struct {
+	MACAddr string `json:"macaddr"`
+	Up      bool   `json:"up"`
+}
+
+// GetTopology returns the main router and every configured access point.
+// An access point that can't be queried is still listed, with no clients.
+func (c *OpenWrtClient) GetTopology(ctx context.Context) (*NetworkTopology, error) {
+	topology := &NetworkTopology{APs: make([]AccessPoint, 0, len(c.aps)+1)}
+	for _, client := range append([]*OpenWrtClient{c}, c.aps...) {
+		ap, err := client.describeAccessPoint(ctx)
+		if err != nil {
+			c.logger.Warn("failed to query access point",
+				zap.String("ap", client.config.Name),
+				zap.Error(err),
+			)
+		}
+		topology.APs = append(topology.APs, ap)
+	}
+	return topology, nil
+}
+
+// accessPoint returns the configured access point with the given name.
+func (c *OpenWrtClient) accessPoint(name string) *OpenWrtClient {
+	for _, ap := range c.aps {
+		if ap.config.Name == name {
+			return ap
+		}
+	}
+	return nil
+}
+
+// describeAccessPoint queries this router's wireless interfaces and clients.
+func (c *OpenWrtClient) describeAccessPoint(ctx context.Context) (AccessPoint, error) {
+	ap := AccessPoint{
+		Name:            c.config.Name,
+		IPAddress:       c.config.Address,
+		SignalThreshold: c.config.SignalThreshold,
+	}
+
+	stations, err := c.runSSHCommand(ctx, wifiAssoclistCmd)
+	if err != nil {
+		return ap, fmt.Errorf("failed to query iwinfo: %w", err)
+	}
+	ifaces, clients := parseAssoclistCounts(stations)
+	for _, count := range clients {
+		ap.ConnectedClients += count
+	}
+
+	output, err := c.runSSHCommand(ctx, "ubus call network.device status")
+	if err != nil {
+		return ap, fmt.Errorf("failed to query ubus: %w", err)
+	}
+	ap.MAC, err = parseDeviceMAC(output, ifaces)
+	return ap, err
+}
+
+// parseAssoclistCounts parses wifiAssoclistCmd output into the interface
+// names, in order, and the number of stations associated with each.
+func parseAssoclistCounts(output string) ([]string, map[string]int) {
+	var ifaces []string
+	counts := make(map[string]int)
+	iface := ""
+
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "IFACE ") {
+			iface = strings.TrimSpace(strings.TrimPrefix(line, "IFACE "))
+			ifaces = append(ifaces, iface)
+			counts[iface] = 0
+			continue
+		}
+		if iface == "" || len(line) < 17 || strings.HasPrefix(line, "\t") || strings.HasPrefix(line, " ") {
+			continue
+		}
+		if _, err := net.ParseMAC(line[:17]); err == nil {
+			counts[iface]++
+		}
+	}
+	return ifaces, counts
+}
+
+// parseDeviceMAC returns the MAC address of the first wireless interface in
+// `ubus call network.device status` output, falling back to the LAN bridge.
+func parseDeviceMAC(output string, wirelessIfaces []string) (string, error) {
+	var devices map[string]ubusDevice
+	if err := json.Unmarshal([]byte(output), &devices); err != nil {
+		return "", fmt.Errorf("failed to parse ubus output: %w", err)
+	}
+
+	candidates := append(append([]string{}, wirelessIfaces...), "br-lan")
+	for _, name := range candidates {
+		if dev, ok := devices[name]; ok && dev.MACAddr != "" {
+			return normalizeMACAddress(dev.MACAddr), nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,68 @@
+package router
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAssoclistCounts(t *testing.T) {
+	output := "IFACE phy0-ap0\n" +
+		"11:22:33:44:55:66  -70 dBm / -95 dBm (SNR 25)  40 ms ago\n" +
+		"\tRX: 6.0 MBit/s                                   120 Pkts.\n" +
+		"\tTX: 6.0 MBit/s                                   110 Pkts.\n" +
+		"\n" +
+		"AA:BB:CC:DD:EE:FF  -52 dBm / -95 dBm (SNR 43)  120 ms ago\n" +
+		"\tRX: 144.4 MBit/s, MCS 15, 20MHz                  3245 Pkts.\n" +
+		"IFACE phy1-ap0\n" +
+		"No station connected\n"
+
+	ifaces, counts := parseAssoclistCounts(output)
+	if len(ifaces) != 2 || ifaces[0] != "phy0-ap0" || ifaces[1] != "phy1-ap0" {
+		t.Fatalf("unexpected interfaces: %v", ifaces)
+	}
+	if counts["phy0-ap0"] != 2 || counts["phy1-ap0"] != 0 {
+		t.Errorf("unexpected counts: %v", counts)
+	}
+}
+
+func TestParseDeviceMAC(t *testing.T) {
+	output := `{"br-lan":{"up":true,"macaddr":"02:00:00:00:00:01"},"phy0-ap0":{"up":true,"macaddr":"02:AA:BB:CC:DD:EE"}}`
+
+	mac, err := parseDeviceMAC(output, []string{"phy0-ap0"})
+	if err != nil || mac != "02:aa:bb:cc:dd:ee" {
+		t.Errorf("wireless MAC: expected 02:aa:bb:cc:dd:ee, got %q (err %v)", mac, err)
+	}
+
+	mac, err = parseDeviceMAC(output, []string{"wlan0"})
+	if err != nil || mac != "02:00:00:00:00:01" {
+		t.Errorf("bridge fallback: expected 02:00:00:00:00:01, got %q (err %v)", mac, err)
+	}
+
+	if _, err := parseDeviceMAC("Command failed: Not found", nil); err == nil {
+		t.Error("expected error for non-JSON output")
+	}
+}
+
+func TestAccessPointConfig(t *testing.T) {
+	main := OpenWrtConfig{
+		Username:          "root",
+		Password:          "secret",
+		AuthTimeout:       3600,
+		PoolSize:          2,
+		HeartbeatInterval: time.Minute,
+		SignalThreshold:   -75,
+	}
+
+	ap := accessPointConfig(main, OpenWrtConfig{Address: "192.168.1.2"}, 1)
+	if ap.Name != "ap2" || ap.Username != "root" || ap.Password != "secret" || ap.AuthTimeout != 3600 {
+		t.Errorf("defaults not inherited: %+v", ap)
+	}
+	if ap.PoolSize != 2 || ap.HeartbeatInterval != time.Minute || ap.SignalThreshold != -75 {
+		t.Errorf("pool settings not inherited: %+v", ap)
+	}
+
+	ap = accessPointConfig(main, OpenWrtConfig{Name: "lobby", Username: "admin", PrivateKey: "key", SignalThreshold: -70}, 0)
+	if ap.Name != "lobby" || ap.Username != "admin" || ap.Password != "" || ap.SignalThreshold != -70 {
+		t.Errorf("explicit settings overridden: %+v", ap)
+	}
+}
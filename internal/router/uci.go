@@ -0,0 +1,72 @@
+package router
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// portalPath is where OpenNDS sends guests on the AirFi server.
+const portalPath = "/connect"
+
+// GenerateOpenWrtUCIConfig returns the UCI commands that set up OpenNDS on
+// a router with the given config to redirect guests to the AirFi captive
+// portal at serverURL. A non-zero AuthTimeout becomes the OpenNDS session
+// timeout. It needs no SSH connection to the router.
+func GenerateOpenWrtUCIConfig(config OpenWrtConfig, serverURL string) (string, error) {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid server URL: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return "", fmt.Errorf("invalid server URL %q: want http(s)://host[:port]", serverURL)
+	}
+	// Values are single-quoted for the router's shell
+	if strings.ContainsAny(serverURL, "'\n") {
+		return "", fmt.Errorf("invalid server URL %q: contains a quote or newline", serverURL)
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	path := strings.TrimSuffix(u.Path, "/") + portalPath
+	redirectURL := (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: path}).String()
+
+	// OpenNDS takes the FAS server as an IP or, failing that, a hostname
+	remoteOption := "fasremotefqdn"
+	if net.ParseIP(u.Hostname()) != nil {
+		remoteOption = "fasremoteip"
+	}
+
+	var b strings.Builder
+	set := func(option, value string) {
+		fmt.Fprintf(&b, "uci set %s='%s'\n", option, value)
+	}
+
+	b.WriteString("# AirFi captive portal\n")
+	set("network.captive_portal", "captive_portal")
+	set("network.captive_portal.redirect_url", redirectURL)
+
+	b.WriteString("\n# OpenNDS forwards guests to AirFi with their MAC and IP\n")
+	set("opennds.@opennds[0].enabled", "1")
+	set("opennds.@opennds[0].gatewayinterface", "br-lan")
+	set("opennds.@opennds[0]."+remoteOption, u.Hostname())
+	set("opennds.@opennds[0].fasport", port)
+	set("opennds.@opennds[0].faspath", path)
+	set("opennds.@opennds[0].fas_secure_enabled", "0")
+
+	b.WriteString("\n# Client timeouts, in minutes; AirFi ends paid sessions itself\n")
+	set("opennds.@opennds[0].preauthidletimeout", "30")
+	if config.AuthTimeout > 0 {
+		set("opennds.@opennds[0].sessiontimeout", fmt.Sprint((config.AuthTimeout+59)/60))
+	}
+
+	b.WriteString("\nuci commit\n")
+	b.WriteString("/etc/init.d/opennds restart\n")
+	return b.String(), nil
+}
@@ -0,0 +1,49 @@
+package router
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateOpenWrtUCIConfig(t *testing.T) {
+	out, err := GenerateOpenWrtUCIConfig(OpenWrtConfig{AuthTimeout: 3600}, "https://airfi.example.com")
+	if err != nil {
+		t.Fatalf("GenerateOpenWrtUCIConfig failed: %v", err)
+	}
+	for _, want := range []string{
+		"uci set network.captive_portal.redirect_url='https://airfi.example.com/connect'\n",
+		"uci set opennds.@opennds[0].fasremotefqdn='airfi.example.com'\n",
+		"uci set opennds.@opennds[0].fasport='443'\n",
+		"uci set opennds.@opennds[0].faspath='/connect'\n",
+		"uci set opennds.@opennds[0].sessiontimeout='60'\n",
+		"uci commit\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	// An IP address with a port and a base path
+	out, err = GenerateOpenWrtUCIConfig(OpenWrtConfig{}, "http://192.168.1.100:8080/airfi/")
+	if err != nil {
+		t.Fatalf("GenerateOpenWrtUCIConfig failed: %v", err)
+	}
+	for _, want := range []string{
+		"redirect_url='http://192.168.1.100:8080/airfi/connect'",
+		"fasremoteip='192.168.1.100'",
+		"fasport='8080'",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "sessiontimeout") {
+		t.Error("Expected no session timeout without AuthTimeout")
+	}
+
+	for _, bad := range []string{"", "airfi.example.com", "ftp://airfi.example.com", "http://x/'; reboot; '"} {
+		if _, err := GenerateOpenWrtUCIConfig(OpenWrtConfig{}, bad); err == nil {
+			t.Errorf("Expected error for server URL %q", bad)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package session
+
+import (
+	"math"
+	"math/big"
+)
+
+// ShannonsPerCKB is the number of shannons in one CKByte.
+const ShannonsPerCKB = 100000000
+
+var shannonsPerCKB = big.NewInt(ShannonsPerCKB)
+
+// ShannonsToCKB converts an amount in shannons to CKB. The division is done
+// with big.Float, so amounts beyond the int64 range convert without
+// overflowing. A nil amount is zero.
+func ShannonsToCKB(shannons *big.Int) float64 {
+	if shannons == nil {
+		return 0
+	}
+	ckb, _ := new(big.Float).Quo(new(big.Float).SetInt(shannons), new(big.Float).SetInt(shannonsPerCKB)).Float64()
+	return ckb
+}
+
+// ShannonsToWholeCKB converts an amount in shannons to whole CKB, truncating
+// toward zero, for the integer CKB columns in the database. Amounts outside
+// the int64 range saturate. A nil amount is zero.
+func ShannonsToWholeCKB(shannons *big.Int) int64 {
+	if shannons == nil {
+		return 0
+	}
+	ckb := new(big.Int).Quo(shannons, shannonsPerCKB)
+	switch {
+	case ckb.IsInt64():
+		return ckb.Int64()
+	case ckb.Sign() > 0:
+		return math.MaxInt64
+	default:
+		return math.MinInt64
+	}
+}
+
+// FormatCKB formats an amount in shannons as CKB with two decimal places.
+func FormatCKB(shannons *big.Int) string {
+	if shannons == nil {
+		shannons = new(big.Int)
+	}
+	return new(big.Float).Quo(new(big.Float).SetInt(shannons), new(big.Float).SetInt(shannonsPerCKB)).Text('f', 2)
+}
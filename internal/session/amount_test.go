@@ -0,0 +1,89 @@
+package session
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestShannonsToCKB(t *testing.T) {
+	tests := []struct {
+		shannons *big.Int
+		want     float64
+	}{
+		{nil, 0},
+		{big.NewInt(0), 0},
+		{big.NewInt(150000000), 1.5},
+		{big.NewInt(-250000000), -2.5},
+		{big.NewInt(math.MaxInt64), 92233720368.54775807},
+		// Past int64, where Int64() would wrap to a negative number
+		{new(big.Int).Add(big.NewInt(math.MaxInt64), big.NewInt(1)), 92233720368.54775808},
+	}
+	for _, tt := range tests {
+		if got := ShannonsToCKB(tt.shannons); got != tt.want {
+			t.Errorf("ShannonsToCKB(%v) = %v, want %v", tt.shannons, got, tt.want)
+		}
+	}
+}
+
+func TestShannonsToWholeCKB(t *testing.T) {
+	beyond := new(big.Int).Lsh(big.NewInt(1), 100)
+	tests := []struct {
+		shannons *big.Int
+		want     int64
+	}{
+		{nil, 0},
+		{big.NewInt(199999999), 1},
+		{big.NewInt(-199999999), -1},
+		{big.NewInt(math.MaxInt64), 92233720368},
+		{new(big.Int).Mul(big.NewInt(math.MaxInt64), big.NewInt(2)), 184467440737},
+		{beyond, math.MaxInt64},
+		{new(big.Int).Neg(beyond), math.MinInt64},
+	}
+	for _, tt := range tests {
+		if got := ShannonsToWholeCKB(tt.shannons); got != tt.want {
+			t.Errorf("ShannonsToWholeCKB(%v) = %d, want %d", tt.shannons, got, tt.want)
+		}
+	}
+}
+
+func TestFormatCKB(t *testing.T) {
+	tests := []struct {
+		shannons *big.Int
+		want     string
+	}{
+		{nil, "0.00"},
+		{big.NewInt(123456789), "1.23"},
+		{big.NewInt(math.MaxInt64), "92233720368.55"},
+		{new(big.Int).Mul(big.NewInt(math.MaxInt64), big.NewInt(10)), "922337203685.48"},
+	}
+	for _, tt := range tests {
+		if got := FormatCKB(tt.shannons); got != tt.want {
+			t.Errorf("FormatCKB(%v) = %q, want %q", tt.shannons, got, tt.want)
+		}
+	}
+}
+
+func TestSession_CKBHelpers(t *testing.T) {
+	sess := &Session{TotalPaid: big.NewInt(math.MaxInt64)}
+	if got := sess.TotalPaidCKB(); got != 92233720368.54775807 {
+		t.Errorf("TotalPaidCKB = %v", got)
+	}
+	if got := sess.TotalPaidCKBString(); got != "92233720368.55" {
+		t.Errorf("TotalPaidCKBString = %q", got)
+	}
+
+	// Funding beyond int64: the old Int64() arithmetic returned garbage here
+	funding := new(big.Int).Add(big.NewInt(math.MaxInt64), big.NewInt(500000000))
+	if got := sess.RemainingBalanceCKB(funding); got != 5 {
+		t.Errorf("RemainingBalanceCKB = %v, want 5", got)
+	}
+
+	empty := &Session{}
+	if got := empty.TotalPaidCKB(); got != 0 {
+		t.Errorf("TotalPaidCKB with nil TotalPaid = %v, want 0", got)
+	}
+	if got := empty.RemainingBalanceCKB(big.NewInt(1000000000)); got != 10 {
+		t.Errorf("RemainingBalanceCKB with nil TotalPaid = %v, want 10", got)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/airfi/airfi-perun-nervous/internal/auth"
+	"github.com/airfi/airfi-perun-nervous/internal/config"
 	"github.com/airfi/airfi-perun-nervous/internal/perun"
 	"go.uber.org/zap"
 )
@@ -27,6 +28,35 @@ func DefaultRateConfig() *RateConfig {
 	}
 }
 
+// RateConfigError reports the RateConfig field that failed validation.
+type RateConfigError struct {
+	Field  string
+	Reason string
+}
+
+// Error returns the field name followed by the reason.
+func (e *RateConfigError) Error() string {
+	return fmt.Sprintf("invalid rate config: %s %s", e.Field, e.Reason)
+}
+
+// Validate checks that the config can price a session. A zero or missing rate
+// would otherwise divide by zero when converting payments to durations.
+func (c *RateConfig) Validate() error {
+	switch {
+	case c.CKBytesPerMinute == nil:
+		return &RateConfigError{Field: "ckbytes_per_minute", Reason: "is required"}
+	case c.CKBytesPerMinute.Sign() <= 0:
+		return &RateConfigError{Field: "ckbytes_per_minute", Reason: "must be positive"}
+	case c.MinSessionTime <= 0:
+		return &RateConfigError{Field: "min_session_time", Reason: "must be positive"}
+	case c.MaxSessionTime <= c.MinSessionTime:
+		return &RateConfigError{Field: "max_session_time", Reason: "must be longer than min_session_time"}
+	case c.MaxSessionTime > config.MaxSessionTime:
+		return &RateConfigError{Field: "max_session_time", Reason: fmt.Sprintf("must be at most %s", config.MaxSessionTime)}
+	}
+	return nil
+}
+
 // Manager handles session lifecycle and payment coordination.
 type Manager struct {
 	store       *Store
@@ -112,7 +142,7 @@ func (m *Manager) ActivateSession(sessionID string, payment *big.Int) (*Session,
 	}
 
 	// Generate access token (MAC/IP not available in this flow)
-	token, err := m.jwtService.GenerateToken(sessionID, session.ChannelID, "", "", duration)
+	token, _, err := m.jwtService.GenerateToken(sessionID, session.ChannelID, "", "", duration)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to generate token: %w", err)
 	}
@@ -121,6 +151,9 @@ func (m *Manager) ActivateSession(sessionID string, payment *big.Int) (*Session,
 	if err := m.store.Activate(sessionID, duration, token, payment); err != nil {
 		return nil, "", fmt.Errorf("failed to activate session: %w", err)
 	}
+	if err := m.store.SetRate(sessionID, m.rateConfig.CKBytesPerMinute); err != nil {
+		return nil, "", fmt.Errorf("failed to record session rate: %w", err)
+	}
 
 	// Get updated session
 	session, _ = m.store.Get(sessionID)
@@ -164,12 +197,14 @@ func (m *Manager) ExtendSession(sessionID string, payment *big.Int) (*Session, s
 
 	// Generate new token with extended duration (MAC/IP not available in this flow)
 	session, _ = m.store.Get(sessionID)
-	newToken, err := m.jwtService.GenerateToken(sessionID, session.ChannelID, "", "", session.RemainingTime())
+	newToken, _, err := m.jwtService.GenerateToken(sessionID, session.ChannelID, "", "", session.RemainingTime())
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to generate new token: %w", err)
 	}
 
-	session.Token = newToken
+	if err := m.store.SetToken(sessionID, newToken); err != nil {
+		return nil, "", fmt.Errorf("failed to store new token: %w", err)
+	}
 
 	m.logger.Info("session extended",
 		zap.String("session_id", sessionID),
@@ -253,7 +288,7 @@ func (m *Manager) CalculatePrice(duration time.Duration) *big.Int {
 
 // ValidateToken validates an access token.
 func (m *Manager) ValidateToken(tokenString string) (*Session, error) {
-	claims, err := m.jwtService.ValidateToken(tokenString)
+	claims, err := m.jwtService.ValidateAccessToken(tokenString)
 	if err != nil {
 		return nil, fmt.Errorf("invalid token: %w", err)
 	}
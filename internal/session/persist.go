@@ -0,0 +1,123 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// persistedSession is the JSON form of a Session. TotalPaid is stored as a
+// hex string so amounts beyond 64 bits survive the round trip.
+type persistedSession struct {
+	ID         string        `json:"id"`
+	ChannelID  string        `json:"channel_id"`
+	GuestAddr  string        `json:"guest_addr"`
+	Status     SessionStatus `json:"status"`
+	StartTime  time.Time     `json:"start_time"`
+	EndTime    *time.Time    `json:"end_time,omitempty"`
+	Duration   time.Duration `json:"duration"`
+	TotalPaid  string        `json:"total_paid"`             // shannons, hex; empty when nil
+	RatePerMin string        `json:"rate_per_min,omitempty"` // hex; empty when nil
+	Token      string        `json:"token"`
+	CreatedAt  time.Time     `json:"created_at"`
+	UpdatedAt  time.Time     `json:"updated_at"`
+}
+
+// Persist writes every session in the store to w as JSON, oldest first.
+func (s *Store) Persist(w io.Writer) error {
+	s.mu.RLock()
+	persisted := make([]persistedSession, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		p := persistedSession{
+			ID:        session.ID,
+			ChannelID: session.ChannelID,
+			GuestAddr: session.GuestAddr,
+			Status:    session.Status,
+			StartTime: session.StartTime,
+			EndTime:   session.EndTime,
+			Duration:  session.Duration,
+			Token:     session.Token,
+			CreatedAt: session.CreatedAt,
+			UpdatedAt: session.UpdatedAt,
+		}
+		if session.TotalPaid != nil {
+			p.TotalPaid = session.TotalPaid.Text(16)
+		}
+		if session.RatePerMin != nil {
+			p.RatePerMin = session.RatePerMin.Text(16)
+		}
+		persisted = append(persisted, p)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(persisted, func(i, j int) bool {
+		if !persisted[i].CreatedAt.Equal(persisted[j].CreatedAt) {
+			return persisted[i].CreatedAt.Before(persisted[j].CreatedAt)
+		}
+		return persisted[i].ID < persisted[j].ID
+	})
+
+	if err := json.NewEncoder(w).Encode(persisted); err != nil {
+		return fmt.Errorf("failed to encode sessions: %w", err)
+	}
+	return nil
+}
+
+// Restore replaces the store's sessions with those read from r, as written
+// by Persist. The store is left unchanged if r can't be decoded.
+func (s *Store) Restore(r io.Reader) error {
+	var persisted []persistedSession
+	if err := json.NewDecoder(r).Decode(&persisted); err != nil {
+		return fmt.Errorf("failed to decode sessions: %w", err)
+	}
+
+	sessions := make(map[string]*Session, len(persisted))
+	byChannel := make(map[string]string)
+	tokenIndex := make(map[string]*Session)
+	for _, p := range persisted {
+		session := &Session{
+			ID:        p.ID,
+			ChannelID: p.ChannelID,
+			GuestAddr: p.GuestAddr,
+			Status:    p.Status,
+			StartTime: p.StartTime,
+			EndTime:   p.EndTime,
+			Duration:  p.Duration,
+			Token:     p.Token,
+			CreatedAt: p.CreatedAt,
+			UpdatedAt: p.UpdatedAt,
+		}
+		if p.TotalPaid != "" {
+			totalPaid, ok := new(big.Int).SetString(p.TotalPaid, 16)
+			if !ok {
+				return fmt.Errorf("session %s: invalid total_paid %q", p.ID, p.TotalPaid)
+			}
+			session.TotalPaid = totalPaid
+		}
+		if p.RatePerMin != "" {
+			ratePerMin, ok := new(big.Int).SetString(p.RatePerMin, 16)
+			if !ok {
+				return fmt.Errorf("session %s: invalid rate_per_min %q", p.ID, p.RatePerMin)
+			}
+			session.RatePerMin = ratePerMin
+		}
+		sessions[session.ID] = session
+
+		// Sessions are persisted oldest first, so the channel maps to its
+		// newest session as it does after Create
+		byChannel[session.ChannelID] = session.ID
+		if session.Status == SessionStatusActive && session.Token != "" {
+			tokenIndex[session.Token] = session
+		}
+	}
+
+	s.mu.Lock()
+	s.sessions = sessions
+	s.byChannel = byChannel
+	s.tokenIndex = tokenIndex
+	s.mu.Unlock()
+	return nil
+}
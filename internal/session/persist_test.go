@@ -0,0 +1,102 @@
+package session
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStore_PersistRestoreRoundTrip(t *testing.T) {
+	store := NewStore()
+
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	for i := 0; i < 10; i++ {
+		sess, err := store.Create(fmt.Sprintf("channel-%d", i), fmt.Sprintf("ckt1guest%d", i))
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+
+		switch i % 5 {
+		case 0: // pending
+		case 1: // active
+			store.Activate(sess.ID, time.Hour, "token-"+sess.ID, big.NewInt(int64(i)*100000000))
+		case 2: // active and extended beyond 64 bits
+			store.Activate(sess.ID, time.Hour, "token-"+sess.ID, big.NewInt(1))
+			store.Extend(sess.ID, 30*time.Minute, huge)
+		case 3: // ended
+			store.Activate(sess.ID, time.Hour, "token-"+sess.ID, big.NewInt(500))
+			store.End(sess.ID)
+		case 4: // expired
+			store.Activate(sess.ID, time.Minute, "token-"+sess.ID, big.NewInt(42))
+			store.MarkExpired(sess.ID)
+		}
+	}
+
+	var first bytes.Buffer
+	if err := store.Persist(&first); err != nil {
+		t.Fatalf("Persist failed: %v", err)
+	}
+
+	restored := NewStore()
+	if err := restored.Restore(bytes.NewReader(first.Bytes())); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if restored.Count() != 10 {
+		t.Fatalf("Expected 10 sessions, got %d", restored.Count())
+	}
+
+	for _, want := range store.ListAll() {
+		got, err := restored.Get(want.ID)
+		if err != nil {
+			t.Fatalf("Get(%s) failed: %v", want.ID, err)
+		}
+		if got.ChannelID != want.ChannelID || got.GuestAddr != want.GuestAddr || got.Status != want.Status ||
+			got.Duration != want.Duration || got.Token != want.Token {
+			t.Errorf("Session %s: fields differ: got %+v, want %+v", want.ID, got, want)
+		}
+		if !got.StartTime.Equal(want.StartTime) || !got.CreatedAt.Equal(want.CreatedAt) || !got.UpdatedAt.Equal(want.UpdatedAt) {
+			t.Errorf("Session %s: times differ", want.ID)
+		}
+		if (got.EndTime == nil) != (want.EndTime == nil) || (got.EndTime != nil && !got.EndTime.Equal(*want.EndTime)) {
+			t.Errorf("Session %s: end time differs: got %v, want %v", want.ID, got.EndTime, want.EndTime)
+		}
+		if got.TotalPaid.Cmp(want.TotalPaid) != 0 {
+			t.Errorf("Session %s: total paid %s, want %s", want.ID, got.TotalPaid, want.TotalPaid)
+		}
+
+		byChannel, err := restored.GetByChannel(want.ChannelID)
+		if err != nil || byChannel.ID != want.ID {
+			t.Errorf("GetByChannel(%s) should return %s, got %v (%v)", want.ChannelID, want.ID, byChannel, err)
+		}
+	}
+
+	// Persisting the restored store reproduces the same bytes
+	var second bytes.Buffer
+	if err := restored.Persist(&second); err != nil {
+		t.Fatalf("Persist failed: %v", err)
+	}
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Errorf("Round trip is not bit-identical:\nfirst:  %s\nsecond: %s", first.String(), second.String())
+	}
+}
+
+func TestStore_RestoreInvalid(t *testing.T) {
+	store := NewStore()
+	store.Create("channel-1", "guest-1")
+
+	tests := []string{
+		`not json`,
+		`[{"id":"s1","channel_id":"c1","total_paid":"xyz"}]`,
+	}
+	for _, input := range tests {
+		if err := store.Restore(strings.NewReader(input)); err == nil {
+			t.Errorf("Restore(%q) should fail", input)
+		}
+		if store.Count() != 1 {
+			t.Errorf("Failed restore should leave the store unchanged, got %d sessions", store.Count())
+		}
+	}
+}
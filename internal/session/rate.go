@@ -0,0 +1,82 @@
+package session
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/airfi/airfi-perun-nervous/internal/config"
+)
+
+// DefaultRatePerHourCKB is the rate charged when no pricing tier matches.
+const DefaultRatePerHourCKB int64 = 500
+
+// PricingTier applies a rate to the hours in [StartHour, EndHour).
+// A tier whose StartHour is greater than its EndHour wraps past midnight,
+// and a tier whose StartHour equals its EndHour covers the whole day.
+type PricingTier struct {
+	StartHour      int
+	EndHour        int
+	RatePerHourCKB int64
+}
+
+// contains reports whether the tier covers the given hour of the day.
+func (t PricingTier) contains(hour int) bool {
+	switch {
+	case t.StartHour == t.EndHour:
+		return true
+	case t.StartHour < t.EndHour:
+		return hour >= t.StartHour && hour < t.EndHour
+	default:
+		return hour >= t.StartHour || hour < t.EndHour
+	}
+}
+
+// RateCalculator resolves the hourly rate for a point in time.
+type RateCalculator struct {
+	tiers       []PricingTier
+	defaultRate int64
+	mu          sync.RWMutex
+}
+
+// NewRateCalculator creates a calculator from the given tiers. Tiers are
+// checked in order and the first one containing the hour wins. A
+// non-positive defaultRate falls back to DefaultRatePerHourCKB.
+func NewRateCalculator(tiers []PricingTier, defaultRate int64) (*RateCalculator, error) {
+	for i, tier := range tiers {
+		if tier.StartHour < 0 || tier.StartHour > 23 || tier.EndHour < 0 || tier.EndHour > 23 {
+			return nil, fmt.Errorf("pricing tier %d: hours must be between 0 and 23", i)
+		}
+		if tier.RatePerHourCKB < 1 || tier.RatePerHourCKB >= config.MaxRatePerHour {
+			return nil, fmt.Errorf("pricing tier %d: rate must be between 1 and %d CKB per hour", i, config.MaxRatePerHour-1)
+		}
+	}
+	if defaultRate <= 0 {
+		defaultRate = DefaultRatePerHourCKB
+	}
+	return &RateCalculator{
+		tiers:       append([]PricingTier(nil), tiers...),
+		defaultRate: defaultRate,
+	}, nil
+}
+
+// GetCurrentRate returns the rate per hour in CKB that applies at t.
+func (rc *RateCalculator) GetCurrentRate(t time.Time) int64 {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+
+	hour := t.Hour()
+	for _, tier := range rc.tiers {
+		if tier.contains(hour) {
+			return tier.RatePerHourCKB
+		}
+	}
+	return rc.defaultRate
+}
+
+// SetDefaultRate changes the rate used outside of every tier.
+func (rc *RateCalculator) SetDefaultRate(ratePerHour int64) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.defaultRate = ratePerHour
+}
@@ -0,0 +1,91 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func atHour(hour, minute int) time.Time {
+	return time.Date(2025, 3, 14, hour, minute, 0, 0, time.UTC)
+}
+
+func TestRateCalculator_GetCurrentRate(t *testing.T) {
+	peakAndNight := []PricingTier{
+		{StartHour: 18, EndHour: 22, RatePerHourCKB: 800},
+		{StartHour: 22, EndHour: 6, RatePerHourCKB: 200},
+	}
+	overlapping := []PricingTier{
+		{StartHour: 9, EndHour: 17, RatePerHourCKB: 700},
+		{StartHour: 12, EndHour: 14, RatePerHourCKB: 900},
+	}
+
+	tests := []struct {
+		name  string
+		tiers []PricingTier
+		at    time.Time
+		want  int64
+	}{
+		{"no tiers uses default", nil, atHour(12, 0), 500},
+		{"inside daytime tier", peakAndNight, atHour(19, 30), 800},
+		{"tier start is inclusive", peakAndNight, atHour(18, 0), 800},
+		{"tier end is exclusive", peakAndNight, atHour(21, 59), 800},
+		{"next tier takes over at end hour", peakAndNight, atHour(22, 0), 200},
+		{"wrapping tier before midnight", peakAndNight, atHour(23, 59), 200},
+		{"wrapping tier at midnight", peakAndNight, atHour(0, 0), 200},
+		{"wrapping tier after midnight", peakAndNight, atHour(5, 59), 200},
+		{"wrapping tier ends at end hour", peakAndNight, atHour(6, 0), 500},
+		{"gap between tiers uses default", peakAndNight, atHour(12, 0), 500},
+		{"overlap resolves to first tier", overlapping, atHour(13, 0), 700},
+		{"overlap outside inner tier", overlapping, atHour(10, 0), 700},
+		{"equal start and end covers the day", []PricingTier{{StartHour: 8, EndHour: 8, RatePerHourCKB: 300}}, atHour(3, 0), 300},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc, err := NewRateCalculator(tt.tiers, DefaultRatePerHourCKB)
+			if err != nil {
+				t.Fatalf("NewRateCalculator failed: %v", err)
+			}
+			if got := rc.GetCurrentRate(tt.at); got != tt.want {
+				t.Errorf("GetCurrentRate(%s): expected %d, got %d", tt.at.Format("15:04"), tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRateCalculator_SetDefaultRate(t *testing.T) {
+	rc, err := NewRateCalculator([]PricingTier{{StartHour: 0, EndHour: 6, RatePerHourCKB: 100}}, 0)
+	if err != nil {
+		t.Fatalf("NewRateCalculator failed: %v", err)
+	}
+	if got := rc.GetCurrentRate(atHour(12, 0)); got != DefaultRatePerHourCKB {
+		t.Errorf("expected fallback %d, got %d", DefaultRatePerHourCKB, got)
+	}
+
+	rc.SetDefaultRate(650)
+	if got := rc.GetCurrentRate(atHour(12, 0)); got != 650 {
+		t.Errorf("expected 650, got %d", got)
+	}
+	if got := rc.GetCurrentRate(atHour(3, 0)); got != 100 {
+		t.Errorf("tier rate changed: expected 100, got %d", got)
+	}
+}
+
+func TestNewRateCalculator_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		tier PricingTier
+	}{
+		{"negative start", PricingTier{StartHour: -1, EndHour: 6, RatePerHourCKB: 100}},
+		{"end past 23", PricingTier{StartHour: 6, EndHour: 24, RatePerHourCKB: 100}},
+		{"zero rate", PricingTier{StartHour: 6, EndHour: 8, RatePerHourCKB: 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRateCalculator([]PricingTier{tt.tier}, 500); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
@@ -1,9 +1,12 @@
 package session
 
 import (
+	"errors"
 	"math/big"
 	"testing"
 	"time"
+
+	"github.com/airfi/airfi-perun-nervous/internal/config"
 )
 
 func TestSessionStore_Create(t *testing.T) {
@@ -25,6 +28,24 @@ func TestSessionStore_Create(t *testing.T) {
 	}
 }
 
+func TestSessionStore_Add(t *testing.T) {
+	store := NewStore()
+
+	session, err := store.Add("sess-1", "channel-123", "ckt1guest")
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if session.ID != "sess-1" || session.Status != SessionStatusPending {
+		t.Errorf("Unexpected session: %+v", session)
+	}
+	if got, _ := store.GetByChannel("channel-123"); got != session {
+		t.Error("Expected the session indexed by channel")
+	}
+	if _, err := store.Add("sess-1", "channel-456", "ckt1guest"); err == nil {
+		t.Error("Expected an error adding a duplicate ID")
+	}
+}
+
 func TestSessionStore_Get(t *testing.T) {
 	store := NewStore()
 
@@ -150,6 +171,31 @@ func TestSessionStore_ListActive(t *testing.T) {
 	}
 }
 
+func TestSessionStore_GetByToken(t *testing.T) {
+	store := NewStore()
+
+	sess, _ := store.Create("channel-1", "guest-1")
+	store.Activate(sess.ID, 1*time.Hour, "token-1", big.NewInt(500))
+
+	found, err := store.GetByToken("token-1")
+	if err != nil || found.ID != sess.ID {
+		t.Fatalf("Expected session %s, got %v (%v)", sess.ID, found, err)
+	}
+
+	store.SetToken(sess.ID, "token-2")
+	if _, err := store.GetByToken("token-1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Replaced token should not be found, got %v", err)
+	}
+	if found, err := store.GetByToken("token-2"); err != nil || found.ID != sess.ID {
+		t.Errorf("Expected reissued token to find session, got %v", err)
+	}
+
+	store.End(sess.ID)
+	if _, err := store.GetByToken("token-2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ended session should not be found, got %v", err)
+	}
+}
+
 func TestSessionStore_Delete(t *testing.T) {
 	store := NewStore()
 
@@ -177,6 +223,51 @@ func TestSessionStore_Count(t *testing.T) {
 	}
 }
 
+func TestSession_PaymentVelocity(t *testing.T) {
+	sess := &Session{
+		Status:    SessionStatusActive,
+		StartTime: time.Now().Add(-10 * time.Minute),
+		Duration:  time.Hour,
+		TotalPaid: big.NewInt(500),
+	}
+	if v := sess.PaymentVelocity(); v.Cmp(big.NewInt(50)) != 0 {
+		t.Errorf("Expected velocity 50 per minute, got %s", v)
+	}
+
+	// Less than a minute in counts as one minute
+	sess.StartTime = time.Now()
+	if v := sess.PaymentVelocity(); v.Cmp(big.NewInt(500)) != 0 {
+		t.Errorf("Expected velocity 500 per minute, got %s", v)
+	}
+
+	pending := &Session{Status: SessionStatusPending, TotalPaid: big.NewInt(500)}
+	if v := pending.PaymentVelocity(); v.Sign() != 0 {
+		t.Errorf("Expected zero velocity before start, got %s", v)
+	}
+}
+
+func TestSessionStore_FindSuspiciousSessions(t *testing.T) {
+	store := NewStore()
+
+	normal, _ := store.Create("channel-1", "guest-1")
+	store.Activate(normal.ID, time.Hour, "token", big.NewInt(100))
+	store.SetRate(normal.ID, big.NewInt(10))
+	normal.StartTime = time.Now().Add(-10 * time.Minute)
+
+	fast, _ := store.Create("channel-2", "guest-2")
+	store.Activate(fast.ID, time.Hour, "token", big.NewInt(500))
+	store.SetRate(fast.ID, big.NewInt(10))
+	fast.StartTime = time.Now().Add(-10 * time.Minute)
+
+	unrated, _ := store.Create("channel-3", "guest-3")
+	store.Activate(unrated.ID, time.Hour, "token", big.NewInt(500))
+
+	suspicious := store.FindSuspiciousSessions(2)
+	if len(suspicious) != 1 || suspicious[0].ID != fast.ID {
+		t.Fatalf("Expected only the fast session, got %d sessions", len(suspicious))
+	}
+}
+
 func TestSession_IsActive(t *testing.T) {
 	sess := &Session{
 		Status:    SessionStatusActive,
@@ -219,6 +310,23 @@ func TestSession_RemainingTime(t *testing.T) {
 	}
 }
 
+func TestSession_IsExpired(t *testing.T) {
+	// Expired sessions are inactive with no time left
+	sess := &Session{Status: SessionStatusActive, StartTime: time.Now().Add(-time.Hour - 10*time.Second), Duration: time.Hour}
+	if !sess.IsExpired() || sess.IsActive() || sess.TimeUntilExpiry() != 0 {
+		t.Error("Expected a session 10s past expiry to be expired and inactive with no time left")
+	}
+
+	// Still running
+	sess.StartTime = time.Now()
+	if sess.IsExpired() {
+		t.Error("Expected a running session not to be expired")
+	}
+	if remaining := sess.TimeUntilExpiry(); remaining <= 59*time.Minute || remaining > time.Hour {
+		t.Errorf("Unexpected time until expiry %v", remaining)
+	}
+}
+
 func TestSession_RemainingTimeFormatted(t *testing.T) {
 	// Expired session
 	sess := &Session{
@@ -256,3 +364,38 @@ func TestDefaultRateConfig(t *testing.T) {
 		t.Errorf("MaxSessionTime: expected 24h, got %v", config.MaxSessionTime)
 	}
 }
+
+func TestRateConfig_Validate(t *testing.T) {
+	if err := DefaultRateConfig().Validate(); err != nil {
+		t.Fatalf("Default config should be valid: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		edit  func(c *RateConfig)
+		field string
+	}{
+		{"nil rate", func(c *RateConfig) { c.CKBytesPerMinute = nil }, "ckbytes_per_minute"},
+		{"zero rate", func(c *RateConfig) { c.CKBytesPerMinute = big.NewInt(0) }, "ckbytes_per_minute"},
+		{"negative rate", func(c *RateConfig) { c.CKBytesPerMinute = big.NewInt(-1) }, "ckbytes_per_minute"},
+		{"zero min", func(c *RateConfig) { c.MinSessionTime = 0 }, "min_session_time"},
+		{"max not above min", func(c *RateConfig) { c.MaxSessionTime = c.MinSessionTime }, "max_session_time"},
+		{"max over a week", func(c *RateConfig) { c.MaxSessionTime = config.MaxSessionTime + time.Hour }, "max_session_time"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultRateConfig()
+			tt.edit(cfg)
+
+			err := cfg.Validate()
+			var rateErr *RateConfigError
+			if !errors.As(err, &rateErr) {
+				t.Fatalf("Expected RateConfigError, got %v", err)
+			}
+			if rateErr.Field != tt.field {
+				t.Errorf("Expected field %q, got %q", tt.field, rateErr.Field)
+			}
+		})
+	}
+}
@@ -2,14 +2,20 @@
 package session
 
 import (
+	"errors"
 	"fmt"
 	"math/big"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/airfi/airfi-perun-nervous/internal/expiry"
 )
 
+// ErrNotFound is returned when no session matches a lookup.
+var ErrNotFound = errors.New("session not found")
+
 // SessionStatus represents the current status of a session.
 type SessionStatus string
 
@@ -34,6 +40,7 @@ type Session struct {
 	EndTime     *time.Time
 	Duration    time.Duration
 	TotalPaid   *big.Int
+	RatePerMin  *big.Int // contracted rate per minute, in TotalPaid's unit; nil when unknown
 	Token       string
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
@@ -41,10 +48,18 @@ type Session struct {
 
 // IsActive returns true if the session is currently active.
 func (s *Session) IsActive() bool {
-	if s.Status != SessionStatusActive {
-		return false
-	}
-	return time.Now().Before(s.StartTime.Add(s.Duration))
+	return s.Status == SessionStatusActive && !s.IsExpired()
+}
+
+// IsExpired reports whether the session has expired; see expiry.Expired.
+func (s *Session) IsExpired() bool {
+	return expiry.Expired(s.ExpiresAt())
+}
+
+// TimeUntilExpiry returns how long until the session expires; see
+// expiry.Until. Unlike RemainingTime it doesn't depend on the status.
+func (s *Session) TimeUntilExpiry() time.Duration {
+	return expiry.Until(s.ExpiresAt())
 }
 
 // RemainingTime returns the remaining session time.
@@ -52,11 +67,12 @@ func (s *Session) RemainingTime() time.Duration {
 	if !s.IsActive() {
 		return 0
 	}
-	remaining := s.StartTime.Add(s.Duration).Sub(time.Now())
-	if remaining < 0 {
-		return 0
-	}
-	return remaining
+	return s.TimeUntilExpiry()
+}
+
+// ExpiresAt returns when the session's paid time runs out.
+func (s *Session) ExpiresAt() time.Time {
+	return s.StartTime.Add(s.Duration)
 }
 
 // RemainingTimeFormatted returns a human-readable remaining time string.
@@ -79,26 +95,94 @@ func (s *Session) RemainingTimeFormatted() string {
 	return fmt.Sprintf("%ds", seconds)
 }
 
+// TotalPaidCKB returns the amount paid so far in CKB.
+func (s *Session) TotalPaidCKB() float64 {
+	return ShannonsToCKB(s.TotalPaid)
+}
+
+// TotalPaidCKBString returns the amount paid so far in CKB with two
+// decimal places.
+func (s *Session) TotalPaidCKBString() string {
+	return FormatCKB(s.TotalPaid)
+}
+
+// RemainingBalanceCKB returns what is left of fundingShannons after the
+// payments made so far, in CKB.
+func (s *Session) RemainingBalanceCKB(fundingShannons *big.Int) float64 {
+	return ShannonsToCKB(remainingShannons(fundingShannons, s.TotalPaid))
+}
+
+// PaymentVelocity returns the average rate paid so far, TotalPaid divided
+// by the whole minutes since StartTime (at least one). Ended sessions are
+// measured up to EndTime. A session that hasn't started has zero velocity.
+func (s *Session) PaymentVelocity() *big.Int {
+	if s.TotalPaid == nil || s.StartTime.IsZero() {
+		return new(big.Int)
+	}
+	end := time.Now()
+	if s.EndTime != nil {
+		end = *s.EndTime
+	}
+	minutes := int64(end.Sub(s.StartTime) / time.Minute)
+	if minutes < 1 {
+		minutes = 1
+	}
+	return new(big.Int).Quo(s.TotalPaid, big.NewInt(minutes))
+}
+
+// exceedsRate reports whether the session's payment velocity is above
+// multiplier times its contracted rate. Sessions without a rate never do.
+func (s *Session) exceedsRate(multiplier float64) bool {
+	if s.RatePerMin == nil || s.RatePerMin.Sign() <= 0 {
+		return false
+	}
+	limit := new(big.Float).Mul(new(big.Float).SetInt(s.RatePerMin), big.NewFloat(multiplier))
+	return new(big.Float).SetInt(s.PaymentVelocity()).Cmp(limit) > 0
+}
+
+// remainingShannons returns funding minus paid, treating nil as zero.
+func remainingShannons(funding, paid *big.Int) *big.Int {
+	remaining := new(big.Int)
+	if funding != nil {
+		remaining.Set(funding)
+	}
+	if paid != nil {
+		remaining.Sub(remaining, paid)
+	}
+	return remaining
+}
+
 // Store provides in-memory session storage.
 type Store struct {
 	sessions map[string]*Session
 	byChannel map[string]string // channelID -> sessionID
+	tokenIndex map[string]*Session // access token -> active session
 	mu       sync.RWMutex
 }
 
 // NewStore creates a new session store.
 func NewStore() *Store {
 	return &Store{
-		sessions:  make(map[string]*Session),
-		byChannel: make(map[string]string),
+		sessions:   make(map[string]*Session),
+		byChannel:  make(map[string]string),
+		tokenIndex: make(map[string]*Session),
 	}
 }
 
 // Create creates a new session.
 func (s *Store) Create(channelID, guestAddr string) (*Session, error) {
+	return s.Add(uuid.New().String(), channelID, guestAddr)
+}
+
+// Add creates a new pending session under an ID chosen by the caller, e.g.
+// to mirror a session tracked elsewhere.
+func (s *Store) Add(id, channelID, guestAddr string) (*Session, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if _, exists := s.sessions[id]; exists {
+		return nil, fmt.Errorf("session %s already exists", id)
+	}
 	// Check if channel already has a session
 	if existingID, exists := s.byChannel[channelID]; exists {
 		if session, ok := s.sessions[existingID]; ok && session.IsActive() {
@@ -107,7 +191,7 @@ func (s *Store) Create(channelID, guestAddr string) (*Session, error) {
 	}
 
 	session := &Session{
-		ID:        uuid.New().String(),
+		ID:        id,
 		ChannelID: channelID,
 		GuestAddr: guestAddr,
 		Status:    SessionStatusPending,
@@ -166,7 +250,7 @@ func (s *Store) Activate(sessionID string, duration time.Duration, token string,
 	session.Status = SessionStatusActive
 	session.StartTime = time.Now()
 	session.Duration = duration
-	session.Token = token
+	s.setToken(session, token)
 	session.TotalPaid = new(big.Int).Set(payment)
 	session.UpdatedAt = time.Now()
 
@@ -194,6 +278,64 @@ func (s *Store) Extend(sessionID string, additionalDuration time.Duration, payme
 	return nil
 }
 
+// SetRate records the rate per minute the session was sold at, which
+// FindSuspiciousSessions compares its payment velocity against.
+func (s *Store) SetRate(sessionID string, ratePerMin *big.Int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, exists := s.sessions[sessionID]
+	if !exists {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	session.RatePerMin = new(big.Int).Set(ratePerMin)
+	session.UpdatedAt = time.Now()
+
+	return nil
+}
+
+// SetToken replaces the access token of a session, e.g. after it was
+// reissued for an extension.
+func (s *Store) SetToken(sessionID, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, exists := s.sessions[sessionID]
+	if !exists {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	s.setToken(session, token)
+	session.UpdatedAt = time.Now()
+
+	return nil
+}
+
+// setToken sets session.Token and moves its token index entry; the caller
+// holds mu.
+func (s *Store) setToken(session *Session, token string) {
+	delete(s.tokenIndex, session.Token)
+	session.Token = token
+	if token != "" {
+		s.tokenIndex[token] = session
+	}
+}
+
+// GetByToken retrieves the session an access token was issued to, without
+// validating the token. Ended and expired sessions are not indexed.
+func (s *Store) GetByToken(token string) (*Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, exists := s.tokenIndex[token]
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	return session, nil
+}
+
 // End ends a session.
 func (s *Store) End(sessionID string) error {
 	s.mu.Lock()
@@ -207,6 +349,7 @@ func (s *Store) End(sessionID string) error {
 	now := time.Now()
 	session.Status = SessionStatusEnded
 	session.EndTime = &now
+	delete(s.tokenIndex, session.Token)
 	session.UpdatedAt = now
 
 	return nil
@@ -225,6 +368,7 @@ func (s *Store) MarkExpired(sessionID string) error {
 	now := time.Now()
 	session.Status = SessionStatusExpired
 	session.EndTime = &now
+	delete(s.tokenIndex, session.Token)
 	session.UpdatedAt = now
 
 	return nil
@@ -245,6 +389,23 @@ func (s *Store) ListActive() []*Session {
 	return active
 }
 
+// FindSuspiciousSessions returns the active sessions paying faster than
+// maxRateMultiplier times their contracted rate, which suggests the payment
+// flow was tampered with. Sessions without a recorded rate are skipped.
+func (s *Store) FindSuspiciousSessions(maxRateMultiplier float64) []*Session {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var suspicious []*Session
+	for _, session := range s.sessions {
+		if session.IsActive() && session.exceedsRate(maxRateMultiplier) {
+			suspicious = append(suspicious, session)
+		}
+	}
+
+	return suspicious
+}
+
 // ListAll returns all sessions.
 func (s *Store) ListAll() []*Session {
 	s.mu.RLock()
@@ -269,6 +430,9 @@ func (s *Store) Delete(sessionID string) error {
 	}
 
 	delete(s.byChannel, session.ChannelID)
+	if s.tokenIndex[session.Token] == session {
+		delete(s.tokenIndex, session.Token)
+	}
 	delete(s.sessions, sessionID)
 
 	return nil
@@ -0,0 +1,57 @@
+package terminal
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	clearScreen = "\033[H\033[2J"
+	clearLine   = "\033[K"
+)
+
+// DiffRenderer redraws a full-screen view by rewriting only the lines that
+// changed since the last render, which avoids the flicker of clearing the
+// whole screen on every refresh.
+type DiffRenderer struct {
+	// Lines is what is currently on screen; nil until the first Render.
+	Lines []string
+}
+
+// Render returns the ANSI escape sequences that turn the current screen into
+// newLines and records newLines as the current state. The first render clears
+// the screen. It returns "" when nothing changed.
+func (r *DiffRenderer) Render(newLines []string) string {
+	var b strings.Builder
+	if r.Lines == nil {
+		b.WriteString(clearScreen)
+	}
+
+	for i, line := range newLines {
+		if r.Lines != nil && i < len(r.Lines) && r.Lines[i] == line {
+			continue
+		}
+		b.WriteString(moveTo(i + 1))
+		b.WriteString(line)
+		b.WriteString(clearLine)
+	}
+	// Blank out lines left over from a longer previous render
+	for i := len(newLines); i < len(r.Lines); i++ {
+		b.WriteString(moveTo(i + 1))
+		b.WriteString(clearLine)
+	}
+
+	r.Lines = append(make([]string, 0, len(newLines)), newLines...)
+	if b.Len() == 0 {
+		return ""
+	}
+	// Park the cursor below the view so other output doesn't overwrite it
+	b.WriteString(moveTo(len(newLines) + 1))
+	return b.String()
+}
+
+// moveTo returns the escape sequence that moves the cursor to the start of a
+// 1-based screen line.
+func moveTo(line int) string {
+	return fmt.Sprintf("\033[%d;1H", line)
+}
@@ -0,0 +1,51 @@
+// Package terminal provides audio and color cues for interactive terminal output.
+package terminal
+
+import (
+	"io"
+	"os"
+)
+
+// Colors accepted by Highlight.
+const (
+	Green  = "green"
+	Yellow = "yellow"
+	Red    = "red"
+)
+
+const reset = "\033[0m"
+
+// ansiColors maps color names to their ANSI escape sequences.
+var ansiColors = map[string]string{
+	Green:  "\033[1;32m",
+	Yellow: "\033[1;33m",
+	Red:    "\033[1;31m",
+}
+
+// out is where Bell writes; tests replace it.
+var out io.Writer = os.Stdout
+
+// Bell rings the terminal bell.
+func Bell() {
+	io.WriteString(out, "\a")
+}
+
+// Highlight wraps text in the ANSI escape sequence for color. Unknown colors
+// return text unchanged.
+func Highlight(text, color string) string {
+	code, ok := ansiColors[color]
+	if !ok {
+		return text
+	}
+	return code + text + reset
+}
+
+// IsColorSupported reports whether ANSI colors should be used. NO_COLOR
+// (https://no-color.org) disables them, as does an unset or dumb TERM.
+func IsColorSupported() bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	term := os.Getenv("TERM")
+	return term != "" && term != "dumb"
+}
@@ -0,0 +1,80 @@
+package terminal
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBell(t *testing.T) {
+	var buf bytes.Buffer
+	prev := out
+	out = &buf
+	defer func() { out = prev }()
+
+	Bell()
+	if buf.String() != "\a" {
+		t.Errorf("Expected BEL, got %q", buf.String())
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	if got := Highlight("row", Green); got != "\033[1;32mrow\033[0m" {
+		t.Errorf("Expected green row, got %q", got)
+	}
+	if got := Highlight("row", "purple"); got != "row" {
+		t.Errorf("Expected unknown color to leave text unchanged, got %q", got)
+	}
+}
+
+func TestIsColorSupported(t *testing.T) {
+	tests := []struct {
+		term, noColor string
+		want          bool
+	}{
+		{"xterm-256color", "", true},
+		{"xterm-256color", "1", false},
+		{"dumb", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		t.Setenv("TERM", tt.term)
+		t.Setenv("NO_COLOR", tt.noColor)
+		if got := IsColorSupported(); got != tt.want {
+			t.Errorf("TERM=%q NO_COLOR=%q: expected %v, got %v", tt.term, tt.noColor, tt.want, got)
+		}
+	}
+}
+
+func TestDiffRenderer(t *testing.T) {
+	r := &DiffRenderer{}
+
+	got := r.Render([]string{"a", "b"})
+	want := "\033[H\033[2J\033[1;1Ha\033[K\033[2;1Hb\033[K\033[3;1H"
+	if got != want {
+		t.Errorf("First render: expected %q, got %q", want, got)
+	}
+
+	if got := r.Render([]string{"a", "b"}); got != "" {
+		t.Errorf("Unchanged render: expected no output, got %q", got)
+	}
+
+	tests := []struct {
+		name  string
+		lines []string
+		want  string
+	}{
+		{"changed line", []string{"a", "c"}, "\033[2;1Hc\033[K\033[3;1H"},
+		{"appended line", []string{"a", "c", "d"}, "\033[3;1Hd\033[K\033[4;1H"},
+		{"removed lines", []string{"a"}, "\033[2;1H\033[K\033[3;1H\033[K\033[2;1H"},
+		{"all changed", []string{"x", "y"}, "\033[1;1Hx\033[K\033[2;1Hy\033[K\033[3;1H"},
+		{"cleared", []string{}, "\033[1;1H\033[K\033[2;1H\033[K\033[1;1H"},
+	}
+	for _, tt := range tests {
+		if got := r.Render(tt.lines); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+		if len(r.Lines) != len(tt.lines) {
+			t.Errorf("%s: expected %d tracked lines, got %d", tt.name, len(tt.lines), len(r.Lines))
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Package webhook delivers AirFi events to operator-configured HTTP endpoints.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// SignatureHeader carries the hex HMAC-SHA256 of the request body when a
+	// secret is configured.
+	SignatureHeader = "X-AirFi-Signature"
+	// EventHeader carries the event name.
+	EventHeader = "X-AirFi-Event"
+
+	deliveryTimeout = 10 * time.Second
+)
+
+// Event is the JSON body posted to every webhook URL.
+type Event struct {
+	Event     string         `json:"event"`
+	Timestamp time.Time      `json:"timestamp"`
+	Data      map[string]any `json:"data"`
+}
+
+// Notifier posts events to a fixed set of URLs.
+type Notifier struct {
+	urls       []string
+	secret     string
+	httpClient *http.Client
+	logger     *zap.Logger
+}
+
+// NewNotifier creates a notifier. It returns nil when no URLs are configured;
+// a nil notifier discards every event.
+func NewNotifier(urls []string, secret string, logger *zap.Logger) *Notifier {
+	if len(urls) == 0 {
+		return nil
+	}
+	return &Notifier{
+		urls:       urls,
+		secret:     secret,
+		httpClient: &http.Client{Timeout: deliveryTimeout},
+		logger:     logger,
+	}
+}
+
+// Emit delivers an event to every URL in the background. Delivery failures
+// are logged and not retried.
+func (n *Notifier) Emit(event string, data map[string]any) {
+	if n == nil {
+		return
+	}
+	body, err := json.Marshal(Event{Event: event, Timestamp: time.Now().UTC(), Data: data})
+	if err != nil {
+		n.logger.Error("failed to encode webhook event", zap.String("event", event), zap.Error(err))
+		return
+	}
+	for _, url := range n.urls {
+		go func(url string) {
+			if err := n.deliver(context.Background(), url, event, body); err != nil {
+				n.logger.Warn("webhook delivery failed",
+					zap.String("event", event),
+					zap.String("url", url),
+					zap.Error(err),
+				)
+			}
+		}(url)
+	}
+}
+
+// deliver posts one event body to url.
+func (n *Notifier) deliver(ctx context.Context, url, event string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event)
+	if n.secret != "" {
+		req.Header.Set(SignatureHeader, Sign(n.secret, body))
+	}
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post event: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the hex HMAC-SHA256 of body, as sent in SignatureHeader.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
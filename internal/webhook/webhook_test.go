@@ -0,0 +1,59 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestNotifier_Emit(t *testing.T) {
+	type received struct {
+		event     Event
+		header    string
+		signature string
+		body      []byte
+	}
+	got := make(chan received, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var event Event
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Errorf("failed to decode event: %v", err)
+		}
+		got <- received{event, r.Header.Get(EventHeader), r.Header.Get(SignatureHeader), body}
+	}))
+	defer srv.Close()
+
+	n := NewNotifier([]string{srv.URL}, "secret", zap.NewNop())
+	n.Emit("channel.funding_confirmed", map[string]any{"session_id": "abc"})
+
+	select {
+	case r := <-got:
+		if r.event.Event != "channel.funding_confirmed" || r.header != "channel.funding_confirmed" {
+			t.Errorf("unexpected event: %q (header %q)", r.event.Event, r.header)
+		}
+		if r.event.Data["session_id"] != "abc" {
+			t.Errorf("unexpected data: %v", r.event.Data)
+		}
+		if r.signature != Sign("secret", r.body) {
+			t.Errorf("signature mismatch")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook not delivered")
+	}
+}
+
+func TestNewNotifier_NoURLs(t *testing.T) {
+	n := NewNotifier(nil, "", zap.NewNop())
+	if n != nil {
+		t.Fatal("expected nil notifier without URLs")
+	}
+	// Emitting on a nil notifier is a no-op
+	n.Emit("channel.funding_confirmed", nil)
+}
@@ -129,7 +129,7 @@ func TestJWTService_GenerateAndValidate(t *testing.T) {
 	jwtService := auth.NewJWTService(kp, "airfi-test")
 
 	// Generate token
-	token, err := jwtService.GenerateToken("session-123", "channel-456", "AA:BB:CC:DD:EE:FF", "192.168.1.100", 1*time.Hour)
+	token, _, err := jwtService.GenerateToken("session-123", "channel-456", "AA:BB:CC:DD:EE:FF", "192.168.1.100", 1*time.Hour)
 	if err != nil {
 		t.Fatalf("GenerateToken failed: %v", err)
 	}
@@ -182,7 +182,7 @@ func TestJWTService_ExpiredToken(t *testing.T) {
 	jwtService := auth.NewJWTService(kp, "airfi-test")
 
 	// Generate token with very short duration
-	token, err := jwtService.GenerateToken("session-123", "channel-456", "", "", 1*time.Millisecond)
+	token, _, err := jwtService.GenerateToken("session-123", "channel-456", "", "", 1*time.Millisecond)
 	if err != nil {
 		t.Fatalf("GenerateToken failed: %v", err)
 	}
@@ -205,7 +205,7 @@ func TestJWTService_NotExpired(t *testing.T) {
 	jwtService := auth.NewJWTService(kp, "airfi-test")
 
 	// Generate token with long duration
-	token, err := jwtService.GenerateToken("session-123", "channel-456", "", "", 1*time.Hour)
+	token, _, err := jwtService.GenerateToken("session-123", "channel-456", "", "", 1*time.Hour)
 	if err != nil {
 		t.Fatalf("GenerateToken failed: %v", err)
 	}
@@ -225,7 +225,7 @@ func TestJWTService_GetRemainingTime(t *testing.T) {
 	jwtService := auth.NewJWTService(kp, "airfi-test")
 
 	duration := 1 * time.Hour
-	token, err := jwtService.GenerateToken("session-123", "channel-456", "", "", duration)
+	token, _, err := jwtService.GenerateToken("session-123", "channel-456", "", "", duration)
 	if err != nil {
 		t.Fatalf("GenerateToken failed: %v", err)
 	}
@@ -241,44 +241,6 @@ func TestJWTService_GetRemainingTime(t *testing.T) {
 	}
 }
 
-func TestJWTService_RefreshToken(t *testing.T) {
-	kp, err := auth.GenerateKeyPair()
-	if err != nil {
-		t.Fatalf("GenerateKeyPair failed: %v", err)
-	}
-
-	jwtService := auth.NewJWTService(kp, "airfi-test")
-
-	// Generate initial token
-	token, err := jwtService.GenerateToken("session-123", "channel-456", "AA:BB:CC:DD:EE:FF", "192.168.1.100", 30*time.Minute)
-	if err != nil {
-		t.Fatalf("GenerateToken failed: %v", err)
-	}
-
-	// Refresh with additional 30 minutes
-	refreshedToken, err := jwtService.RefreshToken(token, 30*time.Minute)
-	if err != nil {
-		t.Fatalf("RefreshToken failed: %v", err)
-	}
-
-	if refreshedToken == "" {
-		t.Error("Refreshed token is empty")
-	}
-
-	// Verify claims are preserved
-	claims, err := jwtService.ValidateToken(refreshedToken)
-	if err != nil {
-		t.Fatalf("ValidateToken failed: %v", err)
-	}
-
-	if claims.SessionID != "session-123" {
-		t.Errorf("SessionID not preserved: got %s", claims.SessionID)
-	}
-	if claims.MACAddress != "AA:BB:CC:DD:EE:FF" {
-		t.Errorf("MACAddress not preserved: got %s", claims.MACAddress)
-	}
-}
-
 func TestJWTService_DifferentKeys(t *testing.T) {
 	kp1, _ := auth.GenerateKeyPair()
 	kp2, _ := auth.GenerateKeyPair()
@@ -287,7 +249,7 @@ func TestJWTService_DifferentKeys(t *testing.T) {
 	jwtService2 := auth.NewJWTService(kp2, "airfi-test")
 
 	// Generate token with first key
-	token, _ := jwtService1.GenerateToken("session-123", "channel-456", "", "", 1*time.Hour)
+	token, _, _ := jwtService1.GenerateToken("session-123", "channel-456", "", "", 1*time.Hour)
 
 	// Try to validate with second key (should fail)
 	_, err := jwtService2.ValidateToken(token)
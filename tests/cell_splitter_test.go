@@ -0,0 +1,233 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/indexer"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/rpc"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
+	"go.uber.org/zap"
+
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
+	"github.com/airfi/airfi-perun-nervous/tests/mocks"
+)
+
+// cellsRPCClient serves a fixed set of live cells and the transactions
+// broadcast through builder; the rest of rpc.Client panics through the
+// embedded nil interface.
+type cellsRPCClient struct {
+	rpc.Client
+	cells   []*indexer.LiveCell
+	builder *mocks.MockTransactionBuilder
+	tamper  func(*types.Transaction) *types.Transaction // rewrites transactions read back
+}
+
+func (c *cellsRPCClient) GetCells(ctx context.Context, searchKey *indexer.SearchKey, order indexer.SearchOrder, limit uint64, afterCursor string) (*indexer.LiveCells, error) {
+	return &indexer.LiveCells{Objects: c.cells}, nil
+}
+
+func (c *cellsRPCClient) GetTransaction(ctx context.Context, hash types.Hash) (*types.TransactionWithStatus, error) {
+	tx := c.builder.Transaction(hash)
+	if c.tamper != nil && tx != nil {
+		tx = c.tamper(tx)
+	}
+	return &types.TransactionWithStatus{
+		Transaction: tx,
+		TxStatus:    &types.TxStatus{Status: types.TransactionStatusCommitted},
+	}, nil
+}
+
+func newSplitterWithMock(t *testing.T, capacities ...uint64) (*perun.CellSplitter, *mocks.MockTransactionBuilder) {
+	t.Helper()
+	cells := make([]*indexer.LiveCell, len(capacities))
+	for i, capacity := range capacities {
+		cells[i] = &indexer.LiveCell{
+			Output:   &types.CellOutput{Capacity: capacity},
+			OutPoint: &types.OutPoint{Index: uint32(i)},
+		}
+	}
+	builder := mocks.NewMockTransactionBuilder()
+	cs := perun.NewCellSplitter(&cellsRPCClient{cells: cells, builder: builder}, zap.NewNop())
+	cs.SetTransactionBuilder(builder)
+	return cs, builder
+}
+
+func testLockScript() *types.Script {
+	return &types.Script{
+		CodeHash: types.HexToHash("0x9bd7e06f3ecf4be0f2fcd2188b23f1b9fcc88e5d4b65a8637b17723bbda3cce8"),
+		HashType: types.HashTypeType,
+		Args:     make([]byte, 20),
+	}
+}
+
+func testSigningKey(t *testing.T) *secp256k1.PrivateKey {
+	t.Helper()
+	key, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return key
+}
+
+func TestCellSplitter_SplitCellOutputs(t *testing.T) {
+	tests := []struct {
+		name       string
+		capacities []uint64
+		expected   []uint64
+	}{
+		{
+			name:       "exactly two minimum cells plus fee",
+			capacities: []uint64{2*MinCellCapacity + perun.SplitFee},
+			expected:   []uint64{MinCellCapacity, MinCellCapacity},
+		},
+		{
+			name:       "1000 CKB splits evenly",
+			capacities: []uint64{1000 * ShannonPerCKB},
+			expected:   []uint64{(1000*ShannonPerCKB - perun.SplitFee) / 2, (1000*ShannonPerCKB - perun.SplitFee) / 2},
+		},
+		{
+			name:       "odd remainder goes to the second cell",
+			capacities: []uint64{200*ShannonPerCKB + 1},
+			expected:   []uint64{(200*ShannonPerCKB + 1 - perun.SplitFee) / 2, (200*ShannonPerCKB+1-perun.SplitFee)/2 + 1},
+		},
+		{
+			name:       "largest cell is the one split",
+			capacities: []uint64{100 * ShannonPerCKB, 300 * ShannonPerCKB},
+			expected:   []uint64{(300*ShannonPerCKB - perun.SplitFee) / 2, (300*ShannonPerCKB - perun.SplitFee) / 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs, builder := newSplitterWithMock(t, tt.capacities...)
+
+			hash, err := cs.SplitCell(context.Background(), testSigningKey(t), testLockScript())
+			if err != nil {
+				t.Fatalf("SplitCell failed: %v", err)
+			}
+
+			calls := builder.SplitCalls()
+			if len(calls) != 1 {
+				t.Fatalf("Expected 1 BuildSplitTx call, got %d", len(calls))
+			}
+			outputs := calls[0].Outputs
+			if len(outputs) != len(tt.expected) {
+				t.Fatalf("Expected %d outputs, got %d", len(tt.expected), len(outputs))
+			}
+			var total uint64
+			for i, capacity := range outputs {
+				if capacity != tt.expected[i] {
+					t.Errorf("Output %d: expected %d, got %d", i, tt.expected[i], capacity)
+				}
+				total += capacity
+			}
+			if fee := calls[0].Cell.Output.Capacity - total; fee != perun.SplitFee {
+				t.Errorf("Expected fee %d, got %d", perun.SplitFee, fee)
+			}
+
+			broadcasts := builder.Broadcasts()
+			if len(broadcasts) != 1 {
+				t.Fatalf("Expected 1 broadcast, got %d", len(broadcasts))
+			}
+			if len(broadcasts[0].Witnesses[0]) <= 65 {
+				t.Error("Expected the broadcast transaction to carry a signed witness")
+			}
+			if hash != broadcasts[0].ComputeHash() {
+				t.Errorf("Expected hash of the broadcast transaction, got %s", hash.Hex())
+			}
+		})
+	}
+}
+
+func TestCellSplitter_SplitCellTooSmall(t *testing.T) {
+	cs, builder := newSplitterWithMock(t, 2*MinCellCapacity+perun.SplitFee-1)
+
+	if _, err := cs.SplitCell(context.Background(), testSigningKey(t), testLockScript()); err == nil {
+		t.Fatal("Expected error for a cell too small to split")
+	}
+	if n := len(builder.SplitCalls()); n != 0 {
+		t.Errorf("Expected no BuildSplitTx calls, got %d", n)
+	}
+}
+
+func TestCellSplitter_SplitCellConfiguredHash(t *testing.T) {
+	cs, builder := newSplitterWithMock(t, 500*ShannonPerCKB)
+	want := types.HexToHash("0xabcdef")
+	builder.Hashes = []types.Hash{want}
+
+	hash, err := cs.SplitCell(context.Background(), testSigningKey(t), testLockScript())
+	if err != nil {
+		t.Fatalf("SplitCell failed: %v", err)
+	}
+	if hash != want {
+		t.Errorf("Expected configured hash %s, got %s", want.Hex(), hash.Hex())
+	}
+}
+
+func TestCellSplitter_SplitCellVerification(t *testing.T) {
+	builder := mocks.NewMockTransactionBuilder()
+	rpcClient := &cellsRPCClient{
+		cells:   []*indexer.LiveCell{{Output: &types.CellOutput{Capacity: 500 * ShannonPerCKB}, OutPoint: &types.OutPoint{}}},
+		builder: builder,
+		tamper: func(tx *types.Transaction) *types.Transaction {
+			modified := *tx
+			modified.Outputs = []*types.CellOutput{{Capacity: tx.Outputs[0].Capacity - ShannonPerCKB}, tx.Outputs[1]}
+			return &modified
+		},
+	}
+	cs := perun.NewCellSplitter(rpcClient, zap.NewNop())
+	cs.SetTransactionBuilder(builder)
+
+	hash, err := cs.SplitCell(context.Background(), testSigningKey(t), testLockScript())
+	if !errors.Is(err, perun.ErrSplitVerificationFailed) {
+		t.Fatalf("Expected ErrSplitVerificationFailed, got %v", err)
+	}
+	if hash == (types.Hash{}) {
+		t.Error("Expected the broadcast hash alongside the error")
+	}
+}
+
+func TestCellSplitter_SplitCellBroadcastError(t *testing.T) {
+	cs, builder := newSplitterWithMock(t, 500*ShannonPerCKB)
+	builder.BroadcastErr = errors.New("node unreachable")
+
+	_, err := cs.SplitCell(context.Background(), testSigningKey(t), testLockScript())
+	if !errors.Is(err, builder.BroadcastErr) {
+		t.Errorf("Expected broadcast error, got %v", err)
+	}
+}
+
+func TestCellSplitter_MergeThenSplitProgress(t *testing.T) {
+	cs, builder := newSplitterWithMock(t, 500*ShannonPerCKB, 100*ShannonPerCKB, 100*ShannonPerCKB)
+	hashes := []types.Hash{types.HexToHash("0x01"), types.HexToHash("0x02"), types.HexToHash("0x03")}
+	builder.Hashes = append([]types.Hash(nil), hashes...)
+
+	var progress []perun.CellSplitProgress
+	cs.SetProgressCallback(func(p perun.CellSplitProgress) {
+		progress = append(progress, p)
+	})
+
+	// The two small cells merge into one, then two splits reach 4 cells
+	if _, err := cs.MergeThenSplit(context.Background(), testSigningKey(t), testLockScript(), 4); err != nil {
+		t.Fatalf("MergeThenSplit failed: %v", err)
+	}
+
+	if len(progress) != 3 {
+		t.Fatalf("Expected 3 progress reports, got %d", len(progress))
+	}
+	for i, p := range progress {
+		want := perun.CellSplitProgress{
+			Step:         i + 1,
+			TotalSteps:   3,
+			CurrentCells: i + 2,
+			TargetCells:  4,
+			LastTxHash:   hashes[i].Hex(),
+		}
+		if p != want {
+			t.Errorf("Report %d: expected %+v, got %+v", i, want, p)
+		}
+	}
+}
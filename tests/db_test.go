@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/airfi/airfi-perun-nervous/internal/db"
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
 )
 
 func setupTestDB(t *testing.T) (*db.DB, func()) {
@@ -38,7 +39,7 @@ func TestDB_CreateAndGetSession(t *testing.T) {
 		WalletID:     "wallet-1",
 		GuestAddress: "ckt1qzda0cr08m85hc8jlnfp3zer7xulejywt49kt2rr0vthywaa50xwsq123",
 		HostAddress:  "ckt1qzda0cr08m85hc8jlnfp3zer7xulejywt49kt2rr0vthywaa50xwsq456",
-		ChannelID:    "channel-1",
+		ChannelID:    perun.ChannelID{1},
 		FundingCKB:   500,
 		BalanceCKB:   500,
 		SpentCKB:     0,
@@ -65,6 +66,9 @@ func TestDB_CreateAndGetSession(t *testing.T) {
 	if retrieved.Status != session.Status {
 		t.Errorf("Status mismatch: expected %s, got %s", session.Status, retrieved.Status)
 	}
+	if retrieved.ChannelID != session.ChannelID {
+		t.Errorf("ChannelID mismatch: expected %s, got %s", session.ChannelID, retrieved.ChannelID)
+	}
 }
 
 func TestDB_UpdateSessionStatus(t *testing.T) {
@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
+	"github.com/airfi/airfi-perun-nervous/tests/mocks"
+)
+
+func TestFeeOracle_RecommendFee(t *testing.T) {
+	oracle, _ := mocks.NewMockFeeOracle(perun.FeeRate{Min: 1000, Median: 2000, Max: 5000})
+
+	tests := []struct {
+		urgency  perun.Urgency
+		expected uint64
+	}{
+		{perun.UrgencyLow, 2000},
+		{perun.UrgencyMedium, 3000},
+		{perun.UrgencyHigh, 5000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.urgency.String(), func(t *testing.T) {
+			if got := oracle.RecommendFee(tt.urgency); got != tt.expected {
+				t.Errorf("RecommendFee(%s): expected %d, got %d", tt.urgency, tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestFeeOracle_CachesFeeRate(t *testing.T) {
+	oracle, source := mocks.NewMockFeeOracle(perun.FeeRate{Min: 1000, Median: 2000, Max: 5000})
+
+	oracle.RecommendFee(perun.UrgencyLow)
+	source.SetRate(perun.FeeRate{Min: 9000, Median: 9000, Max: 9000})
+
+	if got := oracle.RecommendFee(perun.UrgencyLow); got != 2000 {
+		t.Errorf("Expected cached median 2000, got %d", got)
+	}
+	if source.Calls() != 1 {
+		t.Errorf("Expected 1 fetch, got %d", source.Calls())
+	}
+
+	oracle.Invalidate()
+	if got := oracle.RecommendFee(perun.UrgencyLow); got != 9000 {
+		t.Errorf("Expected refreshed median 9000, got %d", got)
+	}
+}
+
+func TestFeeOracle_FallbackOnError(t *testing.T) {
+	oracle, source := mocks.NewMockFeeOracle(perun.FeeRate{})
+	source.SetError(errors.New("rpc unavailable"))
+
+	if got := oracle.RecommendFee(perun.UrgencyHigh); got != perun.MinFeeRate {
+		t.Errorf("Expected MinFeeRate %d, got %d", perun.MinFeeRate, got)
+	}
+}
@@ -0,0 +1,57 @@
+package mocks
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
+)
+
+// MockFeeRateSource is a mock fee rate source for testing the fee oracle.
+type MockFeeRateSource struct {
+	Rate  perun.FeeRate
+	Err   error
+	calls int
+	mu    sync.Mutex
+}
+
+// FetchFeeRate returns the configured rate or error.
+func (m *MockFeeRateSource) FetchFeeRate(ctx context.Context) (*perun.FeeRate, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls++
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	rate := m.Rate
+	return &rate, nil
+}
+
+// SetRate changes the rate returned by subsequent fetches.
+func (m *MockFeeRateSource) SetRate(rate perun.FeeRate) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Rate = rate
+}
+
+// SetError makes subsequent fetches fail with err.
+func (m *MockFeeRateSource) SetError(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Err = err
+}
+
+// Calls returns how many times the source was queried.
+func (m *MockFeeRateSource) Calls() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.calls
+}
+
+// NewMockFeeOracle creates a fee oracle that serves a fixed rate.
+func NewMockFeeOracle(rate perun.FeeRate) (*perun.NetworkFeeOracle, *MockFeeRateSource) {
+	source := &MockFeeRateSource{Rate: rate}
+	return perun.NewNetworkFeeOracleWithSource(source, zap.NewNop()), source
+}
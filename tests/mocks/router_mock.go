@@ -2,15 +2,29 @@ package mocks
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/rand"
 	"sync"
+	"time"
+
+	"github.com/airfi/airfi-perun-nervous/internal/router"
+)
+
+var (
+	_ router.Router           = (*MockRouter)(nil)
+	_ router.BandwidthLimiter = (*MockRouter)(nil)
+	_ router.Router           = (*SimulatedLatencyRouter)(nil)
 )
 
 // MockRouter is a mock implementation of the WiFi router interface.
 type MockRouter struct {
 	authorizedMACs map[string]bool
+	clientInfo     map[string]*router.ClientInfo
+	topology       *router.NetworkTopology
+	bandwidth      map[string]int
 	mu             sync.RWMutex
-	AuthorizeFunc  func(ctx context.Context, mac, ip, comment string) error
+	AuthorizeFunc  func(ctx context.Context, mac, ip, comment, preferredAP string) error
 	DeauthFunc     func(ctx context.Context, mac string) error
 }
 
@@ -18,13 +32,67 @@ type MockRouter struct {
 func NewMockRouter() *MockRouter {
 	return &MockRouter{
 		authorizedMACs: make(map[string]bool),
+		clientInfo:     make(map[string]*router.ClientInfo),
+		bandwidth:      make(map[string]int),
+	}
+}
+
+// TestConnection always succeeds.
+func (m *MockRouter) TestConnection(ctx context.Context) error {
+	return nil
+}
+
+// Ping always succeeds.
+func (m *MockRouter) Ping(ctx context.Context) error {
+	return nil
+}
+
+// GetClientInfo returns the client info configured with SetClientInfo.
+func (m *MockRouter) GetClientInfo(ctx context.Context, mac string) (*router.ClientInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	info, ok := m.clientInfo[mac]
+	if !ok {
+		return nil, router.ErrClientNotFound
+	}
+	result := *info
+	return &result, nil
+}
+
+// SetClientInfo configures the test data returned for a MAC address.
+func (m *MockRouter) SetClientInfo(mac string, info *router.ClientInfo) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.clientInfo[mac] = info
+}
+
+// GetTopology returns the topology configured with SetTopology.
+func (m *MockRouter) GetTopology(ctx context.Context) (*router.NetworkTopology, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.topology == nil {
+		return &router.NetworkTopology{APs: []router.AccessPoint{}}, nil
 	}
+	aps := append([]router.AccessPoint{}, m.topology.APs...)
+	return &router.NetworkTopology{APs: aps}, nil
+}
+
+// SetTopology configures the test data returned by GetTopology.
+func (m *MockRouter) SetTopology(topology *router.NetworkTopology) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.topology = topology
+}
+
+// GetSessionCount returns the number of authorized MACs.
+func (m *MockRouter) GetSessionCount(ctx context.Context) (int, error) {
+	return m.GetAuthorizedCount(), nil
 }
 
 // AuthorizeMAC authorizes a MAC address for network access.
-func (m *MockRouter) AuthorizeMAC(ctx context.Context, mac, ip, comment string) error {
+func (m *MockRouter) AuthorizeMAC(ctx context.Context, mac, ip, comment, preferredAP string) error {
 	if m.AuthorizeFunc != nil {
-		return m.AuthorizeFunc(ctx, mac, ip, comment)
+		return m.AuthorizeFunc(ctx, mac, ip, comment, preferredAP)
 	}
 
 	m.mu.Lock()
@@ -59,11 +127,36 @@ func (m *MockRouter) GetAuthorizedCount() int {
 	return len(m.authorizedMACs)
 }
 
+// LimitBandwidth records the rate limit for an authorized MAC address.
+func (m *MockRouter) LimitBandwidth(ctx context.Context, mac string, kbps int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.authorizedMACs[mac] {
+		return router.ErrClientNotAuthorized
+	}
+	if kbps <= 0 {
+		delete(m.bandwidth, mac)
+		return nil
+	}
+	m.bandwidth[mac] = kbps
+	return nil
+}
+
+// GetBandwidthLimit returns the rate limit set for a MAC address, or 0.
+func (m *MockRouter) GetBandwidthLimit(mac string) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.bandwidth[mac]
+}
+
 // Reset clears all authorized MACs.
 func (m *MockRouter) Reset() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.authorizedMACs = make(map[string]bool)
+	m.clientInfo = make(map[string]*router.ClientInfo)
+	m.bandwidth = make(map[string]int)
+	m.topology = nil
 }
 
 // MockRouterWithError is a mock router that returns errors.
@@ -83,11 +176,11 @@ func NewMockRouterWithError(authErr, deauthErr error) *MockRouterWithError {
 }
 
 // AuthorizeMAC returns the configured error.
-func (m *MockRouterWithError) AuthorizeMAC(ctx context.Context, mac, ip, comment string) error {
+func (m *MockRouterWithError) AuthorizeMAC(ctx context.Context, mac, ip, comment, preferredAP string) error {
 	if m.AuthorizeError != nil {
 		return m.AuthorizeError
 	}
-	return m.MockRouter.AuthorizeMAC(ctx, mac, ip, comment)
+	return m.MockRouter.AuthorizeMAC(ctx, mac, ip, comment, preferredAP)
 }
 
 // DeauthorizeMAC returns the configured error.
@@ -108,9 +201,10 @@ type MockRouterWithTracking struct {
 
 // AuthorizeCall represents a call to AuthorizeMAC.
 type AuthorizeCall struct {
-	MAC     string
-	IP      string
-	Comment string
+	MAC         string
+	IP          string
+	Comment     string
+	PreferredAP string
 }
 
 // NewMockRouterWithTracking creates a mock router that tracks calls.
@@ -123,15 +217,16 @@ func NewMockRouterWithTracking() *MockRouterWithTracking {
 }
 
 // AuthorizeMAC tracks the call and delegates to MockRouter.
-func (m *MockRouterWithTracking) AuthorizeMAC(ctx context.Context, mac, ip, comment string) error {
+func (m *MockRouterWithTracking) AuthorizeMAC(ctx context.Context, mac, ip, comment, preferredAP string) error {
 	m.mu.Lock()
 	m.AuthorizeCalls = append(m.AuthorizeCalls, AuthorizeCall{
-		MAC:     mac,
-		IP:      ip,
-		Comment: comment,
+		MAC:         mac,
+		IP:          ip,
+		Comment:     comment,
+		PreferredAP: preferredAP,
 	})
 	m.mu.Unlock()
-	return m.MockRouter.AuthorizeMAC(ctx, mac, ip, comment)
+	return m.MockRouter.AuthorizeMAC(ctx, mac, ip, comment, preferredAP)
 }
 
 // DeauthorizeMAC tracks the call and delegates to MockRouter.
@@ -156,20 +251,114 @@ func (m *MockRouterWithTracking) GetDeauthorizeCalls() []string {
 	return append([]string{}, m.DeauthorizeCalls...)
 }
 
-// SimulatedLatencyRouter adds simulated latency to operations.
+// ErrSimulatedFailure is returned by SimulatedLatencyRouter for injected
+// failures.
+var ErrSimulatedFailure = errors.New("simulated router failure")
+
+// SimulatedLatencyRouter behaves like a router on a slow, unreliable network:
+// AuthorizeMAC and DeauthorizeMAC wait a normally distributed delay and may
+// fail, and Ping may drop. Zero settings behave like MockRouter.
 type SimulatedLatencyRouter struct {
 	*MockRouter
-	LatencyMs int
+	mean        time.Duration
+	stddev      time.Duration
+	authFailure float64
+	pingDrop    float64
+	rng         *rand.Rand
+	mu          sync.Mutex
 }
 
-// NewSimulatedLatencyRouter creates a mock router with simulated latency.
-func NewSimulatedLatencyRouter(latencyMs int) *SimulatedLatencyRouter {
+// NewSimulatedLatencyRouter creates a mock router whose delays and failures
+// are drawn from a source seeded with seed, so runs are reproducible.
+func NewSimulatedLatencyRouter(seed int64) *SimulatedLatencyRouter {
 	return &SimulatedLatencyRouter{
 		MockRouter: NewMockRouter(),
-		LatencyMs:  latencyMs,
+		rng:        rand.New(rand.NewSource(seed)),
 	}
 }
 
+// SetLatency sets the mean and standard deviation of the delay added to
+// AuthorizeMAC and DeauthorizeMAC. Negative samples are treated as no delay.
+func (m *SimulatedLatencyRouter) SetLatency(mean, stddev time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mean, m.stddev = mean, stddev
+}
+
+// SetAuthorizationFailureRate sets the probability, from 0.0 to 1.0, that
+// AuthorizeMAC or DeauthorizeMAC fails after its delay.
+func (m *SimulatedLatencyRouter) SetAuthorizationFailureRate(rate float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.authFailure = rate
+}
+
+// SetConnectDropRate sets the probability, from 0.0 to 1.0, that Ping fails.
+func (m *SimulatedLatencyRouter) SetConnectDropRate(rate float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pingDrop = rate
+}
+
+// sample draws a delay and whether the call fails with failureRate.
+func (m *SimulatedLatencyRouter) sample(failureRate float64) (time.Duration, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delay := m.mean + time.Duration(m.rng.NormFloat64()*float64(m.stddev))
+	return max(delay, 0), m.rng.Float64() < failureRate
+}
+
+// simulate waits a sampled delay, or until ctx is done, and reports whether
+// the call should fail.
+func (m *SimulatedLatencyRouter) simulate(ctx context.Context) error {
+	m.mu.Lock()
+	failureRate := m.authFailure
+	m.mu.Unlock()
+
+	delay, fail := m.sample(failureRate)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+	}
+	if fail {
+		return ErrSimulatedFailure
+	}
+	return nil
+}
+
+// AuthorizeMAC authorizes the MAC after a simulated delay, unless the call
+// is chosen to fail.
+func (m *SimulatedLatencyRouter) AuthorizeMAC(ctx context.Context, mac, ip, comment, preferredAP string) error {
+	if err := m.simulate(ctx); err != nil {
+		return err
+	}
+	return m.MockRouter.AuthorizeMAC(ctx, mac, ip, comment, preferredAP)
+}
+
+// DeauthorizeMAC deauthorizes the MAC after a simulated delay, unless the
+// call is chosen to fail.
+func (m *SimulatedLatencyRouter) DeauthorizeMAC(ctx context.Context, mac string) error {
+	if err := m.simulate(ctx); err != nil {
+		return err
+	}
+	return m.MockRouter.DeauthorizeMAC(ctx, mac)
+}
+
+// Ping fails at the configured drop rate.
+func (m *SimulatedLatencyRouter) Ping(ctx context.Context) error {
+	m.mu.Lock()
+	dropRate := m.pingDrop
+	m.mu.Unlock()
+
+	if _, drop := m.sample(dropRate); drop {
+		return ErrSimulatedFailure
+	}
+	return nil
+}
+
 // ValidateMACAddress validates MAC address format.
 func ValidateMACAddress(mac string) error {
 	if len(mac) != 17 {
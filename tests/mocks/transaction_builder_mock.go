@@ -0,0 +1,100 @@
+package mocks
+
+import (
+	"context"
+	"sync"
+
+	"github.com/nervosnetwork/ckb-sdk-go/v2/indexer"
+	"github.com/nervosnetwork/ckb-sdk-go/v2/types"
+
+	"github.com/airfi/airfi-perun-nervous/internal/perun"
+)
+
+var _ perun.TransactionBuilder = (*MockTransactionBuilder)(nil)
+
+// SplitTxCall records the arguments of one BuildSplitTx call.
+type SplitTxCall struct {
+	Cell       *indexer.LiveCell
+	LockScript *types.Script
+	Outputs    []uint64
+}
+
+// MockTransactionBuilder is a mock transaction builder that captures calls
+// instead of talking to a CKB node.
+type MockTransactionBuilder struct {
+	// Hashes are returned by successive BroadcastTx calls. Once used up,
+	// the transaction's own hash is returned.
+	Hashes       []types.Hash
+	BuildErr     error
+	BroadcastErr error
+
+	splitCalls []SplitTxCall
+	broadcasts []*types.Transaction
+	byHash     map[types.Hash]*types.Transaction
+	mu         sync.Mutex
+}
+
+// NewMockTransactionBuilder creates a mock builder returning hashes in order.
+func NewMockTransactionBuilder(hashes ...types.Hash) *MockTransactionBuilder {
+	return &MockTransactionBuilder{Hashes: hashes}
+}
+
+// BuildSplitTx records the call and returns the unsigned transaction the
+// default builder produces, which needs no node.
+func (m *MockTransactionBuilder) BuildSplitTx(cell *indexer.LiveCell, lockScript *types.Script, outputs []uint64) (*types.Transaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.splitCalls = append(m.splitCalls, SplitTxCall{
+		Cell:       cell,
+		LockScript: lockScript,
+		Outputs:    append([]uint64(nil), outputs...),
+	})
+	if m.BuildErr != nil {
+		return nil, m.BuildErr
+	}
+
+	return perun.NewDefaultTransactionBuilder(nil, nil).BuildSplitTx(cell, lockScript, outputs)
+}
+
+// BroadcastTx records the transaction and returns the next configured hash
+// or error.
+func (m *MockTransactionBuilder) BroadcastTx(ctx context.Context, tx *types.Transaction) (types.Hash, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.broadcasts = append(m.broadcasts, tx)
+	if m.BroadcastErr != nil {
+		return types.Hash{}, m.BroadcastErr
+	}
+	hash := tx.ComputeHash()
+	if len(m.Hashes) > 0 {
+		hash = m.Hashes[0]
+		m.Hashes = m.Hashes[1:]
+	}
+	if m.byHash == nil {
+		m.byHash = make(map[types.Hash]*types.Transaction)
+	}
+	m.byHash[hash] = tx
+	return hash, nil
+}
+
+// Transaction returns the transaction BroadcastTx returned hash for, or
+// nil.
+func (m *MockTransactionBuilder) Transaction(hash types.Hash) *types.Transaction {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.byHash[hash]
+}
+
+// SplitCalls returns the BuildSplitTx calls made so far.
+func (m *MockTransactionBuilder) SplitCalls() []SplitTxCall {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]SplitTxCall(nil), m.splitCalls...)
+}
+
+// Broadcasts returns the transactions passed to BroadcastTx so far.
+func (m *MockTransactionBuilder) Broadcasts() []*types.Transaction {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*types.Transaction(nil), m.broadcasts...)
+}
@@ -4,14 +4,16 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
+	"github.com/airfi/airfi-perun-nervous/internal/router"
 	"github.com/airfi/airfi-perun-nervous/tests/mocks"
 )
 
 func TestMockRouter_AuthorizeMAC(t *testing.T) {
 	router := mocks.NewMockRouter()
 
-	err := router.AuthorizeMAC(context.Background(), "AA:BB:CC:DD:EE:FF", "192.168.1.100", "test")
+	err := router.AuthorizeMAC(context.Background(), "AA:BB:CC:DD:EE:FF", "192.168.1.100", "test", "")
 	if err != nil {
 		t.Fatalf("AuthorizeMAC failed: %v", err)
 	}
@@ -24,7 +26,7 @@ func TestMockRouter_AuthorizeMAC(t *testing.T) {
 func TestMockRouter_DeauthorizeMAC(t *testing.T) {
 	router := mocks.NewMockRouter()
 
-	router.AuthorizeMAC(context.Background(), "AA:BB:CC:DD:EE:FF", "192.168.1.100", "test")
+	router.AuthorizeMAC(context.Background(), "AA:BB:CC:DD:EE:FF", "192.168.1.100", "test", "")
 	router.DeauthorizeMAC(context.Background(), "AA:BB:CC:DD:EE:FF")
 
 	if router.IsAuthorized("AA:BB:CC:DD:EE:FF") {
@@ -39,8 +41,8 @@ func TestMockRouter_GetAuthorizedCount(t *testing.T) {
 		t.Error("Initial count should be 0")
 	}
 
-	router.AuthorizeMAC(context.Background(), "AA:BB:CC:DD:EE:FF", "192.168.1.100", "test1")
-	router.AuthorizeMAC(context.Background(), "11:22:33:44:55:66", "192.168.1.101", "test2")
+	router.AuthorizeMAC(context.Background(), "AA:BB:CC:DD:EE:FF", "192.168.1.100", "test1", "")
+	router.AuthorizeMAC(context.Background(), "11:22:33:44:55:66", "192.168.1.101", "test2", "")
 
 	if router.GetAuthorizedCount() != 2 {
 		t.Errorf("Count should be 2, got %d", router.GetAuthorizedCount())
@@ -50,7 +52,7 @@ func TestMockRouter_GetAuthorizedCount(t *testing.T) {
 func TestMockRouter_Reset(t *testing.T) {
 	router := mocks.NewMockRouter()
 
-	router.AuthorizeMAC(context.Background(), "AA:BB:CC:DD:EE:FF", "192.168.1.100", "test")
+	router.AuthorizeMAC(context.Background(), "AA:BB:CC:DD:EE:FF", "192.168.1.100", "test", "")
 	router.Reset()
 
 	if router.GetAuthorizedCount() != 0 {
@@ -62,7 +64,7 @@ func TestMockRouterWithError_AuthorizeError(t *testing.T) {
 	expectedErr := errors.New("authorize failed")
 	router := mocks.NewMockRouterWithError(expectedErr, nil)
 
-	err := router.AuthorizeMAC(context.Background(), "AA:BB:CC:DD:EE:FF", "192.168.1.100", "test")
+	err := router.AuthorizeMAC(context.Background(), "AA:BB:CC:DD:EE:FF", "192.168.1.100", "test", "")
 	if err != expectedErr {
 		t.Errorf("Expected error %v, got %v", expectedErr, err)
 	}
@@ -81,8 +83,8 @@ func TestMockRouterWithError_DeauthorizeError(t *testing.T) {
 func TestMockRouterWithTracking_TracksCalls(t *testing.T) {
 	router := mocks.NewMockRouterWithTracking()
 
-	router.AuthorizeMAC(context.Background(), "AA:BB:CC:DD:EE:FF", "192.168.1.100", "comment1")
-	router.AuthorizeMAC(context.Background(), "11:22:33:44:55:66", "192.168.1.101", "comment2")
+	router.AuthorizeMAC(context.Background(), "AA:BB:CC:DD:EE:FF", "192.168.1.100", "comment1", "")
+	router.AuthorizeMAC(context.Background(), "11:22:33:44:55:66", "192.168.1.101", "comment2", "")
 	router.DeauthorizeMAC(context.Background(), "AA:BB:CC:DD:EE:FF")
 
 	authCalls := router.GetAuthorizeCalls()
@@ -146,12 +148,12 @@ func TestMockRouter_CustomAuthorizeFunc(t *testing.T) {
 	router := mocks.NewMockRouter()
 
 	customCalled := false
-	router.AuthorizeFunc = func(ctx context.Context, mac, ip, comment string) error {
+	router.AuthorizeFunc = func(ctx context.Context, mac, ip, comment, preferredAP string) error {
 		customCalled = true
 		return nil
 	}
 
-	router.AuthorizeMAC(context.Background(), "AA:BB:CC:DD:EE:FF", "192.168.1.100", "test")
+	router.AuthorizeMAC(context.Background(), "AA:BB:CC:DD:EE:FF", "192.168.1.100", "test", "")
 
 	if !customCalled {
 		t.Error("Custom authorize function should be called")
@@ -167,7 +169,7 @@ func TestMockRouter_ConcurrentAccess(t *testing.T) {
 	for i := 0; i < 10; i++ {
 		go func(idx int) {
 			mac := "00:00:00:00:00:" + string(rune('A'+idx))
-			router.AuthorizeMAC(context.Background(), mac, "192.168.1.1", "test")
+			router.AuthorizeMAC(context.Background(), mac, "192.168.1.1", "test", "")
 			done <- true
 		}(i)
 	}
@@ -186,3 +188,117 @@ func TestMockRouter_ConcurrentAccess(t *testing.T) {
 		<-done
 	}
 }
+
+func TestMockRouter_GetClientInfo(t *testing.T) {
+	r := mocks.NewMockRouter()
+
+	if _, err := r.GetClientInfo(context.Background(), "aa:bb:cc:dd:ee:ff"); !errors.Is(err, router.ErrClientNotFound) {
+		t.Errorf("Expected ErrClientNotFound, got %v", err)
+	}
+
+	r.SetClientInfo("aa:bb:cc:dd:ee:ff", &router.ClientInfo{
+		MAC:            "aa:bb:cc:dd:ee:ff",
+		SignalStrength: -55,
+		BytesIn:        1024,
+		BytesOut:       512,
+	})
+
+	info, err := r.GetClientInfo(context.Background(), "aa:bb:cc:dd:ee:ff")
+	if err != nil {
+		t.Fatalf("GetClientInfo failed: %v", err)
+	}
+	if info.SignalStrength != -55 {
+		t.Errorf("SignalStrength: expected -55, got %d", info.SignalStrength)
+	}
+	if info.BytesIn+info.BytesOut != 1536 {
+		t.Errorf("Total bytes: expected 1536, got %d", info.BytesIn+info.BytesOut)
+	}
+}
+
+func TestMockRouter_Topology(t *testing.T) {
+	mock := mocks.NewMockRouter()
+
+	topology, err := mock.GetTopology(context.Background())
+	if err != nil || len(topology.APs) != 0 {
+		t.Fatalf("Expected empty topology, got %v (err %v)", topology, err)
+	}
+
+	mock.SetTopology(&router.NetworkTopology{APs: []router.AccessPoint{
+		{Name: "main", ConnectedClients: 2},
+		{Name: "lobby", ConnectedClients: 4},
+	}})
+	topology, _ = mock.GetTopology(context.Background())
+	if len(topology.APs) != 2 || topology.APs[1].Name != "lobby" {
+		t.Errorf("Unexpected topology: %+v", topology)
+	}
+}
+
+func TestMockRouterWithTracking_PreferredAP(t *testing.T) {
+	mock := mocks.NewMockRouterWithTracking()
+	mock.AuthorizeMAC(context.Background(), "AA:BB:CC:DD:EE:FF", "192.168.1.100", "test", "lobby")
+
+	calls := mock.GetAuthorizeCalls()
+	if len(calls) != 1 || calls[0].PreferredAP != "lobby" {
+		t.Errorf("Expected preferred AP to be tracked, got %+v", calls)
+	}
+}
+
+func TestSimulatedLatencyRouter_Latency(t *testing.T) {
+	mock := mocks.NewSimulatedLatencyRouter(1)
+	mock.SetLatency(50*time.Millisecond, 0)
+
+	start := time.Now()
+	if err := mock.AuthorizeMAC(context.Background(), "AA:BB:CC:DD:EE:FF", "192.168.1.100", "test", ""); err != nil {
+		t.Fatalf("AuthorizeMAC failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected at least 50ms delay, got %s", elapsed)
+	}
+	if !mock.IsAuthorized("AA:BB:CC:DD:EE:FF") {
+		t.Error("MAC should be authorized after the delay")
+	}
+
+	// A cancelled context cuts the delay short
+	mock.SetLatency(time.Hour, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := mock.DeauthorizeMAC(ctx, "AA:BB:CC:DD:EE:FF"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+	if !mock.IsAuthorized("AA:BB:CC:DD:EE:FF") {
+		t.Error("MAC should stay authorized when deauthorization is cut short")
+	}
+}
+
+func TestSimulatedLatencyRouter_Failures(t *testing.T) {
+	mock := mocks.NewSimulatedLatencyRouter(1)
+
+	mock.SetAuthorizationFailureRate(1)
+	if err := mock.AuthorizeMAC(context.Background(), "AA:BB:CC:DD:EE:FF", "192.168.1.100", "test", ""); !errors.Is(err, mocks.ErrSimulatedFailure) {
+		t.Errorf("Expected simulated failure, got %v", err)
+	}
+	if mock.IsAuthorized("AA:BB:CC:DD:EE:FF") {
+		t.Error("MAC should not be authorized after a failed call")
+	}
+
+	mock.SetConnectDropRate(1)
+	if err := mock.Ping(context.Background()); !errors.Is(err, mocks.ErrSimulatedFailure) {
+		t.Errorf("Expected dropped ping, got %v", err)
+	}
+	mock.SetConnectDropRate(0)
+	if err := mock.Ping(context.Background()); err != nil {
+		t.Errorf("Expected ping to succeed, got %v", err)
+	}
+
+	// Roughly half the calls fail at a 0.5 rate
+	mock.SetAuthorizationFailureRate(0.5)
+	failures := 0
+	for i := 0; i < 200; i++ {
+		if mock.AuthorizeMAC(context.Background(), "AA:BB:CC:DD:EE:FF", "192.168.1.100", "test", "") != nil {
+			failures++
+		}
+	}
+	if failures < 60 || failures > 140 {
+		t.Errorf("Expected about 100 of 200 calls to fail, got %d", failures)
+	}
+}